@@ -0,0 +1,448 @@
+// Package client talks to a golinks server over its HTTP API, so other Go
+// tools can manage links without hand-writing requests. Requests are
+// retried when the server is briefly unavailable.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of Config.
+const (
+	DefaultRetries    = 2
+	DefaultRetryDelay = 250 * time.Millisecond
+	// maxRetryDelay caps the wait before a retry, including one the
+	// server asks for with Retry-After.
+	maxRetryDelay = 10 * time.Second
+)
+
+// userAgent identifies the client in server logs. It must not look like a
+// chat unfurler, which would get a preview page instead of a redirect.
+const userAgent = "golinks-client"
+
+// Errors matched by the *Error of a failed request, for errors.Is.
+var (
+	ErrNotFound     = errors.New("link not found")
+	ErrConflict     = errors.New("slug already exists")
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// Error is a response of the server with an error status.
+type Error struct {
+	StatusCode int
+	Message    string
+	// Code names the kind of failure, such as "not_found" or
+	// "invalid_request"; empty if the server sent no JSON error.
+	Code string
+	// Fields are the request fields the server rejected, by JSON name.
+	Fields []FieldError
+	// RequestID finds the request in the server log.
+	RequestID string
+}
+
+// FieldError is a request field the server rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("golinks: %d %s", e.StatusCode, e.Message)
+}
+
+// Is matches ErrNotFound, ErrConflict and ErrUnauthorized by status.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	}
+	return false
+}
+
+// Link is a link as the server reports it.
+type Link struct {
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+	// Status is "active", "pending" (waiting for a second admin's
+	// approval) or "reserved" (no destination yet).
+	Status     string     `json:"status"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	ApprovedBy string     `json:"approved_by,omitempty"`
+	Public     bool       `json:"public"`
+	Clicks     int        `json:"clicks"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// ListOpens are the clicks opened from the list page.
+	ListOpens int    `json:"list_opens,omitempty"`
+	Pin       int    `json:"pin,omitempty"`
+	HitBudget int    `json:"hit_budget,omitempty"`
+	Failover  string `json:"failover,omitempty"`
+	Referrer  string `json:"referrer,omitempty"`
+	// Fields are the custom fields the server's admins defined, by name.
+	Fields    map[string]string `json:"fields,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// AddLinkRequest describes a new link. Without a slug the server generates
+// one if it has a slug strategy, or SlugStrategy names one.
+type AddLinkRequest struct {
+	Slug         string `json:"slug,omitempty"`
+	URL          string `json:"url"`
+	Public       bool   `json:"public,omitempty"`
+	SlugStrategy string `json:"slug_strategy,omitempty"`
+	Title        string `json:"title,omitempty"`
+
+	// Settings stored together with the link: if any is rejected, no link
+	// is added.
+	Aliases      []string     `json:"aliases,omitempty"`
+	Collections  []string     `json:"collections,omitempty"`
+	Access       []AccessRule `json:"access,omitempty"`
+	ReviewAt     string       `json:"review_at,omitempty"`
+	ReviewMonths int          `json:"review_months,omitempty"`
+	Pin          int          `json:"pin,omitempty"`
+	HitBudget    int          `json:"hit_budget,omitempty"`
+	Failover     string       `json:"failover,omitempty"`
+	Referrer     string       `json:"referrer,omitempty"`
+	// Fields are custom fields, by name; the server rejects names its
+	// admins have not defined.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// AccessRule is a weekly window in which clients of an IP group may open a
+// link, e.g. "kids" on "fri-sat" from "15:00" to "21:00".
+type AccessRule struct {
+	Group string `json:"group"`
+	Days  string `json:"days,omitempty"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ListOptions filter and page List. Zero values use the server defaults:
+// all links, newest first, 50 a page.
+type ListOptions struct {
+	// Query keeps links whose slug or URL contains it, ignoring case.
+	Query string
+	// Fields keeps links whose custom fields have the given values,
+	// ignoring case; an empty value keeps links without the field.
+	Fields map[string]string
+	// Sort is "created_at" or "slug"; Order is "asc" or "desc".
+	Sort  string
+	Order string
+	Page  int
+	Limit int
+}
+
+// LinkPage is one page of List.
+type LinkPage struct {
+	Links []Link `json:"links"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+	Total int    `json:"total"`
+	Pages int    `json:"pages"`
+}
+
+// Config selects the server and how to reach it.
+type Config struct {
+	// BaseURL is where the server is served, e.g. "https://go.example.com".
+	BaseURL string
+	// Username and Password log in as an admin; empty for servers without
+	// admin authentication.
+	Username string
+	Password string
+	// HTTPClient sends the requests; nil means one with a 30s timeout.
+	HTTPClient *http.Client
+	// Retries is how often a request is retried after a network error or
+	// a 429, 502, 503 or 504 response; 0 means DefaultRetries, negative
+	// none. Adding and renaming links are only retried on 429, as other
+	// failures may have applied them. RetryDelay is the first wait, doubled on each retry;
+	// 0 means DefaultRetryDelay.
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// Client is a golinks API client. It is safe for concurrent use.
+type Client struct {
+	cfg  Config
+	base *url.URL
+	// noRedirect is the HTTP client with redirects turned off, for
+	// Resolve.
+	noRedirect *http.Client
+}
+
+// New returns a Client for the server at cfg.BaseURL.
+func New(cfg Config) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("golinks: invalid base URL %q", cfg.BaseURL)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = DefaultRetries
+	}
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = DefaultRetryDelay
+	}
+	noRedirect := *cfg.HTTPClient
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Client{cfg: cfg, base: base, noRedirect: &noRedirect}, nil
+}
+
+// AddLink adds a link and returns it as stored. A link to a sensitive
+// destination comes back with Status "pending".
+func (c *Client) AddLink(ctx context.Context, req AddLinkRequest) (Link, error) {
+	var link Link
+	err := c.do(ctx, http.MethodPost, "/api/v1/links", nil, req, &link)
+	return link, err
+}
+
+// GetLink returns the link at slug.
+func (c *Client) GetLink(ctx context.Context, slug string) (Link, error) {
+	var link Link
+	err := c.do(ctx, http.MethodGet, "/api/v1/links/"+escapeSlug(slug), nil, nil, &link)
+	return link, err
+}
+
+// UpdateLink points the link at slug to url and returns it as stored.
+func (c *Client) UpdateLink(ctx context.Context, slug, url string) (Link, error) {
+	var link Link
+	err := c.do(ctx, http.MethodPut, "/api/v1/links/"+escapeSlug(slug), nil, map[string]string{"url": url}, &link)
+	return link, err
+}
+
+// RenameLink moves the link at from to the slug to. With alias, from keeps
+// redirecting to the link.
+func (c *Client) RenameLink(ctx context.Context, from, to string, alias bool) error {
+	req := map[string]any{"from": from, "to": to, "alias": alias}
+	return c.do(ctx, http.MethodPost, "/admin/rename", nil, req, nil)
+}
+
+// RemoveLink deletes the link at slug.
+func (c *Client) RemoveLink(ctx context.Context, slug string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/links/"+escapeSlug(slug), nil, nil, nil)
+}
+
+// List returns one page of links.
+func (c *Client) List(ctx context.Context, opts ListOptions) (LinkPage, error) {
+	query := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	set("q", opts.Query)
+	set("sort", opts.Sort)
+	set("order", opts.Order)
+	for name, value := range opts.Fields {
+		query.Set("field."+name, value)
+	}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	var page LinkPage
+	err := c.do(ctx, http.MethodGet, "/api/v1/links", query, nil, &page)
+	return page, err
+}
+
+// ListAll returns every link matching opts, fetching page after page from
+// opts.Page (default 1) on, 500 links at a time unless opts.Limit is set.
+func (c *Client) ListAll(ctx context.Context, opts ListOptions) ([]Link, error) {
+	opts.Page = max(opts.Page, 1)
+	if opts.Limit == 0 {
+		opts.Limit = 500
+	}
+	var links []Link
+	for {
+		page, err := c.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, page.Links...)
+		if page.Page >= page.Pages {
+			return links, nil
+		}
+		opts.Page++
+	}
+}
+
+// Resolve returns where go/slug leads, as a browser following it would be
+// sent, without needing admin credentials. Renamed slugs are followed.
+func (c *Client) Resolve(ctx context.Context, slug string) (string, error) {
+	u := c.base.JoinPath(escapeSlug(slug))
+	var target string
+	err := c.retry(ctx, true, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := c.noRedirect.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			target = resp.Header.Get("Location")
+			return nil
+		}
+		return responseError(resp)
+	})
+	return target, err
+}
+
+// do sends a JSON request to path and decodes the response into out, if
+// not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	u := c.base.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	return c.retry(ctx, method != http.MethodPost, func() error {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.cfg.Username != "" || c.cfg.Password != "" {
+			req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+		}
+		resp, err := c.cfg.HTTPClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return responseError(resp)
+		}
+		if out == nil {
+			return nil
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("golinks: decoding response: %w", err)
+		}
+		return nil
+	})
+}
+
+// retryError is a failed attempt that may be retried, after Wait if the
+// server asked for one.
+type retryError struct {
+	err  error
+	wait time.Duration
+}
+
+func (e *retryError) Error() string { return e.err.Error() }
+func (e *retryError) Unwrap() error { return e.err }
+
+// retry runs attempt until it succeeds, fails for good or the retries are
+// used up. Network errors are only retried for idempotent requests.
+func (c *Client) retry(ctx context.Context, idempotent bool, attempt func() error) error {
+	delay := c.cfg.RetryDelay
+	for n := 0; ; n++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		var apiErr *Error
+		var retry *retryError
+		switch {
+		case errors.As(err, &retry):
+			if !idempotent && retry.err.(*Error).StatusCode != http.StatusTooManyRequests {
+				return retry.err
+			}
+		case errors.As(err, &apiErr), ctx.Err() != nil, !idempotent:
+			return err
+		}
+		if n >= c.cfg.Retries {
+			if retry != nil {
+				return retry.err
+			}
+			return err
+		}
+
+		wait := delay
+		if retry != nil && retry.wait > 0 {
+			wait = retry.wait
+		}
+		select {
+		case <-time.After(min(wait, maxRetryDelay)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// responseError reads the error of a failed response: the message of an
+// /api/v1 JSON error, or the plain text body of the other endpoints. HTML
+// pages are left out. Statuses worth retrying come wrapped in a
+// *retryError.
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	e := &Error{StatusCode: resp.StatusCode}
+	switch ct := resp.Header.Get("Content-Type"); {
+	case strings.HasPrefix(ct, "application/json"):
+		var body struct {
+			Error struct {
+				Code      string       `json:"code"`
+				Message   string       `json:"message"`
+				Fields    []FieldError `json:"fields"`
+				RequestID string       `json:"request_id"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			e.Code, e.Message, e.Fields, e.RequestID = body.Error.Code, body.Error.Message, body.Error.Fields, body.Error.RequestID
+		}
+	case strings.HasPrefix(ct, "text/plain"):
+		e.Message = strings.TrimSpace(string(data))
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		retry := &retryError{err: e}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retry.wait = time.Duration(secs) * time.Second
+		}
+		return retry
+	}
+	return e
+}
+
+// escapeSlug escapes slug for a URL path. Slugs may contain "/", which is
+// kept as a path separator.
+func escapeSlug(slug string) string {
+	return (&url.URL{Path: slug}).EscapedPath()
+}
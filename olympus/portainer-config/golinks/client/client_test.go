@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+func newTestClient(t *testing.T, h http.Handler, cfg Config) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	cfg.BaseURL = srv.URL
+	if cfg.RetryDelay == 0 {
+		cfg.RetryDelay = time.Millisecond
+	}
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	api := httpapi.New(httpapi.Config{Admins: map[string]string{"alice": "pw"}}, st, httpapi.Pages{Index: http.NotFoundHandler()})
+	c := newTestClient(t, api.Handler(), Config{Username: "alice", Password: "pw"})
+
+	link, err := c.AddLink(ctx, AddLinkRequest{Slug: "team/wiki", URL: "https://wiki.example.com"})
+	if err != nil || link.Slug != "team/wiki" || link.Status != "active" || link.CreatedBy != "alice" || link.CreatedAt.IsZero() {
+		t.Fatalf("AddLink = %+v, %v", link, err)
+	}
+	if _, err := c.AddLink(ctx, AddLinkRequest{Slug: "team/wiki", URL: "https://other.example.com"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate AddLink: %v", err)
+	}
+	var apiErr *Error
+	if _, err := c.AddLink(ctx, AddLinkRequest{Slug: "ftp", URL: "ftp://files.example.com"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message == "" ||
+		apiErr.Code != "invalid_request" || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "url" || apiErr.RequestID == "" {
+		t.Errorf("invalid AddLink: %v", err)
+	}
+	for i := range 3 {
+		c.AddLink(ctx, AddLinkRequest{Slug: fmt.Sprintf("l%d", i), URL: "https://example.com"})
+	}
+
+	if link, err := c.UpdateLink(ctx, "team/wiki", "https://new.example.com"); err != nil || link.URL != "https://new.example.com" {
+		t.Errorf("UpdateLink = %+v, %v", link, err)
+	}
+	if target, err := c.Resolve(ctx, "team/wiki"); err != nil || target != "https://new.example.com" {
+		t.Errorf("Resolve = %q, %v", target, err)
+	}
+	if _, err := c.Resolve(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve of a missing slug: %v", err)
+	}
+
+	page, err := c.List(ctx, ListOptions{Sort: "slug", Limit: 2})
+	if err != nil || page.Total != 4 || page.Pages != 2 || len(page.Links) != 2 || page.Links[0].Slug != "l0" {
+		t.Errorf("List = %+v, %v", page, err)
+	}
+	all, err := c.ListAll(ctx, ListOptions{Sort: "slug", Limit: 3})
+	if err != nil || len(all) != 4 || all[3].Slug != "team/wiki" {
+		t.Errorf("ListAll = %+v, %v", all, err)
+	}
+
+	if err := c.RenameLink(ctx, "team/wiki", "wiki", true); err != nil {
+		t.Errorf("RenameLink: %v", err)
+	}
+	if err := c.RenameLink(ctx, "l0", "l1", false); !errors.Is(err, ErrConflict) {
+		t.Errorf("RenameLink onto a taken slug: %v", err)
+	}
+	if target, err := c.Resolve(ctx, "team/wiki"); err != nil || target != "https://new.example.com" {
+		t.Errorf("Resolve of the alias = %q, %v", target, err)
+	}
+
+	if err := c.RemoveLink(ctx, "wiki"); err != nil {
+		t.Errorf("RemoveLink: %v", err)
+	}
+	if _, err := c.GetLink(ctx, "wiki"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLink after removal: %v", err)
+	}
+
+	bad := newTestClient(t, api.Handler(), Config{Username: "alice", Password: "wrong"})
+	if _, err := bad.List(ctx, ListOptions{}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong password: %v", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"slug":"wiki","url":"https://wiki.example.com","status":"active"}`)
+	})
+
+	c := newTestClient(t, flaky, Config{})
+	if link, err := c.GetLink(ctx, "wiki"); err != nil || link.URL != "https://wiki.example.com" || calls.Load() != 3 {
+		t.Errorf("GetLink = %+v, %v after %d calls", link, err, calls.Load())
+	}
+
+	// Adding is not retried, as the link may have been added
+	calls.Store(0)
+	if _, err := c.AddLink(ctx, AddLinkRequest{Slug: "wiki", URL: "https://wiki.example.com"}); err == nil || calls.Load() != 1 {
+		t.Errorf("AddLink = %v after %d calls", err, calls.Load())
+	}
+
+	calls.Store(0)
+	c = newTestClient(t, flaky, Config{Retries: -1})
+	var apiErr *Error
+	if _, err := c.GetLink(ctx, "wiki"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("GetLink without retries = %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestNewInvalidBaseURL(t *testing.T) {
+	for _, base := range []string{"", "go.example.com", "ftp://go.example.com"} {
+		if _, err := New(Config{BaseURL: base}); err == nil {
+			t.Errorf("New(%q) accepted", base)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golinks/internal/accesslog"
+	"golinks/internal/approval"
+	"golinks/internal/backup"
+	"golinks/internal/banner"
+	"golinks/internal/budget"
+	"golinks/internal/captcha"
+	"golinks/internal/clicks"
+	"golinks/internal/gitops"
+	"golinks/internal/health"
+	"golinks/internal/httpapi"
+	"golinks/internal/jobs"
+	"golinks/internal/linksync"
+	"golinks/internal/logging"
+	"golinks/internal/metrics"
+	"golinks/internal/mirror"
+	"golinks/internal/peers"
+	"golinks/internal/probe"
+	"golinks/internal/ratelimit"
+	"golinks/internal/reminder"
+	"golinks/internal/report"
+	"golinks/internal/shadow"
+	"golinks/internal/snapshot"
+	"golinks/internal/sshadmin"
+	"golinks/internal/store"
+	"golinks/internal/web"
+)
+
+// app is a fully wired golinks instance.
+type app struct {
+	store   store.Store
+	handler http.Handler
+	// accessLog is closed with the app, if configured.
+	accessLog *accesslog.Logger
+	// stop ends background jobs; jobs that must finish before the store
+	// closes are tracked by jobs.
+	stop context.CancelFunc
+	jobs sync.WaitGroup
+}
+
+// openStore opens the database of cfg: the PostgreSQL or MySQL server at
+// DATABASE_URL if set, else the SQLite or bbolt file at DB_PATH.
+func openStore(cfg config) (store.Store, error) {
+	var st store.Store
+	var err error
+	switch {
+	case cfg.databaseURL != "":
+		st, err = store.OpenServer(cfg.databaseURL, store.ServerOptions{QueryTimeout: cfg.queryTimeout, MaxOpenConns: cfg.maxOpenConns, MaxIdleConns: cfg.maxIdleConns})
+	case cfg.dbEngine == dbBolt:
+		st, err = store.OpenBolt(cfg.dbPath)
+	default:
+		opts := cfg.sqlite
+		opts.QueryTimeout = cfg.queryTimeout
+		st, err = store.OpenSQLite(cfg.dbPath, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return st, nil
+}
+
+// newApp opens the database and builds the HTTP handler for cfg.
+func newApp(cfg config) (*app, error) {
+	logging.SetLevel(cfg.logLevel)
+
+	db, err := openStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.restoreFrom != "" {
+		if err := restoreOnBoot(cfg, db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	st := db
+	if cfg.redisURL != "" {
+		cache, err := store.OpenRedisCache(db, cfg.redisURL, cfg.redis)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		st = cache
+	}
+	if cfg.linkCache.Size > 0 {
+		st = store.NewLRUCache(st, cfg.linkCache)
+	}
+
+	api := cfg.api
+	if b, ok := db.(httpapi.Backuper); ok {
+		api.Backups = b
+	}
+	checker := health.NewChecker(st, cfg.healthInterval)
+	webCfg := cfg.web
+	webCfg.Health = checker
+	webCfg.LinkFields = api.LinkFields
+	api.Health = checker
+	if cfg.snapshot.Enabled() {
+		archiver, err := snapshot.New(cfg.snapshot)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		api.Snapshots = archiver
+		webCfg.Snapshots = archiver
+	}
+
+	if cfg.peers.Enabled() {
+		api.Peers = peers.New(cfg.peers)
+	}
+	if cfg.shadow.Enabled() {
+		api.Shadow = shadow.New(cfg.shadow)
+	}
+	if cfg.rateLimit.Enabled() {
+		api.RateLimit = ratelimit.New(cfg.rateLimit)
+	}
+	if cfg.guestLimit.Enabled() {
+		api.GuestLimit = ratelimit.New(cfg.guestLimit)
+		if cfg.captcha.Enabled() {
+			api.Captcha = captcha.New(cfg.captcha)
+		}
+	}
+	if cfg.mirror.Enabled() {
+		api.MirrorOf = cfg.mirror.Upstream
+		webCfg.MirrorOf = cfg.mirror.Upstream
+	}
+	var syncer *linksync.Syncer
+	if cfg.sync.Enabled() {
+		if syncer, err = linksync.New(cfg.sync, st); err != nil {
+			st.Close()
+			return nil, err
+		}
+		api.Sync = syncer
+	}
+
+	board, err := banner.Open(cfg.bannerPath)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+	api.Banner = board
+	webCfg.Banner = board
+
+	// Setup routes
+	pages, err := web.New(webCfg, st)
+	if err != nil {
+		st.Close()
+		return nil, fmt.Errorf("failed to load templates: %w", err)
+	}
+	usage := report.NewUsage()
+	reporter, err := report.New(cfg.report, st, usage)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+	api.Usage = usage
+	api.Budgets = budget.New(cfg.budget)
+	if cfg.approval.Enabled() {
+		api.Approvals = approval.New(cfg.approval)
+	}
+	recorder := clicks.New(st, cfg.clickRetention)
+	recorder.SetFlushInterval(cfg.clickFlush)
+	if cfg.clickSampling.Enabled() {
+		recorder.SetSampler(clicks.NewSampler(cfg.clickSampling))
+	}
+	api.Clicks = recorder
+	if cfg.logSampling.Enabled() {
+		api.RedirectLog = clicks.NewSampler(cfg.logSampling)
+	}
+	// Bulk jobs pause briefly every few links so redirects keep priority
+	queue := jobs.NewQueue(16, 20*time.Millisecond)
+	api.Jobs = queue
+
+	p := httpapi.Pages{
+		Index:      pages,
+		Poster:     http.HandlerFunc(pages.ServePoster),
+		Reports:    reporter,
+		Stats:      pages.StatsPage(usage),
+		Preview:    pages.ServePreview,
+		Collection: pages.ServeCollection,
+		Closed:     pages.ServeClosed,
+		Reserved:   pages.ServeReserved,
+		NotFound:   pages.ServeNotFound,
+		Error:      pages.ServeError,
+		AddForm:    pages.ServeAddForm,
+		Info:       pages.ServeInfo,
+		Visited:    pages.RememberVisit,
+	}
+	if cfg.sitemap {
+		p.Sitemap = http.HandlerFunc(pages.ServeSitemap)
+	}
+	remind := reminder.New(cfg.reminder, st)
+	scheduler := jobs.NewScheduler()
+	scheduler.Register("health-check", jobs.Schedule{Every: cfg.healthInterval}, checker.Check)
+	if cfg.mirror.Enabled() {
+		// Review reminders are the upstream's to send
+		scheduler.Register("mirror-sync", jobs.Schedule{Every: cfg.mirror.Interval}, mirror.New(cfg.mirror, st).Sync)
+	} else {
+		scheduler.Register("review-reminders", jobs.Schedule{Every: reminder.CheckEvery}, func(ctx context.Context) error {
+			return remind.Check(ctx, time.Now())
+		})
+	}
+	scheduler.Register("usage-report", jobs.Schedule{Next: reporter.NextRun, Label: reporter.Schedule()}, func(ctx context.Context) error {
+		_, err := reporter.Generate(ctx)
+		return err
+	})
+	scheduler.Register("click-prune", jobs.Schedule{Every: clicks.PruneEvery}, recorder.Prune)
+	if syncer != nil {
+		scheduler.Register("link-sync", jobs.Schedule{Every: cfg.sync.Interval}, syncer.Sync)
+	}
+	if cfg.backup.Enabled() {
+		// The config only allows backups of SQLite
+		scheduler.Register("s3-backup", jobs.Schedule{Every: cfg.backup.Interval}, backup.New(cfg.backup, db.(backup.Source)).Run)
+	}
+	api.Scheduler = scheduler
+	if cfg.healthInterval > 0 {
+		p.Status = pages.StatusPage(checker, false)
+		p.StatusDetail = pages.StatusPage(checker, true)
+	}
+	if cfg.probe.Enabled() {
+		// The first probe waits an interval for the listener to start
+		prober := probe.New(cfg.probe)
+		api.Probe = prober
+		scheduler.Register("uptime-probe", jobs.Schedule{
+			Next:  func(now time.Time) time.Time { return now.Add(cfg.probe.Interval) },
+			Label: "every " + cfg.probe.Interval.String(),
+		}, prober.Probe)
+	}
+	if cfg.metrics {
+		m := metrics.New(st, checker)
+		m.SetProber(api.Probe)
+		api.Lookups = m
+		p.Metrics = m
+		p.AlertRules = m.RulesHandler()
+	}
+	// Set once the app is returned, whose Close closes the access log
+	started := false
+	if cfg.accessLog.Enabled() {
+		accessLog, err := accesslog.Open(cfg.accessLog)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		api.AccessLog = accessLog
+		defer func() {
+			if !started {
+				accessLog.Close()
+			}
+		}()
+	}
+	// Concurrent redirects for the same slug share one database read
+	server := httpapi.New(api, store.Coalesce(st), p)
+	if cfg.gitops.Enabled() {
+		syncer := gitops.New(cfg.gitops, st, server)
+		scheduler.Register("gitops-sync", jobs.Schedule{Every: cfg.gitops.Interval}, syncer.Check)
+	}
+
+	var sshListener net.Listener
+	var sshServer *sshadmin.Server
+	if cfg.ssh.Addr != "" {
+		if sshServer, err = sshadmin.New(cfg.ssh, st, server); err != nil {
+			st.Close()
+			return nil, err
+		}
+		if sshListener, err = net.Listen("tcp", cfg.ssh.Addr); err != nil {
+			st.Close()
+			return nil, fmt.Errorf("failed to start SSH admin server: %w", err)
+		}
+	}
+
+	handler := server.Handler()
+	if len(cfg.aliasDomains) > 0 {
+		handler = httpapi.AliasRedirect(cfg.aliasTarget, cfg.aliasDomains, handler)
+	}
+	var aliasServer *http.Server
+	var aliasListener net.Listener
+	if cfg.aliasListenAddr != "" {
+		if aliasListener, err = net.Listen("tcp", cfg.aliasListenAddr); err != nil {
+			if sshListener != nil {
+				sshListener.Close()
+			}
+			st.Close()
+			return nil, fmt.Errorf("failed to start alias redirect listener: %w", err)
+		}
+		aliasServer = &http.Server{Handler: httpapi.AliasRedirect(cfg.aliasTarget, nil, nil)}
+	}
+
+	a := &app{store: st, handler: handler, accessLog: api.AccessLog}
+	started = true
+	ctx, stop := context.WithCancel(context.Background())
+	a.stop = stop
+	// The recorder writes its queued clicks when stopped, and running jobs
+	// stop before the store closes
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		recorder.Run(ctx)
+	}()
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		queue.Run(ctx)
+	}()
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		scheduler.Run(ctx)
+	}()
+	if sshServer != nil {
+		go func() {
+			if err := sshServer.Serve(ctx, sshListener); err != nil {
+				slog.Error("SSH admin server failed", "error", err)
+			}
+		}()
+	}
+	if aliasServer != nil {
+		go func() {
+			if err := aliasServer.Serve(aliasListener); !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Alias redirect listener failed", "error", err)
+			}
+		}()
+		context.AfterFunc(ctx, func() { aliasServer.Close() })
+	}
+
+	return a, nil
+}
+
+func (a *app) Close() error {
+	a.stop()
+	a.jobs.Wait()
+	if a.accessLog != nil {
+		a.accessLog.Close()
+	}
+	return a.store.Close()
+}
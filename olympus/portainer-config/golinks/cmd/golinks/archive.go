@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"golinks/internal/archive"
+	"golinks/internal/store"
+)
+
+// runCommand runs a maintenance command given on the command line instead
+// of the server: "export FILE" or "import FILE", where FILE "-" is stdout
+// or stdin.
+func runCommand(cfg config, args []string) error {
+	if len(args) != 2 || (args[0] != "export" && args[0] != "import") {
+		return fmt.Errorf("usage: golinks [export FILE | import FILE]")
+	}
+	st, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	if args[0] == "export" {
+		return exportArchive(ctx, st, args[1])
+	}
+	return importArchive(ctx, st, args[1])
+}
+
+func exportArchive(ctx context.Context, st store.Store, path string) error {
+	var w io.Writer = os.Stdout
+	var f *os.File
+	if path != "-" {
+		var err error
+		if f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600); err != nil {
+			return err
+		}
+		w = f
+	}
+	m, err := archive.Export(ctx, st, w)
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	slog.Info("Exported archive", "links", m.Links, "collections", m.Collections, "path", path)
+	return nil
+}
+
+func importArchive(ctx context.Context, st store.Store, path string) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	res, err := archive.Import(ctx, st, r)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	slog.Info("Imported archive", "links", res.Links, "collections", res.Collections, "aliases", res.Aliases, "path", path,
+		"created", res.Manifest.CreatedAt.Format("2006-01-02 15:04"))
+	if len(res.Skipped) > 0 {
+		slog.Info("Skipped already existing", "skipped", len(res.Skipped), "slugs", strings.Join(res.Skipped, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,779 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golinks/internal/accesslog"
+	"golinks/internal/approval"
+	"golinks/internal/backup"
+	"golinks/internal/budget"
+	"golinks/internal/captcha"
+	"golinks/internal/clicks"
+	"golinks/internal/gitops"
+	"golinks/internal/httpapi"
+	"golinks/internal/linksync"
+	"golinks/internal/logging"
+	"golinks/internal/mirror"
+	"golinks/internal/notify"
+	"golinks/internal/peers"
+	"golinks/internal/probe"
+	"golinks/internal/ratelimit"
+	"golinks/internal/reminder"
+	"golinks/internal/report"
+	"golinks/internal/shadow"
+	"golinks/internal/snapshot"
+	"golinks/internal/sshadmin"
+	"golinks/internal/store"
+	"golinks/internal/web"
+)
+
+// Engines of the database file at DB_PATH.
+const (
+	dbSQLite = "sqlite"
+	dbBolt   = "bolt"
+)
+
+// config is the server configuration, read from the environment.
+type config struct {
+	dbPath string
+	// dbEngine is the kind of file at dbPath: dbSQLite or dbBolt.
+	dbEngine string
+	// sqlite tunes the SQLite file; queryTimeout is added when it is
+	// opened.
+	sqlite store.SQLiteOptions
+	// databaseURL, if set, selects a database server instead of the
+	// file at dbPath.
+	databaseURL string
+	// redisURL, if set, puts a Redis read cache in front of the database.
+	redisURL string
+	redis    store.RedisOptions
+	// linkCache keeps the most used links in memory if its Size is set.
+	linkCache    store.LRUOptions
+	listenAddr   string
+	queryTimeout time.Duration
+	// maxOpenConns and maxIdleConns size the database connection pool; 0
+	// leaves it to the store.
+	maxOpenConns int
+	maxIdleConns int
+	logLevel     logging.Level
+	logFormat    string
+	accessLog    accesslog.Config
+	sitemap      bool
+	metrics      bool
+	// healthInterval is how often link destinations are checked; zero
+	// disables the checks and the status page.
+	healthInterval time.Duration
+	// clickRetention is how long single clicks are kept; zero keeps them
+	// forever. Link click totals are never pruned.
+	clickRetention time.Duration
+	// clickFlush is how often queued clicks are written.
+	clickFlush time.Duration
+	// clickSampling and logSampling thin out the stored clicks and the
+	// logged redirects of hot links.
+	clickSampling clicks.Sampling
+	logSampling   clicks.Sampling
+	// restoreFrom names a backup to restore into an empty database on
+	// startup: "s3", a URL or a file.
+	restoreFrom string
+	// bannerPath keeps the announcement banner across restarts.
+	bannerPath string
+	// Legacy short domains are redirected to aliasTarget: aliasDomains by
+	// Host on the main listener, and every request on aliasListenAddr.
+	aliasTarget     string
+	aliasDomains    []string
+	aliasListenAddr string
+	api             httpapi.Config
+	web             web.Config
+	report          report.Config
+	reminder        reminder.Config
+	budget          budget.Config
+	approval        approval.Config
+	snapshot        snapshot.Config
+	peers           peers.Config
+	gitops          gitops.Config
+	mirror          mirror.Config
+	sync            linksync.Config
+	shadow          shadow.Config
+	probe           probe.Config
+	rateLimit       ratelimit.Config
+	backup          backup.Config
+	ssh             sshadmin.Config
+	// guestLimit limits the link suggestions of each guest; disabled, the
+	// guest form is not served.
+	guestLimit ratelimit.Config
+	captcha    captcha.Config
+}
+
+func loadConfig() (config, error) {
+	cfg := config{
+		dbPath:     getEnv("DB_PATH", "./data/links.db"),
+		listenAddr: getEnv("LISTEN_ADDR", "0.0.0.0:8080"),
+		bannerPath: getEnv("BANNER_FILE", "./data/banner.json"),
+	}
+
+	var err error
+	if cfg.queryTimeout, err = getDuration("DB_QUERY_TIMEOUT", 5*time.Second); err != nil {
+		return config{}, err
+	}
+	cfg.databaseURL = os.Getenv("DATABASE_URL")
+	if cfg.databaseURL != "" && !store.IsServerURL(cfg.databaseURL) {
+		return config{}, fmt.Errorf("DATABASE_URL must be a postgres:// or mysql:// URL")
+	}
+	cfg.dbEngine = getEnv("DB_ENGINE", dbSQLite)
+	switch cfg.dbEngine {
+	case dbSQLite:
+	case dbBolt:
+		cfg.dbPath = getEnv("DB_PATH", "./data/links.bolt")
+		if cfg.databaseURL != "" {
+			return config{}, fmt.Errorf("DB_ENGINE=bolt cannot be combined with DATABASE_URL")
+		}
+	default:
+		return config{}, fmt.Errorf("DB_ENGINE must be %s or %s", dbSQLite, dbBolt)
+	}
+	cfg.sqlite = store.SQLiteOptions{
+		JournalMode: os.Getenv("SQLITE_JOURNAL_MODE"),
+		Synchronous: os.Getenv("SQLITE_SYNCHRONOUS"),
+	}
+	if cfg.sqlite.BusyTimeout, err = getDuration("SQLITE_BUSY_TIMEOUT", 5*time.Second); err != nil {
+		return config{}, err
+	}
+	if cfg.sqlite.BusyTimeout <= 0 {
+		return config{}, fmt.Errorf("SQLITE_BUSY_TIMEOUT must be positive")
+	}
+	if cfg.maxOpenConns, err = getInt("DB_MAX_OPEN_CONNS", 0); err != nil {
+		return config{}, err
+	}
+	if cfg.maxIdleConns, err = getInt("DB_MAX_IDLE_CONNS", 0); err != nil {
+		return config{}, err
+	}
+	if cfg.maxOpenConns < 0 || cfg.maxIdleConns < 0 {
+		return config{}, fmt.Errorf("DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	cfg.sqlite.MaxOpenConns, cfg.sqlite.MaxIdleConns = cfg.maxOpenConns, cfg.maxIdleConns
+	if err := cfg.sqlite.Validate(); err != nil {
+		return config{}, fmt.Errorf("SQLITE_JOURNAL_MODE or SQLITE_SYNCHRONOUS: %w", err)
+	}
+	cfg.redisURL = os.Getenv("REDIS_URL")
+	if u, err := url.Parse(cfg.redisURL); cfg.redisURL != "" && (err != nil || (u.Scheme != "redis" && u.Scheme != "rediss")) {
+		return config{}, fmt.Errorf("REDIS_URL must be a redis:// or rediss:// URL")
+	}
+	if cfg.redis.TTL, err = getDuration("REDIS_CACHE_TTL", time.Hour); err != nil {
+		return config{}, err
+	}
+	if cfg.redis.TTL <= 0 {
+		return config{}, fmt.Errorf("REDIS_CACHE_TTL must be positive")
+	}
+	cfg.redis.Prefix = getEnv("REDIS_PREFIX", "golinks:")
+	if cfg.linkCache.Size, err = getInt("LINK_CACHE_SIZE", 0); err != nil {
+		return config{}, err
+	}
+	if cfg.linkCache.TTL, err = getDuration("LINK_CACHE_TTL", 10*time.Minute); err != nil {
+		return config{}, err
+	}
+	if cfg.linkCache.MissTTL, err = getDuration("LINK_CACHE_MISS_TTL", 30*time.Second); err != nil {
+		return config{}, err
+	}
+	if cfg.linkCache.Size < 0 || cfg.linkCache.TTL <= 0 || cfg.linkCache.MissTTL < 0 {
+		return config{}, fmt.Errorf("LINK_CACHE_SIZE and LINK_CACHE_MISS_TTL must not be negative and LINK_CACHE_TTL must be positive")
+	}
+	if cfg.logLevel, err = logging.ParseLevel(os.Getenv("LOG_LEVEL")); err != nil {
+		return config{}, fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	cfg.logFormat = strings.ToLower(getEnv("LOG_FORMAT", logging.FormatText))
+	if !logging.ValidFormat(cfg.logFormat) {
+		return config{}, fmt.Errorf("LOG_FORMAT must be %q or %q", logging.FormatText, logging.FormatJSON)
+	}
+	cfg.accessLog = accesslog.Config{
+		Path:   os.Getenv("ACCESS_LOG"),
+		Format: strings.ToLower(getEnv("ACCESS_LOG_FORMAT", accesslog.Combined)),
+	}
+	if !accesslog.ValidFormat(cfg.accessLog.Format) {
+		return config{}, fmt.Errorf("ACCESS_LOG_FORMAT must be %q or %q", accesslog.Combined, accesslog.JSON)
+	}
+	if cfg.sitemap, err = getBool("SITEMAP", false); err != nil {
+		return config{}, err
+	}
+	if cfg.metrics, err = getBool("METRICS", false); err != nil {
+		return config{}, err
+	}
+	if cfg.healthInterval, err = getDuration("HEALTH_CHECK_INTERVAL", 0); err != nil {
+		return config{}, err
+	}
+	if cfg.healthInterval < 0 {
+		return config{}, fmt.Errorf("HEALTH_CHECK_INTERVAL must not be negative")
+	}
+	if cfg.clickRetention, err = getDuration("CLICK_RETENTION", 365*24*time.Hour); err != nil {
+		return config{}, err
+	}
+	if cfg.clickRetention < 0 {
+		return config{}, fmt.Errorf("CLICK_RETENTION must not be negative")
+	}
+	if cfg.clickFlush, err = getDuration("CLICK_FLUSH_INTERVAL", time.Second); err != nil {
+		return config{}, err
+	}
+	if cfg.clickFlush <= 0 {
+		return config{}, fmt.Errorf("CLICK_FLUSH_INTERVAL must be positive")
+	}
+	if cfg.clickSampling.Rate, err = getInt("CLICK_SAMPLE_RATE", 1); err != nil {
+		return config{}, err
+	}
+	if cfg.logSampling.Rate, err = getInt("LOG_SAMPLE_RATE", 1); err != nil {
+		return config{}, err
+	}
+	if cfg.clickSampling.Hot, err = getInt("SAMPLE_HOT_CLICKS", 100); err != nil {
+		return config{}, err
+	}
+	cfg.logSampling.Hot = cfg.clickSampling.Hot
+	if cfg.clickSampling.Rate < 1 || cfg.logSampling.Rate < 1 || cfg.clickSampling.Hot < 0 {
+		return config{}, fmt.Errorf("CLICK_SAMPLE_RATE and LOG_SAMPLE_RATE must be at least 1 and SAMPLE_HOT_CLICKS must not be negative")
+	}
+	cfg.web.Order = store.LinkOrder(getEnv("INDEX_ORDER", string(store.OrderNewest)))
+	if !cfg.web.Order.Valid() {
+		return config{}, fmt.Errorf("INDEX_ORDER must be one of %v", store.LinkOrders)
+	}
+	cfg.web.ErrorPages = os.Getenv("ERROR_PAGES_DIR")
+	cfg.web.Contact = os.Getenv("ERROR_CONTACT")
+
+	bannedWords, err := loadBannedWords(os.Getenv("BANNED_WORDS"), os.Getenv("BANNED_WORDS_FILE"))
+	if err != nil {
+		return config{}, fmt.Errorf("failed to load banned words: %w", err)
+	}
+	cfg.api = httpapi.Config{
+		Admins:            parseAdmins(os.Getenv("ADMIN_USER"), os.Getenv("ADMIN_PASS"), os.Getenv("ADMIN_USERS")),
+		SensitivePatterns: splitList(os.Getenv("SENSITIVE_PATTERNS")),
+		BannedWords:       bannedWords,
+		BannedWordsMode:   getEnv("BANNED_WORDS_MODE", httpapi.BannedWordsToken),
+		SafeBrowsingKey:   os.Getenv("SAFE_BROWSING_API_KEY"),
+	}
+	cfg.api.SlugStrategy = os.Getenv("SLUG_STRATEGY")
+	if cfg.api.SlugStrategy != "" && !slices.Contains(httpapi.SlugStrategies, cfg.api.SlugStrategy) {
+		return config{}, fmt.Errorf("SLUG_STRATEGY must be one of %v", httpapi.SlugStrategies)
+	}
+	if cfg.api.TypoCorrection, err = getBool("TYPO_CORRECTION", false); err != nil {
+		return config{}, err
+	}
+	if cfg.api.LookupBudget, err = getDuration("REDIRECT_LOOKUP_BUDGET", 0); err != nil {
+		return config{}, err
+	}
+	if cfg.api.LookupBudget < 0 {
+		return config{}, fmt.Errorf("REDIRECT_LOOKUP_BUDGET must not be negative")
+	}
+	if cfg.api.APIDocs, err = getBool("API_DOCS", false); err != nil {
+		return config{}, err
+	}
+	if cfg.api.Pprof, err = getBool("PPROF", false); err != nil {
+		return config{}, err
+	}
+	// Without admins the profiles would be open to anyone
+	if cfg.api.Pprof && len(cfg.api.Admins) == 0 {
+		return config{}, fmt.Errorf("PPROF needs ADMIN_USER and ADMIN_PASS or ADMIN_USERS")
+	}
+	if cfg.api.AccessGroups, err = parseAccessGroups(os.Getenv("ACCESS_GROUPS")); err != nil {
+		return config{}, fmt.Errorf("ACCESS_GROUPS: %w", err)
+	}
+	if cfg.api.TrustedProxies, err = parsePrefixes(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		return config{}, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	if cfg.api.LinkFields, err = parseLinkFields(os.Getenv("LINK_FIELDS")); err != nil {
+		return config{}, fmt.Errorf("LINK_FIELDS: %w", err)
+	}
+	if cfg.api.Chains, err = parseChains(); err != nil {
+		return config{}, err
+	}
+	cfg.api.CORSOrigins = splitList(os.Getenv("CORS_ORIGINS"))
+	// Listed origins get credentials and "*" never does; mixed, it is
+	// unclear which was meant
+	if slices.Contains(cfg.api.CORSOrigins, "*") && len(cfg.api.CORSOrigins) > 1 {
+		return config{}, fmt.Errorf("CORS_ORIGINS cannot combine * with other origins")
+	}
+	mailer := notify.Mailer{
+		Addr: os.Getenv("SMTP_ADDR"),
+		User: os.Getenv("SMTP_USER"),
+		Pass: os.Getenv("SMTP_PASS"),
+		From: getEnv("SMTP_FROM", "golinks@localhost"),
+	}
+	notifiers, err := loadNotifiers(mailer)
+	if err != nil {
+		return config{}, err
+	}
+	cfg.report = report.Config{
+		Schedule:   os.Getenv("REPORT_SCHEDULE"),
+		Format:     getEnv("REPORT_FORMAT", report.Markdown),
+		WebhookURL: os.Getenv("REPORT_WEBHOOK_URL"),
+		Mailer:     mailer,
+		EmailTo:    splitList(os.Getenv("REPORT_EMAIL_TO")),
+		Notifier:   notifiers[notify.EventReport],
+	}
+	cfg.reminder = reminder.Config{
+		WebhookURL: os.Getenv("REMINDER_WEBHOOK_URL"),
+		Mailer:     mailer,
+		EmailTo:    splitList(os.Getenv("REMINDER_EMAIL_TO")),
+		Notifier:   notifiers[notify.EventReview],
+	}
+	cfg.budget = budget.Config{
+		WebhookURL: os.Getenv("BUDGET_WEBHOOK_URL"),
+		NtfyURL:    os.Getenv("BUDGET_NTFY_URL"),
+		Notifier:   notifiers[notify.EventBudget],
+	}
+	cfg.approval = approval.Config{
+		BaseURL:            os.Getenv("APPROVAL_BASE_URL"),
+		Secret:             os.Getenv("APPROVAL_SECRET"),
+		SlackWebhookURL:    os.Getenv("APPROVAL_SLACK_WEBHOOK_URL"),
+		SlackSigningSecret: os.Getenv("APPROVAL_SLACK_SIGNING_SECRET"),
+		MatrixHomeserver:   os.Getenv("APPROVAL_MATRIX_HOMESERVER"),
+		MatrixRoom:         os.Getenv("APPROVAL_MATRIX_ROOM"),
+		MatrixToken:        os.Getenv("APPROVAL_MATRIX_TOKEN"),
+	}
+	if cfg.approval.Enabled() && len(cfg.approval.Secret) < 16 {
+		return config{}, fmt.Errorf("chat approvals require APPROVAL_SECRET of at least 16 characters")
+	}
+	if cfg.approval.SlackWebhookURL != "" && cfg.approval.SlackSigningSecret == "" {
+		return config{}, fmt.Errorf("APPROVAL_SLACK_WEBHOOK_URL requires APPROVAL_SLACK_SIGNING_SECRET")
+	}
+	if cfg.approval.SlackAdmins, err = parseSlackAdmins(os.Getenv("APPROVAL_SLACK_ADMINS"), cfg.api.Admins); err != nil {
+		return config{}, fmt.Errorf("APPROVAL_SLACK_ADMINS: %w", err)
+	}
+	// Without it every click would be refused
+	if cfg.approval.SlackWebhookURL != "" && len(cfg.approval.SlackAdmins) == 0 {
+		return config{}, fmt.Errorf("APPROVAL_SLACK_WEBHOOK_URL requires APPROVAL_SLACK_ADMINS")
+	}
+	if cfg.approval.MatrixRoom != "" && (cfg.approval.MatrixHomeserver == "" || cfg.approval.MatrixToken == "" || cfg.approval.BaseURL == "") {
+		return config{}, fmt.Errorf("APPROVAL_MATRIX_ROOM requires APPROVAL_MATRIX_HOMESERVER, APPROVAL_MATRIX_TOKEN and APPROVAL_BASE_URL")
+	}
+	cfg.snapshot = snapshot.Config{
+		Dir:     os.Getenv("SNAPSHOT_DIR"),
+		HookURL: os.Getenv("SNAPSHOT_HOOK_URL"),
+	}
+	if cfg.snapshot.HookURL != "" && !cfg.snapshot.Enabled() {
+		return config{}, fmt.Errorf("SNAPSHOT_HOOK_URL requires SNAPSHOT_DIR")
+	}
+	if cfg.peers.Peers, err = peers.Parse(os.Getenv("PEERS")); err != nil {
+		return config{}, fmt.Errorf("PEERS: %w", err)
+	}
+	if cfg.peers.Fallback, err = getBool("PEER_FALLBACK", false); err != nil {
+		return config{}, err
+	}
+	if cfg.peers.Timeout, err = getDuration("PEER_TIMEOUT", 2*time.Second); err != nil {
+		return config{}, err
+	}
+	cfg.gitops.Path = os.Getenv("GITOPS_PATH")
+	if cfg.gitops.Interval, err = getDuration("GITOPS_INTERVAL", 30*time.Second); err != nil {
+		return config{}, err
+	}
+	if cfg.gitops.Exclusive, err = getBool("GITOPS_EXCLUSIVE", false); err != nil {
+		return config{}, err
+	}
+	cfg.ssh = sshadmin.Config{
+		Addr:               os.Getenv("SSH_ADDR"),
+		HostKeyPath:        getEnv("SSH_HOST_KEY", "./data/ssh_host_ed25519_key"),
+		AuthorizedKeysPath: os.Getenv("SSH_AUTHORIZED_KEYS"),
+	}
+	for name := range cfg.api.Admins {
+		cfg.ssh.Admins = append(cfg.ssh.Admins, name)
+	}
+	if cfg.ssh.Addr != "" && cfg.ssh.AuthorizedKeysPath == "" {
+		return config{}, fmt.Errorf("SSH_ADDR requires SSH_AUTHORIZED_KEYS")
+	}
+	cfg.mirror = mirror.Config{
+		Upstream: os.Getenv("MIRROR_UPSTREAM"),
+		User:     os.Getenv("MIRROR_USER"),
+		Pass:     os.Getenv("MIRROR_PASS"),
+	}
+	if cfg.mirror.Interval, err = getDuration("MIRROR_INTERVAL", 5*time.Minute); err != nil {
+		return config{}, err
+	}
+	if cfg.mirror.Enabled() {
+		if u, err := url.Parse(cfg.mirror.Upstream); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config{}, fmt.Errorf("MIRROR_UPSTREAM needs an http(s) base URL")
+		}
+		// A mirror's links are overwritten by every sync
+		if cfg.gitops.Enabled() || cfg.ssh.Addr != "" {
+			return config{}, fmt.Errorf("MIRROR_UPSTREAM cannot be combined with GITOPS_PATH or SSH_ADDR")
+		}
+	}
+	cfg.sync = linksync.Config{
+		Peer:      os.Getenv("SYNC_PEER"),
+		User:      os.Getenv("SYNC_USER"),
+		Pass:      os.Getenv("SYNC_PASS"),
+		Token:     os.Getenv("SYNC_TOKEN"),
+		StatePath: getEnv("SYNC_STATE_FILE", "./data/sync.json"),
+	}
+	// The instance syncing with this one pushes its changes with the token
+	cfg.api.SyncToken = cfg.sync.Token
+	if cfg.sync.Interval, err = getDuration("SYNC_INTERVAL", 5*time.Minute); err != nil {
+		return config{}, err
+	}
+	if cfg.sync.Enabled() {
+		if u, err := url.Parse(cfg.sync.Peer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config{}, fmt.Errorf("SYNC_PEER needs an http(s) base URL")
+		}
+		if cfg.sync.Interval <= 0 {
+			return config{}, fmt.Errorf("SYNC_INTERVAL must be positive")
+		}
+		if cfg.sync.Token == "" {
+			return config{}, fmt.Errorf("SYNC_PEER requires SYNC_TOKEN")
+		}
+		// Both would overwrite the links the sync brings in
+		if cfg.mirror.Enabled() || cfg.gitops.Enabled() {
+			return config{}, fmt.Errorf("SYNC_PEER cannot be combined with MIRROR_UPSTREAM or GITOPS_PATH")
+		}
+	}
+	cfg.shadow = shadow.Config{Target: os.Getenv("SHADOW_TARGET")}
+	if cfg.shadow.Timeout, err = getDuration("SHADOW_TIMEOUT", 5*time.Second); err != nil {
+		return config{}, err
+	}
+	if cfg.shadow.Enabled() {
+		if u, err := url.Parse(cfg.shadow.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config{}, fmt.Errorf("SHADOW_TARGET needs an http(s) base URL")
+		}
+		percent, err := strconv.ParseFloat(getEnv("SHADOW_PERCENT", "10"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return config{}, fmt.Errorf("SHADOW_PERCENT must be a number above 0 and at most 100")
+		}
+		cfg.shadow.Percent = percent
+		if cfg.shadow.Timeout <= 0 {
+			return config{}, fmt.Errorf("SHADOW_TIMEOUT must be positive")
+		}
+	}
+	cfg.probe = probe.Config{Slug: strings.Trim(os.Getenv("PROBE_SLUG"), "/"), Target: os.Getenv("PROBE_TARGET")}
+	if cfg.probe.Interval, err = getDuration("PROBE_INTERVAL", time.Minute); err != nil {
+		return config{}, err
+	}
+	if cfg.probe.Enabled() {
+		if cfg.probe.Interval <= 0 {
+			return config{}, fmt.Errorf("PROBE_INTERVAL must be positive")
+		}
+		if cfg.probe.Target == "" {
+			// Resolve the canary the way clients do, through the listener
+			if cfg.probe.Target, err = probe.LocalTarget(cfg.listenAddr); err != nil {
+				return config{}, fmt.Errorf("PROBE_TARGET is needed as LISTEN_ADDR is not host:port: %w", err)
+			}
+		} else if u, err := url.Parse(cfg.probe.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return config{}, fmt.Errorf("PROBE_TARGET needs an http(s) base URL")
+		}
+	}
+	if cfg.rateLimit.Limit, err = getInt("API_RATE_LIMIT", 0); err != nil {
+		return config{}, err
+	}
+	if cfg.rateLimit.Window, err = getDuration("API_RATE_WINDOW", time.Minute); err != nil {
+		return config{}, err
+	}
+	if cfg.rateLimit.Limit < 0 || cfg.rateLimit.Window <= 0 {
+		return config{}, fmt.Errorf("API_RATE_LIMIT must not be negative and API_RATE_WINDOW must be positive")
+	}
+	if cfg.guestLimit.Limit, err = getInt("GUEST_SUGGEST_LIMIT", 0); err != nil {
+		return config{}, err
+	}
+	if cfg.guestLimit.Window, err = getDuration("GUEST_SUGGEST_WINDOW", time.Hour); err != nil {
+		return config{}, err
+	}
+	if cfg.guestLimit.Limit < 0 || cfg.guestLimit.Window <= 0 {
+		return config{}, fmt.Errorf("GUEST_SUGGEST_LIMIT must not be negative and GUEST_SUGGEST_WINDOW must be positive")
+	}
+	cfg.captcha = captcha.Config{
+		Provider: getEnv("CAPTCHA_PROVIDER", captcha.HCaptcha),
+		SiteKey:  os.Getenv("CAPTCHA_SITE_KEY"),
+		Secret:   os.Getenv("CAPTCHA_SECRET"),
+	}
+	if cfg.captcha.Enabled() && (!captcha.Valid(cfg.captcha.Provider) || cfg.captcha.SiteKey == "") {
+		return config{}, fmt.Errorf("CAPTCHA_PROVIDER must be %s or %s, and CAPTCHA_SITE_KEY is needed with CAPTCHA_SECRET", captcha.HCaptcha, captcha.Turnstile)
+	}
+	cfg.backup = backup.Config{
+		Endpoint:   getEnv("BACKUP_S3_ENDPOINT", "https://s3.amazonaws.com"),
+		Bucket:     os.Getenv("BACKUP_S3_BUCKET"),
+		Region:     os.Getenv("BACKUP_S3_REGION"),
+		AccessKey:  os.Getenv("BACKUP_S3_ACCESS_KEY"),
+		SecretKey:  os.Getenv("BACKUP_S3_SECRET_KEY"),
+		Prefix:     getEnv("BACKUP_S3_PREFIX", "golinks/"),
+		Passphrase: os.Getenv("BACKUP_PASSPHRASE"),
+	}
+	if cfg.backup.Interval, err = getDuration("BACKUP_INTERVAL", 24*time.Hour); err != nil {
+		return config{}, err
+	}
+	if cfg.backup.Keep, err = getInt("BACKUP_KEEP", 14); err != nil {
+		return config{}, err
+	}
+	if cfg.backup.Enabled() {
+		if cfg.backup.AccessKey == "" || cfg.backup.SecretKey == "" {
+			return config{}, fmt.Errorf("BACKUP_S3_BUCKET requires BACKUP_S3_ACCESS_KEY and BACKUP_S3_SECRET_KEY")
+		}
+		if len(cfg.backup.Passphrase) < 12 {
+			return config{}, fmt.Errorf("BACKUP_S3_BUCKET requires BACKUP_PASSPHRASE of at least 12 characters")
+		}
+		if cfg.backup.Interval <= 0 || cfg.backup.Keep < 0 {
+			return config{}, fmt.Errorf("BACKUP_INTERVAL must be positive and BACKUP_KEEP not negative")
+		}
+		// The backups are snapshots of the database file
+		if cfg.databaseURL != "" {
+			return config{}, fmt.Errorf("BACKUP_S3_BUCKET requires a database file; back up a DATABASE_URL server with its own tools")
+		}
+	}
+	cfg.restoreFrom = os.Getenv("RESTORE_FROM")
+	if cfg.restoreFrom == restoreFromS3 && !cfg.backup.Enabled() {
+		return config{}, fmt.Errorf("RESTORE_FROM=s3 requires BACKUP_S3_BUCKET")
+	}
+	if cfg.restoreFrom != "" && cfg.mirror.Enabled() {
+		return config{}, fmt.Errorf("RESTORE_FROM cannot be combined with MIRROR_UPSTREAM, which fills the database itself")
+	}
+	cfg.api.BackupPassphrase = cfg.backup.Passphrase
+
+	cfg.aliasTarget = os.Getenv("ALIAS_REDIRECT_TO")
+	cfg.aliasDomains = splitList(os.Getenv("ALIAS_DOMAINS"))
+	cfg.aliasListenAddr = os.Getenv("ALIAS_LISTEN_ADDR")
+	if cfg.aliasTarget == "" && (len(cfg.aliasDomains) > 0 || cfg.aliasListenAddr != "") {
+		return config{}, fmt.Errorf("ALIAS_DOMAINS and ALIAS_LISTEN_ADDR require ALIAS_REDIRECT_TO")
+	}
+	if cfg.aliasTarget != "" {
+		u, err := url.Parse(cfg.aliasTarget)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+			return config{}, fmt.Errorf("ALIAS_REDIRECT_TO must be an http(s) base URL such as https://go.example.com")
+		}
+	}
+	return cfg, nil
+}
+
+// loadNotifiers reads the notification channels of each event from
+// NOTIFY_<EVENT>, such as NOTIFY_BUDGET, plus those in NOTIFY_ALL. Events
+// without channels are left out.
+func loadNotifiers(mailer notify.Mailer) (map[string]notify.Notifier, error) {
+	all, err := notify.ParseList(os.Getenv("NOTIFY_ALL"), mailer)
+	if err != nil {
+		return nil, fmt.Errorf("NOTIFY_ALL: %w", err)
+	}
+	notifiers := make(map[string]notify.Notifier)
+	for _, event := range notify.Events {
+		key := "NOTIFY_" + strings.ToUpper(event)
+		list, err := notify.ParseList(os.Getenv(key), mailer)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if list = append(list, all...); len(list) > 0 {
+			notifiers[event] = notify.Multi(list)
+		}
+	}
+	return notifiers, nil
+}
+
+// parseAdmins builds the admin credential map from ADMIN_USER/ADMIN_PASS and
+// the comma-separated "user:pass" pairs in ADMIN_USERS.
+func parseAdmins(user, pass, users string) map[string]string {
+	m := make(map[string]string)
+	if user != "" && pass != "" {
+		m[user] = pass
+	}
+	for _, entry := range splitList(users) {
+		u, p, ok := strings.Cut(entry, ":")
+		if !ok || u == "" || p == "" {
+			slog.Warn("Ignoring malformed ADMIN_USERS entry", "user", u)
+			continue
+		}
+		m[u] = p
+	}
+	return m
+}
+
+// parseSlackAdmins parses comma-separated "slack-user-id:admin" pairs, such
+// as "U012AB3CD:alice", each naming one of admins.
+func parseSlackAdmins(s string, admins map[string]string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, entry := range splitList(s) {
+		id, admin, ok := strings.Cut(entry, ":")
+		id, admin = strings.TrimSpace(id), strings.TrimSpace(admin)
+		if !ok || id == "" || admin == "" {
+			return nil, fmt.Errorf("entry %q is not slack-user-id:admin", entry)
+		}
+		if _, ok := admins[admin]; !ok {
+			return nil, fmt.Errorf("%s is not an admin", admin)
+		}
+		m[id] = admin
+	}
+	return m, nil
+}
+
+// loadBannedWords merges the comma-separated BANNED_WORDS list with the
+// one-word-per-line BANNED_WORDS_FILE (blank lines and # comments ignored).
+func loadBannedWords(list, file string) ([]string, error) {
+	words := splitList(list)
+	if file == "" {
+		return words, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, nil
+}
+
+// parseAccessGroups parses semicolon-separated "name=networks" groups such
+// as "kids=192.168.20.0/24,fd00:20::/64;guests=192.168.30.0/24".
+func parseAccessGroups(s string) (map[string][]netip.Prefix, error) {
+	groups := make(map[string][]netip.Prefix)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, networks, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("malformed group %q, want name=networks", entry)
+		}
+		prefixes, err := parsePrefixes(networks)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+		if len(prefixes) == 0 {
+			return nil, fmt.Errorf("group %s has no networks", name)
+		}
+		groups[name] = append(groups[name], prefixes...)
+	}
+	return groups, nil
+}
+
+// parseLinkFields parses comma-separated "name=Label" custom fields such as
+// "cost_center=Cost center,room=Room". Names are lowercase letters, digits,
+// "_" and "-"; a field without a label is labelled by its name.
+func parseLinkFields(s string) ([]httpapi.LinkField, error) {
+	var fields []httpapi.LinkField
+	for _, entry := range splitList(s) {
+		name, label, _ := strings.Cut(entry, "=")
+		name, label = strings.TrimSpace(name), strings.TrimSpace(label)
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789_-") != "" {
+			return nil, fmt.Errorf("invalid field name %q, want lowercase letters, digits, _ and -", name)
+		}
+		if slices.ContainsFunc(fields, func(f httpapi.LinkField) bool { return f.Name == name }) {
+			return nil, fmt.Errorf("field %s is given twice", name)
+		}
+		fields = append(fields, httpapi.LinkField{Name: name, Label: cmp.Or(label, name)})
+	}
+	return fields, nil
+}
+
+// parseChains reads the middleware chain of each route group from
+// MIDDLEWARE_<GROUP>, a comma-separated list of stages, outermost first, or
+// "none" for no middleware at all. Groups without one keep their default.
+func parseChains() (map[string][]string, error) {
+	chains := make(map[string][]string)
+	for _, group := range httpapi.Groups {
+		key := "MIDDLEWARE_" + strings.ToUpper(group)
+		value := strings.TrimSpace(os.Getenv(key))
+		switch value {
+		case "":
+			continue
+		case "none":
+			chains[group] = []string{}
+			continue
+		}
+		chain := splitList(value)
+		if err := httpapi.CheckChain(chain); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		chains[group] = chain
+	}
+	return chains, nil
+}
+
+// parsePrefixes parses a comma-separated list of networks in CIDR notation;
+// a bare address, in brackets or not, stands for itself. Clients are matched
+// by their IPv4 address even when they connect over IPv6 as
+// ::ffff:192.0.2.7, so IPv4-mapped networks are taken as IPv4 ones, and
+// zones are dropped.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range splitList(s) {
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(part, "["), "]"))
+			if err != nil {
+				return nil, err
+			}
+			addr = addr.Unmap().WithZone("")
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, err
+		}
+		if addr := prefix.Addr(); addr.Is4In6() {
+			if prefix.Bits() < 96 {
+				return nil, fmt.Errorf("network %s is wider than the IPv4-mapped range", part)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getDuration parses a duration setting such as "5s" or "250ms".
+func getDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return d, nil
+}
+
+// getInt parses an integer setting.
+func getInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return n, nil
+}
+
+// getBool parses a boolean setting such as "true" or "0".
+func getBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", key, err)
+	}
+	return b, nil
+}
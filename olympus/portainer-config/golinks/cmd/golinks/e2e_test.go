@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golinks/internal/accesslog"
+	"golinks/internal/archive"
+	"golinks/internal/httpapi"
+	"golinks/internal/logging"
+	"golinks/internal/store"
+)
+
+// e2e is a full golinks server running against a temporary SQLite database.
+type e2e struct {
+	t      *testing.T
+	srv    *httptest.Server
+	client *http.Client
+}
+
+func startE2E(t *testing.T, api httpapi.Config) *e2e {
+	t.Helper()
+	a, err := newApp(config{
+		dbPath:       filepath.Join(t.TempDir(), "links.db"),
+		queryTimeout: 5 * time.Second,
+		logLevel:     logging.LevelError,
+		api:          api,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(a.handler)
+	t.Cleanup(func() {
+		srv.Close()
+		a.Close()
+	})
+	return &e2e{
+		t:   t,
+		srv: srv,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// do sends a request and returns the response with its body read. A non-nil
+// body is encoded as JSON.
+func (e *e2e) do(method, path string, body any, user, pass string) (*http.Response, string) {
+	e.t.Helper()
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, e.srv.URL+path, payload)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func (e *e2e) expect(method, path string, body any, user, pass string, want int) string {
+	e.t.Helper()
+	resp, got := e.do(method, path, body, user, pass)
+	if resp.StatusCode != want {
+		e.t.Fatalf("%s %s: status = %d, want %d (body %q)", method, path, resp.StatusCode, want, got)
+	}
+	return got
+}
+
+func TestE2EAuth(t *testing.T) {
+	e := startE2E(t, httpapi.Config{Admins: map[string]string{"alice": "s3cret"}})
+	link := httpapi.AddLinkRequest{Slug: "wiki", URL: "https://wiki.example.com"}
+
+	e.expect(http.MethodPost, "/admin/add", link, "", "", http.StatusUnauthorized)
+	e.expect(http.MethodPost, "/admin/add", link, "alice", "wrong", http.StatusUnauthorized)
+	e.expect(http.MethodPost, "/admin/add", link, "mallory", "s3cret", http.StatusUnauthorized)
+	e.expect(http.MethodGet, "/wiki", nil, "", "", http.StatusNotFound)
+
+	e.expect(http.MethodPost, "/admin/add", link, "alice", "s3cret", http.StatusCreated)
+	e.expect(http.MethodGet, "/wiki", nil, "", "", http.StatusFound)
+	e.expect(http.MethodGet, "/admin/security-report", nil, "", "", http.StatusUnauthorized)
+}
+
+func TestE2ECRUD(t *testing.T) {
+	e := startE2E(t, httpapi.Config{})
+
+	e.expect(http.MethodPost, "/admin/add", httpapi.AddLinkRequest{Slug: "docs", URL: "https://docs.example.com"}, "", "", http.StatusCreated)
+	e.expect(http.MethodPost, "/admin/add", httpapi.AddLinkRequest{Slug: "docs", URL: "https://other.example.com"}, "", "", http.StatusConflict)
+	e.expect(http.MethodPost, "/admin/add", httpapi.AddLinkRequest{Slug: "admin", URL: "https://x.example.com"}, "", "", http.StatusBadRequest)
+	e.expect(http.MethodPost, "/admin/add", httpapi.AddLinkRequest{Slug: "ftp", URL: "ftp://files.example.com"}, "", "", http.StatusBadRequest)
+
+	if body := e.expect(http.MethodGet, "/", nil, "", "", http.StatusOK); !strings.Contains(body, "https://docs.example.com") {
+		t.Errorf("index does not list the new link:\n%s", body)
+	}
+
+	e.expect(http.MethodPost, "/admin/remove", httpapi.RemoveLinkRequest{Slug: "docs"}, "", "", http.StatusOK)
+	e.expect(http.MethodPost, "/admin/remove", httpapi.RemoveLinkRequest{Slug: "docs"}, "", "", http.StatusNotFound)
+	e.expect(http.MethodGet, "/docs", nil, "", "", http.StatusNotFound)
+}
+
+func TestE2EApproval(t *testing.T) {
+	e := startE2E(t, httpapi.Config{
+		Admins:            map[string]string{"alice": "a-pass", "bob": "b-pass"},
+		SensitivePatterns: []string{"pay.example.com"},
+	})
+	link := httpapi.AddLinkRequest{Slug: "pay", URL: "https://pay.example.com"}
+
+	e.expect(http.MethodPost, "/admin/add", link, "alice", "a-pass", http.StatusAccepted)
+	e.expect(http.MethodGet, "/pay", nil, "", "", http.StatusForbidden)
+	e.expect(http.MethodPost, "/admin/approve", httpapi.ApproveLinkRequest{Slug: "pay", URL: link.URL}, "alice", "a-pass", http.StatusForbidden)
+	e.expect(http.MethodPost, "/admin/approve", httpapi.ApproveLinkRequest{Slug: "pay", URL: link.URL}, "bob", "b-pass", http.StatusOK)
+	e.expect(http.MethodPost, "/admin/approve", httpapi.ApproveLinkRequest{Slug: "pay", URL: link.URL}, "bob", "b-pass", http.StatusConflict)
+	e.expect(http.MethodGet, "/pay", nil, "", "", http.StatusFound)
+}
+
+func TestE2ERedirect(t *testing.T) {
+	e := startE2E(t, httpapi.Config{})
+	e.expect(http.MethodPost, "/admin/add", httpapi.AddLinkRequest{Slug: "search", URL: "https://search.example.com/?q=go"}, "", "", http.StatusCreated)
+
+	resp, _ := e.do(http.MethodGet, "/search", nil, "", "")
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("status = %d, want 302", resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Location"); loc != "https://search.example.com/?q=go" {
+		t.Errorf("Location = %q", loc)
+	}
+
+	e.expect(http.MethodGet, "/missing", nil, "", "", http.StatusNotFound)
+	e.expect(http.MethodGet, "/Search", nil, "", "", http.StatusNotFound)
+}
+
+func TestE2EConcurrentWrites(t *testing.T) {
+	e := startE2E(t, httpapi.Config{})
+
+	const writers = 8
+	const perWriter = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				// Every writer races for the shared slug as well as adding its own
+				for _, slug := range []string{fmt.Sprintf("w%d-%d", w, i), fmt.Sprintf("shared-%d", i)} {
+					data, _ := json.Marshal(httpapi.AddLinkRequest{Slug: slug, URL: "https://example.com/" + slug})
+					resp, err := e.client.Post(e.srv.URL+"/admin/add", "application/json", bytes.NewReader(data))
+					if err != nil {
+						errs <- err
+						continue
+					}
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusConflict {
+						errs <- fmt.Errorf("add %s: status %d", slug, resp.StatusCode)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for w := 0; w < writers; w++ {
+		for i := 0; i < perWriter; i++ {
+			e.expect(http.MethodGet, fmt.Sprintf("/w%d-%d", w, i), nil, "", "", http.StatusFound)
+		}
+	}
+	for i := 0; i < perWriter; i++ {
+		e.expect(http.MethodGet, fmt.Sprintf("/shared-%d", i), nil, "", "", http.StatusFound)
+	}
+}
+
+func TestE2ERestoreOnBoot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	backupOf := func(slug string) string {
+		src := store.NewMemory()
+		src.AddLink(ctx, store.Link{Slug: slug, URL: "https://" + slug + ".example.com"})
+		path := filepath.Join(dir, slug+".tar.gz")
+		var buf bytes.Buffer
+		if _, err := archive.Export(ctx, src, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	cfg := config{dbPath: filepath.Join(dir, "links.db"), queryTimeout: 5 * time.Second, logLevel: logging.LevelError}
+
+	// The empty database is restored, a database with links is left alone
+	for _, slug := range []string{"wiki", "mail"} {
+		cfg.restoreFrom = backupOf(slug)
+		a, err := newApp(cfg)
+		if err != nil {
+			t.Fatalf("newApp restoring %s: %v", slug, err)
+		}
+		n, _ := a.store.CountLinks(ctx)
+		_, err = a.store.GetLink(ctx, "wiki")
+		a.Close()
+		if n != 1 || err != nil {
+			t.Errorf("after starting with %s: %d link(s), wiki: %v", slug, n, err)
+		}
+	}
+
+	cfg.dbPath = filepath.Join(dir, "other.db")
+	cfg.restoreFrom = filepath.Join(dir, "missing.tar.gz")
+	if a, err := newApp(cfg); err == nil {
+		a.Close()
+		t.Error("newApp with a missing backup to restore succeeded")
+	}
+}
+
+func TestE2EStartFailureClosesAccessLog(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("no /proc/self/fd to find open files in")
+	}
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	cfg := config{
+		dbPath:          filepath.Join(dir, "links.db"),
+		queryTimeout:    5 * time.Second,
+		logLevel:        logging.LevelError,
+		accessLog:       accesslog.Config{Path: path, Format: accesslog.Combined},
+		aliasListenAddr: busy.Addr().String(),
+	}
+	if a, err := newApp(cfg); err == nil {
+		a.Close()
+		t.Fatal("newApp on a busy alias address succeeded")
+	}
+	fds, _ := os.ReadDir("/proc/self/fd")
+	for _, fd := range fds {
+		if target, _ := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); target == path {
+			t.Errorf("access log left open as fd %s", fd.Name())
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Command golinks runs the go links URL shortener server. "golinks export
+// FILE" and "golinks import FILE" write and restore an archive of its
+// links instead.
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golinks/internal/logging"
+)
+
+// shutdownTimeout bounds how long requests in flight may take to finish on
+// SIGTERM, leaving the rest of Docker's 10 second grace period to write the
+// queued clicks and close the store.
+const shutdownTimeout = 5 * time.Second
+
+func main() {
+	// Get configuration from environment
+	cfg, err := loadConfig()
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	logging.Setup(os.Stderr, cfg.logFormat)
+	logging.SetLevel(cfg.logLevel)
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			fatal("Command failed", err)
+		}
+		return
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		fatal("Failed to start", err)
+	}
+
+	// Start server
+	slog.Info("Starting golinks server", "addr", cfg.listenAddr)
+	if cfg.databaseURL != "" {
+		u, _ := url.Parse(cfg.databaseURL)
+		slog.Info("Database", "url", u.Redacted())
+	} else {
+		slog.Info("Database", "path", cfg.dbPath, "engine", cfg.dbEngine)
+	}
+	if len(cfg.api.Admins) > 0 {
+		slog.Info("Admin authentication enabled", "admins", len(cfg.api.Admins))
+	}
+	if len(cfg.api.SensitivePatterns) > 0 {
+		slog.Info("Sensitive destinations require approval", "patterns", strings.Join(cfg.api.SensitivePatterns, ", "))
+	}
+	if len(cfg.api.AccessGroups) > 0 {
+		slog.Info("Link access schedules enabled", "groups", len(cfg.api.AccessGroups), "trusted_proxies", len(cfg.api.TrustedProxies))
+	}
+	if cfg.ssh.Addr != "" {
+		slog.Info("SSH admin interface", "addr", cfg.ssh.Addr)
+	}
+	if len(cfg.aliasDomains) > 0 {
+		slog.Info("Redirecting alias domains", "domains", strings.Join(cfg.aliasDomains, ", "), "target", cfg.aliasTarget)
+	}
+	if cfg.aliasListenAddr != "" {
+		slog.Info("Redirecting everything on the alias listener", "addr", cfg.aliasListenAddr, "target", cfg.aliasTarget)
+	}
+	if cfg.healthInterval > 0 {
+		slog.Info("Link health checks", "every", cfg.healthInterval.String())
+	}
+	if cfg.metrics {
+		slog.Info("Prometheus metrics at /admin/metrics")
+	}
+	if cfg.probe.Enabled() {
+		slog.Info("Uptime probe", "slug", cfg.probe.Slug, "target", cfg.probe.Target, "every", cfg.probe.Interval.String())
+	}
+	if cfg.backup.Enabled() {
+		slog.Info("Encrypted backups", "bucket", cfg.backup.Bucket, "prefix", cfg.backup.Prefix, "every", cfg.backup.Interval.String(), "keep", cfg.backup.Keep)
+	}
+	if cfg.report.Schedule != "" {
+		slog.Info("Usage reports enabled", "schedule", cfg.report.Schedule)
+	}
+	if cfg.api.Pprof {
+		slog.Info("Profiles for admins at /debug/pprof/")
+	}
+	if cfg.accessLog.Enabled() {
+		slog.Info("Access log", "path", cfg.accessLog.Path, "format", cfg.accessLog.Format)
+	}
+	if len(cfg.api.BannedWords) > 0 {
+		slog.Info("Banned-word slug filter enabled", "words", len(cfg.api.BannedWords))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	srv := &http.Server{Addr: cfg.listenAddr, Handler: a.handler}
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe() }()
+
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		slog.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		err = srv.Shutdown(shutdownCtx)
+		cancel()
+	}
+	if closeErr := a.Close(); closeErr != nil {
+		slog.Error("Failed to close the database", "error", closeErr)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("Server failed", err)
+	}
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golinks/internal/archive"
+	"golinks/internal/backup"
+	"golinks/internal/store"
+)
+
+// restoreFromS3 is the RESTORE_FROM value that restores the newest backup
+// in the BACKUP_S3_BUCKET.
+const restoreFromS3 = "s3"
+
+// restoreTimeout bounds fetching and restoring the backup on first boot.
+const restoreTimeout = 10 * time.Minute
+
+// restoreOnBoot restores the backup at cfg.restoreFrom into st if st has no
+// links yet, so a new or wiped instance starts where the backup left off.
+// Later starts leave st alone.
+func restoreOnBoot(cfg config, st store.Store) error {
+	ctx, cancel := context.WithTimeout(context.Background(), restoreTimeout)
+	defer cancel()
+	n, err := st.CountLinks(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	data, from, err := fetchBackup(ctx, cfg)
+	if errors.Is(err, backup.ErrNoBackups) {
+		slog.Info("No backup to restore yet; starting empty", "bucket", cfg.backup.Bucket, "prefix", cfg.backup.Prefix)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch backup to restore: %w", err)
+	}
+	contents, err := archive.Load(ctx, data, cfg.backup.Passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", from, err)
+	}
+	res, err := archive.Restore(ctx, st, contents, false)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup %s: %w", from, err)
+	}
+	slog.Info("Empty database restored", "from", from, "format", contents.Format, "links", res.Links, "collections", res.Collections, "aliases", res.Aliases)
+	return nil
+}
+
+// fetchBackup reads the backup RESTORE_FROM names: "s3", an http(s) URL such
+// as the /admin/backup of another instance, or a file.
+func fetchBackup(ctx context.Context, cfg config) ([]byte, string, error) {
+	switch {
+	case cfg.restoreFrom == restoreFromS3:
+		latest, data, err := backup.New(cfg.backup, nil).Latest(ctx)
+		return data, "s3://" + cfg.backup.Bucket + "/" + latest.Key, err
+	case strings.HasPrefix(cfg.restoreFrom, "http://") || strings.HasPrefix(cfg.restoreFrom, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.restoreFrom, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		// Credentials in the URL log in, but are not logged
+		from := req.URL.Redacted()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, from, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, from, fmt.Errorf("%s: %s", from, resp.Status)
+		}
+		data, err := io.ReadAll(resp.Body)
+		return data, from, err
+	default:
+		data, err := os.ReadFile(cfg.restoreFrom)
+		return data, cfg.restoreFrom, err
+	}
+}
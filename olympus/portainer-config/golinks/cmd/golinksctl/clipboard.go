@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"golinks/client"
+)
+
+// maxSuggestedSlug bounds the length of a slug suggested from a title, as
+// the server bounds its title slugs.
+const maxSuggestedSlug = 40
+
+// readClipboard returns the text on the clipboard, through the paste tool
+// of the platform. It is a variable so tests can replace it.
+var readClipboard = func(ctx context.Context) (string, error) {
+	var cmd *exec.Cmd
+	switch {
+	case runtime.GOOS == "darwin":
+		cmd = exec.CommandContext(ctx, "pbpaste")
+	case runtime.GOOS == "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", "Get-Clipboard")
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		cmd = exec.CommandContext(ctx, "wl-paste", "--no-newline")
+	default:
+		cmd = exec.CommandContext(ctx, "xclip", "-selection", "clipboard", "-out")
+	}
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+var titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// pageTitle returns the title of the HTML page at rawURL, or "" if it has
+// none or does not answer within a few seconds.
+func pageTitle(ctx context.Context, rawURL string) string {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return ""
+	}
+	// The title is in the head, near the top
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256<<10))
+	m := titleRe.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+}
+
+// suggestSlug makes a slug from a page title in kebab-case, as the
+// server's title slug strategy does, else from the last part of the URL's
+// path or its host.
+func suggestSlug(title, rawURL string) string {
+	if slug := kebab(title); slug != "" {
+		return slug
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if base := path.Base(u.Path); base != "/" && base != "." {
+		return kebab(strings.TrimSuffix(base, path.Ext(base)))
+	}
+	return kebab(strings.TrimPrefix(u.Hostname(), "www."))
+}
+
+// kebab lowercases s and joins its runs of ASCII letters and digits with
+// "-", cut at a word boundary after maxSuggestedSlug characters.
+func kebab(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	slug := ""
+	for _, w := range words {
+		if slug != "" && len(slug)+1+len(w) > maxSuggestedSlug {
+			break
+		}
+		if slug != "" {
+			slug += "-"
+		}
+		slug += w
+	}
+	if len(slug) > maxSuggestedSlug {
+		slug = slug[:maxSuggestedSlug]
+	}
+	return slug
+}
+
+// isURL reports whether text is a single http or https URL.
+func isURL(text string) bool {
+	if strings.ContainsFunc(text, unicode.IsSpace) {
+		return false
+	}
+	u, err := url.Parse(text)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (c *cli) watchClipboard(ctx context.Context, args []string) error {
+	fs := c.flags("watch-clipboard")
+	interval := fs.Duration("interval", time.Second, "")
+	const syntax = "watch-clipboard [-interval 1s]"
+	if _, err := parse(fs, args, 0, syntax); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return usageError(syntax)
+	}
+
+	// What is on the clipboard already was not just copied
+	last, err := readClipboard(ctx)
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("reading the clipboard: %w", err)
+	}
+	last = strings.TrimSpace(last)
+	fmt.Fprintln(c.prompt, "Watching the clipboard for URLs, Ctrl-C to stop")
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		// An empty clipboard makes some paste tools fail
+		text, err := readClipboard(ctx)
+		if err != nil {
+			continue
+		}
+		if text = strings.TrimSpace(text); text == last {
+			continue
+		}
+		last = text
+		if !isURL(text) {
+			continue
+		}
+		if err := c.offer(ctx, text); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// offer asks whether to add a link to rawURL under a slug suggested from
+// its page title: Enter adds it, "n" skips it and anything else is the
+// slug to use instead. A failed add is reported and watching goes on.
+func (c *cli) offer(ctx context.Context, rawURL string) error {
+	title := pageTitle(ctx, rawURL)
+	slug := suggestSlug(title, rawURL)
+	if title != "" {
+		fmt.Fprintf(c.prompt, "\n%s\n%s\n", title, rawURL)
+	} else {
+		fmt.Fprintf(c.prompt, "\n%s\n", rawURL)
+	}
+	if slug != "" {
+		fmt.Fprintf(c.prompt, "Add go/%s? [Enter] add, n skip, or type another slug: ", slug)
+	} else {
+		fmt.Fprint(c.prompt, "Slug for it, or Enter to skip: ")
+	}
+	line, err := c.in.ReadString('\n')
+	if err != nil && line == "" {
+		return err
+	}
+	switch answer := strings.TrimSpace(line); answer {
+	case "":
+	case "n", "N":
+		return nil
+	default:
+		slug = strings.TrimPrefix(answer, "go/")
+	}
+	if slug == "" {
+		return nil
+	}
+
+	link, err := c.client.AddLink(ctx, client.AddLinkRequest{Slug: slug, URL: rawURL, Title: title})
+	if errors.Is(err, client.ErrUnauthorized) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(c.prompt, "not added: %s\n", errorMessage(err))
+		return nil
+	}
+	return c.printAdded(link)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWatchClipboard(t *testing.T) {
+	env := newTestEnv(t)
+	pages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/planning" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><head><title>\n  Quarterly Planning &amp; Goals | Wiki\n</title></head></html>"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("no title"))
+	}))
+	t.Cleanup(pages.Close)
+
+	// The clipboard changes once per read, then keeps its last text
+	clips := []string{
+		"https://before.example.com",
+		"https://before.example.com",
+		pages.URL + "/planning\n",
+		"some notes",
+		pages.URL + "/handbook.pdf",
+		pages.URL + "/planning",
+		pages.URL + "/onboarding",
+		pages.URL + "/last",
+	}
+	var mu sync.Mutex
+	orig := readClipboard
+	readClipboard = func(context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		clip := clips[0]
+		if len(clips) > 1 {
+			clips = clips[1:]
+		}
+		return clip, nil
+	}
+	t.Cleanup(func() { readClipboard = orig })
+
+	// Enter takes the title's slug, a typed slug replaces it, n skips, and
+	// the end of the input stops watching
+	var stdout, stderr bytes.Buffer
+	answers := strings.NewReader("\ndocs\nn\n")
+	code := run(context.Background(), []string{"watch-clipboard", "-interval", "1ms"}, func(k string) string { return env[k] }, answers, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+	want := "added go/quarterly-planning-goals-wiki -> " + pages.URL + "/planning\n" +
+		"added go/docs -> " + pages.URL + "/handbook.pdf\n"
+	if stdout.String() != want {
+		t.Errorf("stdout:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+	for _, prompt := range []string{"Quarterly Planning & Goals | Wiki\n", "Add go/handbook?", "Add go/onboarding?"} {
+		if !strings.Contains(stderr.String(), prompt) {
+			t.Errorf("no %q in prompts:\n%s", prompt, stderr.String())
+		}
+	}
+	if strings.Contains(stderr.String(), "before.example.com") || strings.Contains(stderr.String(), "some notes") {
+		t.Errorf("offered text that was not a newly copied URL:\n%s", stderr.String())
+	}
+
+	// A taken slug is reported and watching goes on
+	clips = []string{"", pages.URL + "/planning", pages.URL + "/other", pages.URL + "/last"}
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"watch-clipboard", "-interval", "1ms"}, func(k string) string { return env[k] }, strings.NewReader("docs\n\n"), &stdout, &stderr)
+	if code != 0 || !strings.Contains(stderr.String(), "not added: ") || stdout.String() != "added go/other -> "+pages.URL+"/other\n" {
+		t.Errorf("exit %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestSuggestSlug(t *testing.T) {
+	tests := []struct{ title, url, want string }{
+		{"Quarterly Planning – Confluence", "https://wiki.example.com/x", "quarterly-planning-confluence"},
+		{"", "https://example.com/docs/handbook.pdf", "handbook"},
+		{"", "https://www.example.com/", "example-com"},
+		{"🍕", "https://pizza.example.com", "pizza-example-com"},
+	}
+	for _, tt := range tests {
+		if got := suggestSlug(tt.title, tt.url); got != tt.want {
+			t.Errorf("suggestSlug(%q, %q) = %q, want %q", tt.title, tt.url, got, tt.want)
+		}
+	}
+}
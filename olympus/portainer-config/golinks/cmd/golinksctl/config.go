@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// settings are where the server is and how to log in.
+type settings struct {
+	URL      string `json:"url"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// defaultConfigPath is the config file used without -config or
+// GOLINKS_CONFIG: golinks/config.json in the user's config directory, such
+// as ~/.config on Linux.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "golinks", "config.json")
+}
+
+// loadSettings reads the config file at path, if it exists, and lets the
+// GOLINKS_URL, GOLINKS_USER and GOLINKS_PASSWORD variables of getenv
+// override it. A missing file is only an error if it was asked for
+// explicitly.
+func loadSettings(path string, explicit bool, getenv func(string) string) (settings, error) {
+	var s settings
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		case err != nil:
+			return settings{}, err
+		default:
+			if err := json.Unmarshal(data, &s); err != nil {
+				return settings{}, fmt.Errorf("reading %s: %w", path, err)
+			}
+		}
+	}
+	for name, field := range map[string]*string{"GOLINKS_URL": &s.URL, "GOLINKS_USER": &s.User, "GOLINKS_PASSWORD": &s.Password} {
+		if v := getenv(name); v != "" {
+			*field = v
+		}
+	}
+	if s.URL == "" {
+		return settings{}, errors.New("no server: set GOLINKS_URL or \"url\" in the config file")
+	}
+	return s, nil
+}
@@ -0,0 +1,375 @@
+// Command golinksctl manages the links of a golinks server from the
+// terminal, through its HTTP API. The name golinks is taken by the server
+// itself.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golinks/client"
+	"golinks/internal/textwidth"
+)
+
+const usage = `Usage: golinksctl [-json] [-config file] <command> [arguments]
+
+Commands:
+  add [-public] <slug> <url>   create a link
+  rm <slug>                    delete a link
+  mv [-alias] <from> <to>      rename a link; -alias keeps the old slug working
+  ls [-n count] [-sort slug]   newest links (default 50, 0 for all), or A to Z
+  search <text>                links whose slug or URL contains text
+  open [-print] <slug>         open where a link leads in the browser
+  watch-clipboard [-interval 1s]
+                               offer to add a link for each URL copied
+
+-json prints results as JSON instead of text and tables.
+
+The server and login are read from the config file, by default
+golinks/config.json in the user config directory (~/.config on Linux):
+
+  {"url": "https://go.example.com", "user": "admin", "password": "..."}
+
+GOLINKS_URL, GOLINKS_USER and GOLINKS_PASSWORD override it, and
+GOLINKS_CONFIG names another file.
+`
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr))
+}
+
+// usageError is a command line mistake, answered with exit status 2.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// cli runs one command against the server. Interactive commands read
+// answers from in and write their prompts to prompt, out of the way of
+// -json output.
+type cli struct {
+	client *client.Client
+	json   bool
+	out    io.Writer
+	in     *bufio.Reader
+	prompt io.Writer
+}
+
+// commands maps command names to their implementations.
+var commands = map[string]func(c *cli, ctx context.Context, args []string) error{
+	"add":             (*cli).add,
+	"rm":              (*cli).rm,
+	"mv":              (*cli).mv,
+	"ls":              (*cli).ls,
+	"search":          (*cli).search,
+	"open":            (*cli).open,
+	"watch-clipboard": (*cli).watchClipboard,
+}
+
+// run executes the command line args and returns the exit status.
+func run(ctx context.Context, args []string, getenv func(string) string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{out: stdout, in: bufio.NewReader(stdin), prompt: stderr}
+	global := flag.NewFlagSet("golinksctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { io.WriteString(stderr, usage) }
+	global.BoolVar(&c.json, "json", false, "")
+	configPath := global.String("config", "", "")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if global.NArg() == 0 {
+		io.WriteString(stderr, usage)
+		return 2
+	}
+	name, args := global.Arg(0), global.Args()[1:]
+	if name == "help" {
+		io.WriteString(stdout, usage)
+		return 0
+	}
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "golinksctl: unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+
+	path, explicit := *configPath, *configPath != ""
+	if !explicit {
+		if path = getenv("GOLINKS_CONFIG"); path != "" {
+			explicit = true
+		} else {
+			path = defaultConfigPath()
+		}
+	}
+	s, err := loadSettings(path, explicit, getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "golinksctl: %v\n", err)
+		return 1
+	}
+	if c.client, err = client.New(client.Config{BaseURL: s.URL, Username: s.User, Password: s.Password}); err != nil {
+		fmt.Fprintf(stderr, "golinksctl: %v\n", err)
+		return 1
+	}
+
+	err = command(c, ctx, args)
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "usage: golinksctl %s\n", usageErr)
+		return 2
+	}
+	fmt.Fprintf(stderr, "golinksctl: %s\n", errorMessage(err))
+	return 1
+}
+
+// errorMessage describes a failed command to the user.
+func errorMessage(err error) string {
+	var apiErr *client.Error
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return "no such link"
+	case errors.Is(err, client.ErrUnauthorized):
+		return "login failed; check GOLINKS_USER and GOLINKS_PASSWORD"
+	case errors.As(err, &apiErr):
+		return apiErr.Message
+	}
+	return err.Error()
+}
+
+// flags returns the flag set of a command, which also accepts -json after
+// the command name.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&c.json, "json", c.json, "")
+	return fs
+}
+
+// parse parses the flags of a command and checks it got n arguments, or at
+// least one if n is -1.
+func parse(fs *flag.FlagSet, args []string, n int, syntax string) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, usageError(syntax)
+	}
+	if (n >= 0 && fs.NArg() != n) || (n < 0 && fs.NArg() == 0) {
+		return nil, usageError(syntax)
+	}
+	return fs.Args(), nil
+}
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *cli) add(ctx context.Context, args []string) error {
+	fs := c.flags("add")
+	public := fs.Bool("public", false, "")
+	args, err := parse(fs, args, 2, "add [-public] <slug> <url>")
+	if err != nil {
+		return err
+	}
+	link, err := c.client.AddLink(ctx, client.AddLinkRequest{Slug: args[0], URL: args[1], Public: *public})
+	if err != nil {
+		return err
+	}
+	return c.printAdded(link)
+}
+
+// printAdded reports a link that was added.
+func (c *cli) printAdded(link client.Link) error {
+	if c.json {
+		return c.printJSON(link)
+	}
+	if link.Status == "pending" {
+		fmt.Fprintf(c.out, "added go/%s -> %s (pending approval by another admin)\n", link.Slug, link.URL)
+		return nil
+	}
+	fmt.Fprintf(c.out, "added go/%s -> %s\n", link.Slug, link.URL)
+	return nil
+}
+
+func (c *cli) rm(ctx context.Context, args []string) error {
+	args, err := parse(c.flags("rm"), args, 1, "rm <slug>")
+	if err != nil {
+		return err
+	}
+	if err := c.client.RemoveLink(ctx, args[0]); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(map[string]string{"status": "removed", "slug": args[0]})
+	}
+	fmt.Fprintf(c.out, "removed go/%s\n", args[0])
+	return nil
+}
+
+func (c *cli) mv(ctx context.Context, args []string) error {
+	fs := c.flags("mv")
+	alias := fs.Bool("alias", false, "")
+	args, err := parse(fs, args, 2, "mv [-alias] <from> <to>")
+	if err != nil {
+		return err
+	}
+	if err := c.client.RenameLink(ctx, args[0], args[1], *alias); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(map[string]any{"status": "renamed", "from": args[0], "to": args[1], "alias": *alias})
+	}
+	fmt.Fprintf(c.out, "renamed go/%s -> go/%s", args[0], args[1])
+	if *alias {
+		fmt.Fprintf(c.out, ", go/%s still works", args[0])
+	}
+	fmt.Fprintln(c.out)
+	return nil
+}
+
+func (c *cli) ls(ctx context.Context, args []string) error {
+	fs := c.flags("ls")
+	n := fs.Int("n", 50, "")
+	sort := fs.String("sort", "created_at", "")
+	const syntax = "ls [-n count] [-sort created_at|slug]"
+	if _, err := parse(fs, args, 0, syntax); err != nil {
+		return err
+	}
+	if *n < 0 || (*sort != "created_at" && *sort != "slug") {
+		return usageError(syntax)
+	}
+	opts := client.ListOptions{Sort: *sort}
+	if *n == 0 {
+		links, err := c.client.ListAll(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return c.printLinks(links, len(links))
+	}
+	// Pages hold at most 500 links
+	opts.Limit = min(*n, 500)
+	page, err := c.client.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	links := page.Links
+	for opts.Page = 2; len(links) < *n && opts.Page <= page.Pages; opts.Page++ {
+		next, err := c.client.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		links = append(links, next.Links...)
+	}
+	return c.printLinks(links[:min(*n, len(links))], page.Total)
+}
+
+func (c *cli) search(ctx context.Context, args []string) error {
+	args, err := parse(c.flags("search"), args, -1, "search <text>")
+	if err != nil {
+		return err
+	}
+	links, err := c.client.ListAll(ctx, client.ListOptions{Query: strings.Join(args, " ")})
+	if err != nil {
+		return err
+	}
+	return c.printLinks(links, len(links))
+}
+
+// printLinks prints links as a table, or as a JSON array, noting when they
+// are only the first of total.
+func (c *cli) printLinks(links []client.Link, total int) error {
+	if c.json {
+		if links == nil {
+			links = []client.Link{}
+		}
+		return c.printJSON(links)
+	}
+	if len(links) == 0 {
+		fmt.Fprintln(c.out, "no links")
+		return nil
+	}
+	rows := [][]string{{"SLUG", "URL", "STATUS", "CLICKS"}}
+	for _, link := range links {
+		rows = append(rows, []string{"go/" + link.Slug, link.URL, link.Status, strconv.Itoa(link.Clicks)})
+	}
+	if err := c.printTable(rows); err != nil {
+		return err
+	}
+	if len(links) < total {
+		fmt.Fprintf(c.out, "(%d of %d shown)\n", len(links), total)
+	}
+	return nil
+}
+
+// printTable prints rows in columns two spaces apart. Columns are as wide
+// as their text shows in a terminal, not as its bytes, as tabwriter would
+// have it, so emoji slugs keep the columns after them in line.
+func (c *cli) printTable(rows [][]string) error {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], textwidth.Width(cell))
+		}
+	}
+	var b strings.Builder
+	for _, row := range rows {
+		for i, cell := range row {
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-textwidth.Width(cell)+2))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(c.out, b.String())
+	return err
+}
+
+// openBrowser opens target in the user's browser. It is a variable so
+// tests can replace it.
+var openBrowser = func(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
+
+func (c *cli) open(ctx context.Context, args []string) error {
+	fs := c.flags("open")
+	printOnly := fs.Bool("print", false, "")
+	args, err := parse(fs, args, 1, "open [-print] <slug>")
+	if err != nil {
+		return err
+	}
+	target, err := c.client.Resolve(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		err = c.printJSON(map[string]string{"slug": args[0], "url": target})
+	} else {
+		_, err = fmt.Fprintln(c.out, target)
+	}
+	if err != nil || *printOnly {
+		return err
+	}
+	return openBrowser(target)
+}
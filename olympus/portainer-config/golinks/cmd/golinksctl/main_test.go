@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+	"golinks/internal/textwidth"
+)
+
+func newTestEnv(t *testing.T) map[string]string {
+	t.Helper()
+	api := httpapi.New(httpapi.Config{Admins: map[string]string{"alice": "pw"}}, store.NewMemory(), httpapi.Pages{Index: http.NotFoundHandler()})
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close)
+	// The file's server and login are overridden by the environment
+	config := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(config, []byte(`{"url": "http://unused.invalid", "user": "bob"}`), 0o600)
+	return map[string]string{
+		"GOLINKS_URL":      srv.URL,
+		"GOLINKS_USER":     "alice",
+		"GOLINKS_PASSWORD": "pw",
+		"GOLINKS_CONFIG":   config,
+	}
+}
+
+// runCLI runs a command line and returns its exit status and output.
+func runCLI(t *testing.T, env map[string]string, line string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), strings.Fields(line), func(k string) string { return env[k] }, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	env := newTestEnv(t)
+
+	steps := []struct {
+		line string
+		code int
+		want string
+	}{
+		{"add wiki https://wiki.example.com", 0, "added go/wiki -> https://wiki.example.com\n"},
+		{"add -public mail https://mail.example.com", 0, "added go/mail"},
+		{"add wiki https://other.example.com", 1, ""},
+		{"add wiki", 2, ""},
+		{"mv -alias mail email", 0, "renamed go/mail -> go/email, go/mail still works\n"},
+		{"open -print mail", 0, "https://mail.example.com\n"},
+		{"ls -sort slug", 0, "SLUG      URL                       STATUS  CLICKS\ngo/email  https://mail.example.com  active  0\ngo/wiki   https://wiki.example.com  active  0\n"},
+		{"ls -n 1", 0, "(1 of 2 shown)\n"},
+		{"search WIKI", 0, "go/wiki"},
+		{"search nothing", 0, "no links\n"},
+		{"rm wiki", 0, "removed go/wiki\n"},
+		{"rm wiki", 1, ""},
+		{"frobnicate", 2, ""},
+		{"-config /nonexistent/golinks.json ls", 1, ""},
+	}
+	for _, step := range steps {
+		code, stdout, stderr := runCLI(t, env, step.line)
+		if code != step.code || !strings.Contains(stdout, step.want) {
+			t.Errorf("%s: exit %d, want %d\nstdout: %s\nstderr: %s", step.line, code, step.code, stdout, stderr)
+		}
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	env := newTestEnv(t)
+	runCLI(t, env, "add wiki https://wiki.example.com")
+
+	_, stdout, _ := runCLI(t, env, "-json ls")
+	var links []struct{ Slug, URL string }
+	if err := json.Unmarshal([]byte(stdout), &links); err != nil || len(links) != 1 || links[0].URL != "https://wiki.example.com" {
+		t.Errorf("ls -json = %s (%v)", stdout, err)
+	}
+	_, stdout, _ = runCLI(t, env, "search -json nothing")
+	if strings.TrimSpace(stdout) != "[]" {
+		t.Errorf("empty search -json = %s", stdout)
+	}
+
+	var opened string
+	orig := openBrowser
+	openBrowser = func(target string) error { opened = target; return nil }
+	t.Cleanup(func() { openBrowser = orig })
+	_, stdout, _ = runCLI(t, env, "open -json wiki")
+	var resolved struct{ Slug, URL string }
+	if err := json.Unmarshal([]byte(stdout), &resolved); err != nil || resolved.URL != "https://wiki.example.com" || opened != resolved.URL {
+		t.Errorf("open -json = %s, opened %q", stdout, opened)
+	}
+}
+
+func TestListEmojiSlugs(t *testing.T) {
+	env := newTestEnv(t)
+	runCLI(t, env, "add 🍕 https://pizza.example.com")
+	runCLI(t, env, "add wiki https://wiki.example.com")
+
+	// The URLs start in the same terminal column on every line
+	_, stdout, _ := runCLI(t, env, "ls -sort slug")
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("ls = %q", stdout)
+	}
+	for _, line := range lines {
+		i := strings.Index(line, "URL")
+		if i < 0 {
+			i = strings.Index(line, "https://")
+		}
+		if got := textwidth.Width(line[:i]); got != len("go/wiki  ") {
+			t.Errorf("URL column of %q at %d, want %d", line, got, len("go/wiki  "))
+		}
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"url": "https://go.example.com", "user": "alice", "password": "file"}`), 0o600)
+	env := map[string]string{"GOLINKS_PASSWORD": "env"}
+	s, err := loadSettings(path, true, func(k string) string { return env[k] })
+	if err != nil || s.URL != "https://go.example.com" || s.User != "alice" || s.Password != "env" {
+		t.Errorf("settings = %+v, %v", s, err)
+	}
+	if _, err := loadSettings(filepath.Join(t.TempDir(), "missing.json"), false, func(string) string { return "" }); err == nil || !strings.Contains(err.Error(), "GOLINKS_URL") {
+		t.Errorf("no server configured: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package accesslog writes one line per HTTP request in the Apache combined
+// format or as JSON, apart from the application log, for tools such as
+// goaccess and log shippers.
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats.
+const (
+	// Combined is the Apache combined log format.
+	Combined = "combined"
+	// JSON is one JSON object per line.
+	JSON = "json"
+)
+
+// Stdout is the Path that writes to standard output.
+const Stdout = "stdout"
+
+// Config names where the access log goes and how it looks.
+type Config struct {
+	// Path is the file lines are appended to, or Stdout; empty disables
+	// the access log.
+	Path string
+	// Format is Combined or JSON.
+	Format string
+}
+
+// Enabled reports whether an access log is configured.
+func (c Config) Enabled() bool {
+	return c.Path != ""
+}
+
+// ValidFormat reports whether format is known.
+func ValidFormat(format string) bool {
+	return format == Combined || format == JSON
+}
+
+// Entry is one request and its response.
+type Entry struct {
+	// Time is when the request came in.
+	Time time.Time
+	// RemoteAddr is the client's address, User the name it logged in with.
+	RemoteAddr string
+	User       string
+	Method     string
+	URI        string
+	Proto      string
+	Status     int
+	// Bytes is the size of the response body.
+	Bytes     int64
+	Referer   string
+	UserAgent string
+	Duration  time.Duration
+	RequestID string
+}
+
+// Logger writes entries to one output.
+type Logger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+	closer io.Closer
+}
+
+// New returns a Logger writing entries to w in format.
+func New(w io.Writer, format string) *Logger {
+	return &Logger{w: w, format: format}
+}
+
+// Open returns a Logger for cfg, which must be enabled with a valid format,
+// appending to its file.
+func Open(cfg Config) (*Logger, error) {
+	if cfg.Path == Stdout {
+		return New(os.Stdout, cfg.Format), nil
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening access log: %w", err)
+	}
+	l := New(f, cfg.Format)
+	l.closer = f
+	return l, nil
+}
+
+// Log writes e. Write errors are dropped: a full disk must not fail the
+// request.
+func (l *Logger) Log(e Entry) {
+	var line []byte
+	if l.format == JSON {
+		line = jsonLine(e)
+	} else {
+		line = combinedLine(e)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(line)
+}
+
+// Close closes the file of a Logger from Open.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// combinedLine formats e as Apache does with
+// `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`.
+func combinedLine(e Entry) []byte {
+	var b strings.Builder
+	b.WriteString(orDash(e.RemoteAddr))
+	b.WriteString(" - ")
+	b.WriteString(orDash(escape(e.User)))
+	b.WriteString(e.Time.Format(" [02/Jan/2006:15:04:05 -0700] "))
+	fmt.Fprintf(&b, `"%s %s %s" %d `, escape(e.Method), escape(e.URI), escape(e.Proto), e.Status)
+	if e.Bytes > 0 {
+		b.WriteString(strconv.FormatInt(e.Bytes, 10))
+	} else {
+		b.WriteString("-")
+	}
+	fmt.Fprintf(&b, " \"%s\" \"%s\"\n", orDash(escape(e.Referer)), orDash(escape(e.UserAgent)))
+	return []byte(b.String())
+}
+
+// jsonEntry is the JSON form of an Entry.
+type jsonEntry struct {
+	Time       string  `json:"time"`
+	RemoteAddr string  `json:"remote_addr"`
+	User       string  `json:"user,omitempty"`
+	Method     string  `json:"method"`
+	URI        string  `json:"uri"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	Referer    string  `json:"referer,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	RequestID  string  `json:"request_id,omitempty"`
+}
+
+func jsonLine(e Entry) []byte {
+	line, _ := json.Marshal(jsonEntry{
+		Time:       e.Time.Format(time.RFC3339Nano),
+		RemoteAddr: e.RemoteAddr,
+		User:       e.User,
+		Method:     e.Method,
+		URI:        e.URI,
+		Proto:      e.Proto,
+		Status:     e.Status,
+		Bytes:      e.Bytes,
+		Referer:    e.Referer,
+		UserAgent:  e.UserAgent,
+		DurationMS: float64(e.Duration.Microseconds()) / 1000,
+		RequestID:  e.RequestID,
+	})
+	return append(line, '\n')
+}
+
+// escape escapes quotes, backslashes and control characters as Apache
+// does, so a field cannot break out of its quotes or its line.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var entry = Entry{
+	Time:       time.Date(2026, 3, 9, 14, 5, 7, 0, time.FixedZone("", 3600)),
+	RemoteAddr: "192.0.2.7",
+	Method:     "GET",
+	URI:        `/wiki?q="x"`,
+	Proto:      "HTTP/1.1",
+	Status:     302,
+	Bytes:      38,
+	UserAgent:  "curl/8.5.0\n",
+	Duration:   1500 * time.Microsecond,
+	RequestID:  "req-7",
+}
+
+func TestCombined(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Combined)
+	l.Log(entry)
+	e := entry
+	e.User, e.Bytes = "admin", 0
+	l.Log(e)
+
+	want := `192.0.2.7 - - [09/Mar/2026:14:05:07 +0100] "GET /wiki?q=\"x\" HTTP/1.1" 302 38 "-" "curl/8.5.0\x0a"` + "\n" +
+		`192.0.2.7 - admin [09/Mar/2026:14:05:07 +0100] "GET /wiki?q=\"x\" HTTP/1.1" 302 - "-" "curl/8.5.0\x0a"` + "\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, JSON).Log(entry)
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %v", buf.String(), err)
+	}
+	if got["time"] != "2026-03-09T14:05:07+01:00" || got["status"] != 302.0 || got["duration_ms"] != 1.5 ||
+		got["uri"] != `/wiki?q="x"` || got["request_id"] != "req-7" || got["user_agent"] != "curl/8.5.0\n" {
+		t.Errorf("entry = %v", got)
+	}
+	if _, ok := got["user"]; ok {
+		t.Errorf("empty user logged: %v", got)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	for range 2 {
+		l, err := Open(Config{Path: path, Format: Combined})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Log(entry)
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A reopened file is appended to
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 2 {
+		t.Errorf("%d lines, want 2:\n%s", n, data)
+	}
+}
@@ -0,0 +1,260 @@
+// Package approval posts links waiting for approval to chat, Slack or a
+// Matrix room, with approve and reject actions that call back into the
+// server. Every action carries a signed token naming the link and the
+// destination it was proposed with, so a link changed in the meantime is
+// not approved by an old message.
+package approval
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golinks/internal/notify"
+	"golinks/internal/store"
+)
+
+// Actions on a pending link.
+const (
+	Approve = "approve"
+	Reject  = "reject"
+)
+
+const (
+	// tokenTTL is how long the actions of a proposal stay valid.
+	tokenTTL = 7 * 24 * time.Hour
+	// slackMaxAge bounds the age of a Slack callback, against replays.
+	slackMaxAge = 5 * time.Minute
+)
+
+var (
+	// ErrBadToken is returned for a token that is malformed or not signed
+	// with the secret.
+	ErrBadToken = errors.New("invalid approval token")
+	// ErrExpired is returned for a validly signed token past its expiry.
+	ErrExpired = errors.New("approval token expired")
+	// ErrBadSignature is returned for a Slack callback that is not signed
+	// with the signing secret or is too old.
+	ErrBadSignature = errors.New("invalid Slack signature")
+)
+
+// Config selects the chat proposals are posted to. Both channels can be
+// used at once.
+type Config struct {
+	// BaseURL is the public URL of the server, e.g. https://go.example.com,
+	// for the confirmation links posted to Matrix.
+	BaseURL string
+	// Secret signs the action tokens.
+	Secret string
+	// SlackWebhookURL is an incoming webhook of a Slack app whose
+	// interactivity request URL is BaseURL + "/chat/slack".
+	SlackWebhookURL string
+	// SlackSigningSecret verifies the callbacks of the Slack app.
+	SlackSigningSecret string
+	// SlackAdmins maps the Slack user IDs allowed to act on links to the
+	// admins they are. Clicks of anyone else are refused.
+	SlackAdmins map[string]string
+	// MatrixHomeserver, MatrixRoom and MatrixToken select the room and the
+	// bot user messages are sent as.
+	MatrixHomeserver string
+	MatrixRoom       string
+	MatrixToken      string
+}
+
+// Enabled reports whether any chat is configured.
+func (c Config) Enabled() bool {
+	return c.SlackWebhookURL != "" || c.MatrixRoom != ""
+}
+
+// Action is a decision on a pending link, as carried by a token.
+type Action struct {
+	Kind string `json:"a"`
+	Slug string `json:"s"`
+	// URLHash identifies the destination the link was proposed with.
+	URLHash string    `json:"u"`
+	Expires time.Time `json:"e"`
+}
+
+// Matches reports whether the action was issued for link as it is now.
+func (a Action) Matches(link store.Link) bool {
+	return a.Slug == link.Slug && hmac.Equal([]byte(a.URLHash), []byte(urlHash(link.URL)))
+}
+
+// Notifier posts proposals and checks the actions coming back.
+type Notifier struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+	// wg tracks proposals being posted.
+	wg sync.WaitGroup
+}
+
+// New creates a Notifier.
+func New(cfg Config) *Notifier {
+	return &Notifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}, now: time.Now}
+}
+
+// Propose posts link, if it is pending, to the configured chats. Posting
+// happens in the background so the request that added the link is not
+// held up.
+func (n *Notifier) Propose(link store.Link) {
+	if link.Status != store.StatusPending {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		if err := n.post(context.Background(), link); err != nil {
+			slog.Error("Approval request failed", "slug", link.Slug, "error", err)
+		}
+	}()
+}
+
+func (n *Notifier) post(ctx context.Context, link store.Link) error {
+	approve := n.Sign(Approve, link)
+	reject := n.Sign(Reject, link)
+	text := fmt.Sprintf("go/%s → %s needs approval", link.Slug, link.URL)
+	if link.CreatedBy != "" {
+		text += " (proposed by " + link.CreatedBy + ")"
+	}
+
+	var errs []error
+	if n.cfg.SlackWebhookURL != "" {
+		if err := notify.PostWebhook(ctx, n.client, n.cfg.SlackWebhookURL, slackMessage(text, approve, reject)); err != nil {
+			errs = append(errs, fmt.Errorf("slack: %w", err))
+		}
+	}
+	if n.cfg.MatrixRoom != "" {
+		approveURL := n.ConfirmURL(approve)
+		rejectURL := n.ConfirmURL(reject)
+		plain := fmt.Sprintf("%s\nApprove: %s\nReject: %s", text, approveURL, rejectURL)
+		formatted := fmt.Sprintf(`%s<br><a href="%s">Approve</a> · <a href="%s">Reject</a>`,
+			html.EscapeString(text), html.EscapeString(approveURL), html.EscapeString(rejectURL))
+		// Tokens are unique per proposal, so the approve token doubles as
+		// the transaction ID
+		txnID := "golinks-" + urlHash(approve)
+		if err := notify.PostMatrix(ctx, n.client, n.cfg.MatrixHomeserver, n.cfg.MatrixRoom, n.cfg.MatrixToken, txnID, plain, formatted); err != nil {
+			errs = append(errs, fmt.Errorf("matrix: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// slackMessage builds a Block Kit message with approve and reject buttons.
+func slackMessage(text, approve, reject string) map[string]any {
+	button := func(label, style, actionID, value string) map[string]any {
+		return map[string]any{
+			"type":      "button",
+			"text":      map[string]string{"type": "plain_text", "text": label},
+			"style":     style,
+			"action_id": actionID,
+			"value":     value,
+		}
+	}
+	return map[string]any{
+		"text": text,
+		"blocks": []any{
+			map[string]any{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": text}},
+			map[string]any{"type": "actions", "elements": []any{
+				button("Approve", "primary", Approve, approve),
+				button("Reject", "danger", Reject, reject),
+			}},
+		},
+	}
+}
+
+// ConfirmURL returns the page confirming the action of token.
+func (n *Notifier) ConfirmURL(token string) string {
+	return strings.TrimSuffix(n.cfg.BaseURL, "/") + "/chat/approval?token=" + url.QueryEscape(token)
+}
+
+// Sign returns a token for kind on link as it is now.
+func (n *Notifier) Sign(kind string, link store.Link) string {
+	payload, _ := json.Marshal(Action{
+		Kind:    kind,
+		Slug:    link.Slug,
+		URLHash: urlHash(link.URL),
+		Expires: n.now().Add(tokenTTL).UTC().Truncate(time.Second),
+	})
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(n.mac(payload))
+}
+
+// Verify returns the action of token, or ErrBadToken or ErrExpired.
+func (n *Notifier) Verify(token string) (Action, error) {
+	enc := base64.RawURLEncoding
+	p, s, ok := strings.Cut(token, ".")
+	payload, err1 := enc.DecodeString(p)
+	sig, err2 := enc.DecodeString(s)
+	if !ok || err1 != nil || err2 != nil || !hmac.Equal(sig, n.mac(payload)) {
+		return Action{}, ErrBadToken
+	}
+	var a Action
+	if err := json.Unmarshal(payload, &a); err != nil || (a.Kind != Approve && a.Kind != Reject) {
+		return Action{}, ErrBadToken
+	}
+	if n.now().After(a.Expires) {
+		return Action{}, ErrExpired
+	}
+	return a, nil
+}
+
+func (n *Notifier) mac(payload []byte) []byte {
+	m := hmac.New(sha256.New, []byte(n.cfg.Secret))
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+// VerifySlack checks that body was sent by Slack, signed with the signing
+// secret, within the last few minutes.
+func (n *Notifier) VerifySlack(header http.Header, body []byte) error {
+	if n.cfg.SlackSigningSecret == "" {
+		return ErrBadSignature
+	}
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if age := n.now().Sub(time.Unix(sec, 0)); age > slackMaxAge || age < -slackMaxAge {
+		return ErrBadSignature
+	}
+	m := hmac.New(sha256.New, []byte(n.cfg.SlackSigningSecret))
+	fmt.Fprintf(m, "v0:%s:%s", ts, body)
+	want := "v0=" + hex.EncodeToString(m.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// SlackAdmin returns the admin the Slack user with ID userID is, if any.
+func (n *Notifier) SlackAdmin(userID string) (string, bool) {
+	admin, ok := n.cfg.SlackAdmins[userID]
+	return admin, ok && userID != ""
+}
+
+// RespondSlack replaces the message of a Slack interaction with text,
+// removing its buttons.
+func (n *Notifier) RespondSlack(ctx context.Context, responseURL, text string) error {
+	return notify.PostWebhook(ctx, n.client, responseURL, map[string]any{"replace_original": true, "text": text})
+}
+
+// urlHash is a short digest of a destination URL.
+func urlHash(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,134 @@
+package approval
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestTokens(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	n := New(Config{Secret: "0123456789abcdef"})
+	n.now = func() time.Time { return now }
+	link := store.Link{Slug: "bank", URL: "https://login.bank.example", Status: store.StatusPending}
+
+	token := n.Sign(Approve, link)
+	a, err := n.Verify(token)
+	if err != nil || a.Kind != Approve || a.Slug != "bank" || !a.Matches(link) {
+		t.Fatalf("Verify = %+v, %v", a, err)
+	}
+	if a.Matches(store.Link{Slug: "bank", URL: "https://evil.example"}) {
+		t.Error("token matches a changed destination")
+	}
+
+	payload, sig, _ := strings.Cut(token, ".")
+	other := New(Config{Secret: "another secret!!"})
+	tests := map[string]string{
+		"tampered":     payload[:len(payload)-2] + "AA." + sig,
+		"wrong secret": other.Sign(Approve, link),
+		"unsigned":     payload,
+		"empty":        "",
+	}
+	for name, token := range tests {
+		if _, err := n.Verify(token); !errors.Is(err, ErrBadToken) {
+			t.Errorf("%s: Verify = %v, want ErrBadToken", name, err)
+		}
+	}
+
+	now = now.Add(tokenTTL + time.Minute)
+	if _, err := n.Verify(token); !errors.Is(err, ErrExpired) {
+		t.Errorf("old token: Verify = %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifySlack(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	n := New(Config{SlackSigningSecret: "slack-secret"})
+	n.now = func() time.Time { return now }
+	body := []byte("payload=%7B%7D")
+
+	sign := func(ts time.Time, secret string) http.Header {
+		m := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(m, "v0:%d:%s", ts.Unix(), body)
+		h := http.Header{}
+		h.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts.Unix(), 10))
+		h.Set("X-Slack-Signature", "v0="+hex.EncodeToString(m.Sum(nil)))
+		return h
+	}
+	if err := n.VerifySlack(sign(now, "slack-secret"), body); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := n.VerifySlack(sign(now, "wrong"), body); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("wrong secret: %v, want ErrBadSignature", err)
+	}
+	if err := n.VerifySlack(sign(now.Add(-10*time.Minute), "slack-secret"), body); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("replayed callback: %v, want ErrBadSignature", err)
+	}
+	if err := n.VerifySlack(http.Header{}, body); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("unsigned callback: %v, want ErrBadSignature", err)
+	}
+}
+
+func TestPropose(t *testing.T) {
+	var mu sync.Mutex
+	var slack []string
+	var matrix []map[string]string
+	var matrixPath, matrixAuth string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		slack = append(slack, string(body))
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	homeserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]string
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		matrix = append(matrix, msg)
+		matrixPath, matrixAuth = r.URL.EscapedPath(), r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer homeserver.Close()
+
+	n := New(Config{
+		BaseURL:          "https://go.example.com/",
+		Secret:           "0123456789abcdef",
+		SlackWebhookURL:  hook.URL,
+		MatrixHomeserver: homeserver.URL,
+		MatrixRoom:       "!room:example.com",
+		MatrixToken:      "bot-token",
+	})
+	n.Propose(store.Link{Slug: "wiki", URL: "https://wiki.example.com", Status: store.StatusActive})
+	n.Propose(store.Link{Slug: "bank", URL: "https://login.bank.example", Status: store.StatusPending, CreatedBy: "alice"})
+	n.wg.Wait()
+
+	if len(slack) != 1 || !strings.Contains(slack[0], "go/bank → https://login.bank.example needs approval (proposed by alice)") ||
+		!strings.Contains(slack[0], `"action_id":"approve"`) || !strings.Contains(slack[0], `"action_id":"reject"`) {
+		t.Errorf("Slack messages = %q", slack)
+	}
+	if len(matrix) != 1 || !strings.HasPrefix(matrixPath, "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/golinks-") || matrixAuth != "Bearer bot-token" {
+		t.Fatalf("Matrix messages = %v at %s (%s)", matrix, matrixPath, matrixAuth)
+	}
+	u, err := url.Parse(strings.TrimPrefix(strings.Split(matrix[0]["body"], "\n")[1], "Approve: "))
+	if err != nil || u.Host != "go.example.com" || u.Path != "/chat/approval" {
+		t.Fatalf("approve link = %v, %v", u, err)
+	}
+	if a, err := n.Verify(u.Query().Get("token")); err != nil || a.Kind != Approve || a.Slug != "bank" {
+		t.Errorf("approve link token = %+v, %v", a, err)
+	}
+}
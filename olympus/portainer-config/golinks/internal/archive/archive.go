@@ -0,0 +1,298 @@
+// Package archive exports the state of an instance to a single file and
+// imports it again, to move to another server or store backend or to
+// recover from a lost database.
+//
+// An archive is a gzipped tar file holding manifest.json, links.json,
+// collections.json and aliases.json. It is written through the store.Store
+// interface, so it does not depend on the database the instance uses.
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golinks/internal/store"
+)
+
+// Version is the archive format written by Export. Import reads archives
+// up to this version.
+const Version = 1
+
+// maxEntrySize bounds a single file in an archive being imported.
+const maxEntrySize = 256 << 20
+
+const (
+	manifestFile    = "manifest.json"
+	linksFile       = "links.json"
+	collectionsFile = "collections.json"
+	aliasesFile     = "aliases.json"
+)
+
+// Manifest describes an archive. The counts let an import be checked
+// against the instance it came from.
+type Manifest struct {
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	Links       int       `json:"links"`
+	Collections int       `json:"collections"`
+	Aliases     int       `json:"aliases"`
+	// Clicks is the sum of the click counts of the links.
+	Clicks int `json:"clicks"`
+}
+
+// Export writes every link, with its click count and last use, every
+// collection and every alias of st to w. Links are written to a temporary file as they are
+// read, since a tar entry needs its size before its contents and the
+// manifest before the links needs their counts.
+func Export(ctx context.Context, st store.Store, w io.Writer) (Manifest, error) {
+	tmp, err := os.CreateTemp("", "golinks-archive-*.json")
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	m := Manifest{Version: Version, CreatedAt: time.Now().UTC()}
+	bw := bufio.NewWriter(tmp)
+	bw.WriteString("[")
+	err = st.EachLink(ctx, func(link store.Link) error {
+		data, err := json.MarshalIndent(link, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		if m.Links > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n  ")
+		_, err = bw.Write(data)
+		m.Links++
+		m.Clicks += link.Clicks
+		return err
+	})
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read links: %w", err)
+	}
+	if m.Links > 0 {
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]")
+	if err := bw.Flush(); err != nil {
+		return Manifest{}, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return Manifest{}, err
+	}
+
+	collections, err := st.ListCollections(ctx)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read collections: %w", err)
+	}
+	if collections == nil {
+		collections = []store.Collection{}
+	}
+	m.Collections = len(collections)
+	aliases, err := st.ListAliases(ctx)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read aliases: %w", err)
+	}
+	if aliases == nil {
+		aliases = []store.Alias{}
+	}
+	m.Aliases = len(aliases)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	entry := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: m.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	jsonEntry := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return entry(name, int64(len(data)), bytes.NewReader(data))
+	}
+	if err := jsonEntry(manifestFile, m); err != nil {
+		return Manifest{}, err
+	}
+	if err := entry(linksFile, size, tmp); err != nil {
+		return Manifest{}, err
+	}
+	if err := jsonEntry(collectionsFile, collections); err != nil {
+		return Manifest{}, err
+	}
+	if err := jsonEntry(aliasesFile, aliases); err != nil {
+		return Manifest{}, err
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return m, gz.Close()
+}
+
+// Result summarizes an import.
+type Result struct {
+	Manifest Manifest
+	// Links, Collections and Aliases count what was added; Skipped lists
+	// the slugs and "+"-prefixed collection names that already existed and
+	// were left as they are.
+	Links       int
+	Collections int
+	Aliases     int
+	Skipped     []string
+	// Removed counts the links a replacing Restore removed first.
+	Removed int
+}
+
+// Import adds the links, collections and aliases of the archive in r to
+// st. Those that already exist are skipped, so importing the same archive
+// twice is harmless.
+func Import(ctx context.Context, st store.Store, r io.Reader) (Result, error) {
+	m, links, collections, aliases, err := read(r)
+	if err != nil {
+		return Result{}, err
+	}
+	res, err := add(ctx, st, links, collections, aliases)
+	res.Manifest = m
+	return res, err
+}
+
+// add restores links, collections and aliases into st, skipping those
+// that exist. An alias is skipped too if its slug is a link's.
+func add(ctx context.Context, st store.Store, links []store.Link, collections []store.Collection, aliases []store.Alias) (Result, error) {
+	var res Result
+	for _, link := range links {
+		err := st.RestoreLink(ctx, link)
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			res.Skipped = append(res.Skipped, link.Slug)
+		case err != nil:
+			return res, fmt.Errorf("failed to restore link %s: %w", link.Slug, err)
+		default:
+			res.Links++
+		}
+	}
+	for _, c := range collections {
+		_, err := st.GetCollection(ctx, c.Name)
+		switch {
+		case err == nil:
+			res.Skipped = append(res.Skipped, "+"+c.Name)
+			continue
+		case !errors.Is(err, store.ErrNotFound):
+			return res, fmt.Errorf("failed to restore collection %s: %w", c.Name, err)
+		}
+		if err := st.SaveCollection(ctx, c); err != nil {
+			return res, fmt.Errorf("failed to restore collection %s: %w", c.Name, err)
+		}
+		res.Collections++
+	}
+	for _, a := range aliases {
+		if _, err := st.ResolveAlias(ctx, a.Slug); err == nil {
+			res.Skipped = append(res.Skipped, a.Slug)
+			continue
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return res, fmt.Errorf("failed to restore alias %s: %w", a.Slug, err)
+		}
+		err := st.SetAlias(ctx, a)
+		switch {
+		case errors.Is(err, store.ErrConflict) || errors.Is(err, store.ErrNotFound):
+			res.Skipped = append(res.Skipped, a.Slug)
+		case err != nil:
+			return res, fmt.Errorf("failed to restore alias %s: %w", a.Slug, err)
+		default:
+			res.Aliases++
+		}
+	}
+	return res, nil
+}
+
+// read decodes an archive. Nothing is returned unless the whole archive
+// is readable, so a truncated file cannot cause a partial import.
+func read(r io.Reader) (Manifest, []store.Link, []store.Collection, []store.Alias, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, nil, nil, nil, fmt.Errorf("not a golinks archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var m Manifest
+	var links []store.Link
+	var collections []store.Collection
+	var aliases []store.Alias
+	seen := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Manifest{}, nil, nil, nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		var v any
+		switch hdr.Name {
+		case manifestFile:
+			v = &m
+		case linksFile:
+			v = &links
+		case collectionsFile:
+			v = &collections
+		case aliasesFile:
+			v = &aliases
+		default:
+			// Files of newer versions are ignored; the manifest version
+			// says whether they matter
+			continue
+		}
+		if seen[hdr.Name] {
+			return Manifest{}, nil, nil, nil, fmt.Errorf("%s appears twice in archive", hdr.Name)
+		}
+		seen[hdr.Name] = true
+		data, err := io.ReadAll(io.LimitReader(tr, maxEntrySize+1))
+		if err != nil {
+			return Manifest{}, nil, nil, nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		if len(data) > maxEntrySize {
+			return Manifest{}, nil, nil, nil, fmt.Errorf("%s is larger than %d bytes", hdr.Name, maxEntrySize)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return Manifest{}, nil, nil, nil, fmt.Errorf("invalid %s: %w", hdr.Name, err)
+		}
+	}
+
+	for _, name := range []string{manifestFile, linksFile, collectionsFile, aliasesFile} {
+		if !seen[name] {
+			return Manifest{}, nil, nil, nil, fmt.Errorf("archive has no %s", name)
+		}
+	}
+	if m.Version < 1 || m.Version > Version {
+		return Manifest{}, nil, nil, nil, fmt.Errorf("archive version %d is not supported (up to %d); upgrade golinks", m.Version, Version)
+	}
+	if len(links) != m.Links || len(collections) != m.Collections || len(aliases) != m.Aliases {
+		return Manifest{}, nil, nil, nil, fmt.Errorf("archive is incomplete: %d link(s), %d collection(s) and %d alias(es), manifest lists %d, %d and %d",
+			len(links), len(collections), len(aliases), m.Links, m.Collections, m.Aliases)
+	}
+	for i, link := range links {
+		if link.Slug == "" || link.URL == "" {
+			return Manifest{}, nil, nil, nil, fmt.Errorf("link %d of archive has no slug or URL", i+1)
+		}
+	}
+	return m, links, collections, aliases, nil
+}
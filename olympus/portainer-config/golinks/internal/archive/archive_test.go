@@ -0,0 +1,209 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/backup"
+	"golinks/internal/store"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewMemory()
+	used := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	src.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", CreatedBy: "alice"})
+	src.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+	src.SetPin(ctx, "wiki", 2)
+	src.SetAccess(ctx, "wiki", []store.AccessRule{{Group: "kids", Days: "sat", From: "10:00", To: "12:00"}})
+	src.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: used}, {Slug: "wiki", At: used}})
+	src.SaveCollection(ctx, store.Collection{Name: "onboarding", Title: "Day one", Slugs: []string{"pay", "wiki"}})
+	src.SetAlias(ctx, store.Alias{Slug: "w", Target: "wiki"})
+
+	var buf bytes.Buffer
+	m, err := Export(ctx, src, &buf)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if m.Version != Version || m.Links != 2 || m.Collections != 1 || m.Aliases != 1 || m.Clicks != 2 {
+		t.Errorf("manifest = %+v", m)
+	}
+
+	// Into another backend, with one link already there
+	dst, err := store.OpenSQLite(filepath.Join(t.TempDir(), "links.db"), store.SQLiteOptions{QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	dst.AddLink(ctx, store.Link{Slug: "pay", URL: "https://new-pay.example.com"})
+
+	res, err := Import(ctx, dst, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if res.Links != 1 || res.Collections != 1 || res.Aliases != 1 || fmt.Sprint(res.Skipped) != "[pay]" {
+		t.Errorf("result = %+v", res)
+	}
+	want, _ := src.GetLink(ctx, "wiki")
+	if got, err := dst.GetLink(ctx, "wiki"); err != nil || fmt.Sprintf("%+v", *got) != fmt.Sprintf("%+v", *want) {
+		t.Errorf("imported wiki = %+v, %v; want %+v", got, err, want)
+	}
+	if got, _ := dst.GetLink(ctx, "pay"); got.URL != "https://new-pay.example.com" {
+		t.Errorf("existing link was replaced: %+v", got)
+	}
+	if c, err := dst.GetCollection(ctx, "onboarding"); err != nil || c.Title != "Day one" || fmt.Sprint(c.Slugs) != "[pay wiki]" {
+		t.Errorf("imported collection = %+v, %v", c, err)
+	}
+	if target, err := dst.ResolveAlias(ctx, "w"); err != nil || target != "wiki" {
+		t.Errorf("imported alias w = %q, %v", target, err)
+	}
+
+	// Importing again changes nothing
+	res, err = Import(ctx, dst, bytes.NewReader(buf.Bytes()))
+	if err != nil || res.Links != 0 || res.Collections != 0 || res.Aliases != 0 || len(res.Skipped) != 4 {
+		t.Errorf("second import = %+v, %v", res, err)
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if _, err := Export(ctx, store.NewMemory(), &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	res, err := Import(ctx, store.NewMemory(), &buf)
+	if err != nil || res.Manifest.Links != 0 || res.Links != 0 {
+		t.Errorf("import of empty archive = %+v, %v", res, err)
+	}
+}
+
+// build writes an archive with the given files.
+func build(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data))})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestImportRejects(t *testing.T) {
+	ctx := context.Background()
+	link := `[{"slug": "wiki", "url": "https://wiki.example.com"}]`
+	tests := []struct {
+		name    string
+		archive []byte
+		wantErr string
+	}{
+		{"not gzip", []byte("slug,url\n"), "not a golinks archive"},
+		{"no links", build(t, map[string]string{manifestFile: `{"version": 1}`, collectionsFile: `[]`, aliasesFile: `[]`}), "no links.json"},
+		{"newer version", build(t, map[string]string{manifestFile: `{"version": 2, "links": 1}`, linksFile: link, collectionsFile: `[]`, aliasesFile: `[]`}), "version 2"},
+		{"missing links", build(t, map[string]string{manifestFile: `{"version": 1, "links": 2}`, linksFile: link, collectionsFile: `[]`, aliasesFile: `[]`}), "incomplete"},
+		{"no url", build(t, map[string]string{manifestFile: `{"version": 1, "links": 1}`, linksFile: `[{"slug": "wiki"}]`, collectionsFile: `[]`, aliasesFile: `[]`}), "no slug or URL"},
+	}
+	for _, tt := range tests {
+		st := store.NewMemory()
+		_, err := Import(ctx, st, bytes.NewReader(tt.archive))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if links, _ := st.ListLinks(ctx); len(links) != 0 {
+			t.Errorf("%s: imported %d link(s)", tt.name, len(links))
+		}
+	}
+}
+
+func TestLoadAndRestore(t *testing.T) {
+	ctx := context.Background()
+	src, err := store.OpenSQLite(filepath.Join(t.TempDir(), "links.db"), store.SQLiteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	src.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	src.AddLink(ctx, store.Link{Slug: "mail", URL: "https://mail.example.com"})
+	src.SaveCollection(ctx, store.Collection{Name: "tools", Slugs: []string{"mail", "wiki"}})
+	src.SetAlias(ctx, store.Alias{Slug: "email", Target: "mail"})
+
+	var snapshot, arch bytes.Buffer
+	if err := src.Backup(ctx, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Export(ctx, src, &arch); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := backup.Encrypt(snapshot.Bytes(), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bolt, err := store.OpenBolt(filepath.Join(t.TempDir(), "links.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+	for _, link := range []string{"wiki", "mail"} {
+		bolt.AddLink(ctx, store.Link{Slug: link, URL: "https://" + link + ".example.com"})
+	}
+	bolt.SaveCollection(ctx, store.Collection{Name: "tools", Slugs: []string{"mail", "wiki"}})
+	bolt.SetAlias(ctx, store.Alias{Slug: "email", Target: "mail"})
+	var boltSnapshot bytes.Buffer
+	if err := bolt.Backup(ctx, &boltSnapshot); err != nil {
+		t.Fatal(err)
+	}
+	export := `{"exported_at": "2026-10-16T12:00:00Z", "links": [{"slug": "wiki", "url": "https://wiki.example.com"}, {"slug": "mail", "url": "https://mail.example.com"}], "collections": [{"name": "tools", "slugs": ["mail", "wiki"]}], "aliases": [{"slug": "email", "target": "mail"}]}`
+
+	for _, tt := range []struct {
+		name, format string
+		data         []byte
+	}{
+		{"archive", FormatArchive, arch.Bytes()},
+		{"snapshot", FormatSQLite, snapshot.Bytes()},
+		{"encrypted", FormatSQLite, sealed},
+		{"bolt", FormatBolt, boltSnapshot.Bytes()},
+		{"export", FormatJSON, []byte(export)},
+	} {
+		c, err := Load(ctx, tt.data, "passphrase")
+		if err != nil {
+			t.Errorf("Load %s: %v", tt.name, err)
+			continue
+		}
+		if c.Format != tt.format || c.Encrypted != (tt.name == "encrypted") || len(c.Links) != 2 || len(c.Collections) != 1 || len(c.Aliases) != 1 {
+			t.Errorf("Load %s = %s (encrypted %v), %d link(s), %d collection(s), %d alias(es)", tt.name, c.Format, c.Encrypted, len(c.Links), len(c.Collections), len(c.Aliases))
+		}
+	}
+	if _, err := Load(ctx, sealed, ""); err == nil {
+		t.Error("Load of an encrypted backup without a passphrase succeeded")
+	}
+	if _, err := Load(ctx, []byte("slug,url\n"), ""); err == nil {
+		t.Error("Load of a CSV succeeded")
+	}
+
+	c, _ := Load(ctx, snapshot.Bytes(), "")
+	dst := store.NewMemory()
+	dst.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://old-wiki.example.com"})
+	dst.AddLink(ctx, store.Link{Slug: "stale", URL: "https://stale.example.com"})
+	res, err := Restore(ctx, dst, c, false)
+	if err != nil || res.Links != 1 || res.Aliases != 1 || fmt.Sprint(res.Skipped) != "[wiki]" {
+		t.Errorf("merging Restore = %+v, %v", res, err)
+	}
+	res, err = Restore(ctx, dst, c, true)
+	if err != nil || res.Removed != 3 || res.Links != 2 || res.Collections != 1 || res.Aliases != 1 {
+		t.Errorf("replacing Restore = %+v, %v", res, err)
+	}
+	wiki, _ := dst.GetLink(ctx, "wiki")
+	if _, err := dst.GetLink(ctx, "stale"); err == nil || wiki == nil || wiki.URL != "https://wiki.example.com" {
+		t.Errorf("after replacing, wiki = %+v and stale is still there: %v", wiki, err == nil)
+	}
+}
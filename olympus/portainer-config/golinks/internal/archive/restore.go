@@ -0,0 +1,159 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golinks/internal/backup"
+	"golinks/internal/store"
+)
+
+// Formats of the backups Load reads.
+const (
+	FormatArchive   = "archive"
+	FormatSQLite    = "sqlite"
+	FormatBolt      = "bolt"
+	FormatEncrypted = "encrypted"
+	FormatJSON      = "json"
+)
+
+// sqliteHeader starts every SQLite database file.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// Contents are the links, collections and aliases of a backup.
+type Contents struct {
+	// Format is the kind of backup they were read from; an encrypted
+	// backup reports the format inside it.
+	Format      string
+	Encrypted   bool
+	Links       []store.Link
+	Collections []store.Collection
+	Aliases     []store.Alias
+}
+
+// Load reads a backup of any kind golinks writes: an archive of "golinks
+// export", a database snapshot of /admin/backup, an encrypted snapshot of
+// the scheduled backups, which passphrase opens, or the JSON of
+// /admin/export.
+func Load(ctx context.Context, data []byte, passphrase string) (Contents, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		_, links, collections, aliases, err := read(bytes.NewReader(data))
+		if err != nil {
+			return Contents{}, err
+		}
+		return Contents{Format: FormatArchive, Links: links, Collections: collections, Aliases: aliases}, nil
+	case bytes.HasPrefix(data, sqliteHeader):
+		return loadSnapshot(ctx, data, FormatSQLite)
+	case store.IsBoltFile(data):
+		return loadSnapshot(ctx, data, FormatBolt)
+	case backup.IsEncrypted(data):
+		if passphrase == "" {
+			return Contents{}, fmt.Errorf("the backup is encrypted; a passphrase is needed")
+		}
+		plain, err := backup.Decrypt(data, passphrase)
+		if err != nil {
+			return Contents{}, err
+		}
+		if backup.IsEncrypted(plain) {
+			return Contents{}, fmt.Errorf("encrypted backup holds another encrypted backup")
+		}
+		c, err := Load(ctx, plain, "")
+		c.Encrypted = true
+		return c, err
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		var export struct {
+			ExportedAt  time.Time          `json:"exported_at"`
+			Links       []store.Link       `json:"links"`
+			Collections []store.Collection `json:"collections"`
+			Aliases     []store.Alias      `json:"aliases"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			return Contents{}, fmt.Errorf("invalid JSON export: %w", err)
+		}
+		if export.Links == nil || export.ExportedAt.IsZero() {
+			return Contents{}, errors.New("not a golinks JSON export")
+		}
+		return Contents{Format: FormatJSON, Links: export.Links, Collections: export.Collections, Aliases: export.Aliases}, nil
+	}
+	return Contents{}, errors.New("not a golinks archive, database, encrypted backup or JSON export")
+}
+
+// loadSnapshot reads the links of a database snapshot of format. The
+// snapshot is opened from a temporary copy, which upgrades an older schema
+// on the way.
+func loadSnapshot(ctx context.Context, data []byte, format string) (Contents, error) {
+	dir, err := os.MkdirTemp("", "golinks-restore-")
+	if err != nil {
+		return Contents{}, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "links.db")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return Contents{}, err
+	}
+	var st store.Store
+	if format == FormatBolt {
+		st, err = store.OpenBolt(path)
+	} else {
+		st, err = store.OpenSQLite(path, store.SQLiteOptions{})
+	}
+	if err != nil {
+		return Contents{}, fmt.Errorf("invalid database snapshot: %w", err)
+	}
+	defer st.Close()
+
+	c := Contents{Format: format}
+	err = st.EachLink(ctx, func(link store.Link) error {
+		c.Links = append(c.Links, link)
+		return nil
+	})
+	if err != nil {
+		return Contents{}, fmt.Errorf("failed to read links of the snapshot: %w", err)
+	}
+	if c.Collections, err = st.ListCollections(ctx); err != nil {
+		return Contents{}, fmt.Errorf("failed to read collections of the snapshot: %w", err)
+	}
+	if c.Aliases, err = st.ListAliases(ctx); err != nil {
+		return Contents{}, fmt.Errorf("failed to read aliases of the snapshot: %w", err)
+	}
+	return c, nil
+}
+
+// Restore adds the contents of a backup to st like Import does. With
+// replace, the links and collections of st are removed first, their
+// aliases going with the links, so st ends up as the backup was; Removed
+// counts the links that went.
+func Restore(ctx context.Context, st store.Store, c Contents, replace bool) (Result, error) {
+	var res Result
+	if replace {
+		collections, err := st.ListCollections(ctx)
+		if err != nil {
+			return res, err
+		}
+		for _, col := range collections {
+			if err := st.RemoveCollection(ctx, col.Name); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return res, fmt.Errorf("failed to remove collection %s: %w", col.Name, err)
+			}
+		}
+		links, err := st.ListLinks(ctx)
+		if err != nil {
+			return res, err
+		}
+		for _, link := range links {
+			if err := st.RemoveLink(ctx, link.Slug); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return res, fmt.Errorf("failed to remove link %s: %w", link.Slug, err)
+			}
+			res.Removed++
+		}
+	}
+	added, err := add(ctx, st, c.Links, c.Collections, c.Aliases)
+	added.Removed = res.Removed
+	return added, err
+}
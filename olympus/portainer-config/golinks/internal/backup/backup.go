@@ -0,0 +1,184 @@
+// Package backup uploads encrypted snapshots of the database to an
+// S3-compatible bucket on a schedule and prunes old ones, so the links
+// survive the loss of the disk they live on.
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds one request to the bucket.
+const defaultTimeout = 5 * time.Minute
+
+// Suffix ends the key of every backup.
+const Suffix = ".db.enc"
+
+// ErrNoBackups is returned by Latest when the bucket holds no backups.
+var ErrNoBackups = errors.New("no backups in the bucket")
+
+// Config names the bucket, how to log in to it and how many backups to
+// keep.
+type Config struct {
+	// Endpoint is the base URL of the S3 API, such as
+	// https://s3.eu-central-1.amazonaws.com or http://minio:9000.
+	Endpoint string
+	Bucket   string
+	// Region signs requests; MinIO accepts the default us-east-1.
+	Region               string
+	AccessKey, SecretKey string
+	// Prefix is put before the name of every backup, such as "golinks/".
+	Prefix string
+	// Passphrase encrypts the backups. It is needed to restore them.
+	Passphrase string
+	// Interval is how often a backup is taken.
+	Interval time.Duration
+	// Keep is how many of the newest backups are kept; 0 keeps all.
+	Keep int
+	// Client talks to the bucket; one with a five-minute timeout if nil.
+	Client *http.Client
+}
+
+// Enabled reports whether a bucket is configured.
+func (c Config) Enabled() bool {
+	return c.Bucket != ""
+}
+
+// Source writes a consistent snapshot of the database, such as
+// *store.SQLite.
+type Source interface {
+	Backup(ctx context.Context, w io.Writer) error
+}
+
+// Uploader takes backups of a Source into the bucket.
+type Uploader struct {
+	cfg Config
+	src Source
+	s3  *s3Client
+}
+
+// New returns an Uploader backing up src to the bucket of cfg.
+func New(cfg Config, src Source) *Uploader {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &Uploader{cfg: cfg, src: src, s3: &s3Client{
+		endpoint:  cfg.Endpoint,
+		bucket:    cfg.Bucket,
+		region:    cfg.Region,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		client:    cfg.Client,
+		now:       time.Now,
+	}}
+}
+
+// Run takes a backup, uploads it encrypted and then deletes the backups
+// beyond the newest Keep. A failed upload leaves the old backups alone.
+func (u *Uploader) Run(ctx context.Context) error {
+	// The backup is encrypted into a temporary file as it is taken, so
+	// only a chunk of the database is ever in memory. The upload is signed
+	// with its hash, which is summed on the way.
+	tmp, err := os.CreateTemp("", "golinks-backup-*"+Suffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	hash := sha256.New()
+	w, err := NewWriter(io.MultiWriter(tmp, hash), u.cfg.Passphrase)
+	if err != nil {
+		return err
+	}
+	if err := u.src.Backup(ctx, w); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	key := u.cfg.Prefix + "golinks-" + u.s3.now().UTC().Format("20060102T150405Z") + Suffix
+	if err := u.s3.put(ctx, key, io.NewSectionReader(tmp, 0, size), size, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return fmt.Errorf("upload backup: %w", err)
+	}
+	slog.Info("Backed up database", "bucket", u.cfg.Bucket, "key", key, "bytes", size)
+	return u.prune(ctx)
+}
+
+// List returns the backups in the bucket, oldest first.
+func (u *Uploader) List(ctx context.Context) ([]Object, error) {
+	objects, err := u.s3.list(ctx, u.cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	backups := objects[:0]
+	for _, o := range objects {
+		// Only names of the form Run uploads, so other files under the
+		// prefix are never pruned
+		name := strings.TrimPrefix(o.Key, u.cfg.Prefix)
+		if strings.HasPrefix(name, "golinks-") && strings.HasSuffix(name, Suffix) && !strings.Contains(name, "/") {
+			backups = append(backups, o)
+		}
+	}
+	// The timestamps in the names sort by time
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Key < backups[j].Key })
+	return backups, nil
+}
+
+// Latest downloads and decrypts the newest backup, or returns
+// ErrNoBackups.
+func (u *Uploader) Latest(ctx context.Context) (Object, []byte, error) {
+	backups, err := u.List(ctx)
+	if err != nil {
+		return Object{}, nil, err
+	}
+	if len(backups) == 0 {
+		return Object{}, nil, ErrNoBackups
+	}
+	latest := backups[len(backups)-1]
+	sealed, err := u.s3.get(ctx, latest.Key)
+	if err != nil {
+		return Object{}, nil, err
+	}
+	plain, err := Decrypt(sealed, u.cfg.Passphrase)
+	if err != nil {
+		return Object{}, nil, fmt.Errorf("%s: %w", latest.Key, err)
+	}
+	return latest, plain, nil
+}
+
+// prune deletes all but the newest Keep backups.
+func (u *Uploader) prune(ctx context.Context) error {
+	if u.cfg.Keep <= 0 {
+		return nil
+	}
+	backups, err := u.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list backups: %w", err)
+	}
+	for len(backups) > u.cfg.Keep {
+		if err := u.s3.remove(ctx, backups[0].Key); err != nil {
+			return fmt.Errorf("delete old backup: %w", err)
+		}
+		slog.Info("Deleted old backup", "bucket", u.cfg.Bucket, "key", backups[0].Key)
+		backups = backups[1:]
+	}
+	return nil
+}
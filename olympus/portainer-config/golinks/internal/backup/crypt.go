@@ -0,0 +1,249 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// magic starts every encrypted backup, followed by the scrypt salt, a nonce
+// prefix and the database sealed in chunks of chunkSize with AES-GCM. The
+// nonce of a chunk is the prefix, the chunk's number and a byte marking the
+// last chunk, so chunks cannot be reordered, dropped or cut off at the end.
+var magic = []byte("golinks-backup-v2\n")
+
+const (
+	saltSize   = 16
+	prefixSize = 7
+	chunkSize  = 64 << 10
+)
+
+// ErrPassphrase is returned by Decrypt when the passphrase is wrong or the
+// backup was tampered with.
+var ErrPassphrase = errors.New("wrong passphrase or corrupted backup")
+
+var errTruncated = errors.New("encrypted backup is truncated")
+
+// deriveKey turns a passphrase into an AES-256 key.
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce returns the nonce of chunk n.
+func chunkNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, 0, prefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, n)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// Writer encrypts a backup as it is written, holding one chunk at a time.
+type Writer struct {
+	w      io.Writer
+	gcm    cipher.AEAD
+	header []byte
+	prefix []byte
+	buf    []byte
+	n      uint32
+	err    error
+}
+
+// NewWriter returns a Writer sealing what is written to it into w, with a
+// key derived from passphrase. Close must be called to write the last
+// chunk.
+func NewWriter(w io.Writer, passphrase string) (*Writer, error) {
+	header := make([]byte, len(magic)+saltSize+prefixSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, header[len(magic):len(magic)+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:      w,
+		gcm:    gcm,
+		header: header,
+		prefix: header[len(magic)+saltSize:],
+		buf:    make([]byte, 0, chunkSize+gcm.Overhead()),
+	}, nil
+}
+
+func (e *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 && e.err == nil {
+		// A full chunk is only sealed once more follows, as the last one
+		// is marked
+		if len(e.buf) == chunkSize {
+			e.seal(false)
+			continue
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, e.err
+}
+
+// Close seals the last chunk. It does not close the underlying writer.
+func (e *Writer) Close() error {
+	if e.err == nil {
+		e.seal(true)
+		if e.err == nil {
+			e.err = errors.New("backup writer is closed")
+			return nil
+		}
+	}
+	return e.err
+}
+
+func (e *Writer) seal(last bool) {
+	// The header is authenticated too, so it cannot be swapped
+	sealed := e.gcm.Seal(e.buf[:0], chunkNonce(e.prefix, e.n, last), e.buf, e.header)
+	if _, err := e.w.Write(sealed); err != nil {
+		e.err = err
+	}
+	e.n++
+	e.buf = e.buf[:0]
+}
+
+// NewReader returns a reader of the backup sealed in r with passphrase.
+// Reads fail with ErrPassphrase once a chunk does not open, so what was
+// read before must be thrown away then.
+func NewReader(r io.Reader, passphrase string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+saltSize+prefixSize)
+	_, err := io.ReadFull(br, header)
+	switch {
+	case !bytes.HasPrefix(header, magic):
+		return nil, fmt.Errorf("not an encrypted golinks backup")
+	case err != nil:
+		return nil, errTruncated
+	}
+	gcm, err := newGCM(passphrase, header[len(magic):len(magic)+saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &reader{
+		r:      br,
+		gcm:    gcm,
+		header: header,
+		prefix: header[len(magic)+saltSize:],
+		buf:    make([]byte, chunkSize+gcm.Overhead()),
+	}, nil
+}
+
+type reader struct {
+	r      *bufio.Reader
+	gcm    cipher.AEAD
+	header []byte
+	prefix []byte
+	buf    []byte
+	plain  []byte
+	n      uint32
+	done   bool
+	err    error
+}
+
+func (d *reader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 && d.err == nil {
+		if d.done {
+			d.err = io.EOF
+			break
+		}
+		d.open()
+	}
+	if len(d.plain) > 0 {
+		n := copy(p, d.plain)
+		d.plain = d.plain[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// open reads and opens the next chunk, which is the last if nothing
+// follows it.
+func (d *reader) open() {
+	n, err := io.ReadFull(d.r, d.buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		d.err = err
+		return
+	}
+	if n < d.gcm.Overhead() {
+		d.err = errTruncated
+		return
+	}
+	_, err = d.r.Peek(1)
+	last := err == io.EOF
+	plain, err := d.gcm.Open(d.buf[:0], chunkNonce(d.prefix, d.n, last), d.buf[:n], d.header)
+	if err != nil {
+		d.err = ErrPassphrase
+		return
+	}
+	d.plain, d.done = plain, last
+	d.n++
+}
+
+// Encrypt seals a backup with a key derived from passphrase.
+func Encrypt(plain []byte, passphrase string) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plain); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsEncrypted reports whether data looks like a backup sealed by Encrypt.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Decrypt opens a backup sealed by Encrypt.
+func Decrypt(sealed []byte, passphrase string) ([]byte, error) {
+	if !IsEncrypted(sealed) {
+		return nil, fmt.Errorf("not an encrypted golinks backup")
+	}
+	r, err := NewReader(bytes.NewReader(sealed), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
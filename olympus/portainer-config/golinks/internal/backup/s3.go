@@ -0,0 +1,204 @@
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Object is a stored backup.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// s3Client talks to an S3-compatible bucket with path-style requests, which
+// both AWS and MinIO accept, signed with AWS Signature Version 4.
+type s3Client struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// put uploads the size bytes of body, whose SHA-256 is payloadHash in hex,
+// as key.
+func (c *s3Client) put(ctx context.Context, key string, body io.Reader, size int64, payloadHash string) error {
+	resp, err := c.send(ctx, http.MethodPut, key, nil, body, size, payloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// get downloads key.
+func (c *s3Client) get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// remove deletes key.
+func (c *s3Client) remove(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// list returns the objects whose key starts with prefix, in key order.
+func (c *s3Client) list(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(ctx, http.MethodGet, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("bad bucket listing: %w", err)
+		}
+		for _, o := range page.Contents {
+			objects = append(objects, Object{Key: o.Key, Size: o.Size, LastModified: o.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// emptyHash is the SHA-256 of an empty body.
+var emptyHash = hashPayload(nil)
+
+func hashPayload(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// do sends a signed request without a body for key, or for the bucket if
+// key is empty, and returns the response if it succeeded.
+func (c *s3Client) do(ctx context.Context, method, key string, query url.Values) (*http.Response, error) {
+	return c.send(ctx, method, key, query, nil, 0, emptyHash)
+}
+
+// send is do with the size bytes of body, whose SHA-256 is payloadHash.
+func (c *s3Client) send(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u := strings.TrimSuffix(c.endpoint, "/") + "/" + c.bucket
+	if key != "" {
+		u += "/" + key
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	// S3 refuses uploads of unknown length
+	req.ContentLength = size
+	req.URL.RawQuery = canonicalQuery(query)
+	sign(req, payloadHash, c.accessKey, c.secretKey, c.region, c.now())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var s3err struct{ Code, Message string }
+		if xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3err) == nil && s3err.Code != "" {
+			return nil, fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, s3err.Code, s3err.Message)
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, req.URL.Path, resp.Status)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 headers for a request whose body
+// has the SHA-256 payloadHash.
+func sign(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s", accessKey, scope, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key, with spaces as %20 as
+// Signature Version 4 requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,115 @@
+// Package banner keeps the announcement admins show at the top of the list
+// and 404 pages, such as "wiki migration this weekend — expect broken
+// links".
+package banner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Banner is one announcement.
+type Banner struct {
+	Text string `json:"text"`
+	// Link, if set, is where the banner leads for details.
+	Link string `json:"link,omitempty"`
+	// Expires, if set, is when the banner stops being shown.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Active reports whether the banner is shown at now.
+func (b Banner) Active(now time.Time) bool {
+	return b.Text != "" && (b.Expires == nil || now.Before(*b.Expires))
+}
+
+// Board holds the current banner and keeps it in a file, so it survives
+// restarts. It is safe for concurrent use.
+type Board struct {
+	path string
+
+	mu     sync.RWMutex
+	banner Banner
+}
+
+// Open loads the banner kept at path; a missing file means there is none.
+// With an empty path the banner is only kept in memory.
+func Open(path string) (*Board, error) {
+	b := &Board{path: path}
+	if path == "" {
+		return b, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &b.banner); err != nil {
+		return nil, fmt.Errorf("reading banner %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Get returns the banner as set, even if it has expired.
+func (b *Board) Get() Banner {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.banner
+}
+
+// Current returns the banner to show at now, or nil if there is none or it
+// has expired.
+func (b *Board) Current(now time.Time) *Banner {
+	banner := b.Get()
+	if !banner.Active(now) {
+		return nil
+	}
+	return &banner
+}
+
+// Set replaces the banner; a banner without text removes it. The file is
+// replaced atomically, so a crash leaves either the old or the new banner.
+func (b *Board) Set(banner Banner) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.path != "" {
+		if err := b.save(banner); err != nil {
+			return err
+		}
+	}
+	b.banner = banner
+	return nil
+}
+
+func (b *Board) save(banner Banner) error {
+	if banner.Text == "" {
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(banner)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), ".banner-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.path)
+}
@@ -0,0 +1,58 @@
+package banner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banner.json")
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if got := b.Current(now); got != nil {
+		t.Fatalf("Current without a banner = %+v", got)
+	}
+
+	expires := now.Add(time.Hour)
+	if err := b.Set(Banner{Text: "Wiki migration this weekend", Link: "https://wiki.example.com/migration", Expires: &expires}); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Current(now); got == nil || got.Text != "Wiki migration this weekend" {
+		t.Errorf("Current = %+v", got)
+	}
+	if got := b.Current(expires); got != nil {
+		t.Errorf("Current after expiry = %+v", got)
+	}
+
+	// The banner survives a restart
+	b, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Get(); got.Link != "https://wiki.example.com/migration" || got.Expires == nil || !got.Expires.Equal(expires) {
+		t.Errorf("reopened banner = %+v", got)
+	}
+
+	if err := b.Set(Banner{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("banner file left after clearing: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 0 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+}
+
+func TestOpenCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banner.json")
+	os.WriteFile(path, []byte("{"), 0o644)
+	if _, err := Open(path); err == nil {
+		t.Error("corrupt banner file accepted")
+	}
+}
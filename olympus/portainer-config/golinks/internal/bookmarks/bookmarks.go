@@ -0,0 +1,175 @@
+// Package bookmarks reads and writes the Netscape bookmark file format that
+// browsers import and export bookmarks in.
+package bookmarks
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bookmark is one bookmark of a file.
+type Bookmark struct {
+	Title string
+	URL   string
+	// Folders are the folders the bookmark is in, outermost first.
+	Folders []string
+	// AddDate is when the bookmark was added, zero if unknown.
+	AddDate time.Time
+	// Line is the bookmark's line in the file, counting from 1.
+	Line int
+}
+
+var (
+	// tag matches the tags that give a bookmark file its structure.
+	tag = regexp.MustCompile(`(?i)<(/?)(dl|h3|a)\b([^>]*)>`)
+	// attr matches one quoted attribute.
+	attr = regexp.MustCompile(`(?i)\b([a-z_]+)\s*=\s*"([^"]*)"`)
+)
+
+// Parse returns the http and https bookmarks of a bookmark file, in file
+// order. Bookmarklets and browser-internal places are left out.
+func Parse(r io.Reader) ([]Bookmark, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := string(data)
+	if !strings.Contains(strings.ToUpper(doc[:min(len(doc), 1024)]), "NETSCAPE-BOOKMARK-FILE") && !tag.MatchString(doc) {
+		return nil, fmt.Errorf("not a bookmark file")
+	}
+
+	var bookmarks []Bookmark
+	// folders is the stack of open lists; a list not opened by a folder
+	// heading, such as the outermost one, has no name
+	var folders []string
+	heading := ""
+	line, pos := 1, 0
+	for _, m := range tag.FindAllStringSubmatchIndex(doc, -1) {
+		line += strings.Count(doc[pos:m[0]], "\n")
+		pos = m[0]
+		closing, name, attrs := doc[m[2]:m[3]] == "/", strings.ToLower(doc[m[4]:m[5]]), doc[m[6]:m[7]]
+		switch {
+		case name == "dl" && !closing:
+			folders = append(folders, heading)
+			heading = ""
+		case name == "dl" && closing:
+			if len(folders) > 0 {
+				folders = folders[:len(folders)-1]
+			}
+		case name == "h3" && !closing:
+			heading = text(doc[m[1]:])
+		case name == "a" && !closing:
+			b := Bookmark{Title: text(doc[m[1]:]), Line: line}
+			for _, a := range attr.FindAllStringSubmatch(attrs, -1) {
+				switch strings.ToLower(a[1]) {
+				case "href":
+					b.URL = strings.TrimSpace(html.UnescapeString(a[2]))
+				case "add_date":
+					if secs, err := strconv.ParseInt(a[2], 10, 64); err == nil && secs > 0 {
+						b.AddDate = time.Unix(secs, 0).UTC()
+					}
+				}
+			}
+			lower := strings.ToLower(b.URL)
+			if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+				continue
+			}
+			for _, f := range folders {
+				if f != "" {
+					b.Folders = append(b.Folders, f)
+				}
+			}
+			bookmarks = append(bookmarks, b)
+		}
+	}
+	return bookmarks, nil
+}
+
+// text returns the text up to the next tag, unescaped and trimmed.
+func text(s string) string {
+	if i := strings.IndexByte(s, '<'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// Write writes bookmarks as a bookmark file titled title, nesting them in
+// their folders.
+func Write(w io.Writer, title string, bookmarks []Bookmark) error {
+	bw := NewWriter(w, title)
+	for _, b := range bookmarks {
+		if err := bw.Add(b); err != nil {
+			return err
+		}
+	}
+	return bw.Close()
+}
+
+// Writer writes a bookmark file one bookmark at a time. A folder is
+// written once if its bookmarks, and those of its subfolders, are added
+// one after another.
+type Writer struct {
+	bw   *bufio.Writer
+	open []string
+}
+
+// NewWriter starts a bookmark file titled title on w. Close must be called
+// to end it.
+func NewWriter(w io.Writer, title string) *Writer {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	fmt.Fprintf(bw, "<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n")
+	fmt.Fprintf(bw, "<TITLE>%s</TITLE>\n<H1>%s</H1>\n", html.EscapeString(title), html.EscapeString(title))
+	fmt.Fprintf(bw, "<DL><p>\n")
+	return &Writer{bw: bw}
+}
+
+// Add writes b in its folders. Writes are buffered, so an error may show
+// up a few bookmarks late.
+func (w *Writer) Add(b Bookmark) error {
+	// Close the folders b is not in, then open the ones it is
+	common := 0
+	for common < len(w.open) && common < len(b.Folders) && w.open[common] == b.Folders[common] {
+		common++
+	}
+	for len(w.open) > common {
+		w.open = w.open[:len(w.open)-1]
+		fmt.Fprintf(w.bw, "%s</DL><p>\n", indent(len(w.open)+1))
+	}
+	for _, f := range b.Folders[common:] {
+		fmt.Fprintf(w.bw, "%s<DT><H3>%s</H3>\n%s<DL><p>\n", indent(len(w.open)+1), html.EscapeString(f), indent(len(w.open)+1))
+		w.open = append(w.open, f)
+	}
+	fmt.Fprintf(w.bw, "%s<DT><A HREF=\"%s\"", indent(len(w.open)+1), html.EscapeString(b.URL))
+	if !b.AddDate.IsZero() {
+		fmt.Fprintf(w.bw, " ADD_DATE=\"%d\"", b.AddDate.Unix())
+	}
+	_, err := fmt.Fprintf(w.bw, ">%s</A>\n", html.EscapeString(b.Title))
+	return err
+}
+
+// Flush writes the buffered bookmarks to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.bw.Flush()
+}
+
+// Close closes the open folders and ends the file. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	for len(w.open) > 0 {
+		w.open = w.open[:len(w.open)-1]
+		fmt.Fprintf(w.bw, "%s</DL><p>\n", indent(len(w.open)+1))
+	}
+	fmt.Fprintf(w.bw, "</DL><p>\n")
+	return w.bw.Flush()
+}
+
+func indent(depth int) string {
+	return strings.Repeat("    ", depth)
+}
@@ -0,0 +1,75 @@
+package bookmarks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chrome is a bookmarks file as Chrome exports it, trimmed.
+const chrome = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><A HREF="https://wiki.example.com/" ADD_DATE="1700000001" ICON="data:image/png;base64,AAA">Team Wiki</A>
+        <DT><H3>Work &amp; HR</H3>
+        <DL><p>
+            <DT><A HREF="https://hr.example.com/?a=1&amp;b=2">HR portal</A>
+            <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+        </DL><p>
+        <DT><A HREF="https://news.example.com/">News</A>
+    </DL><p>
+    <DT><A HREF="http://other.example.com/">Other</A>
+</DL><p>
+`
+
+func TestParse(t *testing.T) {
+	got, err := Parse(strings.NewReader(chrome))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bookmark{
+		{Title: "Team Wiki", URL: "https://wiki.example.com/", Folders: []string{"Bookmarks bar"}, AddDate: time.Unix(1700000001, 0).UTC(), Line: 11},
+		{Title: "HR portal", URL: "https://hr.example.com/?a=1&b=2", Folders: []string{"Bookmarks bar", "Work & HR"}, Line: 14},
+		{Title: "News", URL: "https://news.example.com/", Folders: []string{"Bookmarks bar"}, Line: 17},
+		{Title: "Other", URL: "http://other.example.com/", Line: 19},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if _, err := Parse(strings.NewReader("slug,url\nwiki,https://wiki.example.com\n")); err == nil {
+		t.Error("Parse of a CSV succeeded")
+	}
+}
+
+func TestWriteParse(t *testing.T) {
+	marks := []Bookmark{
+		{Title: "go/top", URL: "https://top.example.com/"},
+		{Title: "go/wiki", URL: "https://wiki.example.com/?a=1&b=2", Folders: []string{"Go Links"}, AddDate: time.Unix(1700000000, 0).UTC()},
+		{Title: "<b>", URL: "https://deep.example.com/", Folders: []string{"Go Links", "Docs"}},
+		{Title: "go/hr", URL: "https://hr.example.com/", Folders: []string{"Go Links"}},
+		{Title: "go/other", URL: "https://other.example.com/", Folders: []string{"Other"}},
+	}
+	var b strings.Builder
+	if err := Write(&b, "Bookmarks", marks); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		got[i].Line = 0
+	}
+	if !reflect.DeepEqual(got, marks) {
+		t.Errorf("Parse(Write) =\n%+v\nwant\n%+v\n%s", got, marks, b.String())
+	}
+}
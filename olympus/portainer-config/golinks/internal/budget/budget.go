@@ -0,0 +1,97 @@
+// Package budget alerts when a link gets more hits in a day than its hit
+// budget allows, e.g. a go/guest-wifi link that leaked beyond the house.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"golinks/internal/notify"
+	"golinks/internal/store"
+)
+
+// Config selects where alerts are delivered. With none set, alerts are
+// only logged.
+type Config struct {
+	// WebhookURL receives a JSON POST per alert with the message as "text"
+	// (Slack and Mattermost compatible) and the link as "link".
+	WebhookURL string
+	// NtfyURL is an ntfy topic URL, such as https://ntfy.sh/my-topic.
+	NtfyURL string
+	// Notifier, if set, receives every alert too.
+	Notifier notify.Notifier
+}
+
+// Watcher counts the hits of links with a budget per local calendar day
+// and alerts once per link and day when the budget is exceeded. Counts live
+// in memory and start from zero when the process restarts.
+type Watcher struct {
+	notifier notify.Multi
+	now      func() time.Time
+
+	mu   sync.Mutex
+	day  string
+	hits map[string]int
+	// wg tracks alerts being delivered.
+	wg sync.WaitGroup
+}
+
+// New creates a Watcher.
+func New(cfg Config) *Watcher {
+	w := &Watcher{now: time.Now, hits: make(map[string]int)}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if cfg.WebhookURL != "" {
+		w.notifier = append(w.notifier, notify.Webhook{URL: cfg.WebhookURL, Client: client})
+	}
+	if cfg.NtfyURL != "" {
+		w.notifier = append(w.notifier, notify.Ntfy{TopicURL: cfg.NtfyURL, Client: client})
+	}
+	if cfg.Notifier != nil {
+		w.notifier = append(w.notifier, cfg.Notifier)
+	}
+	return w
+}
+
+// Hit records a redirect for link. The alert is delivered in the
+// background so the redirect is not held up.
+func (w *Watcher) Hit(link store.Link) {
+	if link.HitBudget <= 0 {
+		return
+	}
+	day := w.now().Format(time.DateOnly)
+
+	w.mu.Lock()
+	if day != w.day {
+		w.day, w.hits = day, make(map[string]int)
+	}
+	w.hits[link.Slug]++
+	hits := w.hits[link.Slug]
+	w.mu.Unlock()
+
+	if hits == link.HitBudget+1 {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			if err := w.alert(context.Background(), link, hits); err != nil {
+				slog.Error("Hit budget alert failed", "slug", link.Slug, "error", err)
+			}
+		}()
+	}
+}
+
+// alert delivers the alert for link to every configured channel.
+func (w *Watcher) alert(ctx context.Context, link store.Link, hits int) error {
+	text := fmt.Sprintf("go/%s has had %d hits today, over its budget of %d a day. If that is unexpected, the link may have leaked.", link.Slug, hits, link.HitBudget)
+	slog.Warn("Hit budget exceeded", "slug", link.Slug, "hits", hits, "budget", link.HitBudget)
+
+	return w.notifier.Notify(ctx, notify.Message{
+		Event: notify.EventBudget,
+		Title: "Hit budget exceeded: go/" + link.Slug,
+		Text:  text,
+		Data:  map[string]any{"link": link},
+	})
+}
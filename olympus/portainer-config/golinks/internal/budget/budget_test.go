@@ -0,0 +1,69 @@
+package budget
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestWatcher(t *testing.T) {
+	var mu sync.Mutex
+	var texts, ntfy []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string     `json:"text"`
+			Link store.Link `json:"link"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		texts = append(texts, payload.Text)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	topic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		ntfy = append(ntfy, r.Header.Get("Title")+": "+string(body))
+		mu.Unlock()
+	}))
+	defer topic.Close()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	w := New(Config{WebhookURL: hook.URL, NtfyURL: topic.URL})
+	w.now = func() time.Time { return now }
+
+	wifi := store.Link{Slug: "guest-wifi", URL: "https://wifi.example.com", HitBudget: 3}
+	for i := 0; i < 10; i++ {
+		w.Hit(wifi)
+		w.Hit(store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	}
+	w.wg.Wait()
+	if len(texts) != 1 || !strings.Contains(texts[0], "go/guest-wifi has had 4 hits today, over its budget of 3") {
+		t.Errorf("webhook alerts = %q, want one for guest-wifi", texts)
+	}
+	if len(ntfy) != 1 || !strings.HasPrefix(ntfy[0], "Hit budget exceeded: go/guest-wifi: ") {
+		t.Errorf("ntfy alerts = %q", ntfy)
+	}
+
+	// Counts start over the next day
+	now = now.Add(24 * time.Hour)
+	for i := 0; i < 3; i++ {
+		w.Hit(wifi)
+	}
+	w.wg.Wait()
+	if len(texts) != 1 {
+		t.Errorf("alerted within budget on the next day: %q", texts)
+	}
+	w.Hit(wifi)
+	w.wg.Wait()
+	if len(texts) != 2 {
+		t.Errorf("no alert on the next day: %q", texts)
+	}
+}
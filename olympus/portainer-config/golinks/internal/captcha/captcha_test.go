@@ -0,0 +1,43 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("secret") != "s3cret" || r.PostFormValue("remoteip") != "192.0.2.7" {
+			t.Errorf("siteverify got %v", r.PostForm)
+		}
+		if r.PostFormValue("response") == "good" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	v := New(Config{Provider: Turnstile, SiteKey: "site", Secret: "s3cret", VerifyURL: srv.URL})
+	if w := v.Widget(); w.Class != "cf-turnstile" || w.Field != "cf-turnstile-response" || w.SiteKey != "site" {
+		t.Errorf("Widget = %+v", w)
+	}
+	if err := v.Verify(ctx, "good", "192.0.2.7"); err != nil {
+		t.Errorf("Verify of a good answer: %v", err)
+	}
+	if err := v.Verify(ctx, "bad", "192.0.2.7"); !errors.Is(err, ErrFailed) {
+		t.Errorf("Verify of a bad answer = %v, want ErrFailed", err)
+	}
+	if err := v.Verify(ctx, "", ""); !errors.Is(err, ErrFailed) {
+		t.Errorf("Verify without an answer = %v, want ErrFailed", err)
+	}
+
+	srv.Close()
+	if err := v.Verify(ctx, "good", ""); err == nil || errors.Is(err, ErrFailed) {
+		t.Errorf("Verify without the provider = %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package clicks records every redirect in the store without holding up
+// the redirect, so unused links can be found and pruned.
+package clicks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"golinks/internal/store"
+)
+
+const (
+	// queueSize bounds the clicks waiting to be written. Clicks arriving
+	// while the queue is full are dropped.
+	queueSize = 4096
+	// batchSize is the most clicks written in one transaction.
+	batchSize = 256
+	// defaultFlushEvery is how long a click waits at most before it is
+	// written, unless SetFlushInterval says otherwise.
+	defaultFlushEvery = time.Second
+)
+
+// PruneEvery is how often clicks past the retention should be deleted.
+const PruneEvery = 24 * time.Hour
+
+// Recorder queues clicks in memory and writes them to the store in
+// batches.
+type Recorder struct {
+	store     store.Store
+	retention time.Duration
+	queue     chan store.Click
+	dropped   atomic.Int64
+	// sampler, if set, leaves out clicks of hot links but the totals.
+	sampler    *Sampler
+	flushEvery time.Duration
+}
+
+// New creates a Recorder for the links in st. Prune deletes clicks older
+// than retention; zero keeps them forever.
+func New(st store.Store, retention time.Duration) *Recorder {
+	return &Recorder{store: st, retention: retention, queue: make(chan store.Click, queueSize), flushEvery: defaultFlushEvery}
+}
+
+// SetFlushInterval makes Run write queued clicks every d, or sooner once a
+// batch is full; zero keeps the default of a second. It must be called
+// before Run.
+func (rec *Recorder) SetFlushInterval(d time.Duration) {
+	if d > 0 {
+		rec.flushEvery = d
+	}
+}
+
+// SetSampler stores only the clicks s keeps, weighted, on their own; the
+// others still count in the totals of their links.
+func (rec *Recorder) SetSampler(s *Sampler) {
+	rec.sampler = s
+}
+
+// Click queues a redirect of slug at at, opened from the list page if
+// fromList. It never blocks: if the queue is full, the click is dropped.
+func (rec *Recorder) Click(slug string, at time.Time, fromList bool) {
+	c := store.Click{Slug: slug, At: at, FromList: fromList}
+	if rec.sampler != nil {
+		c.Weight = rec.sampler.Sample(slug, at)
+		c.Unsampled = c.Weight == 0
+	}
+	select {
+	case rec.queue <- c:
+	default:
+		rec.dropped.Add(1)
+	}
+}
+
+// Run writes queued clicks until ctx is done, then writes the clicks still
+// queued and returns.
+func (rec *Recorder) Run(ctx context.Context) {
+	flush := time.NewTicker(rec.flushEvery)
+	defer flush.Stop()
+
+	var batch []store.Click
+	for {
+		select {
+		case c := <-rec.queue:
+			if batch = append(batch, c); len(batch) >= batchSize {
+				batch = rec.write(context.Background(), batch)
+			}
+		case <-flush.C:
+			batch = rec.write(context.Background(), batch)
+		case <-ctx.Done():
+			for {
+				select {
+				case c := <-rec.queue:
+					batch = append(batch, c)
+				default:
+					rec.write(context.Background(), batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// write stores batch and returns it emptied for reuse. Clicks that fail to
+// be written are dropped rather than retried, so a broken database cannot
+// fill the memory.
+func (rec *Recorder) write(ctx context.Context, batch []store.Click) []store.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+	compact(batch)
+	if err := rec.store.RecordClicks(ctx, batch); err != nil {
+		slog.Error("Failed to record clicks", "clicks", len(batch), "error", err)
+	}
+	if n := rec.dropped.Swap(0); n > 0 {
+		slog.Warn("Dropped clicks while the click queue was full", "clicks", n)
+	}
+	return batch[:0]
+}
+
+// clickKey tells apart the clicks a store keeps apart: single clicks are
+// kept to the second.
+type clickKey struct {
+	slug string
+	at   int64
+}
+
+// compact folds the clicks of a link within the same second into the
+// first of them, weighted by the clicks it stands for, so a burst costs the
+// store one row; the others still count in the link's totals.
+func compact(batch []store.Click) {
+	first := make(map[clickKey]int)
+	for i := range batch {
+		c := &batch[i]
+		if c.Unsampled {
+			continue
+		}
+		key := clickKey{c.Slug, c.At.Unix()}
+		j, ok := first[key]
+		if !ok {
+			first[key] = i
+			continue
+		}
+		batch[j].Weight = max(batch[j].Weight, 1) + max(c.Weight, 1)
+		c.Weight, c.Unsampled = 0, true
+	}
+}
+
+// Prune deletes the clicks past the retention.
+func (rec *Recorder) Prune(ctx context.Context) error {
+	if rec.retention <= 0 {
+		return nil
+	}
+	n, err := rec.store.PruneClicks(ctx, time.Now().Add(-rec.retention))
+	if err != nil {
+		return fmt.Errorf("failed to prune clicks: %w", err)
+	}
+	if n > 0 {
+		slog.Info("Pruned clicks", "clicks", n, "older_than", rec.retention.String())
+	}
+	return nil
+}
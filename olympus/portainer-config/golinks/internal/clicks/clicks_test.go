@@ -0,0 +1,171 @@
+package clicks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestRecorderFlushesOnStop(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	rec := New(st, 0)
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		rec.Run(runCtx)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		rec.Click("wiki", time.Now(), i == 0)
+	}
+	rec.Click("missing", time.Now(), false)
+	stop()
+	<-done
+
+	link, err := st.GetLink(ctx, "wiki")
+	if err != nil || link.Clicks != 3 || link.ListOpens != 1 || link.LastUsedAt == nil {
+		t.Errorf("wiki = %+v, %v; want 3 clicks, 1 from the list", link, err)
+	}
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	rec := &Recorder{store: st, queue: make(chan store.Click, 2)}
+
+	// Nothing drains the queue, so Click must return anyway
+	for i := 0; i < 5; i++ {
+		rec.Click("wiki", time.Now(), false)
+	}
+	if n := rec.dropped.Load(); n != 3 {
+		t.Errorf("dropped = %d, want 3", n)
+	}
+}
+
+func TestRecorderPrunes(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	now := time.Now()
+	st.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: now.AddDate(-2, 0, 0)}, {Slug: "wiki", At: now}})
+
+	if err := New(st, 365*24*time.Hour).Prune(ctx); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := st.ClickCounts(ctx, time.Time{})
+	if err != nil || counts["wiki"] != 1 {
+		t.Errorf("clicks after pruning = %v, %v; want 1", counts, err)
+	}
+	if link, _ := st.GetLink(ctx, "wiki"); link.Clicks != 2 {
+		t.Errorf("link clicks = %d, want 2 (totals are kept)", link.Clicks)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(Sampling{Rate: 3, Hot: 2})
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	var got []int
+	for i := 0; i < 8; i++ {
+		got = append(got, s.Sample("wiki", at))
+	}
+	if fmt.Sprint(got) != "[1 1 0 0 3 0 0 3]" {
+		t.Errorf("Sample = %v", got)
+	}
+	if w := s.Sample("mail", at); w != 1 {
+		t.Errorf("Sample of another link = %d", w)
+	}
+	// Every hour starts over, and a late click doesn't go back
+	if w := s.Sample("wiki", at.Add(time.Hour)); w != 1 {
+		t.Errorf("Sample in the next hour = %d", w)
+	}
+	s.Sample("wiki", at.Add(time.Hour))
+	if w := s.Sample("wiki", at); w != 0 {
+		t.Errorf("Sample of a late click = %d", w)
+	}
+	if w := NewSampler(Sampling{}).Sample("wiki", at); w != 1 {
+		t.Errorf("Sample without sampling = %d", w)
+	}
+}
+
+func TestRecorderSamples(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	rec := New(st, 0)
+	rec.SetSampler(NewSampler(Sampling{Rate: 10}))
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		rec.Run(runCtx)
+		close(done)
+	}()
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		rec.Click("wiki", now, false)
+	}
+	stop()
+	<-done
+	if link, _ := st.GetLink(ctx, "wiki"); link.Clicks != 20 {
+		t.Errorf("wiki = %d clicks, want all 20", link.Clicks)
+	}
+	if times, _ := st.ClickTimes(ctx, "wiki", now.Add(-time.Minute)); len(times) != 20 {
+		t.Errorf("%d click times, want 20 from 2 weighted clicks", len(times))
+	}
+}
+
+func TestCompact(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	batch := []store.Click{
+		{Slug: "wiki", At: at},
+		{Slug: "wiki", At: at.Add(300 * time.Millisecond), FromList: true},
+		{Slug: "mail", At: at},
+		{Slug: "wiki", At: at.Add(time.Second)},
+		{Slug: "wiki", At: at, Weight: 10},
+		{Slug: "wiki", At: at, Unsampled: true},
+	}
+	compact(batch)
+	var stored []string
+	for _, c := range batch {
+		if !c.Unsampled {
+			stored = append(stored, fmt.Sprintf("%s@%d×%d", c.Slug, c.At.Sub(at)/time.Second, c.Weight))
+		}
+	}
+	if got := strings.Join(stored, " "); got != "wiki@0×12 mail@0×0 wiki@1×0" {
+		t.Errorf("stored clicks = %s", got)
+	}
+	if !batch[1].FromList || len(batch) != 6 {
+		t.Errorf("compact lost clicks from the totals: %+v", batch)
+	}
+
+	// The store gets the same counts as without compacting
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	rec := New(st, 0)
+	rec.SetFlushInterval(time.Hour)
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		rec.Run(runCtx)
+		close(done)
+	}()
+	for i := 0; i < 5; i++ {
+		rec.Click("wiki", at, i == 0)
+	}
+	stop()
+	<-done
+	if link, _ := st.GetLink(ctx, "wiki"); link.Clicks != 5 || link.ListOpens != 1 {
+		t.Errorf("wiki = %+v", link)
+	}
+	if days, _ := st.ClickDays(ctx, "wiki", at.Add(-time.Hour), time.UTC); days["2026-10-16"] != 5 {
+		t.Errorf("ClickDays = %v", days)
+	}
+}
@@ -0,0 +1,59 @@
+package clicks
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampling keeps one in Rate of the clicks of hot links, those clicked
+// more than Hot times in the current hour, to bound what very busy links
+// cost to store or log. The zero value keeps every click.
+type Sampling struct {
+	Rate int
+	Hot  int
+}
+
+// Enabled reports whether any click is left out.
+func (s Sampling) Enabled() bool {
+	return s.Rate > 1
+}
+
+// Sampler decides which clicks of hot links are kept. It is safe for
+// concurrent use.
+type Sampler struct {
+	cfg Sampling
+
+	mu sync.Mutex
+	// seen counts the clicks of each slug since hour began.
+	hour time.Time
+	seen map[string]int
+}
+
+// NewSampler returns a Sampler for cfg.
+func NewSampler(cfg Sampling) *Sampler {
+	return &Sampler{cfg: cfg, seen: make(map[string]int)}
+}
+
+// Sample returns how many clicks the click of slug at at stands for if it
+// is kept: 1 while its link isn't hot, Rate for one in Rate of the clicks
+// after, and 0 for the others, which are left out.
+func (s *Sampler) Sample(slug string, at time.Time) int {
+	if !s.cfg.Enabled() {
+		return 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A click may arrive after a later one, so the hour only moves forward
+	if hour := at.Truncate(time.Hour); hour.After(s.hour) {
+		s.hour, s.seen = hour, make(map[string]int)
+	}
+	n := s.seen[slug]
+	s.seen[slug] = n + 1
+	switch {
+	case n < s.cfg.Hot:
+		return 1
+	case (n-s.cfg.Hot)%s.cfg.Rate == s.cfg.Rate-1:
+		return s.cfg.Rate
+	}
+	return 0
+}
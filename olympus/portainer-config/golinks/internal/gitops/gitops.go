@@ -0,0 +1,425 @@
+// Package gitops keeps the links in the database in line with a links file
+// kept in git, so links can be reviewed as pull requests and deployed
+// together with the stack.
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+// Owner is the creator recorded on links and collections the sync adds.
+// Only links it owns are removed when they leave the file, unless the sync
+// is exclusive.
+const Owner = "gitops"
+
+// Config configures the sync.
+type Config struct {
+	// Path is a links file, or a directory whose *.yaml, *.yml and *.json
+	// files together declare the links.
+	Path string
+	// Interval is how often the files are checked for changes.
+	Interval time.Duration
+	// Exclusive removes every link and collection the files don't
+	// declare, not just the ones the sync added.
+	Exclusive bool
+}
+
+// Enabled reports whether a links file is configured.
+func (c Config) Enabled() bool {
+	return c.Path != ""
+}
+
+// Spec is the desired set of links.
+type Spec struct {
+	Links       []Link       `json:"links" yaml:"links"`
+	Collections []Collection `json:"collections" yaml:"collections"`
+}
+
+// Link is a declared link. Settings left out are reset to their defaults.
+type Link struct {
+	Slug       string `json:"slug" yaml:"slug"`
+	URL        string `json:"url" yaml:"url"`
+	Public     bool   `json:"public" yaml:"public"`
+	Pin        int    `json:"pin" yaml:"pin"`
+	HitsPerDay int    `json:"hits_per_day" yaml:"hits_per_day"`
+	Failover   string `json:"failover" yaml:"failover"`
+	// Referrer is "strip", "replace", or "pass" or empty to leave the
+	// Referer to the browser.
+	Referrer string `json:"referrer" yaml:"referrer"`
+}
+
+// Collection is a declared collection.
+type Collection struct {
+	Name        string   `json:"name" yaml:"name"`
+	Title       string   `json:"title" yaml:"title"`
+	Description string   `json:"description" yaml:"description"`
+	Links       []string `json:"links" yaml:"links"`
+}
+
+// Links validates and stores links the way the admin API does; it is
+// implemented by *httpapi.Server.
+type Links interface {
+	AddLink(ctx context.Context, req httpapi.AddLinkRequest, createdBy string) (store.Link, error)
+	UpdateLink(ctx context.Context, slug, url, changedBy string) (store.Link, error)
+	RemoveLink(ctx context.Context, slug string) (string, error)
+	SaveCollection(ctx context.Context, req httpapi.SaveCollectionRequest, createdBy string) (store.Collection, error)
+	CheckFailover(url string) error
+}
+
+// Result is what one reconciliation changed.
+type Result struct {
+	Created, Updated, Removed []string
+	// Failed maps the slugs and "+collections" that could not be brought
+	// in line to why.
+	Failed map[string]string
+}
+
+// Load reads the spec at path, a file or a directory of files.
+func Load(path string) (Spec, error) {
+	files, err := specFiles(path)
+	if err != nil {
+		return Spec{}, err
+	}
+	var spec Spec
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return Spec{}, err
+		}
+		part, err := decode(name, data)
+		if err != nil {
+			return Spec{}, fmt.Errorf("%s: %w", name, err)
+		}
+		spec.Links = append(spec.Links, part.Links...)
+		spec.Collections = append(spec.Collections, part.Collections...)
+	}
+	return spec, spec.validate()
+}
+
+// specFiles returns path, or the links files in the directory path in name
+// order.
+func specFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// decode parses one file, as JSON if its name ends in .json and as YAML
+// otherwise. Unknown keys are rejected so typos don't go unnoticed.
+func decode(name string, data []byte) (Spec, error) {
+	var spec Spec
+	if !strings.EqualFold(filepath.Ext(name), ".json") {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// An empty file declares nothing
+		if err := dec.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+			return Spec{}, err
+		}
+		return spec, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return Spec{}, err
+	}
+	return spec, nil
+}
+
+func (spec Spec) validate() error {
+	slugs := make(map[string]bool, len(spec.Links))
+	for i, l := range spec.Links {
+		slug := strings.TrimSpace(l.Slug)
+		switch {
+		case slug == "" || strings.TrimSpace(l.URL) == "":
+			return fmt.Errorf("link %d needs a slug and a url", i+1)
+		case slugs[slug]:
+			return fmt.Errorf("link %s declared twice", slug)
+		case l.Pin < 0 || l.HitsPerDay < 0:
+			return fmt.Errorf("link %s: pin and hits_per_day must not be negative", slug)
+		}
+		switch l.Referrer {
+		case "", "pass", store.ReferrerStrip, store.ReferrerReplace:
+		default:
+			return fmt.Errorf("link %s: referrer must be pass, strip or replace", slug)
+		}
+		slugs[slug] = true
+	}
+	names := make(map[string]bool, len(spec.Collections))
+	for i, c := range spec.Collections {
+		name := strings.TrimSpace(c.Name)
+		if name == "" {
+			return fmt.Errorf("collection %d needs a name", i+1)
+		}
+		if names[name] {
+			return fmt.Errorf("collection %s declared twice", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// Syncer reconciles the database with the links file whenever it changes.
+type Syncer struct {
+	cfg   Config
+	store store.Store
+	links Links
+
+	mu sync.Mutex
+	// synced fingerprints the files last reconciled
+	synced [sha256.Size]byte
+}
+
+// New returns a Syncer for the links file of cfg, storing through links.
+func New(cfg Config, st store.Store, links Links) *Syncer {
+	return &Syncer{cfg: cfg, store: st, links: links}
+}
+
+// Check reconciles the database if the links file changed since the last
+// successful check; the first check always does. It returns an error if
+// the file cannot be read or any link failed.
+func (s *Syncer) Check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum, err := fingerprint(s.cfg.Path)
+	if err != nil {
+		return err
+	}
+	if sum == s.synced {
+		return nil
+	}
+	spec, err := Load(s.cfg.Path)
+	if err != nil {
+		return err
+	}
+	res, err := s.Reconcile(ctx, spec)
+	if err != nil {
+		return err
+	}
+	slog.Info("GitOps sync", "path", s.cfg.Path, "created", len(res.Created), "updated", len(res.Updated), "removed", len(res.Removed), "failed", len(res.Failed))
+	// Links that failed validation fail again until the file is fixed, so
+	// they are not retried before it changes
+	s.synced = sum
+	if len(res.Failed) > 0 {
+		keys := make([]string, 0, len(res.Failed))
+		for k := range res.Failed {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			slog.Warn("GitOps sync failed", "key", k, "error", res.Failed[k])
+		}
+		return fmt.Errorf("%d of the declared links and collections failed: %s", len(keys), strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// fingerprint hashes the names and contents of the links files at path.
+func fingerprint(path string) ([sha256.Size]byte, error) {
+	files, err := specFiles(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	h := sha256.New()
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// Reconcile adds, updates and removes links and collections until the
+// database matches spec. Links that fail are recorded in the result and
+// the rest carry on; an error is only returned if the database cannot be
+// read.
+func (s *Syncer) Reconcile(ctx context.Context, spec Spec) (Result, error) {
+	if s.cfg.Exclusive && len(spec.Links) == 0 {
+		// An empty spec is far more likely a broken checkout than a wish to
+		// delete every link
+		return Result{}, errors.New("refusing an exclusive sync without links")
+	}
+	existing := map[string]store.Link{}
+	err := s.store.EachLink(ctx, func(l store.Link) error {
+		existing[l.Slug] = l
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Failed: map[string]string{}}
+	declared := make(map[string]bool, len(spec.Links))
+	for _, want := range spec.Links {
+		slug := strings.TrimSpace(want.Slug)
+		declared[slug] = true
+		stored, changed, err := s.syncLink(ctx, want, slug, existing)
+		if stored != "" {
+			declared[stored] = true
+		}
+		if err != nil {
+			res.Failed[slug] = err.Error()
+			continue
+		}
+		if _, ok := existing[stored]; !ok {
+			res.Created = append(res.Created, stored)
+		} else if changed {
+			res.Updated = append(res.Updated, stored)
+		}
+	}
+
+	s.syncCollections(ctx, spec.Collections, &res)
+
+	for _, l := range existing {
+		if declared[l.Slug] || (!s.cfg.Exclusive && l.CreatedBy != Owner) {
+			continue
+		}
+		if _, err := s.links.RemoveLink(ctx, l.Slug); err != nil && !errors.Is(err, store.ErrNotFound) {
+			res.Failed[l.Slug] = err.Error()
+			continue
+		}
+		res.Removed = append(res.Removed, l.Slug)
+	}
+	slices.Sort(res.Removed)
+	return res, nil
+}
+
+// syncLink brings one declared link in line. It returns the slug the link
+// is stored under and whether an existing link changed.
+func (s *Syncer) syncLink(ctx context.Context, want Link, slug string, existing map[string]store.Link) (string, bool, error) {
+	url := strings.TrimSpace(want.URL)
+	if err := s.links.CheckFailover(want.Failover); err != nil {
+		return "", false, fmt.Errorf("failover: %w", err)
+	}
+
+	have, ok := existing[slug]
+	changed := false
+	switch {
+	case !ok:
+		link, err := s.links.AddLink(ctx, httpapi.AddLinkRequest{Slug: slug, URL: url, Public: want.Public}, Owner)
+		if err != nil {
+			return "", false, err
+		}
+		have = link
+	case have.Status == store.StatusReserved:
+		return slug, false, errors.New("slug is reserved")
+	case have.URL != url:
+		// Pointing a link elsewhere makes it the sync's, like any other
+		// admin who changes it
+		link, err := s.links.UpdateLink(ctx, slug, url, Owner)
+		if err != nil {
+			return slug, false, err
+		}
+		link.Public, link.Pin, link.HitBudget, link.Failover, link.Referrer = have.Public, have.Pin, have.HitBudget, have.Failover, have.Referrer
+		have, changed = link, true
+	}
+
+	referrer := want.Referrer
+	if referrer == "pass" {
+		referrer = ""
+	}
+	var steps []error
+	set := func(differs bool, fn func() error) {
+		if differs {
+			changed = true
+			steps = append(steps, fn())
+		}
+	}
+	set(have.Public != want.Public, func() error { return s.store.SetPublic(ctx, have.Slug, want.Public) })
+	set(have.Pin != want.Pin, func() error { return s.store.SetPin(ctx, have.Slug, want.Pin) })
+	set(have.HitBudget != want.HitsPerDay, func() error { return s.store.SetHitBudget(ctx, have.Slug, want.HitsPerDay) })
+	set(have.Failover != want.Failover, func() error { return s.store.SetFailover(ctx, have.Slug, want.Failover) })
+	set(have.Referrer != referrer, func() error { return s.store.SetReferrer(ctx, have.Slug, referrer) })
+	return have.Slug, changed, errors.Join(steps...)
+}
+
+// syncCollections saves the declared collections that differ and removes
+// the ones no longer declared.
+func (s *Syncer) syncCollections(ctx context.Context, want []Collection, res *Result) {
+	have, err := s.store.ListCollections(ctx)
+	if err != nil {
+		res.Failed["+collections"] = err.Error()
+		return
+	}
+	byName := make(map[string]store.Collection, len(have))
+	for _, c := range have {
+		byName[c.Name] = c
+	}
+
+	declared := make(map[string]bool, len(want))
+	for _, c := range want {
+		req := httpapi.SaveCollectionRequest{
+			Name:        strings.TrimSpace(c.Name),
+			Title:       strings.TrimSpace(c.Title),
+			Description: strings.TrimSpace(c.Description),
+			Slugs:       c.Links,
+		}
+		declared[req.Name] = true
+		old, ok := byName[req.Name]
+		if ok && old.Title == req.Title && old.Description == req.Description && slices.Equal(old.Slugs, req.Slugs) {
+			continue
+		}
+		if _, err := s.links.SaveCollection(ctx, req, Owner); err != nil {
+			res.Failed["+"+req.Name] = err.Error()
+			continue
+		}
+		if ok {
+			res.Updated = append(res.Updated, "+"+req.Name)
+		} else {
+			res.Created = append(res.Created, "+"+req.Name)
+		}
+	}
+
+	for _, c := range have {
+		if declared[c.Name] || (!s.cfg.Exclusive && c.CreatedBy != Owner) {
+			continue
+		}
+		if err := s.store.RemoveCollection(ctx, c.Name); err != nil && !errors.Is(err, store.ErrNotFound) {
+			res.Failed["+"+c.Name] = err.Error()
+			continue
+		}
+		res.Removed = append(res.Removed, "+"+c.Name)
+	}
+}
@@ -0,0 +1,214 @@
+package gitops
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	doc := `---
+# Team links
+links:
+  - slug: wiki
+    url: "https://wiki.example.com/#home"  # quoted, so # stays
+    public: true
+    pin: 2
+  - slug: 'it''s'
+    url: https://example.com/bob's # owner: bob
+    referrer: strip
+  - slug: 2024
+    url: https://example.com/2024
+collections:
+- name: onboarding
+  title: Start here
+  links: [wiki, "it's"]
+  description: ~
+`
+	got, err := decode("links.yaml", []byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Spec{
+		Links: []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com/#home", Public: true, Pin: 2},
+			{Slug: "it's", URL: "https://example.com/bob's", Referrer: "strip"},
+			{Slug: "2024", URL: "https://example.com/2024"},
+		},
+		Collections: []Collection{{Name: "onboarding", Title: "Start here", Links: []string{"wiki", "it's"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decode = %#v\nwant %#v", got, want)
+	}
+	if got, err := decode("empty.yaml", []byte("# nothing yet\n")); err != nil || !reflect.DeepEqual(got, Spec{}) {
+		t.Errorf("empty file = %#v, %v", got, err)
+	}
+
+	for doc, wantErr := range map[string]string{
+		"links:\n\t- slug: a":             "line 2",
+		"links: []\nlinks: []":            `"links" already defined`,
+		"links:\n  - slgu: a\n    url: b": "field slgu not found",
+		"links:\n  - slug: [a]":           "line 2",
+		"links: {":                        "line 1",
+	} {
+		if _, err := decode("links.yaml", []byte(doc)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("decode(%q) = %v, want %q", doc, err, wantErr)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b.json", `{"links": [{"slug": "docs", "url": "https://docs.example.com"}]}`)
+	write("a.yaml", "links:\n  - slug: wiki\n    url: https://wiki.example.com\n")
+	write("notes.txt", "not a links file")
+
+	spec, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Links) != 2 || spec.Links[0].Slug != "wiki" || spec.Links[1].Slug != "docs" {
+		t.Errorf("Load = %+v, want wiki then docs", spec.Links)
+	}
+
+	write("c.yml", "links:\n  - slug: wiki\n    url: https://other.example.com\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "wiki declared twice") {
+		t.Errorf("Load with a duplicate = %v", err)
+	}
+	write("c.yml", "links:\n  - slug: x\n    url: https://x.example.com\n    pinned: 1\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "field pinned not found") {
+		t.Errorf("Load with a typo = %v", err)
+	}
+	write("c.yml", "links:\n  - slug: x\n    url: https://x.example.com\n    referrer: hide\n")
+	if _, err := Load(dir); err == nil || !strings.Contains(err.Error(), "referrer must be") {
+		t.Errorf("Load with a bad referrer = %v", err)
+	}
+}
+
+func newTestSyncer(t *testing.T, cfg Config) (*Syncer, *store.Memory) {
+	t.Helper()
+	st := store.NewMemory()
+	api := httpapi.New(httpapi.Config{SensitivePatterns: []string{"*.bank.example.com"}}, st, httpapi.Pages{})
+	return New(cfg, st, api), st
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestSyncer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "manual", URL: "https://manual.example.com", Status: store.StatusActive, CreatedBy: "alice"})
+	st.AddLink(ctx, store.Link{Slug: "adopted", URL: "https://old.example.com", Status: store.StatusActive, CreatedBy: "alice"})
+	st.AddLink(ctx, store.Link{Slug: "held", Status: store.StatusReserved, CreatedBy: "alice"})
+
+	spec := Spec{
+		Links: []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com", Public: true, Pin: 3, Referrer: "strip"},
+			{Slug: "adopted", URL: "https://new.example.com", Failover: "https://mirror.example.com"},
+			{Slug: "held", URL: "https://held.example.com"},
+			{Slug: "bad", URL: "ftp://example.com"},
+			{Slug: "risky", URL: "https://x.example.com", Failover: "https://login.bank.example.com"},
+		},
+		Collections: []Collection{{Name: "team", Title: "Team", Links: []string{"wiki", "adopted"}}},
+	}
+	res, err := s.Reconcile(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"wiki", "+team"}; !reflect.DeepEqual(res.Created, want) {
+		t.Errorf("Created = %v, want %v", res.Created, want)
+	}
+	if want := []string{"adopted"}; !reflect.DeepEqual(res.Updated, want) {
+		t.Errorf("Updated = %v, want %v", res.Updated, want)
+	}
+	if len(res.Failed) != 3 || res.Failed["held"] == "" || res.Failed["bad"] == "" || res.Failed["risky"] == "" {
+		t.Errorf("Failed = %v, want held, bad and risky", res.Failed)
+	}
+
+	wiki, _ := st.GetLink(ctx, "wiki")
+	if !wiki.Public || wiki.Pin != 3 || wiki.Referrer != store.ReferrerStrip || wiki.CreatedBy != Owner {
+		t.Errorf("wiki = %+v", wiki)
+	}
+	adopted, _ := st.GetLink(ctx, "adopted")
+	if adopted.URL != "https://new.example.com" || adopted.Failover != "https://mirror.example.com" || adopted.CreatedBy != Owner {
+		t.Errorf("adopted = %+v", adopted)
+	}
+
+	// A second run with nothing changed changes nothing
+	res, err = s.Reconcile(ctx, spec)
+	if err != nil || len(res.Created)+len(res.Updated)+len(res.Removed) != 0 {
+		t.Errorf("second Reconcile = %+v, %v", res, err)
+	}
+
+	// Dropping links removes the ones the sync owns, not the manual ones
+	res, err = s.Reconcile(ctx, Spec{Links: []Link{{Slug: "wiki", URL: "https://wiki.example.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"+team", "adopted"}; !reflect.DeepEqual(res.Removed, want) {
+		t.Errorf("Removed = %v, want %v", res.Removed, want)
+	}
+	if wiki, _ := st.GetLink(ctx, "wiki"); wiki.Public || wiki.Pin != 0 || wiki.Referrer != "" {
+		t.Errorf("wiki settings left in place: %+v", wiki)
+	}
+	if _, err := st.GetLink(ctx, "manual"); err != nil {
+		t.Errorf("manual link removed: %v", err)
+	}
+}
+
+func TestReconcileExclusive(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestSyncer(t, Config{Exclusive: true})
+	st.AddLink(ctx, store.Link{Slug: "manual", URL: "https://manual.example.com", Status: store.StatusActive, CreatedBy: "alice"})
+
+	if _, err := s.Reconcile(ctx, Spec{}); err == nil {
+		t.Error("exclusive Reconcile of an empty spec succeeded")
+	}
+	res, err := s.Reconcile(ctx, Spec{Links: []Link{{Slug: "wiki", URL: "https://wiki.example.com"}}})
+	if err != nil || !reflect.DeepEqual(res.Removed, []string{"manual"}) {
+		t.Errorf("exclusive Reconcile = %+v, %v", res, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.yaml")
+	os.WriteFile(path, []byte("links:\n  - slug: wiki\n    url: https://wiki.example.com\n"), 0o644)
+	s, st := newTestSyncer(t, Config{Path: path})
+
+	if err := s.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Unchanged files are not reconciled again, so manual edits stay until
+	// the file changes
+	st.SetPin(ctx, "wiki", 5)
+	if err := s.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if wiki, _ := st.GetLink(ctx, "wiki"); wiki.Pin != 5 {
+		t.Errorf("unchanged file reconciled: pin = %d", wiki.Pin)
+	}
+
+	os.WriteFile(path, []byte("links:\n  - slug: wiki\n    url: https://wiki.example.com/v2\n  - slug: bad\n    url: nope\n"), 0o644)
+	if err := s.Check(ctx); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Errorf("Check with a bad link = %v", err)
+	}
+	if wiki, _ := st.GetLink(ctx, "wiki"); wiki.URL != "https://wiki.example.com/v2" || wiki.Pin != 0 {
+		t.Errorf("wiki = %+v", wiki)
+	}
+
+	os.WriteFile(path, []byte("links: [\n"), 0o644)
+	if err := s.Check(ctx); err == nil {
+		t.Error("Check of a malformed file succeeded")
+	}
+}
@@ -0,0 +1,652 @@
+// Package graphql answers GraphQL queries over a schema whose fields are
+// resolved by Go functions. It covers what a dashboard needs to fetch a
+// page in one request: queries with arguments, variables, aliases,
+// fragments and @skip/@include. Mutations, subscriptions, interfaces,
+// input objects and introspection beyond __typename are not supported;
+// Schema.String prints the schema instead.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Built-in scalar types.
+var scalars = map[string]bool{"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true}
+
+// Object is an object type of a schema.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+
+	fields map[string]*Field
+}
+
+// Field is a field of an object type.
+type Field struct {
+	Name        string
+	Description string
+	// Type is the field's type in schema notation, e.g. "[Link!]!".
+	Type string
+	Args []Arg
+	// Resolve returns the value of the field of source, which is nil for
+	// the fields of Query. Lists may be returned as any slice; objects
+	// are handed to the resolvers of their own fields.
+	Resolve func(ctx context.Context, source any, args map[string]any) (any, error)
+}
+
+// Arg is an argument of a field. Args holds every argument, with Default
+// (which may be nil) for those not given.
+type Arg struct {
+	Name, Type string
+	Default    any
+}
+
+// EnumType is an enum type of a schema. Resolvers see its values as
+// strings.
+type EnumType struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+// Schema is a Query type and the object and enum types it reaches.
+type Schema struct {
+	query *Object
+	types []any
+	named map[string]any
+}
+
+// NewSchema checks that every type the fields and arguments of query and
+// types refer to is defined. types are *Object and *EnumType values.
+func NewSchema(query *Object, types ...any) (*Schema, error) {
+	s := &Schema{query: query, types: append([]any{query}, types...), named: make(map[string]any)}
+	for _, t := range s.types {
+		var name string
+		switch t := t.(type) {
+		case *Object:
+			name = t.Name
+			t.fields = make(map[string]*Field, len(t.Fields))
+			for _, f := range t.Fields {
+				t.fields[f.Name] = f
+			}
+		case *EnumType:
+			name = t.Name
+		default:
+			return nil, fmt.Errorf("graphql: %T is not a type", t)
+		}
+		if s.named[name] != nil || scalars[name] {
+			return nil, fmt.Errorf("graphql: type %s is defined twice", name)
+		}
+		s.named[name] = t
+	}
+	for _, t := range s.types {
+		o, ok := t.(*Object)
+		if !ok {
+			continue
+		}
+		for _, f := range o.Fields {
+			if !s.defined(f.Type) {
+				return nil, fmt.Errorf("graphql: %s.%s has unknown type %s", o.Name, f.Name, f.Type)
+			}
+			if f.Resolve == nil {
+				return nil, fmt.Errorf("graphql: %s.%s has no resolver", o.Name, f.Name)
+			}
+			for _, a := range f.Args {
+				if _, isObject := s.named[namedType(a.Type)].(*Object); !s.defined(a.Type) || isObject {
+					return nil, fmt.Errorf("graphql: argument %s of %s.%s has unknown input type %s", a.Name, o.Name, f.Name, a.Type)
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) defined(typ string) bool {
+	name := namedType(typ)
+	return scalars[name] || s.named[name] != nil
+}
+
+// namedType strips the list and non-null wrappers of typ.
+func namedType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is absent if the request
+// could not be executed at all.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []Error         `json:"errors,omitempty"`
+}
+
+// Error is an error of a request, with the path of the field it occurred
+// at, if any.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Execute runs the query of req. Errors of single fields leave those
+// fields null and are listed next to the rest of the data.
+func (s *Schema) Execute(ctx context.Context, req Request) Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	e := &executor{ctx: ctx, schema: s, doc: doc}
+	e.validate(s.query, op.Selection, nil, map[string]bool{})
+	if len(e.errs) > 0 {
+		return Response{Errors: e.errs}
+	}
+	if e.vars, err = s.variables(op, req.Variables); err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	for name := range e.used {
+		if _, ok := e.vars[name]; !ok {
+			return Response{Errors: []Error{{Message: fmt.Sprintf("variable $%s is not defined", name)}}}
+		}
+	}
+
+	data, ok := e.selectFields(s.query, op.Selection, nil, nil)
+	resp := Response{Data: json.RawMessage("null"), Errors: e.errs}
+	if ok {
+		if resp.Data, err = json.Marshal(data); err != nil {
+			resp.Data = json.RawMessage("null")
+			resp.Errors = append(resp.Errors, Error{Message: err.Error()})
+		}
+	}
+	return resp
+}
+
+// operation picks the operation name of doc; without a name the document
+// must hold a single one.
+func (doc *Document) operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for a document with several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %s", name)
+}
+
+// variables coerces the values of the variables of op, taking defaults
+// for those not given.
+func (s *Schema) variables(op *Operation, given map[string]any) (map[string]any, error) {
+	vars := make(map[string]any, len(op.Variables))
+	for _, v := range op.Variables {
+		if _, isObject := s.named[namedType(v.Type)].(*Object); !s.defined(v.Type) || isObject {
+			return nil, fmt.Errorf("variable $%s has unknown input type %s", v.Name, v.Type)
+		}
+		value, ok := given[v.Name]
+		if !ok {
+			value = v.Default
+		}
+		coerced, err := s.coerce(v.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", v.Name, err)
+		}
+		vars[v.Name] = coerced
+	}
+	return vars, nil
+}
+
+// coerce converts an argument or variable value, parsed from the query or
+// decoded from JSON, to the Go value resolvers see for typ: int, float64,
+// string, bool, []any or nil.
+func (s *Schema) coerce(typ string, v any) (any, error) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if v == nil {
+		if nonNull {
+			return nil, fmt.Errorf("expected %s!, found null", typ)
+		}
+		return nil, nil
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		elem := typ[1 : len(typ)-1]
+		items, ok := v.([]any)
+		if !ok {
+			// A single value stands for a list of one
+			items = []any{v}
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			var err error
+			if list[i], err = s.coerce(elem, item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+
+	switch typ {
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case float64:
+			if n == float64(int(n)) {
+				return int(n), nil
+			}
+		}
+	case "Float":
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "String":
+		if str, ok := v.(string); ok {
+			return str, nil
+		}
+	case "ID":
+		switch id := v.(type) {
+		case string:
+			return id, nil
+		case int:
+			return strconv.Itoa(id), nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		enum := s.named[typ].(*EnumType)
+		// Enum values are bare names in queries and strings in JSON
+		var value string
+		switch e := v.(type) {
+		case Enum:
+			value = string(e)
+		case string:
+			value = e
+		}
+		if slices.Contains(enum.Values, value) {
+			return value, nil
+		}
+		return nil, fmt.Errorf("expected one of %s, found %s", strings.Join(enum.Values, ", "), describe(v))
+	}
+	return nil, fmt.Errorf("expected %s, found %s", typ, describe(v))
+}
+
+func describe(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case Enum:
+		return string(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+type executor struct {
+	ctx    context.Context
+	schema *Schema
+	doc    *Document
+	vars   map[string]any
+	// used are the variables the query refers to
+	used map[string]bool
+	errs []Error
+}
+
+func (e *executor) errorf(path []any, format string, args ...any) {
+	e.errs = append(e.errs, Error{Message: fmt.Sprintf(format, args...), Path: slices.Clone(path)})
+}
+
+// validate checks that set only selects fields obj has, with arguments
+// they take, and subselections exactly on fields of object type.
+// spreads holds the fragments being expanded, to catch cycles.
+func (e *executor) validate(obj *Object, set []Selection, path []any, spreads map[string]bool) {
+	for _, sel := range set {
+		for _, d := range sel.Directives {
+			if d.Name != "skip" && d.Name != "include" {
+				e.errorf(path, "unknown directive @%s", d.Name)
+			} else if _, ok := d.Args["if"]; !ok || len(d.Args) != 1 {
+				e.errorf(path, "@%s takes a single argument if", d.Name)
+			}
+			e.useVars(d.Args)
+		}
+		switch {
+		case sel.Spread != "":
+			f := e.doc.Fragments[sel.Spread]
+			if f == nil {
+				e.errorf(path, "unknown fragment %s", sel.Spread)
+				continue
+			}
+			if spreads[f.Name] {
+				e.errorf(path, "fragment %s spreads itself", f.Name)
+				continue
+			}
+			spreads[f.Name] = true
+			e.validate(obj, f.Selection, path, spreads)
+			delete(spreads, f.Name)
+			continue
+		case sel.Inline:
+			e.validate(obj, sel.Selection, path, spreads)
+			continue
+		}
+
+		fieldPath := append(path, sel.key())
+		if sel.Name == "__typename" {
+			if sel.Selection != nil {
+				e.errorf(fieldPath, "__typename has no fields")
+			}
+			continue
+		}
+		f := obj.fields[sel.Name]
+		if f == nil {
+			e.errorf(fieldPath, "cannot query field %s on type %s", sel.Name, obj.Name)
+			continue
+		}
+		for name := range sel.Args {
+			if !slices.ContainsFunc(f.Args, func(a Arg) bool { return a.Name == name }) {
+				e.errorf(fieldPath, "unknown argument %s of %s.%s", name, obj.Name, f.Name)
+			}
+		}
+		e.useVars(sel.Args)
+		sub, isObject := e.schema.named[namedType(f.Type)].(*Object)
+		switch {
+		case isObject && sel.Selection == nil:
+			e.errorf(fieldPath, "field %s of type %s needs a selection of subfields", f.Name, f.Type)
+		case !isObject && sel.Selection != nil:
+			e.errorf(fieldPath, "field %s of type %s has no subfields", f.Name, f.Type)
+		case isObject:
+			e.validate(sub, sel.Selection, fieldPath, spreads)
+		}
+	}
+}
+
+// useVars records the variables args refer to.
+func (e *executor) useVars(args map[string]any) {
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case Variable:
+			if e.used == nil {
+				e.used = make(map[string]bool)
+			}
+			e.used[string(v)] = true
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	for _, v := range args {
+		walk(v)
+	}
+}
+
+// key is the name of a field in the response.
+func (sel *Selection) key() string {
+	if sel.Alias != "" {
+		return sel.Alias
+	}
+	return sel.Name
+}
+
+// fieldGroup is the selections of one response key, merged.
+type fieldGroup struct {
+	key  string
+	sels []Selection
+}
+
+// collect flattens the fragments of set and groups its fields by response
+// key, in order of first appearance, leaving out skipped ones.
+func (e *executor) collect(set []Selection, groups []fieldGroup) []fieldGroup {
+	for _, sel := range set {
+		if !e.included(sel.Directives) {
+			continue
+		}
+		switch {
+		case sel.Spread != "":
+			groups = e.collect(e.doc.Fragments[sel.Spread].Selection, groups)
+		case sel.Inline:
+			groups = e.collect(sel.Selection, groups)
+		default:
+			i := slices.IndexFunc(groups, func(g fieldGroup) bool { return g.key == sel.key() })
+			if i < 0 {
+				groups = append(groups, fieldGroup{key: sel.key()})
+				i = len(groups) - 1
+			}
+			groups[i].sels = append(groups[i].sels, sel)
+		}
+	}
+	return groups
+}
+
+// included evaluates @skip and @include.
+func (e *executor) included(ds []Directive) bool {
+	for _, d := range ds {
+		v, _ := e.schema.coerce("Boolean!", e.substitute(d.Args["if"]))
+		if cond, _ := v.(bool); cond == (d.Name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// substitute replaces the variables in an argument value by their values.
+func (e *executor) substitute(v any) any {
+	switch v := v.(type) {
+	case Variable:
+		return e.vars[string(v)]
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = e.substitute(item)
+		}
+		return list
+	}
+	return v
+}
+
+// selectFields resolves set on source, an object of type obj. It returns
+// false if a non-null field turned out null, making the object null.
+func (e *executor) selectFields(obj *Object, set []Selection, source any, path []any) (object, bool) {
+	groups := e.collect(set, nil)
+	result := make(object, 0, len(groups))
+	for _, g := range groups {
+		value, ok := e.resolve(obj, g, source, append(path, g.key))
+		if !ok {
+			return nil, false
+		}
+		result = append(result, member{g.key, value})
+	}
+	return result, true
+}
+
+func (e *executor) resolve(obj *Object, g fieldGroup, source any, path []any) (any, bool) {
+	sel := g.sels[0]
+	if sel.Name == "__typename" {
+		return obj.Name, true
+	}
+	f := obj.fields[sel.Name]
+	nonNull := strings.HasSuffix(f.Type, "!")
+	if err := e.ctx.Err(); err != nil {
+		e.errorf(path, "%v", err)
+		return nil, !nonNull
+	}
+
+	args := make(map[string]any, len(f.Args))
+	for _, a := range f.Args {
+		v, given := sel.Args[a.Name]
+		if !given {
+			v = a.Default
+		}
+		v, err := e.schema.coerce(a.Type, e.substitute(v))
+		if err != nil {
+			e.errorf(path, "argument %s: %v", a.Name, err)
+			return nil, !nonNull
+		}
+		args[a.Name] = v
+	}
+	value, err := f.Resolve(e.ctx, source, args)
+	if err != nil {
+		e.errorf(path, "%v", err)
+		return nil, !nonNull
+	}
+
+	var sub []Selection
+	for _, s := range g.sels {
+		sub = append(sub, s.Selection...)
+	}
+	return e.complete(f.Type, value, sub, path)
+}
+
+// complete turns a resolved value of type typ into its response value.
+func (e *executor) complete(typ string, value any, set []Selection, path []any) (any, bool) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	rv := reflect.ValueOf(value)
+	if value == nil || rv.Kind() == reflect.Pointer && rv.IsNil() {
+		if nonNull {
+			e.errorf(path, "null for non-null field of type %s!", typ)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		if rv.Kind() != reflect.Slice {
+			e.errorf(path, "%T is not a list", value)
+			return nil, !nonNull
+		}
+		list := make([]any, rv.Len())
+		for i := range list {
+			item, ok := e.complete(typ[1:len(typ)-1], rv.Index(i).Interface(), set, append(path, i))
+			if !ok {
+				return nil, !nonNull
+			}
+			list[i] = item
+		}
+		return list, true
+	}
+
+	obj, isObject := e.schema.named[typ].(*Object)
+	if !isObject {
+		return value, true
+	}
+	result, ok := e.selectFields(obj, set, value, path)
+	if !ok {
+		return nil, !nonNull
+	}
+	return result, true
+}
+
+// object is a response object, which keeps its fields in query order.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// String prints the schema in the GraphQL schema language.
+func (s *Schema) String() string {
+	var b strings.Builder
+	for i, t := range s.types {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		switch t := t.(type) {
+		case *Object:
+			writeDescription(&b, "", t.Description)
+			fmt.Fprintf(&b, "type %s {\n", t.Name)
+			for _, f := range t.Fields {
+				writeDescription(&b, "  ", f.Description)
+				fmt.Fprintf(&b, "  %s", f.Name)
+				if len(f.Args) > 0 {
+					b.WriteByte('(')
+					for j, a := range f.Args {
+						if j > 0 {
+							b.WriteString(", ")
+						}
+						fmt.Fprintf(&b, "%s: %s", a.Name, a.Type)
+						if a.Default != nil {
+							fmt.Fprintf(&b, " = %s", s.literal(a.Type, a.Default))
+						}
+					}
+					b.WriteByte(')')
+				}
+				fmt.Fprintf(&b, ": %s\n", f.Type)
+			}
+			b.WriteString("}\n")
+		case *EnumType:
+			writeDescription(&b, "", t.Description)
+			fmt.Fprintf(&b, "enum %s {\n", t.Name)
+			for _, v := range t.Values {
+				fmt.Fprintf(&b, "  %s\n", v)
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, indent, desc string) {
+	if desc != "" {
+		fmt.Fprintf(b, "%s%s\n", indent, strconv.Quote(desc))
+	}
+}
+
+// literal writes a default value as it would appear in a query.
+func (s *Schema) literal(typ string, v any) string {
+	if _, isEnum := s.named[namedType(typ)].(*EnumType); isEnum {
+		return fmt.Sprint(v)
+	}
+	if str, ok := v.(string); ok {
+		return strconv.Quote(str)
+	}
+	return fmt.Sprint(v)
+}
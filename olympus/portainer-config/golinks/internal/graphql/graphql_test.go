@@ -0,0 +1,234 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type book struct {
+	Title  string
+	Year   int
+	Author *person
+}
+
+type person struct {
+	Name string
+}
+
+func testSchema(t *testing.T) *Schema {
+	t.Helper()
+	books := []book{
+		{"Dune", 1965, &person{"Frank Herbert"}},
+		{"Anonymous", 1900, nil},
+		{"Hyperion", 1989, &person{"Dan Simmons"}},
+	}
+	personType := &Object{Name: "Person", Fields: []*Field{
+		{Name: "name", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(*person).Name, nil
+		}},
+	}}
+	bookType := &Object{Name: "Book", Description: "A book.", Fields: []*Field{
+		{Name: "title", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(book).Title, nil
+		}},
+		{Name: "year", Type: "Int!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(book).Year, nil
+		}},
+		{Name: "author", Type: "Person", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(book).Author, nil
+		}},
+		{Name: "authorName", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			if a := src.(book).Author; a != nil {
+				return a.Name, nil
+			}
+			return nil, nil
+		}},
+	}}
+	order := &EnumType{Name: "Order", Values: []string{"TITLE", "YEAR"}}
+	query := &Object{Name: "Query", Fields: []*Field{
+		{
+			Name: "books", Type: "[Book!]!", Description: "Every book.",
+			Args: []Arg{{Name: "first", Type: "Int", Default: 10}, {Name: "order", Type: "Order", Default: "YEAR"}, {Name: "titles", Type: "[String!]"}},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				var list []book
+				for _, b := range books {
+					if titles, ok := args["titles"].([]any); ok && !contains(titles, b.Title) {
+						continue
+					}
+					list = append(list, b)
+				}
+				return list[:min(args["first"].(int), len(list))], nil
+			},
+		},
+		{Name: "book", Type: "Book", Args: []Arg{{Name: "title", Type: "String!"}}, Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			for _, b := range books {
+				if b.Title == args["title"] {
+					return b, nil
+				}
+			}
+			return nil, nil
+		}},
+		{Name: "fail", Type: "Int", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return nil, errors.New("out of ink")
+		}},
+	}}
+	s, err := NewSchema(query, bookType, personType, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func contains(list []any, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func run(t *testing.T, s *Schema, query string, vars map[string]any) (string, []Error) {
+	t.Helper()
+	resp := s.Execute(context.Background(), Request{Query: query, Variables: vars})
+	return string(resp.Data), resp.Errors
+}
+
+func TestExecute(t *testing.T) {
+	s := testSchema(t)
+	tests := []struct {
+		name, query string
+		vars        map[string]any
+		want        string
+	}{
+		{"fields in query order", `{ books(first: 1) { year title } }`, nil,
+			`{"books":[{"year":1965,"title":"Dune"}]}`},
+		{"aliases and arguments", `query { a: book(title: "Dune") { title } b: book(title: "nope") { title } }`, nil,
+			`{"a":{"title":"Dune"},"b":null}`},
+		{"variables", `query Q($t: String!, $n: Int = 2) { book(title: $t) { year } books(first: $n) { title } }`, map[string]any{"t": "Hyperion"},
+			`{"book":{"year":1989},"books":[{"title":"Dune"},{"title":"Anonymous"}]}`},
+		{"nested and null objects", `{ books { author { name } } }`, nil,
+			`{"books":[{"author":{"name":"Frank Herbert"}},{"author":null},{"author":{"name":"Dan Simmons"}}]}`},
+		{"fragments", `{ book(title: "Dune") { ...T ... on Book { year } } } fragment T on Book { title __typename }`, nil,
+			`{"book":{"title":"Dune","__typename":"Book","year":1965}}`},
+		{"merged selections", `{ book(title: "Dune") { title } book(title: "Dune") { year } }`, nil,
+			`{"book":{"title":"Dune","year":1965}}`},
+		{"directives", `query($all: Boolean!) { book(title: "Dune") { title year @include(if: $all) author @skip(if: true) { name } } }`, map[string]any{"all": false},
+			`{"book":{"title":"Dune"}}`},
+		{"list argument", `{ books(titles: ["Hyperion", "Dune"]) { title } }`, nil,
+			`{"books":[{"title":"Dune"},{"title":"Hyperion"}]}`},
+		{"single value as list", `{ books(titles: "Dune") { title } }`, nil,
+			`{"books":[{"title":"Dune"}]}`},
+		{"json numbers as Int", `query($n: Int) { books(first: $n) { title } }`, map[string]any{"n": float64(1)},
+			`{"books":[{"title":"Dune"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, errs := run(t, s, tt.query, tt.vars)
+			if len(errs) > 0 {
+				t.Fatalf("errors: %v", errs)
+			}
+			if data != tt.want {
+				t.Errorf("data = %s\nwant   %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteFieldErrors(t *testing.T) {
+	s := testSchema(t)
+
+	// A failing field is null and reported with its path
+	data, errs := run(t, s, `{ fail book(title: "Dune") { title } }`, nil)
+	if data != `{"fail":null,"book":{"title":"Dune"}}` || len(errs) != 1 || errs[0].Message != "out of ink" {
+		t.Errorf("data = %s, errors = %v", data, errs)
+	}
+
+	// A null non-null field nulls its parent
+	data, errs = run(t, s, `{ book(title: "Anonymous") { title authorName } }`, nil)
+	if data != `{"book":null}` || len(errs) != 1 {
+		t.Errorf("data = %s, errors = %v", data, errs)
+	}
+	if b, _ := json.Marshal(errs[0].Path); string(b) != `["book","authorName"]` {
+		t.Errorf("path = %s", b)
+	}
+	// ... up to the nearest nullable field, here the root
+	data, errs = run(t, s, `{ books { authorName } }`, nil)
+	if data != "null" || len(errs) != 1 {
+		t.Errorf("data = %s, errors = %v", data, errs)
+	}
+
+	if _, errs := run(t, s, `{ books(order: SIDEWAYS) { title } }`, nil); len(errs) != 1 || !strings.Contains(errs[0].Message, "expected one of TITLE, YEAR") {
+		t.Errorf("bad enum: %v", errs)
+	}
+}
+
+func TestExecuteRequestErrors(t *testing.T) {
+	s := testSchema(t)
+	tests := []struct {
+		name, query string
+		vars        map[string]any
+		want        string
+	}{
+		{"syntax", `{ books { title }`, nil, "syntax error at 1:18"},
+		{"mutation", `mutation { books { title } }`, nil, "only queries are supported"},
+		{"unknown field", `{ books { isbn } }`, nil, "cannot query field isbn on type Book"},
+		{"unknown argument", `{ books(last: 1) { title } }`, nil, "unknown argument last"},
+		{"missing subselection", `{ books }`, nil, "needs a selection of subfields"},
+		{"scalar subselection", `{ books { title { x } } }`, nil, "has no subfields"},
+		{"unknown fragment", `{ books { ...F } }`, nil, "unknown fragment F"},
+		{"fragment cycle", `{ books { ...F } } fragment F on Book { ...G } fragment G on Book { ...F }`, nil, "spreads itself"},
+		{"undefined variable", `{ books(first: $n) { title } }`, nil, "variable $n is not defined"},
+		{"missing variable", `query($t: String!) { book(title: $t) { title } }`, nil, "expected String!, found null"},
+		{"wrong variable type", `query($n: Int) { books(first: $n) { title } }`, map[string]any{"n": "ten"}, `expected Int, found "ten"`},
+		{"too deep", `{ a { b { c { d { e { f { g { h { i { j { k } } } } } } } } } } }`, nil, "nested more than 10 levels"},
+		{"unknown directive", `{ books @cached { title } }`, nil, "unknown directive @cached"},
+		{"several operations", `query A { books { title } } query B { books { year } }`, nil, "operationName is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.Execute(context.Background(), Request{Query: tt.query, Variables: tt.vars})
+			if resp.Data != nil {
+				t.Errorf("data = %s, want none", resp.Data)
+			}
+			if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.want) {
+				t.Errorf("errors = %v, want %q", resp.Errors, tt.want)
+			}
+		})
+	}
+
+	resp := s.Execute(context.Background(), Request{Query: `query A { books { title } } query B { book(title: "Dune") { year } }`, OperationName: "B"})
+	if string(resp.Data) != `{"book":{"year":1965}}` {
+		t.Errorf("operation B: %s %v", resp.Data, resp.Errors)
+	}
+}
+
+func TestNewSchemaErrors(t *testing.T) {
+	resolve := func(ctx context.Context, src any, args map[string]any) (any, error) { return nil, nil }
+	if _, err := NewSchema(&Object{Name: "Query", Fields: []*Field{{Name: "x", Type: "[Thing]", Resolve: resolve}}}); err == nil {
+		t.Error("unknown field type accepted")
+	}
+	if _, err := NewSchema(&Object{Name: "Query", Fields: []*Field{{Name: "x", Type: "Int"}}}); err == nil {
+		t.Error("field without resolver accepted")
+	}
+	q := &Object{Name: "Query", Fields: []*Field{{Name: "x", Type: "Int", Args: []Arg{{Name: "q", Type: "Query"}}, Resolve: resolve}}}
+	if _, err := NewSchema(q); err == nil {
+		t.Error("object argument type accepted")
+	}
+}
+
+func TestSchemaString(t *testing.T) {
+	got := testSchema(t).String()
+	for _, want := range []string{
+		"type Query {\n  \"Every book.\"\n  books(first: Int = 10, order: Order = YEAR, titles: [String!]): [Book!]!\n",
+		"\"A book.\"\ntype Book {\n  title: String!\n",
+		"enum Order {\n  TITLE\n  YEAR\n}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("schema lacks %q:\n%s", want, got)
+		}
+	}
+}
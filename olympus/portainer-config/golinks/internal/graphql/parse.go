@@ -0,0 +1,511 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth is how deeply selection sets may nest, so a query cannot walk
+// links and collections back and forth without end.
+const maxDepth = 10
+
+// Document is a parsed query document.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query in a document.
+type Operation struct {
+	Name      string
+	Variables []VariableDef
+	Selection []Selection
+}
+
+// VariableDef declares a variable of an operation.
+type VariableDef struct {
+	Name    string
+	Type    string
+	Default any // nil without default
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name      string
+	Selection []Selection
+}
+
+// Selection is a field, a fragment spread (Spread set) or an inline
+// fragment (Inline set).
+type Selection struct {
+	Alias, Name string
+	Args        map[string]any
+	Directives  []Directive
+	Selection   []Selection
+	Spread      string
+	Inline      bool
+}
+
+// Directive is @name(args) on a selection.
+type Directive struct {
+	Name string
+	Args map[string]any
+}
+
+// Variable is a $name reference in an argument value.
+type Variable string
+
+// Enum is a bare name in an argument value, such as CREATED_AT.
+type Enum string
+
+// SyntaxError reports where a document fails to parse.
+type SyntaxError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Token kinds.
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// Parse parses a query document. Mutations and subscriptions are
+// rejected, as is any definition of types.
+func Parse(src string) (*Document, error) {
+	p := &parser{src: src}
+	doc := &Document{Fragments: make(map[string]*Fragment)}
+	err := p.run(func() {
+		p.next()
+		for p.tok.kind != tokEOF {
+			switch {
+			case p.peek("{"):
+				doc.Operations = append(doc.Operations, &Operation{Selection: p.selectionSet(1)})
+			case p.tok.kind == tokName && p.tok.text == "query":
+				doc.Operations = append(doc.Operations, p.operation())
+			case p.tok.kind == tokName && p.tok.text == "fragment":
+				f := p.fragment()
+				if doc.Fragments[f.Name] != nil {
+					p.fail("fragment %s is defined twice", f.Name)
+				}
+				doc.Fragments[f.Name] = f
+			case p.tok.kind == tokName && (p.tok.text == "mutation" || p.tok.text == "subscription"):
+				p.fail("only queries are supported")
+			default:
+				p.fail("unexpected %q", p.tok.text)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Operations) == 0 {
+		return nil, &SyntaxError{Line: 1, Column: 1, Msg: "no query in document"}
+	}
+	return doc, nil
+}
+
+// run calls parse, turning the panic of fail into an error.
+func (p *parser) run(parse func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	parse()
+	return nil
+}
+
+func (p *parser) fail(format string, args ...any) {
+	line, col := 1, 1
+	for _, r := range p.src[:p.tok.pos] {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	panic(&SyntaxError{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) operation() *Operation {
+	p.next() // query
+	op := &Operation{}
+	if p.tok.kind == tokName {
+		op.Name = p.name()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			v := VariableDef{Name: p.name()}
+			p.expect(":")
+			v.Type = p.typeRef()
+			if p.skip("=") {
+				v.Default = p.value(true)
+			}
+			op.Variables = append(op.Variables, v)
+		}
+	}
+	if p.peek("@") {
+		p.fail("directives on operations are not supported")
+	}
+	op.Selection = p.selectionSet(1)
+	return op
+}
+
+func (p *parser) fragment() *Fragment {
+	p.next() // fragment
+	f := &Fragment{Name: p.name()}
+	if f.Name == "on" {
+		p.fail("fragment cannot be named on")
+	}
+	p.typeCondition()
+	f.Selection = p.selectionSet(1)
+	return f
+}
+
+// typeCondition parses "on Type". Every type of the schema is concrete, so
+// the condition is only checked for syntax.
+func (p *parser) typeCondition() {
+	if p.tok.kind != tokName || p.tok.text != "on" {
+		p.fail("expected on")
+	}
+	p.next()
+	p.name()
+}
+
+func (p *parser) typeRef() string {
+	var t string
+	if p.skip("[") {
+		t = "[" + p.typeRef() + "]"
+		p.expect("]")
+	} else {
+		t = p.name()
+	}
+	if p.skip("!") {
+		t += "!"
+	}
+	return t
+}
+
+func (p *parser) selectionSet(depth int) []Selection {
+	if depth > maxDepth {
+		p.fail("query is nested more than %d levels deep", maxDepth)
+	}
+	p.expect("{")
+	var set []Selection
+	for !p.skip("}") {
+		if p.tok.kind == tokEOF {
+			p.fail("unexpected end of document")
+		}
+		set = append(set, p.selection(depth))
+	}
+	if len(set) == 0 {
+		p.fail("empty selection")
+	}
+	return set
+}
+
+func (p *parser) selection(depth int) Selection {
+	if p.skip("...") {
+		if p.tok.kind == tokName && p.tok.text != "on" {
+			s := Selection{Spread: p.name()}
+			s.Directives = p.directives()
+			return s
+		}
+		s := Selection{Inline: true}
+		if p.tok.kind == tokName {
+			p.typeCondition()
+		}
+		s.Directives = p.directives()
+		s.Selection = p.selectionSet(depth)
+		return s
+	}
+
+	s := Selection{Name: p.name()}
+	if p.skip(":") {
+		s.Alias, s.Name = s.Name, p.name()
+	}
+	if p.skip("(") {
+		s.Args = make(map[string]any)
+		for !p.skip(")") {
+			name := p.name()
+			p.expect(":")
+			s.Args[name] = p.value(false)
+		}
+	}
+	s.Directives = p.directives()
+	if p.peek("{") {
+		s.Selection = p.selectionSet(depth + 1)
+	}
+	return s
+}
+
+func (p *parser) directives() []Directive {
+	var ds []Directive
+	for p.skip("@") {
+		d := Directive{Name: p.name(), Args: make(map[string]any)}
+		if p.skip("(") {
+			for !p.skip(")") {
+				name := p.name()
+				p.expect(":")
+				d.Args[name] = p.value(false)
+			}
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// value parses an argument value; constant values may not hold
+// variables.
+func (p *parser) value(constant bool) any {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		p.next()
+		n, err := strconv.Atoi(tok.text)
+		if err != nil {
+			p.fail("integer %s out of range", tok.text)
+		}
+		return n
+	case tokFloat:
+		p.next()
+		f, _ := strconv.ParseFloat(tok.text, 64)
+		return f
+	case tokString:
+		p.next()
+		return tok.text
+	case tokName:
+		p.next()
+		switch tok.text {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return Enum(tok.text)
+	}
+	switch {
+	case p.skip("$"):
+		if constant {
+			p.fail("variables are not allowed here")
+		}
+		return Variable(p.name())
+	case p.skip("["):
+		list := []any{}
+		for !p.skip("]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case p.skip("{"):
+		obj := map[string]any{}
+		for !p.skip("}") {
+			name := p.name()
+			p.expect(":")
+			obj[name] = p.value(constant)
+		}
+		return obj
+	}
+	p.fail("unexpected %q", tok.text)
+	return nil
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokName {
+		p.fail("expected a name, found %q", p.tok.text)
+	}
+	name := p.tok.text
+	p.next()
+	return name
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == punct
+}
+
+func (p *parser) skip(punct string) bool {
+	if p.peek(punct) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) {
+	if !p.skip(punct) {
+		p.fail("expected %q, found %q", punct, p.tok.text)
+	}
+}
+
+// next reads the following token. Commas are insignificant, like white
+// space.
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	start := p.pos
+	p.tok = token{pos: start}
+	if p.pos >= len(p.src) {
+		p.tok.kind = tokEOF
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.text = tokPunct, "..."
+	case strings.IndexByte("!$()&:=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.text = tokPunct, string(c)
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.text = tokName, p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		p.number()
+	case c == '"':
+		p.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.tok.text = string(r)
+		p.fail("unexpected character %q", r)
+	}
+}
+
+func (p *parser) number() {
+	start := p.pos
+	p.tok.kind = tokInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		n := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == n {
+			p.tok.text = p.src[start:p.pos]
+			p.fail("malformed number")
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		p.tok.kind = tokFloat
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		p.tok.kind = tokFloat
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok.text = p.src[start:p.pos]
+}
+
+func (p *parser) string() {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.fail("unterminated string")
+		}
+		p.tok.kind, p.tok.text = tokString, p.src[p.pos+3:p.pos+3+end]
+		p.pos += end + 6
+		return
+	}
+
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		esc := p.src[p.pos+1]
+		p.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("bad unicode escape")
+			}
+			n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("bad unicode escape")
+			}
+			b.WriteRune(rune(n))
+			p.pos += 4
+		default:
+			p.fail("bad escape \\%c", esc)
+		}
+	}
+	p.tok.kind, p.tok.text = tokString, b.String()
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
@@ -0,0 +1,125 @@
+// Package health checks whether link destinations still load, on demand
+// for usage reports and periodically for the status page.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golinks/internal/store"
+)
+
+const (
+	// checkWorkers bounds concurrent destination checks; checkTimeout bounds
+	// one check and checkTotalTimeout all of them, after which unchecked
+	// links are left Unknown.
+	checkWorkers      = 8
+	checkTimeout      = 10 * time.Second
+	checkTotalTimeout = 2 * time.Minute
+)
+
+// Status is the outcome of checking one destination.
+type Status string
+
+const (
+	Healthy Status = "healthy"
+	Broken  Status = "broken"
+	// Unknown links have not been checked yet, or their check did not
+	// finish in time.
+	Unknown Status = "unknown"
+)
+
+// Result is the latest check of one link.
+type Result struct {
+	Slug   string `json:"slug"`
+	URL    string `json:"url"`
+	Status Status `json:"status"`
+	// Problem says why a Broken link failed, e.g. "HTTP 404".
+	Problem   string    `json:"problem,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// NewClient returns the HTTP client checks are made with.
+func NewClient() *http.Client {
+	return &http.Client{Timeout: checkTimeout}
+}
+
+// CheckLinks requests every destination with a bounded worker pool and
+// returns one result per link, in input order.
+func CheckLinks(ctx context.Context, client *http.Client, links []store.Link) []Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTotalTimeout)
+	defer cancel()
+
+	results := make([]Result, len(links))
+	for i, link := range links {
+		results[i] = Result{Slug: link.Slug, URL: link.URL, Status: Unknown}
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < checkWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				checkLink(ctx, client, &results[i])
+			}
+		}()
+	}
+	for i := range links {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// checkLink fills in whether r's destination loads. HEAD is tried first;
+// servers that reject it get a GET. Links still in flight when ctx ends
+// stay Unknown.
+func checkLink(ctx context.Context, client *http.Client, r *Result) {
+	if ctx.Err() != nil {
+		return
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	broken := func(problem string) {
+		r.Status, r.Problem, r.CheckedAt = Broken, problem, time.Now().UTC()
+	}
+	status := 0
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(reqCtx, method, r.URL, nil)
+		if err != nil {
+			broken("invalid URL")
+			return
+		}
+		resp, err := client.Do(req)
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			return
+		case reqCtx.Err() != nil:
+			broken("timed out")
+			return
+		default:
+			broken("unreachable")
+			return
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+	if status >= 400 {
+		broken(fmt.Sprintf("HTTP %d", status))
+		return
+	}
+	r.Status, r.CheckedAt = Healthy, time.Now().UTC()
+}
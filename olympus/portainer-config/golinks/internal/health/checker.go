@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"golinks/internal/store"
+)
+
+// Checker checks every active link and keeps the latest results in
+// memory.
+type Checker struct {
+	store    store.Store
+	client   *http.Client
+	interval time.Duration
+
+	mu        sync.RWMutex
+	results   map[string]Result
+	lastCheck time.Time
+}
+
+// NewChecker creates a Checker for the links in st, to be checked every
+// interval; zero means links are only checked on demand.
+func NewChecker(st store.Store, interval time.Duration) *Checker {
+	return &Checker{
+		store:    st,
+		client:   NewClient(),
+		interval: interval,
+		results:  make(map[string]Result),
+	}
+}
+
+// Interval returns how often the links are checked, zero if only on
+// demand.
+func (c *Checker) Interval() time.Duration {
+	return c.interval
+}
+
+// Check checks every active link once and replaces the previous results.
+func (c *Checker) Check(ctx context.Context) error {
+	var active []store.Link
+	err := c.store.EachLink(ctx, func(link store.Link) error {
+		if link.Status == store.StatusActive {
+			active = append(active, link)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	results := make(map[string]Result, len(active))
+	broken := 0
+	for _, r := range CheckLinks(ctx, c.client, active) {
+		results[r.Slug] = r
+		if r.Status == Broken {
+			broken++
+		}
+	}
+	slog.Info("Links checked", "links", len(active), "broken", broken, "took", time.Since(start).Round(time.Millisecond).String())
+
+	c.mu.Lock()
+	c.results = results
+	c.lastCheck = start.UTC()
+	c.mu.Unlock()
+	return nil
+}
+
+// Result returns the latest check of link, Unknown if it was not checked
+// since it was added or pointed elsewhere.
+func (c *Checker) Result(link store.Link) Result {
+	c.mu.RLock()
+	r, ok := c.results[link.Slug]
+	c.mu.RUnlock()
+	if !ok || r.URL != link.URL {
+		return Result{Slug: link.Slug, URL: link.URL, Status: Unknown}
+	}
+	return r
+}
+
+// FailoverActive reports whether link has a failover destination and the
+// latest check found its URL broken, so redirects go to the failover.
+func (c *Checker) FailoverActive(link store.Link) bool {
+	return link.Failover != "" && c.Result(link).Status == Broken
+}
+
+// Summary counts the links of each Status as of the last check.
+type Summary struct {
+	Healthy   int       `json:"healthy"`
+	Broken    int       `json:"broken"`
+	Unknown   int       `json:"unknown"`
+	LastCheck time.Time `json:"last_check"`
+}
+
+// Results returns the current state of every active link, in store
+// order. Links added or pointed elsewhere since the last check are
+// Unknown.
+func (c *Checker) Results(ctx context.Context) (Summary, []Result, error) {
+	// Check replaces the map rather than modifying it
+	c.mu.RLock()
+	results, lastCheck := c.results, c.lastCheck
+	c.mu.RUnlock()
+
+	sum := Summary{LastCheck: lastCheck}
+	var list []Result
+	err := c.store.EachLink(ctx, func(link store.Link) error {
+		if link.Status != store.StatusActive {
+			return nil
+		}
+		r, ok := results[link.Slug]
+		if !ok || r.URL != link.URL {
+			r = Result{Slug: link.Slug, URL: link.URL, Status: Unknown}
+		}
+		switch r.Status {
+		case Healthy:
+			sum.Healthy++
+		case Broken:
+			sum.Broken++
+		default:
+			sum.Unknown++
+		}
+		list = append(list, r)
+		return nil
+	})
+	if err != nil {
+		return Summary{}, nil, err
+	}
+	return sum, list, nil
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+// newDest returns a destination server where /gone is missing and HEAD
+// requests to /nohead are rejected.
+func newDest(t *testing.T) *httptest.Server {
+	t.Helper()
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/gone":
+			http.NotFound(w, r)
+		case r.URL.Path == "/nohead" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(dest.Close)
+	return dest
+}
+
+func TestCheckLinks(t *testing.T) {
+	dest := newDest(t)
+	links := []store.Link{
+		{Slug: "wiki", URL: dest.URL + "/wiki"},
+		{Slug: "gone", URL: dest.URL + "/gone"},
+		{Slug: "nohead", URL: dest.URL + "/nohead"},
+		{Slug: "down", URL: "http://127.0.0.1:1/"},
+	}
+
+	want := []struct {
+		status  Status
+		problem string
+	}{
+		{Healthy, ""},
+		{Broken, "HTTP 404"},
+		{Healthy, ""},
+		{Broken, "unreachable"},
+	}
+	results := CheckLinks(context.Background(), NewClient(), links)
+	for i, r := range results {
+		if r.Slug != links[i].Slug || r.Status != want[i].status || r.Problem != want[i].problem {
+			t.Errorf("result %d = %+v, want %s %q", i, r, want[i].status, want[i].problem)
+		}
+		if r.CheckedAt.IsZero() {
+			t.Errorf("result %d has no check time", i)
+		}
+	}
+}
+
+func TestCheckLinksCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := CheckLinks(ctx, NewClient(), []store.Link{{Slug: "wiki", URL: newDest(t).URL}})
+	if results[0].Status != Unknown {
+		t.Errorf("status = %s, want unknown for an unfinished check", results[0].Status)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	dest := newDest(t)
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: dest.URL + "/wiki"})
+	st.AddLink(ctx, store.Link{Slug: "gone", URL: dest.URL + "/gone"})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: dest.URL + "/gone", Status: store.StatusPending})
+
+	c := NewChecker(st, 0)
+	sum, _, err := c.Results(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != (Summary{Unknown: 2}) {
+		t.Errorf("summary before the first check = %+v", sum)
+	}
+
+	if err := c.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Links added or changed since the check are unknown again
+	st.AddLink(ctx, store.Link{Slug: "new", URL: dest.URL})
+	st.UpdateLink(ctx, store.Link{Slug: "gone", URL: dest.URL + "/moved"})
+
+	sum, results, err := c.Results(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Healthy != 1 || sum.Broken != 0 || sum.Unknown != 2 || sum.LastCheck.IsZero() {
+		t.Errorf("summary = %+v, want 1 healthy, 2 unknown", sum)
+	}
+	if len(results) != 3 {
+		t.Errorf("results = %+v, want the 3 active links", results)
+	}
+}
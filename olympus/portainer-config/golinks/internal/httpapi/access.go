@@ -0,0 +1,315 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// maxAccessRules bounds the rules of one link.
+const maxAccessRules = 32
+
+// weekdays maps the day names accepted in access rules.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// window is a parsed store.AccessRule: the days it starts on and its start
+// and end as minutes after midnight.
+type window struct {
+	days     [7]bool
+	from, to int
+}
+
+// parseRule validates rule and returns its window.
+func parseRule(rule store.AccessRule) (window, error) {
+	var w window
+	var err error
+	if w.days, err = parseDays(rule.Days); err != nil {
+		return window{}, err
+	}
+	if w.from, err = parseClock(rule.From); err != nil {
+		return window{}, fmt.Errorf("from: %w", err)
+	}
+	if w.to, err = parseClock(rule.To); err != nil {
+		return window{}, fmt.Errorf("to: %w", err)
+	}
+	if w.from == w.to {
+		return window{}, fmt.Errorf("from and to must differ")
+	}
+	return w, nil
+}
+
+// parseDays parses a list such as "mon-thu,sun". Ranges may wrap around
+// the week ("fri-mon"); empty means every day.
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+	if strings.TrimSpace(s) == "" {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+	for _, part := range strings.Split(strings.ToLower(s), ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, ok := weekdays[strings.TrimSpace(first)]
+		if !ok {
+			return days, fmt.Errorf("unknown day %q", part)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdays[strings.TrimSpace(last)]; !ok {
+				return days, fmt.Errorf("unknown day %q", part)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight. "24:00" is
+// accepted as the end of the day.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hours, errH := strconv.Atoi(h)
+	minutes, errM := strconv.Atoi(m)
+	if !ok || errH != nil || errM != nil || len(m) != 2 || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// contains reports whether t falls inside the window. A window that ends
+// before it starts runs past midnight, so it is also checked against the
+// previous day.
+func (w window) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.from < w.to {
+		return w.days[t.Weekday()] && minute >= w.from && minute < w.to
+	}
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.days[t.Weekday()] && minute >= w.from) || (w.days[yesterday] && minute < w.to)
+}
+
+// nextOpen returns the next time after t the window starts.
+func (w window) nextOpen(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i <= 7; i++ {
+		day := midnight.AddDate(0, 0, i)
+		start := day.Add(time.Duration(w.from) * time.Minute)
+		if w.days[day.Weekday()] && start.After(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// accessAt reports whether a client in groups may open link at now. Only
+// rules of the client's groups apply, and a client without any applying
+// rule is not restricted. If access is denied, opens is when the next
+// allowed window starts.
+func accessAt(link store.Link, groups []string, now time.Time) (allowed bool, opens time.Time) {
+	restricted := false
+	for _, rule := range link.Access {
+		if !slices.Contains(groups, rule.Group) {
+			continue
+		}
+		w, err := parseRule(rule)
+		if err != nil {
+			// Rules are validated when set, so this is a hand-edited
+			// database. Failing closed keeps the restriction in place.
+			slog.Warn("Invalid access rule, denying access", "slug", link.Slug, "error", err)
+			restricted = true
+			continue
+		}
+		restricted = true
+		if w.contains(now) {
+			return true, time.Time{}
+		}
+		if next := w.nextOpen(now); opens.IsZero() || next.Before(opens) {
+			opens = next
+		}
+	}
+	return !restricted, opens
+}
+
+// clientGroups returns the access groups the client of r belongs to.
+func (s *Server) clientGroups(r *http.Request) []string {
+	if imp, ok := ImpersonationFrom(r.Context()); ok && imp.Group != "" {
+		return []string{imp.Group}
+	}
+	if len(s.cfg.AccessGroups) == 0 {
+		return nil
+	}
+	ip, ok := s.clientIP(r)
+	if !ok {
+		return nil
+	}
+	var groups []string
+	for name, prefixes := range s.cfg.AccessGroups {
+		for _, prefix := range prefixes {
+			if prefix.Contains(ip) {
+				groups = append(groups, name)
+				break
+			}
+		}
+	}
+	return groups
+}
+
+// clientIP returns the address of the client of r. Requests from a trusted
+// proxy are attributed to the last X-Forwarded-For entry that is not a
+// trusted proxy itself.
+func (s *Server) clientIP(r *http.Request) (netip.Addr, bool) {
+	ip, ok := parseClientAddr(r.RemoteAddr)
+	if !ok || !s.trustedProxy(ip) {
+		return ip, ok
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseClientAddr(hops[i])
+		if !ok {
+			break
+		}
+		ip = hop
+		if !s.trustedProxy(ip) {
+			break
+		}
+	}
+	return ip, true
+}
+
+// remote names the client of r in logs: its address as clientIP finds it,
+// or else RemoteAddr as it is.
+func (s *Server) remote(r *http.Request) string {
+	if ip, ok := s.clientIP(r); ok {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// parseClientAddr parses a client address as RemoteAddr or an
+// X-Forwarded-For entry has it: "192.0.2.7:1234", "[2001:db8::7]:1234", or
+// either without a port, with or without brackets. On a dual-stack listener
+// the same client can show up in several forms, so IPv4-mapped IPv6
+// addresses are returned as IPv4, and the zone of a link-local address,
+// which no network matches, is dropped.
+func parseClientAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	ip, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(s)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		ip = addrPort.Addr()
+	}
+	return ip.Unmap().WithZone(""), true
+}
+
+// clientNetwork names the client at ip for per-client quotas: by its
+// address if IPv4, or by its /64 if IPv6, within which a host picks new
+// addresses at will.
+func clientNetwork(ip netip.Addr) string {
+	if ip.Is4() {
+		return ip.String()
+	}
+	prefix, _ := ip.Prefix(64)
+	return prefix.String()
+}
+
+func (s *Server) trustedProxy(ip netip.Addr) bool {
+	for _, prefix := range s.cfg.TrustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAccessRequest replaces the access rules of a link; no rules lifts all
+// restrictions.
+type SetAccessRequest struct {
+	Slug  string             `json:"slug"`
+	Rules []store.AccessRule `json:"rules"`
+}
+
+// checkAccess validates access rules, trimming their groups in place, and
+// returns an *InvalidError for the first bad one.
+func (s *Server) checkAccess(rules []store.AccessRule) error {
+	if len(rules) > maxAccessRules {
+		return &InvalidError{Msg: fmt.Sprintf("At most %d access rules per link", maxAccessRules), Field: "access"}
+	}
+	for i, rule := range rules {
+		rule.Group = strings.TrimSpace(rule.Group)
+		if _, ok := s.cfg.AccessGroups[rule.Group]; !ok {
+			return &InvalidError{Msg: fmt.Sprintf("Rule %d: unknown access group %q", i+1, rule.Group), Field: "access"}
+		}
+		if _, err := parseRule(rule); err != nil {
+			return &InvalidError{Msg: fmt.Sprintf("Rule %d: %v", i+1, err), Field: "access"}
+		}
+		rules[i] = rule
+	}
+	return nil
+}
+
+func (s *Server) handleAdminAccess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	if err := s.checkAccess(req.Rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetAccess(r.Context(), req.Slug, req.Rules); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Access rules set", "slug", req.Slug, "rules", len(req.Rules), "remote_addr", s.remote(r))
+
+	if req.Rules == nil {
+		req.Rules = []store.AccessRule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": "updated",
+		"slug":   req.Slug,
+		"rules":  req.Rules,
+	})
+}
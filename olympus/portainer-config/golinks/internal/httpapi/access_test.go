@@ -0,0 +1,173 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestAccessWindow(t *testing.T) {
+	// 2024-05-03 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		rule store.AccessRule
+		now  time.Time
+		want bool
+	}{
+		{"inside", store.AccessRule{Days: "fri-sat", From: "15:00", To: "21:00"}, at(3, 16, 0), true},
+		{"at start", store.AccessRule{Days: "fri", From: "15:00", To: "21:00"}, at(3, 15, 0), true},
+		{"at end", store.AccessRule{Days: "fri", From: "15:00", To: "21:00"}, at(3, 21, 0), false},
+		{"wrong day", store.AccessRule{Days: "sat,sun", From: "15:00", To: "21:00"}, at(3, 16, 0), false},
+		{"every day", store.AccessRule{From: "00:00", To: "24:00"}, at(1, 23, 59), true},
+		{"wrapping week", store.AccessRule{Days: "fri-mon", From: "08:00", To: "09:00"}, at(6, 8, 30), true},
+		{"past midnight", store.AccessRule{Days: "fri", From: "22:00", To: "01:00"}, at(4, 0, 30), true},
+		{"past midnight wrong day", store.AccessRule{Days: "sat", From: "22:00", To: "01:00"}, at(4, 0, 30), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := parseRule(tt.rule)
+			if err != nil {
+				t.Fatalf("parseRule: %v", err)
+			}
+			if got := w.contains(tt.now); got != tt.want {
+				t.Errorf("contains(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []store.AccessRule{
+		{Days: "someday", From: "15:00", To: "21:00"},
+		{From: "25:00", To: "21:00"},
+		{From: "15:0", To: "21:00"},
+		{From: "15:00", To: "15:00"},
+	} {
+		if _, err := parseRule(bad); err == nil {
+			t.Errorf("parseRule(%+v) accepted", bad)
+		}
+	}
+}
+
+func TestAccessAt(t *testing.T) {
+	link := store.Link{Slug: "games", Access: []store.AccessRule{
+		{Group: "kids", Days: "fri-sat", From: "15:00", To: "21:00"},
+		{Group: "kids", Days: "sun", From: "10:00", To: "12:00"},
+	}}
+	thursday := time.Date(2024, 5, 2, 16, 0, 0, 0, time.UTC)
+
+	if ok, _ := accessAt(link, nil, thursday); !ok {
+		t.Error("client outside every group was restricted")
+	}
+	ok, opens := accessAt(link, []string{"kids"}, thursday)
+	if ok || !opens.Equal(time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("accessAt(kids, Thursday) = %v, %v; want denied until Friday 15:00", ok, opens)
+	}
+	ok, opens = accessAt(link, []string{"kids"}, time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC))
+	if ok || !opens.Equal(time.Date(2024, 5, 5, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("accessAt(kids, Saturday night) = %v, %v; want denied until Sunday 10:00", ok, opens)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	s, _ := newTestServer(t, Config{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")}})
+	tests := []struct {
+		remote, forwarded, want string
+	}{
+		{"192.168.20.5:1234", "", "192.168.20.5"},
+		{"192.168.20.5:1234", "1.2.3.4", "192.168.20.5"}, // untrusted peers cannot spoof
+		{"10.0.0.2:1234", "192.168.20.5", "192.168.20.5"},
+		{"10.0.0.2:1234", "1.2.3.4, 192.168.20.5, 10.0.0.3", "192.168.20.5"},
+		{"10.0.0.2:1234", "", "10.0.0.2"},
+		{"[::ffff:192.168.20.5]:1234", "", "192.168.20.5"},
+		{"[fd00::5]:1234", "", "fd00::5"},
+		{"[fe80::5%eth0]:1234", "", "fe80::5"},
+		{"fd00::5", "", "fd00::5"},
+		{"[fd00::5]", "", "fd00::5"},
+		{"192.168.20.5", "", "192.168.20.5"},
+		{"[::ffff:10.0.0.2]:1234", "[fd00::7]:5555, 10.0.0.3", "fd00::7"},
+		{"10.0.0.2:1234", "::ffff:192.168.20.5", "192.168.20.5"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got, ok := s.clientIP(r); !ok || got.String() != tt.want {
+			t.Errorf("clientIP(%s, %q) = %v, want %s", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+}
+
+func TestClientNetwork(t *testing.T) {
+	for ip, want := range map[string]string{
+		"192.168.20.5":            "192.168.20.5",
+		"2001:db8:1:2:aaaa::1":    "2001:db8:1:2::/64",
+		"2001:db8:1:2:bbbb::9999": "2001:db8:1:2::/64",
+		"fe80::1":                 "fe80::/64",
+	} {
+		if got := clientNetwork(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("clientNetwork(%s) = %s, want %s", ip, got, want)
+		}
+	}
+}
+
+func TestAccessRedirect(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{AccessGroups: map[string][]netip.Prefix{
+		"kids": {netip.MustParsePrefix("192.168.20.0/24")},
+	}})
+	st.AddLink(ctx, store.Link{Slug: "games", URL: "https://games.example.com"})
+
+	rec := do(t, s, http.MethodPost, "/admin/access", SetAccessRequest{Slug: "games", Rules: []store.AccessRule{{Group: "nobody", From: "00:00", To: "01:00"}}}, "", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown group: status = %d", rec.Code)
+	}
+	rec = do(t, s, http.MethodPost, "/admin/access", SetAccessRequest{Slug: "games", Rules: []store.AccessRule{{Group: "kids", From: "9:00", To: "noon"}}}, "", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid time: status = %d", rec.Code)
+	}
+	rec = do(t, s, http.MethodPost, "/admin/access", SetAccessRequest{Slug: "missing", Rules: nil}, "", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing link: status = %d", rec.Code)
+	}
+
+	// A one-minute window two minutes from now is closed during the test
+	now := time.Now()
+	from := now.Add(2 * time.Minute).Format("15:04")
+	to := now.Add(3 * time.Minute).Format("15:04")
+	rec = do(t, s, http.MethodPost, "/admin/access", SetAccessRequest{Slug: "games", Rules: []store.AccessRule{{Group: "kids", From: from, To: to}}}, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("set access: status = %d (%s)", rec.Code, rec.Body)
+	}
+
+	get := func(remote string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/games", nil)
+		r.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, r)
+		return rec
+	}
+	if rec := get("192.168.20.7:5000"); rec.Code != http.StatusForbidden {
+		t.Errorf("kids outside window: status = %d", rec.Code)
+	}
+	if rec := get("192.168.1.7:5000"); rec.Code != http.StatusFound {
+		t.Errorf("other client: status = %d", rec.Code)
+	}
+
+	// Clearing the rules lifts the restriction
+	rec = do(t, s, http.MethodPost, "/admin/access", SetAccessRequest{Slug: "games"}, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("clear access: status = %d", rec.Code)
+	}
+	if rec := get("192.168.20.7:5000"); rec.Code != http.StatusFound {
+		t.Errorf("kids after clearing: status = %d", rec.Code)
+	}
+}
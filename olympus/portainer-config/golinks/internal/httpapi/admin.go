@@ -0,0 +1,850 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+type AddLinkRequest struct {
+	Slug   string `json:"slug"`
+	URL    string `json:"url"`
+	Public bool   `json:"public,omitempty"`
+	// SlugStrategy generates the slug when Slug is empty, overriding
+	// Config.SlugStrategy. Title is used by SlugTitle.
+	SlugStrategy string `json:"slug_strategy,omitempty"`
+	Title        string `json:"title,omitempty"`
+
+	// The settings below are stored together with the link, as they would
+	// be by the single-setting endpoints afterwards. Aliases are more slugs
+	// leading to the link; Collections, which must exist, get the link
+	// added at their end.
+	Aliases      []string           `json:"aliases,omitempty"`
+	Collections  []string           `json:"collections,omitempty"`
+	Access       []store.AccessRule `json:"access,omitempty"`
+	ReviewAt     string             `json:"review_at,omitempty"`
+	ReviewMonths int                `json:"review_months,omitempty"`
+	Pin          int                `json:"pin,omitempty"`
+	HitBudget    int                `json:"hit_budget,omitempty"`
+	Failover     string             `json:"failover,omitempty"`
+	Referrer     string             `json:"referrer,omitempty"`
+	// Fields are custom fields, by name; see Config.LinkFields.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type UpdateLinkRequest struct {
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+}
+
+type RenameLinkRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Alias keeps From redirecting to the link, so old bookmarks work.
+	Alias bool `json:"alias"`
+}
+
+type RemoveLinkRequest struct {
+	Slug string `json:"slug"`
+}
+
+type ApproveLinkRequest struct {
+	Slug string `json:"slug"`
+	// URL is the destination the approver reviewed. The link is only
+	// approved while it still points there.
+	URL string `json:"url"`
+}
+
+type SetPublicRequest struct {
+	Slug   string `json:"slug"`
+	Public bool   `json:"public"`
+}
+
+type SetPinRequest struct {
+	Slug string `json:"slug"`
+	// Pin places the link in the pinned index order, higher first; 0
+	// unpins it.
+	Pin int `json:"pin"`
+}
+
+type SetHitBudgetRequest struct {
+	Slug string `json:"slug"`
+	// HitsPerDay is the most redirects a day expected; more raise an
+	// alert. 0 removes the budget.
+	HitsPerDay int `json:"hits_per_day"`
+}
+
+type SetFailoverRequest struct {
+	Slug string `json:"slug"`
+	// URL is where the link leads while its destination is found broken;
+	// "" removes the failover.
+	URL string `json:"url"`
+}
+
+type SetReferrerRequest struct {
+	Slug string `json:"slug"`
+	// Policy is "strip" to send the destination no Referer, "replace" to
+	// send the golinks origin instead, or "pass" (or "") to leave it to the
+	// browser.
+	Policy string `json:"policy"`
+}
+
+type SetReviewRequest struct {
+	Slug string `json:"slug"`
+	// ReviewAt is a date (2006-01-02, midnight server time) or an RFC 3339
+	// time. Empty clears the reminder.
+	ReviewAt string `json:"review_at"`
+	// ReviewMonths repeats the reminder; 0 reminds once.
+	ReviewMonths int `json:"review_months"`
+}
+
+// maxReviewMonths bounds the review interval at ten years.
+const maxReviewMonths = 120
+
+// InvalidError rejects a link change. Its message is meant for the admin
+// who made the request.
+type InvalidError struct {
+	Msg string
+	// Field is the request field at fault, if one is, as named in JSON.
+	Field string
+}
+
+func (e *InvalidError) Error() string { return e.Msg }
+
+// AddLink validates and stores a new link on behalf of createdBy, the admin
+// name ("" without authentication). Without a slug, one is generated if a
+// slug strategy is configured or requested. Links to sensitive
+// destinations are stored pending and posted to chat, if configured. The
+// destination is snapshotted if snapshots are enabled. Validation failures
+// are returned as *InvalidError.
+//
+// The link, its settings, aliases and collections are validated up front
+// and then stored in one transaction, so a failure leaves nothing behind.
+// Only the follow-ups after the commit, chat and snapshot, may fail on
+// their own; they are logged and do not fail the call.
+func (s *Server) AddLink(ctx context.Context, req AddLinkRequest, createdBy string) (store.Link, error) {
+	if strings.TrimSpace(req.Slug) == "" {
+		if strategy := cmp.Or(req.SlugStrategy, s.cfg.SlugStrategy); strategy != "" {
+			link, err := s.addGenerated(ctx, req, strategy, createdBy)
+			if err == nil {
+				s.saved(link)
+			}
+			return link, err
+		}
+	}
+
+	slug := canonicalSlug(strings.TrimSpace(req.Slug))
+	if err := s.checkNewSlug(slug, createdBy); err != nil {
+		return store.Link{}, err
+	}
+
+	link, err := s.destination(slug, req.URL, createdBy)
+	if err != nil {
+		return store.Link{}, err
+	}
+	aliases, err := s.newLinkSettings(ctx, req, &link)
+	if err != nil {
+		return store.Link{}, err
+	}
+	if err := s.createLink(ctx, link, aliases, req.Collections); err != nil {
+		return store.Link{}, err
+	}
+	s.saved(link)
+	return link, nil
+}
+
+// newLinkSettings validates the settings of req and sets them on link. It
+// returns the canonical aliases.
+func (s *Server) newLinkSettings(ctx context.Context, req AddLinkRequest, link *store.Link) ([]string, error) {
+	link.Public = req.Public
+	if err := s.checkAccess(req.Access); err != nil {
+		return nil, err
+	}
+	link.Access = req.Access
+	at, err := parseReview(req.ReviewAt, req.ReviewMonths)
+	if err != nil {
+		return nil, err
+	}
+	link.ReviewAt, link.ReviewMonths = at, req.ReviewMonths
+	if req.Pin < 0 {
+		return nil, &InvalidError{Msg: "pin must not be negative", Field: "pin"}
+	}
+	link.Pin = req.Pin
+	if req.HitBudget < 0 {
+		return nil, &InvalidError{Msg: "hit_budget must not be negative", Field: "hit_budget"}
+	}
+	link.HitBudget = req.HitBudget
+	link.Failover = strings.TrimSpace(req.Failover)
+	if err := s.CheckFailover(link.Failover); err != nil {
+		return nil, err
+	}
+	if link.Referrer, err = referrerPolicy(req.Referrer); err != nil {
+		return nil, err
+	}
+	if link.Fields, err = s.checkFields(req.Fields); err != nil {
+		return nil, err
+	}
+
+	// Taken aliases and missing collections are reported here by name; the
+	// store catches whatever changes before the transaction
+	var aliases []string
+	for _, alias := range req.Aliases {
+		alias = canonicalSlug(strings.TrimSpace(alias))
+		if err := s.checkNewSlug(alias, link.CreatedBy); err != nil {
+			return nil, &InvalidError{Msg: "Alias " + alias + ": " + err.Error(), Field: "aliases"}
+		}
+		if alias == link.Slug || slices.Contains(aliases, alias) {
+			return nil, &InvalidError{Msg: "Alias " + alias + " is given twice", Field: "aliases"}
+		}
+		if _, err := s.store.GetLink(ctx, alias); err == nil {
+			return nil, &InvalidError{Msg: "Alias " + alias + " is taken by a link", Field: "aliases"}
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		if _, err := s.store.ResolveAlias(ctx, alias); err == nil {
+			return nil, &InvalidError{Msg: "Alias " + alias + " is taken by another alias", Field: "aliases"}
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	for _, name := range req.Collections {
+		if _, err := s.store.GetCollection(ctx, name); errors.Is(err, store.ErrNotFound) {
+			return nil, &InvalidError{Msg: "Unknown collection " + name, Field: "collections"}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return aliases, nil
+}
+
+// createLink stores a new link with its aliases and collections in one
+// transaction.
+func (s *Server) createLink(ctx context.Context, link store.Link, aliases, collections []string) error {
+	err := s.store.CreateLink(ctx, link, aliases, collections)
+	if errors.Is(err, store.ErrNotFound) {
+		return &InvalidError{Msg: "A collection was removed while the link was added", Field: "collections"}
+	}
+	return err
+}
+
+// checkNewSlug validates the canonical slug of a link about to be added or
+// reserved by createdBy.
+func (s *Server) checkNewSlug(slug, createdBy string) error {
+	if !isValidSlug(slug) {
+		return &InvalidError{Msg: "Invalid slug", Field: "slug"}
+	}
+	if s.containsBannedWord(slug) {
+		slog.Warn("Rejected slug with banned word", "slug", slug, "user", createdBy)
+		return &InvalidError{Msg: "Slug contains a banned word", Field: "slug"}
+	}
+	return nil
+}
+
+// UpdateLink points an existing slug at a new destination on behalf of
+// changedBy. A sensitive destination puts the link back into pending, to be
+// approved by an admin other than changedBy. Reserved slugs are filled by
+// claiming them instead.
+func (s *Server) UpdateLink(ctx context.Context, slug, url, changedBy string) (store.Link, error) {
+	link, err := s.destination(canonicalSlug(strings.TrimSpace(slug)), url, changedBy)
+	if err != nil {
+		return store.Link{}, err
+	}
+	existing, err := s.store.GetLink(ctx, link.Slug)
+	if err != nil {
+		return store.Link{}, err
+	}
+	if existing.Status == store.StatusReserved {
+		return store.Link{}, &InvalidError{Msg: "Slug is reserved; claim it to set its destination", Field: "slug"}
+	}
+	if err := s.store.UpdateLink(ctx, link); err != nil {
+		return store.Link{}, err
+	}
+	s.saved(link)
+	return link, nil
+}
+
+// saved follows up on link being added or pointed at a new destination: it
+// is posted to chat if it waits for approval, and its destination is
+// snapshotted.
+func (s *Server) saved(link store.Link) {
+	s.propose(link)
+	if s.cfg.Snapshots != nil {
+		s.cfg.Snapshots.Take(link)
+	}
+}
+
+// RenameLink moves the link at from to the new slug to, which must be
+// valid and free, on behalf of changedBy. With alias, from keeps
+// redirecting to the link.
+func (s *Server) RenameLink(ctx context.Context, from, to string, alias bool, changedBy string) error {
+	from = canonicalSlug(strings.TrimSpace(from))
+	to = canonicalSlug(strings.TrimSpace(to))
+	if from == "" || from == "admin" || !isValidSlug(to) {
+		return &InvalidError{Msg: "Invalid slug", Field: "to"}
+	}
+	if from == to {
+		return &InvalidError{Msg: "The new slug is the same as the old one", Field: "to"}
+	}
+	if s.containsBannedWord(to) {
+		slog.WarnContext(ctx, "Rejected slug with banned word", "slug", to, "user", changedBy)
+		return &InvalidError{Msg: "Slug contains a banned word", Field: "to"}
+	}
+	return s.store.RenameLink(ctx, from, to, alias)
+}
+
+// RemoveLink deletes a link and returns the slug it was stored under.
+func (s *Server) RemoveLink(ctx context.Context, slug string) (string, error) {
+	raw := strings.TrimSpace(slug)
+	slug = canonicalSlug(raw)
+	if slug == "" || slug == "admin" {
+		return "", &InvalidError{Msg: "Invalid slug", Field: "slug"}
+	}
+
+	err := s.store.RemoveLink(ctx, slug)
+	if errors.Is(err, store.ErrNotFound) && raw != slug {
+		// Slugs the emoji migration could not rewrite keep their original form
+		slug = raw
+		err = s.store.RemoveLink(ctx, slug)
+	}
+	return slug, err
+}
+
+// destination validates a link's URL and decides whether it needs approval.
+func (s *Server) destination(slug, url, admin string) (store.Link, error) {
+	url = strings.TrimSpace(url)
+	if !isValidURL(url) {
+		return store.Link{}, &InvalidError{Msg: "Invalid URL - must start with http:// or https://", Field: "url"}
+	}
+
+	// Sensitive destinations wait for a second admin
+	status := store.StatusActive
+	if s.isSensitiveURL(url) {
+		status = store.StatusPending
+	}
+	return store.Link{Slug: slug, URL: url, Status: status, CreatedBy: admin}, nil
+}
+
+// writeLinkError responds to a failed AddLink, UpdateLink or RenameLink.
+func writeLinkError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid *InvalidError
+	if errors.As(err, &invalid) {
+		writeInvalid(w, invalid)
+		return
+	}
+	slog.ErrorContext(r.Context(), "Error saving link", "error", err)
+	httperr.Write(w, err)
+}
+
+func (s *Server) handleAdminAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AddLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.AddLink(r.Context(), req, s.adminName(r))
+	if err != nil {
+		writeLinkError(w, r, err)
+		return
+	}
+
+	if link.Status == store.StatusPending {
+		slog.InfoContext(r.Context(), "Link pending approval", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "pending",
+			"slug":   link.Slug,
+			"url":    link.URL,
+		})
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Link added", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "created",
+		"slug":   link.Slug,
+		"url":    link.URL,
+	})
+}
+
+// handleAdminUpdate points an existing link at a new URL, keeping its
+// creation time, clicks and other settings.
+func (s *Server) handleAdminUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req UpdateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if slug := canonicalSlug(strings.TrimSpace(req.Slug)); slug == "" || slug == "admin" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.UpdateLink(r.Context(), req.Slug, req.URL, s.adminName(r))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			httperr.Write(w, err)
+			return
+		}
+		writeLinkError(w, r, err)
+		return
+	}
+
+	status, code := "updated", http.StatusOK
+	if link.Status == store.StatusPending {
+		status, code = "pending", http.StatusAccepted
+		slog.InfoContext(r.Context(), "Link pending approval", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	} else {
+		slog.InfoContext(r.Context(), "Link updated", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": status,
+		"slug":   link.Slug,
+		"url":    link.URL,
+	})
+}
+
+func (s *Server) handleAdminRename(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RenameLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.RenameLink(r.Context(), req.From, req.To, req.Alias, s.adminName(r)); err != nil {
+		writeLinkError(w, r, err)
+		return
+	}
+	from, to := canonicalSlug(strings.TrimSpace(req.From)), canonicalSlug(strings.TrimSpace(req.To))
+
+	slog.InfoContext(r.Context(), "Link renamed", "slug", to, "from", from, "alias", req.Alias, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": "renamed",
+		"from":   from,
+		"to":     to,
+		"alias":  req.Alias,
+	})
+}
+
+func (s *Server) handleAdminRemove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RemoveLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	slug, err := s.RemoveLink(r.Context(), req.Slug)
+	if errors.As(err, new(*InvalidError)) {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error removing link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	req.Slug = slug
+
+	slog.InfoContext(r.Context(), "Link removed", "slug", req.Slug, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "removed",
+		"slug":   req.Slug,
+	})
+}
+
+func (s *Server) handleAdminApprove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ApproveLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" || req.Slug == "admin" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	if req.URL == "" {
+		http.Error(w, "URL is required", http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.store.GetLink(r.Context(), req.Slug)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if link.Status != store.StatusPending {
+		http.Error(w, "Link is not pending approval", http.StatusConflict)
+		return
+	}
+	if link.URL != req.URL {
+		http.Error(w, "Link changed since it was reviewed", http.StatusConflict)
+		return
+	}
+
+	// The approver must be a different admin than the creator. Without
+	// authentication configured there is no way to tell admins apart.
+	approver := s.adminName(r)
+	if approver != "" && approver == link.CreatedBy {
+		http.Error(w, "Link must be approved by a different admin", http.StatusForbidden)
+		return
+	}
+
+	// Only the reviewed destination is approved; if the link was replaced
+	// or approved in the meantime the store reports a conflict.
+	if err := s.store.ApproveLink(r.Context(), link.Slug, req.URL, approver); err != nil {
+		slog.ErrorContext(r.Context(), "Error approving link", "error", err)
+		if errors.Is(err, store.ErrConflict) {
+			http.Error(w, "Link changed while being approved", http.StatusConflict)
+			return
+		}
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Link approved", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "approved",
+		"slug":   link.Slug,
+		"url":    link.URL,
+	})
+}
+
+func (s *Server) handleAdminPublic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetPublicRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetPublic(r.Context(), req.Slug, req.Public); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Link visibility set", "slug", req.Slug, "public", req.Public, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": "updated",
+		"slug":   req.Slug,
+		"public": req.Public,
+	})
+}
+
+func (s *Server) handleAdminPin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetPinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	if req.Pin < 0 {
+		http.Error(w, "pin must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetPin(r.Context(), req.Slug, req.Pin); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Link pinned", "slug", req.Slug, "pin", req.Pin, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": "updated",
+		"slug":   req.Slug,
+		"pin":    req.Pin,
+	})
+}
+
+func (s *Server) handleAdminBudget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetHitBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	if req.HitsPerDay < 0 {
+		http.Error(w, "hits_per_day must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetHitBudget(r.Context(), req.Slug, req.HitsPerDay); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Hit budget set", "slug", req.Slug, "hits_per_day", req.HitsPerDay, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":       "updated",
+		"slug":         req.Slug,
+		"hits_per_day": req.HitsPerDay,
+	})
+}
+
+// handleAdminFailover sets or removes the backup destination of a link.
+func (s *Server) handleAdminFailover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetFailoverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if err := s.CheckFailover(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetFailover(r.Context(), req.Slug, req.URL); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	if req.URL == "" {
+		slog.InfoContext(r.Context(), "Failover removed", "slug", req.Slug, "remote_addr", s.remote(r))
+	} else {
+		slog.InfoContext(r.Context(), "Failover set", "slug", req.Slug, "url", req.URL, "remote_addr", s.remote(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "updated",
+		"slug":   req.Slug,
+		"url":    req.URL,
+	})
+}
+
+// CheckFailover validates a failover destination, "" meaning none, and
+// returns an *InvalidError if it may not be used.
+func (s *Server) CheckFailover(url string) error {
+	if url == "" {
+		return nil
+	}
+	if !isValidURL(url) {
+		return &InvalidError{Msg: "Invalid URL - must start with http:// or https://", Field: "failover"}
+	}
+	// The failover is used without review, so it may not be somewhere a
+	// link would need approval to go
+	if s.isSensitiveURL(url) {
+		return &InvalidError{Msg: "Failover destination needs approval; use it as the link's URL instead", Field: "failover"}
+	}
+	return nil
+}
+
+// referrerPolicy validates a referrer policy as requested and returns it as
+// stored: "pass" and "" leave the Referer to the browser.
+func referrerPolicy(policy string) (string, error) {
+	switch policy {
+	case "", "pass":
+		return "", nil
+	case store.ReferrerStrip, store.ReferrerReplace:
+		return policy, nil
+	}
+	return "", &InvalidError{Msg: "Policy must be pass, strip or replace"}
+}
+
+// handleAdminReferrer sets what the destination of a link is told of the
+// page the link was opened from.
+func (s *Server) handleAdminReferrer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetReferrerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	policy, err := referrerPolicy(req.Policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if policy == "" {
+		req.Policy = "pass"
+	}
+
+	if err := s.store.SetReferrer(r.Context(), req.Slug, policy); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Referrer policy set", "slug", req.Slug, "policy", req.Policy, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "updated",
+		"slug":   req.Slug,
+		"policy": req.Policy,
+	})
+}
+
+// parseReview validates a review reminder: a date (midnight server time) or
+// an RFC 3339 time, "" for none, repeated every months.
+func parseReview(reviewAt string, months int) (*time.Time, error) {
+	if months < 0 || months > maxReviewMonths {
+		return nil, &InvalidError{Msg: "review_months must be between 0 and 120", Field: "review_months"}
+	}
+	if reviewAt == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, reviewAt, time.Local)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, reviewAt); err != nil {
+			return nil, &InvalidError{Msg: "review_at must be a date (YYYY-MM-DD) or RFC 3339 time", Field: "review_at"}
+		}
+	}
+	return &t, nil
+}
+
+func (s *Server) handleAdminReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	at, err := parseReview(req.ReviewAt, req.ReviewMonths)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetReview(r.Context(), req.Slug, at, req.ReviewMonths); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	if at == nil {
+		slog.InfoContext(r.Context(), "Review reminder cleared", "slug", req.Slug, "remote_addr", s.remote(r))
+	} else {
+		slog.InfoContext(r.Context(), "Review scheduled", "slug", req.Slug, "due", at.Format(time.RFC3339), "every_months", req.ReviewMonths, "remote_addr", s.remote(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":        "updated",
+		"slug":          req.Slug,
+		"review_at":     at,
+		"review_months": req.ReviewMonths,
+	})
+}
@@ -0,0 +1,526 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+var twoAdmins = Config{Admins: map[string]string{"alice": "pw1", "bob": "pw2"}}
+
+func TestAdminAdd(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{BannedWords: []string{"Bad-Word"}})
+
+	tests := []struct {
+		name string
+		body any
+		want int
+	}{
+		{"valid", AddLinkRequest{Slug: "wiki", URL: "https://wiki.example.com"}, http.StatusCreated},
+		{"duplicate", AddLinkRequest{Slug: "wiki", URL: "https://other.example.com"}, http.StatusConflict},
+		{"empty slug", AddLinkRequest{Slug: "  ", URL: "https://wiki.example.com"}, http.StatusBadRequest},
+		{"reserved slug", AddLinkRequest{Slug: "admin", URL: "https://wiki.example.com"}, http.StatusBadRequest},
+		{"banned word", AddLinkRequest{Slug: "my-badword", URL: "https://wiki.example.com"}, http.StatusBadRequest},
+		{"bad scheme", AddLinkRequest{Slug: "ftp", URL: "ftp://files.example.com"}, http.StatusBadRequest},
+		{"invalid json", "not an object", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, http.MethodPost, "/admin/add", tt.body, "", "")
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	if _, err := st.GetLink(ctx, "wiki"); err != nil {
+		t.Errorf("link not stored: %v", err)
+	}
+	if rec := do(t, s, http.MethodGet, "/admin/add", nil, "", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", rec.Code)
+	}
+}
+
+func TestAdminRemove(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	if rec := do(t, s, http.MethodPost, "/admin/remove", RemoveLinkRequest{Slug: "wiki"}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/remove", RemoveLinkRequest{Slug: "wiki"}, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second remove status = %d, want 404", rec.Code)
+	}
+}
+
+func TestAdminUpdate(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{SensitivePatterns: []string{"*.bank.example"}}
+	s, st := newTestServer(t, cfg)
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://old.example.com"})
+	st.SetPin(ctx, "wiki", 3)
+	st.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: time.Now()}})
+	before, _ := st.GetLink(ctx, "wiki")
+
+	if rec := do(t, s, http.MethodPatch, "/admin/update", UpdateLinkRequest{Slug: "wiki", URL: "https://new.example.com"}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	link, _ := st.GetLink(ctx, "wiki")
+	if link.URL != "https://new.example.com" || !link.CreatedAt.Equal(before.CreatedAt) || link.Clicks != 1 || link.Pin != 3 {
+		t.Errorf("updated link = %+v, want new URL with created_at, clicks and pin kept", link)
+	}
+
+	rec := do(t, s, http.MethodPost, "/admin/update", UpdateLinkRequest{Slug: "wiki", URL: "https://login.bank.example"}, "", "")
+	if link, _ := st.GetLink(ctx, "wiki"); rec.Code != http.StatusAccepted || link.Status != store.StatusPending {
+		t.Errorf("sensitive update: status = %d, link %s, want 202 and pending", rec.Code, link.Status)
+	}
+
+	tests := []struct {
+		req  UpdateLinkRequest
+		want int
+	}{
+		{UpdateLinkRequest{Slug: "missing", URL: "https://a.example.com"}, http.StatusNotFound},
+		{UpdateLinkRequest{Slug: "wiki", URL: "ftp://a.example.com"}, http.StatusBadRequest},
+		{UpdateLinkRequest{Slug: " ", URL: "https://a.example.com"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := do(t, s, http.MethodPost, "/admin/update", tt.req, "", ""); rec.Code != tt.want {
+			t.Errorf("%+v: status = %d, want %d", tt.req, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAdminRename(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{BannedWords: []string{"secret"}})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "mail", URL: "https://mail.example.com"})
+
+	rec := do(t, s, http.MethodPost, "/admin/rename", RenameLinkRequest{From: "wiki", To: " team/wiki ", Alias: true}, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var resp map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp["to"] != "team/wiki" || resp["alias"] != true {
+		t.Errorf("response = %v", resp)
+	}
+
+	// The old slug keeps redirecting to the link
+	rec = do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://wiki.example.com" {
+		t.Errorf("GET /wiki = %d %s, want redirect via the alias", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Without an alias the old slug is gone
+	do(t, s, http.MethodPost, "/admin/rename", RenameLinkRequest{From: "mail", To: "email"}, "", "")
+	if rec := do(t, s, http.MethodGet, "/mail", nil, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /mail = %d, want 404", rec.Code)
+	}
+
+	tests := []struct {
+		req  RenameLinkRequest
+		want int
+	}{
+		{RenameLinkRequest{From: "email", To: "team/wiki"}, http.StatusConflict},
+		{RenameLinkRequest{From: "missing", To: "other"}, http.StatusNotFound},
+		{RenameLinkRequest{From: "email", To: "admin/x"}, http.StatusBadRequest},
+		{RenameLinkRequest{From: "email", To: "email"}, http.StatusBadRequest},
+		{RenameLinkRequest{From: "email", To: "secret-mail"}, http.StatusBadRequest},
+		{RenameLinkRequest{From: "", To: "x"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := do(t, s, http.MethodPost, "/admin/rename", tt.req, "", ""); rec.Code != tt.want {
+			t.Errorf("%+v: status = %d, want %d", tt.req, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	s, _ := newTestServer(t, twoAdmins)
+	body := AddLinkRequest{Slug: "wiki", URL: "https://wiki.example.com"}
+
+	rec := do(t, s, http.MethodPost, "/admin/add", body, "", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("no credentials: status = %d, want 401", rec.Code)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("no credentials: missing WWW-Authenticate header")
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/add", body, "alice", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status = %d, want 401", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/add", body, "bob", "pw2"); rec.Code != http.StatusCreated {
+		t.Errorf("valid credentials: status = %d, want 201", rec.Code)
+	}
+}
+
+func TestApprovalFlow(t *testing.T) {
+	ctx := context.Background()
+	cfg := twoAdmins
+	cfg.SensitivePatterns = []string{"*.bank.example"}
+	s, st := newTestServer(t, cfg)
+
+	rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: "bank", URL: "https://login.bank.example"}, "alice", "pw1")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("add status = %d, want 202", rec.Code)
+	}
+	if link, _ := st.GetLink(ctx, "bank"); link.Status != store.StatusPending || link.CreatedBy != "alice" {
+		t.Fatalf("stored link = %+v, want pending by alice", link)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "bank", URL: "https://login.bank.example"}, "alice", "pw1"); rec.Code != http.StatusForbidden {
+		t.Errorf("self-approve status = %d, want 403", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "bank", URL: "https://login.bank.example"}, "bob", "pw2"); rec.Code != http.StatusOK {
+		t.Fatalf("approve status = %d, want 200", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "bank", URL: "https://login.bank.example"}, "bob", "pw2"); rec.Code != http.StatusConflict {
+		t.Errorf("re-approve status = %d, want 409", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "missing", URL: "https://login.bank.example"}, "bob", "pw2"); rec.Code != http.StatusNotFound {
+		t.Errorf("approve missing status = %d, want 404", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/bank", nil, "", ""); rec.Code != http.StatusFound {
+		t.Errorf("redirect after approval status = %d, want 302", rec.Code)
+	}
+}
+
+// swapStore replaces a pending link with a different destination between the
+// approve handler's lookup and its update.
+type swapStore struct {
+	*store.Memory
+}
+
+func (s swapStore) GetLink(ctx context.Context, slug string) (*store.Link, error) {
+	link, err := s.Memory.GetLink(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	s.Memory.RemoveLink(ctx, slug)
+	s.Memory.AddLink(ctx, store.Link{Slug: slug, URL: "https://evil.example", Status: store.StatusPending, CreatedBy: link.CreatedBy})
+	return link, nil
+}
+
+func TestApproveRace(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory()
+	mem.AddLink(ctx, store.Link{Slug: "bank", URL: "https://login.bank.example", Status: store.StatusPending, CreatedBy: "alice"})
+	s := New(twoAdmins, swapStore{mem}, Pages{})
+
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "bank", URL: "https://login.bank.example"}, "bob", "pw2"); rec.Code != http.StatusConflict {
+		t.Errorf("approve of swapped link status = %d, want 409", rec.Code)
+	}
+	if link, _ := mem.GetLink(ctx, "bank"); link.Status != store.StatusPending {
+		t.Errorf("swapped link status = %q, want pending", link.Status)
+	}
+}
+
+func TestApproveReviewedURL(t *testing.T) {
+	ctx := context.Background()
+	cfg := twoAdmins
+	cfg.SensitivePatterns = []string{"*.bank.example"}
+	s, st := newTestServer(t, cfg)
+	st.AddLink(ctx, store.Link{Slug: "bank", URL: "https://login.bank.example", Status: store.StatusPending, CreatedBy: "alice"})
+
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "bank"}, "bob", "pw2"); rec.Code != http.StatusBadRequest {
+		t.Errorf("approve without url status = %d, want 400", rec.Code)
+	}
+
+	// Bob reviews the link, then alice points it elsewhere before he
+	// approves
+	reviewed := "https://login.bank.example"
+	if err := st.ReplaceLink(ctx, store.Link{Slug: "bank", URL: "https://evil.bank.example", Status: store.StatusPending, CreatedBy: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "bank", URL: reviewed}, "bob", "pw2"); rec.Code != http.StatusConflict {
+		t.Errorf("approve of changed link status = %d, want 409", rec.Code)
+	}
+	if link, _ := st.GetLink(ctx, "bank"); link.Status != store.StatusPending {
+		t.Errorf("changed link status = %q, want pending", link.Status)
+	}
+}
+
+func TestAdminPublic(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+
+	rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: "docs", URL: "https://docs.example.com", Public: true}, "", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add: status = %d", rec.Code)
+	}
+	if link, _ := st.GetLink(ctx, "docs"); !link.Public {
+		t.Error("link added with public=true is not public")
+	}
+
+	rec = do(t, s, http.MethodPost, "/admin/public", SetPublicRequest{Slug: "docs", Public: false}, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("public: status = %d", rec.Code)
+	}
+	if link, _ := st.GetLink(ctx, "docs"); link.Public {
+		t.Error("link still public")
+	}
+
+	rec = do(t, s, http.MethodPost, "/admin/public", SetPublicRequest{Slug: "missing", Public: true}, "", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing slug: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestAdminReview(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "insurance", URL: "https://insurance.example.com"})
+
+	rec := do(t, s, http.MethodPost, "/admin/review", SetReviewRequest{Slug: "insurance", ReviewAt: "2027-03-01T09:00:00Z", ReviewMonths: 12}, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("review: status = %d: %s", rec.Code, rec.Body)
+	}
+	link, _ := st.GetLink(ctx, "insurance")
+	if link.ReviewAt == nil || !link.ReviewAt.Equal(time.Date(2027, 3, 1, 9, 0, 0, 0, time.UTC)) || link.ReviewMonths != 12 {
+		t.Errorf("review = %v every %d months", link.ReviewAt, link.ReviewMonths)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/review", SetReviewRequest{Slug: "insurance", ReviewAt: "2027-03-01"}, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("date only: status = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/review", SetReviewRequest{Slug: "insurance"}, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("clear: status = %d", rec.Code)
+	}
+	if link, _ := st.GetLink(ctx, "insurance"); link.ReviewAt != nil {
+		t.Errorf("review not cleared: %v", link.ReviewAt)
+	}
+
+	for _, req := range []SetReviewRequest{
+		{Slug: "insurance", ReviewAt: "next year"},
+		{Slug: "insurance", ReviewAt: "2027-03-01", ReviewMonths: -1},
+		{Slug: "insurance", ReviewAt: "2027-03-01", ReviewMonths: 121},
+	} {
+		if rec := do(t, s, http.MethodPost, "/admin/review", req, "", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%+v: status = %d, want 400", req, rec.Code)
+		}
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/review", SetReviewRequest{Slug: "missing", ReviewAt: "2027-03-01"}, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing slug: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestAdminPin(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	if rec := do(t, s, http.MethodPost, "/admin/pin", SetPinRequest{Slug: "wiki", Pin: 3}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("pin: status = %d: %s", rec.Code, rec.Body)
+	}
+	if link, _ := st.GetLink(ctx, "wiki"); link.Pin != 3 {
+		t.Errorf("pin = %d, want 3", link.Pin)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/pin", SetPinRequest{Slug: "wiki", Pin: -1}, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative pin: status = %d, want 400", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/pin", SetPinRequest{Slug: "missing", Pin: 1}, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing slug: status = %d, want 404", rec.Code)
+	}
+}
+
+// budgetLog records BudgetWatcher calls.
+type budgetLog []string
+
+func (b *budgetLog) Hit(link store.Link) { *b = append(*b, link.Slug) }
+
+func TestAdminBudget(t *testing.T) {
+	ctx := context.Background()
+	var budgets budgetLog
+	s, st := newTestServer(t, Config{Budgets: &budgets})
+	st.AddLink(ctx, store.Link{Slug: "guest-wifi", URL: "https://wifi.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	if rec := do(t, s, http.MethodPost, "/admin/budget", SetHitBudgetRequest{Slug: "guest-wifi", HitsPerDay: 100}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("budget: status = %d: %s", rec.Code, rec.Body)
+	}
+	if link, _ := st.GetLink(ctx, "guest-wifi"); link.HitBudget != 100 {
+		t.Errorf("hit budget = %d, want 100", link.HitBudget)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/budget", SetHitBudgetRequest{Slug: "wiki", HitsPerDay: -1}, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative budget: status = %d, want 400", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/budget", SetHitBudgetRequest{Slug: "missing", HitsPerDay: 1}, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing slug: status = %d, want 404", rec.Code)
+	}
+
+	// Only links with a budget are reported to the watcher
+	do(t, s, http.MethodGet, "/guest-wifi", nil, "", "")
+	do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	if len(budgets) != 1 || budgets[0] != "guest-wifi" {
+		t.Errorf("budget hits = %v, want [guest-wifi]", budgets)
+	}
+}
+
+func TestAdminClicks(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	for _, slug := range []string{"wiki", "mail", "old"} {
+		st.AddLink(ctx, store.Link{Slug: slug, URL: "https://" + slug + ".example.com"})
+	}
+	now := time.Now()
+	st.RecordClicks(ctx, []store.Click{
+		{Slug: "wiki", At: now}, {Slug: "wiki", At: now}, {Slug: "mail", At: now},
+		{Slug: "old", At: now.AddDate(0, -6, 0)}, {Slug: "old", At: now.AddDate(0, -6, 0)},
+	})
+
+	rec := do(t, s, http.MethodGet, "/admin/clicks?days=30", nil, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("clicks: status = %d: %s", rec.Code, rec.Body)
+	}
+	var report ClickReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range report.Links {
+		got = append(got, fmt.Sprintf("%s %d/%d", l.Slug, l.Recent, l.Clicks))
+	}
+	if report.Days != 30 || strings.Join(got, ", ") != "old 0/2, mail 1/1, wiki 2/2" {
+		t.Errorf("report = %d days: %v", report.Days, got)
+	}
+
+	if rec := do(t, s, http.MethodGet, "/admin/clicks?days=0", nil, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("days=0: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestLinkStats(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, twoAdmins)
+	st.AddLink(ctx, store.Link{Slug: "team/wiki", URL: "https://wiki.example.com"})
+	now := time.Now()
+	st.RecordClicks(ctx, []store.Click{
+		{Slug: "team/wiki", At: now}, {Slug: "team/wiki", At: now},
+		{Slug: "team/wiki", At: now.AddDate(0, 0, -2)},
+		{Slug: "team/wiki", At: now.AddDate(0, 0, -10)},
+	})
+
+	if rec := do(t, s, http.MethodGet, "/api/links/team/wiki/stats", nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without auth: status = %d, want 401", rec.Code)
+	}
+	rec := do(t, s, http.MethodGet, "/api/links/team/wiki/stats?days=3", nil, "alice", "pw1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stats: status = %d: %s", rec.Code, rec.Body)
+	}
+	var stats LinkStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	var daily []string
+	for _, d := range stats.Daily {
+		daily = append(daily, fmt.Sprintf("%s:%d", d.Date, d.Clicks))
+	}
+	want := fmt.Sprintf("%s:1 %s:0 %s:2", now.AddDate(0, 0, -2).Format(time.DateOnly), now.AddDate(0, 0, -1).Format(time.DateOnly), now.Format(time.DateOnly))
+	if stats.Slug != "team/wiki" || stats.Clicks != 4 || stats.LastUsed == nil || strings.Join(daily, " ") != want {
+		t.Errorf("stats = %+v, daily %v; want %s", stats, daily, want)
+	}
+
+	for target, code := range map[string]int{
+		"/api/links/missing/stats":              http.StatusNotFound,
+		"/api/links/team/wiki":                  http.StatusNotFound,
+		"/api/links/team/wiki/stats?days=0":     http.StatusBadRequest,
+		"/api/links/team/wiki/stats?days=10000": http.StatusBadRequest,
+	} {
+		if rec := do(t, s, http.MethodGet, target, nil, "alice", "pw1"); rec.Code != code {
+			t.Errorf("GET %s: status = %d, want %d", target, rec.Code, code)
+		}
+	}
+}
+
+func TestAdminFailover(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{SensitivePatterns: []string{"*.bank.example"}})
+	st.AddLink(ctx, store.Link{Slug: "nas", URL: "https://nas.example.com"})
+
+	tests := []struct {
+		name string
+		body SetFailoverRequest
+		want int
+	}{
+		{"set", SetFailoverRequest{Slug: "nas", URL: " http://nas.lan "}, http.StatusOK},
+		{"bad scheme", SetFailoverRequest{Slug: "nas", URL: "ftp://nas.lan"}, http.StatusBadRequest},
+		{"sensitive", SetFailoverRequest{Slug: "nas", URL: "https://www.bank.example"}, http.StatusBadRequest},
+		{"missing", SetFailoverRequest{Slug: "nope", URL: "http://nas.lan"}, http.StatusNotFound},
+		{"empty slug", SetFailoverRequest{URL: "http://nas.lan"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, http.MethodPost, "/admin/failover", tt.body, "", "")
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+	if link, _ := st.GetLink(ctx, "nas"); link.Failover != "http://nas.lan" {
+		t.Errorf("failover = %q", link.Failover)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/failover", SetFailoverRequest{Slug: "nas"}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("remove: %d %s", rec.Code, rec.Body)
+	}
+	if link, _ := st.GetLink(ctx, "nas"); link.Failover != "" {
+		t.Errorf("failover after removal = %q", link.Failover)
+	}
+}
+
+func TestAdminReferrer(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "grafana", URL: "https://grafana.example.com/?a=1&b=2", Status: store.StatusActive})
+
+	tests := []struct {
+		name string
+		body SetReferrerRequest
+		want int
+	}{
+		{"unknown policy", SetReferrerRequest{Slug: "grafana", Policy: "hide"}, http.StatusBadRequest},
+		{"missing", SetReferrerRequest{Slug: "nope", Policy: "strip"}, http.StatusNotFound},
+		{"empty slug", SetReferrerRequest{Policy: "strip"}, http.StatusBadRequest},
+		{"strip", SetReferrerRequest{Slug: "grafana", Policy: "strip"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, http.MethodPost, "/admin/referrer", tt.body, "", "")
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	// Stripped and replaced referrers redirect through a page with the
+	// matching policy
+	rec := do(t, s, http.MethodGet, "/grafana", nil, "", "")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("Referrer-Policy") != "no-referrer" || rec.Header().Get("Location") != "" ||
+		!strings.Contains(body, `<meta name="referrer" content="no-referrer">`) || !strings.Contains(body, "url=https://grafana.example.com/?a=1&amp;b=2") {
+		t.Errorf("strip redirect = %d %v\n%s", rec.Code, rec.Header(), body)
+	}
+	do(t, s, http.MethodPost, "/admin/referrer", SetReferrerRequest{Slug: "grafana", Policy: "replace"}, "", "")
+	if rec := do(t, s, http.MethodGet, "/grafana", nil, "", ""); rec.Header().Get("Referrer-Policy") != "origin" || !strings.Contains(rec.Body.String(), `content="origin"`) {
+		t.Errorf("replace redirect = %d %v", rec.Code, rec.Header())
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/referrer", SetReferrerRequest{Slug: "grafana", Policy: "pass"}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("pass: %d %s", rec.Code, rec.Body)
+	}
+	if link, _ := st.GetLink(ctx, "grafana"); link.Referrer != "" {
+		t.Errorf("referrer = %q", link.Referrer)
+	}
+	if rec := do(t, s, http.MethodGet, "/grafana", nil, "", ""); rec.Code != http.StatusFound {
+		t.Errorf("pass redirect = %d", rec.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package httpapi
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// AliasRedirect sends requests for a legacy short domain to the same path
+// and query under target, e.g. http://old-go/wiki to https://go.example.com/wiki.
+// Requests whose host is one of hosts are redirected and all others go to
+// next; with a nil next every request is redirected, for a listener that
+// only serves legacy domains.
+//
+// GET and HEAD get a 301. Other methods get a 308 so API clients repeat
+// the same request against the new domain.
+func AliasRedirect(target string, hosts []string, next http.Handler) http.Handler {
+	target = strings.TrimSuffix(target, "/")
+	aliases := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		aliases[normalizeHost(host)] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if next != nil && !aliases[normalizeHost(r.Host)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		w.Header()["Location"] = []string{target + r.URL.RequestURI()}
+		w.WriteHeader(code)
+	})
+}
+
+// normalizeHost lowercases host and strips its port and any trailing dot.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
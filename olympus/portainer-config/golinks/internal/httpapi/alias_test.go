@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAliasRedirect(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := AliasRedirect("https://go.example.com/", []string{"old-go", "Go.Legacy.Example.com"}, next)
+
+	tests := []struct {
+		method, host, target string
+		code                 int
+		location             string
+	}{
+		{http.MethodGet, "old-go", "/wiki?q=1", http.StatusMovedPermanently, "https://go.example.com/wiki?q=1"},
+		{http.MethodGet, "go.legacy.example.com.:8080", "/%F0%9F%8D%95", http.StatusMovedPermanently, "https://go.example.com/%F0%9F%8D%95"},
+		{http.MethodHead, "old-go", "/", http.StatusMovedPermanently, "https://go.example.com/"},
+		{http.MethodPost, "old-go", "/admin/add", http.StatusPermanentRedirect, "https://go.example.com/admin/add"},
+		{http.MethodGet, "go.example.com", "/wiki", http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.code || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s%s = %d %q, want %d %q", tt.method, tt.host, tt.target, rec.Code, rec.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+}
+
+func TestAliasRedirectListener(t *testing.T) {
+	// Without a next handler any host is redirected
+	h := AliasRedirect("https://go.example.com", nil, nil)
+	req := httptest.NewRequest(http.MethodGet, "/wiki", nil)
+	req.Host = "anything"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://go.example.com/wiki" {
+		t.Errorf("got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// handleAdminBackup streams a snapshot of the database, taken while it
+// stays in use, as a download.
+func (s *Server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := fmt.Sprintf("golinks-%s.db", time.Now().UTC().Format("20060102T150405Z"))
+	bw := &backupWriter{ResponseWriter: w, name: name}
+	if err := s.cfg.Backups.Backup(r.Context(), bw); err != nil {
+		slog.ErrorContext(r.Context(), "Error backing up database", "error", err)
+		if !bw.started {
+			http.Error(w, "Backup failed", http.StatusInternalServerError)
+		}
+		return
+	}
+	slog.InfoContext(r.Context(), "Database backed up", "file", name, "remote_addr", s.remote(r))
+}
+
+// backupWriter sends the download headers with the first bytes of a
+// backup, so a backup that fails before then still gets an error status.
+type backupWriter struct {
+	http.ResponseWriter
+	name    string
+	started bool
+}
+
+func (w *backupWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.name))
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,39 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type fakeBackups struct{ err error }
+
+func (f fakeBackups) Backup(ctx context.Context, w io.Writer) error {
+	if f.err != nil {
+		return f.err
+	}
+	_, err := io.WriteString(w, "SQLite format 3\x00")
+	return err
+}
+
+func TestAdminBackup(t *testing.T) {
+	s, _ := newTestServer(t, Config{Admins: map[string]string{"admin": "pw"}, Backups: fakeBackups{}})
+	if rec := do(t, s, http.MethodGet, "/admin/backup", nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without login: status = %d", rec.Code)
+	}
+	rec := do(t, s, http.MethodGet, "/admin/backup", nil, "admin", "pw")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "SQLite format 3") {
+		t.Fatalf("backup: status = %d, body %q", rec.Code, rec.Body)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "golinks-") || !strings.Contains(cd, ".db") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+
+	s, _ = newTestServer(t, Config{Backups: fakeBackups{err: errors.New("disk full")}})
+	if rec := do(t, s, http.MethodGet, "/admin/backup", nil, "", ""); rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Disposition") != "" {
+		t.Errorf("failed backup: status = %d, Content-Disposition %q", rec.Code, rec.Header().Get("Content-Disposition"))
+	}
+}
@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golinks/internal/banner"
+)
+
+// maxBannerText bounds the banner text in characters.
+const maxBannerText = 500
+
+type SetBannerRequest struct {
+	// Text is the announcement; "" removes the banner.
+	Text string `json:"text"`
+	// Link, if set, leads to details.
+	Link string `json:"link,omitempty"`
+	// Expires is a date (2006-01-02, midnight server time) or an RFC 3339
+	// time after which the banner is no longer shown. Empty keeps it up
+	// until removed.
+	Expires string `json:"expires,omitempty"`
+}
+
+// handleAdminBanner returns the banner on GET, including an expired one,
+// and replaces it on POST.
+func (s *Server) handleAdminBanner(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeBanner(w, s.cfg.Banner.Get())
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetBannerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	b := banner.Banner{Text: strings.TrimSpace(req.Text), Link: strings.TrimSpace(req.Link)}
+	if b.Text != "" {
+		if utf8.RuneCountInString(b.Text) > maxBannerText {
+			http.Error(w, "text must be at most 500 characters", http.StatusBadRequest)
+			return
+		}
+		if b.Link != "" && !isValidURL(b.Link) {
+			http.Error(w, "Invalid link - must start with http:// or https://", http.StatusBadRequest)
+			return
+		}
+		if req.Expires != "" {
+			t, err := time.ParseInLocation(time.DateOnly, req.Expires, time.Local)
+			if err != nil {
+				if t, err = time.Parse(time.RFC3339, req.Expires); err != nil {
+					http.Error(w, "expires must be a date (YYYY-MM-DD) or RFC 3339 time", http.StatusBadRequest)
+					return
+				}
+			}
+			if !t.After(time.Now()) {
+				http.Error(w, "expires must be in the future", http.StatusBadRequest)
+				return
+			}
+			b.Expires = &t
+		}
+	} else {
+		b = banner.Banner{}
+	}
+
+	if err := s.cfg.Banner.Set(b); err != nil {
+		slog.ErrorContext(r.Context(), "Error saving banner", "error", err)
+		http.Error(w, "Failed to save banner", http.StatusInternalServerError)
+		return
+	}
+
+	if b.Text == "" {
+		slog.InfoContext(r.Context(), "Banner removed", "remote_addr", s.remote(r))
+	} else {
+		slog.InfoContext(r.Context(), "Banner set", "text", b.Text, "remote_addr", s.remote(r))
+	}
+	writeBanner(w, b)
+}
+
+func writeBanner(w http.ResponseWriter, b banner.Banner) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		banner.Banner
+		Active bool `json:"active"`
+	}{b, b.Active(time.Now())})
+}
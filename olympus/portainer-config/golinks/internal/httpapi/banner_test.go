@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"golinks/internal/banner"
+)
+
+func TestAdminBanner(t *testing.T) {
+	board, _ := banner.Open("")
+	s, _ := newTestServer(t, Config{Banner: board})
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+	tests := []struct {
+		name string
+		body any
+		want int
+	}{
+		{"bad link", SetBannerRequest{Text: "Wiki migration", Link: "wiki/migration"}, http.StatusBadRequest},
+		{"bad expiry", SetBannerRequest{Text: "Wiki migration", Expires: "this weekend"}, http.StatusBadRequest},
+		{"past expiry", SetBannerRequest{Text: "Wiki migration", Expires: "2020-01-01"}, http.StatusBadRequest},
+		{"invalid json", "not an object", http.StatusBadRequest},
+		{"valid", SetBannerRequest{Text: " Wiki migration this weekend ", Link: "https://wiki.example.com/migration", Expires: tomorrow}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(t, s, http.MethodPost, "/admin/banner", tt.body, "", ""); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+	if b := board.Current(time.Now()); b == nil || b.Text != "Wiki migration this weekend" || b.Expires == nil {
+		t.Errorf("banner = %+v", b)
+	}
+
+	rec := do(t, s, http.MethodGet, "/admin/banner", nil, "", "")
+	var got struct {
+		Text   string `json:"text"`
+		Active bool   `json:"active"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Text != "Wiki migration this weekend" || !got.Active {
+		t.Errorf("GET = %s", rec.Body)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/banner", SetBannerRequest{}, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("remove: %d", rec.Code)
+	}
+	if b := board.Current(time.Now()); b != nil {
+		t.Errorf("banner after removal = %+v", b)
+	}
+
+	s, _ = newTestServer(t, Config{})
+	if rec := do(t, s, http.MethodGet, "/admin/banner", nil, "", ""); rec.Code == http.StatusOK {
+		t.Error("/admin/banner served without a board")
+	}
+}
@@ -0,0 +1,97 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"golinks/internal/logging"
+	"golinks/internal/store"
+)
+
+// newBenchServer returns a server over an in-memory store seeded with n
+// links named link0..link<n-1>. Logging is silenced for the benchmark.
+func newBenchServer(b *testing.B, n int) *Server {
+	b.Helper()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	ctx := context.Background()
+	st := store.NewMemory()
+	for i := 0; i < n; i++ {
+		st.AddLink(ctx, store.Link{Slug: fmt.Sprintf("link%d", i), URL: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	return New(Config{}, store.Coalesce(st), Pages{Index: http.NotFoundHandler()})
+}
+
+func BenchmarkRedirect(b *testing.B) {
+	h := newBenchServer(b, 1000).Handler()
+	req := httptest.NewRequest(http.MethodGet, "/link500", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusFound {
+			b.Fatalf("status = %d", rec.Code)
+		}
+	}
+}
+
+func BenchmarkRedirectParallel(b *testing.B) {
+	h := newBenchServer(b, 1000).Handler()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		req := httptest.NewRequest(http.MethodGet, "/link500", nil)
+		for pb.Next() {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusFound {
+				b.Errorf("status = %d", rec.Code)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkRedirectNotFound(b *testing.B) {
+	h := newBenchServer(b, 1000).Handler()
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotFound {
+			b.Fatalf("status = %d", rec.Code)
+		}
+	}
+}
+
+// BenchmarkRedirectQuiet measures the redirect path with LOG_LEVEL=warn,
+// where per-redirect log lines are skipped before they are formatted.
+func BenchmarkRedirectQuiet(b *testing.B) {
+	h := newBenchServer(b, 1000).Handler()
+	logging.SetLevel(logging.LevelWarn)
+	b.Cleanup(func() { logging.SetLevel(logging.LevelInfo) })
+	req := httptest.NewRequest(http.MethodGet, "/link500", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusFound {
+			b.Fatalf("status = %d", rec.Code)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golinks/internal/jobs"
+	"golinks/internal/store"
+)
+
+// maxBulkItems bounds the links of one bulk request.
+const maxBulkItems = 10000
+
+type BulkAddRequest struct {
+	Links []AddLinkRequest `json:"links"`
+}
+
+type BulkRemoveRequest struct {
+	Slugs []string `json:"slugs"`
+}
+
+// BulkResponse is the answer to a bulk request: the queued job and where to
+// poll it.
+type BulkResponse struct {
+	Job       jobs.Job `json:"job"`
+	StatusURL string   `json:"status_url"`
+}
+
+func (s *Server) handleAdminBulkAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BulkAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !checkBulkSize(w, len(req.Links)) {
+		return
+	}
+
+	admin := s.adminName(r)
+	s.submitBulk(w, r, "bulk-add", len(req.Links), func(ctx context.Context, p *jobs.Progress) error {
+		for _, item := range req.Links {
+			link, err := s.AddLink(ctx, item, admin)
+			if err != nil {
+				err = fmt.Errorf("%s: %s", strings.TrimSpace(item.Slug), linkErrorText(err))
+			} else if link.Status == store.StatusPending {
+				slog.InfoContext(r.Context(), "Link pending approval", "slug", link.Slug, "url", link.URL, "source", "bulk", "remote_addr", s.remote(r))
+			}
+			if err := p.Item(ctx, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Server) handleAdminBulkRemove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BulkRemoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !checkBulkSize(w, len(req.Slugs)) {
+		return
+	}
+
+	s.submitBulk(w, r, "bulk-remove", len(req.Slugs), func(ctx context.Context, p *jobs.Progress) error {
+		for _, slug := range req.Slugs {
+			_, err := s.RemoveLink(ctx, slug)
+			if err != nil {
+				err = fmt.Errorf("%s: %s", strings.TrimSpace(slug), linkErrorText(err))
+			}
+			if err := p.Item(ctx, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func checkBulkSize(w http.ResponseWriter, n int) bool {
+	switch {
+	case n == 0:
+		http.Error(w, "Nothing to do", http.StatusBadRequest)
+		return false
+	case n > maxBulkItems:
+		http.Error(w, fmt.Sprintf("At most %d links per request", maxBulkItems), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// submitBulk queues task and answers 202 with the job, or 503 if the queue
+// is full.
+func (s *Server) submitBulk(w http.ResponseWriter, r *http.Request, kind string, total int, task jobs.Task) {
+	job, err := s.cfg.Jobs.Submit(kind, s.adminName(r), total, task)
+	if errors.Is(err, jobs.ErrQueueFull) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many bulk jobs waiting, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Job queued", "job", job.ID, "kind", kind, "items", total, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(BulkResponse{Job: job, StatusURL: "/api/v1/jobs/" + job.ID})
+}
+
+// linkErrorText describes why adding or removing one link of a bulk job
+// failed.
+func linkErrorText(err error) string {
+	var invalid *InvalidError
+	if errors.As(err, &invalid) {
+		return invalid.Msg
+	}
+	switch {
+	case errors.Is(err, store.ErrConflict):
+		return "slug already exists"
+	case errors.Is(err, store.ErrNotFound):
+		return "not found"
+	}
+	slog.Error("Error in bulk job", "error", err)
+	return "internal error"
+}
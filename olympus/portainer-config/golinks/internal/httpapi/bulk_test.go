@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"golinks/internal/jobs"
+	"golinks/internal/store"
+)
+
+// pollJob fetches the job at statusURL until it is finished.
+func pollJob(t *testing.T, s *Server, statusURL string) jobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := do(t, s, http.MethodGet, statusURL, nil, "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d", statusURL, rec.Code)
+		}
+		var job jobs.Job
+		json.Unmarshal(rec.Body.Bytes(), &job)
+		if job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job at %s did not finish", statusURL)
+	return jobs.Job{}
+}
+
+func TestAdminBulk(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	queue := jobs.NewQueue(4, 0)
+	go queue.Run(ctx)
+	s, st := newTestServer(t, Config{Jobs: queue})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	rec := do(t, s, http.MethodPost, "/admin/bulk/add", BulkAddRequest{Links: []AddLinkRequest{
+		{Slug: "mail", URL: "https://mail.example.com"},
+		{Slug: "wiki", URL: "https://other.example.com"},
+		{Slug: "bad", URL: "ftp://files.example.com"},
+		{Slug: "cal", URL: "https://cal.example.com"},
+	}}, "", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("bulk add: status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp BulkResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Job.Total != 4 || resp.StatusURL != rec.Header().Get("Location") {
+		t.Errorf("bulk add response = %+v", resp)
+	}
+	job := pollJob(t, s, resp.StatusURL)
+	if job.Status != jobs.StatusDone || job.Done != 4 || job.Failed != 2 || len(job.Errors) != 2 || job.Errors[0] != "wiki: slug already exists" {
+		t.Errorf("bulk add job = %+v", job)
+	}
+	if _, err := st.GetLink(ctx, "cal"); err != nil {
+		t.Errorf("cal not added: %v", err)
+	}
+
+	rec = do(t, s, http.MethodPost, "/admin/bulk/remove", BulkRemoveRequest{Slugs: []string{"mail", "cal", "missing"}}, "", "")
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if job := pollJob(t, s, resp.StatusURL); job.Done != 3 || job.Failed != 1 || job.Errors[0] != "missing: not found" {
+		t.Errorf("bulk remove job = %+v", job)
+	}
+	if links, _ := st.ListLinks(ctx); len(links) != 1 {
+		t.Errorf("%d link(s) left, want 1", len(links))
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/bulk/remove", BulkRemoveRequest{}, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty bulk remove: status = %d, want 400", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/api/v1/jobs/999", nil, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job: status = %d, want 404", rec.Code)
+	}
+}
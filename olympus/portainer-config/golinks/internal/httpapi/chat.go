@@ -0,0 +1,217 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"golinks/internal/approval"
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// maxSlackBody bounds the body of a Slack interaction callback.
+const maxSlackBody = 64 << 10
+
+var (
+	// errDecided rejects an action on a link that is no longer pending
+	// with the destination it was proposed with.
+	errDecided = errors.New("link was changed or already decided")
+	// errSelfApproval rejects an admin approving their own link.
+	errSelfApproval = errors.New("link must be approved by a different admin")
+	// errNotAdmin rejects a chat user who is not mapped to an admin.
+	errNotAdmin = errors.New("only admins may approve or reject links")
+)
+
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 3em auto">
+<h1>{{.Title}}</h1>
+{{if .Link.Slug}}<p><strong>go/{{.Link.Slug}}</strong> → {{.Link.URL}}{{with .Link.CreatedBy}} (proposed by {{.}}){{end}}</p>{{end}}
+{{if .Token}}<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">{{.Button}}</button></form>{{end}}
+</body>
+</html>
+`))
+
+type confirmData struct {
+	Title  string
+	Link   store.Link
+	Token  string
+	Button string
+}
+
+// propose posts link to chat if it waits for approval.
+func (s *Server) propose(link store.Link) {
+	if s.cfg.Approvals != nil {
+		s.cfg.Approvals.Propose(link)
+	}
+}
+
+// decide applies a on the link it was issued for, on behalf of by.
+func (s *Server) decide(ctx context.Context, a approval.Action, by string) (store.Link, error) {
+	link, err := s.store.GetLink(ctx, a.Slug)
+	if errors.Is(err, store.ErrNotFound) {
+		return store.Link{}, errDecided
+	}
+	if err != nil {
+		return store.Link{}, err
+	}
+	if link.Status != store.StatusPending || !a.Matches(*link) {
+		return store.Link{}, errDecided
+	}
+
+	if a.Kind == approval.Reject {
+		if err := s.store.RemoveLink(ctx, link.Slug); err != nil {
+			return store.Link{}, err
+		}
+		slog.InfoContext(ctx, "Link rejected", "slug", link.Slug, "url", link.URL, "user", by, "source", "chat")
+		return *link, nil
+	}
+	if by == link.CreatedBy {
+		return store.Link{}, errSelfApproval
+	}
+	if err := s.store.ApproveLink(ctx, link.Slug, link.URL, by); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return store.Link{}, errDecided
+		}
+		return store.Link{}, err
+	}
+	slog.InfoContext(ctx, "Link approved", "slug", link.Slug, "url", link.URL, "user", by, "source", "chat")
+	return *link, nil
+}
+
+// decisionText describes the outcome of decide for chat.
+func decisionText(a approval.Action, link store.Link, by string, err error) string {
+	switch {
+	case err == nil && a.Kind == approval.Approve:
+		return fmt.Sprintf("go/%s → %s approved by %s", link.Slug, link.URL, by)
+	case err == nil:
+		return fmt.Sprintf("go/%s → %s rejected by %s", link.Slug, link.URL, by)
+	case errors.Is(err, errDecided), errors.Is(err, errSelfApproval), errors.Is(err, errNotAdmin):
+		return fmt.Sprintf("go/%s: %v", a.Slug, err)
+	}
+	slog.Error("Error deciding on link", "error", err)
+	return fmt.Sprintf("go/%s: internal error, try again", a.Slug)
+}
+
+// handleSlackAction serves the interactivity callback of the Slack app,
+// sent when an approve or reject button is clicked. Only the Slack users
+// mapped to admins may act, as those admins, so the creator of a link
+// cannot approve it from Slack either.
+func (s *Server) handleSlackAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSlackBody))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := s.cfg.Approvals.VerifySlack(r.Header, body); err != nil {
+		slog.WarnContext(r.Context(), "Rejected Slack callback", "error", err, "remote_addr", s.remote(r))
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		User struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+		Actions []struct {
+			Value string `json:"value"`
+		} `json:"actions"`
+		ResponseURL string `json:"response_url"`
+	}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil || len(payload.Actions) == 0 {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	by, isAdmin := s.cfg.Approvals.SlackAdmin(payload.User.ID)
+	var text string
+	a, err := s.cfg.Approvals.Verify(payload.Actions[0].Value)
+	switch {
+	case err != nil:
+		text = "Cannot act on this request: " + err.Error()
+	case !isAdmin:
+		slog.WarnContext(r.Context(), "Refused Slack action of a user who is not an admin", "slug", a.Slug, "slack_user", payload.User.ID, "slack_username", payload.User.Username)
+		text = decisionText(a, store.Link{}, "", errNotAdmin)
+	default:
+		link, err := s.decide(r.Context(), a, by)
+		text = decisionText(a, link, by, err)
+	}
+	if payload.ResponseURL != "" {
+		if err := s.cfg.Approvals.RespondSlack(r.Context(), payload.ResponseURL, text); err != nil {
+			slog.ErrorContext(r.Context(), "Slack response failed", "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleChatApproval serves the confirmation page of the links posted to
+// Matrix: GET shows the link and a button, which POSTs the decision.
+func (s *Server) handleChatApproval(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.FormValue("token")
+	a, err := s.cfg.Approvals.Verify(token)
+	if err != nil {
+		http.Error(w, "Cannot act on this request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		link, err := s.store.GetLink(r.Context(), a.Slug)
+		if errors.Is(err, store.ErrNotFound) {
+			link, err = &store.Link{}, nil
+		}
+		if err != nil {
+			httperr.Write(w, err)
+			return
+		}
+		data := confirmData{Title: "Approve this link?", Link: *link, Token: token, Button: "Approve"}
+		if a.Kind == approval.Reject {
+			data.Title, data.Button = "Reject this link?", "Reject"
+		}
+		if link.Status != store.StatusPending || !a.Matches(*link) {
+			data = confirmData{Title: "This link was changed or already decided", Link: *link}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		confirmPage.Execute(w, data)
+		return
+	}
+
+	by := s.adminName(r)
+	if by == "" {
+		by = "chat"
+	}
+	link, err := s.decide(r.Context(), a, by)
+	code := http.StatusOK
+	switch {
+	case errors.Is(err, errDecided):
+		code = http.StatusConflict
+	case errors.Is(err, errSelfApproval):
+		code = http.StatusForbidden
+	case err != nil:
+		code = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	confirmPage.Execute(w, confirmData{Title: decisionText(a, link, by, err)})
+}
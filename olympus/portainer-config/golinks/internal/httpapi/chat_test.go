@@ -0,0 +1,190 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/approval"
+	"golinks/internal/store"
+)
+
+const testSigningSecret = "slack-signing-secret"
+
+// slackCallback posts a signed Slack button click of the user with ID
+// userID with token to s.
+func slackCallback(t *testing.T, s *Server, userID, token, responseURL string) int {
+	t.Helper()
+	payload, _ := json.Marshal(map[string]any{
+		"type":         "block_actions",
+		"user":         map[string]string{"id": userID, "username": strings.ToLower(userID)},
+		"actions":      []map[string]string{{"value": token}},
+		"response_url": responseURL,
+	})
+	body := url.Values{"payload": {string(payload)}}.Encode()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	m := hmac.New(sha256.New, []byte(testSigningSecret))
+	fmt.Fprintf(m, "v0:%s:%s", ts, body)
+
+	req := httptest.NewRequest(http.MethodPost, "/chat/slack", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(m.Sum(nil)))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestChatApproval(t *testing.T) {
+	ctx := context.Background()
+	messages := make(chan string, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text   string `json:"text"`
+			Blocks []struct {
+				Elements []struct {
+					ActionID string `json:"action_id"`
+					Value    string `json:"value"`
+				} `json:"elements"`
+			} `json:"blocks"`
+		}
+		json.NewDecoder(r.Body).Decode(&msg)
+		for _, b := range msg.Blocks {
+			for _, e := range b.Elements {
+				messages <- e.ActionID + " " + e.Value
+			}
+		}
+		messages <- msg.Text
+	}))
+	defer hook.Close()
+
+	approvals := approval.New(approval.Config{
+		BaseURL:            "https://go.example.com",
+		Secret:             "0123456789abcdef",
+		SlackWebhookURL:    hook.URL,
+		SlackSigningSecret: testSigningSecret,
+		SlackAdmins:        map[string]string{"U0ALICE": "alice", "U0BOB": "bob"},
+	})
+	cfg := twoAdmins
+	cfg.SensitivePatterns = []string{"*.bank.example"}
+	cfg.Approvals = approvals
+	s, st := newTestServer(t, cfg)
+
+	next := func() string {
+		select {
+		case m := <-messages:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatal("no message posted")
+			return ""
+		}
+	}
+
+	// Adding a sensitive link posts it with approve and reject buttons
+	if rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: "bank", URL: "https://login.bank.example"}, "alice", "pw1"); rec.Code != http.StatusAccepted {
+		t.Fatalf("add: status = %d", rec.Code)
+	}
+	approve, _ := strings.CutPrefix(next(), "approve ")
+	reject, _ := strings.CutPrefix(next(), "reject ")
+	if text := next(); !strings.Contains(text, "go/bank → https://login.bank.example needs approval") {
+		t.Errorf("proposal text = %q", text)
+	}
+
+	// Channel members who are not admins cannot act, and the creator
+	// cannot approve their own link from Slack either
+	slackCallback(t, s, "U0MALLORY", approve, hook.URL)
+	if text := next(); text != "go/bank: only admins may approve or reject links" {
+		t.Errorf("Slack response to a non-admin = %q", text)
+	}
+	slackCallback(t, s, "U0MALLORY", reject, hook.URL)
+	if text := next(); text != "go/bank: only admins may approve or reject links" {
+		t.Errorf("Slack response to a non-admin rejecting = %q", text)
+	}
+	slackCallback(t, s, "U0ALICE", approve, hook.URL)
+	if text := next(); text != "go/bank: link must be approved by a different admin" {
+		t.Errorf("Slack response to the creator = %q", text)
+	}
+	if link, _ := st.GetLink(ctx, "bank"); link.Status != store.StatusPending {
+		t.Fatalf("link after refused actions = %+v", link)
+	}
+
+	if code := slackCallback(t, s, "U0BOB", approve, hook.URL); code != http.StatusOK {
+		t.Fatalf("Slack callback: status = %d", code)
+	}
+	if text := next(); text != "go/bank → https://login.bank.example approved by bob" {
+		t.Errorf("Slack response = %q", text)
+	}
+	if link, _ := st.GetLink(ctx, "bank"); link.Status != store.StatusActive || link.ApprovedBy != "bob" {
+		t.Errorf("link after approval = %+v", link)
+	}
+
+	// Once decided, the other button does nothing
+	slackCallback(t, s, "U0BOB", reject, hook.URL)
+	if text := next(); text != "go/bank: link was changed or already decided" {
+		t.Errorf("Slack response to a stale button = %q", text)
+	}
+
+	// A bad signature is refused before anything else
+	req := httptest.NewRequest(http.MethodPost, "/chat/slack", strings.NewReader("payload={}"))
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set("X-Slack-Signature", "v0=00")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned callback: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestChatApprovalPage(t *testing.T) {
+	ctx := context.Background()
+	approvals := approval.New(approval.Config{BaseURL: "https://go.example.com", Secret: "0123456789abcdef"})
+	cfg := twoAdmins
+	cfg.Approvals = approvals
+	s, st := newTestServer(t, cfg)
+	pending := store.Link{Slug: "bank", URL: "https://login.bank.example", Status: store.StatusPending, CreatedBy: "alice"}
+	st.AddLink(ctx, pending)
+
+	approve := "/chat/approval?token=" + url.QueryEscape(approvals.Sign(approval.Approve, pending))
+	reject := "/chat/approval?token=" + url.QueryEscape(approvals.Sign(approval.Reject, pending))
+
+	rec := do(t, s, http.MethodGet, reject, nil, "bob", "pw2")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Reject this link?") || !strings.Contains(rec.Body.String(), `<form method="post">`) {
+		t.Errorf("confirm page: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, reject, nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("confirm page without login: status = %d, want 401", rec.Code)
+	}
+
+	// The creator cannot approve their own link
+	if rec := do(t, s, http.MethodPost, approve, nil, "alice", "pw1"); rec.Code != http.StatusForbidden {
+		t.Errorf("self approval: status = %d, want 403", rec.Code)
+	}
+
+	// Changing the destination voids the posted actions
+	st.UpdateLink(ctx, store.Link{Slug: "bank", URL: "https://other.bank.example", Status: store.StatusPending, CreatedBy: "alice"})
+	if rec := do(t, s, http.MethodPost, approve, nil, "bob", "pw2"); rec.Code != http.StatusConflict {
+		t.Errorf("approval of a changed link: status = %d, want 409", rec.Code)
+	}
+	pending.URL = "https://other.bank.example"
+	reject = "/chat/approval?token=" + url.QueryEscape(approvals.Sign(approval.Reject, pending))
+	if rec := do(t, s, http.MethodPost, reject, nil, "bob", "pw2"); rec.Code != http.StatusOK {
+		t.Errorf("reject: status = %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := st.GetLink(ctx, "bank"); err == nil {
+		t.Error("rejected link was kept")
+	}
+
+	if rec := do(t, s, http.MethodGet, "/chat/approval?token=forged", nil, "bob", "pw2"); rec.Code != http.StatusBadRequest {
+		t.Errorf("forged token: status = %d, want 400", rec.Code)
+	}
+}
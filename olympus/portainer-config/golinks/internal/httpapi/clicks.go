@@ -0,0 +1,175 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// defaultClickDays is the window of the click report when none is given.
+const defaultClickDays = 90
+
+// Link stats cover the last defaultStatsDays days unless ?days= asks for
+// up to maxStatsDays.
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+)
+
+// LinkClicks is the usage of one link in the click report.
+type LinkClicks struct {
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+	// Clicks counts every redirect since the link was created, Recent
+	// those within the report window.
+	Clicks   int        `json:"clicks"`
+	Recent   int        `json:"recent"`
+	LastUsed *time.Time `json:"last_used"`
+}
+
+// ClickReport lists every link, least used first, to find links to prune.
+type ClickReport struct {
+	Days  int          `json:"days"`
+	Links []LinkClicks `json:"links"`
+}
+
+func (s *Server) handleAdminClicks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days, ok := queryInt(w, r, "days", defaultClickDays, 0)
+	if !ok {
+		return
+	}
+
+	links, err := s.store.ListLinks(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching links", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	recent, err := s.store.ClickCounts(r.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting clicks", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	report := ClickReport{Days: days, Links: make([]LinkClicks, 0, len(links))}
+	for _, link := range links {
+		report.Links = append(report.Links, LinkClicks{
+			Slug:     link.Slug,
+			URL:      link.URL,
+			Clicks:   link.Clicks,
+			Recent:   recent[link.Slug],
+			LastUsed: link.LastUsedAt,
+		})
+	}
+	slices.SortFunc(report.Links, func(a, b LinkClicks) int {
+		return cmp.Or(cmp.Compare(a.Recent, b.Recent), cmp.Compare(a.Clicks, b.Clicks), cmp.Compare(a.Slug, b.Slug))
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// DayClicks is the number of clicks on one day, in server local time.
+type DayClicks struct {
+	Date   string `json:"date"`
+	Clicks int    `json:"clicks"`
+}
+
+// LinkStats is the usage of one link, served at /api/links/{slug}/stats.
+type LinkStats struct {
+	Slug   string `json:"slug"`
+	URL    string `json:"url"`
+	Clicks int    `json:"clicks"`
+	// ListOpens are the clicks opened from the list page.
+	ListOpens int        `json:"list_opens"`
+	LastUsed  *time.Time `json:"last_used"`
+	// Daily has one entry per day of the window, oldest first, including
+	// days without clicks.
+	Daily []DayClicks `json:"daily"`
+}
+
+// handleLinkStats serves GET /api/links/{slug}/stats. Slugs may contain
+// "/", so the path is taken apart by hand rather than by a mux pattern.
+func (s *Server) handleLinkStats(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/links/"), "/stats")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveLinkStats(w, r, canonicalSlug(rest))
+}
+
+// serveLinkStats writes the LinkStats of slug.
+func (s *Server) serveLinkStats(w http.ResponseWriter, r *http.Request, slug string) {
+	if slug == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days, ok := queryInt(w, r, "days", defaultStatsDays, maxStatsDays)
+	if !ok {
+		return
+	}
+
+	link, err := s.store.GetLink(r.Context(), slug)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Error fetching link", "error", err)
+		}
+		httperr.Write(w, err)
+		return
+	}
+	daily, err := s.dailyClicks(r.Context(), link.Slug, days)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching clicks", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	stats := LinkStats{Slug: link.Slug, URL: link.URL, Clicks: link.Clicks, ListOpens: link.ListOpens, LastUsed: link.LastUsedAt, Daily: daily}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// dailyClicks counts the clicks of slug on each of the last days local
+// calendar days, today last.
+func (s *Server) dailyClicks(ctx context.Context, slug string, days int) ([]DayClicks, error) {
+	now := time.Now()
+	first := time.Date(now.Year(), now.Month(), now.Day()-(days-1), 0, 0, 0, 0, now.Location())
+	times, err := s.store.ClickTimes(ctx, slug, first)
+	if err != nil {
+		return nil, err
+	}
+
+	daily := make([]DayClicks, days)
+	index := make(map[string]int, days)
+	for i := range daily {
+		date := first.AddDate(0, 0, i).Format(time.DateOnly)
+		daily[i].Date = date
+		index[date] = i
+	}
+	for _, at := range times {
+		if i, ok := index[at.In(now.Location()).Format(time.DateOnly)]; ok {
+			daily[i].Clicks++
+		}
+	}
+	return daily, nil
+}
@@ -0,0 +1,144 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// maxCollectionLinks bounds the size of one collection.
+const maxCollectionLinks = 500
+
+type SaveCollectionRequest struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Slugs       []string `json:"slugs"`
+}
+
+type RemoveCollectionRequest struct {
+	Name string `json:"name"`
+}
+
+// handleAdminCollections lists collections (GET) or creates or replaces
+// one (POST).
+func (s *Server) handleAdminCollections(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		collections, err := s.store.ListCollections(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing collections", "error", err)
+			httperr.Write(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(collections)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SaveCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	c, err := s.SaveCollection(r.Context(), req, s.adminName(r))
+	if err != nil {
+		var invalid *InvalidError
+		if errors.As(err, &invalid) {
+			writeInvalid(w, invalid)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error saving collection", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Collection saved", "collection", c.Name, "links", len(c.Slugs), "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": "saved",
+		"name":   c.Name,
+		"slugs":  c.Slugs,
+	})
+}
+
+// SaveCollection validates and creates or replaces a collection on behalf
+// of createdBy. Every slug must name an existing link. Validation failures
+// are returned as *InvalidError.
+func (s *Server) SaveCollection(ctx context.Context, req SaveCollectionRequest, createdBy string) (store.Collection, error) {
+	// Names share the slug rules so "/+name" routes like "/slug" does
+	name := canonicalSlug(strings.TrimSpace(req.Name))
+	if !isValidSlug(name) {
+		return store.Collection{}, &InvalidError{Msg: "Invalid collection name", Field: "name"}
+	}
+	if len(req.Slugs) > maxCollectionLinks {
+		return store.Collection{}, &InvalidError{Msg: "Too many links in collection", Field: "slugs"}
+	}
+
+	c := store.Collection{
+		Name:        name,
+		Title:       strings.TrimSpace(req.Title),
+		Description: strings.TrimSpace(req.Description),
+		Slugs:       make([]string, 0, len(req.Slugs)),
+		CreatedBy:   createdBy,
+	}
+	for _, slug := range req.Slugs {
+		slug = canonicalSlug(strings.TrimSpace(slug))
+		if _, err := s.store.GetLink(ctx, slug); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return store.Collection{}, &InvalidError{Msg: "Unknown slug: " + slug, Field: "slugs"}
+			}
+			return store.Collection{}, err
+		}
+		c.Slugs = append(c.Slugs, slug)
+	}
+	if err := s.store.SaveCollection(ctx, c); err != nil {
+		return store.Collection{}, err
+	}
+	return c, nil
+}
+
+func (s *Server) handleAdminCollectionRemove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RemoveCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = canonicalSlug(strings.TrimSpace(req.Name))
+	err := s.store.RemoveCollection(r.Context(), req.Name)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error removing collection", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Collection removed", "collection", req.Name, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "removed",
+		"name":   req.Name,
+	})
+}
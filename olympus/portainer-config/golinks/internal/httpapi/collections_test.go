@@ -0,0 +1,73 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestAdminCollections(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, twoAdmins)
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "hr", URL: "https://hr.example.com"})
+
+	rec := do(t, s, http.MethodPost, "/admin/collections", SaveCollectionRequest{Name: "onboarding", Title: "Onboarding", Slugs: []string{"wiki", " hr "}}, "alice", "pw1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("save: status = %d: %s", rec.Code, rec.Body)
+	}
+	c, err := st.GetCollection(ctx, "onboarding")
+	if err != nil || c.Title != "Onboarding" || c.CreatedBy != "alice" || fmt.Sprint(c.Slugs) != "[wiki hr]" {
+		t.Errorf("stored collection = %+v, %v", c, err)
+	}
+
+	for _, req := range []SaveCollectionRequest{
+		{Name: "", Slugs: []string{"wiki"}},
+		{Name: "admin", Slugs: []string{"wiki"}},
+		{Name: "+nested", Slugs: []string{"wiki"}},
+		{Name: "onboarding", Slugs: []string{"wiki", "missing"}},
+	} {
+		if rec := do(t, s, http.MethodPost, "/admin/collections", req, "alice", "pw1"); rec.Code != http.StatusBadRequest {
+			t.Errorf("%+v: status = %d, want 400", req, rec.Code)
+		}
+	}
+
+	rec = do(t, s, http.MethodGet, "/admin/collections", nil, "bob", "pw2")
+	var list []store.Collection
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil || len(list) != 1 || list[0].Name != "onboarding" {
+		t.Errorf("list = %+v, %v", list, err)
+	}
+	if rec := do(t, s, http.MethodGet, "/admin/collections", nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated list: status = %d", rec.Code)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/collections/remove", RemoveCollectionRequest{Name: "onboarding"}, "bob", "pw2"); rec.Code != http.StatusOK {
+		t.Errorf("remove: status = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/collections/remove", RemoveCollectionRequest{Name: "onboarding"}, "bob", "pw2"); rec.Code != http.StatusNotFound {
+		t.Errorf("remove twice: status = %d, want 404", rec.Code)
+	}
+	if _, err := st.GetLink(ctx, "wiki"); err != nil {
+		t.Errorf("removing a collection removed its link: %v", err)
+	}
+}
+
+func TestCollectionRoute(t *testing.T) {
+	st := store.NewMemory()
+	var got string
+	s := New(Config{}, st, Pages{Collection: func(w http.ResponseWriter, r *http.Request, name string) {
+		got = name
+	}})
+	do(t, s, http.MethodGet, "/+onboarding", nil, "", "")
+	if got != "onboarding" {
+		t.Errorf("collection page got %q", got)
+	}
+	do(t, s, http.MethodGet, "/+%F0%9F%8D%95%EF%B8%8F", nil, "", "")
+	if got != "🍕" {
+		t.Errorf("collection page got %q, want the canonical emoji name", got)
+	}
+}
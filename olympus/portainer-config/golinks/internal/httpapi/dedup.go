@@ -0,0 +1,111 @@
+package httpapi
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"golinks/internal/store"
+)
+
+// Reasons an import row is taken for a near-duplicate, the reason of an
+// ImportMerge.
+const (
+	// DuplicateSlug is a slug that only differs from another in case or
+	// punctuation, such as "Team-Wiki" for "team_wiki".
+	DuplicateSlug = "slug"
+	// DuplicateURL is a URL that is another link's once normalized, such
+	// as "https://Wiki.example.com/" for "https://wiki.example.com".
+	DuplicateURL = "url"
+)
+
+// ImportMerge suggests merging an import row into the link it nearly
+// duplicates, such as by renaming that link to the row's slug and keeping
+// its old slug as an alias.
+type ImportMerge struct {
+	Line int    `json:"line"`
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+	// Into is the existing link, or earlier row, the row duplicates.
+	Into   string `json:"into"`
+	Reason string `json:"reason"`
+}
+
+// dedupIndex finds the links an import row nearly duplicates, among the
+// existing links and the rows imported before it.
+type dedupIndex struct {
+	// slugs holds every slug as it is; bySlug and byURL map the keys of
+	// slugKey and urlKey to the first slug they were seen with.
+	slugs  map[string]bool
+	bySlug map[string]string
+	byURL  map[string]string
+}
+
+// newDedupIndex indexes the links of st.
+func newDedupIndex(ctx context.Context, st store.Store) (*dedupIndex, error) {
+	d := &dedupIndex{slugs: map[string]bool{}, bySlug: map[string]string{}, byURL: map[string]string{}}
+	err := st.EachLink(ctx, func(link store.Link) error {
+		d.add(link.Slug, link.URL)
+		return nil
+	})
+	return d, err
+}
+
+func (d *dedupIndex) add(slug, url string) {
+	d.slugs[slug] = true
+	if k := slugKey(slug); k != "" && d.bySlug[k] == "" {
+		d.bySlug[k] = slug
+	}
+	if k := urlKey(url); k != "" && d.byURL[k] == "" {
+		d.byURL[k] = slug
+	}
+}
+
+// find returns the slug a new link at slug and url nearly duplicates, and
+// why, or "" if it doesn't. A slug that is taken as it is isn't new, and is
+// left to the conflict handling of the import.
+func (d *dedupIndex) find(slug, url string) (into, reason string) {
+	if d.slugs[slug] {
+		return "", ""
+	}
+	if other := d.bySlug[slugKey(slug)]; other != "" {
+		return other, DuplicateSlug
+	}
+	if other := d.byURL[urlKey(url)]; other != "" {
+		return other, DuplicateURL
+	}
+	return "", ""
+}
+
+// slugKey is what near-duplicate slugs have in common: their letters and
+// digits, lowercased.
+func slugKey(slug string) string {
+	return normalizeWord(slug)
+}
+
+// urlKey is what URLs to the same page have in common: the scheme, a
+// leading "www.", a default port, a trailing slash, the fragment, tracking
+// parameters and the order of the query are left out, and the host is
+// lowercased. It is "" for what isn't an http(s) URL.
+func urlKey(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	query := u.Query()
+	for name := range query {
+		if strings.HasPrefix(strings.ToLower(name), "utm_") {
+			query.Del(name)
+		}
+	}
+	// Encode sorts the parameters by name
+	key := host + strings.TrimRight(u.EscapedPath(), "/")
+	if len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	return key
+}
@@ -0,0 +1,310 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golinks/internal/bookmarks"
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// Export formats. An import reads CSV and bookmarks.
+const (
+	ExportJSON      = "json"
+	ExportCSV       = "csv"
+	ExportBookmarks = "bookmarks"
+)
+
+// bookmarksFolder is the top folder of the links in a bookmarks export.
+const bookmarksFolder = "Go Links"
+
+// exportColumns are the columns of a CSV export. The first three are what
+// /admin/import reads. A column per custom field, named after it, follows.
+var exportColumns = []string{
+	"slug", "url", "tags", "status", "created_by", "approved_by", "public", "clicks",
+	"last_used_at", "created_at", "review_at", "review_months", "pin", "hit_budget", "failover",
+	"referrer",
+}
+
+// Export is a JSON export: every link with all its fields, every
+// collection and every alias.
+type Export struct {
+	ExportedAt  time.Time          `json:"exported_at"`
+	Collections []store.Collection `json:"collections"`
+	Aliases     []store.Alias      `json:"aliases"`
+	Links       []store.Link       `json:"links"`
+}
+
+// exportFlushRows is how many rows an export writes between flushes, so a
+// large export reaches the client as it is read.
+const exportFlushRows = 500
+
+// exportWriter buffers an export on its way to the client and flushes it
+// every exportFlushRows rows.
+type exportWriter struct {
+	*bufio.Writer
+	w    http.ResponseWriter
+	rows int
+}
+
+// row counts a written row, flushing if it is time to.
+func (e *exportWriter) row() error {
+	if e.rows++; e.rows%exportFlushRows != 0 {
+		return nil
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	http.NewResponseController(e.w).Flush()
+	return nil
+}
+
+// handleAdminExport dumps every link as JSON (default), with ?format=csv as
+// CSV with the link's collections as tags, or with ?format=bookmarks as a
+// bookmarks file browsers import, foldered by collection and namespace.
+// Links are written as they are read, so an export never holds them all.
+func (s *Server) handleAdminExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportJSON
+	}
+	if format != ExportJSON && format != ExportCSV && format != ExportBookmarks {
+		http.Error(w, "format must be json, csv or bookmarks", http.StatusBadRequest)
+		return
+	}
+
+	// Collections and aliases are few, and both CSV tags and bookmark
+	// folders need the collections before the first link
+	collections, err := s.store.ListCollections(r.Context())
+	var aliases []store.Alias
+	if err == nil && format == ExportJSON {
+		aliases, err = s.store.ListAliases(r.Context())
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error exporting links", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	if collections == nil {
+		collections = []store.Collection{}
+	}
+	if aliases == nil {
+		aliases = []store.Alias{}
+	}
+
+	exportedAt := time.Now().UTC()
+	ext := format
+	if format == ExportBookmarks {
+		ext = "html"
+	}
+	filename := "golinks-" + exportedAt.Format("2006-01-02") + "." + ext
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	out := &exportWriter{Writer: bufio.NewWriter(w), w: w}
+	switch format {
+	case ExportJSON:
+		w.Header().Set("Content-Type", "application/json")
+		err = s.exportJSON(r.Context(), out, exportedAt, collections, aliases)
+	case ExportBookmarks:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = s.exportBookmarks(r.Context(), out, collections)
+	default:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = s.exportCSV(r.Context(), out, collections)
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		// The status is sent, so the download is cut off to show it is
+		// not whole
+		slog.ErrorContext(r.Context(), "Error exporting links", "error", err, "links", out.rows)
+		panic(http.ErrAbortHandler)
+	}
+
+	slog.InfoContext(r.Context(), "Links exported", "links", out.rows, "format", format, "remote_addr", s.remote(r))
+}
+
+// exportJSON writes an Export, the links one at a time into an array
+// written by hand.
+func (s *Server) exportJSON(ctx context.Context, out *exportWriter, exportedAt time.Time, collections []store.Collection, aliases []store.Alias) error {
+	head, err := json.MarshalIndent(struct {
+		ExportedAt  time.Time          `json:"exported_at"`
+		Collections []store.Collection `json:"collections"`
+		Aliases     []store.Alias      `json:"aliases"`
+	}{exportedAt, collections, aliases}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The links go where the closing brace was
+	out.Write(head[:len(head)-2])
+	out.WriteString(`,
+  "links": [`)
+	sep := "\n    "
+	err = s.store.EachLinkBy(ctx, store.OrderAlpha, func(link store.Link) error {
+		b, err := json.MarshalIndent(link, "    ", "  ")
+		if err != nil {
+			return err
+		}
+		out.WriteString(sep)
+		out.Write(b)
+		sep = ",\n    "
+		return out.row()
+	})
+	if err != nil {
+		return err
+	}
+	if out.rows > 0 {
+		out.WriteString("\n  ")
+	}
+	_, err = out.WriteString("]\n}\n")
+	return err
+}
+
+// exportCSV writes a row per link, with its collections as tags.
+func (s *Server) exportCSV(ctx context.Context, out *exportWriter, collections []store.Collection) error {
+	tags := make(map[string][]string)
+	for _, c := range collections {
+		for _, slug := range c.Slugs {
+			tags[slug] = append(tags[slug], c.Name)
+		}
+	}
+	cw := csv.NewWriter(out)
+	columns := slices.Clone(exportColumns)
+	for _, f := range s.cfg.LinkFields {
+		columns = append(columns, f.Name)
+	}
+	cw.Write(columns)
+	err := s.store.EachLinkBy(ctx, store.OrderAlpha, func(link store.Link) error {
+		row := []string{
+			link.Slug,
+			link.URL,
+			strings.Join(tags[link.Slug], ";"),
+			link.Status,
+			link.CreatedBy,
+			link.ApprovedBy,
+			strconv.FormatBool(link.Public),
+			strconv.Itoa(link.Clicks),
+			csvTime(link.LastUsedAt),
+			csvTime(&link.CreatedAt),
+			csvTime(link.ReviewAt),
+			strconv.Itoa(link.ReviewMonths),
+			strconv.Itoa(link.Pin),
+			strconv.Itoa(link.HitBudget),
+			link.Failover,
+			link.Referrer,
+		}
+		for _, f := range s.cfg.LinkFields {
+			row = append(row, link.Fields[f.Name])
+		}
+		cw.Write(row)
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		return out.row()
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// csvTime formats t for a CSV export, empty if nil or zero.
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// exportBookmarks writes links as bookmarks titled "go/slug", leaving out
+// reserved slugs, which go nowhere. A link in collections is filed in a
+// folder per collection; any other link in the folder of its namespace, so
+// team/infra/oncall is in "team" > "infra", or at the top. Links are read
+// in slug order, which keeps each namespace's together, and a collection's
+// folder is written before the first link of a namespace sorting after it,
+// so a collection and a namespace of the same name share one folder.
+func (s *Server) exportBookmarks(ctx context.Context, out *exportWriter, collections []store.Collection) error {
+	bw := bookmarks.NewWriter(out, "Bookmarks")
+	collected := make(map[string]bool)
+	for _, c := range collections {
+		for _, slug := range c.Slugs {
+			collected[slug] = true
+		}
+	}
+	collections = slices.Clone(collections)
+	slices.SortFunc(collections, func(a, b store.Collection) int { return strings.Compare(a.Name, b.Name) })
+	add := func(link store.Link, folders []string) error {
+		if err := bw.Add(bookmarks.Bookmark{
+			Title:   "go/" + link.Slug,
+			URL:     link.URL,
+			Folders: folders,
+			AddDate: link.CreatedAt,
+		}); err != nil {
+			return err
+		}
+		return out.row()
+	}
+	// addCollections writes the folders of the collections named up to
+	// name, or of all that are left if name is ""
+	addCollections := func(name string) error {
+		for len(collections) > 0 && (name == "" || collections[0].Name <= name) {
+			c := collections[0]
+			collections = collections[1:]
+			slugs := slices.Clone(c.Slugs)
+			slices.Sort(slugs)
+			for _, slug := range slugs {
+				link, err := s.store.GetLink(ctx, slug)
+				if errors.Is(err, store.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if link.URL == "" {
+					continue
+				}
+				if err := add(*link, []string{bookmarksFolder, c.Name}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	err := s.store.EachLinkBy(ctx, store.OrderAlpha, func(link store.Link) error {
+		if link.URL == "" || collected[link.Slug] {
+			return nil
+		}
+		namespace := strings.Split(link.Slug, "/")
+		if len(namespace) > 1 {
+			if err := addCollections(namespace[0]); err != nil {
+				return err
+			}
+		}
+		return add(link, append([]string{bookmarksFolder}, namespace[:len(namespace)-1]...))
+	})
+	if err == nil {
+		err = addCollections("")
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Close()
+}
@@ -0,0 +1,188 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/bookmarks"
+	"golinks/internal/store"
+)
+
+func TestAdminExport(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", Status: store.StatusActive, CreatedBy: "alice", Public: true})
+	st.AddLink(ctx, store.Link{Slug: "hr", URL: "https://hr.example.com", Status: store.StatusActive})
+	st.SetHitBudget(ctx, "hr", 50)
+	st.SaveCollection(ctx, store.Collection{Name: "docs", Slugs: []string{"wiki", "hr"}})
+	st.SaveCollection(ctx, store.Collection{Name: "people", Slugs: []string{"hr"}})
+	st.SetAlias(ctx, store.Alias{Slug: "w", Target: "wiki"})
+
+	rec := do(t, s, http.MethodGet, "/admin/export", nil, "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), ".json") {
+		t.Fatalf("JSON export = %d %v", rec.Code, rec.Header())
+	}
+	var export Export
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Links) != 2 || export.Links[0].Slug != "hr" || export.Links[0].HitBudget != 50 || export.Links[1].CreatedBy != "alice" || len(export.Collections) != 2 ||
+		!reflect.DeepEqual(export.Aliases, []store.Alias{{Slug: "w", Target: "wiki"}}) {
+		t.Errorf("JSON export = %+v", export)
+	}
+
+	rec = do(t, s, http.MethodGet, "/admin/export?format=csv", nil, "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("CSV export = %d %v", rec.Code, rec.Header())
+	}
+	rows, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("CSV export = %q, %v", rows, err)
+	}
+	if strings.Join(rows[0][:4], ",") != "slug,url,tags,status" || strings.Join(rows[1][:3], ",") != "hr,https://hr.example.com,docs;people" || rows[2][4] != "alice" || rows[2][6] != "true" {
+		t.Errorf("CSV export = %q", rows)
+	}
+	if _, err := time.Parse(time.RFC3339, rows[1][9]); err != nil {
+		t.Errorf("created_at = %q: %v", rows[1][9], err)
+	}
+
+	// The CSV imports into another instance as it is
+	other, otherStore := newTestServer(t, Config{})
+	code, report := postImport(t, other, "", rec.Body.String())
+	if got := results(report); code != http.StatusOK || got != "hr:created wiki:created" {
+		t.Errorf("import of export = %d %s", code, got)
+	}
+	if c, _ := otherStore.GetCollection(ctx, "docs"); c == nil || strings.Join(c.Slugs, " ") != "hr wiki" {
+		t.Errorf("imported docs = %+v", c)
+	}
+
+	if rec := do(t, s, http.MethodGet, "/admin/export?format=xml", nil, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("format=xml = %d", rec.Code)
+	}
+}
+
+func TestAdminExportStreams(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	n := 2*exportFlushRows + 1
+	for i := range n {
+		st.AddLink(ctx, store.Link{Slug: fmt.Sprintf("team/link%04d", i), URL: "https://example.com", Status: store.StatusActive})
+	}
+	st.AddLink(ctx, store.Link{Slug: "hr", URL: "https://hr.example.com", Status: store.StatusActive})
+	st.SaveCollection(ctx, store.Collection{Name: "team", Slugs: []string{"hr"}})
+
+	var export Export
+	rec := do(t, s, http.MethodGet, "/admin/export", nil, "", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &export); err != nil || len(export.Links) != n+1 || len(export.Collections) != 1 {
+		t.Errorf("JSON export of %d links = %d links, %v", n+1, len(export.Links), err)
+	}
+	rec = do(t, s, http.MethodGet, "/admin/export?format=csv", nil, "", "")
+	if rows, err := csv.NewReader(rec.Body).ReadAll(); err != nil || len(rows) != n+2 {
+		t.Errorf("CSV export of %d links = %d rows, %v", n+1, len(rows), err)
+	}
+
+	// The collection shares its folder with the namespace of its name
+	rec = do(t, s, http.MethodGet, "/admin/export?format=bookmarks", nil, "", "")
+	if got := strings.Count(rec.Body.String(), "<H3>team</H3>"); got != 1 {
+		t.Errorf("bookmarks have %d team folders, want 1", got)
+	}
+	marks, err := bookmarks.Parse(rec.Body)
+	if err != nil || len(marks) != n+1 {
+		t.Fatalf("bookmarks export of %d links = %d marks, %v", n+1, len(marks), err)
+	}
+	if marks[0].Title != "go/hr" || strings.Join(marks[0].Folders, "/") != "Go Links/team" {
+		t.Errorf("first bookmark = %+v, want go/hr in Go Links/team", marks[0])
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", Status: store.StatusActive})
+	st.AddLink(ctx, store.Link{Slug: "soon", Status: store.StatusReserved})
+
+	rec := do(t, s, http.MethodGet, "/admin/export?format=bookmarks", nil, "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), ".html") {
+		t.Fatalf("bookmarks export = %d %v", rec.Code, rec.Header())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `<A HREF="https://wiki.example.com" ADD_DATE=`) || !strings.Contains(body, ">go/wiki</A>") || strings.Contains(body, "soon") {
+		t.Errorf("bookmarks export =\n%s", body)
+	}
+
+	// The export imports as it is, keeping the slugs
+	other, _ := newTestServer(t, Config{})
+	if code, report := postImport(t, other, "?format=bookmarks", body); code != http.StatusOK || results(report) != "wiki:created" {
+		t.Errorf("import of export = %d %s", code, results(report))
+	}
+
+	// Links are filed by collection, or else by namespace
+	st.AddLink(ctx, store.Link{Slug: "team/infra/oncall", URL: "https://oncall.example.com", Status: store.StatusActive})
+	st.AddLink(ctx, store.Link{Slug: "team/roadmap", URL: "https://roadmap.example.com", Status: store.StatusActive})
+	st.AddLink(ctx, store.Link{Slug: "hr", URL: "https://hr.example.com", Status: store.StatusActive})
+	st.SaveCollection(ctx, store.Collection{Name: "onboarding", Slugs: []string{"wiki", "team/roadmap"}})
+	st.SaveCollection(ctx, store.Collection{Name: "docs", Slugs: []string{"wiki"}})
+	rec = do(t, s, http.MethodGet, "/admin/export?format=bookmarks", nil, "", "")
+	marks, err := bookmarks.Parse(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filed []string
+	for _, b := range marks {
+		filed = append(filed, strings.Join(b.Folders, "/")+": "+b.Title)
+	}
+	wantFiled := []string{
+		"Go Links: go/hr",
+		"Go Links/docs: go/wiki",
+		"Go Links/onboarding: go/team/roadmap",
+		"Go Links/onboarding: go/wiki",
+		"Go Links/team/infra: go/team/infra/oncall",
+	}
+	if !slices.Equal(filed, wantFiled) {
+		t.Errorf("bookmarks = %q, want %q", filed, wantFiled)
+	}
+
+	file := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<DL><p>
+    <DT><H3>Team Docs</H3>
+    <DL><p>
+        <DT><A HREF="https://handbook.example.com/">Employee Handbook</A>
+        <DT><A HREF="https://other.example.com/handbook">Employee handbook!</A>
+        <DT><A HREF="https://handbook.example.com/">Employee Handbook</A>
+        <DT><A HREF="https://example.com/q3-roadmap.pdf"></A>
+    </DL><p>
+    <DT><A HREF="https://wiki.example.com/">Wiki</A>
+</DL><p>
+`
+	req := httptest.NewRequest(http.MethodPost, "/admin/import?folders=true", strings.NewReader(file))
+	req.Header.Set("Content-Type", "text/html")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	var report ImportReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("bookmarks import = %d %s", rec.Code, rec.Body)
+	}
+	want := "employee-handbook:created employee-handbook-2:created employee-handbook:skipped q3-roadmap:created wiki:skipped"
+	if got := results(report); got != want {
+		t.Errorf("bookmarks import = %s, want %s", got, want)
+	}
+	if row := report.Rows[1]; row.Line != 6 || row.Title != "Employee handbook!" || strings.Join(row.Tags, " ") != "team-docs" {
+		t.Errorf("row = %+v", row)
+	}
+	if c, _ := st.GetCollection(ctx, "team-docs"); c == nil || strings.Join(c.Slugs, " ") != "employee-handbook employee-handbook-2 q3-roadmap" {
+		t.Errorf("team-docs = %+v", c)
+	}
+
+	if code, _ := postImport(t, s, "?format=xml", file); code != http.StatusBadRequest {
+		t.Errorf("unknown format = %d", code)
+	}
+}
@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"golinks/internal/store"
+)
+
+// fallbackSize is how many slugs the fallback of a LookupBudget remembers.
+const fallbackSize = 10000
+
+// staleHeader marks a redirect served from the fallback, with how many
+// seconds ago its link was read.
+const staleHeader = "X-Golinks-Stale"
+
+// fallbackLinks remembers the last lookup of the most recently used slugs,
+// to redirect with when the store takes longer than the LookupBudget.
+// Unlike a cache it is never read while the store answers in time, so it
+// has no TTL: every lookup refreshes its slug, and one that finds no link
+// drops it.
+type fallbackLinks struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type fallbackEntry struct {
+	// slug is the slug looked up, and resolved the link's own slug if that
+	// was an alias.
+	slug, resolved string
+	link           store.Link
+	at             time.Time
+}
+
+func newFallbackLinks() *fallbackLinks {
+	return &fallbackLinks{order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// update records the result of looking up slug.
+func (f *fallbackLinks) update(slug, resolved string, link *store.Link, err error, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case err == nil:
+		if el, ok := f.entries[slug]; ok {
+			f.order.Remove(el)
+		}
+		f.entries[slug] = f.order.PushFront(&fallbackEntry{slug: slug, resolved: resolved, link: *link, at: at})
+		for f.order.Len() > fallbackSize {
+			el := f.order.Back()
+			delete(f.entries, el.Value.(*fallbackEntry).slug)
+			f.order.Remove(el)
+		}
+	case errors.Is(err, store.ErrNotFound):
+		if el, ok := f.entries[slug]; ok {
+			delete(f.entries, slug)
+			f.order.Remove(el)
+		}
+	}
+	// On other errors the last link found is kept, as it is no worse
+}
+
+// get returns the last link found at slug.
+func (f *fallbackLinks) get(slug string) (fallbackEntry, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	el, ok := f.entries[slug]
+	if !ok {
+		return fallbackEntry{}, false
+	}
+	f.order.MoveToFront(el)
+	return *el.Value.(*fallbackEntry), true
+}
+
+type lookupResult struct {
+	slug string
+	link *store.Link
+	err  error
+}
+
+// lookupWithinBudget is lookupLink, except that a lookup taking longer than
+// the LookupBudget is answered with the last link found at slug, if there
+// is one, read at stale. A fresh answer has a zero stale. Without a link to
+// fall back on it waits for the store as lookupLink does.
+func (s *Server) lookupWithinBudget(ctx context.Context, slug string) (resolved string, link *store.Link, stale time.Time, err error) {
+	if s.fallback == nil {
+		resolved, link, err = s.lookupLink(ctx, slug)
+		return resolved, link, time.Time{}, err
+	}
+	done := make(chan lookupResult, 1)
+	go func() {
+		// A redirect answered from the fallback does not cancel the lookup,
+		// which still refreshes the fallback for the next one
+		at := time.Now()
+		resolved, link, err := s.lookupLink(context.WithoutCancel(ctx), slug)
+		s.fallback.update(slug, resolved, link, err, at)
+		done <- lookupResult{resolved, link, err}
+	}()
+	timer := time.NewTimer(s.cfg.LookupBudget)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.slug, res.link, time.Time{}, res.err
+	case <-timer.C:
+	}
+	if e, ok := s.fallback.get(slug); ok {
+		slog.WarnContext(ctx, "Lookup over budget, redirecting with a stale link", "slug", slug, "age", time.Since(e.at).Round(time.Second).String())
+		return e.resolved, &e.link, e.at, nil
+	}
+	select {
+	case res := <-done:
+		return res.slug, res.link, time.Time{}, res.err
+	case <-ctx.Done():
+		return slug, nil, time.Time{}, ctx.Err()
+	}
+}
@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+// slowStore takes its time to get links while slow is set.
+type slowStore struct {
+	store.Store
+	slow atomic.Bool
+}
+
+func (s *slowStore) GetLink(ctx context.Context, slug string) (*store.Link, error) {
+	if s.slow.Load() {
+		time.Sleep(200 * time.Millisecond)
+	}
+	return s.Store.GetLink(ctx, slug)
+}
+
+func TestLookupBudget(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory()
+	st := &slowStore{Store: mem}
+	s := New(Config{LookupBudget: 10 * time.Millisecond}, st, Pages{})
+	mem.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	rec := do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	if rec.Code != http.StatusFound || rec.Header().Get(staleHeader) != "" {
+		t.Fatalf("redirect = %d, %v", rec.Code, rec.Header())
+	}
+
+	mem.UpdateLink(ctx, store.Link{Slug: "wiki", URL: "https://new-wiki.example.com"})
+	st.slow.Store(true)
+	rec = do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://wiki.example.com" || rec.Header().Get(staleHeader) != "0" {
+		t.Errorf("slow redirect = %d, %v", rec.Code, rec.Header())
+	}
+	// A slug never looked up waits for the store
+	if rec := do(t, s, http.MethodGet, "/missing", nil, "", ""); rec.Code != http.StatusNotFound || rec.Header().Get(staleHeader) != "" {
+		t.Errorf("slow miss = %d, %v", rec.Code, rec.Header())
+	}
+
+	st.slow.Store(false)
+	rec = do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	if rec.Header().Get("Location") != "https://new-wiki.example.com" || rec.Header().Get(staleHeader) != "" {
+		t.Errorf("redirect once fast again = %d, %v", rec.Code, rec.Header())
+	}
+}
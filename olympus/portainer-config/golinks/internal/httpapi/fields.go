@@ -0,0 +1,170 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// maxFieldValue bounds the length of a custom field value, in characters.
+const maxFieldValue = 200
+
+// LinkField is a custom field admins may set on links, such as the cost
+// center or room of an internal tool.
+type LinkField struct {
+	// Name is the key the value is stored and filtered under, such as
+	// "cost_center".
+	Name string `json:"name"`
+	// Label is what pages show, such as "Cost center".
+	Label string `json:"label"`
+}
+
+// FieldValue is a custom field together with its value on a link, as pages
+// show it.
+type FieldValue struct {
+	LinkField
+	Value string
+}
+
+// FieldValues returns every field of fields with its value in values, in
+// order.
+func FieldValues(fields []LinkField, values map[string]string) []FieldValue {
+	var out []FieldValue
+	for _, f := range fields {
+		out = append(out, FieldValue{LinkField: f, Value: values[f.Name]})
+	}
+	return out
+}
+
+type SetFieldsRequest struct {
+	Slug string `json:"slug"`
+	// Fields replace all custom fields of the link; an empty value
+	// removes a field.
+	Fields map[string]string `json:"fields"`
+}
+
+// checkFields validates custom fields against Config.LinkFields and returns
+// them trimmed, without empty values, or an *InvalidError.
+func (s *Server) checkFields(fields map[string]string) (map[string]string, error) {
+	var checked map[string]string
+	for name, value := range fields {
+		if !s.isLinkField(name) {
+			return nil, &InvalidError{Msg: fmt.Sprintf("Unknown field %q", name), Field: "fields"}
+		}
+		value = strings.TrimSpace(value)
+		if utf8.RuneCountInString(value) > maxFieldValue {
+			return nil, &InvalidError{Msg: fmt.Sprintf("Field %s is longer than %d characters", name, maxFieldValue), Field: "fields"}
+		}
+		if value == "" {
+			continue
+		}
+		if checked == nil {
+			checked = make(map[string]string)
+		}
+		checked[name] = value
+	}
+	return checked, nil
+}
+
+func (s *Server) isLinkField(name string) bool {
+	for _, f := range s.cfg.LinkFields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldFilter returns the custom field filters of a query, given as
+// field.<name>=<value>, or an *InvalidError for an unknown field.
+func (s *Server) fieldFilter(query url.Values) (map[string]string, error) {
+	var filter map[string]string
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "field.")
+		if !ok {
+			continue
+		}
+		if !s.isLinkField(name) {
+			return nil, &InvalidError{Msg: fmt.Sprintf("Unknown field %q", name), Field: key}
+		}
+		if filter == nil {
+			filter = make(map[string]string)
+		}
+		filter[name] = strings.TrimSpace(values[0])
+	}
+	return filter, nil
+}
+
+// matchFields reports whether link has every field of filter, ignoring
+// case. An empty filter value matches links without the field.
+func matchFields(link store.Link, filter map[string]string) bool {
+	for name, want := range filter {
+		if !strings.EqualFold(link.Fields[name], want) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleAdminFields lists the custom fields links may have (GET) or
+// replaces those of one link (POST).
+func (s *Server) handleAdminFields(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		fields := s.cfg.LinkFields
+		if fields == nil {
+			fields = []LinkField{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fields)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetFieldsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	fields, err := s.checkFields(req.Fields)
+	var invalid *InvalidError
+	if errors.As(err, &invalid) {
+		writeInvalid(w, invalid)
+		return
+	}
+
+	if err := s.store.SetFields(r.Context(), req.Slug, fields); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Custom fields set", "slug", req.Slug, "fields", len(fields), "remote_addr", s.remote(r))
+
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": "updated",
+		"slug":   req.Slug,
+		"fields": fields,
+	})
+}
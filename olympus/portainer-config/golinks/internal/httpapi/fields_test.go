@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestAdminFields(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{LinkFields: []LinkField{{Name: "cost_center", Label: "Cost center"}, {Name: "room", Label: "Room"}}})
+	st.AddLink(ctx, store.Link{Slug: "grafana", URL: "https://grafana.example.com"})
+
+	rec := do(t, s, http.MethodGet, "/admin/fields", nil, "", "")
+	var defined []LinkField
+	if err := json.NewDecoder(rec.Body).Decode(&defined); err != nil || len(defined) != 2 || defined[0].Label != "Cost center" {
+		t.Errorf("GET /admin/fields = %v, %v", defined, err)
+	}
+
+	tests := []struct {
+		name string
+		body SetFieldsRequest
+		want int
+	}{
+		{"unknown field", SetFieldsRequest{Slug: "grafana", Fields: map[string]string{"owner": "ops"}}, http.StatusBadRequest},
+		{"too long", SetFieldsRequest{Slug: "grafana", Fields: map[string]string{"room": string(make([]byte, maxFieldValue+1))}}, http.StatusBadRequest},
+		{"missing", SetFieldsRequest{Slug: "nope", Fields: map[string]string{"room": "B12"}}, http.StatusNotFound},
+		{"empty slug", SetFieldsRequest{Fields: map[string]string{"room": "B12"}}, http.StatusBadRequest},
+		{"set", SetFieldsRequest{Slug: "grafana", Fields: map[string]string{"cost_center": " CC-42 ", "room": ""}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, http.MethodPost, "/admin/fields", tt.body, "", "")
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+	if link, _ := st.GetLink(ctx, "grafana"); !maps.Equal(link.Fields, map[string]string{"cost_center": "CC-42"}) {
+		t.Errorf("fields = %v", link.Fields)
+	}
+}
+
+func TestListLinksByField(t *testing.T) {
+	s, _ := newTestServer(t, Config{LinkFields: []LinkField{{Name: "room", Label: "Room"}}})
+	for _, req := range []AddLinkRequest{
+		{Slug: "printer", URL: "https://printer.example.com", Fields: map[string]string{"room": "B12"}},
+		{Slug: "nas", URL: "https://nas.example.com", Fields: map[string]string{"room": "Basement"}},
+		{Slug: "wiki", URL: "https://wiki.example.com"},
+	} {
+		if rec := do(t, s, http.MethodPost, "/api/v1/links", req, "", ""); rec.Code != http.StatusCreated {
+			t.Fatalf("add %s: %d %s", req.Slug, rec.Code, rec.Body)
+		}
+	}
+	if rec := do(t, s, http.MethodPost, "/api/v1/links", AddLinkRequest{Slug: "tv", URL: "https://tv.example.com", Fields: map[string]string{"owner": "me"}}, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("add with unknown field: %d", rec.Code)
+	}
+
+	for query, want := range map[string][]string{
+		"/api/links?field.room=b12":              {"printer"},
+		"/api/links?field.room=":                 {"wiki"},
+		"/api/links?field.room=Basement&q=print": {},
+	} {
+		rec := do(t, s, http.MethodGet, query, nil, "", "")
+		var page LinkPage
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: %d %v", query, rec.Code, err)
+		}
+		var got []string
+		for _, l := range page.Links {
+			got = append(got, l.Slug)
+		}
+		if len(got) != len(want) || (len(want) > 0 && got[0] != want[0]) {
+			t.Errorf("%s = %v, want %v", query, got, want)
+		}
+	}
+	if rec := do(t, s, http.MethodGet, "/api/links?field.owner=me", nil, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown field filter: %d", rec.Code)
+	}
+}
@@ -0,0 +1,336 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golinks/internal/graphql"
+	"golinks/internal/store"
+)
+
+// maxGraphQLBody limits the size of a POSTed GraphQL request.
+const maxGraphQLBody = 1 << 20
+
+// topLink is a link and its clicks in a period, as listed by topLinks.
+type topLink struct {
+	link   store.Link
+	clicks int
+}
+
+// newGraphSchema describes links, collections and click stats for
+// /graphql. Arguments are checked by the graphql package, so resolvers
+// may assert their types.
+func (s *Server) newGraphSchema() *graphql.Schema {
+	str := func(get func(store.Link) string) func(context.Context, any, map[string]any) (any, error) {
+		return func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return get(src.(store.Link)), nil
+		}
+	}
+	num := func(get func(store.Link) int) func(context.Context, any, map[string]any) (any, error) {
+		return func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return get(src.(store.Link)), nil
+		}
+	}
+	days := graphql.Arg{Name: "days", Type: "Int", Default: defaultStatsDays}
+
+	linkType := &graphql.Object{Name: "Link", Fields: []*graphql.Field{
+		{Name: "slug", Type: "String!", Resolve: str(func(l store.Link) string { return l.Slug })},
+		{Name: "url", Type: "String!", Resolve: str(func(l store.Link) string { return l.URL })},
+		{Name: "status", Type: "String!", Description: "active, pending or reserved", Resolve: str(func(l store.Link) string { return l.Status })},
+		{Name: "createdBy", Type: "String!", Resolve: str(func(l store.Link) string { return l.CreatedBy })},
+		{Name: "approvedBy", Type: "String!", Resolve: str(func(l store.Link) string { return l.ApprovedBy })},
+		{Name: "createdAt", Type: "String!", Description: "RFC 3339", Resolve: str(func(l store.Link) string { return l.CreatedAt.UTC().Format(time.RFC3339) })},
+		{Name: "updatedAt", Type: "String!", Description: "RFC 3339", Resolve: str(func(l store.Link) string { return l.UpdatedAt.UTC().Format(time.RFC3339) })},
+		{Name: "failover", Type: "String!", Resolve: str(func(l store.Link) string { return l.Failover })},
+		{Name: "referrer", Type: "String!", Description: "strip, replace, or empty to leave it to the browser", Resolve: str(func(l store.Link) string { return l.Referrer })},
+		{Name: "clicks", Type: "Int!", Description: "All-time click count", Resolve: num(func(l store.Link) int { return l.Clicks })},
+		{Name: "listOpens", Type: "Int!", Description: "Clicks opened from the list page", Resolve: num(func(l store.Link) int { return l.ListOpens })},
+		{Name: "pin", Type: "Int!", Resolve: num(func(l store.Link) int { return l.Pin })},
+		{Name: "hitBudget", Type: "Int!", Resolve: num(func(l store.Link) int { return l.HitBudget })},
+		{
+			Name: "field", Type: "String", Description: "A custom field, null if unset",
+			Args: []graphql.Arg{{Name: "name", Type: "String!"}},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				if v, ok := src.(store.Link).Fields[args["name"].(string)]; ok {
+					return v, nil
+				}
+				return nil, nil
+			},
+		},
+		{Name: "public", Type: "Boolean!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(store.Link).Public, nil
+		}},
+		{Name: "lastUsedAt", Type: "String", Description: "RFC 3339, null if never used", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			if at := src.(store.Link).LastUsedAt; at != nil {
+				return at.UTC().Format(time.RFC3339), nil
+			}
+			return nil, nil
+		}},
+		{
+			Name: "daily", Type: "[DayClicks!]!", Description: "Clicks on each of the last days days, today last",
+			Args: []graphql.Arg{days},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				n, err := statsDays(args)
+				if err != nil {
+					return nil, err
+				}
+				return s.dailyClicks(ctx, src.(store.Link).Slug, n)
+			},
+		},
+		{
+			Name: "recentClicks", Type: "Int!", Description: "Clicks in the last days days",
+			Args: []graphql.Arg{days},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				n, err := statsDays(args)
+				if err != nil {
+					return nil, err
+				}
+				times, err := s.store.ClickTimes(ctx, src.(store.Link).Slug, time.Now().AddDate(0, 0, -n))
+				return len(times), err
+			},
+		},
+		{
+			Name: "collections", Type: "[Collection!]!", Description: "The collections the link is in",
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				all, err := s.store.ListCollections(ctx)
+				if err != nil {
+					return nil, err
+				}
+				slug := src.(store.Link).Slug
+				return slices.DeleteFunc(all, func(c store.Collection) bool { return !slices.Contains(c.Slugs, slug) }), nil
+			},
+		},
+	}}
+
+	collectionType := &graphql.Object{Name: "Collection", Fields: []*graphql.Field{
+		{Name: "name", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(store.Collection).Name, nil
+		}},
+		{Name: "title", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(store.Collection).Title, nil
+		}},
+		{Name: "description", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(store.Collection).Description, nil
+		}},
+		{Name: "createdBy", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(store.Collection).CreatedBy, nil
+		}},
+		{Name: "createdAt", Type: "String!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(store.Collection).CreatedAt.UTC().Format(time.RFC3339), nil
+		}},
+		{Name: "links", Type: "[Link!]!", Description: "In collection order; removed links are left out", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			var links []store.Link
+			for _, slug := range src.(store.Collection).Slugs {
+				link, err := s.store.GetLink(ctx, slug)
+				if errors.Is(err, store.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				links = append(links, *link)
+			}
+			return links, nil
+		}},
+	}}
+
+	dayType := &graphql.Object{Name: "DayClicks", Fields: []*graphql.Field{
+		{Name: "date", Type: "String!", Description: "YYYY-MM-DD, local time", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(DayClicks).Date, nil
+		}},
+		{Name: "clicks", Type: "Int!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(DayClicks).Clicks, nil
+		}},
+	}}
+
+	linkClicksType := &graphql.Object{Name: "LinkClicks", Fields: []*graphql.Field{
+		{Name: "link", Type: "Link!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(topLink).link, nil
+		}},
+		{Name: "clicks", Type: "Int!", Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+			return src.(topLink).clicks, nil
+		}},
+	}}
+
+	var orders []string
+	for _, o := range store.LinkOrders {
+		orders = append(orders, strings.ToUpper(string(o)))
+	}
+	orderType := &graphql.EnumType{Name: "LinkOrder", Values: orders}
+
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{
+			Name: "link", Type: "Link", Description: "The link at slug, following renames; null if there is none",
+			Args: []graphql.Arg{{Name: "slug", Type: "String!"}},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				_, link, err := s.lookupLink(ctx, canonicalSlug(strings.TrimSpace(args["slug"].(string))))
+				if errors.Is(err, store.ErrNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				return *link, nil
+			},
+		},
+		{
+			Name: "links", Type: "[Link!]!", Description: "Links whose slug or URL contains q, a page at a time",
+			Args: []graphql.Arg{
+				{Name: "q", Type: "String"},
+				{Name: "order", Type: "LinkOrder", Default: "NEWEST"},
+				{Name: "reverse", Type: "Boolean", Default: false},
+				{Name: "first", Type: "Int", Default: defaultPageSize},
+				{Name: "offset", Type: "Int", Default: 0},
+			},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				first, _ := args["first"].(int)
+				offset, _ := args["offset"].(int)
+				if first < 0 || first > maxPageSize || offset < 0 {
+					return nil, fmt.Errorf("first must be between 0 and %d and offset not negative", maxPageSize)
+				}
+				order, _ := args["order"].(string)
+				reverse, _ := args["reverse"].(bool)
+				q, _ := args["q"].(string)
+				links, err := s.searchLinks(ctx, q, nil, store.LinkOrder(cmp.Or(strings.ToLower(order), string(store.OrderNewest))), reverse)
+				if err != nil {
+					return nil, err
+				}
+				start := min(offset, len(links))
+				return links[start:min(start+first, len(links))], nil
+			},
+		},
+		{
+			Name: "linkCount", Type: "Int!", Description: "How many links links would list over all pages",
+			Args: []graphql.Arg{{Name: "q", Type: "String"}},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				q, _ := args["q"].(string)
+				if strings.TrimSpace(q) == "" {
+					return s.store.CountLinks(ctx)
+				}
+				links, err := s.searchLinks(ctx, q, nil, store.OrderNewest, false)
+				return len(links), err
+			},
+		},
+		{
+			Name: "collection", Type: "Collection", Args: []graphql.Arg{{Name: "name", Type: "String!"}},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				c, err := s.store.GetCollection(ctx, args["name"].(string))
+				if errors.Is(err, store.ErrNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				return *c, nil
+			},
+		},
+		{
+			Name: "collections", Type: "[Collection!]!",
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				return s.store.ListCollections(ctx)
+			},
+		},
+		{
+			Name: "topLinks", Type: "[LinkClicks!]!", Description: "The most clicked links of the last days days",
+			Args: []graphql.Arg{days, {Name: "first", Type: "Int", Default: 10}},
+			Resolve: func(ctx context.Context, src any, args map[string]any) (any, error) {
+				n, err := statsDays(args)
+				if err != nil {
+					return nil, err
+				}
+				first, _ := args["first"].(int)
+				if first < 0 || first > maxPageSize {
+					return nil, fmt.Errorf("first must be between 0 and %d", maxPageSize)
+				}
+				counts, err := s.store.ClickCounts(ctx, time.Now().AddDate(0, 0, -n))
+				if err != nil {
+					return nil, err
+				}
+				top := make([]topLink, 0, len(counts))
+				for slug, clicks := range counts {
+					top = append(top, topLink{link: store.Link{Slug: slug}, clicks: clicks})
+				}
+				slices.SortFunc(top, func(a, b topLink) int {
+					return cmp.Or(b.clicks-a.clicks, strings.Compare(a.link.Slug, b.link.Slug))
+				})
+				var result []topLink
+				for _, lc := range top {
+					if len(result) == first {
+						break
+					}
+					link, err := s.store.GetLink(ctx, lc.link.Slug)
+					if errors.Is(err, store.ErrNotFound) {
+						continue
+					}
+					if err != nil {
+						return nil, err
+					}
+					result = append(result, topLink{link: *link, clicks: lc.clicks})
+				}
+				return result, nil
+			},
+		},
+	}}
+
+	schema, err := graphql.NewSchema(query, linkType, collectionType, dayType, linkClicksType, orderType)
+	if err != nil {
+		// The schema is fixed, so this is a bug caught by any test
+		panic(err)
+	}
+	return schema
+}
+
+// statsDays returns the days argument, which must be in the range of the
+// link stats API.
+func statsDays(args map[string]any) (int, error) {
+	n, _ := args["days"].(int)
+	if n < 1 || n > maxStatsDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", maxStatsDays)
+	}
+	return n, nil
+}
+
+// handleGraphQL serves /graphql: queries as POSTed JSON or in the query
+// parameters of a GET. A GET without a query returns the schema.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if query.Get("query") == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(s.graph.String()))
+			return
+		}
+		req.Query, req.OperationName = query.Get("query"), query.Get("operationName")
+		if v := query.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables JSON", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBody)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := s.graph.Execute(r.Context(), req)
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Data == nil {
+		// The query could not run at all
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/graphql"
+	"golinks/internal/store"
+)
+
+func TestGraphQL(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "mail", URL: "https://mail.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "cal", URL: "https://cal.example.com"})
+	st.SaveCollection(ctx, store.Collection{Name: "work", Title: "Work", Slugs: []string{"mail", "cal"}})
+	now := time.Now()
+	st.RecordClicks(ctx, []store.Click{{Slug: "mail", At: now}, {Slug: "mail", At: now}, {Slug: "wiki", At: now}, {Slug: "cal", At: now.AddDate(0, 0, -60)}})
+
+	query := `query Dashboard($q: String) {
+		count: linkCount
+		matching: links(q: $q, order: ALPHA) { slug url }
+		top: topLinks(days: 7) { link { slug } clicks }
+		collection(name: "work") { title links { slug collections { name } } }
+		link(slug: "mail") { clicks recentClicks(days: 7) daily(days: 2) { clicks } }
+		missing: link(slug: "nope") { slug }
+	}`
+	rec := do(t, s, http.MethodPost, "/graphql", graphql.Request{Query: query, Variables: map[string]any{"q": "MAIL"}}, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+	want := `{"data":{"count":3,` +
+		`"matching":[{"slug":"mail","url":"https://mail.example.com"}],` +
+		`"top":[{"link":{"slug":"mail"},"clicks":2},{"link":{"slug":"wiki"},"clicks":1}],` +
+		`"collection":{"title":"Work","links":[{"slug":"mail","collections":[{"name":"work"}]},{"slug":"cal","collections":[{"name":"work"}]}]},` +
+		`"link":{"clicks":2,"recentClicks":2,"daily":[{"clicks":0},{"clicks":2}]},` +
+		`"missing":null}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("response:\n%s\nwant\n%s", got, want)
+	}
+
+	// GET takes the query as a parameter
+	rec = do(t, s, http.MethodGet, "/graphql?query="+url.QueryEscape(`{ links(first: 1, order: ALPHA, reverse: true) { slug } }`), nil, "", "")
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != `{"data":{"links":[{"slug":"wiki"}]}}` {
+		t.Errorf("GET: %d %s", rec.Code, got)
+	}
+
+	// Field errors come with data, request errors without
+	rec = do(t, s, http.MethodPost, "/graphql", graphql.Request{Query: `{ link(slug: "mail") { daily(days: 0) { clicks } } count: linkCount }`}, "", "")
+	var resp graphql.Response
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || string(resp.Data) != `{"link":null,"count":3}` || len(resp.Errors) != 1 {
+		t.Errorf("field error: %d %s", rec.Code, rec.Body)
+	}
+	st.SetFields(ctx, "mail", map[string]string{"room": "B12"})
+	rec = do(t, s, http.MethodPost, "/graphql", graphql.Request{Query: `{ link(slug: "mail") { room: field(name: "room") owner: field(name: "owner") } }`}, "", "")
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"data":{"link":{"room":"B12","owner":null}}}` {
+		t.Errorf("custom fields: %s", got)
+	}
+	if rec := do(t, s, http.MethodPost, "/graphql", graphql.Request{Query: `{ links { tags } }`}, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid query: %d %s", rec.Code, rec.Body)
+	}
+
+	// Without a query, GET returns the schema
+	rec = do(t, s, http.MethodGet, "/graphql", nil, "", "")
+	if !strings.Contains(rec.Body.String(), "type Query {") || !strings.Contains(rec.Body.String(), "enum LinkOrder {\n  NEWEST\n") {
+		t.Errorf("schema:\n%s", rec.Body)
+	}
+
+	s, _ = newTestServer(t, twoAdmins)
+	if rec := do(t, s, http.MethodGet, "/graphql", nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without login: %d", rec.Code)
+	}
+}
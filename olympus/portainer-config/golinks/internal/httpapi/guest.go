@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golinks/internal/captcha"
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// GuestOwner is the creator recorded on links suggested by visitors
+// without a login.
+const GuestOwner = "guest"
+
+// SuggestLink stores a link suggested by a visitor without a login. It
+// always waits for an admin's approval, whatever its destination, and is
+// posted to chat if configured. Validation failures are returned as
+// *InvalidError.
+func (s *Server) SuggestLink(ctx context.Context, slug, url string) (store.Link, error) {
+	slug = canonicalSlug(strings.TrimSpace(slug))
+	if err := s.checkNewSlug(slug, GuestOwner); err != nil {
+		return store.Link{}, err
+	}
+	link, err := s.destination(slug, url, GuestOwner)
+	if err != nil {
+		return store.Link{}, err
+	}
+	link.Status = store.StatusPending
+	if err := s.createLink(ctx, link, nil, nil); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return store.Link{}, &InvalidError{Msg: "That short name is taken", Field: "slug"}
+		}
+		return store.Link{}, err
+	}
+	// Unlike an admin's, the destination is not snapshotted: nobody has
+	// vouched for it yet
+	s.propose(link)
+	return link, nil
+}
+
+// suggestForm is the state of the guest suggestion page.
+type suggestForm struct {
+	Slug, URL string
+	Error     string
+	// Suggested is the slug just suggested.
+	Suggested string
+	Captcha   *captcha.Widget
+}
+
+// suggestPage is the form at /admin/suggest where visitors without a login
+// suggest links.
+var suggestPage = template.Must(template.New("suggest").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1.0"><title>Suggest a link</title>
+{{with .Captcha}}<script src="{{.Script}}" async defer></script>{{end}}</head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 3em auto; padding: 0 1em">
+<h1>Suggest a link</h1>
+{{with .Suggested}}<p style="color: #3c763d"><strong>Thanks! go/{{.}} waits for an admin to approve it.</strong></p>{{end}}
+{{with .Error}}<p style="color: #a94442"><strong>{{.}}</strong></p>{{end}}
+<p>Know a page others should find at a short name? Suggest it here; it works once an admin approves it.</p>
+<form method="post" action="/admin/suggest">
+<p><label>Short name<br>go/<input name="slug" value="{{.Slug}}" autocapitalize="off" spellcheck="false" required></label></p>
+<p><label>Destination<br><input name="url" type="url" value="{{.URL}}" placeholder="https://" size="50" required></label></p>
+{{with .Captcha}}<div class="{{.Class}}" data-sitekey="{{.SiteKey}}"></div>{{end}}
+<p><button>Suggest</button></p>
+</form>
+</body>
+</html>
+`))
+
+// handleSuggest serves the guest suggestion form (GET) and takes its posts.
+// It needs no login; each client address may only post GuestLimit allows,
+// and the captcha must be solved if one is configured.
+func (s *Server) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	form := suggestForm{}
+	if s.cfg.Captcha != nil {
+		widget := s.cfg.Captcha.Widget()
+		form.Captcha = &widget
+	}
+	render := func(status int) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		suggestPage.Execute(w, form)
+	}
+	switch r.Method {
+	case http.MethodGet:
+		render(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" || !sameOrigin(r) {
+		http.Error(w, "Cross-site form posts are not allowed", http.StatusForbidden)
+		return
+	}
+	form.Slug = strings.TrimSpace(r.PostFormValue("slug"))
+	form.URL = strings.TrimSpace(r.PostFormValue("url"))
+
+	// Every post counts, failed ones too, so guessing a captcha costs the
+	// quota as well
+	ip, key := "", r.RemoteAddr
+	if addr, ok := s.clientIP(r); ok {
+		ip, key = addr.String(), clientNetwork(addr)
+	}
+	if quota, ok := s.cfg.GuestLimit.Allow("guest " + key); !ok {
+		slog.WarnContext(r.Context(), "Rate limited link suggestions", "remote_addr", s.remote(r))
+		w.Header().Set("Retry-After", strconv.Itoa(quota.Reset))
+		form.Error = "Too many suggestions; try again later"
+		render(http.StatusTooManyRequests)
+		return
+	}
+	if s.cfg.Captcha != nil {
+		if err := s.cfg.Captcha.Verify(r.Context(), r.PostFormValue(form.Captcha.Field), ip); err != nil {
+			code := http.StatusBadRequest
+			form.Error = "Please solve the captcha"
+			if !errors.Is(err, captcha.ErrFailed) {
+				slog.ErrorContext(r.Context(), "Error verifying captcha", "error", err)
+				code, form.Error = http.StatusServiceUnavailable, "The captcha could not be checked; try again later"
+			}
+			render(code)
+			return
+		}
+	}
+
+	link, err := s.SuggestLink(r.Context(), form.Slug, form.URL)
+	if err != nil {
+		var invalid *InvalidError
+		code := http.StatusBadRequest
+		if errors.As(err, &invalid) {
+			form.Error = invalid.Msg
+		} else {
+			slog.ErrorContext(r.Context(), "Error saving suggested link", "error", err)
+			code, form.Error = httperr.Status(err)
+		}
+		render(code)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Link suggested, pending approval", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	form = suggestForm{Suggested: link.Slug, Captcha: form.Captcha}
+	render(http.StatusOK)
+}
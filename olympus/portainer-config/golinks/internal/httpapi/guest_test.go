@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/captcha"
+	"golinks/internal/ratelimit"
+	"golinks/internal/store"
+)
+
+// suggest posts the guest form from addr.
+func suggest(s *Server, addr string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/suggest", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = addr
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{
+		Admins:     map[string]string{"admin": "secret"},
+		GuestLimit: ratelimit.New(ratelimit.Config{Limit: 3, Window: time.Hour}),
+	})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	if rec := do(t, s, http.MethodGet, "/admin/suggest", nil, "", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `name="slug"`) {
+		t.Fatalf("GET = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := suggest(s, "192.0.2.7:1234", url.Values{"slug": {"lunch"}, "url": {"https://lunch.example.com"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "go/lunch waits") {
+		t.Fatalf("POST = %d: %s", rec.Code, rec.Body)
+	}
+	link, err := st.GetLink(ctx, "lunch")
+	if err != nil || link.Status != store.StatusPending || link.CreatedBy != GuestOwner {
+		t.Errorf("lunch = %+v, %v", link, err)
+	}
+	if rec := do(t, s, http.MethodGet, "/lunch", nil, "", ""); rec.Code == http.StatusFound {
+		t.Error("suggested link redirects before approval")
+	}
+
+	// Any admin may approve it
+	if rec := do(t, s, http.MethodPost, "/admin/approve", ApproveLinkRequest{Slug: "lunch", URL: "https://lunch.example.com"}, "admin", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("approve = %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := suggest(s, "192.0.2.7:1234", url.Values{"slug": {"wiki"}, "url": {"https://evil.example.com"}}); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "taken") {
+		t.Errorf("POST of a taken slug = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := suggest(s, "192.0.2.7:1234", url.Values{"slug": {"x"}, "url": {"javascript:alert(1)"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST of a bad URL = %d", rec.Code)
+	}
+	// The failed posts counted too
+	if rec := suggest(s, "192.0.2.7:1234", url.Values{"slug": {"more"}, "url": {"https://more.example.com"}}); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("POST over the limit = %d", rec.Code)
+	}
+	if rec := suggest(s, "192.0.2.8:1234", url.Values{"slug": {"more"}, "url": {"https://more.example.com"}}); rec.Code != http.StatusOK {
+		t.Errorf("POST of another client = %d: %s", rec.Code, rec.Body)
+	}
+
+	// Without a limiter there is no form
+	s, _ = newTestServer(t, Config{})
+	if rec := do(t, s, http.MethodGet, "/admin/suggest", nil, "", ""); rec.Code == http.StatusOK && strings.Contains(rec.Body.String(), "Suggest") {
+		t.Error("guest form served without GuestLimit")
+	}
+}
+
+func TestSuggestCaptcha(t *testing.T) {
+	verify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("response") == "solved" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false}`))
+	}))
+	defer verify.Close()
+	s, st := newTestServer(t, Config{
+		GuestLimit: ratelimit.New(ratelimit.Config{Limit: 10}),
+		Captcha:    captcha.New(captcha.Config{Provider: captcha.HCaptcha, SiteKey: "site-key", Secret: "secret", VerifyURL: verify.URL}),
+	})
+
+	if rec := do(t, s, http.MethodGet, "/admin/suggest", nil, "", ""); !strings.Contains(rec.Body.String(), `data-sitekey="site-key"`) {
+		t.Errorf("form without the captcha widget: %s", rec.Body)
+	}
+	form := url.Values{"slug": {"lunch"}, "url": {"https://lunch.example.com"}, "h-captcha-response": {"guessed"}}
+	if rec := suggest(s, "192.0.2.7:1234", form); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with a wrong answer = %d", rec.Code)
+	}
+	form.Set("h-captcha-response", "solved")
+	if rec := suggest(s, "192.0.2.7:1234", form); rec.Code != http.StatusOK {
+		t.Errorf("POST with the answer = %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := st.GetLink(context.Background(), "lunch"); err != nil {
+		t.Errorf("lunch: %v", err)
+	}
+}
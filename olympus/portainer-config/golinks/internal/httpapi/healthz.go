@@ -0,0 +1,40 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golinks/internal/probe"
+)
+
+// Health is the answer of /healthz.
+type Health struct {
+	// Status is "ok", or "failing" while the probe finds redirects broken.
+	Status string `json:"status"`
+	// Probe is the latest probe, absent without a probe or before its
+	// first run.
+	Probe *probe.Result `json:"probe,omitempty"`
+}
+
+// handleHealthz serves GET /healthz for load balancers and container
+// health checks: 200 while the server answers and, if the uptime probe is
+// on, its latest probe redirected; 503 otherwise. It needs no login.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h, code := Health{Status: "ok"}, http.StatusOK
+	if s.cfg.Probe != nil {
+		if last := s.cfg.Probe.Result(); !last.CheckedAt.IsZero() {
+			h.Probe = &last
+			if !last.OK {
+				h.Status, code = "failing", http.StatusServiceUnavailable
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golinks/internal/probe"
+	"golinks/internal/store"
+)
+
+func TestHealthz(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestServer(t, twoAdmins)
+	if rec := do(t, s, http.MethodGet, "/healthz", nil, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("without a probe: %d %s", rec.Code, rec.Body)
+	}
+
+	var clicks clickLog
+	s, st := newTestServer(t, Config{Clicks: &clicks})
+	st.AddLink(ctx, store.Link{Slug: "canary", URL: "https://example.com/"})
+	listener := httptest.NewServer(s.Handler())
+	defer listener.Close()
+	p := probe.New(probe.Config{Slug: "canary", Target: listener.URL})
+	s.cfg.Probe = p
+
+	if err := p.Probe(ctx); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	rec := do(t, s, http.MethodGet, "/healthz", nil, "", "")
+	var h Health
+	if err := json.NewDecoder(rec.Body).Decode(&h); err != nil || rec.Code != http.StatusOK || h.Status != "ok" || h.Probe == nil || !h.Probe.OK {
+		t.Errorf("healthy: %d %+v %v", rec.Code, h, err)
+	}
+	if len(clicks) != 0 {
+		t.Errorf("probe counted as clicks: %v", clicks)
+	}
+
+	st.RemoveLink(ctx, "canary")
+	p.Probe(ctx)
+	if rec := do(t, s, http.MethodGet, "/healthz", nil, "", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("with a missing canary: %d %s", rec.Code, rec.Body)
+	}
+}
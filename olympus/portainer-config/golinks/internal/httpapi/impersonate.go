@@ -0,0 +1,254 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ImpersonationHeader names who a response was served as, while an admin
+// impersonates someone.
+const ImpersonationHeader = "X-Golinks-Impersonating"
+
+// impersonationCookie carries an impersonation, signed by the server so it
+// can be neither forged nor edited.
+const impersonationCookie = "golinks_impersonate"
+
+// impersonationTTL is how long an impersonation lasts unless stopped.
+const impersonationTTL = time.Hour
+
+// Impersonation is an admin viewing golinks as someone else, to debug what
+// they can see and open. It is view-only: requests that would change
+// anything are refused while it lasts.
+type Impersonation struct {
+	// Admin is the admin impersonating.
+	Admin string `json:"admin"`
+	// User is the admin impersonated, or "" for a visitor without an
+	// admin login.
+	User string `json:"user,omitempty"`
+	// Group, if set, is the access group whose rules apply instead of the
+	// client network's.
+	Group     string    `json:"group,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// String names who is impersonated, as in "bob" or "a visitor in kids".
+func (imp Impersonation) String() string {
+	who := imp.User
+	if who == "" {
+		who = "a visitor"
+	}
+	if imp.Group != "" {
+		who += " in " + imp.Group
+	}
+	return who
+}
+
+type impersonationKey struct{}
+
+// ImpersonationFrom returns the impersonation a request is served under,
+// for pages to show it.
+func ImpersonationFrom(ctx context.Context) (Impersonation, bool) {
+	imp, ok := ctx.Value(impersonationKey{}).(Impersonation)
+	return imp, ok
+}
+
+type ImpersonateRequest struct {
+	User  string `json:"user"`
+	Group string `json:"group"`
+}
+
+// newImpersonationKey returns the key impersonation cookies are signed
+// with. It lives only as long as the process, so a restart ends every
+// impersonation.
+func newImpersonationKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+func (s *Server) signImpersonation(imp Impersonation) string {
+	data, _ := json.Marshal(imp)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	mac := hmac.New(sha256.New, s.impersonationKey)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// impersonation returns the valid, unexpired impersonation of r's cookie.
+func (s *Server) impersonation(r *http.Request) (Impersonation, bool) {
+	c, err := r.Cookie(impersonationCookie)
+	if err != nil {
+		return Impersonation{}, false
+	}
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return Impersonation{}, false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	mac := hmac.New(sha256.New, s.impersonationKey)
+	mac.Write([]byte(payload))
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return Impersonation{}, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	var imp Impersonation
+	if err != nil || json.Unmarshal(data, &imp) != nil || time.Now().After(imp.ExpiresAt) {
+		return Impersonation{}, false
+	}
+	return imp, true
+}
+
+// impersonating serves requests carrying an impersonation cookie as the
+// impersonated user, logging each one. Only reads go through; /graphql
+// takes its queries by POST, and /admin/impersonate ends the
+// impersonation.
+func (s *Server) impersonating(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		imp, ok := s.impersonation(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/admin/impersonate") {
+			slog.InfoContext(r.Context(), "Impersonated request", "admin", imp.Admin, "as", imp.String(), "method", r.Method, "path", r.URL.Path, "remote_addr", s.remote(r))
+			safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+			if !safe && r.URL.Path != "/graphql" {
+				http.Error(w, "Impersonation is view-only; stop it at /admin/impersonate/stop to make changes", http.StatusForbidden)
+				return
+			}
+		}
+		w.Header().Set(ImpersonationHeader, imp.String())
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), impersonationKey{}, imp)))
+	})
+}
+
+// impersonatePage lets an admin start and stop impersonating from the
+// browser, whose cookie the impersonation lives in.
+var impersonatePage = template.Must(template.New("impersonate").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Impersonate</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 3em auto">
+<h1>Impersonate</h1>
+{{with .Current}}<p>Viewing as <strong>{{.}}</strong> until {{.ExpiresAt.Format "15:04"}}.</p>
+<form method="post" action="/admin/impersonate/stop"><button>Stop</button></form>{{end}}
+<p>See golinks as another admin or a visitor, optionally in an access group, without changing anything.</p>
+<form method="post" action="/admin/impersonate">
+<p><label>User <select name="user"><option value="">A visitor (no admin login)</option>{{range .Admins}}<option>{{.}}</option>{{end}}</select></label></p>
+{{if .Groups}}<p><label>Access group <select name="group"><option value="">The client's own network</option>{{range .Groups}}<option>{{.}}</option>{{end}}</select></label></p>{{end}}
+<p><button>Impersonate</button></p>
+</form>
+</body>
+</html>
+`))
+
+// handleAdminImpersonate shows the current impersonation (GET) or starts
+// one (POST, JSON or a form).
+func (s *Server) handleAdminImpersonate(w http.ResponseWriter, r *http.Request) {
+	current, impersonating := ImpersonationFrom(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			data := struct {
+				Current        *Impersonation
+				Admins, Groups []string
+			}{}
+			if impersonating {
+				data.Current = &current
+			}
+			for name := range s.cfg.Admins {
+				data.Admins = append(data.Admins, name)
+			}
+			for name := range s.cfg.AccessGroups {
+				data.Groups = append(data.Groups, name)
+			}
+			slices.Sort(data.Admins)
+			slices.Sort(data.Groups)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			impersonatePage.Execute(w, data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !impersonating {
+			json.NewEncoder(w).Encode(map[string]string{"status": "off"})
+			return
+		}
+		json.NewEncoder(w).Encode(current)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	form := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	var req ImpersonateRequest
+	if form {
+		req.User, req.Group = r.PostFormValue("user"), r.PostFormValue("group")
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.User, req.Group = strings.TrimSpace(req.User), strings.TrimSpace(req.Group)
+	if _, ok := s.cfg.Admins[req.User]; req.User != "" && !ok {
+		http.Error(w, "Unknown admin", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.cfg.AccessGroups[req.Group]; req.Group != "" && !ok {
+		http.Error(w, "Unknown access group", http.StatusBadRequest)
+		return
+	}
+
+	// The real admin stays on record when switching to someone else
+	admin := s.adminName(r)
+	if impersonating {
+		admin = current.Admin
+	}
+	imp := Impersonation{Admin: admin, User: req.User, Group: req.Group, ExpiresAt: time.Now().Add(impersonationTTL).UTC().Truncate(time.Second)}
+	http.SetCookie(w, &http.Cookie{
+		Name:     impersonationCookie,
+		Value:    s.signImpersonation(imp),
+		Path:     "/",
+		MaxAge:   int(impersonationTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	slog.InfoContext(r.Context(), "Impersonation started", "admin", imp.Admin, "as", imp.String(), "remote_addr", s.remote(r))
+
+	if form {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imp)
+}
+
+// handleAdminImpersonateStop ends the impersonation of the request's
+// cookie.
+func (s *Server) handleAdminImpersonateStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if imp, ok := ImpersonationFrom(r.Context()); ok {
+		slog.InfoContext(r.Context(), "Impersonation stopped", "admin", imp.Admin, "as", imp.String(), "remote_addr", s.remote(r))
+	}
+	http.SetCookie(w, &http.Cookie{Name: impersonationCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "stopped"})
+}
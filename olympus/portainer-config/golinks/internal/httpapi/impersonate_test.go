@@ -0,0 +1,77 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestImpersonate(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{
+		Admins:       map[string]string{"alice": "pw", "bob": "pw2"},
+		AccessGroups: map[string][]netip.Prefix{"kids": {netip.MustParsePrefix("192.168.20.0/24")}},
+	})
+	st.AddLink(ctx, store.Link{Slug: "games", URL: "https://games.example.com", Status: store.StatusActive, Access: []store.AccessRule{
+		{Group: "kids", From: time.Now().Add(2 * time.Minute).Format("15:04"), To: time.Now().Add(3 * time.Minute).Format("15:04")},
+	}})
+
+	if rec := do(t, s, http.MethodPost, "/admin/impersonate", ImpersonateRequest{User: "carol"}, "alice", "pw"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown admin: status = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/impersonate", ImpersonateRequest{Group: "adults"}, "alice", "pw"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown group: status = %d", rec.Code)
+	}
+	rec := do(t, s, http.MethodPost, "/admin/impersonate", ImpersonateRequest{Group: "kids"}, "alice", "pw")
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 {
+		t.Fatalf("start: status = %d, cookies %v", rec.Code, cookies)
+	}
+	cookie := cookies[0]
+
+	send := func(method, target string, c *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(`{"slug": "x", "url": "https://x.example.com"}`))
+		r.SetBasicAuth("alice", "pw")
+		r.AddCookie(c)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, r)
+		return rec
+	}
+
+	// A visitor in kids can't open games now, whatever network alice is on
+	rec = send(http.MethodGet, "/games", cookie)
+	if rec.Code != http.StatusForbidden || rec.Header().Get(ImpersonationHeader) != "a visitor in kids" {
+		t.Errorf("GET /games = %d, %s %q", rec.Code, ImpersonationHeader, rec.Header().Get(ImpersonationHeader))
+	}
+	// Visitors have no admin pages, and nothing can be changed
+	if rec := send(http.MethodGet, "/admin/export", cookie); rec.Code != http.StatusForbidden {
+		t.Errorf("GET /admin/export = %d, want 403", rec.Code)
+	}
+	if rec := send(http.MethodPost, "/admin/add", cookie); rec.Code != http.StatusForbidden {
+		t.Errorf("POST /admin/add = %d, want 403", rec.Code)
+	}
+	if _, err := st.GetLink(ctx, "x"); err == nil {
+		t.Error("link added while impersonating")
+	}
+	if rec := send(http.MethodGet, "/admin/impersonate", cookie); !strings.Contains(rec.Body.String(), `"admin":"alice"`) {
+		t.Errorf("GET /admin/impersonate = %s", rec.Body)
+	}
+
+	// A tampered cookie is ignored
+	forged := *cookie
+	forged.Value = strings.Replace(forged.Value, ".", "x.", 1)
+	if rec := send(http.MethodGet, "/admin/export", &forged); rec.Code != http.StatusOK || rec.Header().Get(ImpersonationHeader) != "" {
+		t.Errorf("forged cookie: status = %d, impersonating %q", rec.Code, rec.Header().Get(ImpersonationHeader))
+	}
+
+	rec = send(http.MethodPost, "/admin/impersonate/stop", cookie)
+	if rec.Code != http.StatusOK || len(rec.Result().Cookies()) != 1 || rec.Result().Cookies()[0].MaxAge >= 0 {
+		t.Errorf("stop: status = %d, cookies %v", rec.Code, rec.Result().Cookies())
+	}
+}
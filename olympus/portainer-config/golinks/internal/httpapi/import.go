@@ -0,0 +1,538 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golinks/internal/bookmarks"
+	"golinks/internal/httperr"
+	"golinks/internal/shorteners"
+	"golinks/internal/store"
+)
+
+// maxImportBody bounds the file of one import.
+const maxImportBody = 10 << 20
+
+// Conflict modes of an import: what to do with a row whose slug is taken.
+const (
+	ImportSkip      = "skip"
+	ImportOverwrite = "overwrite"
+)
+
+// ImportCreate is the duplicate mode that creates near-duplicates anyway;
+// ImportSkip, the default, leaves them out.
+const ImportCreate = "create"
+
+// Results of one import row.
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+	// ImportDuplicate is a row left out as a near-duplicate of a link.
+	ImportDuplicate = "duplicate"
+)
+
+// ImportRow reports what happened to one row, bookmark or link of another
+// shortener.
+type ImportRow struct {
+	// Line is the row's line in the file, or its position in a JSON
+	// export, counting from 1.
+	Line int `json:"line"`
+	// Title is the title of a bookmark, which its slug was made from, or of
+	// a link of another shortener.
+	Title  string   `json:"title,omitempty"`
+	Slug   string   `json:"slug"`
+	URL    string   `json:"url"`
+	Tags   []string `json:"tags,omitempty"`
+	Result string   `json:"result"`
+	// Status is the link's status after the import, "pending" if it waits
+	// for approval.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// DuplicateOf is the link a row left out as a duplicate duplicates.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// ImportCollection reports the links a tag added to its collection.
+type ImportCollection struct {
+	Name    string   `json:"name"`
+	Created bool     `json:"created,omitempty"`
+	Added   []string `json:"added"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ImportReport is the answer to an import: a result per row, the
+// collections of the rows' tags, and the near-duplicates worth merging.
+type ImportReport struct {
+	DryRun      bool               `json:"dry_run"`
+	OnConflict  string             `json:"on_conflict"`
+	OnDuplicate string             `json:"on_duplicate"`
+	Summary     map[string]int     `json:"summary"`
+	Rows        []ImportRow        `json:"rows"`
+	Collections []ImportCollection `json:"collections"`
+	Merges      []ImportMerge      `json:"merges"`
+}
+
+// importer imports the rows of one file.
+type importer struct {
+	s         *Server
+	admin     string
+	remote    string
+	dryRun    bool
+	overwrite bool
+	// seen holds the slugs of earlier rows, so a dry run knows a slug
+	// repeated in the file is taken by then.
+	seen map[string]bool
+	// dups finds near-duplicates, which are created too with
+	// createDuplicates; merges lists those found.
+	dups             *dedupIndex
+	createDuplicates bool
+	merges           []ImportMerge
+}
+
+// handleAdminImport imports links from a CSV of slug,url[,tags] rows, with
+// an optional header row naming the columns. Tags, separated by spaces or semicolons, add the
+// link to the collections of those names. With ?format=bookmarks, or an
+// HTML body, it imports a browser bookmarks file instead, and with the
+// name of another shortener, such as ?format=shlink, its export. ?on_conflict=
+// skips (default) or overwrites taken slugs, ?on_duplicate= skips (default)
+// or creates rows that nearly duplicate a link, and ?dry_run=true reports
+// what would happen without changing anything.
+func (s *Server) handleAdminImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	im := &importer{s: s, admin: s.adminName(r), remote: s.remote(r), seen: map[string]bool{}}
+	if v := query.Get("dry_run"); v != "" {
+		var err error
+		if im.dryRun, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	onConflict := query.Get("on_conflict")
+	switch onConflict {
+	case "":
+		onConflict = ImportSkip
+	case ImportSkip:
+	case ImportOverwrite:
+		im.overwrite = true
+	default:
+		http.Error(w, "on_conflict must be skip or overwrite", http.StatusBadRequest)
+		return
+	}
+	onDuplicate := query.Get("on_duplicate")
+	switch onDuplicate {
+	case "":
+		onDuplicate = ImportSkip
+	case ImportSkip:
+	case ImportCreate:
+		im.createDuplicates = true
+	default:
+		http.Error(w, "on_duplicate must be skip or create", http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = ExportCSV
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "text/html" {
+			format = ExportBookmarks
+		}
+	}
+	folders := false
+	if v := query.Get("folders"); v != "" {
+		var err error
+		if folders, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "folders must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBody)
+	var records [][]string
+	var lines []int
+	var titles []string
+	var err error
+	switch format {
+	case ExportCSV:
+		records, lines, err = readImportCSV(body)
+	case ExportBookmarks:
+		records, lines, titles, err = readImportBookmarks(body, folders)
+	default:
+		if !slices.Contains(shorteners.Formats, format) {
+			err = fmt.Errorf("format must be csv, bookmarks or one of %s", strings.Join(shorteners.Formats, ", "))
+			break
+		}
+		records, lines, titles, err = readImportShortener(body, format)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !checkBulkSize(w, len(records)) {
+		return
+	}
+	if im.dups, err = newDedupIndex(r.Context(), s.store); err != nil {
+		slog.ErrorContext(r.Context(), "Error listing links for an import", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	report := ImportReport{
+		DryRun:      im.dryRun,
+		OnConflict:  onConflict,
+		OnDuplicate: onDuplicate,
+		Summary:     map[string]int{ImportCreated: 0, ImportUpdated: 0, ImportSkipped: 0, ImportFailed: 0, ImportDuplicate: 0},
+		Rows:        make([]ImportRow, 0, len(records)),
+		Collections: []ImportCollection{},
+	}
+	var tags []string
+	tagged := map[string][]string{}
+	for i, record := range records {
+		row := im.row(r.Context(), lines[i], record)
+		if titles != nil {
+			row.Title = titles[i]
+		}
+		report.Rows = append(report.Rows, row)
+		report.Summary[row.Result]++
+		if row.Result != ImportCreated && row.Result != ImportUpdated {
+			continue
+		}
+		for _, tag := range row.Tags {
+			if _, ok := tagged[tag]; !ok {
+				tags = append(tags, tag)
+			}
+			tagged[tag] = append(tagged[tag], row.Slug)
+		}
+	}
+	for _, tag := range tags {
+		report.Collections = append(report.Collections, im.collect(r.Context(), tag, tagged[tag]))
+	}
+	report.Merges = append([]ImportMerge{}, im.merges...)
+
+	msg := "Imported"
+	if im.dryRun {
+		msg = "Dry run of import"
+	}
+	slog.InfoContext(r.Context(), msg, "created", report.Summary[ImportCreated], "updated", report.Summary[ImportUpdated], "skipped", report.Summary[ImportSkipped],
+		"failed", report.Summary[ImportFailed], "duplicates", report.Summary[ImportDuplicate], "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// readImportCSV returns the records of an import CSV as slug,url[,tags]
+// and their line numbers. A header row, if there is one, names the columns
+// so they may come in any order and others, such as those of an export,
+// are ignored.
+func readImportCSV(body io.Reader) ([][]string, []int, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	var records [][]string
+	var lines []int
+	var columns []int
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, nil, fmt.Errorf("CSV larger than %d bytes", maxImportBody)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid CSV: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(records) == 0 && columns == nil && slices.ContainsFunc(record, isColumn("slug")) {
+			if columns = importColumns(record); columns == nil {
+				return nil, nil, errors.New("Header row needs slug and url columns")
+			}
+			continue
+		}
+		if columns != nil {
+			record = pickColumns(record, columns)
+		}
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return records, lines, nil
+}
+
+// readImportBookmarks returns the bookmarks of a bookmarks file as
+// slug,url,tags records, their line numbers and their titles. Slugs are made
+// from the titles, or the URLs of untitled bookmarks, and numbered when
+// bookmarks of different URLs get the same slug. With folders, a bookmark's
+// innermost folder becomes its tag.
+func readImportBookmarks(body io.Reader, folders bool) ([][]string, []int, []string, error) {
+	marks, err := bookmarks.Parse(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, nil, nil, fmt.Errorf("Bookmarks file larger than %d bytes", maxImportBody)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Invalid bookmarks file: %v", err)
+	}
+	records := make([][]string, 0, len(marks))
+	lines := make([]int, 0, len(marks))
+	titles := make([]string, 0, len(marks))
+	taken := map[string]string{}
+	for _, b := range marks {
+		base := bookmarkSlug(b.Title, b.URL)
+		slug := base
+		for n := 2; base != "" && taken[slug] != "" && taken[slug] != b.URL; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[slug] = b.URL
+		tag := ""
+		if folders && len(b.Folders) > 0 {
+			tag = kebab(b.Folders[len(b.Folders)-1])
+		}
+		records = append(records, []string{slug, b.URL, tag})
+		lines = append(lines, b.Line)
+		titles = append(titles, b.Title)
+	}
+	return records, lines, titles, nil
+}
+
+// readImportShortener returns the links of another shortener's export as
+// slug,url,tags records, their line numbers and their titles.
+func readImportShortener(body io.Reader, format string) ([][]string, []int, []string, error) {
+	links, err := shorteners.Parse(format, body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, nil, nil, fmt.Errorf("Export larger than %d bytes", maxImportBody)
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Invalid %s export: %v", format, err)
+	}
+	records := make([][]string, 0, len(links))
+	lines := make([]int, 0, len(links))
+	titles := make([]string, 0, len(links))
+	for _, link := range links {
+		var tags []string
+		for _, tag := range link.Tags {
+			if tag = kebab(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		records = append(records, []string{link.Slug, link.URL, strings.Join(tags, ";")})
+		lines = append(lines, link.Line)
+		titles = append(titles, link.Title)
+	}
+	return records, lines, titles, nil
+}
+
+// bookmarkSlug makes a slug for a bookmark: the slug of a "go/slug" title,
+// as a bookmarks export names links, else the title or URL in kebab-case.
+func bookmarkSlug(title, url string) string {
+	if rest, ok := strings.CutPrefix(title, "go/"); ok && isValidSlug(rest) {
+		return canonicalSlug(rest)
+	}
+	if slug := kebab(title); slug != "" {
+		return slug
+	}
+	return kebab(urlName(url))
+}
+
+func isColumn(name string) func(string) bool {
+	return func(field string) bool { return strings.EqualFold(strings.TrimSpace(field), name) }
+}
+
+// importColumns returns the indexes of the slug, url and, if present, tags
+// columns of header, or nil without slug or url.
+func importColumns(header []string) []int {
+	var columns []int
+	for _, name := range []string{"slug", "url", "tags"} {
+		i := slices.IndexFunc(header, isColumn(name))
+		if i < 0 {
+			if name == "tags" {
+				break
+			}
+			return nil
+		}
+		columns = append(columns, i)
+	}
+	return columns
+}
+
+// pickColumns returns the fields of record at columns, leaving out the
+// tags of a row that ends before them. A row without slug and url is
+// returned as it is, to be reported as malformed.
+func pickColumns(record []string, columns []int) []string {
+	picked := make([]string, 0, len(columns))
+	for i, c := range columns {
+		switch {
+		case c < len(record):
+			picked = append(picked, record[c])
+		case i < 2:
+			return record[:1]
+		}
+	}
+	return picked
+}
+
+// row imports one CSV record.
+func (im *importer) row(ctx context.Context, line int, record []string) ImportRow {
+	row := ImportRow{Line: line, Slug: canonicalSlug(strings.TrimSpace(record[0]))}
+	fail := func(msg string) ImportRow {
+		row.Result, row.Error = ImportFailed, msg
+		return row
+	}
+	if len(record) < 2 || len(record) > 3 {
+		return fail("expected slug,url[,tags]")
+	}
+	row.URL = strings.TrimSpace(record[1])
+	if len(record) == 3 {
+		for _, tag := range strings.FieldsFunc(record[2], func(r rune) bool { return r == ';' || r == ' ' || r == '\t' }) {
+			tag = canonicalSlug(tag)
+			if !isValidSlug(tag) {
+				return fail("Invalid tag: " + tag)
+			}
+			if !slices.Contains(row.Tags, tag) {
+				row.Tags = append(row.Tags, tag)
+			}
+		}
+	}
+	if row.Slug == "" {
+		return fail("Invalid slug")
+	}
+	// Trotto and others fill in %s from the rest of the path, which golinks
+	// links don't do
+	if strings.Contains(row.Slug, "%s") {
+		return fail("Programmatic links are not supported")
+	}
+	into, reason := im.dups.find(row.Slug, row.URL)
+	if into != "" && !im.createDuplicates {
+		im.merges = append(im.merges, ImportMerge{Line: line, Slug: row.Slug, URL: row.URL, Into: into, Reason: reason})
+		row.Result, row.DuplicateOf = ImportDuplicate, into
+		return row
+	}
+
+	var link store.Link
+	var err error
+	if im.dryRun {
+		link, err = im.check(ctx, row.Slug, row.URL)
+	} else {
+		link, err = im.s.AddLink(ctx, AddLinkRequest{Slug: row.Slug, URL: row.URL}, im.admin)
+	}
+	row.Result = ImportCreated
+	if errors.Is(err, store.ErrConflict) {
+		if !im.overwrite {
+			row.Result = ImportSkipped
+			return row
+		}
+		if im.dryRun {
+			link, err = im.checkUpdate(ctx, row.Slug, row.URL)
+		} else {
+			link, err = im.s.UpdateLink(ctx, row.Slug, row.URL, im.admin)
+		}
+		row.Result = ImportUpdated
+	}
+	if err != nil {
+		return fail(linkErrorText(err))
+	}
+	im.seen[row.Slug] = true
+	im.dups.add(row.Slug, row.URL)
+	if into != "" {
+		im.merges = append(im.merges, ImportMerge{Line: line, Slug: row.Slug, URL: row.URL, Into: into, Reason: reason})
+	}
+	row.Status = link.Status
+	if link.Status == store.StatusPending && !im.dryRun {
+		slog.InfoContext(ctx, "Link pending approval", "slug", link.Slug, "url", link.URL, "source", "import", "remote_addr", im.remote)
+	}
+	return row
+}
+
+// check reports whether AddLink would add slug, like it without saving.
+func (im *importer) check(ctx context.Context, slug, url string) (store.Link, error) {
+	if err := im.s.checkNewSlug(slug, im.admin); err != nil {
+		return store.Link{}, err
+	}
+	link, err := im.s.destination(slug, url, im.admin)
+	if err != nil {
+		return store.Link{}, err
+	}
+	if im.seen[slug] {
+		return store.Link{}, store.ErrConflict
+	}
+	if _, err := im.s.store.GetLink(ctx, slug); !errors.Is(err, store.ErrNotFound) {
+		if err == nil {
+			err = store.ErrConflict
+		}
+		return store.Link{}, err
+	}
+	return link, nil
+}
+
+// checkUpdate reports whether UpdateLink would update slug, like it without
+// saving.
+func (im *importer) checkUpdate(ctx context.Context, slug, url string) (store.Link, error) {
+	link, err := im.s.destination(slug, url, im.admin)
+	if err != nil {
+		return store.Link{}, err
+	}
+	existing, err := im.s.store.GetLink(ctx, slug)
+	if errors.Is(err, store.ErrNotFound) && im.seen[slug] {
+		return link, nil
+	}
+	if err != nil {
+		return store.Link{}, err
+	}
+	if existing.Status == store.StatusReserved {
+		return store.Link{}, &InvalidError{Msg: "Slug is reserved; claim it to set its destination", Field: "slug"}
+	}
+	return link, nil
+}
+
+// collect adds slugs to the collection name, creating it if needed.
+func (im *importer) collect(ctx context.Context, name string, slugs []string) ImportCollection {
+	result := ImportCollection{Name: name, Added: []string{}}
+	c, err := im.s.store.GetCollection(ctx, name)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c = &store.Collection{Name: name, CreatedBy: im.admin}
+		result.Created = true
+	case err != nil:
+		slog.ErrorContext(ctx, "Error loading collection", "collection", name, "error", err)
+		result.Error = "internal error"
+		return result
+	}
+	for _, slug := range slugs {
+		if !slices.Contains(c.Slugs, slug) {
+			c.Slugs = append(c.Slugs, slug)
+			result.Added = append(result.Added, slug)
+		}
+	}
+	if len(c.Slugs) > maxCollectionLinks {
+		result.Added = []string{}
+		result.Error = "Too many links in collection"
+		return result
+	}
+	if im.dryRun || len(result.Added) == 0 {
+		return result
+	}
+	if err := im.s.store.SaveCollection(ctx, *c); err != nil {
+		slog.ErrorContext(ctx, "Error saving collection", "error", err)
+		result.Error = "internal error"
+		return result
+	}
+	slog.InfoContext(ctx, "Collection saved", "collection", c.Name, "links", len(c.Slugs), "source", "import", "remote_addr", im.remote)
+	return result
+}
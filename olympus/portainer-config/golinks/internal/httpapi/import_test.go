@@ -0,0 +1,228 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+const importCSV = `slug,url,tags
+mail,https://mail.example.com,tools
+wiki,https://wiki.example.com/new,docs;tools
+# retired
+bad,ftp://files.example.com
+"hr", "https://hr.example.com", people docs
+mail,https://mail2.example.com
+a,b,c,d
+`
+
+// postImport sends csv to /admin/import with query and decodes the report.
+func postImport(t *testing.T, s *Server, query, csv string) (int, ImportReport) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/import"+query, strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	var report ImportReport
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("report: %v\n%s", err, rec.Body)
+		}
+	}
+	return rec.Code, report
+}
+
+// results lists the slug and result of every row of report.
+func results(report ImportReport) string {
+	var parts []string
+	for _, row := range report.Rows {
+		parts = append(parts, row.Slug+":"+row.Result)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestAdminImport(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.SaveCollection(ctx, store.Collection{Name: "docs", Slugs: []string{"wiki"}})
+
+	// A dry run reports without changing anything
+	code, report := postImport(t, s, "?dry_run=true&on_conflict=overwrite", importCSV)
+	if code != http.StatusOK || !report.DryRun {
+		t.Fatalf("dry run = %d %+v", code, report)
+	}
+	const want = "mail:created wiki:updated bad:failed hr:created mail:updated a:failed"
+	if got := results(report); got != want {
+		t.Errorf("dry run results = %s, want %s", got, want)
+	}
+	if report.Rows[0].Line != 2 || report.Rows[3].Line != 6 || report.Rows[2].Error == "" {
+		t.Errorf("dry run rows = %+v", report.Rows)
+	}
+	if _, err := st.GetLink(ctx, "mail"); err == nil {
+		t.Error("dry run added a link")
+	}
+	if c, _ := st.GetCollection(ctx, "tools"); c != nil {
+		t.Error("dry run created a collection")
+	}
+
+	code, report = postImport(t, s, "?on_conflict=overwrite", importCSV)
+	if got := results(report); code != http.StatusOK || got != want {
+		t.Errorf("import = %d %s, want %s", code, got, want)
+	}
+	if sum := report.Summary; sum[ImportCreated] != 2 || sum[ImportUpdated] != 2 || sum[ImportFailed] != 2 {
+		t.Errorf("summary = %v", sum)
+	}
+	if link, _ := st.GetLink(ctx, "mail"); link == nil || link.URL != "https://mail2.example.com" {
+		t.Errorf("mail = %+v", link)
+	}
+	if link, _ := st.GetLink(ctx, "wiki"); link == nil || link.URL != "https://wiki.example.com/new" {
+		t.Errorf("wiki = %+v", link)
+	}
+	if c, _ := st.GetCollection(ctx, "tools"); c == nil || strings.Join(c.Slugs, " ") != "mail wiki" {
+		t.Errorf("tools = %+v", c)
+	}
+	if c, _ := st.GetCollection(ctx, "docs"); c == nil || strings.Join(c.Slugs, " ") != "wiki hr" {
+		t.Errorf("docs = %+v", c)
+	}
+
+	// Skipping leaves taken slugs alone
+	code, report = postImport(t, s, "", "wiki,https://elsewhere.example.com\nnew,https://new.example.com\n")
+	if got := results(report); code != http.StatusOK || got != "wiki:skipped new:created" || report.OnConflict != ImportSkip {
+		t.Errorf("import with skip = %d %s", code, got)
+	}
+	if link, _ := st.GetLink(ctx, "wiki"); link.URL != "https://wiki.example.com/new" {
+		t.Errorf("skipped wiki = %+v", link)
+	}
+
+	for _, tt := range []struct{ query, csv string }{
+		{"", ""},
+		{"", "slug,url\n"},
+		{"", "wiki,\"unterminated\n"},
+		{"?on_conflict=replace", "x,https://x.example.com\n"},
+		{"?dry_run=maybe", "x,https://x.example.com\n"},
+	} {
+		if code, _ := postImport(t, s, tt.query, tt.csv); code != http.StatusBadRequest {
+			t.Errorf("import %q of %q = %d", tt.query, tt.csv, code)
+		}
+	}
+}
+
+func TestImportDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "team_wiki", URL: "https://wiki.example.com/"})
+
+	const csv = `Team-Wiki,https://wiki2.example.com
+handbook,http://www.Wiki.example.com#top
+docs,https://docs.example.com/?b=2&a=1
+docs2,https://docs.example.com?a=1&b=2&utm_source=mail
+team_wiki,https://wiki.example.com/new
+`
+	code, report := postImport(t, s, "", csv)
+	if got := results(report); code != http.StatusOK || got != "Team-Wiki:duplicate handbook:duplicate docs:created docs2:duplicate team_wiki:skipped" {
+		t.Fatalf("import = %d %s", code, got)
+	}
+	if report.OnDuplicate != ImportSkip || report.Summary[ImportDuplicate] != 3 || report.Rows[0].DuplicateOf != "team_wiki" {
+		t.Errorf("report = %+v", report)
+	}
+	var merges []string
+	for _, m := range report.Merges {
+		merges = append(merges, m.Slug+">"+m.Into+":"+m.Reason)
+	}
+	if got := strings.Join(merges, " "); got != "Team-Wiki>team_wiki:slug handbook>team_wiki:url docs2>docs:url" {
+		t.Errorf("merges = %s", got)
+	}
+	if _, err := st.GetLink(ctx, "handbook"); err == nil {
+		t.Error("duplicate created")
+	}
+
+	code, report = postImport(t, s, "?on_duplicate=create", "handbook,https://wiki.example.com\n")
+	if got := results(report); code != http.StatusOK || got != "handbook:created" || len(report.Merges) != 1 {
+		t.Errorf("import creating duplicates = %d %s %+v", code, got, report.Merges)
+	}
+	if code, _ := postImport(t, s, "?on_duplicate=merge", csv); code != http.StatusBadRequest {
+		t.Errorf("import with on_duplicate=merge = %d", code)
+	}
+}
+
+func TestURLKey(t *testing.T) {
+	for _, tt := range []struct{ a, b string }{
+		{"https://wiki.example.com", "http://WWW.wiki.example.com:443/"},
+		{"https://example.com/a/?x=1&y=2", "https://example.com/a?y=2&x=1&utm_campaign=q3#intro"},
+	} {
+		if urlKey(tt.a) != urlKey(tt.b) {
+			t.Errorf("urlKey(%q) = %q, urlKey(%q) = %q", tt.a, urlKey(tt.a), tt.b, urlKey(tt.b))
+		}
+	}
+	for _, tt := range []struct{ a, b string }{
+		{"https://example.com/a", "https://example.com/A"},
+		{"https://example.com/?x=1", "https://example.com/?x=2"},
+		{"https://example.com:8443", "https://example.com"},
+	} {
+		if urlKey(tt.a) == urlKey(tt.b) {
+			t.Errorf("urlKey(%q) = urlKey(%q)", tt.a, tt.b)
+		}
+	}
+	if urlKey("mailto:hr@example.com") != "" {
+		t.Error("urlKey of a mailto URL")
+	}
+}
+
+func TestImportHeader(t *testing.T) {
+	s, st := newTestServer(t, Config{})
+	code, report := postImport(t, s, "", "notes,URL,Slug\nfirst,https://a.example.com,a\nshort\n")
+	if got := results(report); code != http.StatusOK || got != "a:created short:failed" {
+		t.Errorf("import with header = %d %s", code, got)
+	}
+	if link, _ := st.GetLink(context.Background(), "a"); link == nil || link.URL != "https://a.example.com" {
+		t.Errorf("a = %+v", link)
+	}
+	if code, _ := postImport(t, s, "", "slug,destination\na,https://a.example.com\n"); code != http.StatusBadRequest {
+		t.Errorf("header without url = %d", code)
+	}
+}
+
+func TestImportShortener(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+
+	shlink := `{"shortUrls": {"data": [
+		{"shortCode": "wiki", "longUrl": "https://wiki.example.com", "title": "Team Wiki", "tags": ["Team Docs"]},
+		{"shortCode": "admin/links", "longUrl": "https://bad.example.com", "tags": []}
+	]}}`
+	code, report := postImport(t, s, "?format=shlink", shlink)
+	if got := results(report); code != http.StatusOK || got != "wiki:created admin/links:failed" {
+		t.Fatalf("shlink import = %d %s", code, got)
+	}
+	if row := report.Rows[0]; row.Line != 1 || row.Title != "Team Wiki" || strings.Join(row.Tags, " ") != "team-docs" {
+		t.Errorf("row = %+v", row)
+	}
+	if c, _ := st.GetCollection(ctx, "team-docs"); c == nil || strings.Join(c.Slugs, " ") != "wiki" {
+		t.Errorf("team-docs = %+v", c)
+	}
+
+	code, report = postImport(t, s, "?format=trotto", `[{"shortpath": "jira/%s", "destination_url": "https://jira.example.com/browse/%s"}]`)
+	if got := results(report); code != http.StatusOK || got != "jira/%s:failed" || report.Rows[0].Error != "Programmatic links are not supported" {
+		t.Errorf("trotto import = %d %+v", code, report.Rows)
+	}
+
+	code, report = postImport(t, s, "?format=yourls", "keyword,url,title\nhr,https://hr.example.com,HR\nwiki,https://other.example.com,\n")
+	if got := results(report); code != http.StatusOK || got != "hr:created wiki:skipped" {
+		t.Errorf("yourls import = %d %s", code, got)
+	}
+
+	for query, body := range map[string]string{
+		"?format=kutt":  "address,target\n",
+		"?format=bitly": "[]",
+	} {
+		if code, _ := postImport(t, s, query, body); code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", query, code)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golinks/internal/jobs"
+)
+
+// handleJobs serves GET /api/v1/jobs, the scheduled jobs with their last
+// run.
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	list := []jobs.Scheduled{}
+	if s.cfg.Scheduler != nil {
+		list = s.cfg.Scheduler.List()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleJob serves GET /api/v1/jobs/{id} for bulk jobs, which have numeric
+// IDs, GET /api/v1/jobs/{name} for scheduled jobs and POST
+// /api/v1/jobs/{name}/run to run a scheduled job now.
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/jobs/")
+	if name, ok := strings.CutSuffix(id, "/run"); ok {
+		s.handleJobRun(w, r, name)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var job any
+	found := false
+	if _, err := strconv.Atoi(id); err == nil {
+		if s.cfg.Jobs != nil {
+			job, found = s.cfg.Jobs.Get(id)
+		}
+	} else if s.cfg.Scheduler != nil {
+		job, found = s.cfg.Scheduler.Get(id)
+	}
+	if !found {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func (s *Server) handleJobRun(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.Scheduler == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	switch err := s.cfg.Scheduler.RunNow(name); {
+	case errors.Is(err, jobs.ErrUnknownJob):
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, jobs.ErrBusy):
+		http.Error(w, "Job is already running", http.StatusConflict)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Job started on demand", "job", name, "remote_addr", s.remote(r))
+
+	job, _ := s.cfg.Scheduler.Get(name)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/jobs/"+name)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"golinks/internal/jobs"
+)
+
+func TestScheduledJobs(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	scheduler := jobs.NewScheduler()
+	scheduler.Register("cleanup", jobs.Schedule{}, func(context.Context) error { return errors.New("store offline") })
+	go scheduler.Run(ctx)
+	s, _ := newTestServer(t, Config{Scheduler: scheduler})
+
+	rec := do(t, s, http.MethodGet, "/api/v1/jobs", nil, "", "")
+	var list []jobs.Scheduled
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list) != 1 || list[0].Name != "cleanup" || list[0].Runs != 0 {
+		t.Fatalf("GET /api/v1/jobs = %d %+v", rec.Code, list)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/api/v1/jobs/cleanup/run", nil, "", ""); rec.Code != http.StatusAccepted {
+		t.Fatalf("run now: status = %d: %s", rec.Code, rec.Body)
+	}
+	var job jobs.Scheduled
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Runs == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		rec = do(t, s, http.MethodGet, "/api/v1/jobs/cleanup", nil, "", "")
+		json.Unmarshal(rec.Body.Bytes(), &job)
+	}
+	if job.Runs != 1 || job.LastError != "store offline" {
+		t.Errorf("job after run = %+v", job)
+	}
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/api/v1/jobs/missing/run", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/jobs/cleanup/run", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/jobs/missing", http.StatusNotFound},
+		// Numeric IDs are bulk jobs, served only with a queue
+		{http.MethodGet, "/api/v1/jobs/1", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/jobs", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if rec := do(t, s, tt.method, tt.path, nil, "", ""); rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golinks/internal/ratelimit"
+)
+
+// LimitsResponse is the body of GET /api/v1/limits.
+type LimitsResponse struct {
+	Limits []ratelimit.Quota `json:"limits"`
+}
+
+// rateLimited counts the requests of each caller against RateLimit and
+// answers 429 once their quota is used up. Every response carries the
+// RateLimit headers; asking for the quota at /api/v1/limits is free. It is
+// a stage of the API routes by default, see StageRateLimit.
+func (s *Server) rateLimited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := s.rateKey(r)
+		if r.URL.Path == "/api/v1/limits" {
+			s.cfg.RateLimit.Peek(key).SetHeaders(w.Header())
+			next.ServeHTTP(w, r)
+			return
+		}
+		quota, ok := s.cfg.RateLimit.Allow(key)
+		quota.SetHeaders(w.Header())
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		slog.WarnContext(r.Context(), "Rate limited", "key", key, "path", r.URL.Path, "remote_addr", s.remote(r))
+		w.Header().Set("Retry-After", strconv.Itoa(quota.Reset))
+		refuse := func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Rate limit exceeded; try again later", http.StatusTooManyRequests)
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+			refuse = jsonErrors(refuse)
+		}
+		refuse(w, r)
+	})
+}
+
+// rateKey names the quota a request counts against: that of the admin it
+// logs in as, or else that of its client address, see clientNetwork.
+func (s *Server) rateKey(r *http.Request) string {
+	if user, pass, ok := r.BasicAuth(); ok && s.cfg.Admins[user] != "" && pass == s.cfg.Admins[user] {
+		return "admin " + user
+	}
+	if ip, ok := s.clientIP(r); ok {
+		return "client " + clientNetwork(ip)
+	}
+	return "client " + r.RemoteAddr
+}
+
+// handleV1Limits serves GET /api/v1/limits, the caller's quotas.
+func (s *Server) handleV1Limits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LimitsResponse{Limits: []ratelimit.Quota{s.cfg.RateLimit.Peek(s.rateKey(r))}})
+}
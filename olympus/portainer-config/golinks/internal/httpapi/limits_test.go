@@ -0,0 +1,73 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golinks/internal/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	cfg := twoAdmins
+	cfg.RateLimit = ratelimit.New(ratelimit.Config{Limit: 2, Window: time.Minute})
+	s, _ := newTestServer(t, cfg)
+
+	for i := 0; i < 2; i++ {
+		rec := do(t, s, http.MethodGet, "/api/v1/links", nil, "alice", "pw1")
+		if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != strconv.Itoa(1-i) {
+			t.Fatalf("request %d: %d %v", i, rec.Code, rec.Header())
+		}
+	}
+	rec := do(t, s, http.MethodGet, "/api/v1/links", nil, "alice", "pw1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" || apiError(t, rec.Body.Bytes()).Code != "rate_limited" {
+		t.Fatalf("over the limit: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+	// Redirects and other admins are not limited
+	if rec := do(t, s, http.MethodGet, "/nope", nil, "", ""); rec.Code == http.StatusTooManyRequests || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("redirect: %d %v", rec.Code, rec.Header())
+	}
+	if rec := do(t, s, http.MethodGet, "/api/v1/links", nil, "bob", "pw2"); rec.Code != http.StatusOK {
+		t.Errorf("bob: %d", rec.Code)
+	}
+
+	rec = do(t, s, http.MethodGet, "/api/v1/limits", nil, "alice", "pw1")
+	var resp LimitsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("limits: %d %s", rec.Code, rec.Body)
+	}
+	if len(resp.Limits) != 1 || resp.Limits[0].Limit != 2 || resp.Limits[0].Remaining != 0 || resp.Limits[0].Reset <= 0 {
+		t.Errorf("limits = %+v", resp.Limits)
+	}
+}
+
+func TestRateLimitIPv6(t *testing.T) {
+	s, _ := newTestServer(t, Config{RateLimit: ratelimit.New(ratelimit.Config{Limit: 2, Window: time.Minute})})
+	get := func(remote string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/links", nil)
+		r.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// A host hopping between privacy addresses of its /64 shares one quota
+	get("[2001:db8:1:2::aaaa]:1234")
+	get("[2001:db8:1:2::bbbb]:1234")
+	if code := get("[2001:db8:1:2::cccc]:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("third request of the /64 = %d, want 429", code)
+	}
+	if code := get("[2001:db8:1:3::aaaa]:1234"); code != http.StatusOK {
+		t.Errorf("request of another /64 = %d", code)
+	}
+
+	// Over IPv4 and IPv4-mapped IPv6, a client is the same
+	get("192.0.2.7:1234")
+	get("[::ffff:192.0.2.7]:1234")
+	if code := get("192.0.2.7:4321"); code != http.StatusTooManyRequests {
+		t.Errorf("third request of 192.0.2.7 = %d, want 429", code)
+	}
+}
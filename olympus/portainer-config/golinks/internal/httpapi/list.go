@@ -0,0 +1,150 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// Link list pages hold defaultPageSize links unless ?limit= asks for up to
+// maxPageSize.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Sorts of the link list API.
+const (
+	SortCreatedAt = "created_at"
+	SortSlug      = "slug"
+)
+
+// LinkPage is one page of the link list API.
+type LinkPage struct {
+	Links []store.Link `json:"links"`
+	Page  int          `json:"page"`
+	Limit int          `json:"limit"`
+	// Total counts the links matching the filter, Pages the pages they
+	// fill.
+	Total int `json:"total"`
+	Pages int `json:"pages"`
+}
+
+// queryInt parses the query parameter name of r, def if absent. It writes
+// a 400 response and returns false if the value is not a number between 1
+// and limit (0 for no limit).
+func queryInt(w http.ResponseWriter, r *http.Request, name string, def, limit int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || (limit > 0 && n > limit) {
+		msg := name + " must be a positive number"
+		if limit > 0 {
+			msg = fmt.Sprintf("%s must be between 1 and %d", name, limit)
+		}
+		http.Error(w, msg, http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+// searchLinks returns the links whose slug or URL contains search,
+// ignoring case, and whose custom fields match fields, in order or, with
+// reverse, the other way around.
+func (s *Server) searchLinks(ctx context.Context, search string, fields map[string]string, order store.LinkOrder, reverse bool) ([]store.Link, error) {
+	search = strings.ToLower(strings.TrimSpace(search))
+	links := []store.Link{}
+	err := s.store.EachLinkBy(ctx, order, func(link store.Link) error {
+		if !matchFields(link, fields) {
+			return nil
+		}
+		if search == "" || strings.Contains(strings.ToLower(link.Slug), search) || strings.Contains(strings.ToLower(link.URL), search) {
+			links = append(links, link)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reverse {
+		slices.Reverse(links)
+	}
+	return links, nil
+}
+
+// handleLinks serves GET /api/links: every link, or those whose slug or
+// URL contains ?q= and whose custom fields match ?field.<name>=, sorted by
+// ?sort= (created_at or slug) in ?order= (asc or desc) and split into pages
+// by ?page= and ?limit=.
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	page, ok := queryInt(w, r, "page", 1, 0)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(w, r, "limit", defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = SortCreatedAt
+	}
+	// Newest first and A to Z unless asked otherwise
+	order, desc := store.OrderNewest, true
+	switch sort {
+	case SortCreatedAt:
+	case SortSlug:
+		order, desc = store.OrderAlpha, false
+	default:
+		http.Error(w, "sort must be created_at or slug", http.StatusBadRequest)
+		return
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	fields, err := s.fieldFilter(query)
+	var invalid *InvalidError
+	if errors.As(err, &invalid) {
+		writeInvalid(w, invalid)
+		return
+	}
+
+	links, err := s.searchLinks(r.Context(), query.Get("q"), fields, order, desc != (order == store.OrderNewest))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing links", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	result := LinkPage{Page: page, Limit: limit, Total: len(links), Pages: (len(links) + limit - 1) / limit}
+	start := min((page-1)*limit, len(links))
+	result.Links = links[start:min(start+limit, len(links))]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
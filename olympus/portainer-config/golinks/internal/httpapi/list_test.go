@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestListLinks(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, slug := range []string{"wiki", "mail", "cal", "team/wiki", "docs"} {
+		st.RestoreLink(ctx, store.Link{Slug: slug, URL: "https://" + slug + ".example.com", CreatedAt: created.AddDate(0, 0, i)})
+	}
+
+	slugs := func(target string) string {
+		t.Helper()
+		rec := do(t, s, http.MethodGet, target, nil, "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var page LinkPage
+		json.Unmarshal(rec.Body.Bytes(), &page)
+		var got []string
+		for _, link := range page.Links {
+			got = append(got, link.Slug)
+		}
+		return fmt.Sprintf("%v %d/%d of %d", got, page.Page, page.Pages, page.Total)
+	}
+
+	tests := []struct{ target, want string }{
+		{"/api/links", "[docs team/wiki cal mail wiki] 1/1 of 5"},
+		{"/api/links?sort=created_at&order=asc", "[wiki mail cal team/wiki docs] 1/1 of 5"},
+		{"/api/links?sort=slug", "[cal docs mail team/wiki wiki] 1/1 of 5"},
+		{"/api/links?sort=slug&order=desc&limit=2", "[wiki team/wiki] 1/3 of 5"},
+		{"/api/links?sort=slug&limit=2&page=3", "[wiki] 3/3 of 5"},
+		{"/api/links?limit=2&page=9", "[] 9/3 of 5"},
+		{"/api/links?q=WIKI", "[team/wiki wiki] 1/1 of 2"},
+		{"/api/links?q=mail.example", "[mail] 1/1 of 1"},
+	}
+	for _, tt := range tests {
+		if got := slugs(tt.target); got != tt.want {
+			t.Errorf("GET %s = %s, want %s", tt.target, got, tt.want)
+		}
+	}
+
+	for _, target := range []string{"/api/links?sort=url", "/api/links?order=up", "/api/links?page=0", "/api/links?limit=501", "/api/links?limit=x"} {
+		if rec := do(t, s, http.MethodGet, target, nil, "", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
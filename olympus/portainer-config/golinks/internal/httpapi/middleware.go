@@ -0,0 +1,314 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"golinks/internal/accesslog"
+	"golinks/internal/logging"
+)
+
+// Route groups, each served through a chain of its own.
+const (
+	// GroupRedirect is the redirects and every other page outside the API
+	// and admin routes, such as the link list and /healthz.
+	GroupRedirect = "redirect"
+	// GroupAPI is /api/ and /graphql.
+	GroupAPI = "api"
+	// GroupAdmin is /admin/, the profiles under /debug/pprof/ and the chat
+	// callbacks under /chat/.
+	GroupAdmin = "admin"
+)
+
+// Groups lists the route groups.
+var Groups = []string{GroupRedirect, GroupAPI, GroupAdmin}
+
+// Middleware stages a chain is made of.
+const (
+	// StageRequestID gives the request an ID, see withRequestID.
+	StageRequestID = "request_id"
+	// StageRecover answers 500 for a handler that panics, and logs it,
+	// instead of dropping the connection.
+	StageRecover = "recover"
+	// StageLog logs every request with its status and duration.
+	StageLog = "log"
+	// StageCORS lets the pages of CORSOrigins call the routes from the
+	// browser.
+	StageCORS = "cors"
+	// StageCompress gzips responses for clients that accept it.
+	StageCompress = "compress"
+	// StageRateLimit counts requests against RateLimit, if set.
+	StageRateLimit = "rate_limit"
+	// StageAuth refuses wrong admin credentials before the stages after it.
+	// Routes that need a login check it again themselves, so leaving it
+	// out never opens one.
+	StageAuth = "auth"
+	// StageErrors answers with JSON errors clients that accept JSON.
+	StageErrors = "errors"
+)
+
+// Stages lists the middleware stages.
+var Stages = []string{StageRequestID, StageRecover, StageLog, StageCORS, StageCompress, StageRateLimit, StageAuth, StageErrors}
+
+// defaultChains are the chains of the groups Chains leaves out, outermost
+// stage first.
+var defaultChains = map[string][]string{
+	GroupRedirect: {StageRequestID},
+	GroupAPI:      {StageRequestID, StageErrors, StageRateLimit},
+	GroupAdmin:    {StageRequestID, StageErrors},
+}
+
+// DefaultChain returns the chain group has unless configured otherwise.
+func DefaultChain(group string) []string {
+	return slices.Clone(defaultChains[group])
+}
+
+// CheckChain returns an error if chain names an unknown stage or one
+// twice.
+func CheckChain(chain []string) error {
+	for i, stage := range chain {
+		if !slices.Contains(Stages, stage) {
+			return fmt.Errorf("unknown middleware %q, want one of %s", stage, strings.Join(Stages, ", "))
+		}
+		if slices.Contains(chain[:i], stage) {
+			return fmt.Errorf("middleware %q listed twice", stage)
+		}
+	}
+	return nil
+}
+
+// routeGroup returns the group of a request path.
+func routeGroup(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/") || path == "/graphql":
+		return GroupAPI
+	case strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/debug/") || strings.HasPrefix(path, "/chat/"):
+		return GroupAdmin
+	}
+	return GroupRedirect
+}
+
+// grouped serves each request through the chain of its route group
+// around next.
+func (s *Server) grouped(next http.Handler) http.Handler {
+	chains := make(map[string]http.Handler, len(Groups))
+	for _, group := range Groups {
+		chain, ok := s.cfg.Chains[group]
+		if !ok {
+			chain = defaultChains[group]
+		}
+		h := next
+		for i := len(chain) - 1; i >= 0; i-- {
+			h = s.stage(group, chain[i], h)
+		}
+		chains[group] = h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chains[routeGroup(r.URL.Path)].ServeHTTP(w, r)
+	})
+}
+
+// stage wraps next in the middleware named stage, for the routes of group.
+func (s *Server) stage(group, stage string, next http.Handler) http.Handler {
+	switch stage {
+	case StageRequestID:
+		// Redirects make up an ID only if something needs it
+		if group == GroupRedirect {
+			return withLazyRequestID(next)
+		}
+		return withRequestID(next)
+	case StageRecover:
+		return recovering(next)
+	case StageLog:
+		return s.logged(next)
+	case StageCORS:
+		return s.cors(next)
+	case StageCompress:
+		return compressed(next)
+	case StageRateLimit:
+		if s.cfg.RateLimit != nil {
+			return s.rateLimited(next)
+		}
+	case StageAuth:
+		return s.authenticated(next)
+	case StageErrors:
+		return negotiatedErrors(next)
+	}
+	return next
+}
+
+// recovering answers 500 for a panic in next, unless it aborts the
+// response on purpose.
+func recovering(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			slog.ErrorContext(r.Context(), "Handler panicked", "path", r.URL.Path, "panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logged logs every request once it is answered.
+func (s *Server) logged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		slog.InfoContext(r.Context(), "Request", "method", r.Method, "path", r.URL.Path, "status", sw.status, "bytes", sw.bytes,
+			"duration", time.Since(start).Round(time.Microsecond).String(), "remote_addr", s.remote(r))
+	})
+}
+
+// accessLogged writes a line to the AccessLog for every request once it is
+// answered.
+func (s *Server) accessLogged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		user, _, _ := r.BasicAuth()
+		s.cfg.AccessLog.Log(accesslog.Entry{
+			Time:       start,
+			RemoteAddr: s.remote(r),
+			User:       user,
+			Method:     r.Method,
+			URI:        r.RequestURI,
+			Proto:      r.Proto,
+			Status:     sw.status,
+			Bytes:      sw.bytes,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			Duration:   time.Since(start),
+			RequestID:  w.Header().Get(logging.RequestIDHeader),
+		})
+	})
+}
+
+// cors lets pages of CORSOrigins call next, credentials included, and
+// answers their preflight requests. With "*", any page may call it, but
+// only without credentials, so no site can act with an admin's login.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		listed := slices.Contains(s.cfg.CORSOrigins, origin)
+		if origin == "" || !listed && !slices.Contains(s.cfg.CORSOrigins, "*") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		allowHeaders := "Content-Type, X-Request-Id"
+		if listed {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+			allowHeaders = "Authorization, " + allowHeaders
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		h.Set("Access-Control-Expose-Headers", "Location, Retry-After, X-Request-Id")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compressed gzips the responses of next for clients that accept it,
+// except redirects and responses without a body.
+func compressed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the Accept-Encoding of r lists gzip, not
+// refused with q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if strings.TrimSpace(name) == "gzip" {
+			q := strings.ReplaceAll(params, " ", "")
+			return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+		}
+	}
+	return false
+}
+
+// gzipWriter compresses the body of a response once its status shows it
+// has one.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	body := code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified && (code < 300 || code >= 400)
+	if body && h.Get("Content-Encoding") == "" {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		// Sniffed from the compressed bytes it would be wrong
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
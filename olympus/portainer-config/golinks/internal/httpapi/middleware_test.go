@@ -0,0 +1,138 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"golinks/internal/accesslog"
+	"golinks/internal/store"
+)
+
+func TestChains(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{
+		Admins: map[string]string{"admin": "secret"},
+		Chains: map[string][]string{
+			GroupRedirect: {},
+			GroupAPI:      {StageRequestID, StageCORS, StageCompress, StageAuth, StageErrors},
+		},
+		CORSOrigins: []string{"https://intranet.example.com"},
+	})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	// An empty chain leaves out even the request ID
+	if rec := do(t, s, http.MethodGet, "/wiki", nil, "", ""); rec.Code != http.StatusFound || rec.Header().Get("X-Request-Id") != "" {
+		t.Errorf("redirect = %d, request id %q", rec.Code, rec.Header().Get("X-Request-Id"))
+	}
+	// The admin group keeps its default
+	if rec := do(t, s, http.MethodGet, "/admin/links", nil, "admin", "secret"); rec.Header().Get("X-Request-Id") == "" {
+		t.Error("admin response without a request id")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/links", nil)
+	req.SetBasicAuth("admin", "secret")
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("list = %d, Content-Encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := io.ReadAll(zr); err != nil || !strings.Contains(string(body), "wiki.example.com") {
+		t.Errorf("list body = %q, %v", body, err)
+	}
+
+	// Wrong credentials are refused even where no login is needed
+	if rec := do(t, s, http.MethodGet, "/api/v1/limits", nil, "admin", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong credentials = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/api/v1/links", nil)
+	req.Header.Set("Origin", "https://intranet.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://intranet.example.com" {
+		t.Errorf("preflight = %d, %v", rec.Code, rec.Header())
+	}
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight of another origin allowed: %v", rec.Header())
+	}
+
+	// A wildcard lets any page in, but never with credentials
+	s, _ = newTestServer(t, Config{
+		Chains:      map[string][]string{GroupAPI: {StageCORS}},
+		CORSOrigins: []string{"*"},
+	})
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	h := rec.Header()
+	if h.Get("Access-Control-Allow-Origin") != "*" || h.Get("Access-Control-Allow-Credentials") != "" || strings.Contains(h.Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("wildcard preflight = %v", h)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	s, st := newTestServer(t, Config{
+		AccessLog:      accesslog.New(&buf, accesslog.Combined),
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+	st.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	req := httptest.NewRequest(http.MethodGet, "/wiki", nil)
+	req.RemoteAddr = "10.0.0.2:4321"
+	req.Header.Set("X-Forwarded-For", "192.0.2.7")
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	do(t, s, http.MethodGet, "/nope", nil, "", "")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("access log:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "192.0.2.7 - - [") || !strings.HasSuffix(lines[0], `] "GET /wiki HTTP/1.1" 302 - "-" "curl/8.5.0"`) {
+		t.Errorf("redirect logged as %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"GET /nope HTTP/1.1" 404 `) {
+		t.Errorf("miss logged as %s", lines[1])
+	}
+}
+
+func TestRecovering(t *testing.T) {
+	h := recovering(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("panic = %d", rec.Code)
+	}
+}
+
+func TestCheckChain(t *testing.T) {
+	for _, group := range Groups {
+		if err := CheckChain(DefaultChain(group)); err != nil {
+			t.Errorf("default %s chain: %v", group, err)
+		}
+	}
+	if err := CheckChain([]string{StageLog, "gzip"}); err == nil {
+		t.Error("unknown stage accepted")
+	}
+	if err := CheckChain([]string{StageLog, StageRecover, StageLog}); err == nil {
+		t.Error("repeated stage accepted")
+	}
+}
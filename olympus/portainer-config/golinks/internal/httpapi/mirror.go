@@ -0,0 +1,34 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// mirrorWritable are the paths a read-only mirror still takes changes on:
+// queries, jobs, and state of the instance itself rather than its links.
+var mirrorWritable = []string{"/graphql", "/api/v1/jobs/", "/admin/snapshots/", "/admin/banner"}
+
+// readOnly refuses requests that would change the links of a mirror, which
+// are overwritten by the next sync from its upstream.
+func (s *Server) readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range mirrorWritable {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		refuse := func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Read-only mirror of "+s.cfg.MirrorOf+"; change links there", http.StatusForbidden)
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+			refuse = jsonErrors(refuse)
+		}
+		refuse(w, r)
+	})
+}
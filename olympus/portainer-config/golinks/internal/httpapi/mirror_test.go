@@ -0,0 +1,36 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestMirrorReadOnly(t *testing.T) {
+	s, st := newTestServer(t, Config{MirrorOf: "https://go.example.com"})
+	st.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com", Status: store.StatusActive})
+
+	if rec := do(t, s, http.MethodGet, "/wiki", nil, "", ""); rec.Code != http.StatusFound {
+		t.Errorf("GET /wiki = %d, want 302", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/api/v1/links/wiki", nil, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/v1/links/wiki = %d, want 200", rec.Code)
+	}
+	rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: "new", URL: "https://new.example.com"}, "", "")
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "https://go.example.com") {
+		t.Errorf("POST /admin/add = %d %q, want 403 naming the upstream", rec.Code, rec.Body)
+	}
+	rec = do(t, s, http.MethodDelete, "/api/v1/links/wiki", nil, "", "")
+	if rec.Code != http.StatusForbidden || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("DELETE /api/v1/links/wiki = %d %s, want a JSON 403", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if _, err := st.GetLink(context.Background(), "wiki"); err != nil {
+		t.Errorf("wiki removed on a mirror: %v", err)
+	}
+	if rec := do(t, s, http.MethodPost, "/graphql", map[string]string{"query": "{ links { slug } }"}, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("POST /graphql = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes the JSON API. It is written by hand: update it with
+// every route or request type that changes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIAssets is where the API docs page loads Swagger UI from.
+const swaggerUIAssets = "https://cdn.jsdelivr.net/npm/swagger-ui-dist@5"
+
+var apiDocsPage = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>golinks API</title>
+	<link rel="stylesheet" href="` + swaggerUIAssets + `/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="` + swaggerUIAssets + `/swagger-ui-bundle.js"></script>
+	<script>SwaggerUIBundle({url: "/api/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`)
+
+// handleOpenAPI serves the OpenAPI description of the API.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// handleAPIDocs serves Swagger UI for the OpenAPI description.
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(apiDocsPage)
+}
@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// notInSpec are routes left out of the OpenAPI description: callbacks of
+// chat integrations and the profiles for go tool pprof, not meant for API
+// clients.
+var notInSpec = map[string]bool{"/chat/slack": true, "/chat/approval": true, "/debug/pprof/": true}
+
+func TestOpenAPISpec(t *testing.T) {
+	var spec struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q", spec.OpenAPI)
+	}
+
+	// Every reference points at a component
+	var doc map[string]any
+	json.Unmarshal(openAPISpec, &doc)
+	for _, m := range regexp.MustCompile(`"\$ref": "#/components/(\w+)/(\w+)"`).FindAllStringSubmatch(string(openAPISpec), -1) {
+		kind, _ := doc["components"].(map[string]any)[m[1]].(map[string]any)
+		if _, ok := kind[m[2]]; !ok {
+			t.Errorf("unresolved reference to %s/%s", m[1], m[2])
+		}
+	}
+
+	// Every route registered by Handler is described; prefix routes by a
+	// path under them
+	src, err := os.ReadFile("server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := regexp.MustCompile(`mux\.Handle(?:Func)?\("([^"]+)"`).FindAllStringSubmatch(string(src), -1)
+	if len(routes) < 10 {
+		t.Fatalf("found only %d routes in server.go", len(routes))
+	}
+	for _, m := range routes {
+		route := m[1]
+		if notInSpec[route] {
+			continue
+		}
+		found := false
+		for path := range spec.Paths {
+			if path == route || (strings.HasSuffix(route, "/") && strings.HasPrefix(path, route)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("route %s missing from openapi.json", route)
+		}
+	}
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	rec := do(t, s, http.MethodGet, "/api/openapi.json", nil, "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || !json.Valid(rec.Body.Bytes()) {
+		t.Errorf("GET /api/openapi.json: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec := do(t, s, http.MethodGet, "/api/docs", nil, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("API docs served without APIDocs: %d", rec.Code)
+	}
+
+	s, _ = newTestServer(t, Config{APIDocs: true})
+	rec = do(t, s, http.MethodGet, "/api/docs", nil, "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `url: "/api/openapi.json"`) {
+		t.Errorf("GET /api/docs: %d\n%s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,201 @@
+package httpapi
+
+import (
+	"errors"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// Paste formats recognized by ParsePaste.
+const (
+	PasteURL      = "URL"
+	PasteSlugURL  = "slug and URL"
+	PasteMarkdown = "Markdown link"
+	PasteSlack    = "Slack link"
+)
+
+var (
+	// markdownLink matches [text](url) and [text](url "title").
+	markdownLink = regexp.MustCompile(`\[([^\]]*)\]\(\s*<?(\S+?)>?(?:\s+"[^"]*")?\s*\)`)
+	// slackLink matches <url|text> and <url> as Slack formats links,
+	// which is also a Markdown autolink.
+	slackLink = regexp.MustCompile(`<(https?://[^|>\s]+)(?:\|([^>]*))?>`)
+)
+
+// Pasted is what ParsePaste found in a pasted text.
+type Pasted struct {
+	Slug string
+	URL  string
+	// Format is one of the Paste constants, "" if no URL was found.
+	Format string
+}
+
+// ParsePaste finds a link in text pasted into the add form: a bare URL,
+// "slug URL" (also "go/slug URL" or "slug -> URL"), a Markdown link or a
+// Slack-formatted link. The text of Markdown and Slack links is turned into
+// a slug suggestion.
+func ParsePaste(text string) Pasted {
+	text = strings.TrimSpace(text)
+	if m := slackLink.FindStringSubmatch(text); m != nil {
+		p := Pasted{URL: html.UnescapeString(m[1]), Format: PasteSlack}
+		if m[2] != "" {
+			p.Slug = kebab(html.UnescapeString(m[2]))
+		} else {
+			p.Slug = pastedSlug(strings.Fields(text[:strings.Index(text, m[0])]))
+		}
+		return p
+	}
+	if m := markdownLink.FindStringSubmatch(text); m != nil && isValidURL(m[2]) {
+		return Pasted{Slug: kebab(m[1]), URL: m[2], Format: PasteMarkdown}
+	}
+
+	fields := strings.Fields(text)
+	for i, field := range fields {
+		u := pastedURL(field)
+		if u == "" {
+			continue
+		}
+		if slug := pastedSlug(fields[:i]); slug != "" {
+			return Pasted{Slug: slug, URL: u, Format: PasteSlugURL}
+		}
+		return Pasted{URL: u, Format: PasteURL}
+	}
+	return Pasted{}
+}
+
+// pastedURL returns field as a URL if it is one, adding https:// to
+// "www." hosts and dropping punctuation that ends a sentence.
+func pastedURL(field string) string {
+	field = strings.TrimRight(field, ".,;:!?)")
+	if strings.HasPrefix(field, "www.") {
+		field = "https://" + field
+	}
+	if !isValidURL(field) {
+		return ""
+	}
+	return field
+}
+
+// pastedSlug returns the slug named by the words before a URL: a single
+// word, optionally with a "go/" prefix and a separator such as ":" or "->",
+// else "".
+func pastedSlug(words []string) string {
+	if n := len(words); n > 1 && strings.Trim(words[n-1], "-=>:→") == "" {
+		words = words[:n-1]
+	}
+	if len(words) != 1 {
+		return ""
+	}
+	slug := strings.TrimSuffix(words[0], ":")
+	slug = strings.TrimPrefix(strings.TrimPrefix(slug, "http://"), "https://")
+	if rest, ok := strings.CutPrefix(slug, "go/"); ok {
+		slug = rest
+	}
+	if !isValidSlug(slug) {
+		return ""
+	}
+	return slug
+}
+
+// AddForm is the state of the quick-add page at /admin/new.
+type AddForm struct {
+	Paste string
+	Slug  string
+	URL   string
+	// Format names what the paste was recognized as, if it was parsed.
+	Format string
+	Error  string
+	// Fields are the custom fields to fill in, with the values given.
+	Fields []FieldValue
+}
+
+// handleAddForm serves the quick-add page. Pasting text and pressing
+// "Fill in" parses it into the slug and URL fields; "Add" creates the link
+// and redirects to its info page.
+func (s *Server) handleAddForm(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.pages.AddForm(w, r, AddForm{Fields: FieldValues(s.cfg.LinkFields, nil)}, http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Browsers resend Basic credentials with any form post, so refuse
+	// posts from other sites.
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" || !sameOrigin(r) {
+		http.Error(w, "Cross-site form posts are not allowed", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	form := AddForm{
+		Paste: r.PostFormValue("paste"),
+		Slug:  strings.TrimSpace(r.PostFormValue("slug")),
+		URL:   strings.TrimSpace(r.PostFormValue("url")),
+	}
+	values := make(map[string]string)
+	for _, f := range s.cfg.LinkFields {
+		values[f.Name] = r.PostFormValue("field." + f.Name)
+	}
+	form.Fields = FieldValues(s.cfg.LinkFields, values)
+	if r.PostFormValue("action") == "parse" {
+		p := ParsePaste(form.Paste)
+		if p.Format == "" {
+			form.Error = "No link found in the pasted text"
+			s.pages.AddForm(w, r, form, http.StatusBadRequest)
+			return
+		}
+		form.Slug, form.URL, form.Format = p.Slug, p.URL, p.Format
+		s.pages.AddForm(w, r, form, http.StatusOK)
+		return
+	}
+
+	link, err := s.AddLink(r.Context(), AddLinkRequest{Slug: form.Slug, URL: form.URL, Fields: values}, s.adminName(r))
+	if err != nil {
+		var invalid *InvalidError
+		code := http.StatusBadRequest
+		if errors.As(err, &invalid) {
+			form.Error = invalid.Msg
+		} else {
+			slog.ErrorContext(r.Context(), "Error saving link", "error", err)
+			code, form.Error = httperr.Status(err)
+		}
+		s.pages.AddForm(w, r, form, code)
+		return
+	}
+
+	if link.Status == store.StatusPending {
+		slog.InfoContext(r.Context(), "Link pending approval", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	slog.InfoContext(r.Context(), "Link added", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	target := "/"
+	if s.pages.Info != nil {
+		target = "/" + link.Slug + "+"
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// sameOrigin reports whether the Origin header, if any, names the host the
+// request was sent to.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
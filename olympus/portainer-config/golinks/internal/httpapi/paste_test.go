@@ -0,0 +1,114 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestParsePaste(t *testing.T) {
+	tests := []struct {
+		text string
+		want Pasted
+	}{
+		{"https://wiki.example.com/Home", Pasted{URL: "https://wiki.example.com/Home", Format: PasteURL}},
+		{"  www.example.com/docs.\n", Pasted{URL: "https://www.example.com/docs", Format: PasteURL}},
+		{"Have a look at https://example.com/a, it's great", Pasted{URL: "https://example.com/a", Format: PasteURL}},
+		{"wiki https://wiki.example.com", Pasted{Slug: "wiki", URL: "https://wiki.example.com", Format: PasteSlugURL}},
+		{"go/team/oncall -> https://oncall.example.com", Pasted{Slug: "team/oncall", URL: "https://oncall.example.com", Format: PasteSlugURL}},
+		{"wiki: https://wiki.example.com", Pasted{Slug: "wiki", URL: "https://wiki.example.com", Format: PasteSlugURL}},
+		{"[Quarterly Planning](https://docs.example.com/q3)", Pasted{Slug: "quarterly-planning", URL: "https://docs.example.com/q3", Format: PasteMarkdown}},
+		{`Read [the RFC](https://rfc.example.com "RFC 42") first`, Pasted{Slug: "the-rfc", URL: "https://rfc.example.com", Format: PasteMarkdown}},
+		{"<https://status.example.com/?a=1&amp;b=2|Status Page>", Pasted{Slug: "status-page", URL: "https://status.example.com/?a=1&b=2", Format: PasteSlack}},
+		{"status <https://status.example.com>", Pasted{Slug: "status", URL: "https://status.example.com", Format: PasteSlack}},
+		{"just some words", Pasted{}},
+		{"[broken](not a url)", Pasted{}},
+		{"", Pasted{}},
+	}
+	for _, tt := range tests {
+		if got := ParsePaste(tt.text); got != tt.want {
+			t.Errorf("ParsePaste(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestAddForm(t *testing.T) {
+	var rendered AddForm
+	pages := Pages{
+		AddForm: func(w http.ResponseWriter, r *http.Request, form AddForm, status int) {
+			rendered = form
+			w.WriteHeader(status)
+		},
+		Info: func(w http.ResponseWriter, r *http.Request, link store.Link) {},
+	}
+	st := store.NewMemory()
+	s := New(twoAdmins, st, pages)
+	post := func(form url.Values, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		rendered = AddForm{}
+		req := httptest.NewRequest(http.MethodPost, "/admin/new", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.SetBasicAuth("alice", "pw1")
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(t, s, http.MethodGet, "/admin/new", nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET without login = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/admin/new", nil, "alice", "pw1"); rec.Code != http.StatusOK {
+		t.Errorf("GET = %d", rec.Code)
+	}
+
+	rec := post(url.Values{"action": {"parse"}, "paste": {"[Team Wiki](https://wiki.example.com)"}}, nil)
+	if rec.Code != http.StatusOK || rendered.Slug != "team-wiki" || rendered.URL != "https://wiki.example.com" || rendered.Format != PasteMarkdown {
+		t.Errorf("parse = %d %+v", rec.Code, rendered)
+	}
+	if rec := post(url.Values{"action": {"parse"}, "paste": {"nothing here"}}, nil); rec.Code != http.StatusBadRequest || rendered.Error == "" {
+		t.Errorf("parse without a link = %d %+v", rec.Code, rendered)
+	}
+
+	rec = post(url.Values{"action": {"add"}, "slug": {"team-wiki"}, "url": {"https://wiki.example.com"}}, nil)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/team-wiki+" {
+		t.Fatalf("add = %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	link, err := st.GetLink(context.Background(), "team-wiki")
+	if err != nil || link.URL != "https://wiki.example.com" || link.CreatedBy != "alice" {
+		t.Errorf("added link = %+v, %v", link, err)
+	}
+
+	// Failures show the form again with what was entered
+	rec = post(url.Values{"action": {"add"}, "slug": {"team-wiki"}, "url": {"https://other.example.com"}}, nil)
+	if rec.Code != http.StatusConflict || rendered.Error != "Slug already exists" || rendered.URL != "https://other.example.com" {
+		t.Errorf("duplicate add = %d %+v", rec.Code, rendered)
+	}
+	if rec := post(url.Values{"action": {"add"}, "slug": {"docs"}, "url": {"ftp://docs"}}, nil); rec.Code != http.StatusBadRequest || rendered.Error == "" {
+		t.Errorf("invalid URL = %d %+v", rec.Code, rendered)
+	}
+
+	// Posts from other sites are refused
+	for _, header := range []http.Header{
+		{"Origin": {"https://evil.example"}},
+		{"Sec-Fetch-Site": {"cross-site"}},
+	} {
+		rec := post(url.Values{"action": {"add"}, "slug": {"evil"}, "url": {"https://evil.example"}}, header)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("post with %v = %d", header, rec.Code)
+		}
+	}
+	if _, err := st.GetLink(context.Background(), "evil"); err == nil {
+		t.Error("cross-site post added a link")
+	}
+	if rec := post(url.Values{"action": {"add"}, "slug": {"same"}, "url": {"https://same.example"}}, http.Header{"Origin": {"http://example.com"}}); rec.Code != http.StatusSeeOther {
+		t.Errorf("same-origin post = %d", rec.Code)
+	}
+}
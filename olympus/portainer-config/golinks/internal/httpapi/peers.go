@@ -0,0 +1,44 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"golinks/internal/logging"
+	"golinks/internal/peers"
+	"golinks/internal/store"
+)
+
+// redirectToPeer looks up slug on the peers and, if one has it, redirects
+// there or answers a preview request. It reports whether it answered r.
+// Redirects to peers' links are not counted as clicks: the link is not
+// ours.
+func (s *Server) redirectToPeer(w http.ResponseWriter, r *http.Request, slug string) bool {
+	link, err := s.cfg.Peers.Resolve(r.Context(), slug)
+	if err != nil {
+		if !errors.Is(err, peers.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Error looking up slug on peers", "slug", slug, "error", err, "remote_addr", s.remote(r))
+		}
+		return false
+	}
+	if isPreviewRequest(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(LinkPreview{
+			Slug:        slug,
+			URL:         link.URL,
+			Destination: link.URL,
+			Status:      store.StatusActive,
+			Referrer:    link.Referrer,
+			Peer:        link.Peer,
+		})
+		return true
+	}
+	if logging.Enabled(logging.LevelInfo) {
+		slog.InfoContext(r.Context(), "Redirect", "status", http.StatusFound, "slug", slug, "url", link.URL, "peer", link.Peer, "remote_addr", s.remote(r))
+	}
+	redirectWithReferrer(w, link.URL, link.Referrer)
+	return true
+}
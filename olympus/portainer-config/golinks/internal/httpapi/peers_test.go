@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golinks/internal/peers"
+	"golinks/internal/store"
+)
+
+func TestPeers(t *testing.T) {
+	ctx := context.Background()
+	// home and work peer with each other, both falling back
+	var homeHandler, workHandler http.Handler
+	homeSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { homeHandler.ServeHTTP(w, r) }))
+	defer homeSrv.Close()
+	workSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { workHandler.ServeHTTP(w, r) }))
+	defer workSrv.Close()
+
+	home, homeStore := newTestServer(t, Config{Peers: peers.New(peers.Config{Peers: []peers.Peer{{Name: "work", URL: workSrv.URL}}, Fallback: true})})
+	work, workStore := newTestServer(t, Config{Peers: peers.New(peers.Config{Peers: []peers.Peer{{Name: "home", URL: homeSrv.URL}}, Fallback: true})})
+	homeHandler, workHandler = home.Handler(), work.Handler()
+	homeStore.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.home.example.com", Status: store.StatusActive})
+	workStore.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.work.example.com", Status: store.StatusActive})
+	workStore.AddLink(ctx, store.Link{Slug: "jira", URL: "https://jira.work.example.com", Status: store.StatusActive, Referrer: store.ReferrerStrip})
+	workStore.AddLink(ctx, store.Link{Slug: "draft", URL: "https://draft.work.example.com", Status: store.StatusPending})
+
+	tests := []struct {
+		slug     string
+		code     int
+		location string
+	}{
+		{"wiki", http.StatusFound, "https://wiki.home.example.com"},
+		{"work:wiki", http.StatusFound, "https://wiki.work.example.com"},
+		{"jira", http.StatusOK, ""},
+		{"draft", http.StatusNotFound, ""},
+		// Known nowhere: each instance asks the other once, not in circles
+		{"nowhere", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := do(t, home, http.MethodGet, "/"+tt.slug, nil, "", "")
+		if rec.Code != tt.code || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s = %d %q, want %d %q", tt.slug, rec.Code, rec.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+	// The peer's referrer policy is kept
+	if rec := do(t, home, http.MethodGet, "/jira", nil, "", ""); rec.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("jira headers = %v", rec.Header())
+	}
+
+	rec := do(t, home, http.MethodGet, "/work:wiki?preview=1", nil, "", "")
+	var preview LinkPreview
+	if err := json.Unmarshal(rec.Body.Bytes(), &preview); err != nil || preview.Peer != "work" || preview.URL != "https://wiki.work.example.com" {
+		t.Errorf("preview = %+v, %v", preview, err)
+	}
+	if link, _ := workStore.GetLink(ctx, "wiki"); link.Clicks != 0 {
+		t.Errorf("work wiki clicks = %d", link.Clicks)
+	}
+}
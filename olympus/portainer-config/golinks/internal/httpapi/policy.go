@@ -0,0 +1,136 @@
+package httpapi
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// canonicalSlug maps the different spellings clients send for one slug onto
+// the stored form: percent-encoded input (pasted from an address bar) is
+// decoded, and emoji variation selectors, which some keyboards and browsers
+// add and others drop ("❤️" vs "❤"), are removed.
+func canonicalSlug(slug string) string {
+	if strings.Contains(slug, "%") {
+		if decoded, err := url.PathUnescape(slug); err == nil {
+			slug = decoded
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '\uFE0E' || r == '\uFE0F' {
+			return -1
+		}
+		return r
+	}, slug)
+}
+
+// isValidSlug reports whether a new slug is reachable as "/<slug>". ServeMux
+// redirects paths with empty, "." or ".." segments to their cleaned form
+// instead of routing them, "admin", "api" and "debug" paths, the sitemap,
+// the health check, the GraphQL endpoint and the chat callbacks are routes
+// of their own (the profiles under "debug/" only with PPROF, but a slug
+// must not disappear when it is turned on), and "/+name" is a collection
+// page.
+func isValidSlug(slug string) bool {
+	if slug == "admin" || strings.HasPrefix(slug, "admin/") || strings.HasPrefix(slug, "api/") || strings.HasPrefix(slug, "debug/") || slug == "sitemap.xml" || slug == "healthz" || slug == "graphql" || slug == "chat/slack" || slug == "chat/approval" || strings.HasPrefix(slug, "+") {
+		return false
+	}
+	for _, segment := range strings.Split(slug, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	// Lookups canonicalize the request path, so only canonical slugs are
+	// reachable ("%2541" would be stored as "%41" but looked up as "A")
+	return strings.IndexFunc(slug, unicode.IsControl) < 0 && canonicalSlug(slug) == slug
+}
+
+func isValidURL(urlStr string) bool {
+	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") {
+		return false
+	}
+	// url.Parse allows control characters in the fragment, and the URL is
+	// sent back verbatim in the Location header
+	if strings.IndexFunc(urlStr, unicode.IsControl) >= 0 {
+		return false
+	}
+
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+
+	return parsedURL.Scheme != "" && parsedURL.Host != ""
+}
+
+// isSensitiveURL reports whether a destination host matches one of the
+// configured sensitive host globs (e.g. "*.paypal.com").
+func (s *Server) isSensitiveURL(urlStr string) bool {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsedURL.Hostname())
+	for _, pattern := range s.cfg.SensitivePatterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Banned-word matching modes.
+const (
+	// BannedWordsToken matches whole slug tokens (split on punctuation), or
+	// runs of consecutive tokens, so "ass" rejects "kick-ass" and "a-s-s" but
+	// not "class".
+	BannedWordsToken = "token"
+	// BannedWordsSubstring matches anywhere in the slug once case and
+	// punctuation are ignored. Stricter, but "ass" also rejects "password".
+	BannedWordsSubstring = "substring"
+)
+
+// containsBannedWord reports whether a slug contains a banned word according
+// to the configured matching mode.
+func (s *Server) containsBannedWord(slug string) bool {
+	if s.cfg.BannedWordsMode == BannedWordsSubstring {
+		normalized := normalizeWord(slug)
+		for _, word := range s.bannedWords {
+			if strings.Contains(normalized, word) {
+				return true
+			}
+		}
+		return false
+	}
+
+	tokens := strings.FieldsFunc(strings.ToLower(slug), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i := range tokens {
+		joined := ""
+		for _, token := range tokens[i:] {
+			joined += token
+			for _, word := range s.bannedWords {
+				if joined == word {
+					return true
+				}
+			}
+			if len(joined) >= s.longestBanned {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// normalizeWord lowercases s and drops everything but letters and digits.
+func normalizeWord(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,21 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// profiles serves the net/http/pprof profiles under /debug/pprof/ to
+// admins. Profiles show the command line and the server's internals, and a
+// CPU profile or trace slows the server while it runs, so nobody else may
+// ask for one.
+func (s *Server) profiles() http.Handler {
+	mux := http.NewServeMux()
+	// Index serves the named profiles, such as heap and goroutine, too
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return s.basicAuth(mux.ServeHTTP)
+}
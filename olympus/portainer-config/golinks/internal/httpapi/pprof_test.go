@@ -0,0 +1,25 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPprof(t *testing.T) {
+	s, _ := newTestServer(t, Config{Admins: map[string]string{"admin": "secret"}})
+	if rec := do(t, s, http.MethodGet, "/debug/pprof/", nil, "admin", "secret"); rec.Code == http.StatusOK {
+		t.Error("profiles served without Pprof")
+	}
+
+	s, _ = newTestServer(t, Config{Admins: map[string]string{"admin": "secret"}, Pprof: true})
+	if rec := do(t, s, http.MethodGet, "/debug/pprof/heap", nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("heap without a login = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/debug/pprof/", nil, "admin", "secret"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("index = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, "/debug/pprof/heap?debug=1", nil, "admin", "secret"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "heap profile") {
+		t.Errorf("heap = %d", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"golinks/internal/store"
+)
+
+// PreviewHeader asks for where a link leads instead of the redirect, like
+// the preview query parameter.
+const PreviewHeader = "X-Golinks-Preview"
+
+// LinkPreview is the answer to a preview request: where the redirect would
+// go, without following it or counting a click.
+type LinkPreview struct {
+	Slug string `json:"slug"`
+	// URL is the redirect target: the destination, or the failover while
+	// the destination is down.
+	URL         string `json:"url"`
+	Destination string `json:"destination"`
+	Failover    bool   `json:"failover,omitempty"`
+	Status      string `json:"status"`
+	// Referrer is the link's referrer policy, if any.
+	Referrer string `json:"referrer,omitempty"`
+	// Peer names the peer instance the link is on, if it is not local.
+	Peer string `json:"peer,omitempty"`
+}
+
+// isPreviewRequest reports whether r asks for a preview with the
+// X-Golinks-Preview header or ?preview=, set to 1 or true. Monitoring
+// probes and security scanners use it to check links without following
+// them.
+func isPreviewRequest(r *http.Request) bool {
+	for _, v := range []string{r.Header.Get(PreviewHeader), r.URL.Query().Get("preview")} {
+		if on, err := strconv.ParseBool(v); err == nil && on {
+			return true
+		}
+	}
+	return false
+}
+
+func writePreview(w http.ResponseWriter, link store.Link, target string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(LinkPreview{
+		Slug:        link.Slug,
+		URL:         target,
+		Destination: link.URL,
+		Failover:    target != link.URL,
+		Status:      link.Status,
+		Referrer:    link.Referrer,
+	})
+}
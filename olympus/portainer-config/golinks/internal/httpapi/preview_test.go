@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestPreviewRequest(t *testing.T) {
+	ctx := context.Background()
+	var usage usageLog
+	var clicks clickLog
+	s, st := newTestServer(t, Config{Usage: &usage, Clicks: &clicks})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", Status: store.StatusActive})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+
+	for _, tt := range []struct {
+		target, header string
+	}{
+		{"/wiki?preview=1", ""},
+		{"/wiki?preview=true", ""},
+		{"/wiki", "1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set(PreviewHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		var preview LinkPreview
+		json.Unmarshal(rec.Body.Bytes(), &preview)
+		if rec.Code != http.StatusOK || rec.Header().Get("Location") != "" || preview != (LinkPreview{Slug: "wiki", URL: "https://wiki.example.com", Destination: "https://wiki.example.com", Status: store.StatusActive}) {
+			t.Errorf("%s with header %q = %d %+v", tt.target, tt.header, rec.Code, preview)
+		}
+	}
+	if len(usage) != 0 || len(clicks) != 0 {
+		t.Errorf("previews counted as use: usage %v, clicks %v", usage, clicks)
+	}
+
+	// Previews answer like the redirect when there is none to follow
+	if rec := do(t, s, http.MethodGet, "/pay?preview=1", nil, "", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("pending preview = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/missing?preview=1", nil, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing preview = %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/wiki?preview=0", nil, "", ""); rec.Code != http.StatusFound {
+		t.Errorf("preview=0 = %d", rec.Code)
+	}
+}
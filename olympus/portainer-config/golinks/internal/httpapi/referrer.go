@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"html/template"
+	"net/http"
+
+	"golinks/internal/store"
+)
+
+// referrerPolicies maps the referrer policy of a link to the
+// Referrer-Policy its redirect page is served with.
+var referrerPolicies = map[string]string{
+	store.ReferrerStrip:   "no-referrer",
+	store.ReferrerReplace: "origin",
+}
+
+// referrerPage redirects to a destination under the page's own referrer
+// policy, which browsers apply to the navigation it starts. A redirect
+// straight to the destination would pass on the Referer of the page the
+// link was opened from.
+var referrerPage = template.Must(template.New("referrer").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><meta name="referrer" content="{{.Policy}}"><meta http-equiv="refresh" content="0; url={{.URL}}"><title>Redirecting…</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 3em auto">
+<p>Redirecting to <a href="{{.URL}}" referrerpolicy="{{.Policy}}">{{.URL}}</a>…</p>
+</body>
+</html>
+`))
+
+// redirectWithReferrer sends the client to target, controlling its Referer
+// by policy, one of the store's Referrer constants, or "" for a plain
+// redirect.
+func redirectWithReferrer(w http.ResponseWriter, target, policy string) {
+	referrer, ok := referrerPolicies[policy]
+	if !ok {
+		redirect(w, target)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", referrer)
+	w.Header().Set("Cache-Control", "no-store")
+	referrerPage.Execute(w, struct{ URL, Policy string }{target, referrer})
+}
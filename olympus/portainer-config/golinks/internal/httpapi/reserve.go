@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+type ReserveLinkRequest struct {
+	Slug string `json:"slug"`
+}
+
+type ClaimLinkRequest struct {
+	Slug string `json:"slug"`
+	URL  string `json:"url"`
+	// TakeOver lets an admin claim a slug another admin reserved.
+	TakeOver bool `json:"take_over,omitempty"`
+}
+
+// ReserveLink holds slug for reservedBy without a destination. Until it is
+// claimed, the slug shows a "coming soon" page.
+func (s *Server) ReserveLink(ctx context.Context, slug, reservedBy string) (store.Link, error) {
+	slug = canonicalSlug(strings.TrimSpace(slug))
+	if err := s.checkNewSlug(slug, reservedBy); err != nil {
+		return store.Link{}, err
+	}
+	link := store.Link{Slug: slug, Status: store.StatusReserved, CreatedBy: reservedBy}
+	if err := s.store.AddLink(ctx, link); err != nil {
+		return store.Link{}, err
+	}
+	return link, nil
+}
+
+func (s *Server) handleAdminReserve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ReserveLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.ReserveLink(r.Context(), req.Slug, s.adminName(r))
+	if err != nil {
+		writeLinkError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Link reserved", "slug", link.Slug, "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "reserved",
+		"slug":   link.Slug,
+	})
+}
+
+// handleAdminClaim gives a reserved slug its destination. Only the admin
+// who reserved it may claim it, unless another admin explicitly takes it
+// over. Sensitive destinations wait for approval as with new links.
+func (s *Server) handleAdminClaim(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ClaimLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Slug = canonicalSlug(strings.TrimSpace(req.Slug))
+	if req.Slug == "" || req.Slug == "admin" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+
+	reserved, err := s.store.GetLink(r.Context(), req.Slug)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if reserved.Status != store.StatusReserved {
+		http.Error(w, "Link is not reserved", http.StatusConflict)
+		return
+	}
+
+	// Without authentication configured there is no way to tell admins
+	// apart, so anyone may claim.
+	claimer := s.adminName(r)
+	if claimer != reserved.CreatedBy && !req.TakeOver {
+		http.Error(w, "Link is reserved by "+reserved.CreatedBy+"; set take_over to claim it anyway", http.StatusForbidden)
+		return
+	}
+
+	link, err := s.destination(req.Slug, req.URL, claimer)
+	if err != nil {
+		writeLinkError(w, r, err)
+		return
+	}
+	if err := s.store.UpdateLink(r.Context(), link); err != nil {
+		slog.ErrorContext(r.Context(), "Error claiming link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	s.saved(link)
+
+	status, code := "claimed", http.StatusOK
+	if link.Status == store.StatusPending {
+		status, code = "pending", http.StatusAccepted
+	}
+	if claimer != reserved.CreatedBy {
+		slog.InfoContext(r.Context(), "Link claimed", "slug", link.Slug, "url", link.URL, "reserved_by", reserved.CreatedBy, "remote_addr", s.remote(r))
+	} else {
+		slog.InfoContext(r.Context(), "Link claimed", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": status,
+		"slug":   link.Slug,
+		"url":    link.URL,
+	})
+}
@@ -0,0 +1,72 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestReserveAndClaim(t *testing.T) {
+	ctx := context.Background()
+	cfg := twoAdmins
+	cfg.SensitivePatterns = []string{"*.bank.example"}
+	s, st := newTestServer(t, cfg)
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	if rec := do(t, s, http.MethodPost, "/admin/reserve", ReserveLinkRequest{Slug: " launch "}, "alice", "pw1"); rec.Code != http.StatusCreated {
+		t.Fatalf("reserve: %d %s", rec.Code, rec.Body)
+	}
+	for slug, want := range map[string]int{"launch": http.StatusConflict, "wiki": http.StatusConflict, "admin": http.StatusBadRequest} {
+		if rec := do(t, s, http.MethodPost, "/admin/reserve", ReserveLinkRequest{Slug: slug}, "bob", "pw2"); rec.Code != want {
+			t.Errorf("reserve %s: %d, want %d", slug, rec.Code, want)
+		}
+	}
+	link, _ := st.GetLink(ctx, "launch")
+	if link.Status != store.StatusReserved || link.URL != "" || link.CreatedBy != "alice" {
+		t.Errorf("reserved link = %+v", link)
+	}
+
+	// Until claimed the slug only shows the coming soon page
+	if rec := do(t, s, http.MethodGet, "/launch", nil, "", ""); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Coming soon") {
+		t.Errorf("redirect: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/update", UpdateLinkRequest{Slug: "launch", URL: "https://launch.example.com"}, "alice", "pw1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("update of reserved link: %d", rec.Code)
+	}
+
+	tests := []struct {
+		name       string
+		req        ClaimLinkRequest
+		user, pass string
+		want       int
+	}{
+		{"not reserved", ClaimLinkRequest{Slug: "wiki", URL: "https://x.example.com"}, "alice", "pw1", http.StatusConflict},
+		{"missing", ClaimLinkRequest{Slug: "nope", URL: "https://x.example.com"}, "alice", "pw1", http.StatusNotFound},
+		{"other admin", ClaimLinkRequest{Slug: "launch", URL: "https://x.example.com"}, "bob", "pw2", http.StatusForbidden},
+		{"bad url", ClaimLinkRequest{Slug: "launch", URL: "launch.example.com"}, "alice", "pw1", http.StatusBadRequest},
+		{"reserver", ClaimLinkRequest{Slug: "launch", URL: "https://launch.example.com"}, "alice", "pw1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := do(t, s, http.MethodPost, "/admin/claim", tt.req, tt.user, tt.pass); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+	if rec := do(t, s, http.MethodGet, "/launch", nil, "", ""); rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://launch.example.com" {
+		t.Errorf("claimed link: %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Another admin can take a reservation over; sensitive destinations
+	// still wait for approval
+	do(t, s, http.MethodPost, "/admin/reserve", ReserveLinkRequest{Slug: "pay"}, "alice", "pw1")
+	if rec := do(t, s, http.MethodPost, "/admin/claim", ClaimLinkRequest{Slug: "pay", URL: "https://www.bank.example", TakeOver: true}, "bob", "pw2"); rec.Code != http.StatusAccepted {
+		t.Errorf("take over: %d %s", rec.Code, rec.Body)
+	}
+	if link, _ := st.GetLink(ctx, "pay"); link.Status != store.StatusPending || link.CreatedBy != "bob" {
+		t.Errorf("taken over link = %+v", link)
+	}
+}
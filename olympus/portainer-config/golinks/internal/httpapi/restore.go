@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"golinks/internal/archive"
+)
+
+// maxRestoreBody bounds an uploaded backup.
+const maxRestoreBody = 256 << 20
+
+// Restore modes: add the backup's links next to the existing ones, or
+// replace everything with them.
+const (
+	RestoreMerge   = "merge"
+	RestoreReplace = "replace"
+)
+
+// RestoreResult is the answer to a restore.
+type RestoreResult struct {
+	Format      string   `json:"format"`
+	Encrypted   bool     `json:"encrypted,omitempty"`
+	Mode        string   `json:"mode"`
+	Links       int      `json:"links"`
+	Collections int      `json:"collections"`
+	Aliases     int      `json:"aliases"`
+	Removed     int      `json:"removed,omitempty"`
+	Skipped     []string `json:"skipped"`
+}
+
+// handleAdminRestore restores the backup uploaded as the "file" field of a
+// multipart form: an archive, a database snapshot, an encrypted scheduled
+// backup or a JSON export. mode=merge (default) skips links and collections
+// that exist; mode=replace removes every link first. An encrypted backup
+// is opened with the "passphrase" field, else with the configured one.
+func (s *Server) handleAdminRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRestoreBody)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Backup too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Upload the backup as the file field of a multipart form", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	defer r.MultipartForm.RemoveAll()
+	mode := r.FormValue("mode")
+	switch mode {
+	case "":
+		mode = RestoreMerge
+	case RestoreMerge, RestoreReplace:
+	default:
+		http.Error(w, "mode must be merge or replace", http.StatusBadRequest)
+		return
+	}
+	passphrase := r.FormValue("passphrase")
+	if passphrase == "" {
+		passphrase = s.cfg.BackupPassphrase
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read the backup", http.StatusBadRequest)
+		return
+	}
+	contents, err := archive.Load(r.Context(), data, passphrase)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := archive.Restore(r.Context(), s.store, contents, mode == RestoreReplace)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error restoring backup", "format", contents.Format, "error", err, "remote_addr", s.remote(r))
+		http.Error(w, "Restore failed part way: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "Backup restored", "format", contents.Format, "mode", mode, "links", res.Links, "collections", res.Collections,
+		"aliases", res.Aliases, "removed", res.Removed, "skipped", len(res.Skipped), "remote_addr", s.remote(r))
+
+	if res.Skipped == nil {
+		res.Skipped = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RestoreResult{
+		Format:      contents.Format,
+		Encrypted:   contents.Encrypted,
+		Mode:        mode,
+		Links:       res.Links,
+		Collections: res.Collections,
+		Aliases:     res.Aliases,
+		Removed:     res.Removed,
+		Skipped:     res.Skipped,
+	})
+}
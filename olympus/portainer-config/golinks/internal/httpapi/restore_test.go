@@ -0,0 +1,72 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+// postRestore uploads backup to /admin/restore with the given form fields.
+func postRestore(t *testing.T, s *Server, backup string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, _ := mw.CreateFormFile("file", "backup.json")
+	fw.Write([]byte(backup))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/admin/restore", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.SetBasicAuth("admin", "pw")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminRestore(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{Admins: map[string]string{"admin": "pw"}})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://old-wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "stale", URL: "https://stale.example.com"})
+	const export = `{"exported_at": "2026-10-16T12:00:00Z", "links": [{"slug": "wiki", "url": "https://wiki.example.com", "status": "active"}, {"slug": "mail", "url": "https://mail.example.com", "status": "active"}], "collections": []}`
+
+	rec := postRestore(t, s, export, nil)
+	var res RestoreResult
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if rec.Code != http.StatusOK || res.Format != "json" || res.Mode != RestoreMerge || res.Links != 1 || len(res.Skipped) != 1 {
+		t.Fatalf("merge: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = postRestore(t, s, export, map[string]string{"mode": "replace"})
+	res = RestoreResult{}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	if rec.Code != http.StatusOK || res.Removed != 3 || res.Links != 2 {
+		t.Fatalf("replace: %d %s", rec.Code, rec.Body)
+	}
+	if n, _ := st.CountLinks(ctx); n != 2 {
+		t.Errorf("%d links after replacing, want 2", n)
+	}
+	if wiki, _ := st.GetLink(ctx, "wiki"); wiki == nil || wiki.URL != "https://wiki.example.com" {
+		t.Errorf("wiki after replacing = %+v", wiki)
+	}
+
+	for name, rec := range map[string]*httptest.ResponseRecorder{
+		"bad mode":   postRestore(t, s, export, map[string]string{"mode": "wipe"}),
+		"not backup": postRestore(t, s, "slug,url\nwiki,https://wiki.example.com\n", nil),
+	} {
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/restore", nil, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without login: %d", rec.Code)
+	}
+}
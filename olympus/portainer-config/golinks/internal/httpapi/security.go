@@ -0,0 +1,331 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// SecurityFinding is a single risky item in the security report.
+type SecurityFinding struct {
+	Check  string `json:"check"`
+	Slug   string `json:"slug,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Detail string `json:"detail"`
+}
+
+type SecurityReport struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	LinkCount   int               `json:"link_count"`
+	Checks      map[string]string `json:"checks"`
+	Findings    []SecurityFinding `json:"findings"`
+}
+
+// defaultCredentials are well-known username/password pairs (including the
+// docker-compose defaults) flagged by the security report.
+var defaultCredentials = map[string]string{
+	"admin": "changeme",
+	"root":  "root",
+}
+
+var defaultPasswords = []string{"admin", "password", "changeme", "secret", "secretpass"}
+
+const (
+	// dnsWorkers bounds concurrent DNS lookups for the private address check.
+	dnsWorkers = 16
+	// dnsLookupTimeout bounds a single lookup; dnsTotalTimeout bounds the
+	// whole check, after which unresolved hosts are treated as public.
+	dnsLookupTimeout = 2 * time.Second
+	dnsTotalTimeout  = 15 * time.Second
+
+	// safeBrowsingBatch is the Safe Browsing v4 limit on threat entries per
+	// threatMatches:find request.
+	safeBrowsingBatch = 500
+)
+
+// safeBrowsingEndpoint is a variable so tests can point it at a fake server.
+var safeBrowsingEndpoint = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+func (s *Server) handleSecurityReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	links, err := s.store.ListLinks(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching links", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	report := SecurityReport{
+		GeneratedAt: time.Now().UTC(),
+		LinkCount:   len(links),
+		Checks:      map[string]string{},
+		Findings:    []SecurityFinding{},
+	}
+
+	// Instance configuration
+	report.Checks["auth"] = "ok"
+	if len(s.cfg.Admins) == 0 {
+		report.Checks["auth"] = "disabled"
+		report.Findings = append(report.Findings, SecurityFinding{
+			Check:  "auth_disabled",
+			Detail: "ADMIN_USER/ADMIN_PASS are not set; admin endpoints are open to anyone",
+		})
+	}
+	report.Checks["default_credentials"] = "ok"
+	for user, pass := range s.cfg.Admins {
+		if isDefaultCredential(user, pass) {
+			report.Checks["default_credentials"] = "found"
+			report.Findings = append(report.Findings, SecurityFinding{
+				Check:  "default_credentials",
+				Detail: fmt.Sprintf("admin %q uses a default or well-known password", user),
+			})
+		}
+	}
+
+	// Per-link checks
+	report.Checks["plain_http"] = "ok"
+	report.Checks["private_ip"] = "ok"
+	private := privateTargets(r.Context(), links)
+	for _, link := range links {
+		if strings.HasPrefix(link.URL, "http://") {
+			report.Checks["plain_http"] = "found"
+			report.Findings = append(report.Findings, SecurityFinding{
+				Check:  "plain_http",
+				Slug:   link.Slug,
+				URL:    link.URL,
+				Detail: "destination is not served over HTTPS",
+			})
+		}
+		if ip := private[link.URL]; ip != "" {
+			report.Checks["private_ip"] = "found"
+			report.Findings = append(report.Findings, SecurityFinding{
+				Check:  "private_ip",
+				Slug:   link.Slug,
+				URL:    link.URL,
+				Detail: "destination resolves to private address " + ip,
+			})
+		}
+	}
+
+	if s.cfg.SafeBrowsingKey == "" {
+		report.Checks["safe_browsing"] = "skipped (SAFE_BROWSING_API_KEY not set)"
+	} else if flagged, err := checkSafeBrowsing(r.Context(), s.cfg.SafeBrowsingKey, links); err != nil {
+		slog.ErrorContext(r.Context(), "Safe Browsing lookup failed", "error", err)
+		report.Checks["safe_browsing"] = "error: lookup failed, see server log"
+	} else {
+		report.Checks["safe_browsing"] = "ok"
+		for _, link := range links {
+			if threat, ok := flagged[link.URL]; ok {
+				report.Checks["safe_browsing"] = "found"
+				report.Findings = append(report.Findings, SecurityFinding{
+					Check:  "safe_browsing",
+					Slug:   link.Slug,
+					URL:    link.URL,
+					Detail: "flagged by Safe Browsing as " + threat,
+				})
+			}
+		}
+	}
+
+	slog.InfoContext(r.Context(), "Security report generated", "findings", len(report.Findings), "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// isDefaultCredential reports whether an admin login is one of the
+// well-known defaults shipped in examples and compose files.
+func isDefaultCredential(user, pass string) bool {
+	if defaultCredentials[user] == pass {
+		return true
+	}
+	for _, p := range defaultPasswords {
+		if pass == p || pass == user {
+			return true
+		}
+	}
+	return false
+}
+
+// privateTargets maps each link destination that points at a private,
+// loopback or link-local address to that address. Hostnames are resolved
+// concurrently by a bounded worker pool under an overall deadline; lookup
+// failures and timeouts are treated as public.
+func privateTargets(ctx context.Context, links []store.Link) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, dnsTotalTimeout)
+	defer cancel()
+
+	urls := make(chan string)
+	var (
+		mu      sync.Mutex
+		private = make(map[string]string)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < dnsWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range urls {
+				if ip := privateTarget(ctx, u); ip != "" {
+					mu.Lock()
+					private[u] = ip
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if seen[link.URL] {
+			continue
+		}
+		seen[link.URL] = true
+		select {
+		case urls <- link.URL:
+		case <-ctx.Done():
+		}
+	}
+	close(urls)
+	wg.Wait()
+	return private
+}
+
+// privateTarget returns the private address a single destination points at,
+// or "" if it is public or could not be resolved.
+func privateTarget(ctx context.Context, urlStr string) string {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+
+	host := parsedURL.Hostname()
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		if ctx.Err() != nil {
+			return ""
+		}
+		ctx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return ""
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	for _, ip := range ips {
+		if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// checkSafeBrowsing looks up all link destinations with the Google Safe
+// Browsing v4 API, in batches of at most safeBrowsingBatch, and returns the
+// flagged URLs mapped to their threat type.
+func checkSafeBrowsing(ctx context.Context, apiKey string, links []store.Link) (map[string]string, error) {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if !seen[link.URL] {
+			seen[link.URL] = true
+			urls = append(urls, link.URL)
+		}
+	}
+
+	flagged := make(map[string]string)
+	for start := 0; start < len(urls); start += safeBrowsingBatch {
+		end := min(start+safeBrowsingBatch, len(urls))
+		if err := findThreats(ctx, apiKey, urls[start:end], flagged); err != nil {
+			return nil, err
+		}
+	}
+	return flagged, nil
+}
+
+type threatEntry struct {
+	URL string `json:"url"`
+}
+
+// findThreats runs one threatMatches:find request and adds matches to
+// flagged. The API key travels in a header so it never appears in request
+// URLs, and therefore never in transport errors or logs.
+func findThreats(ctx context.Context, apiKey string, urls []string, flagged map[string]string) error {
+	entries := make([]threatEntry, 0, len(urls))
+	for _, u := range urls {
+		entries = append(entries, threatEntry{URL: u})
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"client": map[string]string{"clientId": "golinks", "clientVersion": "1.0"},
+		"threatInfo": map[string]any{
+			"threatTypes":      []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"},
+			"platformTypes":    []string{"ANY_PLATFORM"},
+			"threatEntryTypes": []string{"URL"},
+			"threatEntries":    entries,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, safeBrowsingEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Api-Key", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Report the transport failure without the request URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var result struct {
+		Matches []struct {
+			ThreatType string      `json:"threatType"`
+			Threat     threatEntry `json:"threat"`
+		} `json:"matches"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	for _, m := range result.Matches {
+		flagged[m.Threat.URL] = m.ThreatType
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestSecurityReport(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "router", URL: "http://192.168.1.1"})
+	st.AddLink(ctx, store.Link{Slug: "local", URL: "https://127.0.0.1:8443"})
+
+	rec := do(t, s, http.MethodGet, "/admin/security-report", nil, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var report SecurityReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.LinkCount != 2 {
+		t.Errorf("LinkCount = %d, want 2", report.LinkCount)
+	}
+
+	counts := map[string]int{}
+	for _, f := range report.Findings {
+		counts[f.Check]++
+	}
+	want := map[string]int{"auth_disabled": 1, "plain_http": 1, "private_ip": 2}
+	for check, n := range want {
+		if counts[check] != n {
+			t.Errorf("%s findings = %d, want %d", check, counts[check], n)
+		}
+	}
+	if report.Checks["safe_browsing"] == "ok" {
+		t.Error("safe_browsing should be skipped without an API key")
+	}
+}
+
+func TestSecurityReportDefaultCredentials(t *testing.T) {
+	s, _ := newTestServer(t, Config{Admins: map[string]string{"admin": "changeme"}})
+
+	rec := do(t, s, http.MethodGet, "/admin/security-report", nil, "admin", "changeme")
+	var report SecurityReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Checks["default_credentials"] != "found" {
+		t.Errorf("default_credentials = %q, want found", report.Checks["default_credentials"])
+	}
+	if report.Checks["auth"] != "ok" {
+		t.Errorf("auth = %q, want ok", report.Checks["auth"])
+	}
+}
+
+func TestCheckSafeBrowsingBatches(t *testing.T) {
+	var batches []int
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Goog-Api-Key") != "secret-key" || r.URL.Query().Get("key") != "" {
+			t.Errorf("API key not sent in header only: %s %v", r.URL, r.Header)
+		}
+		var req struct {
+			ThreatInfo struct {
+				ThreatEntries []threatEntry `json:"threatEntries"`
+			} `json:"threatInfo"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		batches = append(batches, len(req.ThreatInfo.ThreatEntries))
+
+		var matches []map[string]any
+		for _, e := range req.ThreatInfo.ThreatEntries {
+			if e.URL == "https://example.com/666" {
+				matches = append(matches, map[string]any{"threatType": "MALWARE", "threat": e})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"matches": matches})
+	}))
+	defer fake.Close()
+	defer func(old string) { safeBrowsingEndpoint = old }(safeBrowsingEndpoint)
+	safeBrowsingEndpoint = fake.URL
+
+	var links []store.Link
+	for i := 0; i < 1200; i++ {
+		links = append(links, store.Link{Slug: fmt.Sprint(i), URL: fmt.Sprintf("https://example.com/%d", i)})
+	}
+
+	flagged, err := checkSafeBrowsing(context.Background(), "secret-key", links)
+	if err != nil {
+		t.Fatalf("checkSafeBrowsing: %v", err)
+	}
+	if want := []int{500, 500, 200}; fmt.Sprint(batches) != fmt.Sprint(want) {
+		t.Errorf("batch sizes = %v, want %v", batches, want)
+	}
+	if flagged["https://example.com/666"] != "MALWARE" || len(flagged) != 1 {
+		t.Errorf("flagged = %v", flagged)
+	}
+}
+
+func TestCheckSafeBrowsingErrorHidesKey(t *testing.T) {
+	defer func(old string) { safeBrowsingEndpoint = old }(safeBrowsingEndpoint)
+	safeBrowsingEndpoint = "http://127.0.0.1:1/v4/threatMatches:find"
+
+	_, err := checkSafeBrowsing(context.Background(), "secret-key", []store.Link{{URL: "https://example.com"}})
+	if err == nil {
+		t.Fatal("expected error from unreachable endpoint")
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("error leaks API key: %v", err)
+	}
+}
+
+func TestPrivateTargets(t *testing.T) {
+	links := []store.Link{
+		{URL: "http://192.168.1.1"},
+		{URL: "http://192.168.1.1"},
+		{URL: "https://[::1]:8443"},
+		{URL: "https://8.8.8.8"},
+	}
+	got := privateTargets(context.Background(), links)
+	want := map[string]string{"http://192.168.1.1": "192.168.1.1", "https://[::1]:8443": "::1"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("privateTargets = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,620 @@
+// Package httpapi implements slug redirects and the JSON admin API.
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golinks/internal/accesslog"
+	"golinks/internal/approval"
+	"golinks/internal/banner"
+	"golinks/internal/captcha"
+	"golinks/internal/graphql"
+	"golinks/internal/health"
+	"golinks/internal/httperr"
+	"golinks/internal/jobs"
+	"golinks/internal/logging"
+	"golinks/internal/peers"
+	"golinks/internal/probe"
+	"golinks/internal/ratelimit"
+	"golinks/internal/shadow"
+	"golinks/internal/snapshot"
+	"golinks/internal/store"
+)
+
+// Config holds the admin and link policy settings of the API.
+type Config struct {
+	// Admins maps admin usernames to passwords. Empty disables admin auth.
+	Admins map[string]string
+	// SensitivePatterns are host globs whose links need a second admin's
+	// approval before they resolve.
+	SensitivePatterns []string
+	// BannedWords may not appear in slugs.
+	BannedWords []string
+	// BannedWordsMode is BannedWordsToken (default) or BannedWordsSubstring.
+	BannedWordsMode string
+	// SlugStrategy, if set, generates the slug of links added without one;
+	// see SlugStrategies. Requests may pick a strategy of their own.
+	SlugStrategy string
+	// TypoCorrection redirects an unknown slug to the only active link one
+	// edit away from it instead of answering 404.
+	TypoCorrection bool
+	// SafeBrowsingKey enables Google Safe Browsing checks in the security
+	// report.
+	SafeBrowsingKey string
+	// Usage, if set, is told about every redirect and every unknown slug.
+	Usage UsageRecorder
+	// Lookups, if set, is told how long every slug lookup took.
+	Lookups LookupObserver
+	// LookupBudget, if set, is how long a redirect waits for the store.
+	// After that it is sent with the link the slug last had, possibly
+	// stale, if it was looked up before.
+	LookupBudget time.Duration
+	// Budgets, if set, is told about every redirect of a link with a hit
+	// budget.
+	Budgets BudgetWatcher
+	// Clicks, if set, stores every redirect for the click counts of links.
+	Clicks ClickRecorder
+	// RedirectLog, if set, samples the redirects logged at info level;
+	// without it every redirect is.
+	RedirectLog Sampler
+	// Jobs, if set, runs bulk requests in the background; without it the
+	// bulk endpoints are not served.
+	Jobs *jobs.Queue
+	// Approvals, if set, posts links waiting for approval to chat and
+	// serves the callbacks of its approve and reject actions.
+	Approvals *approval.Notifier
+	// Scheduler, if set, is listed at /api/v1/jobs, where its jobs can be
+	// run on demand.
+	Scheduler *jobs.Scheduler
+	// Snapshots, if set, keeps a copy of the destination of every link
+	// added or updated, served at /admin/snapshots/{slug}.
+	Snapshots *snapshot.Archiver
+	// Banner, if set, is the announcement admins manage at /admin/banner.
+	Banner *banner.Board
+	// Health, if set, sends redirects of links with a failover destination
+	// there while it finds their URL broken.
+	Health *health.Checker
+	// Peers, if set, looks up slugs that are not found locally on other
+	// golinks instances.
+	Peers *peers.Resolver
+	// MirrorOf, if set, is the upstream instance whose links this one
+	// mirrors. Requests that would change links are refused.
+	MirrorOf string
+	// Sync, if set, syncs the links with another instance both ways. The
+	// conflicts it finds are served at /admin/sync/conflicts.
+	Sync SyncReporter
+	// SyncToken, if set, is the secret an instance syncing with this one
+	// sends to POST /admin/sync as a bearer token. Admin logins are not
+	// taken there, and without it the route is not served.
+	SyncToken string
+	// Shadow, if set, is sent a share of the redirects to compare with a
+	// second deployment; its stats are served at /admin/shadow.
+	Shadow *shadow.Shadower
+	// Probe, if set, resolves a canary slug through this server now and
+	// then; /healthz fails while it does not redirect.
+	Probe *probe.Prober
+	// RateLimit, if set, limits the API requests of each admin, or of
+	// each client address without a login; the caller's quota is served at
+	// /api/v1/limits.
+	RateLimit *ratelimit.Limiter
+	// GuestLimit, if set, serves the form at /admin/suggest where visitors
+	// without a login suggest links, which wait for an admin's approval,
+	// and limits the suggestions of each client address.
+	GuestLimit *ratelimit.Limiter
+	// Captcha, if set, must be solved to suggest a link.
+	Captcha *captcha.Verifier
+	// Backups, if set, serves snapshots of the database at /admin/backup.
+	Backups Backuper
+	// BackupPassphrase opens encrypted backups uploaded to /admin/restore
+	// without a passphrase of their own.
+	BackupPassphrase string
+	// APIDocs serves Swagger UI for the OpenAPI description at /api/docs.
+	APIDocs bool
+	// Pprof serves the runtime profiles of net/http/pprof to admins at
+	// /debug/pprof/.
+	Pprof bool
+	// AccessGroups names groups of client networks that link access rules
+	// apply to, e.g. "kids" for the children's VLAN.
+	AccessGroups map[string][]netip.Prefix
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header
+	// is believed when matching clients against AccessGroups.
+	TrustedProxies []netip.Prefix
+	// LinkFields are the custom fields links may have, such as a cost
+	// center or room. Links can be listed by them.
+	LinkFields []LinkField
+	// Chains are the middleware stages each route group is served
+	// through, outermost first; see Groups and Stages. Groups left out get
+	// DefaultChain.
+	Chains map[string][]string
+	// CORSOrigins are the origins StageCORS lets call the routes from the
+	// browser with credentials, or "*" for any origin without them.
+	CORSOrigins []string
+	// AccessLog gets a line for every request, if set, whatever the
+	// chains.
+	AccessLog *accesslog.Logger
+}
+
+// Backuper writes a consistent snapshot of the database, such as
+// *store.SQLite.
+type Backuper interface {
+	Backup(ctx context.Context, w io.Writer) error
+}
+
+// UsageRecorder counts redirects and requests for unknown slugs.
+type UsageRecorder interface {
+	Hit(slug string)
+	Miss(slug string)
+}
+
+// BudgetWatcher counts redirects against the daily hit budget of links.
+type BudgetWatcher interface {
+	Hit(link store.Link)
+}
+
+// ClickRecorder stores redirects, noting those opened from the list page.
+// Click must not block, as it runs before the redirect is sent.
+type ClickRecorder interface {
+	Click(slug string, at time.Time, fromList bool)
+}
+
+// Sampler picks the redirects of hot links worth keeping: Sample returns 0
+// for one left out, else how many redirects it stands for.
+type Sampler interface {
+	Sample(slug string, at time.Time) int
+}
+
+// LookupObserver records slug lookups: how long the store took and the
+// error it returned, nil if the link was found.
+type LookupObserver interface {
+	ObserveLookup(d time.Duration, err error)
+}
+
+// Pages are the HTML pages served alongside the API.
+type Pages struct {
+	// Index renders the link listing served at "/".
+	Index http.Handler
+	// Poster renders the printable QR code sheet at /admin/poster.
+	Poster http.Handler
+	// Sitemap, if set, lists public links at /sitemap.xml.
+	Sitemap http.Handler
+	// Reports serves usage reports at /admin/reports.
+	Reports http.Handler
+	// Stats, if set, serves the usage dashboard at /admin/stats.
+	Stats http.Handler
+	// Status, if set, shows the link health summary at /admin/status
+	// without authentication; StatusDetail lists every link's health at
+	// /admin/status/links for admins.
+	Status       http.Handler
+	StatusDetail http.Handler
+	// Metrics, if set, serves Prometheus metrics at /admin/metrics and
+	// AlertRules suggested alerting rules for them at /admin/metrics/rules,
+	// both without authentication.
+	Metrics    http.Handler
+	AlertRules http.Handler
+	// Collection, if set, renders the page of a collection, served at
+	// "/+name".
+	Collection func(w http.ResponseWriter, r *http.Request, name string)
+	// Preview renders a link preview page for chat unfurlers, served
+	// instead of the redirect when isUnfurler matches the User-Agent.
+	Preview func(w http.ResponseWriter, r *http.Request, link store.Link)
+	// Closed, if set, renders the page served with 403 when an access rule
+	// keeps the client from opening link until opens (zero if never).
+	Closed func(w http.ResponseWriter, r *http.Request, link store.Link, opens time.Time)
+	// NotFound, if set, renders the page served with 404 for unknown
+	// slugs.
+	NotFound http.HandlerFunc
+	// Error, if set, renders the page of a redirect that failed with
+	// status, such as 403 for a link pending approval or 500 when the
+	// store fails, instead of a plain text error.
+	Error func(w http.ResponseWriter, r *http.Request, status int, message string)
+	// Reserved, if set, renders the "coming soon" page of a reserved link,
+	// served with 404 until the link is claimed.
+	Reserved func(w http.ResponseWriter, r *http.Request, link store.Link)
+	// AddForm, if set, renders the quick-add page at /admin/new with the
+	// given status.
+	AddForm func(w http.ResponseWriter, r *http.Request, form AddForm, status int)
+	// Info, if set, renders the info page of a link, served at "/slug+"
+	// instead of the redirect.
+	Info func(w http.ResponseWriter, r *http.Request, link store.Link)
+	// Visited, if set, is called before redirecting to the link slug, to
+	// remember it among the client's recently used links.
+	Visited func(w http.ResponseWriter, r *http.Request, slug string)
+}
+
+// Server routes requests to the redirect handler, the admin API and the
+// HTML pages.
+type Server struct {
+	cfg           Config
+	store         store.Store
+	pages         Pages
+	bannedWords   []string
+	longestBanned int
+	counter       slugCounter
+	graph         *graphql.Schema
+	// impersonationKey signs impersonation cookies
+	impersonationKey []byte
+	// fallback remembers the links redirects fall back on with a
+	// LookupBudget.
+	fallback *fallbackLinks
+}
+
+// New creates a Server.
+func New(cfg Config, st store.Store, pages Pages) *Server {
+	s := &Server{cfg: cfg, store: st, pages: pages, impersonationKey: newImpersonationKey()}
+	s.graph = s.newGraphSchema()
+	if cfg.LookupBudget > 0 {
+		s.fallback = newFallbackLinks()
+	}
+	for _, word := range cfg.BannedWords {
+		if word = normalizeWord(word); word != "" {
+			s.bannedWords = append(s.bannedWords, word)
+			s.longestBanned = max(s.longestBanned, len(word))
+		}
+	}
+	return s
+}
+
+// Handler returns the HTTP handler with all routes registered.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	if s.cfg.Shadow != nil {
+		mux.HandleFunc("/", s.shadowed(s.handleRoot))
+		mux.HandleFunc("/admin/shadow", s.basicAuth(s.handleAdminShadow))
+	} else {
+		mux.HandleFunc("/", s.handleRoot)
+	}
+	mux.HandleFunc("/admin/add", deprecated(s.basicAuth(s.handleAdminAdd)))
+	mux.HandleFunc("/admin/update", deprecated(s.basicAuth(s.handleAdminUpdate)))
+	mux.HandleFunc("/admin/rename", s.basicAuth(s.handleAdminRename))
+	mux.HandleFunc("/admin/transfer", s.basicAuth(s.handleAdminTransfer))
+	mux.HandleFunc("/admin/remove", deprecated(s.basicAuth(s.handleAdminRemove)))
+	mux.HandleFunc("/admin/approve", s.basicAuth(s.handleAdminApprove))
+	mux.HandleFunc("/admin/reserve", s.basicAuth(s.handleAdminReserve))
+	mux.HandleFunc("/admin/claim", s.basicAuth(s.handleAdminClaim))
+	mux.HandleFunc("/admin/import", s.basicAuth(s.handleAdminImport))
+	mux.HandleFunc("/admin/export", s.basicAuth(s.handleAdminExport))
+	mux.HandleFunc("/admin/restore", s.basicAuth(s.handleAdminRestore))
+	mux.HandleFunc("/admin/public", s.basicAuth(s.handleAdminPublic))
+	mux.HandleFunc("/admin/review", s.basicAuth(s.handleAdminReview))
+	mux.HandleFunc("/admin/access", s.basicAuth(s.handleAdminAccess))
+	mux.HandleFunc("/admin/pin", s.basicAuth(s.handleAdminPin))
+	mux.HandleFunc("/admin/budget", s.basicAuth(s.handleAdminBudget))
+	mux.HandleFunc("/admin/failover", s.basicAuth(s.handleAdminFailover))
+	mux.HandleFunc("/admin/referrer", s.basicAuth(s.handleAdminReferrer))
+	mux.HandleFunc("/admin/fields", s.basicAuth(s.handleAdminFields))
+	mux.HandleFunc("/admin/collections", s.basicAuth(s.handleAdminCollections))
+	mux.HandleFunc("/admin/collections/remove", s.basicAuth(s.handleAdminCollectionRemove))
+	mux.HandleFunc("/admin/clicks", s.basicAuth(s.handleAdminClicks))
+	if s.cfg.SyncToken != "" {
+		mux.HandleFunc("/admin/sync", s.peerAuth(s.handleAdminSync))
+	}
+	if s.cfg.Sync != nil {
+		mux.HandleFunc("/admin/sync/conflicts", s.basicAuth(s.handleAdminSyncConflicts))
+	}
+	mux.HandleFunc("/api/links", s.basicAuth(s.handleLinks))
+	mux.HandleFunc("/api/links/", s.basicAuth(s.handleLinkStats))
+	mux.HandleFunc("/api/v1/links", jsonErrors(s.basicAuth(s.handleV1Links)))
+	mux.HandleFunc("/api/v1/links/", jsonErrors(s.basicAuth(s.handleV1Link)))
+	mux.HandleFunc("/graphql", s.basicAuth(s.handleGraphQL))
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)
+	if s.cfg.APIDocs {
+		mux.HandleFunc("/api/docs", s.handleAPIDocs)
+	}
+	if s.cfg.Jobs != nil {
+		mux.HandleFunc("/admin/bulk/add", s.basicAuth(s.handleAdminBulkAdd))
+		mux.HandleFunc("/admin/bulk/remove", s.basicAuth(s.handleAdminBulkRemove))
+	}
+	if s.cfg.RateLimit != nil {
+		mux.HandleFunc("/api/v1/limits", jsonErrors(s.basicAuth(s.handleV1Limits)))
+	}
+	if s.cfg.Jobs != nil || s.cfg.Scheduler != nil {
+		mux.HandleFunc("/api/v1/jobs", jsonErrors(s.basicAuth(s.handleJobs)))
+		mux.HandleFunc("/api/v1/jobs/", jsonErrors(s.basicAuth(s.handleJob)))
+	}
+	if s.cfg.Approvals != nil {
+		// Slack signs its callbacks instead of logging in
+		mux.HandleFunc("/chat/slack", s.handleSlackAction)
+		mux.HandleFunc("/chat/approval", s.basicAuth(s.handleChatApproval))
+	}
+	if s.cfg.Snapshots != nil {
+		mux.HandleFunc("/admin/snapshots/", s.basicAuth(s.handleSnapshot))
+	}
+	if s.cfg.Backups != nil {
+		mux.HandleFunc("/admin/backup", s.basicAuth(s.handleAdminBackup))
+	}
+	if s.cfg.Banner != nil {
+		mux.HandleFunc("/admin/banner", s.basicAuth(s.handleAdminBanner))
+	}
+	mux.HandleFunc("/admin/security-report", s.basicAuth(s.handleSecurityReport))
+	mux.HandleFunc("/admin/impersonate", s.basicAuth(s.handleAdminImpersonate))
+	// Stopping only clears the caller's own cookie, so it needs no login
+	mux.HandleFunc("/admin/impersonate/stop", s.handleAdminImpersonateStop)
+	if s.pages.Sitemap != nil {
+		mux.Handle("/sitemap.xml", s.pages.Sitemap)
+	}
+	if s.pages.Reports != nil {
+		mux.HandleFunc("/admin/reports", s.basicAuth(s.pages.Reports.ServeHTTP))
+	}
+	if s.pages.Stats != nil {
+		mux.HandleFunc("/admin/stats", s.basicAuth(s.pages.Stats.ServeHTTP))
+	}
+	if s.pages.Status != nil {
+		mux.Handle("/admin/status", s.pages.Status)
+	}
+	if s.pages.StatusDetail != nil {
+		mux.HandleFunc("/admin/status/links", s.basicAuth(s.pages.StatusDetail.ServeHTTP))
+	}
+	if s.pages.Metrics != nil {
+		mux.Handle("/admin/metrics", s.pages.Metrics)
+	}
+	if s.pages.AlertRules != nil {
+		mux.Handle("/admin/metrics/rules", s.pages.AlertRules)
+	}
+	if s.pages.AddForm != nil && s.cfg.MirrorOf == "" {
+		mux.HandleFunc("/admin/new", s.basicAuth(s.handleAddForm))
+	}
+	if s.cfg.GuestLimit != nil && s.cfg.MirrorOf == "" {
+		// Guests have no login; the limit and captcha keep them in check
+		mux.HandleFunc("/admin/suggest", s.handleSuggest)
+	}
+	if s.pages.Poster != nil {
+		mux.HandleFunc("/admin/poster", s.basicAuth(s.pages.Poster.ServeHTTP))
+	}
+	if s.cfg.Pprof {
+		mux.Handle("/debug/pprof/", s.profiles())
+	}
+	handler := s.impersonating(mux)
+	if s.cfg.MirrorOf != "" {
+		handler = s.readOnly(handler)
+	}
+	handler = s.grouped(handler)
+	if s.cfg.AccessLog != nil {
+		handler = s.accessLogged(handler)
+	}
+	return handler
+}
+
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	// Root path - list all links
+	if path == "" {
+		s.pages.Index.ServeHTTP(w, r)
+		return
+	}
+
+	if name, ok := strings.CutPrefix(path, "+"); ok && s.pages.Collection != nil {
+		s.pages.Collection(w, r, canonicalSlug(name))
+		return
+	}
+
+	// Slug lookup
+	slug := canonicalSlug(path)
+	start := time.Now()
+	slug, link, stale, err := s.lookupWithinBudget(r.Context(), slug)
+	if s.cfg.Lookups != nil {
+		s.cfg.Lookups.ObserveLookup(time.Since(start), err)
+	}
+	if !stale.IsZero() {
+		w.Header().Set(staleHeader, strconv.Itoa(int(time.Since(stale).Seconds())))
+	}
+	// go/slug+ shows the info page of slug, unless a link is named "slug+"
+	info := false
+	if base, ok := strings.CutSuffix(slug, "+"); ok && base != "" && s.pages.Info != nil && errors.Is(err, store.ErrNotFound) {
+		if infoSlug, infoLink, infoErr := s.lookupLink(r.Context(), base); !errors.Is(infoErr, store.ErrNotFound) {
+			slug, link, err, info = infoSlug, infoLink, infoErr, true
+		}
+	}
+	if errors.Is(err, store.ErrNotFound) && !info && s.cfg.Peers != nil && r.Header.Get(peers.Header) == "" {
+		if s.redirectToPeer(w, r, slug) {
+			return
+		}
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		// The typo still counts as a miss, so reports show which aliases
+		// people keep reaching for
+		if s.cfg.Usage != nil {
+			s.cfg.Usage.Miss(slug)
+		}
+		var corrected *store.Link
+		if s.cfg.TypoCorrection {
+			corrected = s.correctTypo(r.Context(), slug)
+		}
+		if corrected == nil {
+			if logging.Enabled(logging.LevelInfo) {
+				slog.InfoContext(r.Context(), "Slug not found", "status", http.StatusNotFound, "slug", slug, "remote_addr", s.remote(r))
+			}
+			if s.pages.NotFound != nil {
+				s.pages.NotFound(w, r)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+		slog.InfoContext(r.Context(), "Corrected typo", "slug", corrected.Slug, "typo", slug, "remote_addr", s.remote(r))
+		w.Header().Set("X-Golinks-Corrected-From", (&url.URL{Path: slug}).EscapedPath())
+		slug, link, err = corrected.Slug, corrected, nil
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error looking up slug", "slug", slug, "error", err, "remote_addr", s.remote(r))
+		s.writeError(w, r, err)
+		return
+	}
+
+	if link.Status == store.StatusPending {
+		slog.InfoContext(r.Context(), "Slug pending approval", "status", http.StatusForbidden, "slug", slug, "remote_addr", s.remote(r))
+		if s.pages.Error != nil {
+			s.pages.Error(w, r, http.StatusForbidden, "This link is waiting for an admin's approval.")
+			return
+		}
+		http.Error(w, "Link pending approval", http.StatusForbidden)
+		return
+	}
+
+	if link.Status == store.StatusReserved {
+		if logging.Enabled(logging.LevelInfo) {
+			slog.InfoContext(r.Context(), "Slug reserved", "status", http.StatusNotFound, "slug", slug, "remote_addr", s.remote(r))
+		}
+		if s.pages.Reserved != nil {
+			s.pages.Reserved(w, r, *link)
+			return
+		}
+		http.Error(w, "Coming soon: this link is reserved", http.StatusNotFound)
+		return
+	}
+
+	if len(link.Access) > 0 {
+		if allowed, opens := accessAt(*link, s.clientGroups(r), time.Now()); !allowed {
+			slog.InfoContext(r.Context(), "Slug outside its access window", "status", http.StatusForbidden, "slug", slug, "remote_addr", s.remote(r))
+			if s.pages.Closed != nil {
+				s.pages.Closed(w, r, *link, opens)
+				return
+			}
+			http.Error(w, "This link is not available right now", http.StatusForbidden)
+			return
+		}
+	}
+
+	if info {
+		if logging.Enabled(logging.LevelInfo) {
+			slog.InfoContext(r.Context(), "Info page", "status", http.StatusOK, "slug", slug, "remote_addr", s.remote(r))
+		}
+		s.pages.Info(w, r, *link)
+		return
+	}
+
+	target := link.URL
+	if s.cfg.Health != nil && s.cfg.Health.FailoverActive(*link) {
+		target = link.Failover
+	}
+	if isPreviewRequest(r) {
+		if logging.Enabled(logging.LevelInfo) {
+			slog.InfoContext(r.Context(), "Destination", "status", http.StatusOK, "slug", slug, "url", target, "remote_addr", s.remote(r))
+		}
+		writePreview(w, *link, target)
+		return
+	}
+
+	if s.pages.Preview != nil && isUnfurler(r.UserAgent()) {
+		if logging.Enabled(logging.LevelInfo) {
+			slog.InfoContext(r.Context(), "Preview", "status", http.StatusOK, "slug", slug, "user_agent", r.UserAgent(), "remote_addr", s.remote(r))
+		}
+		s.pages.Preview(w, r, *link)
+		return
+	}
+
+	// The uptime probe's own redirects are not anybody's clicks
+	if r.Header.Get(probe.Header) == "" {
+		if s.cfg.Usage != nil {
+			s.cfg.Usage.Hit(slug)
+		}
+		if s.cfg.Budgets != nil && link.HitBudget > 0 {
+			s.cfg.Budgets.Hit(*link)
+		}
+		if s.cfg.Clicks != nil {
+			s.cfg.Clicks.Click(slug, time.Now(), fromListPage(r))
+		}
+	}
+	if logging.Enabled(logging.LevelInfo) {
+		if weight := s.sampleLog(slug); weight == 1 {
+			slog.InfoContext(r.Context(), "Redirect", "status", http.StatusFound, "slug", slug, "url", target, "remote_addr", s.remote(r))
+		} else if weight > 1 {
+			slog.InfoContext(r.Context(), "Redirect", "status", http.StatusFound, "slug", slug, "url", target, "remote_addr", s.remote(r), "sample_rate", weight)
+		}
+	}
+	if s.pages.Visited != nil {
+		s.pages.Visited(w, r, slug)
+	}
+	redirectWithReferrer(w, target, link.Referrer)
+}
+
+// sampleLog returns how many redirects of slug the one being logged stands
+// for, 0 to leave it out.
+func (s *Server) sampleLog(slug string) int {
+	if s.cfg.RedirectLog == nil {
+		return 1
+	}
+	return s.cfg.RedirectLog.Sample(slug, time.Now())
+}
+
+// fromListPage reports whether r follows a link on the list page of this
+// server, going by its Referer. Browsers send the full URL of same-origin
+// pages by default; a stricter policy makes list opens look typed.
+func fromListPage(r *http.Request) bool {
+	ref, err := url.Parse(r.Referer())
+	return err == nil && ref.Host == r.Host && (ref.Path == "/" || ref.Path == "")
+}
+
+// unfurlers are User-Agent substrings of the link preview bots of chat apps
+// and social networks.
+var unfurlers = []string{
+	"Slackbot",
+	"Discordbot",
+	"Twitterbot",
+	"facebookexternalhit",
+	"LinkedInBot",
+	"TelegramBot",
+	"WhatsApp",
+	"Mattermost",
+	"Iframely",
+	"redditbot",
+	"SkypeUriPreview",
+	"MicrosoftPreview",
+	"Mastodon",
+}
+
+// isUnfurler reports whether a request comes from a chat link preview bot.
+func isUnfurler(userAgent string) bool {
+	if userAgent == "" {
+		return false
+	}
+	for _, bot := range unfurlers {
+		if strings.Contains(userAgent, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError answers a redirect whose lookup failed with err, on the error
+// page if there is one.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if s.pages.Error == nil {
+		httperr.Write(w, err)
+		return
+	}
+	code, msg := httperr.Status(err)
+	s.pages.Error(w, r, code, msg)
+}
+
+// lookupLink returns the link at slug or, if slug is the old slug of a
+// renamed link, the link it moved to, together with the slug it is at.
+func (s *Server) lookupLink(ctx context.Context, slug string) (string, *store.Link, error) {
+	link, err := s.store.GetLink(ctx, slug)
+	if !errors.Is(err, store.ErrNotFound) {
+		return slug, link, err
+	}
+	target, err := s.store.ResolveAlias(ctx, slug)
+	if err != nil {
+		return slug, nil, err
+	}
+	link, err = s.store.GetLink(ctx, target)
+	return target, link, err
+}
+
+// redirect sends a bare 302. Stored URLs are already absolute, so unlike
+// http.Redirect there is no URL resolution and no HTML body to render.
+func redirect(w http.ResponseWriter, target string) {
+	w.Header()["Location"] = []string{target}
+	w.WriteHeader(http.StatusFound)
+}
@@ -0,0 +1,414 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golinks/internal/health"
+	"golinks/internal/store"
+)
+
+// newTestServer returns a Server backed by an in-memory store whose index
+// page simply writes "index".
+func newTestServer(t *testing.T, cfg Config) (*Server, *store.Memory) {
+	t.Helper()
+	st := store.NewMemory()
+	index := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("index"))
+	})
+	return New(cfg, st, Pages{Index: index}), st
+}
+
+// do sends a request through the server's full handler. A non-nil body is
+// encoded as JSON.
+func do(t *testing.T, s *Server, method, target string, body any, user, pass string) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, payload)
+	if user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRedirect(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	rec := do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "https://wiki.example.com" {
+		t.Errorf("Location = %q", loc)
+	}
+}
+
+func TestRedirectVisited(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	var visited []string
+	s := New(Config{}, st, Pages{Visited: func(w http.ResponseWriter, r *http.Request, slug string) {
+		visited = append(visited, slug)
+	}})
+
+	do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	do(t, s, http.MethodGet, "/missing", nil, "", "")
+	if len(visited) != 1 || visited[0] != "wiki" {
+		t.Errorf("visited = %v, want [wiki]", visited)
+	}
+}
+
+func TestRedirectFailover(t *testing.T) {
+	ctx := context.Background()
+	var down atomic.Bool
+	cloud := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+		}
+	}))
+	defer cloud.Close()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "nas", URL: cloud.URL, Failover: "http://nas.lan"})
+	checker := health.NewChecker(st, 0)
+	s := New(Config{Health: checker}, st, Pages{})
+
+	location := func() string {
+		t.Helper()
+		rec := do(t, s, http.MethodGet, "/nas", nil, "", "")
+		if rec.Code != http.StatusFound {
+			t.Fatalf("status = %d, want 302", rec.Code)
+		}
+		return rec.Header().Get("Location")
+	}
+	if got := location(); got != cloud.URL {
+		t.Errorf("before any check: Location = %q, want %q", got, cloud.URL)
+	}
+	down.Store(true)
+	checker.Check(ctx)
+	if got := location(); got != "http://nas.lan" {
+		t.Errorf("while broken: Location = %q, want the failover", got)
+	}
+	down.Store(false)
+	checker.Check(ctx)
+	if got := location(); got != cloud.URL {
+		t.Errorf("after recovery: Location = %q, want %q", got, cloud.URL)
+	}
+}
+
+func TestRedirectInfo(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "c++", URL: "https://isocpp.org"})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+	st.AddLink(ctx, store.Link{Slug: "docs", URL: "https://docs.example.com"})
+	st.RenameLink(ctx, "docs", "handbook", true)
+	var info string
+	s := New(Config{}, st, Pages{Info: func(w http.ResponseWriter, r *http.Request, link store.Link) {
+		info = link.Slug
+	}})
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantInfo string
+	}{
+		{"/wiki+", http.StatusOK, "wiki"},
+		// A link named with a trailing + still redirects
+		{"/c++", http.StatusFound, ""},
+		{"/c+++", http.StatusOK, "c++"},
+		{"/docs+", http.StatusOK, "handbook"},
+		{"/pay+", http.StatusForbidden, ""},
+		{"/missing+", http.StatusNotFound, ""},
+		{"/+", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		info = ""
+		rec := do(t, s, http.MethodGet, tt.path, nil, "", "")
+		if rec.Code != tt.wantCode || info != tt.wantInfo {
+			t.Errorf("%s: status %d, info %q; want %d, %q", tt.path, rec.Code, info, tt.wantCode, tt.wantInfo)
+		}
+	}
+}
+
+func TestRedirectEmoji(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	for _, slug := range []string{"🍕", "❤\uFE0F", "%F0%9F%8E%82"} {
+		if rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: slug, URL: "https://example.com/" + slug}, "", ""); rec.Code != http.StatusCreated {
+			t.Fatalf("add %q: status = %d", slug, rec.Code)
+		}
+	}
+
+	tests := map[string]string{
+		"/%F0%9F%8D%95":       "https://example.com/🍕",
+		"/%f0%9f%8d%95":       "https://example.com/🍕",
+		"/🍕":                  "https://example.com/🍕",
+		"/%E2%9D%A4":          "https://example.com/❤\uFE0F",
+		"/%E2%9D%A4%EF%B8%8F": "https://example.com/❤\uFE0F",
+		"/🎂":                  "https://example.com/%F0%9F%8E%82",
+	}
+	for target, want := range tests {
+		rec := do(t, s, http.MethodGet, target, nil, "", "")
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+			t.Errorf("GET %s = %d (Location %q), want 302 to %s", target, rec.Code, rec.Header().Get("Location"), want)
+		}
+	}
+}
+
+func TestRedirectNotFound(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := do(t, s, http.MethodGet, "/missing", nil, "", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestRedirectPending(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+
+	rec := do(t, s, http.MethodGet, "/pay", nil, "", "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := do(t, s, http.MethodGet, "/", nil, "", "")
+	if rec.Body.String() != "index" {
+		t.Errorf("body = %q, want index page", rec.Body.String())
+	}
+}
+
+// timeoutStore simulates a store whose queries exceed their deadline.
+type timeoutStore struct {
+	*store.Memory
+}
+
+func (timeoutStore) GetLink(ctx context.Context, slug string) (*store.Link, error) {
+	return nil, fmt.Errorf("query: %w", context.DeadlineExceeded)
+}
+
+func TestRedirectTimeout(t *testing.T) {
+	s := New(Config{}, timeoutStore{store.NewMemory()}, Pages{})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wiki", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestRedirectErrorPage(t *testing.T) {
+	errorPage := func(w http.ResponseWriter, r *http.Request, status int, message string) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "error page: %s", message)
+	}
+	s := New(Config{}, timeoutStore{store.NewMemory()}, Pages{Error: errorPage})
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wiki", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "error page: Service unavailable" {
+		t.Errorf("store timeout: %d %q", rec.Code, rec.Body)
+	}
+
+	st := store.NewMemory()
+	st.AddLink(context.Background(), store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+	s = New(Config{}, st, Pages{Error: errorPage})
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pay", nil))
+	if rec.Code != http.StatusForbidden || !strings.HasPrefix(rec.Body.String(), "error page: ") {
+		t.Errorf("pending link: %d %q", rec.Code, rec.Body)
+	}
+}
+
+func FuzzAddAndRedirect(f *testing.F) {
+	for _, seed := range []string{"wiki", "team/wiki", " padded ", "a//b", "../etc", "wiki/", "%41", "é", "a?b#c", "admin/add", "🍕", "❤\uFE0F", "%2541"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, slug string) {
+		s, st := newTestServer(t, Config{})
+		const dest = "https://example.com/"
+
+		rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: slug, URL: dest}, "", "")
+		if rec.Code != http.StatusCreated {
+			return
+		}
+
+		// Every slug the API accepts must be reachable as /<slug>
+		var created map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &created)
+		stored, err := st.GetLink(context.Background(), created["slug"])
+		if err != nil {
+			t.Fatalf("slug %q: created as %q but not stored: %v", slug, created["slug"], err)
+		}
+		target := (&url.URL{Path: "/" + stored.Slug}).EscapedPath()
+		rec = do(t, s, http.MethodGet, target, nil, "", "")
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != dest {
+			t.Fatalf("slug %q: GET %s = %d (Location %q), want 302 to %s", slug, target, rec.Code, rec.Header().Get("Location"), dest)
+		}
+	})
+}
+
+func TestRedirectUnfurler(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+	s := New(Config{}, st, Pages{
+		Preview: func(w http.ResponseWriter, r *http.Request, link store.Link) {
+			fmt.Fprintf(w, "preview %s", link.Slug)
+		},
+	})
+
+	tests := []struct {
+		slug, userAgent string
+		wantCode        int
+		wantBody        string
+	}{
+		{"wiki", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", http.StatusOK, "preview wiki"},
+		{"wiki", "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", http.StatusOK, "preview wiki"},
+		{"wiki", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0", http.StatusFound, ""},
+		{"wiki", "", http.StatusFound, ""},
+		{"pay", "Slackbot-LinkExpanding 1.0", http.StatusForbidden, "Link pending approval\n"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/"+tt.slug, nil)
+		req.Header.Set("User-Agent", tt.userAgent)
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != tt.wantCode || rec.Body.String() != tt.wantBody {
+			t.Errorf("%s as %q: got %d %q, want %d %q", tt.slug, tt.userAgent, rec.Code, rec.Body.String(), tt.wantCode, tt.wantBody)
+		}
+	}
+}
+
+// usageLog records UsageRecorder calls.
+type usageLog []string
+
+func (u *usageLog) Hit(slug string)  { *u = append(*u, "hit "+slug) }
+func (u *usageLog) Miss(slug string) { *u = append(*u, "miss "+slug) }
+
+// clickLog records ClickRecorder calls.
+type clickLog []string
+
+func (c *clickLog) Click(slug string, at time.Time, fromList bool) {
+	if fromList {
+		slug += " (list)"
+	}
+	*c = append(*c, slug)
+}
+
+func TestRedirectRecordsUsage(t *testing.T) {
+	ctx := context.Background()
+	var usage usageLog
+	var clicks clickLog
+	s, st := newTestServer(t, Config{Usage: &usage, Clicks: &clicks})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+
+	for _, target := range []string{"/wiki", "/missing", "/pay", "/wiki"} {
+		do(t, s, http.MethodGet, target, nil, "", "")
+	}
+	if got := strings.Join(usage, ", "); got != "hit wiki, miss missing, hit wiki" {
+		t.Errorf("usage = %s", got)
+	}
+	if got := strings.Join(clicks, ", "); got != "wiki, wiki" {
+		t.Errorf("clicks = %s", got)
+	}
+}
+
+func TestRedirectFromListPage(t *testing.T) {
+	var clicks clickLog
+	s, st := newTestServer(t, Config{Clicks: &clicks})
+	st.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	for _, referer := range []string{
+		"http://example.com/",
+		"http://example.com/?order=clicks",
+		"",
+		"http://example.com/+onboarding",
+		"https://intranet.example.org/",
+		"http://example.com",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/wiki", nil)
+		req.Header.Set("Referer", referer)
+		s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if got := strings.Join(clicks, ", "); got != "wiki (list), wiki (list), wiki, wiki, wiki, wiki (list)" {
+		t.Errorf("clicks = %s", got)
+	}
+}
+
+// lookupLog records LookupObserver calls.
+type lookupLog []error
+
+func (l *lookupLog) ObserveLookup(d time.Duration, err error) { *l = append(*l, err) }
+
+func TestRedirectObservesLookups(t *testing.T) {
+	ctx := context.Background()
+	var lookups lookupLog
+	s, st := newTestServer(t, Config{Lookups: &lookups})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	for _, target := range []string{"/wiki", "/missing", "/"} {
+		do(t, s, http.MethodGet, target, nil, "", "")
+	}
+	if len(lookups) != 2 || lookups[0] != nil || !errors.Is(lookups[1], store.ErrNotFound) {
+		t.Errorf("lookups = %v, want [nil, ErrNotFound]", lookups)
+	}
+}
+
+// everyOther keeps every other redirect, each standing for two.
+type everyOther struct{ n int }
+
+func (e *everyOther) Sample(slug string, at time.Time) int {
+	e.n++
+	return 2 * (e.n % 2)
+}
+
+func TestRedirectLogSampling(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	s, st := newTestServer(t, Config{RedirectLog: &everyOther{}})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	for i := 0; i < 4; i++ {
+		if rec := do(t, s, http.MethodGet, "/wiki", nil, "", ""); rec.Code != http.StatusFound {
+			t.Fatalf("redirect = %d", rec.Code)
+		}
+	}
+	if n := strings.Count(buf.String(), "Redirect status=302 slug=wiki"); n != 2 || !strings.Contains(buf.String(), "sample_rate=2") {
+		t.Errorf("%d redirects logged, want 2:\n%s", n, buf.String())
+	}
+}
@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// shadowed passes the redirects, lookups of unknown slugs and other pages
+// next serves to the shadow of cfg.Shadow, with the status and Location it
+// answered them with. The list page is not a lookup and is left out.
+func (s *Server) shadowed(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path == "/" {
+			next(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next(sw, r)
+		s.cfg.Shadow.Observe(r, sw.status, w.Header().Get("Location"))
+	}
+}
+
+// statusWriter notes the status a handler answered with, and the size of
+// the body.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wrote {
+		w.status, w.wrote = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// handleAdminShadow reports how the shadow's answers compare.
+func (s *Server) handleAdminShadow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.cfg.Shadow.Stats())
+}
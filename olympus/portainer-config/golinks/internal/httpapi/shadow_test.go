@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golinks/internal/shadow"
+	"golinks/internal/store"
+)
+
+func TestShadowRedirects(t *testing.T) {
+	paths := make(chan string, 10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		http.NotFound(w, r)
+	}))
+	defer target.Close()
+	s, st := newTestServer(t, Config{Shadow: shadow.New(shadow.Config{Target: target.URL, Percent: 100})})
+	st.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	if rec := do(t, s, http.MethodGet, "/wiki", nil, "", ""); rec.Code != http.StatusFound {
+		t.Fatalf("GET /wiki = %d", rec.Code)
+	}
+	do(t, s, http.MethodGet, "/", nil, "", "")
+	select {
+	case path := <-paths:
+		if path != "/wiki" {
+			t.Errorf("shadow got %s", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("redirect not sent to the shadow")
+	}
+
+	// The shadow answered 404 where this instance redirected
+	var stats shadow.Stats
+	for deadline := time.Now().Add(5 * time.Second); stats.Mismatched == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		rec := do(t, s, http.MethodGet, "/admin/shadow", nil, "", "")
+		json.Unmarshal(rec.Body.Bytes(), &stats)
+	}
+	if stats.Mismatched != 1 || stats.Mismatches[0].Status != http.StatusFound || stats.Mismatches[0].ShadowStatus != http.StatusNotFound {
+		t.Errorf("stats = %+v", stats)
+	}
+	// The list page is no lookup
+	if len(paths) != 0 {
+		t.Errorf("list page sent to the shadow: %s", <-paths)
+	}
+}
@@ -0,0 +1,236 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golinks/internal/store"
+)
+
+// Strategies for generating the slug of a link added without one.
+const (
+	// SlugRandom is 6 random letters and digits, e.g. "q3ZtK9".
+	SlugRandom = "random"
+	// SlugWords is an adjective and a noun, e.g. "amber-falcon".
+	SlugWords = "words"
+	// SlugHashid is a short, non-sequential code of a counter, e.g. "5bT0xQ".
+	SlugHashid = "hashid"
+	// SlugTitle is the title of the link in kebab-case, e.g.
+	// "quarterly-planning". Without a title, the last part of the URL's path
+	// or its host is used.
+	SlugTitle = "title"
+)
+
+// SlugStrategies lists the valid slug strategies.
+var SlugStrategies = []string{SlugRandom, SlugWords, SlugHashid, SlugTitle}
+
+// maxSlugAttempts bounds the slugs tried for one link before giving up.
+const maxSlugAttempts = 10
+
+// maxTitleSlug bounds the length of a title-derived slug.
+const maxTitleSlug = 40
+
+const base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// hashidAlphabet is base62 shuffled, so consecutive codes don't look
+// alike.
+const hashidAlphabet = "cKaVPRug0job8tZSlEmMivsHLXGCh1DxWOe7ANIzJfTqr52dwUBn6yQ43Fp9Yk"
+
+var slugAdjectives = []string{
+	"amber", "ancient", "autumn", "bold", "brave", "bright", "calm", "clever",
+	"cosmic", "crimson", "crisp", "dapper", "eager", "early", "fancy", "gentle",
+	"golden", "happy", "hidden", "humble", "icy", "jolly", "keen", "kind",
+	"lively", "lucky", "mellow", "misty", "nimble", "noble", "patient", "plucky",
+	"proud", "quiet", "rapid", "rosy", "rustic", "silent", "silver", "snowy",
+	"spry", "steady", "sunny", "swift", "tidy", "vivid", "wandering", "witty",
+}
+
+var slugNouns = []string{
+	"badger", "beacon", "breeze", "brook", "canyon", "cedar", "comet", "coral",
+	"crane", "falcon", "fern", "finch", "fjord", "fox", "glacier", "harbor",
+	"heron", "island", "lantern", "lark", "maple", "meadow", "meteor", "moose",
+	"otter", "owl", "panda", "pebble", "pine", "quartz", "raven", "reef",
+	"ridge", "river", "robin", "sparrow", "spruce", "summit", "thistle", "tiger",
+	"tulip", "valley", "walrus", "willow", "wren", "yak", "zebra", "zephyr",
+}
+
+// slugCounter numbers the links given a SlugHashid slug. It starts from
+// the number of links, so codes rarely repeat after a restart; a repeat
+// is a conflict and simply moves on to the next number.
+type slugCounter struct {
+	once sync.Once
+	mu   sync.Mutex
+	next uint32
+}
+
+func (c *slugCounter) take(ctx context.Context, st store.Store) uint32 {
+	c.once.Do(func() {
+		st.EachLink(ctx, func(store.Link) error {
+			c.next++
+			return nil
+		})
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next++
+	return c.next
+}
+
+// slugGenerator returns the candidate slugs of strategy for req, one per
+// attempt, or an *InvalidError for an unknown strategy.
+func (s *Server) slugGenerator(ctx context.Context, strategy string, req AddLinkRequest) (func(attempt int) string, error) {
+	switch strategy {
+	case SlugRandom:
+		return func(attempt int) string {
+			// Longer codes once the short ones keep colliding
+			return randomString(base62, 6+attempt/3)
+		}, nil
+	case SlugWords:
+		return func(attempt int) string {
+			slug := pick(slugAdjectives) + "-" + pick(slugNouns)
+			if attempt >= maxSlugAttempts/2 {
+				slug += "-" + randomString("0123456789", 2)
+			}
+			return slug
+		}, nil
+	case SlugHashid:
+		return func(int) string {
+			return hashid(s.counter.take(ctx, s.store))
+		}, nil
+	case SlugTitle:
+		base := kebab(req.Title)
+		if base == "" {
+			base = kebab(urlName(req.URL))
+		}
+		if base == "" {
+			return nil, &InvalidError{Msg: "Cannot derive a slug from the title or URL", Field: "slug"}
+		}
+		return func(attempt int) string {
+			if attempt == 0 {
+				return base
+			}
+			return fmt.Sprintf("%s-%d", base, attempt+1)
+		}, nil
+	}
+	return nil, &InvalidError{Msg: fmt.Sprintf("Unknown slug strategy %q, want one of %s", strategy, strings.Join(SlugStrategies, ", ")), Field: "slug_strategy"}
+}
+
+// addGenerated stores req under a slug generated by strategy, trying the
+// next candidate while slugs are taken, reserved or contain a banned word.
+func (s *Server) addGenerated(ctx context.Context, req AddLinkRequest, strategy, createdBy string) (store.Link, error) {
+	next, err := s.slugGenerator(ctx, strategy, req)
+	if err != nil {
+		return store.Link{}, err
+	}
+	link, err := s.destination("", req.URL, createdBy)
+	if err != nil {
+		return store.Link{}, err
+	}
+	aliases, err := s.newLinkSettings(ctx, req, &link)
+	if err != nil {
+		return store.Link{}, err
+	}
+	banned := 0
+	for attempt := 0; attempt < maxSlugAttempts; attempt++ {
+		link.Slug = next(attempt)
+		if !isValidSlug(link.Slug) {
+			continue
+		}
+		if s.containsBannedWord(link.Slug) {
+			banned++
+			continue
+		}
+		// The aliases were checked to be free, so a conflict is the slug
+		err := s.createLink(ctx, link, aliases, req.Collections)
+		if err == nil {
+			return link, nil
+		}
+		if !errors.Is(err, store.ErrConflict) {
+			return store.Link{}, err
+		}
+	}
+	if banned == maxSlugAttempts {
+		return store.Link{}, &InvalidError{Msg: "Slug contains a banned word", Field: "slug"}
+	}
+	return store.Link{}, fmt.Errorf("no free %s slug after %d attempts: %w", strategy, maxSlugAttempts, store.ErrConflict)
+}
+
+// randomString returns n characters of alphabet chosen at random.
+func randomString(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[randomInt(len(alphabet))]
+	}
+	return string(b)
+}
+
+func pick(words []string) string {
+	return words[randomInt(len(words))]
+}
+
+func randomInt(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return int(i.Int64())
+}
+
+// hashid encodes n as a short code. Multiplying by an odd constant is a
+// bijection on uint32, so distinct counters give distinct codes, but
+// neighbouring counters give unrelated ones.
+func hashid(n uint32) string {
+	x := n * 2654435761
+	var b []byte
+	for {
+		b = append(b, hashidAlphabet[x%62])
+		if x /= 62; x == 0 {
+			break
+		}
+	}
+	return string(b)
+}
+
+// kebab lowercases s and joins its runs of ASCII letters and digits with
+// "-", cut at a word boundary after maxTitleSlug characters.
+func kebab(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	slug := ""
+	for _, w := range words {
+		if slug != "" && len(slug)+1+len(w) > maxTitleSlug {
+			break
+		}
+		if slug != "" {
+			slug += "-"
+		}
+		slug += w
+	}
+	if len(slug) > maxTitleSlug {
+		slug = slug[:maxTitleSlug]
+	}
+	return slug
+}
+
+// urlName names a URL without a title: the last part of its path without
+// the extension, else its host without "www.".
+func urlName(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	if base := path.Base(u.Path); base != "/" && base != "." {
+		return strings.TrimSuffix(base, path.Ext(base))
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
@@ -0,0 +1,91 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestKebab(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Quarterly Planning", "quarterly-planning"},
+		{"  Q3 OKRs: draft (v2)!  ", "q3-okrs-draft-v2"},
+		{"Café menu", "caf-menu"},
+		{"🍕", ""},
+		{"a very long title that goes on and on past the limit of slugs", "a-very-long-title-that-goes-on-and-on"},
+	}
+	for _, tt := range tests {
+		if got := kebab(tt.in); got != tt.want {
+			t.Errorf("kebab(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHashidDistinct(t *testing.T) {
+	seen := map[string]uint32{}
+	for n := uint32(1); n <= 10000; n++ {
+		code := hashid(n)
+		if prev, ok := seen[code]; ok {
+			t.Fatalf("hashid(%d) = hashid(%d) = %q", n, prev, code)
+		}
+		seen[code] = n
+	}
+}
+
+func TestAdminAddGeneratedSlug(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{SlugStrategy: SlugWords})
+	st.AddLink(ctx, store.Link{Slug: "team-wiki", URL: "https://taken.example.com"})
+
+	tests := []struct {
+		req  AddLinkRequest
+		want string
+	}{
+		{AddLinkRequest{URL: "https://a.example.com"}, `^[a-z]+-[a-z]+(-[0-9]{2})?$`},
+		{AddLinkRequest{URL: "https://b.example.com", SlugStrategy: SlugRandom}, `^[0-9a-zA-Z]{6}$`},
+		{AddLinkRequest{URL: "https://c.example.com", SlugStrategy: SlugHashid}, `^[0-9a-zA-Z]{1,6}$`},
+		{AddLinkRequest{URL: "https://d.example.com", SlugStrategy: SlugTitle, Title: "Team Wiki"}, `^team-wiki-2$`},
+		{AddLinkRequest{URL: "https://e.example.com/docs/onboarding.pdf", SlugStrategy: SlugTitle}, `^onboarding$`},
+	}
+	for _, tt := range tests {
+		rec := do(t, s, http.MethodPost, "/admin/add", tt.req, "", "")
+		var resp map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusCreated || !regexp.MustCompile(tt.want).MatchString(resp["slug"]) {
+			t.Errorf("%+v: %d %q, want slug matching %s", tt.req, rec.Code, resp["slug"], tt.want)
+			continue
+		}
+		if link, err := st.GetLink(ctx, resp["slug"]); err != nil || link.URL != tt.req.URL {
+			t.Errorf("%s not stored: %+v, %v", resp["slug"], link, err)
+		}
+	}
+
+	if rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{URL: "https://f.example.com", SlugStrategy: "uuid"}, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown strategy: status = %d, want 400", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{URL: "ftp://g.example.com"}, "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad URL: status = %d, want 400", rec.Code)
+	}
+
+	// Every candidate taken
+	st.AddLink(ctx, store.Link{Slug: "full", URL: "https://taken.example.com"})
+	for i := 2; i <= maxSlugAttempts; i++ {
+		st.AddLink(ctx, store.Link{Slug: fmt.Sprintf("full-%d", i), URL: "https://taken.example.com"})
+	}
+	if rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{URL: "https://h.example.com", SlugStrategy: SlugTitle, Title: "Full"}, "", ""); rec.Code != http.StatusConflict {
+		t.Errorf("all slugs taken: status = %d, want 409", rec.Code)
+	}
+}
+
+func TestAdminAddGeneratedBannedWord(t *testing.T) {
+	s, _ := newTestServer(t, Config{SlugStrategy: SlugTitle, BannedWords: []string{"secret"}})
+	rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{URL: "https://a.example.com", Title: "Secret plans"}, "", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golinks/internal/httperr"
+	"golinks/internal/snapshot"
+	"golinks/internal/store"
+)
+
+// handleSnapshot serves /admin/snapshots/{slug}: GET returns the snapshot
+// of the link's destination as it was taken, POST takes a new one now.
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	slug := canonicalSlug(strings.TrimPrefix(r.URL.Path, "/admin/snapshots/"))
+	if slug == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	link, err := s.store.GetLink(r.Context(), slug)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Error fetching link", "error", err)
+		}
+		httperr.Write(w, err)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		snap, err := s.cfg.Snapshots.Capture(r.Context(), link.URL)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Snapshot failed", "slug", slug, "url", link.URL, "error", err)
+			http.Error(w, "Snapshot failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		slog.InfoContext(r.Context(), "Snapshot taken", "slug", slug, "url", link.URL, "remote_addr", s.remote(r))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snap)
+		return
+	}
+
+	snap, f, err := s.cfg.Snapshots.Open(link.URL)
+	if errors.Is(err, snapshot.ErrNotFound) {
+		http.Error(w, "No snapshot of this link", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error opening snapshot", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	defer f.Close()
+	// The page was copied from another site: it must not run scripts or
+	// reach cookies on this origin
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", snap.ContentType)
+	http.ServeContent(w, r, "", snap.TakenAt, f)
+}
@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golinks/internal/snapshot"
+)
+
+func TestSnapshots(t *testing.T) {
+	page := "<h1>Wiki</h1>"
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, page)
+	}))
+	defer site.Close()
+	archiver, err := snapshot.New(snapshot.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, Config{Snapshots: archiver})
+
+	if rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: "wiki", URL: site.URL}, "", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("add: %d %s", rec.Code, rec.Body)
+	}
+	// Adding a link snapshots its destination in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := archiver.Get(site.URL); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot taken after adding a link")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	page = "<h1>Wiki, edited</h1>"
+	rec := do(t, s, http.MethodGet, "/admin/snapshots/wiki", nil, "", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "<h1>Wiki</h1>" {
+		t.Fatalf("GET: %d %q", rec.Code, rec.Body)
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); csp != "sandbox" {
+		t.Errorf("Content-Security-Policy = %q, want sandbox", csp)
+	}
+
+	// POST takes a new one
+	if rec := do(t, s, http.MethodPost, "/admin/snapshots/wiki", nil, "", ""); rec.Code != http.StatusOK {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(t, s, http.MethodGet, "/admin/snapshots/wiki", nil, "", ""); rec.Body.String() != page {
+		t.Errorf("after POST: %q, want %q", rec.Body, page)
+	}
+
+	do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: "cal", URL: "https://cal.invalid"}, "", "")
+	if rec := do(t, s, http.MethodPost, "/admin/snapshots/cal", nil, "", ""); rec.Code != http.StatusBadGateway {
+		t.Errorf("POST of unreachable destination = %d, want 502", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/admin/snapshots/cal", nil, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET without snapshot = %d, want 404", rec.Code)
+	}
+	if rec := do(t, s, http.MethodGet, "/admin/snapshots/missing", nil, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of unknown slug = %d, want 404", rec.Code)
+	}
+}
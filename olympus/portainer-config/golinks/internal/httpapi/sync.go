@@ -0,0 +1,271 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// SyncRequest is the body of POST /admin/sync: the changes an instance
+// syncing with this one decided on.
+type SyncRequest struct {
+	Changes []SyncChange `json:"changes"`
+	// Aliases are made once the links are in place, as they point at
+	// them.
+	Aliases []SyncAlias `json:"aliases,omitempty"`
+}
+
+// SyncChange is one link as the syncing instance wants it here.
+type SyncChange struct {
+	Slug string `json:"slug"`
+	// Link is the link with every setting, UpdatedAt included; nil removes
+	// it.
+	Link *store.Link `json:"link,omitempty"`
+	// Expect is the UpdatedAt the change was decided on, nil if there was
+	// no link at the slug. If the link has changed since, the change is
+	// rejected.
+	Expect *time.Time `json:"expect,omitempty"`
+}
+
+// SyncAlias is one alias as the syncing instance wants it here.
+type SyncAlias struct {
+	Slug string `json:"slug"`
+	// Target is the slug of the link the alias points at; empty removes
+	// the alias.
+	Target string `json:"target,omitempty"`
+}
+
+// SyncResult says which changes of a SyncRequest were made.
+type SyncResult struct {
+	Applied int `json:"applied"`
+	// Rejected are the slugs whose link changed after the syncing instance
+	// looked, or that this instance refuses as it would refuse them added
+	// here; it tries them again on its next sync.
+	Rejected []string `json:"rejected"`
+	// RejectedAliases are the aliases that could not be set, as their
+	// slug is taken by a link or not a valid slug, or their target is
+	// missing.
+	RejectedAliases []string `json:"rejected_aliases"`
+}
+
+// SyncConflict is a link changed on both instances since they last
+// synced. The newer change was kept on both.
+type SyncConflict struct {
+	Slug string `json:"slug"`
+	// At is when the sync found the conflict.
+	At time.Time `json:"at"`
+	// Reason is what happened on either side, such as "changed on both".
+	Reason string `json:"reason"`
+	// Kept is whose version both now have: "local" or "peer".
+	Kept string `json:"kept"`
+	// Local and Peer are the two versions, nil where the link was removed.
+	Local *store.Link `json:"local,omitempty"`
+	Peer  *store.Link `json:"peer,omitempty"`
+}
+
+// SyncReporter lists the conflicts of the two-way sync with another
+// instance, newest first.
+type SyncReporter interface {
+	Conflicts() []SyncConflict
+}
+
+// ApplySync makes the changes of req to the links and aliases of st.
+// Clicks are counted where the redirects happen, so a replaced link keeps
+// its own and a new one starts without any.
+func ApplySync(ctx context.Context, st store.Store, req SyncRequest) (SyncResult, error) {
+	result := SyncResult{Rejected: []string{}, RejectedAliases: []string{}}
+	for _, c := range req.Changes {
+		current, err := st.GetLink(ctx, c.Slug)
+		if errors.Is(err, store.ErrNotFound) {
+			current, err = nil, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		if (current == nil) != (c.Expect == nil) || (current != nil && !current.UpdatedAt.Equal(*c.Expect)) {
+			result.Rejected = append(result.Rejected, c.Slug)
+			continue
+		}
+		switch {
+		case c.Link == nil:
+			err = st.RemoveLink(ctx, c.Slug)
+		case current == nil:
+			link := *c.Link
+			link.Clicks, link.ListOpens, link.LastUsedAt = 0, 0, nil
+			err = st.RestoreLink(ctx, link)
+		default:
+			err = st.ReplaceLink(ctx, *c.Link)
+		}
+		// The link was added or removed meanwhile
+		if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrConflict) {
+			result.Rejected = append(result.Rejected, c.Slug)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Applied++
+	}
+	for _, a := range req.Aliases {
+		var err error
+		if a.Target == "" {
+			err = st.RemoveAlias(ctx, a.Slug)
+			if errors.Is(err, store.ErrNotFound) {
+				err = nil
+			}
+		} else {
+			err = st.SetAlias(ctx, store.Alias{Slug: a.Slug, Target: a.Target})
+		}
+		if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrConflict) {
+			result.RejectedAliases = append(result.RejectedAliases, a.Slug)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Applied++
+	}
+	return result, nil
+}
+
+// handleAdminSync takes the changes of an instance syncing with this one.
+func (s *Server) handleAdminSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SyncRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	for _, c := range req.Changes {
+		if c.Slug == "" || (c.Link != nil && c.Link.Slug != c.Slug) {
+			http.Error(w, "Every change needs a slug, and its link the same slug", http.StatusBadRequest)
+			return
+		}
+	}
+	for _, a := range req.Aliases {
+		if a.Slug == "" {
+			http.Error(w, "Every alias needs a slug", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Links from the peer pass the checks of links added here
+	var changes []SyncChange
+	var refused []string
+	var held []store.Link
+	for _, c := range req.Changes {
+		if c.Link != nil {
+			// Stores take an empty status as active
+			link := *c.Link
+			link.Status = cmp.Or(link.Status, store.StatusActive)
+			wasActive := link.Status == store.StatusActive
+			err := s.checkSyncedLink(r.Context(), &link)
+			var invalid *InvalidError
+			if errors.As(err, &invalid) {
+				slog.WarnContext(r.Context(), "Refused synced link", "slug", c.Slug, "error", invalid.Msg, "remote_addr", s.remote(r))
+				refused = append(refused, c.Slug)
+				continue
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error applying sync", "error", err)
+				httperr.Write(w, err)
+				return
+			}
+			if wasActive && link.Status == store.StatusPending {
+				held = append(held, link)
+			}
+			c.Link = &link
+		}
+		changes = append(changes, c)
+	}
+	var aliases []SyncAlias
+	var refusedAliases []string
+	for _, a := range req.Aliases {
+		if a.Target != "" && s.checkNewSlug(a.Slug, "") != nil {
+			refusedAliases = append(refusedAliases, a.Slug)
+			continue
+		}
+		aliases = append(aliases, a)
+	}
+
+	result, err := ApplySync(r.Context(), s.store, SyncRequest{Changes: changes, Aliases: aliases})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error applying sync", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	for _, link := range held {
+		if !slices.Contains(result.Rejected, link.Slug) {
+			slog.InfoContext(r.Context(), "Synced link pending approval", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+			s.propose(link)
+		}
+	}
+	result.Rejected = append(result.Rejected, refused...)
+	result.RejectedAliases = append(result.RejectedAliases, refusedAliases...)
+	slog.InfoContext(r.Context(), "Synced", "applied", result.Applied, "rejected", len(result.Rejected), "remote_addr", s.remote(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// checkSyncedLink validates a link sent by a peer as AddLink validates a
+// new one. The peer's approval of a sensitive destination cannot be
+// verified here, so such a link arrives pending unless it is already
+// active here with the same destination.
+func (s *Server) checkSyncedLink(ctx context.Context, link *store.Link) error {
+	if err := s.checkNewSlug(link.Slug, link.CreatedBy); err != nil {
+		return err
+	}
+	switch link.Status {
+	case store.StatusReserved:
+		if link.URL != "" {
+			return &InvalidError{Msg: "A reserved link has no URL", Field: "url"}
+		}
+		return nil
+	case store.StatusActive, store.StatusPending:
+	default:
+		return &InvalidError{Msg: "Invalid status", Field: "status"}
+	}
+	dest, err := s.destination(link.Slug, link.URL, link.CreatedBy)
+	if err != nil {
+		return err
+	}
+	if err := s.CheckFailover(link.Failover); err != nil {
+		return err
+	}
+	link.URL = dest.URL
+	if link.Status == store.StatusActive && dest.Status == store.StatusPending {
+		current, err := s.store.GetLink(ctx, link.Slug)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		if current == nil || current.Status != store.StatusActive || current.URL != link.URL {
+			link.Status, link.ApprovedBy = store.StatusPending, ""
+		}
+	}
+	return nil
+}
+
+// handleAdminSyncConflicts lists the conflicts the sync with another
+// instance found.
+func (s *Server) handleAdminSyncConflicts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(s.cfg.Sync.Conflicts())
+}
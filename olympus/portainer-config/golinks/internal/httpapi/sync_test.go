@@ -0,0 +1,174 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+// fixedConflicts is a SyncReporter with a fixed list.
+type fixedConflicts []SyncConflict
+
+func (c fixedConflicts) Conflicts() []SyncConflict { return c }
+
+// pushSync posts body to /admin/sync with token, as a syncing peer does.
+func pushSync(t *testing.T, s *Server, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/sync", bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminSync(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{SyncToken: "s3cret"})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "old", URL: "https://old.example.com"})
+	st.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: time.Now()}})
+	st.SetAlias(ctx, store.Alias{Slug: "o", Target: "old"})
+	st.SetAlias(ctx, store.Alias{Slug: "w", Target: "wiki"})
+	wiki, _ := st.GetLink(ctx, "wiki")
+	old, _ := st.GetLink(ctx, "old")
+	stale := wiki.UpdatedAt.Add(-time.Hour)
+	changed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	req := SyncRequest{Changes: []SyncChange{
+		{Slug: "wiki", Link: &store.Link{Slug: "wiki", URL: "https://wiki2.example.com", Status: store.StatusActive, Pin: 2, UpdatedAt: changed}, Expect: &wiki.UpdatedAt},
+		{Slug: "old", Expect: &old.UpdatedAt},
+		{Slug: "new", Link: &store.Link{Slug: "new", URL: "https://new.example.com", Clicks: 9, UpdatedAt: changed}},
+		// Decided on versions that are gone
+		{Slug: "wiki", Link: &store.Link{Slug: "wiki", URL: "https://stale.example.com"}, Expect: &stale},
+		{Slug: "new", Link: &store.Link{Slug: "new", URL: "https://stale.example.com"}},
+	}, Aliases: []SyncAlias{
+		{Slug: "n", Target: "new"},
+		{Slug: "w"},
+		// A link's slug, and a link that is not here
+		{Slug: "wiki", Target: "new"},
+		{Slug: "m", Target: "missing"},
+	}}
+	rec := pushSync(t, s, "s3cret", req)
+	var result SyncResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/sync = %d %s", rec.Code, rec.Body)
+	}
+	if result.Applied != 5 || !reflect.DeepEqual(result.Rejected, []string{"wiki", "new"}) || !reflect.DeepEqual(result.RejectedAliases, []string{"wiki", "m"}) {
+		t.Errorf("result = %+v", result)
+	}
+	if link, _ := st.GetLink(ctx, "wiki"); link.URL != "https://wiki2.example.com" || link.Pin != 2 || link.Clicks != 1 || !link.UpdatedAt.Equal(changed) {
+		t.Errorf("wiki = %+v", link)
+	}
+	if _, err := st.GetLink(ctx, "old"); err == nil {
+		t.Error("old not removed")
+	}
+	if link, _ := st.GetLink(ctx, "new"); link == nil || link.Clicks != 0 || !link.UpdatedAt.Equal(changed) {
+		t.Errorf("new = %+v", link)
+	}
+	if aliases, _ := st.ListAliases(ctx); !reflect.DeepEqual(aliases, []store.Alias{{Slug: "n", Target: "new"}}) {
+		t.Errorf("aliases = %v", aliases)
+	}
+
+	for _, body := range []any{"nope", SyncRequest{Changes: []SyncChange{{Slug: "a", Link: &store.Link{Slug: "b"}}}}} {
+		if rec := pushSync(t, s, "s3cret", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /admin/sync %v = %d", body, rec.Code)
+		}
+	}
+
+	// Conflicts are only served with a sync configured
+	if rec := do(t, s, http.MethodGet, "/admin/sync/conflicts", nil, "", ""); rec.Code == http.StatusOK {
+		t.Errorf("conflicts served without a sync")
+	}
+	s, _ = newTestServer(t, Config{Sync: fixedConflicts{{Slug: "wiki", Reason: "changed on both", Kept: "peer"}}})
+	rec = do(t, s, http.MethodGet, "/admin/sync/conflicts", nil, "", "")
+	var conflicts []SyncConflict
+	if err := json.Unmarshal(rec.Body.Bytes(), &conflicts); err != nil || len(conflicts) != 1 || conflicts[0].Slug != "wiki" {
+		t.Errorf("GET /admin/sync/conflicts = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAdminSyncAuth(t *testing.T) {
+	req := SyncRequest{Changes: []SyncChange{{Slug: "wiki", Link: &store.Link{Slug: "wiki", URL: "https://wiki.example.com"}}}}
+	cfg := twoAdmins
+	s, st := newTestServer(t, cfg)
+	if rec := pushSync(t, s, "", req); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /admin/sync without a SyncToken = %d", rec.Code)
+	}
+
+	// Only the token is taken, not an admin login
+	cfg.SyncToken = "s3cret"
+	s, st = newTestServer(t, cfg)
+	if rec := do(t, s, http.MethodPost, "/admin/sync", req, "alice", "pw1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /admin/sync as an admin = %d", rec.Code)
+	}
+	if rec := pushSync(t, s, "wrong", req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /admin/sync with a wrong token = %d", rec.Code)
+	}
+	if n, _ := st.CountLinks(context.Background()); n != 0 {
+		t.Errorf("%d links after refused syncs", n)
+	}
+	if rec := pushSync(t, s, "s3cret", req); rec.Code != http.StatusOK {
+		t.Errorf("POST /admin/sync with the token = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAdminSyncChecks(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{SyncToken: "s3cret", SensitivePatterns: []string{"*.bank.example"}, BannedWords: []string{"darn"}})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.bank.example", Status: store.StatusActive, CreatedBy: "alice", ApprovedBy: "bob"})
+	pay, _ := st.GetLink(ctx, "pay")
+	changed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	req := SyncRequest{Changes: []SyncChange{
+		// Refused as an add here would be
+		{Slug: "admin", Link: &store.Link{Slug: "admin", URL: "https://evil.example.com", UpdatedAt: changed}},
+		{Slug: "darn-it", Link: &store.Link{Slug: "darn-it", URL: "https://example.com", UpdatedAt: changed}},
+		{Slug: "js", Link: &store.Link{Slug: "js", URL: "javascript:alert(1)", UpdatedAt: changed}},
+		{Slug: "odd", Link: &store.Link{Slug: "odd", URL: "https://example.com", Status: "odd", UpdatedAt: changed}},
+		{Slug: "fall", Link: &store.Link{Slug: "fall", URL: "https://example.com", Failover: "https://x.bank.example", UpdatedAt: changed}},
+		// A sensitive link claimed approved by the peer waits here
+		{Slug: "bank", Link: &store.Link{Slug: "bank", URL: "https://www.bank.example", Status: store.StatusActive, CreatedBy: "mallory", ApprovedBy: "mallory", UpdatedAt: changed}},
+		// One approved here keeps its approval while its destination stays
+		{Slug: "pay", Link: &store.Link{Slug: "pay", URL: "https://pay.bank.example", Status: store.StatusActive, CreatedBy: "alice", ApprovedBy: "bob", Pin: 1, UpdatedAt: changed}, Expect: &pay.UpdatedAt},
+	}, Aliases: []SyncAlias{{Slug: "admin", Target: "pay"}}}
+	rec := pushSync(t, s, "s3cret", req)
+	var result SyncResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/sync = %d %s", rec.Code, rec.Body)
+	}
+	if result.Applied != 2 || !reflect.DeepEqual(result.Rejected, []string{"admin", "darn-it", "js", "odd", "fall"}) || !reflect.DeepEqual(result.RejectedAliases, []string{"admin"}) {
+		t.Errorf("result = %+v", result)
+	}
+	if n, _ := st.CountLinks(ctx); n != 2 {
+		t.Errorf("%d links, want bank and pay", n)
+	}
+	if link, _ := st.GetLink(ctx, "bank"); link == nil || link.Status != store.StatusPending || link.ApprovedBy != "" {
+		t.Errorf("bank = %+v", link)
+	}
+	if link, _ := st.GetLink(ctx, "pay"); link.Status != store.StatusActive || link.Pin != 1 {
+		t.Errorf("pay = %+v", link)
+	}
+
+	// A new destination needs approval here again
+	pay, _ = st.GetLink(ctx, "pay")
+	req = SyncRequest{Changes: []SyncChange{
+		{Slug: "pay", Link: &store.Link{Slug: "pay", URL: "https://other.bank.example", Status: store.StatusActive, ApprovedBy: "bob", UpdatedAt: changed.Add(time.Hour)}, Expect: &pay.UpdatedAt},
+	}}
+	if rec := pushSync(t, s, "s3cret", req); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/sync = %d %s", rec.Code, rec.Body)
+	}
+	if link, _ := st.GetLink(ctx, "pay"); link.Status != store.StatusPending || link.URL != "https://other.bank.example" {
+		t.Errorf("pay with a new destination = %+v", link)
+	}
+}
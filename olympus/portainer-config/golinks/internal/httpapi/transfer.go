@@ -0,0 +1,351 @@
+package httpapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// Results of a link in a TransferReport.
+const (
+	TransferMoved     = "transferred"
+	TransferUnchanged = "unchanged"
+	TransferFailed    = "failed"
+)
+
+// TransferRequest hands links over to another owner or namespace, such as
+// when someone leaves or a project moves teams. The links are those named
+// in Slugs, or else every link, narrowed down by the other selectors: those
+// in Collection, created by Owner and below Namespace. At least one
+// selector must be given.
+type TransferRequest struct {
+	Slugs      []string `json:"slugs,omitempty"`
+	Collection string   `json:"collection,omitempty"`
+	Owner      string   `json:"owner,omitempty"`
+	Namespace  string   `json:"namespace,omitempty"`
+	// ToOwner becomes the creator of the links. ToNamespace moves them
+	// below another namespace, keeping the part of their slug below
+	// Namespace, or else their last segment: with namespace "team-a",
+	// "team-a/docs/api" moves to "team-b/docs/api"; without, to
+	// "team-b/api". At least one of them must be given.
+	ToOwner     string `json:"to_owner,omitempty"`
+	ToNamespace string `json:"to_namespace,omitempty"`
+	// Alias keeps the old slugs of moved links redirecting to them.
+	Alias bool `json:"alias"`
+	// DryRun only reports what would be transferred.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// TransferResult is what a transfer did to one link.
+type TransferResult struct {
+	Slug string `json:"slug"`
+	// To is the slug the link moved to, if it moved.
+	To string `json:"to,omitempty"`
+	// Owner is the creator of the link after the transfer.
+	Owner  string `json:"owner,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TransferReport is the answer to a transfer: every selected link and the
+// number of links per result.
+type TransferReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Links   []TransferResult `json:"links"`
+	Summary map[string]int   `json:"summary"`
+}
+
+// TransferLinks hands the links req selects over to its new owner or
+// namespace on behalf of changedBy. Links are moved with RenameLink and
+// get their new creator in place, so their clicks, aliases, collections
+// and history stay with them. A link that can't be transferred is reported
+// as failed and the others still are. Invalid requests are returned as
+// *InvalidError.
+func (s *Server) TransferLinks(ctx context.Context, req TransferRequest, changedBy string) (TransferReport, error) {
+	req.Collection = strings.TrimSpace(req.Collection)
+	req.Owner = strings.TrimSpace(req.Owner)
+	req.Namespace = canonicalSlug(strings.Trim(strings.TrimSpace(req.Namespace), "/"))
+	req.ToOwner = strings.TrimSpace(req.ToOwner)
+	req.ToNamespace = canonicalSlug(strings.Trim(strings.TrimSpace(req.ToNamespace), "/"))
+	switch {
+	case len(req.Slugs) == 0 && req.Collection == "" && req.Owner == "" && req.Namespace == "":
+		return TransferReport{}, &InvalidError{Msg: "Select the links by slugs, collection, owner or namespace", Field: "slugs"}
+	case req.ToOwner == "" && req.ToNamespace == "":
+		return TransferReport{}, &InvalidError{Msg: "Give the new owner or namespace", Field: "to_owner"}
+	case req.ToNamespace != "" && !isValidSlug(req.ToNamespace):
+		return TransferReport{}, &InvalidError{Msg: "Invalid namespace", Field: "to_namespace"}
+	}
+
+	report := TransferReport{DryRun: req.DryRun, Links: []TransferResult{}, Summary: map[string]int{}}
+	links, missing, err := s.transferSelection(ctx, req)
+	if err != nil {
+		return TransferReport{}, err
+	}
+	for _, slug := range missing {
+		report.Links = append(report.Links, TransferResult{Slug: slug, Result: TransferFailed, Error: linkErrorText(store.ErrNotFound)})
+	}
+	// taken holds the slugs moved to so far, which a dry run can't look up
+	taken := make(map[string]bool)
+	for _, link := range links {
+		report.Links = append(report.Links, s.transferLink(ctx, link, req, changedBy, taken))
+	}
+	for _, res := range report.Links {
+		report.Summary[res.Result]++
+	}
+	return report, nil
+}
+
+// transferSelection returns the links req selects, and the slugs it names
+// that have no link.
+func (s *Server) transferSelection(ctx context.Context, req TransferRequest) ([]store.Link, []string, error) {
+	var inCollection map[string]bool
+	if req.Collection != "" {
+		c, err := s.store.GetCollection(ctx, req.Collection)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil, &InvalidError{Msg: "Unknown collection", Field: "collection"}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		inCollection = make(map[string]bool, len(c.Slugs))
+		for _, slug := range c.Slugs {
+			inCollection[slug] = true
+		}
+	}
+	selected := func(link store.Link) bool {
+		return (inCollection == nil || inCollection[link.Slug]) &&
+			(req.Owner == "" || link.CreatedBy == req.Owner) &&
+			(req.Namespace == "" || strings.HasPrefix(link.Slug, req.Namespace+"/"))
+	}
+
+	var links []store.Link
+	var missing []string
+	if len(req.Slugs) > 0 {
+		if len(req.Slugs) > maxBulkItems {
+			return nil, nil, &InvalidError{Msg: fmt.Sprintf("At most %d links per request", maxBulkItems), Field: "slugs"}
+		}
+		seen := make(map[string]bool)
+		for _, slug := range req.Slugs {
+			slug = canonicalSlug(strings.TrimSpace(slug))
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			link, err := s.store.GetLink(ctx, slug)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				missing = append(missing, slug)
+			case err != nil:
+				return nil, nil, err
+			case selected(*link):
+				links = append(links, *link)
+			}
+		}
+		return links, missing, nil
+	}
+
+	// The links are gathered before any is changed, which EachLink can't
+	// be relied on to survive
+	err := s.store.EachLink(ctx, func(link store.Link) error {
+		if !selected(link) {
+			return nil
+		}
+		if len(links) == maxBulkItems {
+			return &InvalidError{Msg: fmt.Sprintf("At most %d links per request", maxBulkItems), Field: "slugs"}
+		}
+		links = append(links, link)
+		return nil
+	})
+	return links, nil, err
+}
+
+// transferLink hands link over as req says, or checks that it could on a
+// dry run.
+func (s *Server) transferLink(ctx context.Context, link store.Link, req TransferRequest, changedBy string, taken map[string]bool) TransferResult {
+	to := transferSlug(link.Slug, req.Namespace, req.ToNamespace)
+	res := TransferResult{Slug: link.Slug, Owner: cmp.Or(req.ToOwner, link.CreatedBy), Result: TransferMoved}
+	if to == link.Slug && res.Owner == link.CreatedBy {
+		res.Result = TransferUnchanged
+		return res
+	}
+	fail := func(err error) TransferResult {
+		res.Result, res.Error = TransferFailed, linkErrorText(err)
+		return res
+	}
+	if link.Status == store.StatusPending && res.Owner != link.CreatedBy {
+		// Its creator may not approve it, which a new one would let them
+		res.Owner = link.CreatedBy
+		return fail(&InvalidError{Msg: "A pending link keeps its creator until it is approved or rejected", Field: "to_owner"})
+	}
+	if to != link.Slug {
+		res.To = to
+		if taken[to] {
+			return fail(store.ErrConflict)
+		}
+		taken[to] = true
+	}
+
+	if req.DryRun {
+		if to == link.Slug {
+			return res
+		}
+		if !isValidSlug(to) || s.containsBannedWord(to) {
+			return fail(&InvalidError{Msg: "Invalid slug", Field: "to"})
+		}
+		if _, err := s.store.GetLink(ctx, to); err == nil {
+			return fail(store.ErrConflict)
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return fail(err)
+		}
+		return res
+	}
+
+	if to != link.Slug {
+		if err := s.RenameLink(ctx, link.Slug, to, req.Alias, changedBy); err != nil {
+			return fail(err)
+		}
+		link.Slug = to
+	}
+	if res.Owner != link.CreatedBy {
+		// Everything else stays as it is, the approver included
+		link.CreatedBy, link.UpdatedAt = res.Owner, time.Time{}
+		if err := s.store.ReplaceLink(ctx, link); err != nil {
+			return fail(err)
+		}
+	}
+	return res
+}
+
+// transferSlug returns the slug moving slug from the namespace from to to
+// gives it, see TransferRequest. An empty to leaves slug where it is.
+func transferSlug(slug, from, to string) string {
+	if to == "" {
+		return slug
+	}
+	rest, ok := strings.CutPrefix(slug, from+"/")
+	if from == "" || !ok {
+		rest = slug[strings.LastIndex(slug, "/")+1:]
+	}
+	return to + "/" + rest
+}
+
+// transferPage is the browser form of /admin/transfer, showing the report
+// of the transfer it posted.
+var transferPage = template.Must(template.New("transfer").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Transfer links</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 3em auto">
+<h1>Transfer links</h1>
+<p>Hand links over to another owner or namespace, keeping their clicks, aliases and collections.</p>
+{{with .Error}}<p style="color: #a94442"><strong>{{.}}</strong></p>{{end}}
+{{with .Report}}<p>{{if .DryRun}}Dry run: {{end}}{{index .Summary "transferred"}} transferred, {{index .Summary "unchanged"}} unchanged, {{index .Summary "failed"}} failed.</p>
+<table>
+<tr><th align="left">Link</th><th align="left">Now</th><th align="left">Owner</th><th align="left">Result</th></tr>
+{{range .Links}}<tr><td>{{.Slug}}</td><td>{{.To}}</td><td>{{.Owner}}</td><td>{{.Result}}{{with .Error}}: {{.}}{{end}}</td></tr>
+{{end}}</table>{{end}}
+<form method="post" action="/admin/transfer">
+<h2>Links</h2>
+<p><label>Slugs, one per line<br><textarea name="slugs" rows="4" cols="40">{{.Slugs}}</textarea></label></p>
+<p>or all links, or those above, that are</p>
+<p><label>In collection <input name="collection" value="{{.Request.Collection}}"></label></p>
+<p><label>Owned by <input name="owner" value="{{.Request.Owner}}"></label></p>
+<p><label>Below namespace <input name="namespace" value="{{.Request.Namespace}}"></label></p>
+<h2>Transfer to</h2>
+<p><label>Owner <input name="to_owner" value="{{.Request.ToOwner}}"></label></p>
+<p><label>Namespace <input name="to_namespace" value="{{.Request.ToNamespace}}"></label></p>
+<p><label><input type="checkbox" name="alias" value="1"{{if .Request.Alias}} checked{{end}}> Keep the old slugs as aliases</label></p>
+<p><label><input type="checkbox" name="dry_run" value="1"{{if .Request.DryRun}} checked{{end}}> Dry run</label></p>
+<p><button>Transfer</button></p>
+</form>
+</body>
+</html>
+`))
+
+// handleAdminTransfer serves the transfer form (GET) or transfers links
+// (POST, JSON or the form).
+func (s *Server) handleAdminTransfer(w http.ResponseWriter, r *http.Request) {
+	type page struct {
+		Request TransferRequest
+		Slugs   string
+		Report  *TransferReport
+		Error   string
+	}
+	render := func(p page, status int) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		transferPage.Execute(w, p)
+	}
+	switch r.Method {
+	case http.MethodGet:
+		render(page{Request: TransferRequest{Alias: true}}, http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	form := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+	var req TransferRequest
+	if form {
+		// Browsers resend Basic credentials with any form post, so refuse
+		// posts from other sites.
+		if r.Header.Get("Sec-Fetch-Site") == "cross-site" || !sameOrigin(r) {
+			http.Error(w, "Cross-site form posts are not allowed", http.StatusForbidden)
+			return
+		}
+		req = TransferRequest{
+			Slugs:       strings.Fields(r.PostFormValue("slugs")),
+			Collection:  r.PostFormValue("collection"),
+			Owner:       r.PostFormValue("owner"),
+			Namespace:   r.PostFormValue("namespace"),
+			ToOwner:     r.PostFormValue("to_owner"),
+			ToNamespace: r.PostFormValue("to_namespace"),
+			Alias:       r.PostFormValue("alias") != "",
+			DryRun:      r.PostFormValue("dry_run") != "",
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	report, err := s.TransferLinks(r.Context(), req, s.adminName(r))
+	if err != nil {
+		if !form {
+			writeLinkError(w, r, err)
+			return
+		}
+		p := page{Request: req, Slugs: r.PostFormValue("slugs")}
+		var invalid *InvalidError
+		code := http.StatusBadRequest
+		if errors.As(err, &invalid) {
+			p.Error = invalid.Msg
+		} else {
+			slog.ErrorContext(r.Context(), "Error transferring links", "error", err)
+			code, p.Error = httperr.Status(err)
+		}
+		render(p, code)
+		return
+	}
+	if !req.DryRun {
+		slog.InfoContext(r.Context(), "Links transferred", "transferred", report.Summary[TransferMoved], "links", len(report.Links), "owner", req.ToOwner, "namespace", req.ToNamespace, "remote_addr", s.remote(r))
+	}
+
+	if form {
+		render(page{Request: req, Slugs: r.PostFormValue("slugs"), Report: &report}, http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestAdminTransfer(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	for _, link := range []store.Link{
+		{Slug: "team-a/wiki", URL: "https://wiki.example.com", CreatedBy: "alice"},
+		{Slug: "team-a/docs/api", URL: "https://api.example.com", CreatedBy: "alice"},
+		{Slug: "mail", URL: "https://mail.example.com", CreatedBy: "alice", ApprovedBy: "carol"},
+		{Slug: "chat", URL: "https://chat.example.com", CreatedBy: "bob"},
+		{Slug: "team-b/api", URL: "https://other.example.com", CreatedBy: "bob"},
+		{Slug: "bank", URL: "https://login.bank.example", CreatedBy: "erin", Status: store.StatusPending},
+	} {
+		st.AddLink(ctx, link)
+	}
+	st.SaveCollection(ctx, store.Collection{Name: "onboarding", Slugs: []string{"team-a/wiki", "chat"}})
+	st.RecordClicks(ctx, []store.Click{{Slug: "team-a/wiki", At: time.Now()}, {Slug: "team-a/wiki", At: time.Now()}})
+
+	transfer := func(req TransferRequest) TransferReport {
+		t.Helper()
+		rec := do(t, s, http.MethodPost, "/admin/transfer", req, "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%+v: status = %d: %s", req, rec.Code, rec.Body)
+		}
+		var report TransferReport
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		return report
+	}
+
+	// A dry run reports the clash with team-b/api and changes nothing
+	report := transfer(TransferRequest{Owner: "alice", ToNamespace: "team-b", DryRun: true})
+	if report.Summary[TransferMoved] != 2 || report.Summary[TransferFailed] != 1 {
+		t.Errorf("dry run = %+v", report)
+	}
+	if _, err := st.GetLink(ctx, "team-b/wiki"); err == nil {
+		t.Error("dry run moved team-a/wiki")
+	}
+
+	report = transfer(TransferRequest{Namespace: "team-a", ToNamespace: "team-b", ToOwner: "bob", Alias: true})
+	if report.Summary[TransferMoved] != 2 {
+		t.Errorf("namespace transfer = %+v", report)
+	}
+	link, err := st.GetLink(ctx, "team-b/wiki")
+	if err != nil || link.CreatedBy != "bob" || link.Clicks != 2 {
+		t.Errorf("team-b/wiki = %+v, %v", link, err)
+	}
+	if link, _ := st.GetLink(ctx, "team-b/docs/api"); link == nil || link.CreatedBy != "bob" {
+		t.Errorf("team-b/docs/api = %+v", link)
+	}
+	if target, _ := st.ResolveAlias(ctx, "team-a/wiki"); target != "team-b/wiki" {
+		t.Errorf("alias team-a/wiki -> %q", target)
+	}
+	if c, _ := st.GetCollection(ctx, "onboarding"); c.Slugs[0] != "team-b/wiki" {
+		t.Errorf("onboarding = %v", c.Slugs)
+	}
+
+	// By collection, the owner changes and the approver stays
+	report = transfer(TransferRequest{Collection: "onboarding", ToOwner: "dave"})
+	if report.Summary[TransferMoved] != 2 {
+		t.Errorf("collection transfer = %+v", report)
+	}
+	if link, _ := st.GetLink(ctx, "chat"); link.CreatedBy != "dave" {
+		t.Errorf("chat = %+v", link)
+	}
+	report = transfer(TransferRequest{Slugs: []string{"mail", "missing", "chat"}, ToOwner: "dave"})
+	want := []TransferResult{
+		{Slug: "missing", Result: TransferFailed, Error: "not found"},
+		{Slug: "mail", Owner: "dave", Result: TransferMoved},
+		{Slug: "chat", Owner: "dave", Result: TransferUnchanged},
+	}
+	for i, res := range report.Links {
+		if i >= len(want) || res != want[i] {
+			t.Errorf("links = %+v, want %+v", report.Links, want)
+			break
+		}
+	}
+	if link, _ := st.GetLink(ctx, "mail"); link.CreatedBy != "dave" || link.ApprovedBy != "carol" {
+		t.Errorf("mail = %+v", link)
+	}
+
+	// Handing a pending link to someone else would let its creator approve
+	// it; moving it is fine
+	report = transfer(TransferRequest{Slugs: []string{"bank"}, ToOwner: "dave"})
+	if report.Summary[TransferFailed] != 1 || report.Links[0].Owner != "erin" {
+		t.Errorf("pending transfer = %+v", report)
+	}
+	if link, _ := st.GetLink(ctx, "bank"); link.CreatedBy != "erin" {
+		t.Errorf("bank = %+v", link)
+	}
+	report = transfer(TransferRequest{Slugs: []string{"bank"}, ToNamespace: "finance"})
+	if link, err := st.GetLink(ctx, "finance/bank"); report.Summary[TransferMoved] != 1 || err != nil || link.CreatedBy != "erin" || link.Status != store.StatusPending {
+		t.Errorf("pending move = %+v, %+v, %v", report, link, err)
+	}
+
+	for _, req := range []TransferRequest{
+		{ToOwner: "dave"},
+		{Owner: "alice"},
+		{Owner: "bob", ToNamespace: "admin"},
+		{Collection: "missing", ToOwner: "dave"},
+	} {
+		if rec := do(t, s, http.MethodPost, "/admin/transfer", req, "", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%+v: status = %d, want 400", req, rec.Code)
+		}
+	}
+}
+
+func TestAdminTransferForm(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", CreatedBy: "alice"})
+
+	if rec := do(t, s, http.MethodGet, "/admin/transfer", nil, "", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `name="to_owner"`) {
+		t.Fatalf("GET = %d: %s", rec.Code, rec.Body)
+	}
+
+	form := url.Values{"slugs": {"wiki"}, "to_namespace": {"ops"}, "alias": {"1"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/transfer", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "1 transferred") {
+		t.Errorf("POST = %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := st.GetLink(ctx, "ops/wiki"); err != nil {
+		t.Errorf("ops/wiki: %v", err)
+	}
+}
+
+func TestTransferSlug(t *testing.T) {
+	for _, tt := range []struct{ slug, from, to, want string }{
+		{"team-a/docs/api", "team-a", "team-b", "team-b/docs/api"},
+		{"team-a/docs/api", "", "team-b", "team-b/api"},
+		{"wiki", "", "team-b", "team-b/wiki"},
+		{"wiki", "", "", "wiki"},
+	} {
+		if got := transferSlug(tt.slug, tt.from, tt.to); got != tt.want {
+			t.Errorf("transferSlug(%q, %q, %q) = %q, want %q", tt.slug, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
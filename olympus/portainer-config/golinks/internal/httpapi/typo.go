@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"unicode/utf8"
+
+	"golinks/internal/store"
+)
+
+// minTypoLength is the shortest unknown slug typo correction is tried on;
+// shorter ones are one edit away from too many unrelated slugs.
+const minTypoLength = 3
+
+// errAmbiguous ends the typo scan once a second candidate turns up.
+var errAmbiguous = errors.New("ambiguous correction")
+
+// correctTypo returns the only active link whose slug is one edit away from
+// the unknown slug typo, or nil if there is none or more than one.
+func (s *Server) correctTypo(ctx context.Context, typo string) *store.Link {
+	if utf8.RuneCountInString(typo) < minTypoLength {
+		return nil
+	}
+	want := []rune(typo)
+	var match *store.Link
+	err := s.store.EachLink(ctx, func(link store.Link) error {
+		if link.Status != store.StatusActive || !oneEditApart(want, []rune(link.Slug)) {
+			return nil
+		}
+		if match != nil {
+			return errAmbiguous
+		}
+		match = &link
+		return nil
+	})
+	if errors.Is(err, errAmbiguous) {
+		return nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error looking for a correction", "slug", typo, "error", err)
+		return nil
+	}
+	return match
+}
+
+// oneEditApart reports whether a becomes b by inserting, deleting or
+// substituting one rune, or by swapping two adjacent ones.
+func oneEditApart(a, b []rune) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if len(a) < len(b) {
+		// Insertion: the rest of b after the extra rune must match
+		return string(a[i:]) == string(b[i+1:])
+	}
+	if i == len(a) {
+		return false // equal
+	}
+	if string(a[i+1:]) == string(b[i+1:]) {
+		return true // substitution
+	}
+	return i+1 < len(a) && a[i] == b[i+1] && a[i+1] == b[i] && string(a[i+2:]) == string(b[i+2:])
+}
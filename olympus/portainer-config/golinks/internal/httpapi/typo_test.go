@@ -0,0 +1,69 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestOneEditApart(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"wiki", "wiki", false},
+		{"wiki", "wikk", true},  // substitution
+		{"wiki", "wik", true},   // deletion
+		{"wik", "wiki", true},   // insertion
+		{"wiki", "xwiki", true}, // insertion at the start
+		{"wiki", "wkii", true},  // transposition
+		{"wiki", "Wiki", true},
+		{"wiki", "kiwi", false},
+		{"wiki", "wi", false},
+		{"wiki", "wxyi", false},
+		{"🍕🍺", "🍺🍕", true},
+		{"café", "cafe", true},
+	}
+	for _, tt := range tests {
+		if got := oneEditApart([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("oneEditApart(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectTypo(t *testing.T) {
+	ctx := context.Background()
+	var usage usageLog
+	s, st := newTestServer(t, Config{TypoCorrection: true, Usage: &usage})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "mail", URL: "https://mail.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "maps", URL: "https://maps.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "payroll", URL: "https://pay.example.com", Status: store.StatusPending})
+	st.AddLink(ctx, store.Link{Slug: "ab", URL: "https://ab.example.com"})
+
+	rec := do(t, s, http.MethodGet, "/wkii", nil, "", "")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://wiki.example.com" || rec.Header().Get("X-Golinks-Corrected-From") != "wkii" {
+		t.Errorf("typo: status = %d, Location = %q, headers %v", rec.Code, rec.Header().Get("Location"), rec.Header())
+	}
+	if len(usage) != 2 || usage[0] != "miss wkii" || usage[1] != "hit wiki" {
+		t.Errorf("usage = %v, want the typo as a miss and the link as a hit", usage)
+	}
+
+	for _, target := range []string{
+		"/mapl",   // mail and maps are both one edit away
+		"/payrol", // pending links are not suggested
+		"/a",      // too short to guess
+		"/wikipedia",
+	} {
+		if rec := do(t, s, http.MethodGet, target, nil, "", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", target, rec.Code)
+		}
+	}
+
+	s, _ = newTestServer(t, Config{})
+	if rec := do(t, s, http.MethodGet, "/wkii", nil, "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("typo with correction disabled: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,368 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golinks/internal/httperr"
+	"golinks/internal/logging"
+	"golinks/internal/store"
+)
+
+// APIError is the body of every JSON error response, wrapped as
+// {"error": {...}}. Everything under /api/v1 answers with it, and the other
+// /api and /admin endpoints do for clients that accept application/json.
+type APIError struct {
+	Status int `json:"status"`
+	// Code names the kind of failure, such as "not_found", for clients to
+	// branch on; Message is for people and may change.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Fields are the request fields a 400 found at fault, if it knows.
+	Fields []FieldError `json:"fields,omitempty"`
+	// RequestID is the X-Request-Id of the request, or one made up for it,
+	// to find the failure in the server log.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// FieldError is a request field that was rejected, named as in its JSON
+// body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// errorCode returns the APIError code for an HTTP status.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	case http.StatusGatewayTimeout:
+		return "timeout"
+	}
+	if status >= 500 {
+		return "internal"
+	}
+	return "error"
+}
+
+// UpdateLinkBody is the body of PUT /api/v1/links/{slug}.
+type UpdateLinkBody struct {
+	URL string `json:"url"`
+}
+
+// jsonErrors rewrites the plain text errors of http.Error, written by next
+// or by the authentication in front of it, into APIError bodies, so every
+// API client parses failures the same way.
+func jsonErrors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jw := &jsonErrorWriter{ResponseWriter: w, r: r}
+		next(jw, r)
+		jw.finish()
+	}
+}
+
+// negotiatedErrors gives the other /api and /admin endpoints JSON errors
+// too, for clients that ask for JSON; browsers keep getting text.
+func negotiatedErrors(next http.Handler) http.Handler {
+	withJSON := jsonErrors(next.ServeHTTP)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/admin/")) &&
+			strings.Contains(r.Header.Get("Accept"), "application/json") {
+			withJSON(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jsonErrorWriter holds back text/plain error responses until finish.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	status int // of the held back error, 0 while passing writes through
+	msg    bytes.Buffer
+	fields []FieldError
+}
+
+func (w *jsonErrorWriter) WriteHeader(code int) {
+	if code >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *jsonErrorWriter) Write(b []byte) (int, error) {
+	if w.status != 0 {
+		return w.msg.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *jsonErrorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *jsonErrorWriter) finish() {
+	if w.status == 0 {
+		return
+	}
+	e := APIError{
+		Status:    w.status,
+		Code:      errorCode(w.status),
+		Message:   strings.TrimSpace(w.msg.String()),
+		RequestID: requestID(w.r),
+	}
+	if w.status == http.StatusBadRequest {
+		e.Fields = w.fields
+	}
+	if w.status >= 500 {
+		slog.ErrorContext(w.r.Context(), "Request failed", "request_id", e.RequestID, "path", w.r.URL.Path, "status", e.Status, "message", e.Message)
+	}
+	writeAPIError(w.ResponseWriter, e)
+}
+
+func writeAPIError(w http.ResponseWriter, e APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if e.RequestID != "" {
+		w.Header().Set(logging.RequestIDHeader, e.RequestID)
+	}
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(map[string]APIError{"error": e})
+}
+
+// requestID returns the ID withRequestID gave r, else the X-Request-Id a
+// proxy or client gave it, or a new random one if it gave none that fits in
+// a log line.
+func requestID(r *http.Request) string {
+	if id := logging.RequestID(r.Context()); id != "" {
+		return id
+	}
+	id := r.Header.Get(logging.RequestIDHeader)
+	if id != "" && len(id) <= 64 && !strings.ContainsFunc(id, func(c rune) bool { return c <= ' ' || c > '~' }) {
+		return id
+	}
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// withRequestID gives every request an ID, the X-Request-Id it came with
+// or a new one. Its context carries the ID into the log lines of the
+// request and the requests it makes to other instances, and the response
+// sends it back in X-Request-Id.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestID(r)
+		w.Header().Set(logging.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// withLazyRequestID is withRequestID for the hot path: the ID is only
+// settled once something asks for it, such as the log line of a redirect,
+// so a redirect that is not logged skips making one up. The response
+// carries the ID if it was settled before the response was written.
+func withLazyRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := logging.WithLazyRequestID(r.Context(), func() string {
+			id := requestID(r)
+			w.Header().Set(logging.RequestIDHeader, id)
+			return id
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// writeInvalid answers 400 with the message of invalid. A JSON error body
+// also names the field at fault.
+func writeInvalid(w http.ResponseWriter, invalid *InvalidError) {
+	if invalid.Field != "" {
+		for rw := w; rw != nil; {
+			if jw, ok := rw.(*jsonErrorWriter); ok {
+				jw.fields = append(jw.fields, FieldError{Field: invalid.Field, Message: invalid.Msg})
+				break
+			}
+			u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+			if !ok {
+				break
+			}
+			rw = u.Unwrap()
+		}
+	}
+	http.Error(w, invalid.Msg, http.StatusBadRequest)
+}
+
+// handleV1Links serves /api/v1/links: GET lists links like /api/links,
+// POST adds one.
+func (s *Server) handleV1Links(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleLinks(w, r)
+	case http.MethodPost:
+		s.v1AddLink(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleV1Link serves /api/v1/links/{slug} and /api/v1/links/{slug}/stats.
+// Slugs may contain "/", so the path is taken apart by hand.
+func (s *Server) handleV1Link(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/links/")
+	if rest, ok := strings.CutSuffix(rest, "/stats"); ok {
+		s.serveLinkStats(w, r, canonicalSlug(rest))
+		return
+	}
+	slug := canonicalSlug(rest)
+	if slug == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.v1GetLink(w, r, slug)
+	case http.MethodPut:
+		s.v1UpdateLink(w, r, slug)
+	case http.MethodDelete:
+		s.v1RemoveLink(w, r, slug)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) v1GetLink(w http.ResponseWriter, r *http.Request, slug string) {
+	link, err := s.store.GetLink(r.Context(), slug)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Error fetching link", "error", err)
+		}
+		httperr.Write(w, err)
+		return
+	}
+	writeV1Link(w, http.StatusOK, *link)
+}
+
+func (s *Server) v1AddLink(w http.ResponseWriter, r *http.Request) {
+	var req AddLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.AddLink(r.Context(), req, s.adminName(r))
+	if err != nil {
+		writeLinkError(w, r, err)
+		return
+	}
+
+	code := http.StatusCreated
+	if link.Status == store.StatusPending {
+		code = http.StatusAccepted
+		slog.InfoContext(r.Context(), "Link pending approval", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	} else {
+		slog.InfoContext(r.Context(), "Link added", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	}
+	// Answer with the link as stored, creation time included
+	if stored, err := s.store.GetLink(r.Context(), link.Slug); err == nil {
+		link = *stored
+	}
+	w.Header().Set("Location", "/api/v1/links/"+url.PathEscape(link.Slug))
+	writeV1Link(w, code, link)
+}
+
+func (s *Server) v1UpdateLink(w http.ResponseWriter, r *http.Request, slug string) {
+	if slug == "admin" {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	var req UpdateLinkBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	link, err := s.UpdateLink(r.Context(), slug, req.URL, s.adminName(r))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			httperr.Write(w, err)
+			return
+		}
+		writeLinkError(w, r, err)
+		return
+	}
+
+	code := http.StatusOK
+	if link.Status == store.StatusPending {
+		code = http.StatusAccepted
+		slog.InfoContext(r.Context(), "Link pending approval", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	} else {
+		slog.InfoContext(r.Context(), "Link updated", "slug", link.Slug, "url", link.URL, "remote_addr", s.remote(r))
+	}
+	if stored, err := s.store.GetLink(r.Context(), link.Slug); err == nil {
+		link = *stored
+	}
+	writeV1Link(w, code, link)
+}
+
+func (s *Server) v1RemoveLink(w http.ResponseWriter, r *http.Request, slug string) {
+	slug, err := s.RemoveLink(r.Context(), slug)
+	if errors.As(err, new(*InvalidError)) {
+		http.Error(w, "Invalid slug", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error removing link", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Link removed", "slug", slug, "remote_addr", s.remote(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeV1Link(w http.ResponseWriter, code int, link store.Link) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(link)
+}
+
+// deprecated marks a response of the pre-/api/v1 admin endpoints, which are
+// kept for existing scripts, as superseded by /api/v1/links.
+func deprecated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", `</api/v1/links>; rel="successor-version"`)
+		next(w, r)
+	}
+}
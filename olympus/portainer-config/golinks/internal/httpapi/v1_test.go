@@ -0,0 +1,252 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"golinks/internal/logging"
+	"golinks/internal/store"
+)
+
+// apiError decodes the error body of an /api/v1 response.
+func apiError(t *testing.T, body []byte) APIError {
+	t.Helper()
+	var resp map[string]APIError
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("error body is not JSON: %v: %s", err, body)
+	}
+	return resp["error"]
+}
+
+func TestV1Links(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+
+	rec := do(t, s, http.MethodPost, "/api/v1/links", AddLinkRequest{Slug: "docs/go", URL: "https://go.dev/doc"}, "", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/links/docs%2Fgo" {
+		t.Errorf("Location = %q", loc)
+	}
+	var link store.Link
+	json.Unmarshal(rec.Body.Bytes(), &link)
+	if link.Slug != "docs/go" || link.URL != "https://go.dev/doc" || link.CreatedAt.IsZero() {
+		t.Errorf("POST returned %+v", link)
+	}
+
+	rec = do(t, s, http.MethodGet, "/api/v1/links/docs/go", nil, "", "")
+	if json.Unmarshal(rec.Body.Bytes(), &link); rec.Code != http.StatusOK || link.URL != "https://go.dev/doc" {
+		t.Errorf("GET: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = do(t, s, http.MethodPut, "/api/v1/links/docs/go", UpdateLinkBody{URL: "https://go.dev/doc/effective_go"}, "", "")
+	if json.Unmarshal(rec.Body.Bytes(), &link); rec.Code != http.StatusOK || link.URL != "https://go.dev/doc/effective_go" {
+		t.Errorf("PUT: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(t, s, http.MethodGet, "/api/v1/links/docs/go/stats", nil, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET stats: %d %s", rec.Code, rec.Body)
+	}
+	rec = do(t, s, http.MethodGet, "/api/v1/links?q=go", nil, "", "")
+	var page LinkPage
+	if json.Unmarshal(rec.Body.Bytes(), &page); rec.Code != http.StatusOK || len(page.Links) != 1 {
+		t.Errorf("GET list: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(t, s, http.MethodDelete, "/api/v1/links/docs/go", nil, "", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := st.GetLink(ctx, "docs/go"); err != store.ErrNotFound {
+		t.Errorf("link still stored: %v", err)
+	}
+}
+
+func TestV1AddLinkWithSettings(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{AccessGroups: map[string][]netip.Prefix{"kids": {netip.MustParsePrefix("192.168.20.0/24")}}})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.SaveCollection(ctx, store.Collection{Name: "onboarding", Slugs: []string{"wiki"}})
+
+	req := AddLinkRequest{
+		Slug: "docs", URL: "https://docs.example.com",
+		Aliases: []string{"handbook", " go%2Fmanual "}, Collections: []string{"onboarding"},
+		Access:   []store.AccessRule{{Group: " kids ", Days: "mon-fri", From: "15:00", To: "18:00"}},
+		ReviewAt: "2027-01-01", ReviewMonths: 6, Pin: 3, HitBudget: 50,
+		Failover: "https://mirror.example.com", Referrer: "strip",
+	}
+	// Every bad part stores nothing, however late it comes
+	for _, tc := range []struct {
+		name string
+		edit func(*AddLinkRequest)
+		want string
+	}{
+		{"taken alias", func(r *AddLinkRequest) { r.Aliases = append(r.Aliases, "wiki") }, "Alias wiki is taken by a link"},
+		{"duplicate alias", func(r *AddLinkRequest) { r.Aliases = append(r.Aliases, "handbook") }, "Alias handbook is given twice"},
+		{"unknown collection", func(r *AddLinkRequest) { r.Collections = append(r.Collections, "missing") }, "Unknown collection missing"},
+		{"unknown group", func(r *AddLinkRequest) { r.Access[0].Group = "teens" }, `Rule 1: unknown access group "teens"`},
+		{"bad review", func(r *AddLinkRequest) { r.ReviewAt = "soon" }, "review_at must be a date (YYYY-MM-DD) or RFC 3339 time"},
+		{"bad referrer", func(r *AddLinkRequest) { r.Referrer = "hide" }, "Policy must be pass, strip or replace"},
+		{"negative budget", func(r *AddLinkRequest) { r.HitBudget = -1 }, "hit_budget must not be negative"},
+	} {
+		bad := req
+		bad.Aliases = append([]string(nil), req.Aliases...)
+		bad.Collections = append([]string(nil), req.Collections...)
+		bad.Access = append([]store.AccessRule(nil), req.Access...)
+		tc.edit(&bad)
+		rec := do(t, s, http.MethodPost, "/api/v1/links", bad, "", "")
+		if rec.Code != http.StatusBadRequest || apiError(t, rec.Body.Bytes()).Message != tc.want {
+			t.Errorf("%s: %d %s", tc.name, rec.Code, rec.Body)
+		}
+		if _, err := st.GetLink(ctx, "docs"); err != store.ErrNotFound {
+			t.Errorf("%s: link stored anyway", tc.name)
+		}
+		if _, err := st.ResolveAlias(ctx, "handbook"); err != store.ErrNotFound {
+			t.Errorf("%s: alias stored anyway", tc.name)
+		}
+	}
+
+	rec := do(t, s, http.MethodPost, "/api/v1/links", req, "", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST: %d %s", rec.Code, rec.Body)
+	}
+	var link store.Link
+	json.Unmarshal(rec.Body.Bytes(), &link)
+	if len(link.Access) != 1 || link.Access[0].Group != "kids" || link.ReviewAt == nil || link.ReviewMonths != 6 ||
+		link.Pin != 3 || link.HitBudget != 50 || link.Failover != "https://mirror.example.com" || link.Referrer != store.ReferrerStrip {
+		t.Errorf("POST returned %+v", link)
+	}
+	for _, alias := range []string{"handbook", "go/manual"} {
+		if target, err := st.ResolveAlias(ctx, alias); err != nil || target != "docs" {
+			t.Errorf("alias %s -> %q, %v", alias, target, err)
+		}
+	}
+	if c, _ := st.GetCollection(ctx, "onboarding"); len(c.Slugs) != 2 || c.Slugs[1] != "docs" {
+		t.Errorf("collection = %+v", c)
+	}
+}
+
+func TestV1Errors(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t, Config{})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	tests := []struct {
+		name, method, target string
+		body                 any
+		want                 int
+	}{
+		{"missing", http.MethodGet, "/api/v1/links/nope", nil, http.StatusNotFound},
+		{"duplicate", http.MethodPost, "/api/v1/links", AddLinkRequest{Slug: "wiki", URL: "https://other.example.com"}, http.StatusConflict},
+		{"bad url", http.MethodPost, "/api/v1/links", AddLinkRequest{Slug: "ftp", URL: "ftp://files.example.com"}, http.StatusBadRequest},
+		{"invalid json", http.MethodPut, "/api/v1/links/wiki", "not an object", http.StatusBadRequest},
+		{"update missing", http.MethodPut, "/api/v1/links/nope", UpdateLinkBody{URL: "https://example.com"}, http.StatusNotFound},
+		{"remove missing", http.MethodDelete, "/api/v1/links/nope", nil, http.StatusNotFound},
+		{"method", http.MethodPatch, "/api/v1/links/wiki", nil, http.StatusMethodNotAllowed},
+		{"bad query", http.MethodGet, "/api/v1/links?page=x", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, s, tt.method, tt.target, tt.body, "", "")
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if e := apiError(t, rec.Body.Bytes()); e.Status != tt.want || e.Code != errorCode(tt.want) || e.Message == "" || e.RequestID == "" {
+				t.Errorf("error body = %+v", e)
+			}
+		})
+	}
+
+	s, _ = newTestServer(t, twoAdmins)
+	rec := do(t, s, http.MethodGet, "/api/v1/links/wiki", nil, "alice", "wrong")
+	if rec.Code != http.StatusUnauthorized || apiError(t, rec.Body.Bytes()).Message != "Unauthorized" {
+		t.Errorf("bad login: %d %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("no WWW-Authenticate challenge")
+	}
+}
+
+func TestAPIErrorFields(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"slug":"docs","url":"ftp://files.example.com"}`))
+	req.Header.Set("X-Request-Id", "req-42")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	e := apiError(t, rec.Body.Bytes())
+	if e.Code != "invalid_request" || len(e.Fields) != 1 || e.Fields[0].Field != "url" || e.Fields[0].Message != e.Message {
+		t.Errorf("error body = %+v", e)
+	}
+	if e.RequestID != "req-42" || rec.Header().Get("X-Request-Id") != "req-42" {
+		t.Errorf("request id = %q, header %q", e.RequestID, rec.Header().Get("X-Request-Id"))
+	}
+
+	// Other endpoints answer in JSON when asked to
+	req = httptest.NewRequest(http.MethodPost, "/admin/rename", strings.NewReader(`{"from":"nope","to":"docs"}`))
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if e := apiError(t, rec.Body.Bytes()); rec.Code != http.StatusNotFound || e.Code != "not_found" || len(e.RequestID) != 16 {
+		t.Errorf("rename: %d %+v", rec.Code, e)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	s, st := newTestServer(t, Config{})
+	if rec := do(t, s, http.MethodGet, "/api/v1/limits", nil, "", ""); len(rec.Header().Get("X-Request-Id")) != 16 {
+		t.Errorf("made up request id = %q", rec.Header().Get("X-Request-Id"))
+	}
+
+	// A redirect makes one up for its log line, and only then
+	var logs bytes.Buffer
+	orig := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(&logs, logging.FormatText)))
+	t.Cleanup(func() { slog.SetDefault(orig) })
+	st.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	rec := do(t, s, http.MethodGet, "/wiki", nil, "", "")
+	if id := rec.Header().Get("X-Request-Id"); len(id) != 16 || !strings.Contains(logs.String(), "request_id="+id) {
+		t.Errorf("redirect request id %q, logged %q", id, logs.String())
+	}
+	logging.SetLevel(logging.LevelWarn)
+	t.Cleanup(func() { logging.SetLevel(logging.LevelInfo) })
+	if rec := do(t, s, http.MethodGet, "/wiki", nil, "", ""); rec.Code != http.StatusFound || rec.Header().Get("X-Request-Id") != "" {
+		t.Errorf("unlogged redirect = %d, request id %q", rec.Code, rec.Header().Get("X-Request-Id"))
+	}
+	logging.SetLevel(logging.LevelInfo)
+
+	// Plain text errors carry it too
+	req := httptest.NewRequest(http.MethodPost, "/admin/remove", strings.NewReader(`{"slug":"nope"}`))
+	req.Header.Set("X-Request-Id", "req-7")
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || rec.Header().Get("X-Request-Id") != "req-7" {
+		t.Errorf("remove: %d, request id %q", rec.Code, rec.Header().Get("X-Request-Id"))
+	}
+}
+
+func TestLegacyAdminDeprecated(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	rec := do(t, s, http.MethodPost, "/admin/add", AddLinkRequest{Slug: "wiki", URL: "https://wiki.example.com"}, "", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add: %d %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Link") != `</api/v1/links>; rel="successor-version"` {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	// Legacy errors stay plain text
+	rec = do(t, s, http.MethodPost, "/admin/remove", RemoveLinkRequest{Slug: "nope"}, "", "")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "Slug not found\n" {
+		t.Errorf("remove: %d %q", rec.Code, rec.Body)
+	}
+}
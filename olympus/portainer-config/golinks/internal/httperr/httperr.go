@@ -0,0 +1,32 @@
+// Package httperr maps store errors to HTTP responses, so every handler and
+// every backend report the same status for the same failure.
+package httperr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"golinks/internal/store"
+)
+
+// Status maps a store error to its HTTP status and message. Timeouts become
+// 503 so clients know to retry.
+func Status(err error) (int, string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound, "Slug not found"
+	case errors.Is(err, store.ErrConflict):
+		return http.StatusConflict, "Slug already exists"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, "Service unavailable"
+	default:
+		return http.StatusInternalServerError, "Internal server error"
+	}
+}
+
+// Write writes the response for a failed store call.
+func Write(w http.ResponseWriter, err error) {
+	code, msg := Status(err)
+	http.Error(w, msg, code)
+}
@@ -0,0 +1,201 @@
+// Package jobs runs work in the background: long admin operations, such as
+// bulk imports, one at a time and at a gentle pace, and periodic
+// maintenance on a schedule. Both keep their progress for polling.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Job states.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+const (
+	// keepFinished is how many finished jobs are kept for polling.
+	keepFinished = 100
+	// maxErrors bounds the item errors kept per job; the rest are only
+	// counted.
+	maxErrors = 100
+	// paceEvery is how many items a job processes between pauses.
+	paceEvery = 50
+)
+
+// ErrQueueFull is returned by Submit when too many jobs are waiting.
+var ErrQueueFull = errors.New("job queue is full")
+
+// Job is the state of a submitted job.
+type Job struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status"`
+	CreatedBy string `json:"created_by,omitempty"`
+	// Total is the number of items; Done counts the processed ones,
+	// including the Failed ones.
+	Total  int `json:"total"`
+	Done   int `json:"done"`
+	Failed int `json:"failed"`
+	// Errors holds the first item errors; Error is why the job as a whole
+	// failed.
+	Errors     []string   `json:"errors,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Task is the work of a job. It reports every item to p.
+type Task func(ctx context.Context, p *Progress) error
+
+// Progress records the items of a running job.
+type Progress struct {
+	q   *Queue
+	job *Job
+}
+
+// Item records one processed item, failed if err is not nil. Every few
+// items it pauses, so a large job leaves the database to redirects; it
+// returns ctx's error if the queue is stopped meanwhile.
+func (p *Progress) Item(ctx context.Context, err error) error {
+	p.q.mu.Lock()
+	p.job.Done++
+	if err != nil {
+		p.job.Failed++
+		if len(p.job.Errors) < maxErrors {
+			p.job.Errors = append(p.job.Errors, err.Error())
+		}
+	}
+	done := p.job.Done
+	p.q.mu.Unlock()
+
+	if done%paceEvery == 0 && p.q.pause > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.q.pause):
+		}
+	}
+	return ctx.Err()
+}
+
+// Queue runs submitted jobs in order, one at a time. Jobs live in memory:
+// queued and running jobs are lost when the process stops.
+type Queue struct {
+	pause   time.Duration
+	pending chan queued
+
+	mu     sync.Mutex
+	nextID int
+	jobs   map[string]*Job
+	// finished holds the IDs of finished jobs, oldest first.
+	finished []string
+}
+
+type queued struct {
+	job  *Job
+	task Task
+}
+
+// NewQueue creates a Queue holding up to size waiting jobs. Running jobs
+// pause for pause every few items.
+func NewQueue(size int, pause time.Duration) *Queue {
+	return &Queue{pause: pause, pending: make(chan queued, size), jobs: make(map[string]*Job)}
+}
+
+// Submit queues task as a job of kind with total items and returns its
+// state, or ErrQueueFull.
+func (q *Queue) Submit(kind, createdBy string, total int, task Task) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	job := &Job{
+		ID:        strconv.Itoa(q.nextID),
+		Kind:      kind,
+		Status:    StatusQueued,
+		CreatedBy: createdBy,
+		Total:     total,
+		CreatedAt: time.Now().UTC(),
+	}
+	select {
+	case q.pending <- queued{job, task}:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[job.ID] = job
+	return q.snapshot(job), nil
+}
+
+// Get returns the state of the job with id.
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return q.snapshot(job), true
+}
+
+// snapshot copies job; q.mu must be held.
+func (q *Queue) snapshot(job *Job) Job {
+	c := *job
+	c.Errors = append([]string(nil), job.Errors...)
+	return c
+}
+
+// Run runs queued jobs until ctx is done.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case next := <-q.pending:
+			q.run(ctx, next)
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, next queued) {
+	job := next.job
+	q.mu.Lock()
+	started := time.Now().UTC()
+	job.Status, job.StartedAt = StatusRunning, &started
+	q.mu.Unlock()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return next.task(ctx, &Progress{q: q, job: job})
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	job.Status = StatusDone
+	if err != nil {
+		job.Status, job.Error = StatusFailed, err.Error()
+	}
+	slog.Info("Job finished", "job", job.ID, "kind", job.Kind, "status", job.Status, "took", finished.Sub(started).Round(time.Millisecond).String(),
+		"done", job.Done, "items", job.Total, "failed", job.Failed)
+
+	q.finished = append(q.finished, job.ID)
+	if len(q.finished) > keepFinished {
+		delete(q.jobs, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// wait polls the job with id until it is finished.
+func wait(t *testing.T, q *Queue, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := q.Get(id); job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(2, time.Millisecond)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	release := make(chan struct{})
+	first, err := q.Submit("slow", "alice", 1, func(ctx context.Context, p *Progress) error {
+		<-release
+		return p.Item(ctx, nil)
+	})
+	if err != nil || first.ID != "1" || first.Status != StatusQueued || first.CreatedBy != "alice" {
+		t.Fatalf("Submit = %+v, %v", first, err)
+	}
+	second, _ := q.Submit("items", "", 120, func(ctx context.Context, p *Progress) error {
+		for i := 0; i < 120; i++ {
+			var err error
+			if i%40 == 0 {
+				err = fmt.Errorf("item %d", i)
+			}
+			if err := p.Item(ctx, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if _, err := q.Submit("third", "", 0, nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit on a full queue = %v, want ErrQueueFull", err)
+	}
+
+	go q.Run(ctx)
+	close(release)
+	if job := wait(t, q, first.ID); job.Status != StatusDone || job.Done != 1 {
+		t.Errorf("first = %+v", job)
+	}
+	job := wait(t, q, second.ID)
+	if job.Status != StatusDone || job.Done != 120 || job.Failed != 3 || fmt.Sprint(job.Errors) != "[item 0 item 40 item 80]" {
+		t.Errorf("second = %+v", job)
+	}
+
+	failed, _ := q.Submit("broken", "", 0, func(context.Context, *Progress) error { panic("oops") })
+	if job := wait(t, q, failed.ID); job.Status != StatusFailed || job.Error != "panic: oops" {
+		t.Errorf("panicking job = %+v", job)
+	}
+	if _, ok := q.Get("missing"); ok {
+		t.Error("Get of an unknown job succeeded")
+	}
+}
+
+func TestQueueKeepsRecentJobs(t *testing.T) {
+	q := NewQueue(keepFinished+10, 0)
+	var last Job
+	for i := 0; i < keepFinished+10; i++ {
+		last, _ = q.Submit("noop", "", 0, func(context.Context, *Progress) error { return nil })
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go q.Run(ctx)
+	wait(t, q, last.ID)
+
+	if _, ok := q.Get("1"); ok {
+		t.Error("oldest job was kept")
+	}
+	if _, ok := q.Get("11"); !ok {
+		t.Error("a recent job was dropped")
+	}
+}
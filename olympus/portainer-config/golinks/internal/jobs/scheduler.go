@@ -0,0 +1,244 @@
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownJob is returned by RunNow for a name that is not registered.
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrBusy is returned by RunNow when the job is running or already
+	// due to run.
+	ErrBusy = errors.New("job is already running")
+)
+
+// Schedule says when a scheduled job runs. A zero Schedule runs the job
+// only on demand.
+type Schedule struct {
+	// Every runs the job right away and then at this interval.
+	Every time.Duration
+	// Next, if Every is zero, returns when the job runs next after now, or
+	// the zero time to run it only on demand.
+	Next func(now time.Time) time.Time
+	// Label describes Next in the job listing, e.g. "weekly".
+	Label string
+}
+
+func (sc Schedule) String() string {
+	switch {
+	case sc.Every > 0:
+		return "every " + sc.Every.String()
+	case sc.Next != nil && sc.Label != "":
+		return sc.Label
+	case sc.Next != nil:
+		return "scheduled"
+	}
+	return "manual"
+}
+
+// Func is the work of a scheduled job.
+type Func func(ctx context.Context) error
+
+// Scheduled is the state of a registered job.
+type Scheduled struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Running      bool       `json:"running"`
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	// LastError is why the last run failed, empty if it succeeded.
+	LastError string     `json:"last_error,omitempty"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+}
+
+type entry struct {
+	schedule Schedule
+	fn       Func
+	// trigger holds a pending "run now" request.
+	trigger chan struct{}
+	state   Scheduled
+}
+
+// Scheduler runs registered jobs on their schedules, or on demand, and
+// keeps the outcome of their last run. Each job runs in its own goroutine,
+// so a slow job does not delay the others, and never overlaps itself.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*entry
+}
+
+// NewScheduler creates an empty Scheduler.
+func NewScheduler() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*entry)}
+}
+
+// Register adds the job name, run by fn on schedule. It must be called
+// before Run; names must be unique and not numeric, which would be taken
+// for bulk jobs.
+func (s *Scheduler) Register(name string, schedule Schedule, fn Func) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		panic(fmt.Sprintf("jobs: %s registered twice", name))
+	}
+	s.jobs[name] = &entry{
+		schedule: schedule,
+		fn:       fn,
+		trigger:  make(chan struct{}, 1),
+		state:    Scheduled{Name: name, Schedule: schedule.String()},
+	}
+}
+
+// List returns the state of every registered job, by name.
+func (s *Scheduler) List() []Scheduled {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Scheduled, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		list = append(list, e.state)
+	}
+	slices.SortFunc(list, func(a, b Scheduled) int { return cmp.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Get returns the state of the job name.
+func (s *Scheduler) Get(name string) (Scheduled, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return Scheduled{}, false
+	}
+	return e.state, true
+}
+
+// RunNow asks for the job name to run as soon as possible, outside its
+// schedule. It returns ErrUnknownJob or ErrBusy rather than queueing a
+// second run.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if e.state.Running {
+		return ErrBusy
+	}
+	select {
+	case e.trigger <- struct{}{}:
+		return nil
+	default:
+		return ErrBusy
+	}
+}
+
+// Run runs the registered jobs until ctx is done, and returns once the
+// running ones have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	entries := make([]*entry, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		entries = append(entries, e)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop runs e whenever it is due or triggered.
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	next := time.Now()
+	if e.schedule.Every <= 0 {
+		next = s.nextRun(e, time.Now())
+	}
+	for {
+		s.mu.Lock()
+		e.state.NextRun = nil
+		if !next.IsZero() {
+			at := next.UTC()
+			e.state.NextRun = &at
+		}
+		s.mu.Unlock()
+
+		// Without a next run, the nil channel waits for a trigger only
+		var timer *time.Timer
+		var due <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-due:
+		case <-e.trigger:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.run(ctx, e)
+		next = s.nextRun(e, time.Now())
+	}
+}
+
+func (s *Scheduler) nextRun(e *entry, now time.Time) time.Time {
+	switch {
+	case e.schedule.Every > 0:
+		return now.Add(e.schedule.Every)
+	case e.schedule.Next != nil:
+		return e.schedule.Next(now)
+	}
+	return time.Time{}
+}
+
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	s.mu.Lock()
+	e.state.Running = true
+	s.mu.Unlock()
+
+	started := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return e.fn(ctx)
+	}()
+	took := time.Since(started)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at := started.UTC()
+	e.state.Running = false
+	e.state.Runs++
+	e.state.LastRun = &at
+	e.state.LastDuration = took.Round(time.Millisecond).String()
+	e.state.LastError = ""
+	if err != nil && ctx.Err() == nil {
+		e.state.Failures++
+		e.state.LastError = err.Error()
+		slog.Error("Job failed", "job", e.state.Name, "took", e.state.LastDuration, "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls the scheduled job name until done reports true.
+func waitFor(t *testing.T, s *Scheduler, name string, done func(Scheduled) bool) Scheduled {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _ := s.Get(name); done(job) {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	job, _ := s.Get(name)
+	t.Fatalf("job %s: condition not met, state %+v", name, job)
+	return job
+}
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler()
+	var ticks atomic.Int32
+	s.Register("tick", Schedule{Every: 5 * time.Millisecond}, func(context.Context) error {
+		ticks.Add(1)
+		return nil
+	})
+	s.Register("broken", Schedule{}, func(context.Context) error { return errors.New("disk full") })
+	release := make(chan struct{})
+	s.Register("slow", Schedule{Next: func(now time.Time) time.Time { return now.Add(time.Hour) }, Label: "hourly"}, func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	list := s.List()
+	if len(list) != 3 || list[0].Name != "broken" || list[0].Schedule != "manual" || list[1].Schedule != "hourly" || list[2].Schedule != "every 5ms" {
+		t.Fatalf("List = %+v", list)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(stopped)
+	}()
+
+	// Interval jobs run right away and then repeatedly
+	waitFor(t, s, "tick", func(j Scheduled) bool { return j.Runs >= 3 && j.LastRun != nil && j.NextRun != nil })
+
+	// Manual jobs only run on demand
+	if job, _ := s.Get("broken"); job.Runs != 0 || job.NextRun != nil {
+		t.Errorf("manual job ran on its own: %+v", job)
+	}
+	if err := s.RunNow("broken"); err != nil {
+		t.Fatalf("RunNow = %v", err)
+	}
+	job := waitFor(t, s, "broken", func(j Scheduled) bool { return j.Runs == 1 })
+	if job.Failures != 1 || job.LastError != "disk full" || job.LastDuration == "" {
+		t.Errorf("failed job = %+v", job)
+	}
+
+	// A running job is not queued again
+	s.RunNow("slow")
+	waitFor(t, s, "slow", func(j Scheduled) bool { return j.Running })
+	if err := s.RunNow("slow"); !errors.Is(err, ErrBusy) {
+		t.Errorf("RunNow of a running job = %v, want ErrBusy", err)
+	}
+	close(release)
+	waitFor(t, s, "slow", func(j Scheduled) bool { return j.Runs == 1 && !j.Running })
+
+	if err := s.RunNow("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow of an unknown job = %v, want ErrUnknownJob", err)
+	}
+
+	stop()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx was done")
+	}
+}
@@ -0,0 +1,434 @@
+// Package linksync keeps the links of two golinks instances in step both
+// ways, such as one at home and one at a second place, each usable while
+// the other is out of reach. The later change of a link wins; changes to
+// the same link on both sides since they last synced are reported as
+// conflicts.
+package linksync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+// defaultTimeout bounds one request to the peer.
+const defaultTimeout = time.Minute
+
+// maxConflicts is how many conflicts are kept, the newest.
+const maxConflicts = 100
+
+// Conflict reasons, as seen from this instance.
+const (
+	ChangedBoth   = "changed on both"
+	RemovedOnPeer = "changed here, removed on the peer"
+	RemovedHere   = "removed here, changed on the peer"
+)
+
+// Config names the peer and how to log in to it.
+type Config struct {
+	// Peer is the base URL of the other instance, such as
+	// https://go.cabin.example.com.
+	Peer string
+	// User and Pass are an admin login on the peer, to download its
+	// export.
+	User, Pass string
+	// Token is the peer's SYNC_TOKEN, sent to push changes there.
+	Token string
+	// Interval is how often the links are synced.
+	Interval time.Duration
+	// StatePath keeps what the instances last agreed on, and the
+	// conflicts, across restarts.
+	StatePath string
+	// Client talks to the peer; one with a one-minute timeout if nil.
+	Client *http.Client
+}
+
+// Enabled reports whether a peer is configured.
+func (c Config) Enabled() bool {
+	return c.Peer != ""
+}
+
+// state is what a Syncer keeps at StatePath.
+type state struct {
+	Peer string `json:"peer"`
+	// Base is the UpdatedAt of every link as both instances had it after
+	// the last sync. A link missing from one side that is in Base was
+	// removed there; one that is not was added on the other.
+	Base map[string]time.Time `json:"base"`
+	// Aliases is the target of every alias both instances had after the
+	// last sync, so a missing alias is told from a new one as links are.
+	Aliases   map[string]string      `json:"aliases"`
+	Conflicts []httpapi.SyncConflict `json:"conflicts"`
+}
+
+// Syncer syncs the links of a store with those of the peer.
+type Syncer struct {
+	cfg   Config
+	store store.Store
+
+	mu    sync.Mutex
+	state state
+}
+
+// New returns a Syncer for st, loading the state kept at cfg.StatePath. The
+// state of another peer is dropped, so the first sync with a new peer
+// merges the links of both.
+func New(cfg Config, st store.Store) (*Syncer, error) {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	s := &Syncer{cfg: cfg, store: st}
+	data, err := os.ReadFile(cfg.StatePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("reading sync state %s: %w", cfg.StatePath, err)
+		}
+	}
+	if s.state.Peer != cfg.Peer {
+		s.state = state{Peer: cfg.Peer}
+	}
+	if s.state.Base == nil {
+		s.state.Base = map[string]time.Time{}
+	}
+	if s.state.Aliases == nil {
+		s.state.Aliases = map[string]string{}
+	}
+	return s, nil
+}
+
+// Conflicts returns the conflicts found so far, newest first.
+func (s *Syncer) Conflicts() []httpapi.SyncConflict {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conflicts := slices.Clone(s.state.Conflicts)
+	slices.Reverse(conflicts)
+	if conflicts == nil {
+		conflicts = []httpapi.SyncConflict{}
+	}
+	return conflicts
+}
+
+// Sync compares the links here and on the peer with the base of the last
+// sync, makes the changes each side is missing on the other, and saves the
+// new base. Changes rejected because a link changed meanwhile are left to
+// the next sync. Aliases are synced the same way once the links are in
+// place; collections are not synced.
+func (s *Syncer) Sync(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export, err := s.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("download from %s: %w", s.cfg.Peer, err)
+	}
+	peer := make(map[string]store.Link, len(export.Links))
+	for _, link := range export.Links {
+		peer[link.Slug] = link
+	}
+	local := map[string]store.Link{}
+	err = s.store.EachLink(ctx, func(link store.Link) error {
+		local[link.Slug] = link
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slugs := make(map[string]bool, len(local)+len(peer)+len(s.state.Base))
+	for _, m := range []map[string]store.Link{local, peer} {
+		for slug := range m {
+			slugs[slug] = true
+		}
+	}
+	for slug := range s.state.Base {
+		slugs[slug] = true
+	}
+
+	// agreed is the new base of every slug both sides will have the same,
+	// nil for removed; it only takes effect once the change is made
+	agreed := map[string]*time.Time{}
+	var here, there []httpapi.SyncChange
+	now := time.Now().UTC()
+	for slug := range slugs {
+		l, inLocal := local[slug]
+		p, inPeer := peer[slug]
+		base, inBase := s.state.Base[slug]
+		localChanged := inLocal && (!inBase || !sameTime(l.UpdatedAt, base))
+		peerChanged := inPeer && (!inBase || !sameTime(p.UpdatedAt, base))
+
+		switch {
+		case !inLocal && !inPeer:
+			agreed[slug] = nil
+		case inLocal && inPeer && sameTime(l.UpdatedAt, p.UpdatedAt):
+			agreed[slug] = &l.UpdatedAt
+		case inLocal && inPeer:
+			keepLocal := l.UpdatedAt.After(p.UpdatedAt)
+			if localChanged && peerChanged && !sameSettings(l, p) {
+				s.conflict(httpapi.SyncConflict{Slug: slug, At: now, Reason: ChangedBoth, Kept: kept(keepLocal), Local: &l, Peer: &p})
+			}
+			if keepLocal {
+				there = append(there, httpapi.SyncChange{Slug: slug, Link: &l, Expect: &p.UpdatedAt})
+				agreed[slug] = &l.UpdatedAt
+			} else {
+				here = append(here, httpapi.SyncChange{Slug: slug, Link: &p, Expect: &l.UpdatedAt})
+				agreed[slug] = &p.UpdatedAt
+			}
+		case inLocal && inBase && !localChanged:
+			// Removed on the peer
+			here = append(here, httpapi.SyncChange{Slug: slug, Expect: &l.UpdatedAt})
+			agreed[slug] = nil
+		case inLocal:
+			if inBase {
+				s.conflict(httpapi.SyncConflict{Slug: slug, At: now, Reason: RemovedOnPeer, Kept: kept(true), Local: &l})
+			}
+			there = append(there, httpapi.SyncChange{Slug: slug, Link: &l})
+			agreed[slug] = &l.UpdatedAt
+		case inBase && !peerChanged:
+			// Removed here
+			there = append(there, httpapi.SyncChange{Slug: slug, Expect: &p.UpdatedAt})
+			agreed[slug] = nil
+		default:
+			if inBase {
+				s.conflict(httpapi.SyncConflict{Slug: slug, At: now, Reason: RemovedHere, Kept: kept(false), Peer: &p})
+			}
+			here = append(here, httpapi.SyncChange{Slug: slug, Link: &p})
+			agreed[slug] = &p.UpdatedAt
+		}
+	}
+
+	// A peer of a release without aliases exports none, which must not
+	// read as all of them removed there
+	var agreedAliases map[string]string
+	var aliasesHere, aliasesThere []httpapi.SyncAlias
+	if export.Aliases != nil {
+		localAliases, err := s.store.ListAliases(ctx)
+		if err != nil {
+			return err
+		}
+		agreedAliases, aliasesHere, aliasesThere = s.mergeAliases(localAliases, export.Aliases)
+	}
+
+	rejected, rejectedAliases := map[string]bool{}, map[string]bool{}
+	if len(here)+len(aliasesHere) > 0 {
+		result, err := httpapi.ApplySync(ctx, s.store, httpapi.SyncRequest{Changes: here, Aliases: aliasesHere})
+		if err != nil {
+			return err
+		}
+		for _, slug := range result.Rejected {
+			rejected[slug] = true
+		}
+		for _, slug := range result.RejectedAliases {
+			rejectedAliases[slug] = true
+		}
+	}
+	if len(there)+len(aliasesThere) > 0 {
+		result, err := s.push(ctx, httpapi.SyncRequest{Changes: there, Aliases: aliasesThere})
+		if err != nil {
+			return fmt.Errorf("send to %s: %w", s.cfg.Peer, err)
+		}
+		for _, slug := range result.Rejected {
+			rejected[slug] = true
+		}
+		for _, slug := range result.RejectedAliases {
+			rejectedAliases[slug] = true
+		}
+	}
+
+	for slug, at := range agreed {
+		switch {
+		case rejected[slug]:
+		case at == nil:
+			delete(s.state.Base, slug)
+		default:
+			s.state.Base[slug] = *at
+		}
+	}
+	for slug, target := range agreedAliases {
+		switch {
+		case rejectedAliases[slug]:
+		case target == "":
+			delete(s.state.Aliases, slug)
+		default:
+			s.state.Aliases[slug] = target
+		}
+	}
+	if err := s.save(); err != nil {
+		return fmt.Errorf("save sync state: %w", err)
+	}
+	if n := len(here) + len(there) + len(aliasesHere) + len(aliasesThere); n > 0 {
+		slog.Info("Synced", "peer", s.cfg.Peer, "here", len(here)+len(aliasesHere), "there", len(there)+len(aliasesThere), "retry", len(rejected)+len(rejectedAliases))
+	}
+	return nil
+}
+
+// mergeAliases compares the aliases here and on the peer with the base of
+// the last sync. It returns the target both sides will agree on for every
+// alias, empty for removed, and the changes to make on each side. Where
+// both sides changed an alias, a target wins over a removal, and of two
+// targets the first in order, so both instances settle on the same one.
+func (s *Syncer) mergeAliases(local, peer []store.Alias) (agreed map[string]string, here, there []httpapi.SyncAlias) {
+	localTargets := make(map[string]string, len(local))
+	for _, a := range local {
+		localTargets[a.Slug] = a.Target
+	}
+	peerTargets := make(map[string]string, len(peer))
+	for _, a := range peer {
+		peerTargets[a.Slug] = a.Target
+	}
+	slugs := maps.Clone(s.state.Aliases)
+	maps.Copy(slugs, localTargets)
+	maps.Copy(slugs, peerTargets)
+
+	agreed = make(map[string]string, len(slugs))
+	for slug := range slugs {
+		l, p, base := localTargets[slug], peerTargets[slug], s.state.Aliases[slug]
+		target := l
+		switch {
+		case l == p:
+		case l == base:
+			target = p
+		case p == base:
+		case l == "" || (p != "" && p < l):
+			target = p
+		}
+		agreed[slug] = target
+		if l != target {
+			here = append(here, httpapi.SyncAlias{Slug: slug, Target: target})
+		}
+		if p != target {
+			there = append(there, httpapi.SyncAlias{Slug: slug, Target: target})
+		}
+	}
+	return agreed, here, there
+}
+
+// conflict records c, dropping the oldest beyond maxConflicts.
+func (s *Syncer) conflict(c httpapi.SyncConflict) {
+	slog.Warn("Sync conflict", "slug", c.Slug, "reason", c.Reason, "kept", c.Kept)
+	s.state.Conflicts = append(s.state.Conflicts, c)
+	if n := len(s.state.Conflicts); n > maxConflicts {
+		s.state.Conflicts = slices.Delete(s.state.Conflicts, 0, n-maxConflicts)
+	}
+}
+
+// fetch downloads the peer's JSON export.
+func (s *Syncer) fetch(ctx context.Context) (httpapi.Export, error) {
+	var export httpapi.Export
+	u := "/admin/export?" + url.Values{"format": {httpapi.ExportJSON}}.Encode()
+	if err := s.do(ctx, http.MethodGet, u, nil, &export); err != nil {
+		return httpapi.Export{}, err
+	}
+	// Anything else must not read as an instance without links
+	if export.Links == nil || export.ExportedAt.IsZero() {
+		return httpapi.Export{}, errors.New("not a golinks export")
+	}
+	return export, nil
+}
+
+// push sends changes to the peer.
+func (s *Syncer) push(ctx context.Context, req httpapi.SyncRequest) (httpapi.SyncResult, error) {
+	var result httpapi.SyncResult
+	err := s.do(ctx, http.MethodPost, "/admin/sync", req, &result)
+	return result, err
+}
+
+// do sends a request with body as JSON to path on the peer and decodes
+// its JSON response into out. Changes are pushed with the Token, anything
+// else with the admin login.
+func (s *Syncer) do(ctx context.Context, method, path string, body, out any) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.cfg.Peer, "/")+path, &payload)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case method == http.MethodPost:
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	case s.cfg.User != "":
+		req.SetBasicAuth(s.cfg.User, s.cfg.Pass)
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// save writes the state to StatePath, replacing the file atomically.
+func (s *Syncer) save() error {
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.cfg.StatePath), ".sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.cfg.StatePath)
+}
+
+// kept names the side whose version of a conflict was kept.
+func kept(local bool) string {
+	if local {
+		return "local"
+	}
+	return "peer"
+}
+
+// sameTime reports whether a and b are the same update time. Databases
+// keep times at different precisions, so they are compared to the
+// millisecond.
+func sameTime(a, b time.Time) bool {
+	return a.Truncate(time.Millisecond).Equal(b.Truncate(time.Millisecond))
+}
+
+// sameSettings reports whether two versions of a link differ only in their
+// clicks and times, as when the same change was made on both sides.
+func sameSettings(a, b store.Link) bool {
+	sameReview := (a.ReviewAt == nil && b.ReviewAt == nil) || (a.ReviewAt != nil && b.ReviewAt != nil && a.ReviewAt.Equal(*b.ReviewAt))
+	return a.URL == b.URL && a.Status == b.Status && a.CreatedBy == b.CreatedBy && a.ApprovedBy == b.ApprovedBy &&
+		a.Public == b.Public && sameReview && a.ReviewMonths == b.ReviewMonths &&
+		(len(a.Access) == 0 && len(b.Access) == 0 || reflect.DeepEqual(a.Access, b.Access)) &&
+		a.Pin == b.Pin && a.HitBudget == b.HitBudget && a.Failover == b.Failover && a.Referrer == b.Referrer &&
+		maps.Equal(a.Fields, b.Fields)
+}
@@ -0,0 +1,205 @@
+package linksync
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	cabin := store.NewMemory()
+	peer := httptest.NewServer(httpapi.New(httpapi.Config{Admins: map[string]string{"sync": "pw"}, SyncToken: "s3cret"}, cabin, httpapi.Pages{}).Handler())
+	t.Cleanup(peer.Close)
+
+	home, err := store.OpenSQLite(filepath.Join(t.TempDir(), "links.db"), store.SQLiteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { home.Close() })
+	cfg := Config{Peer: peer.URL, User: "sync", Pass: "pw", Token: "s3cret", StatePath: filepath.Join(t.TempDir(), "sync.json")}
+	s, err := New(cfg, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urlOf := func(st store.Store, slug string) string {
+		t.Helper()
+		link, err := st.GetLink(ctx, slug)
+		if err != nil {
+			return ""
+		}
+		return link.URL
+	}
+	aliasesOf := func(st store.Store) []store.Alias {
+		t.Helper()
+		aliases, err := st.ListAliases(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return aliases
+	}
+	sync := func() {
+		t.Helper()
+		// Edits made right before a sync must be later than it
+		time.Sleep(2 * time.Millisecond)
+		if err := s.Sync(ctx); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// The first sync merges the links of both
+	home.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", CreatedBy: "alice"})
+	cabin.AddLink(ctx, store.Link{Slug: "boat", URL: "https://boat.example.com"})
+	home.SetAlias(ctx, store.Alias{Slug: "w", Target: "wiki"})
+	cabin.SetAlias(ctx, store.Alias{Slug: "b", Target: "boat"})
+	sync()
+	both := []store.Alias{{Slug: "b", Target: "boat"}, {Slug: "w", Target: "wiki"}}
+	if got := aliasesOf(home); !reflect.DeepEqual(got, both) {
+		t.Errorf("home aliases after first sync = %v, want %v", got, both)
+	}
+	if got := aliasesOf(cabin); !reflect.DeepEqual(got, both) {
+		t.Errorf("cabin aliases after first sync = %v, want %v", got, both)
+	}
+	if urlOf(cabin, "wiki") != "https://wiki.example.com" || urlOf(home, "boat") != "https://boat.example.com" {
+		t.Fatalf("after first sync: cabin wiki %q, home boat %q", urlOf(cabin, "wiki"), urlOf(home, "boat"))
+	}
+	if wiki, _ := cabin.GetLink(ctx, "wiki"); wiki.CreatedBy != "alice" {
+		t.Errorf("cabin wiki = %+v", wiki)
+	}
+
+	// Changes and removals on either side go across; clicks stay put
+	home.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: time.Now()}})
+	cabin.UpdateLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki2.example.com", CreatedBy: "bob"})
+	cabin.AddLink(ctx, store.Link{Slug: "new", URL: "https://new.example.com"})
+	home.RemoveLink(ctx, "boat")
+	home.RemoveAlias(ctx, "w")
+	cabin.SetAlias(ctx, store.Alias{Slug: "n", Target: "new"})
+	sync()
+	only := []store.Alias{{Slug: "n", Target: "new"}}
+	if got := aliasesOf(home); !reflect.DeepEqual(got, only) {
+		t.Errorf("home aliases = %v, want %v", got, only)
+	}
+	if got := aliasesOf(cabin); !reflect.DeepEqual(got, only) {
+		t.Errorf("cabin aliases = %v, want %v", got, only)
+	}
+	if wiki, _ := home.GetLink(ctx, "wiki"); wiki.URL != "https://wiki2.example.com" || wiki.CreatedBy != "bob" || wiki.Clicks != 1 {
+		t.Errorf("home wiki = %+v", wiki)
+	}
+	if urlOf(cabin, "boat") != "" || urlOf(home, "new") != "https://new.example.com" {
+		t.Errorf("cabin boat %q, home new %q", urlOf(cabin, "boat"), urlOf(home, "new"))
+	}
+	if c := s.Conflicts(); len(c) != 0 {
+		t.Errorf("conflicts = %+v", c)
+	}
+
+	// Changes to the same link on both sides: the later wins, the other
+	// is reported
+	home.SetPin(ctx, "wiki", 3)
+	time.Sleep(2 * time.Millisecond)
+	cabin.UpdateLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki3.example.com", CreatedBy: "carol"})
+	// A link removed here but changed there comes back
+	home.RemoveLink(ctx, "new")
+	cabin.SetPublic(ctx, "new", true)
+	sync()
+	if wiki, _ := home.GetLink(ctx, "wiki"); wiki.URL != "https://wiki3.example.com" || wiki.Pin != 0 {
+		t.Errorf("home wiki after conflict = %+v", wiki)
+	}
+	if link, err := home.GetLink(ctx, "new"); err != nil || !link.Public {
+		t.Errorf("home new = %+v, %v", link, err)
+	}
+	conflicts := s.Conflicts()
+	if len(conflicts) != 2 {
+		t.Fatalf("conflicts = %+v", conflicts)
+	}
+	for _, c := range conflicts {
+		switch c.Slug {
+		case "wiki":
+			if c.Reason != ChangedBoth || c.Kept != "peer" || c.Local == nil || c.Local.Pin != 3 || c.Peer == nil || c.Peer.URL != "https://wiki3.example.com" {
+				t.Errorf("wiki conflict = %+v", c)
+			}
+		case "new":
+			if c.Reason != RemovedHere || c.Kept != "peer" || c.Local != nil || c.Peer == nil {
+				t.Errorf("new conflict = %+v", c)
+			}
+		default:
+			t.Errorf("conflict = %+v", c)
+		}
+	}
+
+	// The base survives a restart: nothing is left to sync or report
+	s, err = New(cfg, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	if n := len(s.Conflicts()); n != 2 {
+		t.Errorf("%d conflicts after restart, want 2", n)
+	}
+	if urlOf(cabin, "wiki") != "https://wiki3.example.com" || urlOf(home, "new") == "" || urlOf(cabin, "boat") != "" {
+		t.Error("links changed by a sync after restart")
+	}
+
+	// A wrong login fails the sync and changes nothing
+	bad, _ := New(Config{Peer: peer.URL, User: "sync", Pass: "wrong", Token: "s3cret", StatePath: cfg.StatePath}, home)
+	if err := bad.Sync(ctx); err == nil {
+		t.Error("Sync with a wrong password succeeded")
+	}
+	// The admin login does not push changes without the token
+	home.AddLink(ctx, store.Link{Slug: "late", URL: "https://late.example.com"})
+	bad, _ = New(Config{Peer: peer.URL, User: "sync", Pass: "pw", Token: "wrong", StatePath: cfg.StatePath}, home)
+	if err := bad.Sync(ctx); err == nil || urlOf(cabin, "late") != "" {
+		t.Errorf("Sync with a wrong token = %v, cabin late %q", err, urlOf(cabin, "late"))
+	}
+}
+
+func TestNewPeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.json")
+	s, err := New(Config{Peer: "https://a.example.com", StatePath: path}, store.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.state.Base["wiki"] = time.Now()
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := New(Config{Peer: "https://a.example.com", StatePath: path}, store.NewMemory()); len(s.state.Base) != 1 {
+		t.Errorf("base of the same peer = %v", s.state.Base)
+	}
+	// What another peer had says nothing about this one
+	if s, _ := New(Config{Peer: "https://b.example.com", StatePath: path}, store.NewMemory()); len(s.state.Base) != 0 {
+		t.Errorf("base of another peer = %v", s.state.Base)
+	}
+}
+
+func TestMergeAliases(t *testing.T) {
+	s := &Syncer{state: state{Aliases: map[string]string{"same": "wiki", "gone": "wiki", "both": "wiki", "moved": "wiki"}}}
+	local := []store.Alias{{Slug: "same", Target: "wiki"}, {Slug: "both", Target: "mail"}, {Slug: "moved", Target: "docs"}, {Slug: "new", Target: "wiki"}}
+	peer := []store.Alias{{Slug: "same", Target: "wiki"}, {Slug: "gone", Target: "wiki"}, {Slug: "both", Target: "docs"}}
+	agreed, here, there := s.mergeAliases(local, peer)
+
+	// Changed on both sides: a target beats a removal, the first target
+	// beats the other
+	want := map[string]string{"same": "wiki", "gone": "", "both": "docs", "moved": "docs", "new": "wiki"}
+	if !reflect.DeepEqual(agreed, want) {
+		t.Errorf("agreed = %v, want %v", agreed, want)
+	}
+	sortAliases := func(a []httpapi.SyncAlias) []httpapi.SyncAlias {
+		slices.SortFunc(a, func(x, y httpapi.SyncAlias) int { return strings.Compare(x.Slug, y.Slug) })
+		return a
+	}
+	if want := []httpapi.SyncAlias{{Slug: "both", Target: "docs"}}; !reflect.DeepEqual(sortAliases(here), want) {
+		t.Errorf("here = %v, want %v", here, want)
+	}
+	if want := []httpapi.SyncAlias{{Slug: "gone"}, {Slug: "moved", Target: "docs"}, {Slug: "new", Target: "wiki"}}; !reflect.DeepEqual(sortAliases(there), want) {
+		t.Errorf("there = %v, want %v", there, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Package logging sets up the process-wide slog logger: its output format
+// and level, and the level check hot paths use to skip building log lines
+// that would be discarded.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// RequestIDHeader carries the ID of a request across proxies and the
+// golinks instances it reaches.
+const RequestIDHeader = "X-Request-Id"
+
+// Output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var current atomic.Int32
+
+// level is current for the slog handlers.
+var level = new(slog.LevelVar)
+
+func init() {
+	current.Store(int32(LevelInfo))
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error" (case-insensitive).
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func SetLevel(l Level) {
+	current.Store(int32(l))
+	level.Set(l.slog())
+}
+
+// slog returns the slog level of l.
+func (l Level) slog() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// ValidFormat reports whether format is FormatText or FormatJSON.
+func ValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// NewHandler returns a handler writing records to w in format, one per
+// line, dropping those below the level set with SetLevel. Records logged
+// with the context of a request carry its request_id.
+func NewHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatJSON {
+		return contextHandler{slog.NewJSONHandler(w, opts)}
+	}
+	return contextHandler{slog.NewTextHandler(w, opts)}
+}
+
+// Setup makes the default slog logger write to w in format. What is still
+// logged through the log package goes the same way, at info level.
+func Setup(w io.Writer, format string) {
+	slog.SetDefault(slog.New(NewHandler(w, format)))
+}
+
+// Enabled reports whether messages at level l should be logged. Hot paths
+// check it before formatting a log line.
+func Enabled(l Level) bool {
+	return int32(l) >= current.Load()
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the ID of the request it serves.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// lazyRequestID is a request ID made the first time it is asked for.
+type lazyRequestID struct {
+	once  sync.Once
+	id    string
+	newID func() string
+}
+
+// WithLazyRequestID returns ctx carrying a request ID that newID makes the
+// first time RequestID asks for it, so a request that is never logged or
+// passed on does not pay for one.
+func WithLazyRequestID(ctx context.Context, newID func() string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, &lazyRequestID{newID: newID})
+}
+
+// RequestID returns the request ID ctx carries, or "".
+func RequestID(ctx context.Context) string {
+	switch id := ctx.Value(requestIDKey{}).(type) {
+	case string:
+		return id
+	case *lazyRequestID:
+		id.once.Do(func() { id.id = id.newID() })
+		return id.id
+	}
+	return ""
+}
+
+// contextHandler adds the request ID of the context to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]Level{
+		"":        LevelInfo,
+		"debug":   LevelDebug,
+		"INFO":    LevelInfo,
+		"warning": LevelWarn,
+		" error ": LevelError,
+	}
+	for in, want := range tests {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud): expected error")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	defer SetLevel(LevelInfo)
+
+	SetLevel(LevelWarn)
+	if Enabled(LevelInfo) {
+		t.Error("info enabled at warn level")
+	}
+	if !Enabled(LevelError) {
+		t.Error("error disabled at warn level")
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	defer SetLevel(LevelInfo)
+	SetLevel(LevelWarn)
+
+	var buf bytes.Buffer
+	log := slog.New(NewHandler(&buf, FormatJSON))
+	log.Info("Redirect", "slug", "wiki")
+	log.WarnContext(WithRequestID(context.Background(), "5f0c9a61"), "Rate limited", "remote_addr", "192.0.2.7", "status", 429)
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("not one JSON record: %q", buf.String())
+	}
+	if rec["level"] != "WARN" || rec["msg"] != "Rate limited" || rec["remote_addr"] != "192.0.2.7" || rec["status"] != 429.0 || rec["request_id"] != "5f0c9a61" {
+		t.Errorf("record = %v", rec)
+	}
+
+	buf.Reset()
+	SetLevel(LevelDebug)
+	slog.New(NewHandler(&buf, FormatText)).Debug("Redirect", "slug", "wiki")
+	if line := buf.String(); !strings.Contains(line, "level=DEBUG") || !strings.Contains(line, "slug=wiki") {
+		t.Errorf("text line = %q", line)
+	}
+}
@@ -0,0 +1,175 @@
+// Package metrics exposes instance metrics in the Prometheus text format
+// and suggests alerting rules for them.
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golinks/internal/health"
+	"golinks/internal/httperr"
+	"golinks/internal/probe"
+	"golinks/internal/store"
+)
+
+// lookupBuckets are the upper bounds, in seconds, of the lookup latency
+// histogram.
+var lookupBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Lookup results, the result label of golinks_lookups_total.
+const (
+	resultFound    = "found"
+	resultNotFound = "not_found"
+	resultError    = "error"
+)
+
+// Metrics counts slug lookups and serves them, with gauges read from the
+// store and the health checker at scrape time.
+type Metrics struct {
+	store store.Store
+	// checker is nil when link health checks are disabled.
+	checker *health.Checker
+	// prober is nil without an uptime probe.
+	prober *probe.Prober
+
+	found, notFound, failed atomic.Uint64
+	// buckets[i] counts lookups that took at most lookupBuckets[i] but
+	// more than the previous bound; the last entry counts slower ones.
+	buckets  []atomic.Uint64
+	sumNanos atomic.Uint64
+}
+
+// New creates Metrics for the links in st. Link health is only reported if
+// checker is set and runs periodically.
+func New(st store.Store, checker *health.Checker) *Metrics {
+	if checker != nil && checker.Interval() <= 0 {
+		checker = nil
+	}
+	return &Metrics{store: st, checker: checker, buckets: make([]atomic.Uint64, len(lookupBuckets)+1)}
+}
+
+// SetProber reports the results of p as well.
+func (m *Metrics) SetProber(p *probe.Prober) {
+	m.prober = p
+}
+
+// ObserveLookup records one slug lookup that took d and failed with err,
+// which is nil for a link that was found.
+func (m *Metrics) ObserveLookup(d time.Duration, err error) {
+	switch {
+	case err == nil:
+		m.found.Add(1)
+	case errors.Is(err, store.ErrNotFound):
+		m.notFound.Add(1)
+	default:
+		m.failed.Add(1)
+	}
+	i := 0
+	for i < len(lookupBuckets) && d.Seconds() > lookupBuckets[i] {
+		i++
+	}
+	m.buckets[i].Add(1)
+	m.sumNanos.Add(uint64(max(d, 0)))
+}
+
+// ServeHTTP writes every metric in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Gauges come first so a store error can still be reported as a 5xx
+	links, err := m.store.CountLinks(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting links", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	var sum health.Summary
+	if m.checker != nil {
+		if sum, _, err = m.checker.Results(r.Context()); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing link health", "error", err)
+			httperr.Write(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	header(bw, "golinks_lookups_total", "counter", "Slug lookups by result.")
+	fmt.Fprintf(bw, "golinks_lookups_total{result=%q} %d\n", resultFound, m.found.Load())
+	fmt.Fprintf(bw, "golinks_lookups_total{result=%q} %d\n", resultNotFound, m.notFound.Load())
+	fmt.Fprintf(bw, "golinks_lookups_total{result=%q} %d\n", resultError, m.failed.Load())
+
+	header(bw, "golinks_lookup_duration_seconds", "histogram", "Time taken to look up a slug in the database.")
+	var count uint64
+	for i, bound := range lookupBuckets {
+		count += m.buckets[i].Load()
+		fmt.Fprintf(bw, "golinks_lookup_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), count)
+	}
+	count += m.buckets[len(lookupBuckets)].Load()
+	fmt.Fprintf(bw, "golinks_lookup_duration_seconds_bucket{le=\"+Inf\"} %d\n", count)
+	fmt.Fprintf(bw, "golinks_lookup_duration_seconds_sum %s\n", strconv.FormatFloat(time.Duration(m.sumNanos.Load()).Seconds(), 'g', -1, 64))
+	fmt.Fprintf(bw, "golinks_lookup_duration_seconds_count %d\n", count)
+
+	header(bw, "golinks_links", "gauge", "Number of links.")
+	fmt.Fprintf(bw, "golinks_links %d\n", links)
+
+	if m.checker != nil {
+		header(bw, "golinks_link_health", "gauge", "Active links by the result of their latest health check.")
+		fmt.Fprintf(bw, "golinks_link_health{status=%q} %d\n", health.Healthy, sum.Healthy)
+		fmt.Fprintf(bw, "golinks_link_health{status=%q} %d\n", health.Broken, sum.Broken)
+		fmt.Fprintf(bw, "golinks_link_health{status=%q} %d\n", health.Unknown, sum.Unknown)
+		header(bw, "golinks_health_last_check_timestamp_seconds", "gauge", "Unix time the last health check finished, 0 before the first.")
+		var last int64
+		if !sum.LastCheck.IsZero() {
+			last = sum.LastCheck.Unix()
+		}
+		fmt.Fprintf(bw, "golinks_health_last_check_timestamp_seconds %d\n", last)
+	}
+
+	if c, ok := m.store.(interface{ CacheStats() store.CacheStats }); ok {
+		stats := c.CacheStats()
+		header(bw, "golinks_link_cache_lookups_total", "counter", "Lookups of the in-memory link cache by result.")
+		fmt.Fprintf(bw, "golinks_link_cache_lookups_total{result=\"hit\"} %d\n", stats.Hits)
+		fmt.Fprintf(bw, "golinks_link_cache_lookups_total{result=\"miss\"} %d\n", stats.Misses)
+		header(bw, "golinks_link_cache_entries", "gauge", "Links in the in-memory link cache.")
+		fmt.Fprintf(bw, "golinks_link_cache_entries %d\n", stats.Size)
+		header(bw, "golinks_link_cache_not_found_entries", "gauge", "Slugs without a link in the in-memory link cache.")
+		fmt.Fprintf(bw, "golinks_link_cache_not_found_entries %d\n", stats.NotFound)
+	}
+
+	if m.prober != nil {
+		last := m.prober.Result()
+		probes, failed := m.prober.Counts()
+		up, at := 0, int64(0)
+		if last.OK {
+			up = 1
+		}
+		if !last.CheckedAt.IsZero() {
+			at = last.CheckedAt.Unix()
+		}
+		header(bw, "golinks_probe_success", "gauge", "Whether the latest uptime probe of the canary slug redirected.")
+		fmt.Fprintf(bw, "golinks_probe_success %d\n", up)
+		header(bw, "golinks_probe_duration_seconds", "gauge", "Time the latest uptime probe took.")
+		fmt.Fprintf(bw, "golinks_probe_duration_seconds %s\n", strconv.FormatFloat(last.Duration.Seconds(), 'g', -1, 64))
+		header(bw, "golinks_probe_last_timestamp_seconds", "gauge", "Unix time of the latest uptime probe, 0 before the first.")
+		fmt.Fprintf(bw, "golinks_probe_last_timestamp_seconds %d\n", at)
+		header(bw, "golinks_probes_total", "counter", "Uptime probes by result.")
+		fmt.Fprintf(bw, "golinks_probes_total{result=\"ok\"} %d\n", probes-failed)
+		fmt.Fprintf(bw, "golinks_probes_total{result=\"failed\"} %d\n", failed)
+	}
+}
+
+func header(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/health"
+	"golinks/internal/probe"
+	"golinks/internal/store"
+)
+
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	m := New(st, nil)
+
+	m.ObserveLookup(300*time.Microsecond, nil)
+	m.ObserveLookup(2*time.Millisecond, store.ErrNotFound)
+	m.ObserveLookup(10*time.Second, errors.New("disk on fire"))
+
+	rec := get(t, m, "/admin/metrics")
+	body := rec.Body.String()
+	for _, want := range []string{
+		`golinks_lookups_total{result="found"} 1`,
+		`golinks_lookups_total{result="not_found"} 1`,
+		`golinks_lookups_total{result="error"} 1`,
+		`golinks_lookup_duration_seconds_bucket{le="0.0005"} 1`,
+		`golinks_lookup_duration_seconds_bucket{le="0.0025"} 2`,
+		`golinks_lookup_duration_seconds_bucket{le="5"} 2`,
+		`golinks_lookup_duration_seconds_bucket{le="+Inf"} 3`,
+		`golinks_lookup_duration_seconds_sum 10.0023`,
+		`golinks_lookup_duration_seconds_count 3`,
+		`golinks_links 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "golinks_link_health") {
+		t.Error("health metrics reported with health checks disabled")
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	m = New(st, health.NewChecker(st, time.Hour))
+	if body := get(t, m, "/admin/metrics").Body.String(); !strings.Contains(body, `golinks_link_health{status="unknown"} 1`) ||
+		!strings.Contains(body, "golinks_health_last_check_timestamp_seconds 0\n") {
+		t.Errorf("health metrics missing:\n%s", body)
+	}
+
+	cache := store.NewLRUCache(st, store.LRUOptions{})
+	cache.GetLink(ctx, "wiki")
+	cache.GetLink(ctx, "wiki")
+	if body := get(t, New(cache, nil), "/admin/metrics").Body.String(); !strings.Contains(body, `golinks_link_cache_lookups_total{result="hit"} 1`+"\n") ||
+		!strings.Contains(body, "golinks_link_cache_entries 1\n") {
+		t.Errorf("link cache metrics:\n%s", body)
+	}
+
+	canary := httptest.NewServer(http.RedirectHandler("https://wiki.example.com", http.StatusFound))
+	defer canary.Close()
+	p := probe.New(probe.Config{Slug: "wiki", Target: canary.URL, Interval: time.Minute})
+	m = New(st, nil)
+	m.SetProber(p)
+	if body := get(t, m, "/admin/metrics").Body.String(); !strings.Contains(body, "golinks_probe_success 0\n") ||
+		!strings.Contains(body, "golinks_probe_last_timestamp_seconds 0\n") {
+		t.Errorf("probe metrics before the first probe:\n%s", body)
+	}
+	p.Probe(ctx)
+	if body := get(t, m, "/admin/metrics").Body.String(); !strings.Contains(body, "golinks_probe_success 1\n") ||
+		!strings.Contains(body, `golinks_probes_total{result="ok"} 1`+"\n") {
+		t.Errorf("probe metrics:\n%s", body)
+	}
+	if got := m.Rules("golinks")[0].Rules; got[len(got)-1].Alert != "GolinksRedirectsBroken" || got[len(got)-1].For != "2m" {
+		t.Errorf("probe rule = %+v", got[len(got)-1])
+	}
+}
+
+func TestRules(t *testing.T) {
+	st := store.NewMemory()
+	alerts := func(m *Metrics) string {
+		var names []string
+		for _, r := range m.Rules("golinks")[0].Rules {
+			names = append(names, r.Alert)
+		}
+		return strings.Join(names, ",")
+	}
+
+	base := "GolinksDown,GolinksHighNotFoundRate,GolinksLookupErrors,GolinksSlowLookups"
+	if got := alerts(New(st, nil)); got != base {
+		t.Errorf("rules without health checks = %s", got)
+	}
+	// A checker that never runs is the same as none
+	if got := alerts(New(st, health.NewChecker(st, 0))); got != base {
+		t.Errorf("rules with idle checker = %s", got)
+	}
+	m := New(st, health.NewChecker(st, 6*time.Hour))
+	if got := alerts(m); got != base+",GolinksBrokenLinks,GolinksHealthCheckStale" {
+		t.Errorf("rules with health checks = %s", got)
+	}
+	broken := m.Rules("golinks")[0].Rules[4]
+	if broken.For != "18h" {
+		t.Errorf("broken links for = %q, want 18h", broken.For)
+	}
+
+	rec := get(t, m.RulesHandler(), "/admin/metrics/rules?job=go-links")
+	body := rec.Body.String()
+	for _, want := range []string{
+		"groups:\n  - name: 'golinks'\n    rules:\n      - alert: 'GolinksDown'\n",
+		`        expr: 'up{job="go-links"} == 0'` + "\n        for: 5m\n        labels:\n          severity: 'critical'\n",
+		"          summary: 'golinks is down'\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("rule file missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = get(t, m.RulesHandler(), "/admin/metrics/rules?format=json")
+	var file struct{ Groups []RuleGroup }
+	if err := json.NewDecoder(rec.Body).Decode(&file); err != nil || len(file.Groups) != 1 || len(file.Groups[0].Rules) != 6 {
+		t.Errorf("JSON rules = %+v, %v", file, err)
+	}
+	if !strings.Contains(file.Groups[0].Rules[0].Expr, `job="golinks"`) {
+		t.Errorf("default job missing from %q", file.Groups[0].Rules[0].Expr)
+	}
+
+	if rec := get(t, m.RulesHandler(), `/admin/metrics/rules?job=a"b`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid job: status = %d", rec.Code)
+	}
+}
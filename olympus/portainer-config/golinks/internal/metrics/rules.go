@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rule is one Prometheus alerting rule.
+type Rule struct {
+	Alert       string            `json:"alert"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// RuleGroup is a named group of rules, as in a Prometheus rule file.
+type RuleGroup struct {
+	Name  string `json:"name"`
+	Rules []Rule `json:"rules"`
+}
+
+// labelValue matches the job names Rules accepts, keeping generated
+// expressions free of quoting surprises.
+var labelValue = regexp.MustCompile(`^[A-Za-z0-9_.:/-]+$`)
+
+// Rules suggests alerting rules for the metrics of this instance, scraped
+// under job. Rules for optional features are only included when the
+// feature is enabled.
+func (m *Metrics) Rules(job string) []RuleGroup {
+	sel := fmt.Sprintf("job=%q", job)
+	rules := []Rule{
+		{
+			Alert:       "GolinksDown",
+			Expr:        fmt.Sprintf("up{%s} == 0", sel),
+			For:         "5m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "golinks is down", "description": "Prometheus cannot scrape {{ $labels.instance }}; go links do not resolve."},
+		},
+		{
+			Alert:       "GolinksHighNotFoundRate",
+			Expr:        fmt.Sprintf(`sum(rate(golinks_lookups_total{%[1]s,result="not_found"}[15m])) / sum(rate(golinks_lookups_total{%[1]s}[15m])) > 0.25 and sum(rate(golinks_lookups_total{%[1]s}[15m])) > 0.01`, sel),
+			For:         "15m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Many go links are not found", "description": "Over a quarter of lookups hit unknown slugs; a popular link may have been removed or renamed."},
+		},
+		{
+			Alert:       "GolinksLookupErrors",
+			Expr:        fmt.Sprintf(`sum(rate(golinks_lookups_total{%s,result="error"}[5m])) > 0`, sel),
+			For:         "5m",
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "Database lookups are failing", "description": "Slug lookups return errors; check the database volume and DB_QUERY_TIMEOUT."},
+		},
+		{
+			Alert:       "GolinksSlowLookups",
+			Expr:        fmt.Sprintf(`histogram_quantile(0.95, sum by (le) (rate(golinks_lookup_duration_seconds_bucket{%s}[10m]))) > 0.1`, sel),
+			For:         "15m",
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: map[string]string{"summary": "Database lookups are slow", "description": "The 95th percentile slug lookup takes over 100ms."},
+		},
+	}
+	if m.checker != nil {
+		// Three missed checks, so one slow run does not page anyone
+		stale := 3 * m.checker.Interval()
+		rules = append(rules,
+			Rule{
+				Alert:       "GolinksBrokenLinks",
+				Expr:        fmt.Sprintf(`golinks_link_health{%s,status="broken"} > 0`, sel),
+				For:         promDuration(stale),
+				Labels:      map[string]string{"severity": "info"},
+				Annotations: map[string]string{"summary": "Some go links are broken", "description": "{{ $value }} link destinations fail to load; see /admin/status/links."},
+			},
+			Rule{
+				Alert:       "GolinksHealthCheckStale",
+				Expr:        fmt.Sprintf(`time() - golinks_health_last_check_timestamp_seconds{%s} > %d`, sel, int64(stale.Seconds())),
+				For:         "10m",
+				Labels:      map[string]string{"severity": "warning"},
+				Annotations: map[string]string{"summary": "Link health checks stopped", "description": "No link health check has finished in three intervals."},
+			},
+		)
+	}
+	if m.prober != nil {
+		// Two failed probes in a row, so one slow answer does not page
+		// anyone
+		rules = append(rules, Rule{
+			Alert:       "GolinksRedirectsBroken",
+			Expr:        fmt.Sprintf(`golinks_probe_success{%s} == 0 and golinks_probe_last_timestamp_seconds{%[1]s} > 0`, sel),
+			For:         promDuration(2 * m.prober.Interval()),
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: map[string]string{"summary": "go links do not redirect", "description": "golinks is up but its canary slug does not redirect; see /healthz."},
+		})
+	}
+	return []RuleGroup{{Name: "golinks", Rules: rules}}
+}
+
+// promDuration formats d in Prometheus duration syntax, e.g. "18h".
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
+
+// RulesHandler serves the suggested rules as a Prometheus rule file, or as
+// JSON with ?format=json. ?job= names the scrape job, "golinks" by
+// default.
+func (m *Metrics) RulesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		job := r.URL.Query().Get("job")
+		if job == "" {
+			job = "golinks"
+		}
+		if !labelValue.MatchString(job) {
+			http.Error(w, "Invalid job name", http.StatusBadRequest)
+			return
+		}
+
+		groups := m.Rules(job)
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"groups": groups})
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		writeYAML(w, groups)
+	})
+}
+
+// writeYAML writes groups as a Prometheus rule file. Strings are single
+// quoted so PromQL and templates need no escaping beyond doubled quotes.
+func writeYAML(w http.ResponseWriter, groups []RuleGroup) {
+	var b strings.Builder
+	b.WriteString("groups:\n")
+	for _, g := range groups {
+		fmt.Fprintf(&b, "  - name: %s\n    rules:\n", yamlString(g.Name))
+		for _, r := range g.Rules {
+			fmt.Fprintf(&b, "      - alert: %s\n", yamlString(r.Alert))
+			fmt.Fprintf(&b, "        expr: %s\n", yamlString(r.Expr))
+			if r.For != "" {
+				fmt.Fprintf(&b, "        for: %s\n", r.For)
+			}
+			writeYAMLMap(&b, "labels", r.Labels)
+			writeYAMLMap(&b, "annotations", r.Annotations)
+		}
+	}
+	w.Write([]byte(b.String()))
+}
+
+func writeYAMLMap(b *strings.Builder, name string, m map[string]string) {
+	fmt.Fprintf(b, "        %s:\n", name)
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	// Sorted keys keep the file stable between requests
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(b, "          %s: %s\n", key, yamlString(m[key]))
+	}
+}
+
+// yamlString single-quotes s for YAML. Generated strings have no newlines,
+// which single quoting would fold into spaces.
+func yamlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
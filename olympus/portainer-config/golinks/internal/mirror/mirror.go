@@ -0,0 +1,217 @@
+// Package mirror keeps a read-only copy of another golinks instance's
+// links, so a satellite site keeps resolving them while its connection to
+// the primary is down.
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+// defaultTimeout bounds one download of the upstream's links.
+const defaultTimeout = time.Minute
+
+// Config names the upstream instance and how to log in to it.
+type Config struct {
+	// Upstream is the base URL of the primary instance, such as
+	// https://go.example.com.
+	Upstream string
+	// User and Pass are an admin login on the upstream, if it requires one
+	// for its export.
+	User, Pass string
+	// Interval is how often the links are synced.
+	Interval time.Duration
+	// Client downloads the links; one with a one-minute timeout if nil.
+	Client *http.Client
+}
+
+// Enabled reports whether an upstream is configured.
+func (c Config) Enabled() bool {
+	return c.Upstream != ""
+}
+
+// Mirror copies the links, collections and aliases of the upstream into a
+// store.
+type Mirror struct {
+	cfg   Config
+	store store.Store
+}
+
+// New returns a Mirror copying the upstream of cfg into st.
+func New(cfg Config, st store.Store) *Mirror {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	return &Mirror{cfg: cfg, store: st}
+}
+
+// Sync downloads the upstream's links, collections and aliases and brings
+// the store in line with them. If the upstream cannot be reached, the store is left
+// as it is, so the links of the last sync keep resolving.
+func (m *Mirror) Sync(ctx context.Context) error {
+	export, err := m.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("download from %s: %w", m.cfg.Upstream, err)
+	}
+
+	local := map[string]store.Link{}
+	err = m.store.EachLink(ctx, func(l store.Link) error {
+		local[l.Slug] = l
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	added, changed, removed := 0, 0, 0
+	upstream := make(map[string]bool, len(export.Links))
+	for _, link := range export.Links {
+		upstream[link.Slug] = true
+		have, ok := local[link.Slug]
+		if ok && same(have, link) {
+			continue
+		}
+		// Clicks are counted where the redirects happen, so the mirror
+		// keeps its own; a changed link is replaced in place, keeping
+		// them and resolving throughout
+		if ok {
+			if err := m.store.ReplaceLink(ctx, link); err != nil {
+				return fmt.Errorf("copy %s: %w", link.Slug, err)
+			}
+			changed++
+			continue
+		}
+		link.Clicks, link.ListOpens, link.LastUsedAt = 0, 0, nil
+		if err := m.store.RestoreLink(ctx, link); err != nil {
+			return fmt.Errorf("copy %s: %w", link.Slug, err)
+		}
+		added++
+	}
+	for slug := range local {
+		if upstream[slug] {
+			continue
+		}
+		if err := m.store.RemoveLink(ctx, slug); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		removed++
+	}
+
+	// Removing links drops them from their collections, so collections
+	// are compared after the links are in place
+	collections, err := m.store.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]store.Collection, len(collections))
+	for _, c := range collections {
+		byName[c.Name] = c
+	}
+	names := make(map[string]bool, len(export.Collections))
+	for _, c := range export.Collections {
+		names[c.Name] = true
+		have, ok := byName[c.Name]
+		if ok && have.Title == c.Title && have.Description == c.Description && slices.Equal(have.Slugs, c.Slugs) {
+			continue
+		}
+		if err := m.store.SaveCollection(ctx, c); err != nil {
+			return fmt.Errorf("copy +%s: %w", c.Name, err)
+		}
+	}
+	for _, c := range collections {
+		if !names[c.Name] {
+			if err := m.store.RemoveCollection(ctx, c.Name); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+	}
+
+	// Aliases point at links, so they are set once the links are in place
+	aliases, err := m.store.ListAliases(ctx)
+	if err != nil {
+		return err
+	}
+	targets := make(map[string]string, len(aliases))
+	for _, a := range aliases {
+		targets[a.Slug] = a.Target
+	}
+	wanted := make(map[string]bool, len(export.Aliases))
+	for _, a := range export.Aliases {
+		wanted[a.Slug] = true
+		if target, ok := targets[a.Slug]; ok && target == a.Target {
+			continue
+		}
+		if err := m.store.SetAlias(ctx, a); err != nil {
+			return fmt.Errorf("copy alias %s: %w", a.Slug, err)
+		}
+	}
+	for _, a := range aliases {
+		if !wanted[a.Slug] {
+			if err := m.store.RemoveAlias(ctx, a.Slug); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+	}
+
+	if added+changed+removed > 0 {
+		slog.Info("Mirrored", "upstream", m.cfg.Upstream, "added", added, "changed", changed, "removed", removed)
+	}
+	return nil
+}
+
+// fetch downloads the upstream's JSON export.
+func (m *Mirror) fetch(ctx context.Context) (httpapi.Export, error) {
+	u := strings.TrimSuffix(m.cfg.Upstream, "/") + "/admin/export?" + url.Values{"format": {httpapi.ExportJSON}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return httpapi.Export{}, err
+	}
+	if m.cfg.User != "" {
+		req.SetBasicAuth(m.cfg.User, m.cfg.Pass)
+	}
+	resp, err := m.cfg.Client.Do(req)
+	if err != nil {
+		return httpapi.Export{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return httpapi.Export{}, errors.New(resp.Status)
+	}
+	var export httpapi.Export
+	if err := json.NewDecoder(resp.Body).Decode(&export); err != nil {
+		return httpapi.Export{}, err
+	}
+	// Anything else must not read as an instance without links
+	if export.Links == nil || export.ExportedAt.IsZero() {
+		return httpapi.Export{}, errors.New("not a golinks export")
+	}
+	return export, nil
+}
+
+// same reports whether a mirrored link matches the upstream's, apart from
+// its clicks and creation time, which the mirror keeps.
+func same(have, want store.Link) bool {
+	sameTime := func(a, b *time.Time) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+	}
+	return have.URL == want.URL && have.Status == want.Status &&
+		have.CreatedBy == want.CreatedBy && have.ApprovedBy == want.ApprovedBy &&
+		have.Public == want.Public && sameTime(have.ReviewAt, want.ReviewAt) &&
+		have.ReviewMonths == want.ReviewMonths && (len(have.Access) == 0 && len(want.Access) == 0 || reflect.DeepEqual(have.Access, want.Access)) &&
+		have.Pin == want.Pin && have.HitBudget == want.HitBudget &&
+		have.Failover == want.Failover && have.Referrer == want.Referrer &&
+		maps.Equal(have.Fields, want.Fields)
+}
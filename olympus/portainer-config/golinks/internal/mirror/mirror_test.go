@@ -0,0 +1,97 @@
+package mirror
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	primary := store.NewMemory()
+	upstream := httptest.NewServer(httpapi.New(httpapi.Config{Admins: map[string]string{"mirror": "pw"}}, primary, httpapi.Pages{}).Handler())
+	t.Cleanup(upstream.Close)
+
+	primary.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", Status: store.StatusActive, CreatedBy: "alice"})
+	primary.AddLink(ctx, store.Link{Slug: "docs", URL: "https://docs.example.com", Status: store.StatusActive})
+	primary.SetPin(ctx, "wiki", 2)
+	primary.SaveCollection(ctx, store.Collection{Name: "team", Slugs: []string{"wiki", "docs"}})
+	primary.SetAlias(ctx, store.Alias{Slug: "w", Target: "wiki"})
+	primary.SetAlias(ctx, store.Alias{Slug: "d", Target: "docs"})
+
+	local := store.NewMemory()
+	m := New(Config{Upstream: upstream.URL, User: "mirror", Pass: "pw"}, local)
+	if err := m.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	wiki, err := local.GetLink(ctx, "wiki")
+	if err != nil || wiki.URL != "https://wiki.example.com" || wiki.Pin != 2 || wiki.CreatedBy != "alice" {
+		t.Fatalf("mirrored wiki = %+v, %v", wiki, err)
+	}
+	aliases, err := local.ListAliases(ctx)
+	if want := []store.Alias{{Slug: "d", Target: "docs"}, {Slug: "w", Target: "wiki"}}; err != nil || !reflect.DeepEqual(aliases, want) {
+		t.Errorf("mirrored aliases = %v, %v, want %v", aliases, err, want)
+	}
+	local.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: time.Now()}})
+
+	// Changes upstream are copied, local clicks are kept
+	primary.UpdateLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki2.example.com", Status: store.StatusActive, CreatedBy: "bob"})
+	primary.RemoveLink(ctx, "docs")
+	primary.SetAlias(ctx, store.Alias{Slug: "wk", Target: "wiki"})
+	if err := m.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	wiki, _ = local.GetLink(ctx, "wiki")
+	if wiki.URL != "https://wiki2.example.com" || wiki.CreatedBy != "bob" || wiki.Clicks != 1 {
+		t.Errorf("synced wiki = %+v", wiki)
+	}
+	if times, err := local.ClickTimes(ctx, "wiki", time.Time{}); err != nil || len(times) != 1 {
+		t.Errorf("click history of synced wiki = %v, %v", times, err)
+	}
+	if _, err := local.GetLink(ctx, "docs"); err == nil {
+		t.Error("docs still mirrored after removal upstream")
+	}
+	team, err := local.GetCollection(ctx, "team")
+	if err != nil || !reflect.DeepEqual(team.Slugs, []string{"wiki"}) {
+		t.Errorf("mirrored team = %+v, %v", team, err)
+	}
+	aliases, err = local.ListAliases(ctx)
+	if want := []store.Alias{{Slug: "w", Target: "wiki"}, {Slug: "wk", Target: "wiki"}}; err != nil || !reflect.DeepEqual(aliases, want) {
+		t.Errorf("synced aliases = %v, %v, want %v", aliases, err, want)
+	}
+
+	// An unreachable or refusing upstream leaves the links in place
+	bad := New(Config{Upstream: upstream.URL, User: "mirror", Pass: "wrong"}, local)
+	if err := bad.Sync(ctx); err == nil {
+		t.Error("Sync with a wrong password succeeded")
+	}
+	upstream.Close()
+	if err := m.Sync(ctx); err == nil {
+		t.Error("Sync of a closed upstream succeeded")
+	}
+	if n, _ := local.CountLinks(ctx); n != 1 {
+		t.Errorf("%d links left after failed syncs, want 1", n)
+	}
+}
+
+func TestSyncNotAnExport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+	local := store.NewMemory()
+	local.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com", Status: store.StatusActive})
+
+	if err := New(Config{Upstream: srv.URL}, local).Sync(context.Background()); err == nil {
+		t.Error("Sync of a non-export succeeded")
+	}
+	if _, err := local.GetLink(context.Background(), "wiki"); err != nil {
+		t.Errorf("wiki removed by a failed sync: %v", err)
+	}
+}
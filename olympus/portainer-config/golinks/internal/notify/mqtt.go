@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mqttTimeout bounds one MQTT delivery.
+const mqttTimeout = 10 * time.Second
+
+// MQTT publishes messages as JSON, like webhooks get them, to a topic of an
+// MQTT 3.1.1 broker. Each message is a connection of its own, published
+// with QoS 0: notifications are rare enough not to keep a session open.
+type MQTT struct {
+	// Addr is the broker's host:port.
+	Addr     string
+	TLS      bool
+	Topic    string
+	Username string
+	Password string
+}
+
+// ParseMQTT parses mqtt://[user:pass@]host[:port]/topic, or mqtts:// for
+// TLS. The port defaults to 1883, or 8883 with TLS.
+func ParseMQTT(spec string) (*MQTT, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	m := &MQTT{TLS: u.Scheme == "mqtts", Topic: strings.TrimPrefix(u.Path, "/")}
+	if u.Hostname() == "" || m.Topic == "" || strings.ContainsAny(m.Topic, "+#") || strings.Contains(spec, "#") {
+		return nil, fmt.Errorf("MQTT URL %s needs a broker and a topic without wildcards", u.Redacted())
+	}
+	port := u.Port()
+	if port == "" {
+		port = "1883"
+		if m.TLS {
+			port = "8883"
+		}
+	}
+	m.Addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		m.Username = u.User.Username()
+		m.Password, _ = u.User.Password()
+	}
+	return m, nil
+}
+
+func (m *MQTT) Notify(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(msg.payload())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, mqttTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	if m.TLS {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		conn, err = (&tls.Dialer{Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", m.Addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", m.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(m.connectPacket()); err != nil {
+		return err
+	}
+	var ack [4]byte
+	if _, err := io.ReadFull(conn, ack[:]); err != nil {
+		return fmt.Errorf("reading CONNACK: %w", err)
+	}
+	if ack[0] != 0x20 || ack[1] != 2 {
+		return errors.New("broker did not answer with CONNACK")
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("broker refused the connection (code %d)", ack[3])
+	}
+
+	var publish []byte
+	publish = appendString(publish, m.Topic)
+	publish = append(publish, payload...)
+	if _, err := conn.Write(packet(0x30, publish)); err != nil {
+		return err
+	}
+	_, err = conn.Write([]byte{0xe0, 0})
+	return err
+}
+
+// connectPacket is the CONNECT packet opening a clean session with a
+// random client ID.
+func (m *MQTT) connectPacket() []byte {
+	var id [8]byte
+	rand.Read(id[:])
+	flags := byte(0x02) // clean session
+	if m.Username != "" {
+		flags |= 0x80
+		if m.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 60) // protocol level 3.1.1, keep alive 60s
+	body = appendString(body, "golinks-"+hex.EncodeToString(id[:]))
+	if m.Username != "" {
+		body = appendString(body, m.Username)
+		if m.Password != "" {
+			body = appendString(body, m.Password)
+		}
+	}
+	return packet(0x10, body)
+}
+
+// packet frames body as an MQTT control packet of the given type and flags.
+func packet(header byte, body []byte) []byte {
+	out := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+// appendString appends s with its 2-byte length prefix.
+func appendString(b []byte, s string) []byte {
+	return append(append(b, byte(len(s)>>8), byte(len(s))), s...)
+}
@@ -0,0 +1,131 @@
+// Package notify delivers messages to people: JSON webhooks (Slack and
+// Mattermost compatible), ntfy topics, Matrix rooms, MQTT and email.
+// Features that notify take a Notifier, so channels are chosen in
+// configuration rather than in their code.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webhookTimeout bounds one webhook delivery.
+const webhookTimeout = 10 * time.Second
+
+// PostWebhook POSTs payload as JSON to url. Any status other than 2xx is
+// an error.
+func PostWebhook(ctx context.Context, client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// PostNtfy publishes message to an ntfy topic URL such as
+// https://ntfy.sh/my-topic, with title as its notification title.
+func PostNtfy(ctx context.Context, client *http.Client, topicURL, title, message string) error {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, topicURL, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// PostMatrix sends a message to a Matrix room as the user of token, with
+// text as its plain body and html as its formatted body. txnID makes
+// retries of the same message idempotent.
+func PostMatrix(ctx context.Context, client *http.Client, homeserver, roomID, token, txnID, text, html string) error {
+	body, err := json.Marshal(map[string]string{
+		"msgtype":        "m.text",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": html,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	u := strings.TrimSuffix(homeserver, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + url.PathEscape(txnID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Mailer sends email through an SMTP server, with PLAIN auth if User is
+// set.
+type Mailer struct {
+	Addr string
+	User string
+	Pass string
+	From string
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (m Mailer) Enabled() bool {
+	return m.Addr != ""
+}
+
+// Send mails body to the recipients. contentType is the MIME type of body,
+// such as "text/plain; charset=utf-8".
+func (m Mailer) Send(to []string, subject, contentType, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\nContent-Type: %s\r\n\r\n", contentType)
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.User != "" {
+		host, _, _ := strings.Cut(m.Addr, ":")
+		auth = smtp.PlainAuth("", m.User, m.Pass, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, to, msg.Bytes())
+}
@@ -0,0 +1,182 @@
+// Package peers looks up slugs on other golinks instances, so one instance
+// can lead to the links of another, such as home to office, without
+// merging their databases.
+package peers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golinks/internal/logging"
+)
+
+// Header marks lookups by a peer. They are answered from the instance's
+// own links only, so instances that peer with each other don't ask each
+// other in circles.
+const Header = "X-Golinks-Peer"
+
+// previewHeader asks a golinks instance where a link leads instead of
+// redirecting; see httpapi.PreviewHeader.
+const previewHeader = "X-Golinks-Preview"
+
+// defaultTimeout bounds one lookup on one peer.
+const defaultTimeout = 2 * time.Second
+
+// ErrNotFound is returned for a slug no peer has.
+var ErrNotFound = errors.New("not found on peers")
+
+// Peer is another golinks instance.
+type Peer struct {
+	// Name is the namespace of the peer's links, as in "work:slug".
+	Name string
+	// URL is the base URL of the peer, such as https://go.work.example.com.
+	URL string
+}
+
+// Config lists the peers and how they are asked.
+type Config struct {
+	Peers []Peer
+	// Fallback looks up slugs without a namespace on every peer, in order,
+	// when they are not found locally.
+	Fallback bool
+	// Timeout bounds one lookup on one peer, 2s if zero.
+	Timeout time.Duration
+	// Client sends the lookups; http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Enabled reports whether any peer is configured.
+func (c Config) Enabled() bool {
+	return len(c.Peers) > 0
+}
+
+// Link is where a peer leads a slug.
+type Link struct {
+	// Peer is the name of the peer that has the link.
+	Peer string
+	// Slug is the slug on the peer, without a namespace.
+	Slug string
+	URL  string
+	// Referrer is the peer's referrer policy for the link, if any.
+	Referrer string
+}
+
+// Resolver looks up slugs on peers.
+type Resolver struct {
+	cfg Config
+}
+
+// New returns a resolver for the peers of cfg.
+func New(cfg Config) *Resolver {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Resolver{cfg: cfg}
+}
+
+// Resolve looks up slug on the peer its namespace names, such as "work"
+// for "work:wiki", or with Fallback on every peer in turn. It returns
+// ErrNotFound if no peer asked has the slug; peers that fail are logged
+// and skipped.
+func (r *Resolver) Resolve(ctx context.Context, slug string) (Link, error) {
+	if name, rest, ok := strings.Cut(slug, ":"); ok && rest != "" {
+		for _, p := range r.cfg.Peers {
+			if p.Name == name {
+				return r.ask(ctx, p, rest)
+			}
+		}
+	}
+	if !r.cfg.Fallback {
+		return Link{}, ErrNotFound
+	}
+	for _, p := range r.cfg.Peers {
+		link, err := r.ask(ctx, p, slug)
+		if err == nil {
+			return link, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			slog.Warn("Peer lookup failed", "peer", p.Name, "error", err)
+		}
+	}
+	return Link{}, ErrNotFound
+}
+
+// ask asks p where slug leads.
+func (r *Resolver) ask(ctx context.Context, p Peer, slug string) (Link, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.URL, "/")+"/"+(&url.URL{Path: slug}).EscapedPath(), nil)
+	if err != nil {
+		return Link{}, err
+	}
+	req.Header.Set(previewHeader, "1")
+	req.Header.Set(Header, "1")
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	resp, err := r.cfg.Client.Do(req)
+	if err != nil {
+		return Link{}, err
+	}
+	defer resp.Body.Close()
+
+	// Pending, reserved and closed links are not found either: the peer
+	// would not redirect to them
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden:
+		return Link{}, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return Link{}, fmt.Errorf("lookup of %s: %s", slug, resp.Status)
+	}
+	var preview struct {
+		Slug     string `json:"slug"`
+		URL      string `json:"url"`
+		Referrer string `json:"referrer"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&preview); err != nil {
+		return Link{}, fmt.Errorf("lookup of %s: %w", slug, err)
+	}
+	if !strings.HasPrefix(preview.URL, "http://") && !strings.HasPrefix(preview.URL, "https://") {
+		return Link{}, fmt.Errorf("lookup of %s: no http(s) destination", slug)
+	}
+	return Link{Peer: p.Name, Slug: preview.Slug, URL: preview.URL, Referrer: preview.Referrer}, nil
+}
+
+// Parse parses comma-separated name=URL peers, such as
+// "work=https://go.work.example.com".
+func Parse(s string) ([]Peer, error) {
+	var peers []Peer
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, base, ok := strings.Cut(entry, "=")
+		name, base = strings.TrimSpace(name), strings.TrimSpace(base)
+		if !ok || name == "" || strings.ContainsAny(name, ":/ ") {
+			return nil, fmt.Errorf("malformed peer %q, want name=URL", entry)
+		}
+		u, err := url.Parse(base)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+			return nil, fmt.Errorf("peer %s needs an http(s) base URL", name)
+		}
+		for _, p := range peers {
+			if p.Name == name {
+				return nil, fmt.Errorf("peer %s named twice", name)
+			}
+		}
+		peers = append(peers, Peer{Name: name, URL: base})
+	}
+	return peers, nil
+}
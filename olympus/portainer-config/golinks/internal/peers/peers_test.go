@@ -0,0 +1,108 @@
+package peers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"golinks/internal/logging"
+)
+
+// fakePeer answers lookups of the slugs in links and counts the requests.
+func fakePeer(t *testing.T, links map[string]string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get(previewHeader) != "1" || r.Header.Get(Header) != "1" {
+			t.Errorf("lookup headers = %v", r.Header)
+		}
+		slug := r.URL.Path[1:]
+		if slug == "broken" {
+			http.Error(w, "oops", http.StatusInternalServerError)
+			return
+		}
+		url, ok := links[slug]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"slug": "` + slug + `", "url": "` + url + `", "referrer": "strip"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	work, workRequests := fakePeer(t, map[string]string{"wiki": "https://wiki.work.example.com", "a/b c": "https://work.example.com/ab"})
+	office, _ := fakePeer(t, map[string]string{"wiki": "https://wiki.office.example.com", "hr": "https://hr.office.example.com"})
+	r := New(Config{Peers: []Peer{{Name: "work", URL: work.URL}, {Name: "office", URL: office.URL + "/"}}})
+
+	got, err := r.Resolve(ctx, "office:wiki")
+	want := Link{Peer: "office", Slug: "wiki", URL: "https://wiki.office.example.com", Referrer: "strip"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve(office:wiki) = %+v, %v", got, err)
+	}
+	if got, err := r.Resolve(ctx, "work:a/b c"); err != nil || got.URL != "https://work.example.com/ab" {
+		t.Errorf("Resolve(work:a/b c) = %+v, %v", got, err)
+	}
+	if _, err := r.Resolve(ctx, "work:broken"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve(work:broken) = %v, want a lookup error", err)
+	}
+
+	// Without fallback, only namespaced slugs are looked up
+	before := workRequests.Load()
+	for _, slug := range []string{"wiki", "home:wiki", "work:missing"} {
+		if _, err := r.Resolve(ctx, slug); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Resolve(%s) = %v, want ErrNotFound", slug, err)
+		}
+	}
+	if n := workRequests.Load() - before; n != 1 {
+		t.Errorf("work asked %d times, want 1", n)
+	}
+
+	// With it, peers are asked in order and failing ones skipped
+	r = New(Config{Peers: []Peer{{Name: "work", URL: work.URL}, {Name: "office", URL: office.URL}}, Fallback: true})
+	if got, err := r.Resolve(ctx, "wiki"); err != nil || got.Peer != "work" {
+		t.Errorf("Resolve(wiki) = %+v, %v", got, err)
+	}
+	if got, err := r.Resolve(ctx, "hr"); err != nil || got.Peer != "office" {
+		t.Errorf("Resolve(hr) = %+v, %v", got, err)
+	}
+	if _, err := r.Resolve(ctx, "broken"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve(broken) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestResolveRequestID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(logging.RequestIDHeader); id != "req-42" {
+			t.Errorf("request id = %q, want req-42", id)
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	r := New(Config{Peers: []Peer{{Name: "work", URL: srv.URL}}})
+	r.Resolve(logging.WithRequestID(context.Background(), "req-42"), "work:wiki")
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse("work=https://go.work.example.com, office = http://go.office.lan:8080/ ,")
+	want := []Peer{{Name: "work", URL: "https://go.work.example.com"}, {Name: "office", URL: "http://go.office.lan:8080/"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v, %v", got, err)
+	}
+	if got, err := Parse(""); err != nil || got != nil {
+		t.Errorf("Parse empty = %+v, %v", got, err)
+	}
+	for _, bad := range []string{"work", "=https://go.example.com", "a:b=https://go.example.com", "work=go.example.com", "work=ftp://go.example.com", "work=https://a.example.com,work=https://b.example.com"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
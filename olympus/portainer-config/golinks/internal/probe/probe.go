@@ -0,0 +1,170 @@
+// Package probe resolves a canary slug through this instance's own HTTP
+// listener every so often, to tell when the service is up but redirects are
+// broken, such as when the database went read-only or away.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header marks the requests of the probe, so they are not counted as
+// clicks.
+const Header = "X-Golinks-Probe"
+
+// defaultTimeout bounds one probe.
+const defaultTimeout = 5 * time.Second
+
+// Config names the canary and how often it is resolved.
+type Config struct {
+	// Slug is the canary, a link that must exist and redirect; empty
+	// disables the probe.
+	Slug string
+	// Target is the base URL the canary is resolved at, such as
+	// http://127.0.0.1:8080.
+	Target string
+	// Interval is how often the canary is resolved.
+	Interval time.Duration
+	// Timeout bounds one probe, 5s if zero.
+	Timeout time.Duration
+	// Client sends the requests; one that doesn't follow redirects if nil.
+	Client *http.Client
+}
+
+// Enabled reports whether a canary is configured.
+func (c Config) Enabled() bool {
+	return c.Slug != ""
+}
+
+// LocalTarget returns the base URL of a server listening on addr, such as
+// ":8080", as reached from the same host.
+func LocalTarget(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	return "http://" + net.JoinHostPort(host, port), nil
+}
+
+// Result is the outcome of a probe.
+type Result struct {
+	Slug string `json:"slug"`
+	// OK is whether the canary redirected.
+	OK bool `json:"ok"`
+	// Status and Location are the answer, if there was one; Error is why
+	// the probe failed.
+	Status    int       `json:"status,omitempty"`
+	Location  string    `json:"location,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Duration is how long the answer took; Took is the same, rounded,
+	// for people.
+	Duration time.Duration `json:"-"`
+	Took     string        `json:"took"`
+	// Failures counts the probes failed in a row, 0 after one succeeded.
+	Failures int `json:"failures"`
+}
+
+// Prober resolves the canary and keeps the latest result.
+type Prober struct {
+	cfg Config
+
+	mu     sync.Mutex
+	last   Result
+	probes int64
+	failed int64
+}
+
+// New returns a Prober for cfg.
+func New(cfg Config) *Prober {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{
+			Timeout: cfg.Timeout,
+			// The redirect is the answer, not where it leads
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	return &Prober{cfg: cfg, last: Result{Slug: cfg.Slug}}
+}
+
+// Interval returns how often Probe should run.
+func (p *Prober) Interval() time.Duration {
+	return p.cfg.Interval
+}
+
+// Probe resolves the canary once. It returns an error, also kept in the
+// result, unless the canary redirected.
+func (p *Prober) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	r := Result{Slug: p.cfg.Slug, CheckedAt: time.Now().UTC()}
+	err := p.resolve(ctx, &r)
+	r.Duration = time.Since(r.CheckedAt)
+	r.Took = r.Duration.Round(time.Millisecond).String()
+	r.OK = err == nil
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.probes++
+	if err != nil {
+		p.failed++
+		r.Error = err.Error()
+		r.Failures = p.last.Failures + 1
+		slog.Warn("Probe failed", "slug", p.cfg.Slug, "failures", r.Failures, "error", err)
+	} else if p.last.Failures > 0 {
+		slog.Info("Probe succeeded again", "slug", p.cfg.Slug, "failures", p.last.Failures)
+	}
+	p.last = r
+	return err
+}
+
+func (p *Prober) resolve(ctx context.Context, r *Result) error {
+	target := strings.TrimSuffix(p.cfg.Target, "/") + "/" + (&url.URL{Path: p.cfg.Slug}).EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(Header, "1")
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	r.Status, r.Location = resp.StatusCode, resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode > 399 || r.Location == "" {
+		return fmt.Errorf("got %s instead of a redirect", resp.Status)
+	}
+	return nil
+}
+
+// Result returns the latest result. Before the first probe, its CheckedAt
+// is zero and OK is false.
+func (p *Prober) Result() Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
+
+// Counts returns how many probes ran and how many of them failed.
+func (p *Prober) Counts() (probes, failed int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.probes, p.failed
+}
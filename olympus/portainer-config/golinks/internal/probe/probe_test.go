@@ -0,0 +1,75 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	var broken atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/team/canary" || r.Header.Get(Header) == "" {
+			t.Errorf("probe requested %s with %s %q", r.URL.Path, Header, r.Header.Get(Header))
+		}
+		if broken.Load() {
+			http.Error(w, "database is locked", http.StatusServiceUnavailable)
+			return
+		}
+		http.Redirect(w, r, "https://example.com/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	p := New(Config{Slug: "team/canary", Target: srv.URL + "/"})
+	if r := p.Result(); r.OK || !r.CheckedAt.IsZero() {
+		t.Errorf("Result before the first probe = %+v", r)
+	}
+	if err := p.Probe(ctx); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if r := p.Result(); !r.OK || r.Status != http.StatusFound || r.Location != "https://example.com/" {
+		t.Errorf("Result = %+v", r)
+	}
+
+	broken.Store(true)
+	p.Probe(ctx)
+	if err := p.Probe(ctx); err == nil {
+		t.Fatal("Probe of a failing server succeeded")
+	}
+	if r := p.Result(); r.OK || r.Status != http.StatusServiceUnavailable || r.Failures != 2 || r.Error == "" {
+		t.Errorf("Result after failures = %+v", r)
+	}
+	broken.Store(false)
+	p.Probe(ctx)
+	if r := p.Result(); !r.OK || r.Failures != 0 {
+		t.Errorf("Result after recovery = %+v", r)
+	}
+	if probes, failed := p.Counts(); probes != 4 || failed != 2 {
+		t.Errorf("Counts = %d, %d", probes, failed)
+	}
+
+	srv.Close()
+	if err := p.Probe(ctx); err == nil {
+		t.Error("Probe of a closed server succeeded")
+	}
+}
+
+func TestLocalTarget(t *testing.T) {
+	for addr, want := range map[string]string{
+		":8080":          "http://127.0.0.1:8080",
+		"0.0.0.0:8080":   "http://127.0.0.1:8080",
+		"[::]:80":        "http://[::1]:80",
+		"10.0.0.5:8080":  "http://10.0.0.5:8080",
+		"localhost:9000": "http://localhost:9000",
+	} {
+		if got, err := LocalTarget(addr); err != nil || got != want {
+			t.Errorf("LocalTarget(%q) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+	if _, err := LocalTarget("8080"); err == nil {
+		t.Error("LocalTarget without a port succeeded")
+	}
+}
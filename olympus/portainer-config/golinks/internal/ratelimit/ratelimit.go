@@ -0,0 +1,119 @@
+// Package ratelimit counts API requests per caller in fixed windows, such as
+// 600 a minute, and reports the quota left in the RateLimit headers of the
+// IETF draft "RateLimit header fields for HTTP".
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config sets how many requests a caller may make per window.
+type Config struct {
+	// Limit is the number of requests per Window; 0 disables limiting.
+	Limit  int
+	Window time.Duration
+}
+
+// Enabled reports whether requests are limited.
+func (c Config) Enabled() bool {
+	return c.Limit > 0
+}
+
+// Quota is the state of a caller's limit.
+type Quota struct {
+	Name      string `json:"name"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	// Window is the length of a window and Reset the time left in the
+	// current one, in seconds.
+	Window int `json:"window"`
+	Reset  int `json:"reset"`
+}
+
+// SetHeaders sets the RateLimit headers of q on h.
+func (q Quota) SetHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.Itoa(q.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(q.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(q.Reset))
+	h.Set("RateLimit-Policy", strconv.Itoa(q.Limit)+";w="+strconv.Itoa(q.Window))
+}
+
+// window counts the requests of one caller since start.
+type window struct {
+	start time.Time
+	used  int
+}
+
+// Limiter counts requests per caller key. It is safe for concurrent use.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*window
+	// swept is when expired windows were last dropped.
+	swept time.Time
+}
+
+// New returns a Limiter for cfg, which must be enabled.
+func New(cfg Config) *Limiter {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	return &Limiter{cfg: cfg, now: time.Now, windows: make(map[string]*window)}
+}
+
+// Allow counts a request of key and reports whether it is within the
+// limit, along with the quota left after it. Refused requests don't count.
+func (l *Limiter) Allow(key string) (Quota, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	win := l.current(key, now)
+	ok := win.used < l.cfg.Limit
+	if ok {
+		win.used++
+	}
+	return l.quota(win, now), ok
+}
+
+// Peek returns the quota of key without counting a request.
+func (l *Limiter) Peek(key string) Quota {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	return l.quota(l.current(key, now), now)
+}
+
+// current returns the window of key at now, starting a new one if the last
+// has ended. It must be called with mu held.
+func (l *Limiter) current(key string, now time.Time) *window {
+	if now.Sub(l.swept) >= l.cfg.Window {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.cfg.Window {
+				delete(l.windows, k)
+			}
+		}
+		l.swept = now
+	}
+	win := l.windows[key]
+	if win == nil || now.Sub(win.start) >= l.cfg.Window {
+		win = &window{start: now}
+		l.windows[key] = win
+	}
+	return win
+}
+
+func (l *Limiter) quota(win *window, now time.Time) Quota {
+	left := win.start.Add(l.cfg.Window).Sub(now)
+	return Quota{
+		Name:      "api",
+		Limit:     l.cfg.Limit,
+		Remaining: l.cfg.Limit - win.used,
+		Window:    int((l.cfg.Window + time.Second - 1) / time.Second),
+		Reset:     int((left + time.Second - 1) / time.Second),
+	}
+}
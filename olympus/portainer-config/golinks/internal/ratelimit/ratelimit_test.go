@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Config{Limit: 2, Window: time.Minute})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if q, ok := l.Allow("alice"); !ok || q.Remaining != 1-i {
+			t.Fatalf("request %d: %+v, %v", i, q, ok)
+		}
+	}
+	now = now.Add(20 * time.Second)
+	q, ok := l.Allow("alice")
+	if ok || q.Remaining != 0 || q.Reset != 40 {
+		t.Errorf("over the limit: %+v, %v", q, ok)
+	}
+	if q, ok := l.Allow("bob"); !ok || q.Remaining != 1 {
+		t.Errorf("other caller: %+v, %v", q, ok)
+	}
+	if q := l.Peek("alice"); q.Remaining != 0 {
+		t.Errorf("Peek = %+v", q)
+	}
+
+	now = now.Add(60 * time.Second)
+	if q := l.Peek("alice"); q.Remaining != 2 || q.Reset != 60 {
+		t.Errorf("next window: %+v", q)
+	}
+	if len(l.windows) != 1 {
+		t.Errorf("%d windows kept, want the expired ones dropped", len(l.windows))
+	}
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	h := make(http.Header)
+	Quota{Limit: 600, Remaining: 599, Window: 60, Reset: 42}.SetHeaders(h)
+	if h.Get("RateLimit-Limit") != "600" || h.Get("RateLimit-Remaining") != "599" || h.Get("RateLimit-Reset") != "42" || h.Get("RateLimit-Policy") != "600;w=60" {
+		t.Errorf("headers = %v", h)
+	}
+}
@@ -0,0 +1,119 @@
+// Package reminder notifies link owners when a link is due for review,
+// e.g. a yearly check that go/insurance still points at the current
+// policy.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golinks/internal/notify"
+	"golinks/internal/store"
+)
+
+// CheckEvery is how often due reviews should be looked for.
+const CheckEvery = time.Hour
+
+// Config selects where reminders are delivered. With no channel
+// configured, reminders are only logged.
+type Config struct {
+	// WebhookURL receives a JSON POST per due link with the reminder as
+	// "text" (Slack and Mattermost compatible) and the link as "link".
+	WebhookURL string
+	// Owners whose name is an email address are mailed directly, other
+	// reminders go to EmailTo. Email needs Mailer to be enabled.
+	Mailer  notify.Mailer
+	EmailTo []string
+	// Notifier, if set, receives every reminder too. Email notifiers mail
+	// owners directly like EmailTo does.
+	Notifier notify.Notifier
+}
+
+// Reminder looks for links due for review and notifies their owners.
+type Reminder struct {
+	notifier notify.Multi
+	store    store.Store
+}
+
+// New creates a Reminder for the links in st.
+func New(cfg Config, st store.Store) *Reminder {
+	rm := &Reminder{store: st}
+	if cfg.WebhookURL != "" {
+		rm.notifier = append(rm.notifier, notify.Webhook{URL: cfg.WebhookURL, Client: &http.Client{Timeout: 10 * time.Second}})
+	}
+	if cfg.Mailer.Enabled() {
+		rm.notifier = append(rm.notifier, notify.Email{Mailer: cfg.Mailer, To: cfg.EmailTo})
+	}
+	if cfg.Notifier != nil {
+		rm.notifier = append(rm.notifier, cfg.Notifier)
+	}
+	return rm
+}
+
+// Check notifies the owner of every link whose review is due at now and
+// moves its review date on. Links whose notification fails keep their date
+// and are retried on the next check.
+func (rm *Reminder) Check(ctx context.Context, now time.Time) error {
+	var due []store.Link
+	err := rm.store.EachLink(ctx, func(link store.Link) error {
+		if link.ReviewAt != nil && !link.ReviewAt.After(now) {
+			due = append(due, link)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, link := range due {
+		if err := rm.notify(ctx, link); err != nil {
+			slog.Error("Review reminder failed", "slug", link.Slug, "error", err)
+			continue
+		}
+		next := nextReview(*link.ReviewAt, link.ReviewMonths, now)
+		if err := rm.store.SetReview(ctx, link.Slug, next, link.ReviewMonths); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextReview returns the first review date after now that is a multiple of
+// months after at, skipping reviews missed while the server was down, or
+// nil for a one-off review.
+func nextReview(at time.Time, months int, now time.Time) *time.Time {
+	if months <= 0 {
+		return nil
+	}
+	next := at
+	for i := 1; !next.After(now); i++ {
+		next = at.AddDate(0, i*months, 0)
+	}
+	return &next
+}
+
+// notify delivers the reminder for link to every configured channel.
+func (rm *Reminder) notify(ctx context.Context, link store.Link) error {
+	owner := link.CreatedBy
+	if owner == "" {
+		owner = "unknown"
+	}
+	text := fmt.Sprintf("Review due: go/%s -> %s (owner: %s). Update or remove it if it is out of date.", link.Slug, link.URL, owner)
+	slog.Info("Review due", "slug", link.Slug, "url", link.URL, "owner", owner)
+
+	msg := notify.Message{
+		Event: notify.EventReview,
+		Title: "Go link review due: go/" + link.Slug,
+		Text:  text,
+		Data:  map[string]any{"link": link},
+	}
+	if strings.Contains(link.CreatedBy, "@") {
+		msg.To = []string{link.CreatedBy}
+	}
+	return rm.notifier.Notify(ctx, msg)
+}
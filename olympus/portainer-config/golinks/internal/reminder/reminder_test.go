@@ -0,0 +1,120 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestNextReview(t *testing.T) {
+	at := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		months int
+		now    time.Time
+		want   time.Time
+	}{
+		{0, at, time.Time{}},
+		{12, at, time.Date(2027, 1, 31, 9, 0, 0, 0, time.UTC)},
+		// Months skipped while the server was down are not reminded again
+		{1, time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)},
+		// April 31 overflows into May; the review after it is back on the 31st
+		{3, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)},
+		{3, time.Date(2026, 5, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 7, 31, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got := nextReview(at, tt.months, tt.now)
+		if tt.want.IsZero() {
+			if got != nil {
+				t.Errorf("nextReview(%d months) = %v, want nil", tt.months, got)
+			}
+			continue
+		}
+		if got == nil || !got.Equal(tt.want) {
+			t.Errorf("nextReview(%d months, now %v) = %v, want %v", tt.months, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	var texts []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Text string     `json:"text"`
+			Link store.Link `json:"link"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		texts = append(texts, payload.Text)
+	}))
+	defer hook.Close()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	due := now.Add(-time.Hour)
+	later := now.Add(24 * time.Hour)
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "insurance", URL: "https://insurance.example.com", CreatedBy: "alice"})
+	st.AddLink(ctx, store.Link{Slug: "visa", URL: "https://visa.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "plain", URL: "https://plain.example.com"})
+	st.SetReview(ctx, "insurance", &due, 12)
+	st.SetReview(ctx, "visa", &due, 0)
+	st.SetReview(ctx, "wiki", &later, 1)
+
+	rm := New(Config{WebhookURL: hook.URL}, st)
+	if err := rm.Check(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(texts) != 2 {
+		t.Fatalf("webhook got %d reminders, want 2: %q", len(texts), texts)
+	}
+	for _, text := range texts {
+		if strings.HasPrefix(text, "Review due: go/insurance -> https://insurance.example.com (owner: alice)") {
+			continue
+		}
+		if !strings.HasPrefix(text, "Review due: go/visa") {
+			t.Errorf("unexpected reminder %q", text)
+		}
+	}
+	if link, _ := st.GetLink(ctx, "insurance"); link.ReviewAt == nil || !link.ReviewAt.Equal(due.AddDate(1, 0, 0)) {
+		t.Errorf("yearly review moved to %v, want a year on", link.ReviewAt)
+	}
+	if link, _ := st.GetLink(ctx, "visa"); link.ReviewAt != nil {
+		t.Errorf("one-off review still set: %v", link.ReviewAt)
+	}
+	if link, _ := st.GetLink(ctx, "wiki"); link.ReviewAt == nil || !link.ReviewAt.Equal(later) {
+		t.Errorf("review that is not due changed to %v", link.ReviewAt)
+	}
+
+	// Nothing is due any more
+	if err := rm.Check(ctx, now); err != nil || len(texts) != 2 {
+		t.Errorf("second check sent %d reminders in total, err %v", len(texts), err)
+	}
+}
+
+func TestCheckRetriesFailedDelivery(t *testing.T) {
+	ctx := context.Background()
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer hook.Close()
+
+	now := time.Now()
+	due := now.Add(-time.Minute)
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "insurance", URL: "https://insurance.example.com"})
+	st.SetReview(ctx, "insurance", &due, 0)
+
+	if err := New(Config{WebhookURL: hook.URL}, st).Check(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if link, _ := st.GetLink(ctx, "insurance"); link.ReviewAt == nil {
+		t.Error("review cleared although the reminder was not delivered")
+	}
+}
@@ -0,0 +1,132 @@
+// Package report builds periodic usage reports (link growth, top links,
+// broken links and top missing slugs) and delivers them by webhook, email
+// or the /admin/reports endpoint.
+package report
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"golinks/internal/health"
+	"golinks/internal/store"
+)
+
+// Report schedules.
+const (
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// topN is the length of the top links and top missing slugs lists.
+const topN = 10
+
+// Count is a slug and how often it was requested.
+type Count struct {
+	Slug  string `json:"slug"`
+	Count int    `json:"count"`
+}
+
+// BrokenLink is an active link whose destination failed to load.
+type BrokenLink struct {
+	Slug    string `json:"slug"`
+	URL     string `json:"url"`
+	Problem string `json:"problem"`
+}
+
+// Report summarizes one period.
+type Report struct {
+	Schedule    string       `json:"schedule"`
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	TotalLinks  int          `json:"total_links"`
+	NewLinks    int          `json:"new_links"`
+	TopLinks    []Count      `json:"top_links"`
+	TopMissing  []Count      `json:"top_missing"`
+	Broken      []BrokenLink `json:"broken_links"`
+	// UsageSince is when the redirect counts start: the previous report,
+	// or the process start if there was none.
+	UsageSince time.Time `json:"usage_since"`
+}
+
+// periodStart returns the start of the period of the given schedule that
+// ends at to.
+func periodStart(schedule string, to time.Time) time.Time {
+	if schedule == Monthly {
+		return to.AddDate(0, -1, 0)
+	}
+	return to.AddDate(0, 0, -7)
+}
+
+// nextRun returns the next scheduled report time strictly after now:
+// Monday 00:00 for weekly reports and the 1st of the month 00:00 for
+// monthly ones, in now's location.
+func nextRun(schedule string, now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if schedule == Monthly {
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	}
+	days := (int(time.Monday) - int(now.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return midnight.AddDate(0, 0, days)
+}
+
+// build assembles a report for the period ending at now from the store and
+// the usage counts of the period.
+func build(ctx context.Context, st store.Store, client *http.Client, schedule string, now, usageSince time.Time, hits, misses map[string]int) (Report, error) {
+	r := Report{
+		Schedule:    schedule,
+		From:        periodStart(schedule, now),
+		To:          now,
+		GeneratedAt: time.Now().UTC(),
+		TopLinks:    Top(hits),
+		TopMissing:  Top(misses),
+		Broken:      []BrokenLink{},
+		UsageSince:  usageSince,
+	}
+
+	var active []store.Link
+	err := st.EachLink(ctx, func(link store.Link) error {
+		r.TotalLinks++
+		if !link.CreatedAt.Before(r.From) {
+			r.NewLinks++
+		}
+		if link.Status == store.StatusActive {
+			active = append(active, link)
+		}
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	// Links whose check did not finish in time are left out
+	for _, result := range health.CheckLinks(ctx, client, active) {
+		if result.Status == health.Broken {
+			r.Broken = append(r.Broken, BrokenLink{Slug: result.Slug, URL: result.URL, Problem: result.Problem})
+		}
+	}
+	return r, nil
+}
+
+// Top returns the topN most requested slugs, most requested first.
+func Top(counts map[string]int) []Count {
+	list := make([]Count, 0, len(counts))
+	for slug, n := range counts {
+		list = append(list, Count{Slug: slug, Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Slug < list[j].Slug
+	})
+	if len(list) > topN {
+		list = list[:topN]
+	}
+	return list
+}
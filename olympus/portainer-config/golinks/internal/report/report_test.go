@@ -0,0 +1,211 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golinks/internal/store"
+)
+
+func TestNextRun(t *testing.T) {
+	loc := time.UTC
+	tests := []struct {
+		schedule string
+		now      time.Time
+		want     time.Time
+	}{
+		// 2026-10-14 is a Wednesday
+		{Weekly, time.Date(2026, 10, 14, 15, 0, 0, 0, loc), time.Date(2026, 10, 19, 0, 0, 0, 0, loc)},
+		{Weekly, time.Date(2026, 10, 19, 0, 0, 0, 0, loc), time.Date(2026, 10, 26, 0, 0, 0, 0, loc)},
+		{Weekly, time.Date(2026, 10, 18, 23, 59, 0, 0, loc), time.Date(2026, 10, 19, 0, 0, 0, 0, loc)},
+		{Monthly, time.Date(2026, 10, 14, 15, 0, 0, 0, loc), time.Date(2026, 11, 1, 0, 0, 0, 0, loc)},
+		{Monthly, time.Date(2026, 12, 1, 0, 0, 0, 0, loc), time.Date(2027, 1, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		if got := nextRun(tt.schedule, tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextRun(%s, %v) = %v, want %v", tt.schedule, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	u := NewUsage()
+	u.Hit("wiki")
+	u.Hit("wiki")
+	u.Miss("wkii")
+	for i := 0; i < maxMissing+5; i++ {
+		u.Miss(fmt.Sprintf("scan%d", i))
+	}
+	u.Miss("wkii")
+
+	hits, misses := u.Take()
+	if hits["wiki"] != 2 {
+		t.Errorf("hits[wiki] = %d, want 2", hits["wiki"])
+	}
+	if len(misses) != maxMissing || misses["wkii"] != 2 {
+		t.Errorf("misses: %d distinct, wkii = %d; want %d and 2", len(misses), misses["wkii"], maxMissing)
+	}
+
+	if hits, misses := u.Take(); len(hits) != 0 || len(misses) != 0 {
+		t.Error("Take did not reset the counts")
+	}
+	recent := u.RecentMisses()
+	if len(recent) != recentMisses || recent[0].Slug != "wkii" || recent[1].Slug != fmt.Sprintf("scan%d", maxMissing+4) {
+		t.Errorf("recent misses: %d, newest %+v", len(recent), recent[:2])
+	}
+}
+
+func TestTop(t *testing.T) {
+	counts := map[string]int{}
+	for i := 0; i < topN+5; i++ {
+		counts[fmt.Sprintf("s%02d", i)] = i % 4
+	}
+	got := Top(counts)
+	if len(got) != topN {
+		t.Fatalf("len = %d, want %d", len(got), topN)
+	}
+	if got[0] != (Count{Slug: "s03", Count: 3}) || got[1] != (Count{Slug: "s07", Count: 3}) {
+		t.Errorf("top = %v", got[:2])
+	}
+}
+
+// agedStore reports the link "old" as created two months ago.
+type agedStore struct {
+	store.Store
+}
+
+func (s agedStore) EachLink(ctx context.Context, fn func(store.Link) error) error {
+	return s.Store.EachLink(ctx, func(link store.Link) error {
+		if link.Slug == "old" {
+			link.CreatedAt = time.Now().AddDate(0, -2, 0)
+		}
+		return fn(link)
+	})
+}
+
+// newReporter returns a Reporter over a store with an old link, a new link
+// and a link to a missing page on a test destination server.
+func newReporter(t *testing.T, cfg Config) (*Reporter, *Usage) {
+	t.Helper()
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(dest.Close)
+
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "old", URL: dest.URL})
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: dest.URL + "/wiki"})
+	st.AddLink(ctx, store.Link{Slug: "gone", URL: dest.URL + "/gone"})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: dest.URL + "/gone", Status: store.StatusPending})
+
+	usage := NewUsage()
+	rp, err := New(cfg, agedStore{st}, usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp, usage
+}
+
+func TestGenerate(t *testing.T) {
+	rp, usage := newReporter(t, Config{Schedule: Weekly})
+	usage.Hit("wiki")
+	usage.Hit("wiki")
+	usage.Hit("old")
+	usage.Miss("jira")
+
+	r, err := rp.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TotalLinks != 4 || r.NewLinks != 3 {
+		t.Errorf("links = %d total, %d new; want 4 and 3", r.TotalLinks, r.NewLinks)
+	}
+	if len(r.TopLinks) != 2 || r.TopLinks[0] != (Count{Slug: "wiki", Count: 2}) {
+		t.Errorf("top links = %v", r.TopLinks)
+	}
+	if len(r.TopMissing) != 1 || r.TopMissing[0].Slug != "jira" {
+		t.Errorf("top missing = %v", r.TopMissing)
+	}
+	// Pending links are not checked
+	if len(r.Broken) != 1 || r.Broken[0].Slug != "gone" || r.Broken[0].Problem != "HTTP 404" {
+		t.Errorf("broken = %v", r.Broken)
+	}
+}
+
+func TestReportsEndpoint(t *testing.T) {
+	rp, usage := newReporter(t, Config{})
+
+	rec := httptest.NewRecorder()
+	rp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/reports", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("before any report: status = %d, want 404", rec.Code)
+	}
+
+	usage.Hit("wiki|docs")
+	rec = httptest.NewRecorder()
+	rp.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reports", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<td>go/wiki|docs</td>") {
+		t.Errorf("POST: status = %d, body:\n%s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	rp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/reports?format=markdown", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"# Go Links weekly report", `| go/wiki\|docs | 1 |`, "| go/gone |"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("markdown missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	rp.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/reports?format=json", nil))
+	var r Report
+	if err := json.NewDecoder(rec.Body).Decode(&r); err != nil || r.TotalLinks != 4 {
+		t.Errorf("json: %+v, %v", r, err)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	received := make(chan map[string]json.RawMessage, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]json.RawMessage
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &payload)
+		received <- payload
+	}))
+	defer hook.Close()
+
+	rp, _ := newReporter(t, Config{Schedule: Monthly, WebhookURL: hook.URL})
+	if _, err := rp.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := <-received
+	var text string
+	json.Unmarshal(payload["text"], &text)
+	if !strings.HasPrefix(text, "# Go Links monthly report") {
+		t.Errorf("webhook text = %q", text)
+	}
+	if _, ok := payload["report"]; !ok {
+		t.Error("webhook payload missing report data")
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New(Config{Schedule: "daily"}, store.NewMemory(), NewUsage()); err == nil {
+		t.Error("unknown schedule accepted")
+	}
+	if _, err := New(Config{Format: "pdf"}, store.NewMemory(), NewUsage()); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
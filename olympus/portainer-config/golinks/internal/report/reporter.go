@@ -0,0 +1,244 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golinks/internal/health"
+	"golinks/internal/httperr"
+	"golinks/internal/notify"
+	"golinks/internal/store"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+// Output formats.
+const (
+	Markdown = "markdown"
+	HTML     = "html"
+)
+
+// keepReports is how many past reports /admin/reports keeps in memory.
+const keepReports = 12
+
+// Config selects when reports are generated and where they are delivered.
+type Config struct {
+	// Schedule is Weekly or Monthly; empty disables scheduled reports.
+	Schedule string
+	// Format is Markdown (default) or HTML, for webhook and email delivery.
+	Format string
+	// WebhookURL receives a JSON POST with the rendered report as "text"
+	// (Slack and Mattermost compatible) and the raw data as "report".
+	WebhookURL string
+	// Reports are mailed to EmailTo when Mailer is enabled.
+	Mailer  notify.Mailer
+	EmailTo []string
+	// Notifier, if set, receives every report too, rendered in Format.
+	Notifier notify.Notifier
+}
+
+// Reporter generates reports on schedule, delivers them, and serves the
+// recent ones.
+type Reporter struct {
+	cfg      Config
+	store    store.Store
+	usage    *Usage
+	client   *http.Client
+	notifier notify.Multi
+	markdown *template.Template
+	html     *htmltemplate.Template
+
+	mu         sync.Mutex
+	usageSince time.Time
+	reports    []Report
+}
+
+// New creates a Reporter reading links from st and usage from usage.
+func New(cfg Config, st store.Store, usage *Usage) (*Reporter, error) {
+	if cfg.Format == "" {
+		cfg.Format = Markdown
+	}
+	if cfg.Format != Markdown && cfg.Format != HTML {
+		return nil, fmt.Errorf("unknown report format %q", cfg.Format)
+	}
+	if cfg.Schedule != "" && cfg.Schedule != Weekly && cfg.Schedule != Monthly {
+		return nil, fmt.Errorf("unknown report schedule %q", cfg.Schedule)
+	}
+
+	markdown, err := template.New("report.md.tmpl").Funcs(template.FuncMap{
+		"cell": func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+	}).ParseFS(templateFS, "templates/report.md.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %w", err)
+	}
+	html, err := htmltemplate.ParseFS(templateFS, "templates/report.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %w", err)
+	}
+
+	rp := &Reporter{
+		cfg:        cfg,
+		store:      st,
+		usage:      usage,
+		client:     health.NewClient(),
+		markdown:   markdown,
+		html:       html,
+		usageSince: time.Now(),
+	}
+	if cfg.WebhookURL != "" {
+		rp.notifier = append(rp.notifier, notify.Webhook{URL: cfg.WebhookURL, Client: rp.client})
+	}
+	if cfg.Mailer.Enabled() && len(cfg.EmailTo) > 0 {
+		rp.notifier = append(rp.notifier, notify.Email{Mailer: cfg.Mailer, To: cfg.EmailTo})
+	}
+	if cfg.Notifier != nil {
+		rp.notifier = append(rp.notifier, cfg.Notifier)
+	}
+	return rp, nil
+}
+
+// Schedule returns the configured schedule, Weekly, Monthly or empty.
+func (rp *Reporter) Schedule() string {
+	return rp.cfg.Schedule
+}
+
+// NextRun returns when the next scheduled report is due after now, or the
+// zero time if reports are only generated on demand.
+func (rp *Reporter) NextRun(now time.Time) time.Time {
+	if rp.cfg.Schedule == "" {
+		return time.Time{}
+	}
+	return nextRun(rp.cfg.Schedule, now)
+}
+
+// Generate builds a report for the period ending now, keeps it for the
+// reports endpoint and delivers it. Delivery failures are logged, not
+// returned, so one broken channel does not block the others.
+func (rp *Reporter) Generate(ctx context.Context) (Report, error) {
+	schedule := rp.cfg.Schedule
+	if schedule == "" {
+		schedule = Weekly
+	}
+
+	rp.mu.Lock()
+	now := time.Now()
+	since := rp.usageSince
+	hits, misses := rp.usage.Take()
+	rp.usageSince = now
+	rp.mu.Unlock()
+
+	r, err := build(ctx, rp.store, rp.client, schedule, now, since, hits, misses)
+	if err != nil {
+		return Report{}, err
+	}
+
+	rp.mu.Lock()
+	rp.reports = append(rp.reports, r)
+	if len(rp.reports) > keepReports {
+		rp.reports = rp.reports[len(rp.reports)-keepReports:]
+	}
+	rp.mu.Unlock()
+
+	slog.Info("Report generated", "schedule", r.Schedule, "links", r.TotalLinks, "new", r.NewLinks, "broken", len(r.Broken))
+	rp.deliver(ctx, r)
+	return r, nil
+}
+
+// Render writes r in the given format.
+func (rp *Reporter) Render(w io.Writer, r Report, format string) error {
+	if format == HTML {
+		return rp.html.Execute(w, r)
+	}
+	return rp.markdown.Execute(w, r)
+}
+
+func (rp *Reporter) deliver(ctx context.Context, r Report) {
+	if len(rp.notifier) == 0 {
+		return
+	}
+	var body bytes.Buffer
+	if err := rp.Render(&body, r, rp.cfg.Format); err != nil {
+		slog.Error("Failed to render report", "error", err)
+		return
+	}
+
+	contentType := "text/markdown; charset=utf-8"
+	if rp.cfg.Format == HTML {
+		contentType = "text/html; charset=utf-8"
+	}
+	err := rp.notifier.Notify(ctx, notify.Message{
+		Event:       notify.EventReport,
+		Title:       fmt.Sprintf("Go Links %s report, %s", r.Schedule, r.To.Format("Jan 02, 2006")),
+		Text:        body.String(),
+		ContentType: contentType,
+		Data:        map[string]any{"report": r},
+	})
+	if err != nil {
+		slog.Error("Report delivery failed", "error", err)
+	}
+}
+
+// ServeHTTP serves the most recent report (GET, as HTML, Markdown with
+// ?format=markdown, or JSON with ?format=json) and generates one on demand
+// (POST).
+func (rp *Reporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var report Report
+	switch r.Method {
+	case http.MethodGet:
+		rp.mu.Lock()
+		n := len(rp.reports)
+		if n > 0 {
+			report = rp.reports[n-1]
+		}
+		rp.mu.Unlock()
+		if n == 0 {
+			http.Error(w, "No report generated yet", http.StatusNotFound)
+			return
+		}
+	case http.MethodPost:
+		var err error
+		if report, err = rp.Generate(r.Context()); err != nil {
+			slog.ErrorContext(r.Context(), "Error generating report", "error", err)
+			httperr.Write(w, err)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	if format != Markdown {
+		format = HTML
+	}
+
+	var body bytes.Buffer
+	if err := rp.Render(&body, report, format); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if format == HTML {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	}
+	body.WriteTo(w)
+}
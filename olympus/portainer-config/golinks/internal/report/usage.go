@@ -0,0 +1,76 @@
+package report
+
+import (
+	"sync"
+	"time"
+)
+
+// maxMissing bounds the distinct unknown slugs counted per period, so a
+// scanner walking random paths cannot grow the map without limit.
+const maxMissing = 10000
+
+// recentMisses is how many of the latest requests for unknown slugs are
+// kept for the stats page.
+const recentMisses = 50
+
+// Miss is a request for a slug that does not exist.
+type Miss struct {
+	Slug string    `json:"slug"`
+	At   time.Time `json:"at"`
+}
+
+// Usage counts redirects and requests for unknown slugs between reports.
+// Counts live in memory and start from zero when the process restarts.
+type Usage struct {
+	mu     sync.Mutex
+	hits   map[string]int
+	misses map[string]int
+	// recent holds the latest misses, oldest first. Take leaves it alone.
+	recent []Miss
+}
+
+// NewUsage returns an empty Usage.
+func NewUsage() *Usage {
+	return &Usage{hits: make(map[string]int), misses: make(map[string]int)}
+}
+
+// Hit records a redirect for slug.
+func (u *Usage) Hit(slug string) {
+	u.mu.Lock()
+	u.hits[slug]++
+	u.mu.Unlock()
+}
+
+// Miss records a request for a slug that does not exist.
+func (u *Usage) Miss(slug string) {
+	u.mu.Lock()
+	if _, ok := u.misses[slug]; ok || len(u.misses) < maxMissing {
+		u.misses[slug]++
+	}
+	if len(u.recent) == recentMisses {
+		u.recent = append(u.recent[:0], u.recent[1:]...)
+	}
+	u.recent = append(u.recent, Miss{Slug: slug, At: time.Now().UTC()})
+	u.mu.Unlock()
+}
+
+// RecentMisses returns the latest requests for unknown slugs, newest
+// first.
+func (u *Usage) RecentMisses() []Miss {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	misses := make([]Miss, len(u.recent))
+	for i, m := range u.recent {
+		misses[len(misses)-1-i] = m
+	}
+	return misses
+}
+
+// Take returns the counts recorded since the previous call and resets them.
+func (u *Usage) Take() (hits, misses map[string]int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	hits, misses = u.hits, u.misses
+	u.hits, u.misses = make(map[string]int), make(map[string]int)
+	return hits, misses
+}
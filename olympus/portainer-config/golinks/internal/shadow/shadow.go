@@ -0,0 +1,199 @@
+// Package shadow sends a share of the redirects to a second golinks
+// deployment as well and compares its answers, to validate a migration,
+// such as to another backend, before cutting over. Clients only ever get
+// the answers of this instance.
+package shadow
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golinks/internal/logging"
+)
+
+// Header marks the requests sent to the shadow, so its logs can tell them
+// from real ones.
+const Header = "X-Golinks-Shadow"
+
+// defaultTimeout bounds one request to the shadow.
+const defaultTimeout = 5 * time.Second
+
+// maxInFlight is how many requests may wait for the shadow at once; more
+// are dropped rather than queued.
+const maxInFlight = 32
+
+// maxMismatches is how many mismatches are kept, the newest.
+const maxMismatches = 50
+
+// Config names the shadow and how much traffic it gets.
+type Config struct {
+	// Target is the base URL of the second deployment, such as
+	// http://golinks-next:8080.
+	Target string
+	// Percent is the share of redirects sent to Target as well, 0 to 100.
+	Percent float64
+	// Timeout bounds one request to Target, 5s if zero.
+	Timeout time.Duration
+	// Client sends the requests; one that doesn't follow redirects if nil.
+	Client *http.Client
+}
+
+// Enabled reports whether a shadow is configured.
+func (c Config) Enabled() bool {
+	return c.Target != ""
+}
+
+// Mismatch is a request the shadow answered differently.
+type Mismatch struct {
+	Path string    `json:"path"`
+	At   time.Time `json:"at"`
+	// Status and Location are the answer of this instance, ShadowStatus
+	// and ShadowLocation that of the shadow.
+	Status         int    `json:"status"`
+	Location       string `json:"location,omitempty"`
+	ShadowStatus   int    `json:"shadow_status"`
+	ShadowLocation string `json:"shadow_location,omitempty"`
+}
+
+// Stats counts the requests sent to the shadow so far.
+type Stats struct {
+	Target  string  `json:"target"`
+	Percent float64 `json:"percent"`
+	// Sent requests were answered by the shadow, with the same status
+	// and Location (Matched) or not (Mismatched); Failed ones got no
+	// answer. Dropped were sampled but not sent, as too many were in
+	// flight.
+	Sent       int64 `json:"sent"`
+	Matched    int64 `json:"matched"`
+	Mismatched int64 `json:"mismatched"`
+	Failed     int64 `json:"failed"`
+	Dropped    int64 `json:"dropped"`
+	// Mismatches are the latest mismatches, newest first.
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Shadower sends sampled requests to the shadow and keeps their stats.
+type Shadower struct {
+	cfg      Config
+	inFlight chan struct{}
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New returns a Shadower for cfg.
+func New(cfg Config) *Shadower {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{
+			Timeout: cfg.Timeout,
+			// The redirect is the answer to compare, not where it leads
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	return &Shadower{
+		cfg:      cfg,
+		inFlight: make(chan struct{}, maxInFlight),
+		stats:    Stats{Target: cfg.Target, Percent: cfg.Percent},
+	}
+}
+
+// Observe takes a request this instance answered with status and, for a
+// redirect, location. A sampled share of them is sent to the shadow in the
+// background; Observe never waits for it.
+func (s *Shadower) Observe(r *http.Request, status int, location string) {
+	if s.cfg.Percent < 100 && rand.Float64()*100 >= s.cfg.Percent {
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		s.count(func(st *Stats) { st.Dropped++ })
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(s.cfg.Target, "/")+r.URL.RequestURI(), nil)
+	if err != nil {
+		<-s.inFlight
+		return
+	}
+	req.Header.Set(Header, "1")
+	if id := logging.RequestID(r.Context()); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	// The shadow should see the same client, for user agent checks and
+	// access rules; it believes the address only from a trusted proxy
+	for _, h := range []string{"User-Agent", "Referer", "Accept"} {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		forwarded := host
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+			forwarded = prior + ", " + host
+		}
+		req.Header.Set("X-Forwarded-For", forwarded)
+	}
+
+	want := Mismatch{Path: r.URL.RequestURI(), Status: status, Location: location}
+	go func() {
+		defer func() { <-s.inFlight }()
+		s.send(req, want)
+	}()
+}
+
+// send sends req to the shadow and compares its answer with want.
+func (s *Shadower) send(req *http.Request, want Mismatch) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	resp, err := s.cfg.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		slog.Warn("Shadow request failed", "path", want.Path, "error", err)
+		s.count(func(st *Stats) { st.Failed++ })
+		return
+	}
+	resp.Body.Close()
+
+	got := resp.Header.Get("Location")
+	if resp.StatusCode == want.Status && got == want.Location {
+		s.count(func(st *Stats) { st.Sent++; st.Matched++ })
+		return
+	}
+	want.At, want.ShadowStatus, want.ShadowLocation = time.Now().UTC(), resp.StatusCode, got
+	slog.Warn("Shadow mismatch", "path", want.Path, "status", want.Status, "location", want.Location, "shadow_status", want.ShadowStatus, "shadow_location", want.ShadowLocation)
+	s.count(func(st *Stats) {
+		st.Sent++
+		st.Mismatched++
+		st.Mismatches = append(st.Mismatches, want)
+		if n := len(st.Mismatches); n > maxMismatches {
+			st.Mismatches = st.Mismatches[n-maxMismatches:]
+		}
+	})
+}
+
+func (s *Shadower) count(fn func(*Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.stats)
+}
+
+// Stats returns the stats so far.
+func (s *Shadower) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Mismatches = make([]Mismatch, len(s.stats.Mismatches))
+	for i, m := range s.stats.Mismatches {
+		stats.Mismatches[len(stats.Mismatches)-1-i] = m
+	}
+	return stats
+}
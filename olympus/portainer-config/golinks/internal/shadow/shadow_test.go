@@ -0,0 +1,78 @@
+package shadow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitFor polls s until n requests got an answer or failed.
+func waitFor(t *testing.T, s *Shadower, n int64) Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := s.Stats()
+		if stats.Sent+stats.Failed >= n || time.Now().After(deadline) {
+			return stats
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShadower(t *testing.T) {
+	var forwarded string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(Header) == "" {
+			t.Error("shadow request without the shadow header")
+		}
+		forwarded = r.Header.Get("X-Forwarded-For")
+		switch r.URL.Path {
+		case "/wiki":
+			http.Redirect(w, r, "https://wiki.example.com", http.StatusFound)
+		case "/mail":
+			http.Redirect(w, r, "https://webmail.example.com", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer target.Close()
+	s := New(Config{Target: target.URL, Percent: 100})
+
+	observe := func(path string, status int, location string) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "192.0.2.7:51234"
+		s.Observe(r, status, location)
+	}
+	observe("/wiki", http.StatusFound, "https://wiki.example.com")
+	stats := waitFor(t, s, 1)
+	observe("/mail", http.StatusFound, "https://mail.example.com")
+	observe("/nope", http.StatusNotFound, "")
+	stats = waitFor(t, s, 3)
+	if stats.Sent != 3 || stats.Matched != 2 || stats.Mismatched != 1 || len(stats.Mismatches) != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if m := stats.Mismatches[0]; m.Path != "/mail" || m.ShadowLocation != "https://webmail.example.com" || m.Location != "https://mail.example.com" {
+		t.Errorf("mismatch = %+v", m)
+	}
+	if forwarded != "192.0.2.7" {
+		t.Errorf("X-Forwarded-For = %q", forwarded)
+	}
+
+	// A shadow that is down counts as failed, not as a mismatch
+	target.Close()
+	observe("/wiki", http.StatusFound, "https://wiki.example.com")
+	if stats := waitFor(t, s, 4); stats.Failed != 1 || stats.Mismatched != 1 {
+		t.Errorf("stats with the shadow down = %+v", stats)
+	}
+}
+
+func TestShadowerSamples(t *testing.T) {
+	s := New(Config{Target: "http://shadow.invalid", Percent: 0.0001, Client: &http.Client{Transport: http.NewFileTransport(http.Dir(t.TempDir()))}})
+	for range 1000 {
+		s.Observe(httptest.NewRequest(http.MethodGet, "/wiki", nil), http.StatusFound, "https://wiki.example.com")
+	}
+	if stats := waitFor(t, s, 0); stats.Sent+stats.Failed+stats.Dropped > 5 {
+		t.Errorf("%+v at 0.0001%%", stats)
+	}
+}
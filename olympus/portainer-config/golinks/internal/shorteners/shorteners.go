@@ -0,0 +1,266 @@
+// Package shorteners reads the exports of other self-hosted URL shorteners,
+// so their links can be imported.
+package shorteners
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Formats of the shorteners Parse reads.
+const (
+	// YOURLS is a CSV with keyword and url columns, as export plugins
+	// write, or the JSON of the stats API action.
+	YOURLS = "yourls"
+	// Shlink is the CSV export of the Shlink web client, or the JSON of
+	// GET /rest/v3/short-urls.
+	Shlink = "shlink"
+	// Trotto is the JSON of GET /_/api/links.
+	Trotto = "trotto"
+	// Kutt is the JSON of GET /api/v2/links.
+	Kutt = "kutt"
+)
+
+// Formats lists every format, for validation and help texts.
+var Formats = []string{YOURLS, Shlink, Trotto, Kutt}
+
+// Link is one link of an export.
+type Link struct {
+	Slug  string
+	URL   string
+	Title string
+	Tags  []string
+	// Line is the link's line in a CSV export, or its position in a JSON
+	// one, counting from 1.
+	Line int
+}
+
+// Parse reads the links of an export in format, telling CSV from JSON by
+// its first character.
+func Parse(format string, r io.Reader) ([]Link, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	isJSON := len(data) > 0 && (data[0] == '{' || data[0] == '[')
+	switch format {
+	case YOURLS:
+		if isJSON {
+			return yourlsJSON(data)
+		}
+		return readCSV(data, columns{slug: "keyword", url: "url", title: "title"})
+	case Shlink:
+		if isJSON {
+			return shlinkJSON(data)
+		}
+		return readCSV(data, columns{shortURL: "shortUrl", url: "longUrl", title: "title", tags: "tags", tagSep: "|"})
+	case Trotto:
+		if !isJSON {
+			return nil, errors.New("trotto exports are JSON")
+		}
+		return trottoJSON(data)
+	case Kutt:
+		if !isJSON {
+			return nil, errors.New("kutt exports are JSON")
+		}
+		return kuttJSON(data)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// columns names the header columns of a CSV export. The slug is either a
+// column of its own or the path of a short URL column.
+type columns struct {
+	slug, shortURL, url, title, tags string
+	tagSep                           string
+}
+
+func readCSV(data []byte, c columns) ([]Link, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("no header row: %v", err)
+	}
+	index := func(name string) int {
+		for i, h := range header {
+			if name != "" && strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+		return -1
+	}
+	slugCol, shortCol, urlCol, titleCol, tagsCol := index(c.slug), index(c.shortURL), index(c.url), index(c.title), index(c.tags)
+	if (slugCol < 0 && shortCol < 0) || urlCol < 0 {
+		name := c.slug
+		if name == "" {
+			name = c.shortURL
+		}
+		return nil, fmt.Errorf("header row needs %s and %s columns", name, c.url)
+	}
+
+	var links []Link
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		link := Link{Slug: field(slugCol), URL: field(urlCol), Title: field(titleCol), Line: line}
+		if slugCol < 0 {
+			link.Slug = slugOf(field(shortCol))
+		}
+		for _, tag := range strings.Split(field(tagsCol), c.tagSep) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				link.Tags = append(link.Tags, tag)
+			}
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// slugOf returns the path of a short URL without its leading slash.
+func slugOf(shortURL string) string {
+	u, err := url.Parse(shortURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Path, "/")
+}
+
+// list decodes data as an array of T, or as the array at key of an object.
+func list[T any](data []byte, key string) ([]T, error) {
+	var items []T
+	if data[0] == '[' {
+		err := json.Unmarshal(data, &items)
+		return items, err
+	}
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, err
+	}
+	raw, ok := wrapper[key]
+	if !ok {
+		return nil, fmt.Errorf("no %q in JSON", key)
+	}
+	err := json.Unmarshal(raw, &items)
+	return items, err
+}
+
+func yourlsJSON(data []byte) ([]Link, error) {
+	type entry struct {
+		Keyword  string `json:"keyword"`
+		ShortURL string `json:"shorturl"`
+		URL      string `json:"url"`
+		Title    string `json:"title"`
+	}
+	var stats struct {
+		Links map[string]entry `json:"links"`
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+	if stats.Links == nil {
+		return nil, errors.New(`no "links" in JSON`)
+	}
+	// The API numbers its links link_1, link_2, ... in the order asked for
+	keys := make([]string, 0, len(stats.Links))
+	for k := range stats.Links {
+		keys = append(keys, k)
+	}
+	number := func(key string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(key, "link_"))
+		return n
+	}
+	slices.SortFunc(keys, func(a, b string) int { return number(a) - number(b) })
+
+	links := make([]Link, 0, len(keys))
+	for i, k := range keys {
+		e := stats.Links[k]
+		slug := e.Keyword
+		if slug == "" {
+			slug = slugOf(e.ShortURL)
+		}
+		links = append(links, Link{Slug: slug, URL: e.URL, Title: e.Title, Line: i + 1})
+	}
+	return links, nil
+}
+
+func shlinkJSON(data []byte) ([]Link, error) {
+	type entry struct {
+		ShortCode string   `json:"shortCode"`
+		LongURL   string   `json:"longUrl"`
+		Title     string   `json:"title"`
+		Tags      []string `json:"tags"`
+	}
+	// The API wraps its page of links as {"shortUrls": {"data": [...]}}
+	if data[0] == '{' {
+		var page struct {
+			ShortURLs json.RawMessage `json:"shortUrls"`
+		}
+		if err := json.Unmarshal(data, &page); err == nil && page.ShortURLs != nil {
+			data = page.ShortURLs
+		}
+	}
+	entries, err := list[entry](data, "data")
+	if err != nil {
+		return nil, err
+	}
+	links := make([]Link, 0, len(entries))
+	for i, e := range entries {
+		links = append(links, Link{Slug: e.ShortCode, URL: e.LongURL, Title: e.Title, Tags: e.Tags, Line: i + 1})
+	}
+	return links, nil
+}
+
+func trottoJSON(data []byte) ([]Link, error) {
+	type entry struct {
+		Shortpath      string `json:"shortpath"`
+		DestinationURL string `json:"destination_url"`
+	}
+	entries, err := list[entry](data, "links")
+	if err != nil {
+		return nil, err
+	}
+	links := make([]Link, 0, len(entries))
+	for i, e := range entries {
+		links = append(links, Link{Slug: strings.TrimPrefix(e.Shortpath, "go/"), URL: e.DestinationURL, Line: i + 1})
+	}
+	return links, nil
+}
+
+func kuttJSON(data []byte) ([]Link, error) {
+	type entry struct {
+		Address     string `json:"address"`
+		Target      string `json:"target"`
+		Description string `json:"description"`
+	}
+	entries, err := list[entry](data, "data")
+	if err != nil {
+		return nil, err
+	}
+	links := make([]Link, 0, len(entries))
+	for i, e := range entries {
+		links = append(links, Link{Slug: e.Address, URL: e.Target, Title: e.Description, Line: i + 1})
+	}
+	return links, nil
+}
@@ -0,0 +1,63 @@
+package shorteners
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		export string
+		want   []Link
+	}{
+		{"yourls csv", YOURLS, "keyword,url,title,timestamp,ip,clicks\nwiki,https://wiki.example.com,Team Wiki,2024-01-02 03:04:05,127.0.0.1,3\nhr,https://hr.example.com,,,,\n", []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com", Title: "Team Wiki", Line: 2},
+			{Slug: "hr", URL: "https://hr.example.com", Line: 3},
+		}},
+		{"yourls json", YOURLS, `{"links": {
+			"link_10": {"shorturl": "https://sho.rt/hr", "url": "https://hr.example.com", "title": "HR"},
+			"link_2": {"shorturl": "https://sho.rt/wiki", "url": "https://wiki.example.com", "title": "Team Wiki"}
+		}, "stats": {"total_links": "2"}, "statusCode": 200}`, []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com", Title: "Team Wiki", Line: 1},
+			{Slug: "hr", URL: "https://hr.example.com", Title: "HR", Line: 2},
+		}},
+		{"shlink csv", Shlink, "createdAt,shortUrl,longUrl,title,tags,visits\n2024-01-02T03:04:05+00:00,https://s.example/wiki,https://wiki.example.com,Team Wiki,docs|Team Stuff,4\n", []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com", Title: "Team Wiki", Tags: []string{"docs", "Team Stuff"}, Line: 2},
+		}},
+		{"shlink json", Shlink, `{"shortUrls": {"data": [{"shortCode": "wiki", "shortUrl": "https://s.example/wiki", "longUrl": "https://wiki.example.com", "title": null, "tags": ["docs"]}], "pagination": {}}}`, []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com", Tags: []string{"docs"}, Line: 1},
+		}},
+		{"trotto", Trotto, `[{"id": 1, "shortpath": "wiki", "destination_url": "https://wiki.example.com", "owner": "a@example.com"}, {"shortpath": "go/hr", "destination_url": "https://hr.example.com"}]`, []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com", Line: 1},
+			{Slug: "hr", URL: "https://hr.example.com", Line: 2},
+		}},
+		{"kutt", Kutt, `{"limit": 10, "skip": 0, "total": 1, "data": [{"address": "wiki", "target": "https://wiki.example.com", "description": "Team Wiki", "link": "https://kutt.it/wiki"}]}`, []Link{
+			{Slug: "wiki", URL: "https://wiki.example.com", Title: "Team Wiki", Line: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.format, strings.NewReader(tt.export))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []struct{ format, export string }{
+		{YOURLS, "slug,url\nwiki,https://wiki.example.com\n"},
+		{Trotto, "shortpath,destination_url\n"},
+		{Kutt, `{"links": []}`},
+		{"bitly", "[]"},
+	} {
+		if _, err := Parse(bad.format, strings.NewReader(bad.export)); err == nil {
+			t.Errorf("Parse(%s, %q) succeeded", bad.format, bad.export)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package snapshot keeps a copy of link destinations, taken when a link is
+// added or pointed elsewhere, so the list can offer it once the
+// destination is gone. The copy is the destination's page as fetched, or
+// whatever an external service returns for it, such as a screenshot.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golinks/internal/store"
+)
+
+const (
+	// fetchTimeout bounds taking one snapshot, hooks included, which may
+	// render the page in a browser.
+	fetchTimeout = time.Minute
+	// maxSize bounds the size of one snapshot; larger ones are not kept.
+	maxSize = 10 << 20
+)
+
+// ErrNotFound is returned for a destination without a snapshot.
+var ErrNotFound = errors.New("no snapshot")
+
+// Config selects where snapshots are kept and how they are taken.
+type Config struct {
+	// Dir keeps one snapshot per destination. Empty disables snapshots.
+	Dir string
+	// HookURL, if set, is POSTed {"url": "..."} for every destination and
+	// its response is kept instead of the page itself, e.g. a screenshot
+	// by a headless browser service.
+	HookURL string
+}
+
+// Enabled reports whether snapshots are taken.
+func (c Config) Enabled() bool {
+	return c.Dir != ""
+}
+
+// Snapshot describes the copy of one destination.
+type Snapshot struct {
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	TakenAt     time.Time `json:"taken_at"`
+}
+
+// Archiver takes and keeps snapshots. The copy of a destination is shared
+// by every link pointing at it, and is replaced when taken again.
+type Archiver struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+	// mu serializes writes, so a snapshot and its description are
+	// replaced together.
+	mu sync.Mutex
+	// wg tracks snapshots being taken.
+	wg sync.WaitGroup
+}
+
+// New creates an Archiver, and cfg.Dir if it does not exist.
+func New(cfg Config) (*Archiver, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	return &Archiver{cfg: cfg, client: &http.Client{Timeout: fetchTimeout}, now: time.Now}, nil
+}
+
+// Take snapshots the destination of link in the background, unless it
+// already has one, so the request that saved the link is not held up.
+func (a *Archiver) Take(link store.Link) {
+	if _, err := a.Get(link.URL); err == nil {
+		return
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if _, err := a.Capture(context.Background(), link.URL); err != nil {
+			slog.Warn("Snapshot failed", "slug", link.Slug, "url", link.URL, "error", err)
+		}
+	}()
+}
+
+// Capture snapshots url now, replacing any previous snapshot.
+func (a *Archiver) Capture(ctx context.Context, url string) (Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	var req *http.Request
+	var err error
+	if a.cfg.HookURL != "" {
+		payload, _ := json.Marshal(map[string]string{"url": url})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.HookURL, bytes.NewReader(payload))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Snapshot{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(body) > maxSize {
+		return Snapshot{}, fmt.Errorf("larger than %d MB", maxSize>>20)
+	}
+
+	snap := Snapshot{
+		URL:         url,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        int64(len(body)),
+		TakenAt:     a.now().UTC().Truncate(time.Second),
+	}
+	if snap.ContentType == "" {
+		snap.ContentType = http.DetectContentType(body)
+	}
+	meta, err := json.Marshal(snap)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	base := a.path(url)
+	if err := writeFile(base, body); err != nil {
+		return Snapshot{}, err
+	}
+	if err := writeFile(base+".json", meta); err != nil {
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// Get returns the description of the snapshot of url, or ErrNotFound.
+func (a *Archiver) Get(url string) (Snapshot, error) {
+	data, err := os.ReadFile(a.path(url) + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, ErrNotFound
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, err
+	}
+	// Distinct URLs could only share a file through a hash collision
+	if snap.URL != url {
+		return Snapshot{}, ErrNotFound
+	}
+	return snap, nil
+}
+
+// Open returns the snapshot of url and its content, which the caller must
+// close, or ErrNotFound.
+func (a *Archiver) Open(url string) (Snapshot, *os.File, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	snap, err := a.Get(url)
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	f, err := os.Open(a.path(url))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, nil, ErrNotFound
+	}
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	return snap, f, nil
+}
+
+// path names the files of the snapshot of url.
+func (a *Archiver) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(a.cfg.Dir, hex.EncodeToString(sum[:16]))
+}
+
+// writeFile replaces name with data through a temporary file, so readers
+// never see a partial snapshot.
+func writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,95 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"golinks/internal/store"
+)
+
+func TestCapture(t *testing.T) {
+	var fetches atomic.Int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<h1>Wiki</h1>")
+	}))
+	defer site.Close()
+
+	a, err := New(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get(site.URL); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before capture = %v, want ErrNotFound", err)
+	}
+
+	a.Take(store.Link{Slug: "wiki", URL: site.URL})
+	a.wg.Wait()
+	snap, f, err := a.Open(site.URL)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	body, _ := io.ReadAll(f)
+	f.Close()
+	if string(body) != "<h1>Wiki</h1>" || snap.ContentType != "text/html; charset=utf-8" || snap.Size != int64(len(body)) || snap.TakenAt.IsZero() {
+		t.Errorf("snapshot = %+v, %q", snap, body)
+	}
+
+	// A destination with a snapshot is not fetched again when another link
+	// points at it
+	a.Take(store.Link{Slug: "docs", URL: site.URL})
+	a.wg.Wait()
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+
+	if _, err := a.Capture(context.Background(), site.URL+"/gone"); err == nil {
+		t.Error("Capture of a 404: expected error")
+	}
+	if _, err := a.Get(site.URL + "/gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("failed capture left a snapshot: %v", err)
+	}
+}
+
+func TestCaptureHook(t *testing.T) {
+	var asked string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL string `json:"url"`
+		}
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		asked = req.URL
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG fake"))
+	}))
+	defer hook.Close()
+
+	a, err := New(Config{Dir: t.TempDir(), HookURL: hook.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := a.Capture(context.Background(), "https://wiki.example.com")
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if asked != "https://wiki.example.com" || snap.ContentType != "image/png" {
+		t.Errorf("hook asked for %q, snapshot %+v", asked, snap)
+	}
+	if got, err := a.Get("https://wiki.example.com"); err != nil || got != snap {
+		t.Errorf("Get = %+v, %v, want %+v", got, err, snap)
+	}
+}
@@ -0,0 +1,292 @@
+// Package sshadmin serves a terminal admin interface over SSH, so links
+// can be listed, searched and edited from a headless box without a browser
+// or hand-written curl JSON. Logins are authorized by public key only.
+package sshadmin
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+
+	"golinks/internal/store"
+)
+
+// handshakeTimeout bounds the SSH handshake of a new connection.
+const handshakeTimeout = 30 * time.Second
+
+// Config configures the SSH admin server.
+type Config struct {
+	// Addr is the listen address, e.g. ":2222".
+	Addr string
+	// HostKeyPath holds the server's private host key. A new ed25519 key
+	// is generated there if the file does not exist.
+	HostKeyPath string
+	// AuthorizedKeysPath lists the admin keys in authorized_keys format.
+	// A key's comment names its admin, who must be one of Admins.
+	AuthorizedKeysPath string
+	// Admins names the admins keys may belong to.
+	Admins []string
+}
+
+// Server is the SSH admin server.
+type Server struct {
+	cfg    Config
+	store  store.Store
+	links  Links
+	config *ssh.ServerConfig
+}
+
+// New loads the host key and authorized keys and returns a Server.
+func New(cfg Config, st store.Store, links Links) (*Server, error) {
+	authorized, err := loadAuthorizedKeys(cfg.AuthorizedKeysPath, cfg.Admins)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authorized keys: %w", err)
+	}
+	if len(authorized) == 0 {
+		return nil, fmt.Errorf("no keys in %s", cfg.AuthorizedKeysPath)
+	}
+	hostKey, err := loadHostKey(cfg.HostKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load host key: %w", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			name, ok := authorized[string(key.Marshal())]
+			if !ok {
+				slog.Warn("SSH login refused", "user", conn.User(), "remote_addr", conn.RemoteAddr().String(), "key", ssh.FingerprintSHA256(key))
+				return nil, errors.New("unauthorized key")
+			}
+			return &ssh.Permissions{Extensions: map[string]string{"admin": name}}, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	return &Server{cfg: cfg, store: st, links: links, config: config}, nil
+}
+
+// loadAuthorizedKeys maps each key's wire form to its comment. The client
+// picks the SSH user name, so only the comment can name the admin, and a
+// key without one or naming someone else is an error.
+func loadAuthorizedKeys(path string, admins []string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string)
+	for len(bytes.TrimSpace(data)) > 0 {
+		key, comment, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, err
+		}
+		if comment == "" {
+			return nil, fmt.Errorf("key %s has no comment naming its admin", ssh.FingerprintSHA256(key))
+		}
+		if !slices.Contains(admins, comment) {
+			return nil, fmt.Errorf("key %s names %q, who is not an admin", ssh.FingerprintSHA256(key), comment)
+		}
+		keys[string(key.Marshal())] = comment
+		data = rest
+	}
+	return keys, nil
+}
+
+// loadHostKey reads the host key at path, generating and saving a new
+// ed25519 key if there is none.
+func loadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "golinks host key")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	slog.Info("Generated SSH host key", "path", path)
+	return ssh.NewSignerFromKey(key)
+}
+
+// Serve accepts SSH connections on ln until ctx is done.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handleConn(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	defer sconn.Close()
+
+	// Close the connection when the server shuts down
+	stop := context.AfterFunc(ctx, func() { sconn.Close() })
+	defer stop()
+
+	admin := sconn.Permissions.Extensions["admin"]
+	slog.Info("SSH admin session", "user", admin, "remote_addr", sconn.RemoteAddr().String())
+
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(ctx, admin, ch, reqs)
+	}
+}
+
+// handleSession serves one session channel: an interactive shell, or a
+// single command given on the ssh command line.
+func (s *Server) handleSession(ctx context.Context, admin string, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	t := term.NewTerminal(ch, "golinks> ")
+	start := make(chan string, 1)
+	go func() {
+		defer close(start)
+		started := false
+		for req := range reqs {
+			ok := false
+			switch req.Type {
+			case "pty-req":
+				// string TERM, then uint32 columns and rows
+				if cols, rows, good := ptySize(req.Payload, true); good {
+					t.SetSize(cols, rows)
+				}
+				ok = true
+			case "window-change":
+				if cols, rows, good := ptySize(req.Payload, false); good {
+					t.SetSize(cols, rows)
+				}
+			case "shell", "exec":
+				if !started {
+					started, ok = true, true
+					command := ""
+					if req.Type == "exec" {
+						command, _ = parseString(req.Payload)
+					}
+					start <- command
+				}
+			}
+			if req.WantReply {
+				req.Reply(ok, nil)
+			}
+		}
+	}()
+
+	command, ok := <-start
+	if !ok {
+		return
+	}
+
+	sess := &session{store: s.store, links: s.links, admin: admin}
+	if command != "" {
+		sess.out = ch
+		sess.run(ctx, command)
+		status := uint32(0)
+		if sess.failed {
+			status = 1
+		}
+		ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+
+	sess.out = t
+	fmt.Fprintf(t, "golinks admin: signed in as %s. Type help for commands.\n", admin)
+	for {
+		line, err := t.ReadLine()
+		if err != nil {
+			return
+		}
+		if sess.run(ctx, line) {
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			return
+		}
+	}
+}
+
+// ptySize extracts the terminal size from a pty-req (withTerm) or
+// window-change payload.
+func ptySize(payload []byte, withTerm bool) (cols, rows int, ok bool) {
+	if withTerm {
+		_, rest := parseString(payload)
+		if rest == nil {
+			return 0, 0, false
+		}
+		payload = rest
+	}
+	if len(payload) < 8 {
+		return 0, 0, false
+	}
+	return int(binary.BigEndian.Uint32(payload)), int(binary.BigEndian.Uint32(payload[4:])), true
+}
+
+// parseString reads an SSH wire string and returns it with the remaining
+// payload, or a nil rest if the payload is malformed.
+func parseString(payload []byte) (string, []byte) {
+	if len(payload) < 4 {
+		return "", nil
+	}
+	n := binary.BigEndian.Uint32(payload)
+	if uint64(len(payload)-4) < uint64(n) {
+		return "", nil
+	}
+	return string(payload[4 : 4+n]), payload[4+n:]
+}
@@ -0,0 +1,159 @@
+package sshadmin
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+// startServer runs a Server on a loopback port that authorizes one freshly
+// generated key, and returns its address and a client config for that key.
+func startServer(t *testing.T, st store.Store) (string, *ssh.ClientConfig) {
+	t.Helper()
+	dir := t.TempDir()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := filepath.Join(dir, "authorized_keys")
+	line := append(bytes.TrimSpace(ssh.MarshalAuthorizedKey(sshPub)), " alice\n"...)
+	if err := os.WriteFile(authorized, line, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := New(Config{HostKeyPath: filepath.Join(dir, "host_key"), AuthorizedKeysPath: authorized, Admins: []string{"alice"}}, st, httpapi.New(httpapi.Config{}, st, httpapi.Pages{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.Serve(ctx, ln)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln.Addr().String(), &ssh.ClientConfig{
+		User:            "admin",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+}
+
+func TestSSHExec(t *testing.T) {
+	st := store.NewMemory()
+	addr, config := startServer(t, st)
+
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	run := func(cmd string) (string, error) {
+		sess, err := client.NewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		out, err := sess.Output(cmd)
+		return string(out), err
+	}
+
+	if out, err := run("add wiki https://wiki.example.com"); err != nil || out != "added go/wiki -> https://wiki.example.com\n" {
+		t.Errorf("add = %q, %v", out, err)
+	}
+	link, err := st.GetLink(context.Background(), "wiki")
+	if err != nil || link.CreatedBy != "alice" {
+		t.Errorf("stored link = %+v, %v; want created by the key comment", link, err)
+	}
+
+	var exitErr *ssh.ExitError
+	if _, err := run("rm missing"); !errors.As(err, &exitErr) || exitErr.ExitStatus() != 1 {
+		t.Errorf("failing command: err = %v, want exit status 1", err)
+	}
+}
+
+func TestSSHRejectsUnknownKey(t *testing.T) {
+	addr, config := startServer(t, store.NewMemory())
+
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(other)
+	config.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	if client, err := ssh.Dial("tcp", addr, config); err == nil {
+		client.Close()
+		t.Fatal("login with an unauthorized key succeeded")
+	}
+}
+
+func TestHostKeyPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "host_key")
+	first, err := loadHostKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loadHostKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.PublicKey().Marshal(), second.PublicKey().Marshal()) {
+		t.Error("host key changed between loads")
+	}
+}
+
+func TestLoadAuthorizedKeys(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.TrimSpace(ssh.MarshalAuthorizedKey(sshPub))
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+
+	// The comment is the only trusted name, so it must be an admin's
+	for _, comment := range []string{"", " mallory", " alice@laptop"} {
+		if err := os.WriteFile(path, append(key, comment+"\n"...), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadAuthorizedKeys(path, []string{"alice"}); err == nil {
+			t.Errorf("key with comment %q: expected error", comment)
+		}
+	}
+	if err := os.WriteFile(path, append(key, " alice\n"...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadAuthorizedKeys(path, []string{"alice", "bob"})
+	if err != nil || keys[string(sshPub.Marshal())] != "alice" {
+		t.Errorf("loadAuthorizedKeys = %v, %v", keys, err)
+	}
+}
@@ -0,0 +1,205 @@
+package sshadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+	"golinks/internal/textwidth"
+)
+
+const (
+	// listDefault is how many links "list" shows without a count.
+	listDefault = 20
+	// searchLimit caps the results of one search.
+	searchLimit = 50
+	// slugColumn is the widest the slug column of a listing gets.
+	slugColumn = 32
+)
+
+const helpText = `Commands:
+  list [n]            newest links (default 20)
+  search <text>       links whose slug or URL contains text
+  show <slug>         details of one link
+  add <slug> <url>    create a link
+  edit <slug> <url>   point a link at a new destination
+  rm <slug>           delete a link
+  help                this text
+  quit                end the session
+`
+
+// Links applies link changes with the same validation and approval rules
+// as the HTTP admin API. *httpapi.Server implements it.
+type Links interface {
+	AddLink(ctx context.Context, req httpapi.AddLinkRequest, createdBy string) (store.Link, error)
+	UpdateLink(ctx context.Context, slug, url, changedBy string) (store.Link, error)
+	RemoveLink(ctx context.Context, slug string) (string, error)
+}
+
+// session runs commands for one authenticated admin.
+type session struct {
+	store store.Store
+	links Links
+	admin string
+	out   io.Writer
+	// failed records whether the last command failed, for exec exit codes.
+	failed bool
+}
+
+// errStop ends an EachLink walk early.
+var errStop = errors.New("stop")
+
+// run executes one command line and reports whether the session should end.
+func (s *session) run(ctx context.Context, line string) (quit bool) {
+	s.failed = false
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return false
+	}
+
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "quit" || cmd == "exit":
+		return true
+	case cmd == "help" || cmd == "?":
+		io.WriteString(s.out, helpText)
+	case cmd == "list" && len(args) <= 1:
+		n := listDefault
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				s.fail("usage: list [n]")
+				return false
+			}
+		}
+		s.list(ctx, n, "")
+	case cmd == "search" && len(args) >= 1:
+		s.list(ctx, searchLimit, strings.ToLower(strings.Join(args, " ")))
+	case cmd == "show" && len(args) == 1:
+		s.show(ctx, args[0])
+	case cmd == "add" && len(args) == 2:
+		link, err := s.links.AddLink(ctx, httpapi.AddLinkRequest{Slug: args[0], URL: args[1]}, s.admin)
+		if s.check(err) {
+			slog.Info("Link added", "slug", link.Slug, "url", link.URL, "user", "ssh:"+s.admin)
+			s.reportSaved("added", link)
+		}
+	case cmd == "edit" && len(args) == 2:
+		link, err := s.links.UpdateLink(ctx, args[0], args[1], s.admin)
+		if s.check(err) {
+			slog.Info("Link updated", "slug", link.Slug, "url", link.URL, "user", "ssh:"+s.admin)
+			s.reportSaved("updated", link)
+		}
+	case cmd == "rm" && len(args) == 1:
+		slug, err := s.links.RemoveLink(ctx, args[0])
+		if s.check(err) {
+			slog.Info("Link removed", "slug", slug, "user", "ssh:"+s.admin)
+			fmt.Fprintf(s.out, "removed go/%s\n", slug)
+		}
+	default:
+		s.fail(fmt.Sprintf("unknown command or wrong arguments: %s (try help)", cmd))
+	}
+	return false
+}
+
+func (s *session) fail(msg string) {
+	s.failed = true
+	fmt.Fprintf(s.out, "error: %s\n", msg)
+}
+
+// check prints a failed command's error and reports whether err was nil.
+func (s *session) check(err error) bool {
+	var invalid *httpapi.InvalidError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &invalid):
+		s.fail(invalid.Msg)
+	case errors.Is(err, store.ErrNotFound):
+		s.fail("no such link")
+	case errors.Is(err, store.ErrConflict):
+		s.fail("slug already exists")
+	default:
+		slog.Error("SSH admin command failed", "user", "ssh:"+s.admin, "error", err)
+		s.fail("failed, see server log")
+	}
+	return false
+}
+
+func (s *session) reportSaved(verb string, link store.Link) {
+	if link.Status == store.StatusPending {
+		fmt.Fprintf(s.out, "%s go/%s -> %s (pending approval by another admin)\n", verb, link.Slug, link.URL)
+		return
+	}
+	fmt.Fprintf(s.out, "%s go/%s -> %s\n", verb, link.Slug, link.URL)
+}
+
+// list prints up to n links, newest first, whose slug or URL contains query
+// (all links if query is empty).
+func (s *session) list(ctx context.Context, n int, query string) {
+	var links []store.Link
+	err := s.store.EachLink(ctx, func(link store.Link) error {
+		if query != "" && !strings.Contains(strings.ToLower(link.Slug), query) && !strings.Contains(strings.ToLower(link.URL), query) {
+			return nil
+		}
+		if len(links) == n {
+			return errStop
+		}
+		links = append(links, link)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		s.check(err)
+		return
+	}
+	if len(links) == 0 {
+		io.WriteString(s.out, "no links\n")
+		return
+	}
+
+	width := 0
+	for _, link := range links {
+		width = max(width, min(textwidth.Width(link.Slug)+3, slugColumn))
+	}
+	for _, link := range links {
+		name := "go/" + link.Slug
+		pad := max(width-textwidth.Width(name), 0)
+		flag := " "
+		if link.Status == store.StatusPending {
+			flag = "!"
+		}
+		fmt.Fprintf(s.out, "%s%s %s %s\n", name, strings.Repeat(" ", pad), flag, link.URL)
+	}
+	if errors.Is(err, errStop) {
+		fmt.Fprintf(s.out, "(first %d shown)\n", n)
+	}
+}
+
+func (s *session) show(ctx context.Context, slug string) {
+	link, err := s.store.GetLink(ctx, slug)
+	if !s.check(err) {
+		return
+	}
+	fmt.Fprintf(s.out, "slug:        go/%s\n", link.Slug)
+	fmt.Fprintf(s.out, "url:         %s\n", link.URL)
+	fmt.Fprintf(s.out, "status:      %s\n", link.Status)
+	fmt.Fprintf(s.out, "public:      %t\n", link.Public)
+	if link.ReviewAt != nil {
+		fmt.Fprintf(s.out, "review:      %s", link.ReviewAt.Local().Format("Jan 02, 2006"))
+		if link.ReviewMonths > 0 {
+			fmt.Fprintf(s.out, ", then every %d month(s)", link.ReviewMonths)
+		}
+		io.WriteString(s.out, "\n")
+	}
+	fmt.Fprintf(s.out, "created:     %s\n", link.CreatedAt.Format("Jan 02, 2006 15:04"))
+	if link.CreatedBy != "" {
+		fmt.Fprintf(s.out, "created by:  %s\n", link.CreatedBy)
+	}
+	if link.ApprovedBy != "" {
+		fmt.Fprintf(s.out, "approved by: %s\n", link.ApprovedBy)
+	}
+}
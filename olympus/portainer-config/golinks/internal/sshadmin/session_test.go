@@ -0,0 +1,87 @@
+package sshadmin
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+func newSession(t *testing.T, cfg httpapi.Config) (*session, *bytes.Buffer, *store.Memory) {
+	t.Helper()
+	st := store.NewMemory()
+	var out bytes.Buffer
+	return &session{store: st, links: httpapi.New(cfg, st, httpapi.Pages{}), admin: "alice", out: &out}, &out, st
+}
+
+// runLine runs one command line and returns its output.
+func runLine(t *testing.T, s *session, out *bytes.Buffer, line string) string {
+	t.Helper()
+	out.Reset()
+	if s.run(context.Background(), line) {
+		t.Fatalf("%q ended the session", line)
+	}
+	return out.String()
+}
+
+func TestSessionCommands(t *testing.T) {
+	ctx := context.Background()
+	s, out, st := newSession(t, httpapi.Config{SensitivePatterns: []string{"pay.example.com"}})
+
+	if got := runLine(t, s, out, "add wiki https://wiki.example.com"); got != "added go/wiki -> https://wiki.example.com\n" {
+		t.Errorf("add = %q", got)
+	}
+	if link, err := st.GetLink(ctx, "wiki"); err != nil || link.CreatedBy != "alice" {
+		t.Errorf("stored link = %+v, %v", link, err)
+	}
+	if got := runLine(t, s, out, "add pay https://pay.example.com"); !strings.Contains(got, "pending approval") {
+		t.Errorf("add sensitive = %q", got)
+	}
+	if got := runLine(t, s, out, "add wiki https://other.example.com"); got != "error: slug already exists\n" || !s.failed {
+		t.Errorf("add duplicate = %q (failed %v)", got, s.failed)
+	}
+	if got := runLine(t, s, out, "add docs ftp://docs"); !strings.HasPrefix(got, "error: Invalid URL") {
+		t.Errorf("add invalid = %q", got)
+	}
+
+	got := runLine(t, s, out, "list")
+	if !strings.Contains(got, "go/wiki   https://wiki.example.com\n") || !strings.Contains(got, "go/pay  ! https://pay.example.com\n") {
+		t.Errorf("list =\n%s", got)
+	}
+	if got := runLine(t, s, out, "search WIKI"); strings.Contains(got, "go/pay") || !strings.Contains(got, "go/wiki") {
+		t.Errorf("search =\n%s", got)
+	}
+	if got := runLine(t, s, out, "list 1"); !strings.Contains(got, "(first 1 shown)") {
+		t.Errorf("list 1 =\n%s", got)
+	}
+
+	if got := runLine(t, s, out, "edit wiki https://wiki2.example.com"); got != "updated go/wiki -> https://wiki2.example.com\n" {
+		t.Errorf("edit = %q", got)
+	}
+	if got := runLine(t, s, out, "show wiki"); !strings.Contains(got, "url:         https://wiki2.example.com\n") {
+		t.Errorf("show =\n%s", got)
+	}
+	if got := runLine(t, s, out, "edit missing https://x.example.com"); got != "error: no such link\n" {
+		t.Errorf("edit missing = %q", got)
+	}
+
+	if got := runLine(t, s, out, "rm wiki"); got != "removed go/wiki\n" {
+		t.Errorf("rm = %q", got)
+	}
+	if got := runLine(t, s, out, "rm wiki"); got != "error: no such link\n" {
+		t.Errorf("rm twice = %q", got)
+	}
+
+	if got := runLine(t, s, out, "frobnicate"); !strings.HasPrefix(got, "error: unknown command") {
+		t.Errorf("unknown = %q", got)
+	}
+	if got := runLine(t, s, out, "help"); !strings.Contains(got, "search <text>") {
+		t.Errorf("help = %q", got)
+	}
+	if !s.run(ctx, "quit") {
+		t.Error("quit did not end the session")
+	}
+}
@@ -0,0 +1,834 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// boltVersion is the layout of the buckets OpenBolt creates. A file of a
+// later version is refused rather than misread.
+const boltVersion = 1
+
+// boltMagic is at offset 16 of every bbolt file, in the byte order of the
+// machine that wrote it.
+const boltMagic = 0xED0CDAED
+
+// Buckets of a Bolt file. Links, aliases and collections are JSON values
+// keyed by slug or name. A click is a key of its slug, a zero byte, and its
+// time and a sequence number, both big-endian, with the weight of a sampled
+// click as value; clickTimes indexes the same clicks by time and sequence,
+// with the slug, and a zero byte and the weight of a sampled click, as
+// value.
+var (
+	boltMeta        = []byte("meta")
+	boltLinks       = []byte("links")
+	boltAliases     = []byte("aliases")
+	boltCollections = []byte("collections")
+	boltClicks      = []byte("clicks")
+	boltClickTimes  = []byte("click_times")
+)
+
+// Bolt is a Store in a single bbolt file: no SQL, and the file can be
+// copied away whenever golinks is stopped. Only one process can open it.
+type Bolt struct {
+	db *bbolt.DB
+}
+
+// IsBoltFile reports whether data starts like a bbolt file.
+func IsBoltFile(data []byte) bool {
+	return len(data) >= 20 && (binary.LittleEndian.Uint32(data[16:20]) == boltMagic || binary.BigEndian.Uint32(data[16:20]) == boltMagic)
+}
+
+// OpenBolt opens (creating if needed) the bbolt file at path. It gives up
+// after a few seconds if another process holds the file.
+func OpenBolt(path string) (*Bolt, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{boltMeta, boltLinks, boltAliases, boltCollections, boltClicks, boltClickTimes} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		meta := tx.Bucket(boltMeta)
+		version := 0
+		if v := meta.Get([]byte("version")); v != nil {
+			version, _ = strconv.Atoi(string(v))
+		}
+		if version > boltVersion {
+			return fmt.Errorf("database is of version %d, newer than this golinks knows (%d)", version, boltVersion)
+		}
+		return meta.Put([]byte("version"), []byte(strconv.Itoa(boltVersion)))
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	slog.Info("Database initialized successfully", "engine", "bolt")
+	return &Bolt{db: db}, nil
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// Backup writes a consistent copy of the file to w while it stays in use.
+func (b *Bolt) Backup(ctx context.Context, w io.Writer) error {
+	return b.view(ctx, func(tx *bbolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// view runs fn in a read transaction unless ctx is done.
+func (b *Bolt) view(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.View(fn)
+}
+
+// update runs fn in a write transaction unless ctx is done. An error from
+// fn rolls back every change it made.
+func (b *Bolt) update(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.db.Update(fn)
+}
+
+// boltGetLink reads the link at slug, or returns ErrNotFound.
+func boltGetLink(tx *bbolt.Tx, slug string) (Link, error) {
+	data := tx.Bucket(boltLinks).Get([]byte(slug))
+	if data == nil {
+		return Link{}, ErrNotFound
+	}
+	var link Link
+	if err := json.Unmarshal(data, &link); err != nil {
+		return Link{}, fmt.Errorf("link %s: %w", slug, err)
+	}
+	return link, nil
+}
+
+func boltPutLink(tx *bbolt.Tx, link Link) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltLinks).Put([]byte(link.Slug), data)
+}
+
+func boltHasLink(tx *bbolt.Tx, slug string) bool {
+	return tx.Bucket(boltLinks).Get([]byte(slug)) != nil
+}
+
+// boltClickPrefix starts the keys of every click of slug.
+func boltClickPrefix(slug string) []byte {
+	return append([]byte(slug), 0)
+}
+
+// boltTimeKey is the key of a click in clickTimes, and the end of its key
+// in clicks. Times before 1970, such as the zero time, sort as 1970.
+func boltTimeKey(at int64, seq uint64) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(max(at, 0)))
+	return binary.BigEndian.AppendUint64(key, seq)
+}
+
+// boltWeightValue is the value of a click in clicks: its weight in
+// decimal, or nothing for 1.
+func boltWeightValue(weight int) []byte {
+	if weight <= 1 {
+		return []byte{}
+	}
+	return strconv.AppendInt(nil, int64(weight), 10)
+}
+
+// boltWeight returns the weight of a value of boltWeightValue.
+func boltWeight(v []byte) int {
+	if n, err := strconv.Atoi(string(v)); err == nil && n > 1 {
+		return n
+	}
+	return 1
+}
+
+// boltTimeValue is the value of a click in clickTimes.
+func boltTimeValue(slug string, weight int) []byte {
+	v := []byte(slug)
+	if weight > 1 {
+		v = append(append(v, 0), boltWeightValue(weight)...)
+	}
+	return v
+}
+
+// boltSplitTimeValue returns the slug and weight of a value of
+// boltTimeValue.
+func boltSplitTimeValue(v []byte) (string, int) {
+	slug, weight, _ := bytes.Cut(v, []byte{0})
+	return string(slug), boltWeight(weight)
+}
+
+// boltClickTime returns the time of a key of clickTimes or the end of one
+// of clicks.
+func boltClickTime(key []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint64(key[len(key)-16:])), 0).UTC()
+}
+
+func (b *Bolt) GetLink(ctx context.Context, slug string) (*Link, error) {
+	var link Link
+	err := b.view(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		link, err = boltGetLink(tx, slug)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (b *Bolt) ListLinks(ctx context.Context) ([]Link, error) {
+	return b.listLinks(ctx, OrderNewest)
+}
+
+func (b *Bolt) listLinks(ctx context.Context, order LinkOrder) ([]Link, error) {
+	less, ok := linkLess[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown link order %q", order)
+	}
+	var links []Link
+	err := b.view(ctx, func(tx *bbolt.Tx) error {
+		links = make([]Link, 0, tx.Bucket(boltLinks).Stats().KeyN)
+		return tx.Bucket(boltLinks).ForEach(func(k, v []byte) error {
+			var link Link
+			if err := json.Unmarshal(v, &link); err != nil {
+				return fmt.Errorf("link %s: %w", k, err)
+			}
+			links = append(links, link)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(links, func(i, j int) bool { return less(links[i], links[j]) })
+	return links, nil
+}
+
+// EachLink iterates over a snapshot so fn may call back into the store;
+// a bbolt write waits for every read transaction to end.
+func (b *Bolt) EachLink(ctx context.Context, fn func(Link) error) error {
+	return b.EachLinkBy(ctx, OrderNewest, fn)
+}
+
+func (b *Bolt) EachLinkBy(ctx context.Context, order LinkOrder, fn func(Link) error) error {
+	links, err := b.listLinks(ctx, order)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bolt) CountLinks(ctx context.Context) (int, error) {
+	var n int
+	err := b.view(ctx, func(tx *bbolt.Tx) error {
+		n = tx.Bucket(boltLinks).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+func (b *Bolt) AddLink(ctx context.Context, link Link) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if boltHasLink(tx, link.Slug) {
+			return ErrConflict
+		}
+		if link.Status == "" {
+			link.Status = StatusActive
+		}
+		link.CreatedAt = time.Now().UTC()
+		link.UpdatedAt = link.CreatedAt
+		return boltPutLink(tx, link)
+	})
+}
+
+func (b *Bolt) CreateLink(ctx context.Context, link Link, aliases, collections []string) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if boltHasLink(tx, link.Slug) {
+			return ErrConflict
+		}
+		for i, alias := range aliases {
+			isAlias := tx.Bucket(boltAliases).Get([]byte(alias)) != nil
+			if boltHasLink(tx, alias) || isAlias || alias == link.Slug || slices.Contains(aliases[:i], alias) {
+				return ErrConflict
+			}
+		}
+
+		if link.Status == "" {
+			link.Status = StatusActive
+		}
+		link.ApprovedBy, link.Clicks, link.ListOpens, link.LastUsedAt = "", 0, 0, nil
+		link.CreatedAt = time.Now().UTC()
+		link.UpdatedAt = link.CreatedAt
+		if err := boltPutLink(tx, link); err != nil {
+			return err
+		}
+		for _, alias := range aliases {
+			if err := tx.Bucket(boltAliases).Put([]byte(alias), []byte(link.Slug)); err != nil {
+				return err
+			}
+		}
+		for _, name := range collections {
+			c, err := boltGetCollection(tx, name)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(c.Slugs, link.Slug) {
+				c.Slugs = append(c.Slugs, link.Slug)
+				if err := boltPutCollection(tx, c); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) RestoreLink(ctx context.Context, link Link) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if boltHasLink(tx, link.Slug) {
+			return ErrConflict
+		}
+		if link.Status == "" {
+			link.Status = StatusActive
+		}
+		if link.CreatedAt.IsZero() {
+			link.CreatedAt = time.Now()
+		}
+		if link.UpdatedAt.IsZero() {
+			link.UpdatedAt = link.CreatedAt
+		}
+		link.CreatedAt, link.UpdatedAt = link.CreatedAt.UTC(), link.UpdatedAt.UTC()
+		// Clicks are kept to the second
+		if link.LastUsedAt != nil {
+			at := link.LastUsedAt.Truncate(time.Second).UTC()
+			link.LastUsedAt = &at
+		}
+		return boltPutLink(tx, link)
+	})
+}
+
+func (b *Bolt) ApproveLink(ctx context.Context, slug, url, approvedBy string) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		link, err := boltGetLink(tx, slug)
+		if errors.Is(err, ErrNotFound) || (err == nil && (link.Status != StatusPending || link.URL != url)) {
+			return ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+		link.Status = StatusActive
+		link.ApprovedBy = approvedBy
+		link.UpdatedAt = time.Now().UTC()
+		return boltPutLink(tx, link)
+	})
+}
+
+// change applies fn to the link at slug and marks it updated, or returns
+// ErrNotFound.
+func (b *Bolt) change(ctx context.Context, slug string, fn func(*Link)) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		link, err := boltGetLink(tx, slug)
+		if err != nil {
+			return err
+		}
+		fn(&link)
+		link.UpdatedAt = time.Now().UTC()
+		return boltPutLink(tx, link)
+	})
+}
+
+func (b *Bolt) UpdateLink(ctx context.Context, link Link) error {
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	return b.change(ctx, link.Slug, func(existing *Link) {
+		existing.URL = link.URL
+		existing.Status = link.Status
+		existing.CreatedBy = link.CreatedBy
+		existing.ApprovedBy = ""
+	})
+}
+
+func (b *Bolt) ReplaceLink(ctx context.Context, link Link) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		existing, err := boltGetLink(tx, link.Slug)
+		if err != nil {
+			return err
+		}
+		if link.Status == "" {
+			link.Status = StatusActive
+		}
+		if link.UpdatedAt.IsZero() {
+			link.UpdatedAt = time.Now()
+		}
+		if link.ReviewAt != nil {
+			at := link.ReviewAt.UTC()
+			link.ReviewAt = &at
+		}
+		if len(link.Access) == 0 {
+			link.Access = nil
+		}
+		if len(link.Fields) == 0 {
+			link.Fields = nil
+		}
+		link.Clicks, link.ListOpens, link.LastUsedAt, link.CreatedAt = existing.Clicks, existing.ListOpens, existing.LastUsedAt, existing.CreatedAt
+		link.UpdatedAt = link.UpdatedAt.UTC()
+		return boltPutLink(tx, link)
+	})
+}
+
+func (b *Bolt) SetPublic(ctx context.Context, slug string, public bool) error {
+	return b.change(ctx, slug, func(link *Link) { link.Public = public })
+}
+
+func (b *Bolt) SetReview(ctx context.Context, slug string, at *time.Time, months int) error {
+	if at != nil {
+		utc := at.UTC()
+		at = &utc
+	}
+	return b.change(ctx, slug, func(link *Link) { link.ReviewAt, link.ReviewMonths = at, months })
+}
+
+func (b *Bolt) SetAccess(ctx context.Context, slug string, rules []AccessRule) error {
+	if len(rules) == 0 {
+		rules = nil
+	}
+	return b.change(ctx, slug, func(link *Link) { link.Access = rules })
+}
+
+func (b *Bolt) SetPin(ctx context.Context, slug string, pin int) error {
+	return b.change(ctx, slug, func(link *Link) { link.Pin = pin })
+}
+
+func (b *Bolt) SetHitBudget(ctx context.Context, slug string, hitsPerDay int) error {
+	return b.change(ctx, slug, func(link *Link) { link.HitBudget = hitsPerDay })
+}
+
+func (b *Bolt) SetFailover(ctx context.Context, slug, url string) error {
+	return b.change(ctx, slug, func(link *Link) { link.Failover = url })
+}
+
+func (b *Bolt) SetReferrer(ctx context.Context, slug, policy string) error {
+	return b.change(ctx, slug, func(link *Link) { link.Referrer = policy })
+}
+
+func (b *Bolt) SetFields(ctx context.Context, slug string, fields map[string]string) error {
+	if len(fields) == 0 {
+		fields = nil
+	}
+	return b.change(ctx, slug, func(link *Link) { link.Fields = fields })
+}
+
+func (b *Bolt) RecordClicks(ctx context.Context, clicks []Click) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		clickBucket, times := tx.Bucket(boltClicks), tx.Bucket(boltClickTimes)
+		for slug, t := range clickTotals(clicks) {
+			link, err := boltGetLink(tx, slug)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			link.Clicks += t.n
+			link.ListOpens += t.fromList
+			if last := time.Unix(t.last, 0).UTC(); link.LastUsedAt == nil || last.After(*link.LastUsedAt) {
+				link.LastUsedAt = &last
+			}
+			if err := boltPutLink(tx, link); err != nil {
+				return err
+			}
+		}
+		for _, c := range clicks {
+			if c.Unsampled || !boltHasLink(tx, c.Slug) {
+				continue
+			}
+			seq, err := clickBucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := boltTimeKey(c.At.Unix(), seq)
+			if err := clickBucket.Put(append(boltClickPrefix(c.Slug), key...), boltWeightValue(c.weight())); err != nil {
+				return err
+			}
+			if err := times.Put(key, boltTimeValue(c.Slug, c.weight())); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// eachClickSince calls fn with the slug, time and weight of every click
+// since since, of slug or of every link if slug is empty, oldest first.
+func (b *Bolt) eachClickSince(ctx context.Context, slug string, since time.Time, fn func(slug string, at time.Time, weight int)) error {
+	from := boltTimeKey(since.Unix(), 0)
+	return b.view(ctx, func(tx *bbolt.Tx) error {
+		if slug == "" {
+			c := tx.Bucket(boltClickTimes).Cursor()
+			for k, v := c.Seek(from); k != nil; k, v = c.Next() {
+				slug, weight := boltSplitTimeValue(v)
+				fn(slug, boltClickTime(k), weight)
+			}
+			return nil
+		}
+		prefix := boltClickPrefix(slug)
+		c := tx.Bucket(boltClicks).Cursor()
+		for k, v := c.Seek(append(prefix, from...)); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			fn(slug, boltClickTime(k), boltWeight(v))
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) ClickCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := b.eachClickSince(ctx, "", since, func(slug string, _ time.Time, weight int) { counts[slug] += weight })
+	return counts, err
+}
+
+func (b *Bolt) ClickTimes(ctx context.Context, slug string, since time.Time) ([]time.Time, error) {
+	var times []time.Time
+	err := b.eachClickSince(ctx, slug, since, func(_ string, at time.Time, weight int) { times = appendClickTimes(times, at, weight) })
+	return times, err
+}
+
+func (b *Bolt) ClickDays(ctx context.Context, slug string, since time.Time, loc *time.Location) (map[string]int, error) {
+	days := make(map[string]int)
+	err := b.eachClickSince(ctx, slug, since, func(_ string, at time.Time, weight int) { days[at.In(loc).Format(time.DateOnly)] += weight })
+	return days, err
+}
+
+func (b *Bolt) PruneClicks(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	end := boltTimeKey(before.Unix(), 0)
+	err := b.update(ctx, func(tx *bbolt.Tx) error {
+		clickBucket, times := tx.Bucket(boltClicks), tx.Bucket(boltClickTimes)
+		// Deleting under a cursor can skip keys, so collect them first
+		var keys, slugs [][]byte
+		c := times.Cursor()
+		for k, v := c.First(); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			keys, slugs = append(keys, bytes.Clone(k)), append(slugs, bytes.Clone(v))
+		}
+		for i, key := range keys {
+			slug, _ := boltSplitTimeValue(slugs[i])
+			if err := clickBucket.Delete(append(boltClickPrefix(slug), key...)); err != nil {
+				return err
+			}
+			if err := times.Delete(key); err != nil {
+				return err
+			}
+		}
+		n = int64(len(keys))
+		return nil
+	})
+	return n, err
+}
+
+// boltClickKeys returns the keys of every click of slug in clicks.
+func boltClickKeys(tx *bbolt.Tx, slug string) [][]byte {
+	var keys [][]byte
+	prefix := boltClickPrefix(slug)
+	c := tx.Bucket(boltClicks).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, bytes.Clone(k))
+	}
+	return keys
+}
+
+// boltEditCollections applies fn to the slugs of every collection and
+// saves those it changed.
+func boltEditCollections(tx *bbolt.Tx, fn func(slugs []string) []string) error {
+	var changed []Collection
+	err := tx.Bucket(boltCollections).ForEach(func(k, v []byte) error {
+		var c Collection
+		if err := json.Unmarshal(v, &c); err != nil {
+			return fmt.Errorf("collection %s: %w", k, err)
+		}
+		if slugs := fn(slices.Clone(c.Slugs)); !slices.Equal(slugs, c.Slugs) {
+			c.Slugs = slugs
+			changed = append(changed, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range changed {
+		if err := boltPutCollection(tx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// boltAliasesOf returns the aliases pointing at slug.
+func boltAliasesOf(tx *bbolt.Tx, slug string) [][]byte {
+	var aliases [][]byte
+	tx.Bucket(boltAliases).ForEach(func(k, v []byte) error {
+		if string(v) == slug {
+			aliases = append(aliases, bytes.Clone(k))
+		}
+		return nil
+	})
+	return aliases
+}
+
+func (b *Bolt) RemoveLink(ctx context.Context, slug string) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if !boltHasLink(tx, slug) {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(boltLinks).Delete([]byte(slug)); err != nil {
+			return err
+		}
+		for _, key := range boltClickKeys(tx, slug) {
+			if err := tx.Bucket(boltClickTimes).Delete(key[len(key)-16:]); err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltClicks).Delete(key); err != nil {
+				return err
+			}
+		}
+		err := boltEditCollections(tx, func(slugs []string) []string {
+			return slices.DeleteFunc(slugs, func(s string) bool { return s == slug })
+		})
+		if err != nil {
+			return err
+		}
+		for _, alias := range boltAliasesOf(tx, slug) {
+			if err := tx.Bucket(boltAliases).Delete(alias); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) RenameLink(ctx context.Context, from, to string, alias bool) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if boltHasLink(tx, to) {
+			return ErrConflict
+		}
+		link, err := boltGetLink(tx, from)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltLinks).Delete([]byte(from)); err != nil {
+			return err
+		}
+		link.Slug = to
+		link.UpdatedAt = time.Now().UTC()
+		if err := boltPutLink(tx, link); err != nil {
+			return err
+		}
+
+		clickBucket, times := tx.Bucket(boltClicks), tx.Bucket(boltClickTimes)
+		for _, key := range boltClickKeys(tx, from) {
+			timeKey, value := key[len(key)-16:], bytes.Clone(clickBucket.Get(key))
+			if err := clickBucket.Put(append(boltClickPrefix(to), timeKey...), value); err != nil {
+				return err
+			}
+			if err := times.Put(timeKey, boltTimeValue(to, boltWeight(value))); err != nil {
+				return err
+			}
+			if err := clickBucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		err = boltEditCollections(tx, func(slugs []string) []string {
+			if i := slices.Index(slugs, from); i >= 0 {
+				if slices.Contains(slugs, to) {
+					return slices.Delete(slugs, i, i+1)
+				}
+				slugs[i] = to
+			}
+			return slugs
+		})
+		if err != nil {
+			return err
+		}
+
+		aliases := tx.Bucket(boltAliases)
+		if err := aliases.Delete([]byte(to)); err != nil {
+			return err
+		}
+		for _, old := range boltAliasesOf(tx, from) {
+			if err := aliases.Put(old, []byte(to)); err != nil {
+				return err
+			}
+		}
+		if alias {
+			return aliases.Put([]byte(from), []byte(to))
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) ResolveAlias(ctx context.Context, slug string) (string, error) {
+	var target string
+	err := b.view(ctx, func(tx *bbolt.Tx) error {
+		v := tx.Bucket(boltAliases).Get([]byte(slug))
+		if v == nil {
+			return ErrNotFound
+		}
+		target = string(v)
+		return nil
+	})
+	return target, err
+}
+
+func (b *Bolt) ListAliases(ctx context.Context) ([]Alias, error) {
+	aliases := []Alias{}
+	err := b.view(ctx, func(tx *bbolt.Tx) error {
+		// Keys are sorted, so the aliases come by slug
+		return tx.Bucket(boltAliases).ForEach(func(k, v []byte) error {
+			aliases = append(aliases, Alias{Slug: string(k), Target: string(v)})
+			return nil
+		})
+	})
+	return aliases, err
+}
+
+func (b *Bolt) SetAlias(ctx context.Context, a Alias) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if boltHasLink(tx, a.Slug) {
+			return ErrConflict
+		}
+		if !boltHasLink(tx, a.Target) {
+			return ErrNotFound
+		}
+		return tx.Bucket(boltAliases).Put([]byte(a.Slug), []byte(a.Target))
+	})
+}
+
+func (b *Bolt) RemoveAlias(ctx context.Context, slug string) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if tx.Bucket(boltAliases).Get([]byte(slug)) == nil {
+			return ErrNotFound
+		}
+		return tx.Bucket(boltAliases).Delete([]byte(slug))
+	})
+}
+
+// boltGetCollection reads the named collection, or returns ErrNotFound.
+func boltGetCollection(tx *bbolt.Tx, name string) (Collection, error) {
+	data := tx.Bucket(boltCollections).Get([]byte(name))
+	if data == nil {
+		return Collection{}, ErrNotFound
+	}
+	var c Collection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Collection{}, fmt.Errorf("collection %s: %w", name, err)
+	}
+	return c, nil
+}
+
+func boltPutCollection(tx *bbolt.Tx, c Collection) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltCollections).Put([]byte(c.Name), data)
+}
+
+func (b *Bolt) SaveCollection(ctx context.Context, c Collection) error {
+	slugs := []string{}
+	for _, slug := range c.Slugs {
+		if !slices.Contains(slugs, slug) {
+			slugs = append(slugs, slug)
+		}
+	}
+	c.Slugs = slugs
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		existing, err := boltGetCollection(tx, c.Name)
+		switch {
+		case err == nil:
+			c.CreatedBy, c.CreatedAt = existing.CreatedBy, existing.CreatedAt
+		case errors.Is(err, ErrNotFound):
+			c.CreatedAt = time.Now().UTC()
+		default:
+			return err
+		}
+		return boltPutCollection(tx, c)
+	})
+}
+
+func (b *Bolt) GetCollection(ctx context.Context, name string) (*Collection, error) {
+	var c Collection
+	err := b.view(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		c, err = boltGetCollection(tx, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListCollections returns the collections in key order, which is by name.
+func (b *Bolt) ListCollections(ctx context.Context) ([]Collection, error) {
+	collections := []Collection{}
+	err := b.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltCollections).ForEach(func(k, v []byte) error {
+			var c Collection
+			if err := json.Unmarshal(v, &c); err != nil {
+				return fmt.Errorf("collection %s: %w", k, err)
+			}
+			collections = append(collections, c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+func (b *Bolt) RemoveCollection(ctx context.Context, name string) error {
+	return b.update(ctx, func(tx *bbolt.Tx) error {
+		if tx.Bucket(boltCollections).Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		return tx.Bucket(boltCollections).Delete([]byte(name))
+	})
+}
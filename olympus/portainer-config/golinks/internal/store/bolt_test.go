@@ -0,0 +1,93 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestBolt opens a new bbolt file, closed when the test ends.
+func openTestBolt(t *testing.T) *Bolt {
+	t.Helper()
+	b, err := OpenBolt(filepath.Join(t.TempDir(), "data", "links.bolt"))
+	if err != nil {
+		t.Fatalf("OpenBolt: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestBolt(t *testing.T) {
+	testStore(t, openTestBolt(t))
+	testLinkOrders(t, openTestBolt(t))
+	testCreateLink(t, openTestBolt(t))
+}
+
+func TestBoltClicks(t *testing.T) {
+	ctx := context.Background()
+	b := openTestBolt(t)
+	b.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	b.AddLink(ctx, Link{Slug: "wik", URL: "https://wik.example.com"})
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b.RecordClicks(ctx, []Click{
+		{Slug: "wiki", At: day.Add(-48 * time.Hour)},
+		{Slug: "wiki", At: day, Weight: 2},
+		{Slug: "wik", At: day},
+		{Slug: "gone", At: day},
+	})
+
+	// A slug that is a prefix of another only counts its own clicks
+	if times, _ := b.ClickTimes(ctx, "wik", day.Add(-72*time.Hour)); len(times) != 1 {
+		t.Errorf("ClickTimes(wik) = %v", times)
+	}
+	if counts, _ := b.ClickCounts(ctx, day.Add(-time.Hour)); counts["wiki"] != 2 || counts["wik"] != 1 || len(counts) != 2 {
+		t.Errorf("ClickCounts = %v", counts)
+	}
+
+	if err := b.RenameLink(ctx, "wiki", "docs", false); err != nil {
+		t.Fatal(err)
+	}
+	// A sampled click keeps its weight
+	if times, _ := b.ClickTimes(ctx, "docs", time.Time{}); len(times) != 3 {
+		t.Errorf("ClickTimes after rename = %v", times)
+	}
+	if n, err := b.PruneClicks(ctx, day.Add(-time.Hour)); err != nil || n != 1 {
+		t.Errorf("PruneClicks = %d, %v", n, err)
+	}
+	if days, _ := b.ClickDays(ctx, "", time.Time{}, time.UTC); days["2026-03-01"] != 3 || len(days) != 1 {
+		t.Errorf("ClickDays after prune = %v", days)
+	}
+	b.RemoveLink(ctx, "docs")
+	if counts, _ := b.ClickCounts(ctx, time.Time{}); len(counts) != 1 {
+		t.Errorf("ClickCounts after remove = %v", counts)
+	}
+}
+
+func TestBoltBackup(t *testing.T) {
+	ctx := context.Background()
+	b := openTestBolt(t)
+	b.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	var buf bytes.Buffer
+	if err := b.Backup(ctx, &buf); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if !IsBoltFile(buf.Bytes()) || IsBoltFile([]byte("SQLite format 3\x00 and then some")) {
+		t.Error("IsBoltFile does not tell a backup from SQLite")
+	}
+	path := filepath.Join(t.TempDir(), "restored.bolt")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := OpenBolt(path)
+	if err != nil {
+		t.Fatalf("OpenBolt of the backup: %v", err)
+	}
+	defer restored.Close()
+	if link, err := restored.GetLink(ctx, "wiki"); err != nil || link.URL != "https://wiki.example.com" {
+		t.Errorf("GetLink of the backup = %+v, %v", link, err)
+	}
+}
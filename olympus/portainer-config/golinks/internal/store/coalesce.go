@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// Coalescing wraps a Store so that concurrent GetLink calls for the same
+// slug share a single lookup in the underlying store. All other methods pass
+// straight through.
+type Coalescing struct {
+	Store
+
+	mu    sync.Mutex
+	calls map[string]*lookup
+}
+
+// lookup is an in-flight GetLink shared by every caller asking for the slug.
+type lookup struct {
+	done chan struct{}
+	link Link
+	err  error
+}
+
+func Coalesce(st Store) *Coalescing {
+	return &Coalescing{Store: st, calls: make(map[string]*lookup)}
+}
+
+func (c *Coalescing) GetLink(ctx context.Context, slug string) (*Link, error) {
+	c.mu.Lock()
+	call, ok := c.calls[slug]
+	if !ok {
+		call = &lookup{done: make(chan struct{})}
+		c.calls[slug] = call
+		c.mu.Unlock()
+
+		// The shared lookup must not fail for everyone just because the
+		// first caller went away; the store's own timeout still applies.
+		go c.run(context.WithoutCancel(ctx), slug, call)
+	} else {
+		c.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return call.result()
+}
+
+func (c *Coalescing) run(ctx context.Context, slug string, call *lookup) {
+	link, err := c.Store.GetLink(ctx, slug)
+	if err == nil {
+		call.link = *link
+	}
+	call.err = err
+
+	c.mu.Lock()
+	delete(c.calls, slug)
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// result returns a copy of the shared link so callers can't mutate each
+// other's results.
+func (call *lookup) result() (*Link, error) {
+	if call.err != nil {
+		return nil, call.err
+	}
+	link := call.link
+	return &link, nil
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingStore counts GetLink calls and holds them until release is closed.
+type blockingStore struct {
+	*Memory
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (b *blockingStore) GetLink(ctx context.Context, slug string) (*Link, error) {
+	b.calls.Add(1)
+	<-b.release
+	return b.Memory.GetLink(ctx, slug)
+}
+
+func TestCoalesceConcurrentLookups(t *testing.T) {
+	ctx := context.Background()
+	backend := &blockingStore{Memory: NewMemory(), release: make(chan struct{})}
+	backend.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	c := Coalesce(backend)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link, err := c.GetLink(ctx, "wiki")
+			if err == nil && link.URL != "https://wiki.example.com" {
+				err = errors.New("wrong link " + link.URL)
+			}
+			errs <- err
+		}()
+	}
+
+	// Give every goroutine time to join the in-flight lookup
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetLink: %v", err)
+		}
+	}
+	if got := backend.calls.Load(); got != 1 {
+		t.Errorf("backend GetLink called %d times, want 1", got)
+	}
+
+	// Once finished, the next lookup goes to the store again
+	if _, err := c.GetLink(ctx, "wiki"); err != nil {
+		t.Fatalf("GetLink: %v", err)
+	}
+	if got := backend.calls.Load(); got != 2 {
+		t.Errorf("backend GetLink called %d times, want 2", got)
+	}
+}
+
+func TestCoalesceNotFound(t *testing.T) {
+	c := Coalesce(NewMemory())
+	if _, err := c.GetLink(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLink missing = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCoalesceCallerCanceled(t *testing.T) {
+	backend := &blockingStore{Memory: NewMemory(), release: make(chan struct{})}
+	defer close(backend.release)
+	c := Coalesce(backend)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetLink(ctx, "wiki"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetLink with expired context = %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,305 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// dialect is what differs between the database servers a Server talks to.
+// Queries are written once with PostgreSQL's numbered parameters ($1, $2)
+// and bound to each server's style by bind.
+type dialect struct {
+	name   string
+	driver string
+	// numbered is whether the server takes $n parameters rather than ?.
+	numbered bool
+	// migrations is the schema history; version i is migrations[i].
+	// Statements of one step are separated by semicolons.
+	migrations []serverMigration
+	// versionTable creates the schema_version table if it is missing.
+	versionTable string
+	// lock takes the lock replicas hold while migrating on conn; unlock
+	// releases it.
+	lock   func(ctx context.Context, conn *sql.Conn) error
+	unlock func(conn *sql.Conn)
+	// upsertCollection inserts a collection ($1 name, $2 title, $3
+	// description, $4 created_by) or updates the title and description of
+	// an existing one.
+	upsertCollection string
+	// isConflict reports whether err is a unique key violation.
+	isConflict func(err error) bool
+}
+
+// serverMigration is one step of a dialect's schema history.
+type serverMigration struct {
+	name string
+	ddl  string
+}
+
+// migrationLock is the advisory lock replicas take while migrating, so only
+// one of them changes the schema when they start together.
+const migrationLock = 0x676f6c696e6b73 // "golinks"
+
+// dialects are the servers OpenServer knows, by URL scheme.
+var dialects = map[string]*dialect{
+	"postgres":   postgresDialect,
+	"postgresql": postgresDialect,
+	"mysql":      mysqlDialect,
+}
+
+var postgresDialect = &dialect{
+	name:     "PostgreSQL",
+	driver:   "postgres",
+	numbered: true,
+	migrations: []serverMigration{
+		// Slugs sort by their bytes, as in SQLite, so every replica and
+		// backend pages through links in the same order. Times that are
+		// compared or paged on are Unix seconds, as in SQLite.
+		1: {"create schema", `
+			CREATE TABLE links (
+				slug TEXT COLLATE "C" PRIMARY KEY,
+				url TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'active',
+				created_by TEXT NOT NULL DEFAULT '',
+				approved_by TEXT NOT NULL DEFAULT '',
+				public BOOLEAN NOT NULL DEFAULT FALSE,
+				review_at TIMESTAMPTZ,
+				review_months INTEGER NOT NULL DEFAULT 0,
+				access_rules TEXT NOT NULL DEFAULT '',
+				clicks BIGINT NOT NULL DEFAULT 0,
+				last_used BIGINT NOT NULL DEFAULT 0,
+				pin INTEGER NOT NULL DEFAULT 0,
+				hit_budget INTEGER NOT NULL DEFAULT 0,
+				failover TEXT NOT NULL DEFAULT '',
+				referrer TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			);
+			CREATE INDEX idx_links_created_at ON links (created_at);
+			CREATE INDEX idx_links_clicks ON links (clicks DESC, slug);
+			CREATE INDEX idx_links_last_used ON links (last_used DESC, slug);
+			CREATE INDEX idx_links_pin ON links (pin DESC, slug);
+			CREATE TABLE collections (
+				name TEXT COLLATE "C" PRIMARY KEY,
+				title TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				created_by TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			);
+			CREATE TABLE collection_links (
+				collection TEXT NOT NULL,
+				slug TEXT NOT NULL,
+				position INTEGER NOT NULL,
+				PRIMARY KEY (collection, slug)
+			);
+			CREATE INDEX idx_collection_links_slug ON collection_links (slug);
+			CREATE TABLE clicks (
+				id BIGSERIAL PRIMARY KEY,
+				slug TEXT NOT NULL,
+				at BIGINT NOT NULL
+			);
+			CREATE INDEX idx_clicks_at ON clicks (at);
+			CREATE INDEX idx_clicks_slug ON clicks (slug);
+			CREATE TABLE aliases (
+				slug TEXT PRIMARY KEY,
+				target TEXT NOT NULL,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			);
+			CREATE INDEX idx_aliases_target ON aliases (target)`},
+		2: {"add list opens", `ALTER TABLE links ADD COLUMN list_opens BIGINT NOT NULL DEFAULT 0`},
+		3: {"add link update times", `
+			ALTER TABLE links ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+			UPDATE links SET updated_at = created_at`},
+		4: {"add custom fields", `ALTER TABLE links ADD COLUMN fields TEXT NOT NULL DEFAULT ''`},
+		5: {"add click weights", `ALTER TABLE clicks ADD COLUMN weight INTEGER NOT NULL DEFAULT 1`},
+	},
+	versionTable: `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLock)
+		return err
+	},
+	unlock: func(conn *sql.Conn) {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLock)
+	},
+	upsertCollection: `INSERT INTO collections (name, title, description, created_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET title = excluded.title, description = excluded.description`,
+	isConflict: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23505"
+	},
+}
+
+var mysqlDialect = &dialect{
+	name:   "MySQL",
+	driver: "mysql",
+	migrations: []serverMigration{
+		// The same schema as PostgreSQL's. utf8mb4_bin compares slugs by
+		// their bytes and keeps emoji; keys are VARCHARs, since MySQL
+		// cannot index TEXT whole. DDL is not transactional in MySQL, so a
+		// step that fails half way has to be cleaned up by hand.
+		1: {"create schema", `
+			CREATE TABLE links (
+				slug VARCHAR(255) NOT NULL PRIMARY KEY,
+				url TEXT NOT NULL,
+				status VARCHAR(16) NOT NULL DEFAULT 'active',
+				created_by VARCHAR(255) NOT NULL DEFAULT '',
+				approved_by VARCHAR(255) NOT NULL DEFAULT '',
+				public BOOLEAN NOT NULL DEFAULT FALSE,
+				review_at DATETIME(6) NULL,
+				review_months INTEGER NOT NULL DEFAULT 0,
+				access_rules TEXT NOT NULL DEFAULT (''),
+				clicks BIGINT NOT NULL DEFAULT 0,
+				last_used BIGINT NOT NULL DEFAULT 0,
+				pin INTEGER NOT NULL DEFAULT 0,
+				hit_budget INTEGER NOT NULL DEFAULT 0,
+				failover TEXT NOT NULL DEFAULT (''),
+				referrer VARCHAR(64) NOT NULL DEFAULT '',
+				created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+				INDEX idx_links_created_at (created_at),
+				INDEX idx_links_clicks (clicks DESC, slug),
+				INDEX idx_links_last_used (last_used DESC, slug),
+				INDEX idx_links_pin (pin DESC, slug)
+			) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+			CREATE TABLE collections (
+				name VARCHAR(255) NOT NULL PRIMARY KEY,
+				title VARCHAR(255) NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT (''),
+				created_by VARCHAR(255) NOT NULL DEFAULT '',
+				created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+			) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+			CREATE TABLE collection_links (
+				collection VARCHAR(255) NOT NULL,
+				slug VARCHAR(255) NOT NULL,
+				position INTEGER NOT NULL,
+				PRIMARY KEY (collection, slug),
+				INDEX idx_collection_links_slug (slug)
+			) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+			CREATE TABLE clicks (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				slug VARCHAR(255) NOT NULL,
+				at BIGINT NOT NULL,
+				INDEX idx_clicks_at (at),
+				INDEX idx_clicks_slug (slug)
+			) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+			CREATE TABLE aliases (
+				slug VARCHAR(255) NOT NULL PRIMARY KEY,
+				target VARCHAR(255) NOT NULL,
+				created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+				INDEX idx_aliases_target (target)
+			) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin`},
+		2: {"add list opens", `ALTER TABLE links ADD COLUMN list_opens BIGINT NOT NULL DEFAULT 0`},
+		3: {"add link update times", `
+			ALTER TABLE links ADD COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6);
+			UPDATE links SET updated_at = created_at`},
+		4: {"add custom fields", `ALTER TABLE links ADD COLUMN fields TEXT NOT NULL DEFAULT ('')`},
+		5: {"add click weights", `ALTER TABLE clicks ADD COLUMN weight INTEGER NOT NULL DEFAULT 1`},
+	},
+	versionTable: `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+		)`,
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		// GET_LOCK gives up after its timeout rather than ctx's
+		wait := 30
+		if deadline, ok := ctx.Deadline(); ok {
+			wait = max(1, int(time.Until(deadline).Seconds()))
+		}
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", strconv.Itoa(migrationLock), wait).Scan(&got); err != nil {
+			return err
+		}
+		if got.Int64 != 1 {
+			return errors.New("timed out waiting for another replica")
+		}
+		return nil
+	},
+	unlock: func(conn *sql.Conn) {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", strconv.Itoa(migrationLock))
+	},
+	upsertCollection: `INSERT INTO collections (name, title, description, created_by) VALUES ($1, $2, $3, $4)
+		ON DUPLICATE KEY UPDATE title = VALUES(title), description = VALUES(description)`,
+	isConflict: func(err error) bool {
+		var myErr *mysql.MySQLError
+		return errors.As(err, &myErr) && myErr.Number == 1062 // ER_DUP_ENTRY
+	},
+}
+
+// bind rewrites the $n parameters of query for d and orders args to match.
+// For numbered servers the parameters are renumbered in order of use, so a
+// query may leave some of args unused, which PostgreSQL rejects otherwise.
+func (d *dialect) bind(query string, args []any) (string, []any) {
+	var b strings.Builder
+	var bound []any
+	number := map[int]int{}
+	for i := 0; i < len(query); i++ {
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+		if query[i] != '$' || j == i+1 {
+			b.WriteByte(query[i])
+			continue
+		}
+		n, _ := strconv.Atoi(query[i+1 : j])
+		i = j - 1
+		if !d.numbered {
+			b.WriteByte('?')
+			bound = append(bound, args[n-1])
+			continue
+		}
+		if _, ok := number[n]; !ok {
+			bound = append(bound, args[n-1])
+			number[n] = len(bound)
+		}
+		b.WriteString("$" + strconv.Itoa(number[n]))
+	}
+	return b.String(), bound
+}
+
+// conflict turns the unique violations of concurrent writers, which SQLite
+// never sees since it has a single writer, into ErrConflict.
+func (d *dialect) conflict(err error) error {
+	if err != nil && d.isConflict(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// dataSource turns a database URL into d's driver connection string.
+func (d *dialect) dataSource(rawURL string) (string, error) {
+	if d != mysqlDialect {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	// The query holds the driver's options, such as tls
+	cfg, err := mysql.ParseDSN("tcp(" + u.Host + ")/?" + u.RawQuery)
+	if err != nil {
+		return "", err
+	}
+	cfg.DBName = strings.TrimPrefix(u.Path, "/")
+	cfg.User = u.User.Username()
+	cfg.Passwd, _ = u.User.Password()
+	// Times come back as time.Time in UTC, and an update that changes
+	// nothing still counts the rows it matched, as in the other backends
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.ClientFoundRows = true
+	return cfg.FormatDSN(), nil
+}
@@ -0,0 +1,315 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LRUCache wraps a Store so that the most recently used links are kept in
+// memory and their redirects don't reach the database at all. Changes made
+// through the cache drop the link from it, and clicks are added to the
+// cached link instead, so a busy link stays cached. With a MissTTL, slugs
+// recently found to have neither a link nor an alias are remembered too,
+// apart from the links so a storm of them can't push the links out, until
+// a link or alias is added at them. Changes made around the cache, such as
+// by another instance sharing the database, go unseen for up to the TTL.
+// All other methods pass straight through.
+type LRUCache struct {
+	Store
+
+	ttl, missTTL time.Duration
+
+	mu sync.Mutex
+	// links holds the cached links, misses the slugs without one.
+	links, misses *lruList
+	// gen counts the changes to cached slugs. A lookup only caches its
+	// result if no change happened while it read the store, which might
+	// have missed it.
+	gen         uint64
+	hits, reads uint64
+}
+
+type lruEntry struct {
+	slug string
+	link Link
+	// noAlias is whether a miss is known to have no alias either.
+	noAlias bool
+	expires time.Time
+}
+
+// lruList holds up to size entries, the most recently used first.
+type lruList struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newLRUList(size int) *lruList {
+	return &lruList{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the entry of slug unless it expired by now, and marks it
+// used.
+func (l *lruList) get(slug string, now time.Time) *lruEntry {
+	el, ok := l.entries[slug]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*lruEntry)
+	if !now.Before(e.expires) {
+		l.remove(el)
+		return nil
+	}
+	l.order.MoveToFront(el)
+	return e
+}
+
+// put adds e in place of any entry of its slug, making room if needed.
+func (l *lruList) put(e *lruEntry) {
+	l.drop(e.slug)
+	l.entries[e.slug] = l.order.PushFront(e)
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *lruList) drop(slug string) {
+	if el, ok := l.entries[slug]; ok {
+		l.remove(el)
+	}
+}
+
+func (l *lruList) remove(el *list.Element) {
+	delete(l.entries, el.Value.(*lruEntry).slug)
+	l.order.Remove(el)
+}
+
+// LRUOptions tunes an LRUCache.
+type LRUOptions struct {
+	// Size is how many links are kept at most, 1000 if zero, and as many
+	// misses.
+	Size int
+	// TTL is how long a link stays cached, 10 minutes if zero.
+	TTL time.Duration
+	// MissTTL is how long a slug without a link stays cached; misses are
+	// not cached if zero.
+	MissTTL time.Duration
+}
+
+// CacheStats counts the lookups of a cache.
+type CacheStats struct {
+	// Hits are the lookups answered by the cache, those of a cached miss
+	// included; Misses are those that read the store.
+	Hits   uint64
+	Misses uint64
+	// Size is how many links are cached now, of at most Capacity, and
+	// NotFound how many slugs without one.
+	Size     int
+	Capacity int
+	NotFound int
+}
+
+// NewLRUCache puts an LRUCache in front of st. Closing the cache closes st.
+func NewLRUCache(st Store, opts LRUOptions) *LRUCache {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 10 * time.Minute
+	}
+	return &LRUCache{Store: st, ttl: opts.TTL, missTTL: opts.MissTTL, links: newLRUList(opts.Size), misses: newLRUList(opts.Size)}
+}
+
+// CacheStats returns the lookups so far and how full the cache is.
+func (c *LRUCache) CacheStats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.reads, Size: c.links.order.Len(), Capacity: c.links.size, NotFound: c.misses.order.Len()}
+}
+
+// GetLink returns the cached link, or reads it from the store and caches
+// it. With a MissTTL, a slug without a link is cached as such.
+func (c *LRUCache) GetLink(ctx context.Context, slug string) (*Link, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if e := c.links.get(slug, now); e != nil {
+		c.hits++
+		link := e.link
+		c.mu.Unlock()
+		return &link, nil
+	}
+	if c.misses.get(slug, now) != nil {
+		c.hits++
+		c.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	c.reads++
+	gen := c.gen
+	c.mu.Unlock()
+
+	link, err := c.Store.GetLink(ctx, slug)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.remember(gen, func() {
+			if c.missTTL > 0 {
+				c.misses.put(&lruEntry{slug: slug, expires: now.Add(c.missTTL)})
+			}
+		})
+		return nil, err
+	case err != nil:
+		return nil, err
+	}
+	c.remember(gen, func() { c.links.put(&lruEntry{slug: slug, link: *link, expires: now.Add(c.ttl)}) })
+	return link, nil
+}
+
+// ResolveAlias returns ErrNotFound for a cached miss known to have no
+// alias either, or else asks the store, and caches that a miss has none.
+func (c *LRUCache) ResolveAlias(ctx context.Context, slug string) (string, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if e := c.misses.get(slug, now); e != nil && e.noAlias {
+		c.hits++
+		c.mu.Unlock()
+		return "", ErrNotFound
+	}
+	c.reads++
+	gen := c.gen
+	c.mu.Unlock()
+
+	target, err := c.Store.ResolveAlias(ctx, slug)
+	if errors.Is(err, ErrNotFound) {
+		c.remember(gen, func() {
+			// Only a slug known to have no link is cached; the alias of
+			// one is not looked up on redirects
+			if e := c.misses.get(slug, now); e != nil {
+				e.noAlias = true
+			}
+		})
+	}
+	return target, err
+}
+
+// remember runs put, which changes the cache, unless a change happened
+// since gen.
+func (c *LRUCache) remember(gen uint64, put func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		put()
+	}
+}
+
+// forget drops slugs from the cache once err shows their change was made.
+func (c *LRUCache) forget(err error, slugs ...string) error {
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, slug := range slugs {
+		c.links.drop(slug)
+		c.misses.drop(slug)
+	}
+	return nil
+}
+
+func (c *LRUCache) AddLink(ctx context.Context, link Link) error {
+	return c.forget(c.Store.AddLink(ctx, link), link.Slug)
+}
+
+func (c *LRUCache) CreateLink(ctx context.Context, link Link, aliases, collections []string) error {
+	return c.forget(c.Store.CreateLink(ctx, link, aliases, collections), append([]string{link.Slug}, aliases...)...)
+}
+
+func (c *LRUCache) RestoreLink(ctx context.Context, link Link) error {
+	return c.forget(c.Store.RestoreLink(ctx, link), link.Slug)
+}
+
+func (c *LRUCache) RemoveLink(ctx context.Context, slug string) error {
+	return c.forget(c.Store.RemoveLink(ctx, slug), slug)
+}
+
+func (c *LRUCache) RenameLink(ctx context.Context, from, to string, alias bool) error {
+	return c.forget(c.Store.RenameLink(ctx, from, to, alias), from, to)
+}
+
+func (c *LRUCache) SetAlias(ctx context.Context, a Alias) error {
+	return c.forget(c.Store.SetAlias(ctx, a), a.Slug)
+}
+
+func (c *LRUCache) RemoveAlias(ctx context.Context, slug string) error {
+	return c.forget(c.Store.RemoveAlias(ctx, slug), slug)
+}
+
+func (c *LRUCache) ApproveLink(ctx context.Context, slug, url, approvedBy string) error {
+	return c.forget(c.Store.ApproveLink(ctx, slug, url, approvedBy), slug)
+}
+
+func (c *LRUCache) ReplaceLink(ctx context.Context, link Link) error {
+	return c.forget(c.Store.ReplaceLink(ctx, link), link.Slug)
+}
+
+func (c *LRUCache) UpdateLink(ctx context.Context, link Link) error {
+	return c.forget(c.Store.UpdateLink(ctx, link), link.Slug)
+}
+
+func (c *LRUCache) SetPublic(ctx context.Context, slug string, public bool) error {
+	return c.forget(c.Store.SetPublic(ctx, slug, public), slug)
+}
+
+func (c *LRUCache) SetReview(ctx context.Context, slug string, at *time.Time, months int) error {
+	return c.forget(c.Store.SetReview(ctx, slug, at, months), slug)
+}
+
+func (c *LRUCache) SetAccess(ctx context.Context, slug string, rules []AccessRule) error {
+	return c.forget(c.Store.SetAccess(ctx, slug, rules), slug)
+}
+
+func (c *LRUCache) SetPin(ctx context.Context, slug string, pin int) error {
+	return c.forget(c.Store.SetPin(ctx, slug, pin), slug)
+}
+
+func (c *LRUCache) SetHitBudget(ctx context.Context, slug string, hitsPerDay int) error {
+	return c.forget(c.Store.SetHitBudget(ctx, slug, hitsPerDay), slug)
+}
+
+func (c *LRUCache) SetFailover(ctx context.Context, slug, url string) error {
+	return c.forget(c.Store.SetFailover(ctx, slug, url), slug)
+}
+
+func (c *LRUCache) SetReferrer(ctx context.Context, slug, policy string) error {
+	return c.forget(c.Store.SetReferrer(ctx, slug, policy), slug)
+}
+
+func (c *LRUCache) SetFields(ctx context.Context, slug string, fields map[string]string) error {
+	return c.forget(c.Store.SetFields(ctx, slug, fields), slug)
+}
+
+// RecordClicks adds the clicks to the cached links as the store does, so
+// the links people use most stay cached while they are used.
+func (c *LRUCache) RecordClicks(ctx context.Context, clicks []Click) error {
+	if err := c.Store.RecordClicks(ctx, clicks); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for slug, t := range clickTotals(clicks) {
+		el, ok := c.links.entries[slug]
+		if !ok {
+			continue
+		}
+		link := &el.Value.(*lruEntry).link
+		link.Clicks += t.n
+		link.ListOpens += t.fromList
+		if last := time.Unix(t.last, 0).UTC(); link.LastUsedAt == nil || last.After(*link.LastUsedAt) {
+			link.LastUsedAt = &last
+		}
+	}
+	return nil
+}
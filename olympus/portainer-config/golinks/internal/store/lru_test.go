@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	testStore(t, NewLRUCache(NewMemory(), LRUOptions{}))
+
+	ctx := context.Background()
+	backend := &countingStore{Memory: NewMemory()}
+	c := NewLRUCache(backend, LRUOptions{Size: 2})
+	for _, slug := range []string{"wiki", "cal", "mail"} {
+		c.AddLink(ctx, Link{Slug: slug, URL: "https://" + slug + ".example.com"})
+	}
+	for i := 0; i < 3; i++ {
+		if link, err := c.GetLink(ctx, "wiki"); err != nil || link.URL != "https://wiki.example.com" {
+			t.Fatalf("GetLink = %+v, %v", link, err)
+		}
+	}
+	if n := backend.reads.Load(); n != 1 {
+		t.Errorf("%d database reads, want 1", n)
+	}
+
+	// Callers can't change the cached link
+	link, _ := c.GetLink(ctx, "wiki")
+	link.URL = "https://evil.example.com"
+	if link, _ := c.GetLink(ctx, "wiki"); link.URL != "https://wiki.example.com" {
+		t.Errorf("cached link changed by a caller: %+v", link)
+	}
+
+	// The least recently used link makes room
+	c.GetLink(ctx, "cal")
+	c.GetLink(ctx, "wiki")
+	c.GetLink(ctx, "mail")
+	backend.reads.Store(0)
+	c.GetLink(ctx, "wiki")
+	c.GetLink(ctx, "cal")
+	if n := backend.reads.Load(); n != 1 {
+		t.Errorf("%d database reads after eviction, want 1", n)
+	}
+	if stats := c.CacheStats(); stats.Size != 2 || stats.Capacity != 2 || stats.Hits == 0 || stats.Misses == 0 {
+		t.Errorf("CacheStats = %+v", stats)
+	}
+
+	// Changes through the cache are seen at once
+	c.UpdateLink(ctx, Link{Slug: "wiki", URL: "https://wiki2.example.com"})
+	if link, _ := c.GetLink(ctx, "wiki"); link.URL != "https://wiki2.example.com" {
+		t.Errorf("after update = %+v", link)
+	}
+	c.SetPublic(ctx, "wiki", true)
+	if link, _ := c.GetLink(ctx, "wiki"); !link.Public {
+		t.Errorf("after SetPublic = %+v", link)
+	}
+
+	// Clicks are counted in the cached link, which stays cached
+	backend.reads.Store(0)
+	at := time.Now().UTC().Truncate(time.Second)
+	c.RecordClicks(ctx, []Click{{Slug: "wiki", At: at}, {Slug: "wiki", At: at, FromList: true}})
+	link, _ = c.GetLink(ctx, "wiki")
+	if link.Clicks != 2 || link.ListOpens != 1 || link.LastUsedAt == nil || !link.LastUsedAt.Equal(at) {
+		t.Errorf("after clicks = %+v", link)
+	}
+	if n := backend.reads.Load(); n != 0 {
+		t.Errorf("%d database reads after clicks, want 0", n)
+	}
+
+	c.RenameLink(ctx, "wiki", "docs", false)
+	if _, err := c.GetLink(ctx, "wiki"); err == nil {
+		t.Error("renamed link still cached")
+	}
+	c.RemoveLink(ctx, "cal")
+	if _, err := c.GetLink(ctx, "cal"); err == nil {
+		t.Error("removed link still cached")
+	}
+}
+
+func TestLRUCacheMisses(t *testing.T) {
+	testStore(t, NewLRUCache(NewMemory(), LRUOptions{MissTTL: time.Minute}))
+
+	ctx := context.Background()
+	backend := &aliasCountingStore{countingStore: countingStore{Memory: NewMemory()}}
+	c := NewLRUCache(backend, LRUOptions{Size: 2, MissTTL: time.Minute})
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetLink(ctx, "wp-login.php"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetLink = %v", err)
+		}
+		if _, err := c.ResolveAlias(ctx, "wp-login.php"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("ResolveAlias = %v", err)
+		}
+	}
+	if n, m := backend.reads.Load(), backend.aliasReads.Load(); n != 1 || m != 1 {
+		t.Errorf("%d link and %d alias reads, want 1 each", n, m)
+	}
+
+	// Misses have their own room, so a storm of them keeps the links
+	c.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	c.GetLink(ctx, "wiki")
+	for _, slug := range []string{".env", "admin.php", "xmlrpc.php"} {
+		c.GetLink(ctx, slug)
+	}
+	backend.reads.Store(0)
+	c.GetLink(ctx, "wiki")
+	if n := backend.reads.Load(); n != 0 {
+		t.Errorf("%d database reads of a link after a storm of misses, want 0", n)
+	}
+	if stats := c.CacheStats(); stats.Size != 1 || stats.NotFound != 2 {
+		t.Errorf("CacheStats = %+v", stats)
+	}
+
+	// A link or alias added at a cached miss is found at once
+	c.GetLink(ctx, "docs")
+	c.ResolveAlias(ctx, "docs")
+	c.AddLink(ctx, Link{Slug: "docs", URL: "https://docs.example.com"})
+	if link, err := c.GetLink(ctx, "docs"); err != nil || link.URL != "https://docs.example.com" {
+		t.Errorf("GetLink after add = %+v, %v", link, err)
+	}
+	c.GetLink(ctx, "manual")
+	c.ResolveAlias(ctx, "manual")
+	c.RenameLink(ctx, "docs", "manual", false)
+	if _, err := c.GetLink(ctx, "manual"); err != nil {
+		t.Errorf("GetLink after rename = %v", err)
+	}
+	c.GetLink(ctx, "email")
+	c.ResolveAlias(ctx, "email")
+	c.CreateLink(ctx, Link{Slug: "inbox", URL: "https://mail.example.com"}, []string{"email"}, nil)
+	if target, err := c.ResolveAlias(ctx, "email"); err != nil || target != "inbox" {
+		t.Errorf("ResolveAlias after create = %q, %v", target, err)
+	}
+
+	// Without a MissTTL, misses read the store each time
+	backend.reads.Store(0)
+	c = NewLRUCache(backend, LRUOptions{})
+	c.GetLink(ctx, "nope")
+	c.GetLink(ctx, "nope")
+	if n := backend.reads.Load(); n != 2 {
+		t.Errorf("%d database reads without a MissTTL, want 2", n)
+	}
+}
+
+// aliasCountingStore also counts ResolveAlias calls that reach the
+// database.
+type aliasCountingStore struct {
+	countingStore
+	aliasReads atomic.Int32
+}
+
+func (c *aliasCountingStore) ResolveAlias(ctx context.Context, slug string) (string, error) {
+	c.aliasReads.Add(1)
+	return c.Memory.ResolveAlias(ctx, slug)
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{Memory: NewMemory()}
+	c := NewLRUCache(backend, LRUOptions{TTL: time.Millisecond})
+	c.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	c.GetLink(ctx, "wiki")
+	// A change made around the cache shows once the link expires
+	backend.UpdateLink(ctx, Link{Slug: "wiki", URL: "https://wiki2.example.com"})
+	time.Sleep(5 * time.Millisecond)
+	if link, _ := c.GetLink(ctx, "wiki"); link.URL != "https://wiki2.example.com" {
+		t.Errorf("after expiry = %+v", link)
+	}
+	if n := backend.reads.Load(); n != 2 {
+		t.Errorf("%d database reads, want 2", n)
+	}
+}
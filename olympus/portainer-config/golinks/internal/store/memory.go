@@ -0,0 +1,774 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a non-persistent Store, used in tests and for throwaway
+// instances.
+type Memory struct {
+	mu          sync.RWMutex
+	links       map[string]Link
+	collections map[string]Collection
+	clicks      []Click
+	// aliases maps the old slugs of renamed links to their current ones.
+	aliases map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{links: make(map[string]Link), collections: make(map[string]Collection), aliases: make(map[string]string)}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) GetLink(ctx context.Context, slug string) (*Link, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &link, nil
+}
+
+func (m *Memory) ListLinks(ctx context.Context) ([]Link, error) {
+	return m.listLinks(ctx, OrderNewest)
+}
+
+// linkLess reports whether a sorts before b in each order.
+var linkLess = map[LinkOrder]func(a, b Link) bool{
+	OrderNewest: func(a, b Link) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.Slug > b.Slug
+	},
+	OrderClicks: func(a, b Link) bool {
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Slug < b.Slug
+	},
+	OrderRecent: func(a, b Link) bool {
+		if ua, ub := lastUsed(a), lastUsed(b); ua != ub {
+			return ua > ub
+		}
+		return a.Slug < b.Slug
+	},
+	OrderAlpha: func(a, b Link) bool {
+		return a.Slug < b.Slug
+	},
+	OrderPinned: func(a, b Link) bool {
+		if a.Pin != b.Pin {
+			return a.Pin > b.Pin
+		}
+		return a.Slug < b.Slug
+	},
+}
+
+// lastUsed returns LastUsedAt in Unix seconds, 0 for never, as SQLite
+// stores it.
+func lastUsed(link Link) int64 {
+	if link.LastUsedAt == nil {
+		return 0
+	}
+	return link.LastUsedAt.Unix()
+}
+
+func (m *Memory) listLinks(ctx context.Context, order LinkOrder) ([]Link, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	less, ok := linkLess[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown link order %q", order)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := make([]Link, 0, len(m.links))
+	for _, link := range m.links {
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool { return less(links[i], links[j]) })
+	return links, nil
+}
+
+// EachLink iterates over a snapshot so fn may call back into the store.
+func (m *Memory) EachLink(ctx context.Context, fn func(Link) error) error {
+	return m.EachLinkBy(ctx, OrderNewest, fn)
+}
+
+func (m *Memory) EachLinkBy(ctx context.Context, order LinkOrder, fn func(Link) error) error {
+	links, err := m.listLinks(ctx, order)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) CountLinks(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.links), nil
+}
+
+func (m *Memory) AddLink(ctx context.Context, link Link) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.links[link.Slug]; exists {
+		return ErrConflict
+	}
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	link.CreatedAt = time.Now().UTC()
+	link.UpdatedAt = link.CreatedAt
+	m.links[link.Slug] = link
+	return nil
+}
+
+func (m *Memory) CreateLink(ctx context.Context, link Link, aliases, collections []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check everything before changing anything
+	if _, exists := m.links[link.Slug]; exists {
+		return ErrConflict
+	}
+	for i, alias := range aliases {
+		_, isLink := m.links[alias]
+		_, isAlias := m.aliases[alias]
+		if isLink || isAlias || alias == link.Slug || slices.Contains(aliases[:i], alias) {
+			return ErrConflict
+		}
+	}
+	for _, name := range collections {
+		if _, ok := m.collections[name]; !ok {
+			return ErrNotFound
+		}
+	}
+
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	link.ApprovedBy, link.Clicks, link.ListOpens, link.LastUsedAt = "", 0, 0, nil
+	link.CreatedAt = time.Now().UTC()
+	link.UpdatedAt = link.CreatedAt
+	m.links[link.Slug] = link
+	for _, alias := range aliases {
+		m.aliases[alias] = link.Slug
+	}
+	for _, name := range collections {
+		c := m.collections[name]
+		if !slices.Contains(c.Slugs, link.Slug) {
+			c.Slugs = append(slices.Clone(c.Slugs), link.Slug)
+			m.collections[name] = c
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RestoreLink(ctx context.Context, link Link) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.links[link.Slug]; exists {
+		return ErrConflict
+	}
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = link.CreatedAt
+	}
+	// Stored at the precision of SQLite
+	link.CreatedAt, link.UpdatedAt = link.CreatedAt.UTC(), link.UpdatedAt.UTC()
+	if link.LastUsedAt != nil {
+		at := link.LastUsedAt.Truncate(time.Second).UTC()
+		link.LastUsedAt = &at
+	}
+	m.links[link.Slug] = link
+	return nil
+}
+
+func (m *Memory) ApproveLink(ctx context.Context, slug, url, approvedBy string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok || link.Status != StatusPending || link.URL != url {
+		return ErrConflict
+	}
+	link.Status = StatusActive
+	link.ApprovedBy = approvedBy
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) UpdateLink(ctx context.Context, link Link) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.links[link.Slug]
+	if !ok {
+		return ErrNotFound
+	}
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	existing.URL = link.URL
+	existing.Status = link.Status
+	existing.CreatedBy = link.CreatedBy
+	existing.ApprovedBy = ""
+	existing.UpdatedAt = time.Now().UTC()
+	m.links[link.Slug] = existing
+	return nil
+}
+
+func (m *Memory) ReplaceLink(ctx context.Context, link Link) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.links[link.Slug]
+	if !ok {
+		return ErrNotFound
+	}
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = time.Now()
+	}
+	if link.ReviewAt != nil {
+		at := link.ReviewAt.UTC()
+		link.ReviewAt = &at
+	}
+	if len(link.Access) == 0 {
+		link.Access = nil
+	}
+	link.Access = slices.Clone(link.Access)
+	if len(link.Fields) == 0 {
+		link.Fields = nil
+	}
+	link.Fields = maps.Clone(link.Fields)
+	link.Clicks, link.ListOpens, link.LastUsedAt, link.CreatedAt = existing.Clicks, existing.ListOpens, existing.LastUsedAt, existing.CreatedAt
+	link.UpdatedAt = link.UpdatedAt.UTC()
+	m.links[link.Slug] = link
+	return nil
+}
+
+func (m *Memory) SetPublic(ctx context.Context, slug string, public bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	link.Public = public
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) SetReview(ctx context.Context, slug string, at *time.Time, months int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	if at != nil {
+		utc := at.UTC()
+		at = &utc
+	}
+	link.ReviewAt, link.ReviewMonths = at, months
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) SetAccess(ctx context.Context, slug string, rules []AccessRule) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	link.Access = slices.Clone(rules)
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) SetPin(ctx context.Context, slug string, pin int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	link.Pin = pin
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) SetHitBudget(ctx context.Context, slug string, hitsPerDay int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	link.HitBudget = hitsPerDay
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) SetFailover(ctx context.Context, slug, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	link.Failover = url
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) SetReferrer(ctx context.Context, slug, policy string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	link.Referrer = policy
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) SetFields(ctx context.Context, slug string, fields map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[slug]
+	if !ok {
+		return ErrNotFound
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+	link.Fields = maps.Clone(fields)
+	link.UpdatedAt = time.Now().UTC()
+	m.links[slug] = link
+	return nil
+}
+
+func (m *Memory) RecordClicks(ctx context.Context, clicks []Click) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range clicks {
+		link, ok := m.links[c.Slug]
+		if !ok {
+			continue
+		}
+		// Second precision, as in SQLite
+		at := c.At.Truncate(time.Second).UTC()
+		if !c.Unsampled {
+			m.clicks = append(m.clicks, Click{Slug: c.Slug, At: at, Weight: c.weight()})
+		}
+		link.Clicks++
+		if c.FromList {
+			link.ListOpens++
+		}
+		if link.LastUsedAt == nil || at.After(*link.LastUsedAt) {
+			link.LastUsedAt = &at
+		}
+		m.links[c.Slug] = link
+	}
+	return nil
+}
+
+func (m *Memory) ClickCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, c := range m.clicks {
+		if !c.At.Before(since.Truncate(time.Second)) {
+			counts[c.Slug] += c.weight()
+		}
+	}
+	return counts, nil
+}
+
+func (m *Memory) ClickTimes(ctx context.Context, slug string, since time.Time) ([]time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var times []time.Time
+	for _, c := range m.clicks {
+		if c.Slug == slug && !c.At.Before(since.Truncate(time.Second)) {
+			times = appendClickTimes(times, c.At, c.weight())
+		}
+	}
+	slices.SortFunc(times, time.Time.Compare)
+	return times, nil
+}
+
+func (m *Memory) ClickDays(ctx context.Context, slug string, since time.Time, loc *time.Location) (map[string]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	days := make(map[string]int)
+	for _, c := range m.clicks {
+		if (slug == "" || c.Slug == slug) && !c.At.Before(since.Truncate(time.Second)) {
+			days[c.At.In(loc).Format(time.DateOnly)] += c.weight()
+		}
+	}
+	return days, nil
+}
+
+func (m *Memory) PruneClicks(ctx context.Context, before time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.clicks)
+	m.clicks = slices.DeleteFunc(m.clicks, func(c Click) bool { return c.At.Before(before.Truncate(time.Second)) })
+	return int64(n - len(m.clicks)), nil
+}
+
+func (m *Memory) RemoveLink(ctx context.Context, slug string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.links[slug]; !ok {
+		return ErrNotFound
+	}
+	delete(m.links, slug)
+	m.clicks = slices.DeleteFunc(m.clicks, func(c Click) bool { return c.Slug == slug })
+	for name, c := range m.collections {
+		c.Slugs = slices.DeleteFunc(slices.Clone(c.Slugs), func(s string) bool { return s == slug })
+		m.collections[name] = c
+	}
+	for old, target := range m.aliases {
+		if target == slug {
+			delete(m.aliases, old)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RenameLink(ctx context.Context, from, to string, alias bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.links[to]; ok {
+		return ErrConflict
+	}
+	link, ok := m.links[from]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.links, from)
+	link.Slug = to
+	link.UpdatedAt = time.Now().UTC()
+	m.links[to] = link
+
+	for i, c := range m.clicks {
+		if c.Slug == from {
+			m.clicks[i].Slug = to
+		}
+	}
+	for name, c := range m.collections {
+		slugs := slices.Clone(c.Slugs)
+		if i := slices.Index(slugs, from); i >= 0 {
+			if slices.Contains(slugs, to) {
+				slugs = slices.Delete(slugs, i, i+1)
+			} else {
+				slugs[i] = to
+			}
+		}
+		c.Slugs = slugs
+		m.collections[name] = c
+	}
+	delete(m.aliases, to)
+	for old, target := range m.aliases {
+		if target == from {
+			m.aliases[old] = to
+		}
+	}
+	if alias {
+		m.aliases[from] = to
+	}
+	return nil
+}
+
+func (m *Memory) ResolveAlias(ctx context.Context, slug string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	target, ok := m.aliases[slug]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return target, nil
+}
+
+func (m *Memory) ListAliases(ctx context.Context) ([]Alias, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	aliases := make([]Alias, 0, len(m.aliases))
+	for slug, target := range m.aliases {
+		aliases = append(aliases, Alias{Slug: slug, Target: target})
+	}
+	slices.SortFunc(aliases, func(a, b Alias) int { return strings.Compare(a.Slug, b.Slug) })
+	return aliases, nil
+}
+
+func (m *Memory) SetAlias(ctx context.Context, a Alias) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.links[a.Slug]; ok {
+		return ErrConflict
+	}
+	if _, ok := m.links[a.Target]; !ok {
+		return ErrNotFound
+	}
+	m.aliases[a.Slug] = a.Target
+	return nil
+}
+
+func (m *Memory) RemoveAlias(ctx context.Context, slug string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.aliases[slug]; !ok {
+		return ErrNotFound
+	}
+	delete(m.aliases, slug)
+	return nil
+}
+
+func (m *Memory) SaveCollection(ctx context.Context, c Collection) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slugs := []string{}
+	for _, slug := range c.Slugs {
+		if !slices.Contains(slugs, slug) {
+			slugs = append(slugs, slug)
+		}
+	}
+	c.Slugs = slugs
+	if existing, ok := m.collections[c.Name]; ok {
+		c.CreatedBy, c.CreatedAt = existing.CreatedBy, existing.CreatedAt
+	} else {
+		c.CreatedAt = time.Now().UTC()
+	}
+	m.collections[c.Name] = c
+	return nil
+}
+
+func (m *Memory) GetCollection(ctx context.Context, name string) (*Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.collections[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c.Slugs = slices.Clone(c.Slugs)
+	return &c, nil
+}
+
+func (m *Memory) ListCollections(ctx context.Context) ([]Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collections := make([]Collection, 0, len(m.collections))
+	for _, c := range m.collections {
+		c.Slugs = slices.Clone(c.Slugs)
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, nil
+}
+
+func (m *Memory) RemoveCollection(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.collections[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.collections, name)
+	return nil
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// migration is one versioned schema change. Migrations run in order, each in
+// its own transaction, and are recorded in the schema_version table.
+type migration struct {
+	version int
+	name    string
+	apply   func(tx *sql.Tx) error
+}
+
+// migrations is the ordered schema history. Append new steps; never edit or
+// reorder released ones.
+var migrations = []migration{
+	{1, "create links table", execAll(`
+		CREATE TABLE IF NOT EXISTS links (
+			slug TEXT PRIMARY KEY,
+			url TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)},
+	// Databases of the original release have the links table but none of
+	// the columns added since, so migration 1 keeps IF NOT EXISTS and the
+	// rest add columns plainly.
+	{2, "add approval columns", execAll(
+		`ALTER TABLE links ADD COLUMN status TEXT NOT NULL DEFAULT 'active'`,
+		`ALTER TABLE links ADD COLUMN created_by TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE links ADD COLUMN approved_by TEXT NOT NULL DEFAULT ''`)},
+	{3, "index links by created_at", execAll(
+		`CREATE INDEX IF NOT EXISTS idx_links_created_at ON links (created_at)`)},
+	// Slugs are now stored without emoji variation selectors (U+FE0E and
+	// U+FE0F). A slug whose stripped form is already taken is left as is.
+	{4, "strip emoji variation selectors from slugs", execAll(`
+		UPDATE OR IGNORE links
+		SET slug = replace(replace(slug, char(65038), ''), char(65039), '')
+		WHERE slug <> replace(replace(slug, char(65038), ''), char(65039), '')`)},
+	{5, "add public flag", execAll(
+		`ALTER TABLE links ADD COLUMN public INTEGER NOT NULL DEFAULT 0`)},
+	{6, "add review reminder columns", execAll(
+		`ALTER TABLE links ADD COLUMN review_at TIMESTAMP`,
+		`ALTER TABLE links ADD COLUMN review_months INTEGER NOT NULL DEFAULT 0`)},
+	{7, "create collections", execAll(`
+		CREATE TABLE IF NOT EXISTS collections (
+			name TEXT PRIMARY KEY,
+			title TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`, `
+		CREATE TABLE IF NOT EXISTS collection_links (
+			collection TEXT NOT NULL,
+			slug TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (collection, slug)
+		)`,
+		// RemoveLink drops memberships by slug
+		`CREATE INDEX IF NOT EXISTS idx_collection_links_slug ON collection_links (slug)`)},
+	// Access rules are read with every redirect, so they live on the link
+	// row as JSON instead of in a table of their own
+	{8, "add access rules", execAll(
+		`ALTER TABLE links ADD COLUMN access_rules TEXT NOT NULL DEFAULT ''`)},
+	// last_used is Unix seconds, 0 for never, so every index order pages
+	// on a plain integer key. The indexes match EachLinkBy's orders.
+	{9, "add click counts and pins", execAll(
+		`ALTER TABLE links ADD COLUMN clicks INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE links ADD COLUMN last_used INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE links ADD COLUMN pin INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_links_clicks ON links (clicks DESC, slug)`,
+		`CREATE INDEX IF NOT EXISTS idx_links_last_used ON links (last_used DESC, slug)`,
+		`CREATE INDEX IF NOT EXISTS idx_links_pin ON links (pin DESC, slug)`)},
+	{10, "add hit budgets", execAll(
+		`ALTER TABLE links ADD COLUMN hit_budget INTEGER NOT NULL DEFAULT 0`)},
+	// at is Unix seconds, like links.last_used
+	{11, "create clicks", execAll(`
+		CREATE TABLE IF NOT EXISTS clicks (
+			id INTEGER PRIMARY KEY,
+			slug TEXT NOT NULL,
+			at INTEGER NOT NULL
+		)`,
+		// ClickCounts and PruneClicks select by time, RemoveLink by slug
+		`CREATE INDEX IF NOT EXISTS idx_clicks_at ON clicks (at)`,
+		`CREATE INDEX IF NOT EXISTS idx_clicks_slug ON clicks (slug)`)},
+	{12, "create aliases", execAll(`
+		CREATE TABLE IF NOT EXISTS aliases (
+			slug TEXT PRIMARY KEY,
+			target TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Renames and RemoveLink update aliases by target
+		`CREATE INDEX IF NOT EXISTS idx_aliases_target ON aliases (target)`)},
+	{13, "add failover destinations", execAll(
+		`ALTER TABLE links ADD COLUMN failover TEXT NOT NULL DEFAULT ''`)},
+	{14, "add referrer policies", execAll(
+		`ALTER TABLE links ADD COLUMN referrer TEXT NOT NULL DEFAULT ''`)},
+	{15, "add list opens", execAll(
+		`ALTER TABLE links ADD COLUMN list_opens INTEGER NOT NULL DEFAULT 0`)},
+	// Links so far last changed when they were created, as far as anyone
+	// can tell
+	{16, "add link update times", execAll(
+		`ALTER TABLE links ADD COLUMN updated_at TIMESTAMP`,
+		`UPDATE links SET updated_at = created_at`)},
+	// Custom fields are JSON on the link row, like access rules
+	{17, "add custom fields", execAll(
+		`ALTER TABLE links ADD COLUMN fields TEXT NOT NULL DEFAULT ''`)},
+	// A sampled click stands for several
+	{18, "add click weights", execAll(
+		`ALTER TABLE clicks ADD COLUMN weight INTEGER NOT NULL DEFAULT 1`)},
+}
+
+// migrate brings the database schema up to the latest version.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	// A newer binary may have changed the schema in ways this one doesn't
+	// understand; refuse to run rather than corrupt it.
+	if latest := migrations[len(migrations)-1].version; current > latest {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d); upgrade golinks", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		slog.Info("Applied migration", "version", m.version, "name", m.name)
+	}
+	return nil
+}
+
+// schemaVersion returns the latest applied migration, or 0 for a new
+// database.
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.apply(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execAll returns a migration step running the given statements in order.
+func execAll(stmts ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
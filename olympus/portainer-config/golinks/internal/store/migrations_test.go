@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationsOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.name, m.version, i+1)
+		}
+	}
+}
+
+func TestSQLiteMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+
+	// Schema and data as written by the original single-file release
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE links (
+		slug TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO links (slug, url) VALUES ('wiki', 'https://wiki.example.com')"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := OpenSQLite(path, SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+
+	link, err := s.GetLink(context.Background(), "wiki")
+	if err != nil {
+		t.Fatalf("GetLink: %v", err)
+	}
+	if link.Status != StatusActive {
+		t.Errorf("legacy link status = %q, want active", link.Status)
+	}
+
+	version, err := schemaVersion(s.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("schema version = %d, want %d", version, len(migrations))
+	}
+}
+
+func TestSQLiteMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+
+	s, err := OpenSQLite(path, SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	future := len(migrations) + 1
+	if _, err := s.db.Exec("INSERT INTO schema_version (version, name) VALUES (?, 'from the future')", future); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if _, err := OpenSQLite(path, SQLiteOptions{}); err == nil {
+		t.Error("OpenSQLite on a newer schema: expected error")
+	}
+}
+
+func TestSQLiteMigrateVariationSelectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stop before migration 4 and store slugs with variation selectors
+	if _, err := db.Exec("CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:3] {
+		if err := applyMigration(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stmts := []string{
+		"INSERT INTO links (slug, url) VALUES ('❤\uFE0F', 'https://love.example.com')",
+		"INSERT INTO links (slug, url) VALUES ('☕\uFE0F', 'https://coffee.example.com')",
+		"INSERT INTO links (slug, url) VALUES ('☕', 'https://tea.example.com')",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	s, err := OpenSQLite(path, SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if link, err := s.GetLink(ctx, "❤"); err != nil || link.URL != "https://love.example.com" {
+		t.Errorf("GetLink(❤) = %v, %v", link, err)
+	}
+	// The stripped form was taken, so the original keeps its selector
+	if link, err := s.GetLink(ctx, "☕"); err != nil || link.URL != "https://tea.example.com" {
+		t.Errorf("GetLink(☕) = %v, %v", link, err)
+	}
+	if _, err := s.GetLink(ctx, "☕\uFE0F"); err != nil {
+		t.Errorf("GetLink(☕+FE0F): %v", err)
+	}
+}
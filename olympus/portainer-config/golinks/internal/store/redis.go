@@ -0,0 +1,324 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds a command to Redis unless the URL sets timeouts of its
+// own; a redirect waits for it before it falls back to the database.
+const redisTimeout = 200 * time.Millisecond
+
+// redisWarnEvery is how often an unreachable cache is logged at most.
+const redisWarnEvery = time.Minute
+
+// RedisCache wraps a Store so that links are read from Redis, where every
+// replica shares them, and the database is only asked on a miss. Changes
+// made through the cache remove the link from it and bump its version, so
+// a lookup that read the database before the change doesn't cache what it
+// read. Clicks are added to the cached link instead, so a busy link stays
+// cached. All other methods pass straight through.
+type RedisCache struct {
+	Store
+
+	rdb    *redis.Client
+	ttl    time.Duration
+	prefix string
+	// warned is when an error of Redis was last logged, in Unix seconds.
+	warned atomic.Int64
+}
+
+// RedisOptions tunes a RedisCache.
+type RedisOptions struct {
+	// TTL is how long a link stays cached. It bounds how long a change
+	// made around the cache, such as directly in the database, goes
+	// unseen. One hour if zero.
+	TTL time.Duration
+	// Prefix starts every key, so instances can share a Redis database.
+	Prefix string
+}
+
+// OpenRedisCache connects to the Redis server at url, such as
+// redis://:password@cache:6379/0, and puts it in front of st. Closing the
+// cache closes st.
+func OpenRedisCache(st Store, url string, opts RedisOptions) (*RedisCache, error) {
+	ro, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	if ro.DialTimeout == 0 {
+		ro.DialTimeout = redisTimeout
+	}
+	if ro.ReadTimeout == 0 {
+		ro.ReadTimeout = redisTimeout
+	}
+	if ro.WriteTimeout == 0 {
+		ro.WriteTimeout = redisTimeout
+	}
+	if opts.TTL == 0 {
+		opts.TTL = time.Hour
+	}
+	c := &RedisCache{Store: st, rdb: redis.NewClient(ro), ttl: opts.TTL, prefix: opts.Prefix}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.rdb.Ping(ctx).Err(); err != nil {
+		c.rdb.Close()
+		return nil, fmt.Errorf("connect to Redis: %w", err)
+	}
+	return c, nil
+}
+
+func (c *RedisCache) Close() error {
+	return errors.Join(c.rdb.Close(), c.Store.Close())
+}
+
+// key is where the link at slug is cached.
+func (c *RedisCache) key(slug string) string {
+	return c.prefix + "link:" + slug
+}
+
+// clicksKey is where the clicks recorded since the link at slug was cached
+// are counted, as the fields n, list and last of a hash.
+func (c *RedisCache) clicksKey(slug string) string {
+	return c.prefix + "clicks:" + slug
+}
+
+// versionKey counts the changes to the link at slug, see fillScript.
+func (c *RedisCache) versionKey(slug string) string {
+	return c.prefix + "version:" + slug
+}
+
+// fillScript caches a link read from the database unless its version
+// changed since the read started, which might have missed the change, and
+// notes that version with its clicks. Its keys are those of the link, its
+// clicks and its version; its arguments the version before the read, the
+// link and the TTL in milliseconds.
+var fillScript = redis.NewScript(`
+if (redis.call('GET', KEYS[3]) or '') ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+redis.call('DEL', KEYS[2])
+redis.call('HSET', KEYS[2], 'v', tonumber(ARGV[1]) or 0)
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+return 1
+`)
+
+// clickScript counts clicks the database has just added on a cached link,
+// until it expires with it. Clicks are added in two steps around the
+// database, see RecordClicks: a link cached from a read between the two,
+// at the version of the first step or later, may or may not have them, so
+// it is dropped instead. The clicks of a link that isn't cached bump its
+// version, as a lookup filling the cache may have read the database before
+// they were added. Its keys are those of fillScript; its arguments the
+// number of clicks, those from the list, the Unix time of the last, the TTL
+// in milliseconds and the version of the first step.
+var clickScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('INCR', KEYS[3])
+	redis.call('PEXPIRE', KEYS[3], ARGV[4])
+	return 0
+end
+if tonumber(redis.call('HGET', KEYS[2], 'v') or '0') >= tonumber(ARGV[5]) then
+	redis.call('DEL', KEYS[1], KEYS[2])
+	return 0
+end
+redis.call('HINCRBY', KEYS[2], 'n', ARGV[1])
+redis.call('HINCRBY', KEYS[2], 'list', ARGV[2])
+if tonumber(ARGV[3]) > tonumber(redis.call('HGET', KEYS[2], 'last') or '0') then
+	redis.call('HSET', KEYS[2], 'last', ARGV[3])
+end
+redis.call('PEXPIRE', KEYS[2], redis.call('PTTL', KEYS[1]))
+return 1
+`)
+
+// warn logs err, unless an error was logged within redisWarnEvery. A cache
+// that is down would otherwise log every redirect.
+func (c *RedisCache) warn(err error) {
+	now := time.Now().Unix()
+	last := c.warned.Load()
+	if now-last < int64(redisWarnEvery/time.Second) || !c.warned.CompareAndSwap(last, now) {
+		return
+	}
+	slog.Warn("Redis cache failed", "error", err)
+}
+
+// GetLink returns the cached link with the clicks since it was cached, or
+// reads it from the store and caches it. Redis failing is logged and the
+// store asked instead. Slugs without a link are not cached.
+func (c *RedisCache) GetLink(ctx context.Context, slug string) (*Link, error) {
+	pipe := c.rdb.Pipeline()
+	cached := pipe.Get(ctx, c.key(slug))
+	clicks := pipe.HGetAll(ctx, c.clicksKey(slug))
+	version := pipe.Get(ctx, c.versionKey(slug))
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		c.warn(err)
+		return c.Store.GetLink(ctx, slug)
+	}
+	if data, err := cached.Bytes(); err == nil {
+		var link Link
+		if err := json.Unmarshal(data, &link); err == nil {
+			addClicks(&link, clicks.Val())
+			return &link, nil
+		}
+	}
+
+	link, err := c.Store.GetLink(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(link); err == nil {
+		keys := []string{c.key(slug), c.clicksKey(slug), c.versionKey(slug)}
+		if err := fillScript.Run(ctx, c.rdb, keys, version.Val(), data, c.ttl.Milliseconds()).Err(); err != nil {
+			c.warn(err)
+		}
+	}
+	return link, nil
+}
+
+// addClicks adds the clicks counted by clickScript to link.
+func addClicks(link *Link, clicks map[string]string) {
+	n, _ := strconv.Atoi(clicks["n"])
+	list, _ := strconv.Atoi(clicks["list"])
+	link.Clicks += n
+	link.ListOpens += list
+	if sec, _ := strconv.ParseInt(clicks["last"], 10, 64); sec > 0 {
+		if last := time.Unix(sec, 0).UTC(); link.LastUsedAt == nil || last.After(*link.LastUsedAt) {
+			link.LastUsedAt = &last
+		}
+	}
+}
+
+// forget removes the links at slugs from the cache and bumps their
+// versions once err shows their change was made. A link whose removal fails
+// stays cached for up to the TTL.
+func (c *RedisCache) forget(ctx context.Context, err error, slugs ...string) error {
+	if err != nil || len(slugs) == 0 {
+		return err
+	}
+	// The change is made, so the cache has to follow even if the caller
+	// has given up
+	ctx = context.WithoutCancel(ctx)
+	_, err = c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, slug := range slugs {
+			pipe.Del(ctx, c.key(slug), c.clicksKey(slug))
+			// A lookup taking longer than the TTL is long given up, so the
+			// versions need not outlive it
+			pipe.Incr(ctx, c.versionKey(slug))
+			pipe.PExpire(ctx, c.versionKey(slug), c.ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Error removing links from the Redis cache", "slugs", slugs, "error", err)
+	}
+	return nil
+}
+
+func (c *RedisCache) RemoveLink(ctx context.Context, slug string) error {
+	return c.forget(ctx, c.Store.RemoveLink(ctx, slug), slug)
+}
+
+func (c *RedisCache) RenameLink(ctx context.Context, from, to string, alias bool) error {
+	return c.forget(ctx, c.Store.RenameLink(ctx, from, to, alias), from, to)
+}
+
+func (c *RedisCache) SetAlias(ctx context.Context, a Alias) error {
+	return c.forget(ctx, c.Store.SetAlias(ctx, a), a.Slug)
+}
+
+func (c *RedisCache) RemoveAlias(ctx context.Context, slug string) error {
+	return c.forget(ctx, c.Store.RemoveAlias(ctx, slug), slug)
+}
+
+func (c *RedisCache) ApproveLink(ctx context.Context, slug, url, approvedBy string) error {
+	return c.forget(ctx, c.Store.ApproveLink(ctx, slug, url, approvedBy), slug)
+}
+
+func (c *RedisCache) ReplaceLink(ctx context.Context, link Link) error {
+	return c.forget(ctx, c.Store.ReplaceLink(ctx, link), link.Slug)
+}
+
+func (c *RedisCache) UpdateLink(ctx context.Context, link Link) error {
+	return c.forget(ctx, c.Store.UpdateLink(ctx, link), link.Slug)
+}
+
+func (c *RedisCache) SetPublic(ctx context.Context, slug string, public bool) error {
+	return c.forget(ctx, c.Store.SetPublic(ctx, slug, public), slug)
+}
+
+func (c *RedisCache) SetReview(ctx context.Context, slug string, at *time.Time, months int) error {
+	return c.forget(ctx, c.Store.SetReview(ctx, slug, at, months), slug)
+}
+
+func (c *RedisCache) SetAccess(ctx context.Context, slug string, rules []AccessRule) error {
+	return c.forget(ctx, c.Store.SetAccess(ctx, slug, rules), slug)
+}
+
+func (c *RedisCache) SetPin(ctx context.Context, slug string, pin int) error {
+	return c.forget(ctx, c.Store.SetPin(ctx, slug, pin), slug)
+}
+
+func (c *RedisCache) SetHitBudget(ctx context.Context, slug string, hitsPerDay int) error {
+	return c.forget(ctx, c.Store.SetHitBudget(ctx, slug, hitsPerDay), slug)
+}
+
+func (c *RedisCache) SetFailover(ctx context.Context, slug, url string) error {
+	return c.forget(ctx, c.Store.SetFailover(ctx, slug, url), slug)
+}
+
+func (c *RedisCache) SetReferrer(ctx context.Context, slug, policy string) error {
+	return c.forget(ctx, c.Store.SetReferrer(ctx, slug, policy), slug)
+}
+
+func (c *RedisCache) SetFields(ctx context.Context, slug string, fields map[string]string) error {
+	return c.forget(ctx, c.Store.SetFields(ctx, slug, fields), slug)
+}
+
+// RecordClicks adds the clicks to the cached links as the store does, so
+// the links people use most stay cached while they are used. Their
+// versions are bumped first, so a link cached while the store adds the
+// clicks is not counted twice, see clickScript. Counts that fail to reach
+// Redis lag for up to the TTL.
+func (c *RedisCache) RecordClicks(ctx context.Context, clicks []Click) error {
+	totals := clickTotals(clicks)
+	// The clicks are made once the store has them, so the cache has to
+	// follow even if the caller has given up
+	rctx := context.WithoutCancel(ctx)
+	slugs := make([]string, 0, len(totals))
+	versions := make(map[string]*redis.IntCmd, len(totals))
+	pipe := c.rdb.Pipeline()
+	for slug := range totals {
+		slugs = append(slugs, slug)
+		versions[slug] = pipe.Incr(rctx, c.versionKey(slug))
+		pipe.PExpire(rctx, c.versionKey(slug), c.ttl)
+	}
+	_, verr := pipe.Exec(rctx)
+
+	if err := c.Store.RecordClicks(ctx, clicks); err != nil {
+		return err
+	}
+	if verr != nil {
+		// Without versions the clicks can't be added safely
+		return c.forget(ctx, nil, slugs...)
+	}
+	pipe = c.rdb.Pipeline()
+	for slug, t := range totals {
+		keys := []string{c.key(slug), c.clicksKey(slug), c.versionKey(slug)}
+		clickScript.Eval(rctx, pipe, keys, t.n, t.fromList, t.last, c.ttl.Milliseconds(), versions[slug].Val())
+	}
+	if _, err := pipe.Exec(rctx); err != nil {
+		slog.Error("Error adding clicks to the Redis cache", "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore counts GetLink calls that reach the database, and runs
+// during, if set, between reading a link and returning it.
+type countingStore struct {
+	*Memory
+	reads  atomic.Int32
+	during func()
+}
+
+func (c *countingStore) GetLink(ctx context.Context, slug string) (*Link, error) {
+	c.reads.Add(1)
+	link, err := c.Memory.GetLink(ctx, slug)
+	if c.during != nil {
+		c.during()
+	}
+	return link, err
+}
+
+// openTestRedis puts the Redis server at $GOLINKS_TEST_REDIS in front of
+// st, under keys no other test uses, or skips the test without one.
+func openTestRedis(t *testing.T, st Store) *RedisCache {
+	t.Helper()
+	url := os.Getenv("GOLINKS_TEST_REDIS")
+	if url == "" {
+		t.Skip("GOLINKS_TEST_REDIS not set")
+	}
+	prefix := fmt.Sprintf("golinks-test:%d:", time.Now().UnixNano())
+	c, err := OpenRedisCache(st, url, RedisOptions{TTL: time.Minute, Prefix: prefix})
+	if err != nil {
+		t.Fatalf("OpenRedisCache: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestRedisCache(t *testing.T) {
+	testStore(t, openTestRedis(t, NewMemory()))
+
+	ctx := context.Background()
+	backend := &countingStore{Memory: NewMemory()}
+	c := openTestRedis(t, backend)
+	c.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	for i := 0; i < 3; i++ {
+		if link, err := c.GetLink(ctx, "wiki"); err != nil || link.URL != "https://wiki.example.com" {
+			t.Fatalf("GetLink = %+v, %v", link, err)
+		}
+	}
+	if n := backend.reads.Load(); n != 1 {
+		t.Errorf("%d database reads, want 1", n)
+	}
+
+	// Changes through the cache are seen at once
+	c.UpdateLink(ctx, Link{Slug: "wiki", URL: "https://wiki2.example.com"})
+	if link, _ := c.GetLink(ctx, "wiki"); link.URL != "https://wiki2.example.com" {
+		t.Errorf("after update = %+v", link)
+	}
+
+	// Clicks are added to the cached link, which stays cached
+	reads := backend.reads.Load()
+	at := time.Now().Truncate(time.Second)
+	c.RecordClicks(ctx, []Click{{Slug: "wiki", At: at}, {Slug: "wiki", At: at, FromList: true}})
+	c.RecordClicks(ctx, []Click{{Slug: "wiki", At: at}})
+	if link, _ := c.GetLink(ctx, "wiki"); link.Clicks != 3 || link.ListOpens != 1 || link.LastUsedAt == nil || !link.LastUsedAt.Equal(at) {
+		t.Errorf("after clicks = %+v", link)
+	}
+	if n := backend.reads.Load(); n != reads {
+		t.Errorf("clicks sent the link back to the database: %d reads, want %d", n, reads)
+	}
+
+	// A change while a lookup reads the database keeps what it read out
+	// of the cache
+	c.RemoveLink(ctx, "wiki")
+	c.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	backend.during = func() {
+		backend.during = nil
+		c.UpdateLink(ctx, Link{Slug: "wiki", URL: "https://wiki3.example.com"})
+	}
+	c.GetLink(ctx, "wiki")
+	if link, _ := c.GetLink(ctx, "wiki"); link.URL != "https://wiki3.example.com" {
+		t.Errorf("after an update during a lookup = %+v", link)
+	}
+	// So do clicks
+	backend.during = func() {
+		backend.during = nil
+		c.RecordClicks(ctx, []Click{{Slug: "wiki", At: at}})
+	}
+	c.RemoveLink(ctx, "wiki")
+	c.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	c.GetLink(ctx, "wiki")
+	if link, _ := c.GetLink(ctx, "wiki"); link.Clicks != 1 {
+		t.Errorf("after a click during a lookup = %+v", link)
+	}
+
+	c.RenameLink(ctx, "wiki", "docs", false)
+	if _, err := c.GetLink(ctx, "wiki"); err == nil {
+		t.Error("renamed link still cached")
+	}
+}
+
+func TestOpenRedisCacheUnreachable(t *testing.T) {
+	if _, err := OpenRedisCache(NewMemory(), "redis://127.0.0.1:1/0", RedisOptions{}); err == nil {
+		t.Error("OpenRedisCache of a closed port succeeded")
+	}
+	if _, err := OpenRedisCache(NewMemory(), "cache:6379", RedisOptions{}); err == nil {
+		t.Error("OpenRedisCache of a malformed URL succeeded")
+	}
+}
@@ -0,0 +1,922 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Server is a Store backed by a database server, PostgreSQL or
+// MySQL/MariaDB. Unlike SQLite it can be shared by several golinks replicas
+// behind a load balancer.
+type Server struct {
+	db   *sql.DB
+	d    *dialect
+	opts ServerOptions
+}
+
+type ServerOptions struct {
+	// QueryTimeout bounds every query, as for SQLite. Zero means no
+	// timeout beyond the caller's.
+	QueryTimeout time.Duration
+	// MaxOpenConns caps the connections to the server; zero means the
+	// database/sql default of no limit.
+	MaxOpenConns int
+	// MaxIdleConns is how many connections are kept open between
+	// requests; zero means the database/sql default of 2.
+	MaxIdleConns int
+}
+
+// IsServerURL reports whether OpenServer can open rawURL: a postgres://,
+// postgresql:// or mysql:// URL.
+func IsServerURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && dialects[u.Scheme] != nil
+}
+
+// OpenServer connects to the database at rawURL, which IsServerURL accepts,
+// and brings its schema up to date.
+func OpenServer(rawURL string, opts ServerOptions) (*Server, error) {
+	db, d, err := openServerDB(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	s := &Server{db: db, d: d, opts: opts}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	slog.Info("Database initialized successfully", "engine", d.name)
+	return s, nil
+}
+
+// openServerDB opens the database at rawURL with the driver of its dialect.
+func openServerDB(rawURL string) (*sql.DB, *dialect, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	d := dialects[u.Scheme]
+	if d == nil {
+		return nil, nil, fmt.Errorf("unsupported database %q", u.Scheme)
+	}
+	source, err := d.dataSource(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	db, err := sql.Open(d.driver, source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, d, nil
+}
+
+// migrate brings the schema up to the latest version of the dialect, each
+// step in its own transaction, recorded in schema_version like in SQLite.
+func (s *Server) migrate(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := s.d.lock(ctx, conn); err != nil {
+		return fmt.Errorf("failed to lock schema: %w", err)
+	}
+	defer s.d.unlock(conn)
+
+	if _, err := conn.ExecContext(ctx, s.d.versionTable); err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	var current int
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if latest := len(s.d.migrations) - 1; current > latest {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d); upgrade golinks", current, latest)
+	}
+
+	for version := current + 1; version < len(s.d.migrations); version++ {
+		m := s.d.migrations[version]
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		// Statements run one by one, since MySQL takes one per call
+		for _, stmt := range strings.Split(m.ddl, ";") {
+			if err == nil && strings.TrimSpace(stmt) != "" {
+				_, err = tx.ExecContext(ctx, stmt)
+			}
+		}
+		if err == nil {
+			_, err = s.exec(ctx, tx, "INSERT INTO schema_version (version, name) VALUES ($1, $2)", version, m.name)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s) failed: %w", version, m.name, err)
+		}
+		slog.Info("Applied migration", "version", version, "name", m.name)
+	}
+	return nil
+}
+
+func (s *Server) Close() error {
+	return s.db.Close()
+}
+
+// withTimeout applies the configured per-query timeout to ctx.
+func (s *Server) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.opts.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.opts.QueryTimeout)
+}
+
+// querier is what the queries of a Server need of *sql.DB or *sql.Tx.
+type querier interface {
+	execer
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// exec, query and queryRow run a query written with $n parameters on db,
+// bound for the dialect.
+func (s *Server) exec(ctx context.Context, db execer, query string, args ...any) (sql.Result, error) {
+	query, args = s.d.bind(query, args)
+	return db.ExecContext(ctx, query, args...)
+}
+
+func (s *Server) query(ctx context.Context, db querier, query string, args ...any) (*sql.Rows, error) {
+	query, args = s.d.bind(query, args)
+	return db.QueryContext(ctx, query, args...)
+}
+
+func (s *Server) queryRow(ctx context.Context, db querier, query string, args ...any) *sql.Row {
+	query, args = s.d.bind(query, args)
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+func (s *Server) GetLink(ctx context.Context, slug string) (*Link, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	link, err := scanLink(s.queryRow(ctx, s.db, "SELECT "+linkColumns+" FROM links WHERE slug = $1", slug))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (s *Server) ListLinks(ctx context.Context) ([]Link, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var links []Link
+	err := s.EachLink(ctx, func(link Link) error {
+		links = append(links, link)
+		return nil
+	})
+	return links, err
+}
+
+// EachLink reads links in keyset-paged batches like SQLite does, so a slow
+// consumer never keeps a query open.
+func (s *Server) EachLink(ctx context.Context, fn func(Link) error) error {
+	return s.EachLinkBy(ctx, OrderNewest, fn)
+}
+
+// serverLinkOrders are linkOrders with $n parameters.
+var serverLinkOrders = map[LinkOrder]linkOrder{
+	OrderNewest: {"created_at DESC, slug DESC", "created_at < $1 OR (created_at = $1 AND slug < $2)", "created_at"},
+	OrderClicks: {"clicks DESC, slug", "clicks < $1 OR (clicks = $1 AND slug > $2)", "clicks"},
+	OrderRecent: {"last_used DESC, slug", "last_used < $1 OR (last_used = $1 AND slug > $2)", "last_used"},
+	OrderAlpha:  {"slug", "slug > $2", "slug"},
+	OrderPinned: {"pin DESC, slug", "pin < $1 OR (pin = $1 AND slug > $2)", "pin"},
+}
+
+func (s *Server) EachLinkBy(ctx context.Context, order LinkOrder, fn func(Link) error) error {
+	o, ok := serverLinkOrders[order]
+	if !ok {
+		return fmt.Errorf("unknown link order %q", order)
+	}
+	var (
+		cursor     any
+		cursorSlug string
+		first      = true
+	)
+	for {
+		batch, last, err := s.linkPage(ctx, o, first, cursor, cursorSlug)
+		if err != nil {
+			return err
+		}
+		for _, link := range batch {
+			if err := fn(link); err != nil {
+				return err
+			}
+		}
+		if len(batch) < eachLinkBatch {
+			return nil
+		}
+		first = false
+		cursor, cursorSlug = last, batch[len(batch)-1].Slug
+	}
+}
+
+func (s *Server) linkPage(ctx context.Context, o linkOrder, first bool, cursor any, cursorSlug string) ([]Link, any, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	columns := "SELECT " + linkColumns + ", " + o.cursor + " FROM links"
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if first {
+		rows, err = s.query(ctx, s.db, columns+" ORDER BY "+o.orderBy+" LIMIT $1", eachLinkBatch)
+	} else {
+		rows, err = s.query(ctx, s.db, columns+" WHERE "+o.after+" ORDER BY "+o.orderBy+" LIMIT $3", cursor, cursorSlug, eachLinkBatch)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	links := make([]Link, 0, eachLinkBatch)
+	var last any
+	for rows.Next() {
+		link, err := scanLink(rows, &last)
+		if err != nil {
+			return nil, nil, err
+		}
+		links = append(links, link)
+	}
+	return links, last, rows.Err()
+}
+
+func (s *Server) CountLinks(ctx context.Context) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM links").Scan(&n)
+	return n, err
+}
+
+func (s *Server) AddLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	_, err := s.exec(ctx, s.db, "INSERT INTO links (slug, url, status, created_by, public) VALUES ($1, $2, $3, $4, $5)",
+		link.Slug, link.URL, link.Status, link.CreatedBy, link.Public)
+	return s.d.conflict(err)
+}
+
+func (s *Server) CreateLink(ctx context.Context, link Link, aliases, collections []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	link.ApprovedBy, link.Clicks, link.ListOpens, link.LastUsedAt = "", 0, 0, nil
+	link.CreatedAt, link.UpdatedAt = time.Now(), time.Time{}
+	if err := s.insertLink(ctx, tx, link); err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		var taken bool
+		if err := s.queryRow(ctx, tx, "SELECT EXISTS (SELECT 1 FROM links WHERE slug = $1)", alias).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrConflict
+		}
+		// A concurrent alias of the same name fails the insert
+		if _, err := s.exec(ctx, tx, "INSERT INTO aliases (slug, target) VALUES ($1, $2)", alias, link.Slug); err != nil {
+			return s.d.conflict(err)
+		}
+	}
+	for _, name := range collections {
+		// Locks the collection, so concurrent adds get distinct positions
+		var exists bool
+		err := s.queryRow(ctx, tx, "SELECT true FROM collections WHERE name = $1 FOR UPDATE", name).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// A membership left from an earlier link of the slug stays put
+		var member bool
+		if err := s.queryRow(ctx, tx, "SELECT EXISTS (SELECT 1 FROM collection_links WHERE collection = $1 AND slug = $2)", name, link.Slug).Scan(&member); err != nil {
+			return err
+		}
+		if member {
+			continue
+		}
+		_, err = s.exec(ctx, tx, `INSERT INTO collection_links (collection, slug, position)
+			SELECT $1, $2, COALESCE(MAX(position), -1) + 1 FROM collection_links WHERE collection = $1`, name, link.Slug)
+		if err != nil {
+			return err
+		}
+	}
+	return s.d.conflict(tx.Commit())
+}
+
+func (s *Server) RestoreLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.insertLink(ctx, s.db, link)
+}
+
+// insertLink is the insertLink of SQLite for a Server.
+func (s *Server) insertLink(ctx context.Context, db execer, link Link) error {
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = link.CreatedAt
+	}
+	access, err := encodeAccess(link.Access)
+	if err != nil {
+		return err
+	}
+	fields, err := encodeFields(link.Fields)
+	if err != nil {
+		return err
+	}
+	var reviewAt any
+	if link.ReviewAt != nil {
+		reviewAt = link.ReviewAt.UTC()
+	}
+	var lastUsed int64
+	if link.LastUsedAt != nil {
+		lastUsed = link.LastUsedAt.Unix()
+	}
+	_, err = s.exec(ctx, db, "INSERT INTO links ("+linkColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)",
+		link.Slug, link.URL, link.Status, link.CreatedBy, link.ApprovedBy, link.Public, reviewAt, link.ReviewMonths,
+		access, link.Clicks, lastUsed, link.Pin, link.HitBudget, link.Failover, link.Referrer, link.CreatedAt.UTC(), link.ListOpens, link.UpdatedAt.UTC(), fields)
+	return s.d.conflict(err)
+}
+
+func (s *Server) ApproveLink(ctx context.Context, slug, url, approvedBy string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, s.db, "UPDATE links SET status = $1, approved_by = $2, updated_at = $6 WHERE slug = $3 AND status = $4 AND url = $5",
+		StatusActive, approvedBy, slug, StatusPending, url, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrConflict)
+}
+
+func (s *Server) UpdateLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	res, err := s.exec(ctx, s.db, "UPDATE links SET url = $1, status = $2, created_by = $3, approved_by = '', updated_at = $5 WHERE slug = $4",
+		link.URL, link.Status, link.CreatedBy, link.Slug, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *Server) ReplaceLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = time.Now()
+	}
+	access, err := encodeAccess(link.Access)
+	if err != nil {
+		return err
+	}
+	fields, err := encodeFields(link.Fields)
+	if err != nil {
+		return err
+	}
+	var reviewAt any
+	if link.ReviewAt != nil {
+		reviewAt = link.ReviewAt.UTC()
+	}
+	res, err := s.exec(ctx, s.db, `UPDATE links SET url = $1, status = $2, created_by = $3, approved_by = $4, public = $5, review_at = $6, review_months = $7,
+		access_rules = $8, pin = $9, hit_budget = $10, failover = $11, referrer = $12, fields = $13, updated_at = $14 WHERE slug = $15`,
+		link.URL, link.Status, link.CreatedBy, link.ApprovedBy, link.Public, reviewAt, link.ReviewMonths,
+		access, link.Pin, link.HitBudget, link.Failover, link.Referrer, fields, link.UpdatedAt.UTC(), link.Slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+// setColumn sets one column of the link at slug, or returns ErrNotFound.
+// column is never user input.
+func (s *Server) setColumn(ctx context.Context, slug, column string, value any) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, s.db, "UPDATE links SET "+column+" = $1, updated_at = $2 WHERE slug = $3", value, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *Server) SetPublic(ctx context.Context, slug string, public bool) error {
+	return s.setColumn(ctx, slug, "public", public)
+}
+
+func (s *Server) SetReview(ctx context.Context, slug string, at *time.Time, months int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var reviewAt any
+	if at != nil {
+		reviewAt = at.UTC()
+	}
+	res, err := s.exec(ctx, s.db, "UPDATE links SET review_at = $1, review_months = $2, updated_at = $4 WHERE slug = $3", reviewAt, months, slug, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *Server) SetAccess(ctx context.Context, slug string, rules []AccessRule) error {
+	access, err := encodeAccess(rules)
+	if err != nil {
+		return err
+	}
+	return s.setColumn(ctx, slug, "access_rules", access)
+}
+
+func (s *Server) SetPin(ctx context.Context, slug string, pin int) error {
+	return s.setColumn(ctx, slug, "pin", pin)
+}
+
+func (s *Server) SetHitBudget(ctx context.Context, slug string, hitsPerDay int) error {
+	return s.setColumn(ctx, slug, "hit_budget", hitsPerDay)
+}
+
+func (s *Server) SetFailover(ctx context.Context, slug, url string) error {
+	return s.setColumn(ctx, slug, "failover", url)
+}
+
+func (s *Server) SetReferrer(ctx context.Context, slug, policy string) error {
+	return s.setColumn(ctx, slug, "referrer", policy)
+}
+
+func (s *Server) SetFields(ctx context.Context, slug string, fields map[string]string) error {
+	encoded, err := encodeFields(fields)
+	if err != nil {
+		return err
+	}
+	return s.setColumn(ctx, slug, "fields", encoded)
+}
+
+func (s *Server) RemoveLink(ctx context.Context, slug string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := s.exec(ctx, tx, "DELETE FROM links WHERE slug = $1", slug)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res, ErrNotFound); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"DELETE FROM collection_links WHERE slug = $1",
+		"DELETE FROM clicks WHERE slug = $1",
+		"DELETE FROM aliases WHERE target = $1",
+	} {
+		if _, err := s.exec(ctx, tx, stmt, slug); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Server) RenameLink(ctx context.Context, from, to string, alias bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A link created at to meanwhile fails the update with a unique
+	// violation
+	res, err := s.exec(ctx, tx, "UPDATE links SET slug = $1, updated_at = $3 WHERE slug = $2", to, from, time.Now().UTC())
+	if err != nil {
+		return s.d.conflict(err)
+	}
+	if err := expectRow(res, ErrNotFound); err != nil {
+		return err
+	}
+
+	// A stale membership of to wins over the renamed one. MySQL cannot
+	// delete from a table its subquery reads, so the collections are
+	// looked up first.
+	rows, err := s.query(ctx, tx, "SELECT collection FROM collection_links WHERE slug = $1", to)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		stale = append(stale, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if _, err := s.exec(ctx, tx, "DELETE FROM collection_links WHERE collection = $1 AND slug = $2", name, from); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range []string{
+		"UPDATE collection_links SET slug = $1 WHERE slug = $2",
+		"UPDATE clicks SET slug = $1 WHERE slug = $2",
+		"UPDATE aliases SET target = $1 WHERE target = $2",
+		"DELETE FROM aliases WHERE slug = $1 OR slug = $2",
+	} {
+		if _, err := s.exec(ctx, tx, stmt, to, from); err != nil {
+			return err
+		}
+	}
+	if alias {
+		if _, err := s.exec(ctx, tx, "INSERT INTO aliases (slug, target) VALUES ($1, $2)", from, to); err != nil {
+			return s.d.conflict(err)
+		}
+	}
+	return s.d.conflict(tx.Commit())
+}
+
+func (s *Server) ResolveAlias(ctx context.Context, slug string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var target string
+	err := s.queryRow(ctx, s.db, "SELECT target FROM aliases WHERE slug = $1", slug).Scan(&target)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return target, err
+}
+
+func (s *Server) ListAliases(ctx context.Context) ([]Alias, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, s.db, "SELECT slug, target FROM aliases ORDER BY slug")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []Alias{}
+	for rows.Next() {
+		var a Alias
+		if err := rows.Scan(&a.Slug, &a.Target); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+func (s *Server) SetAlias(ctx context.Context, a Alias) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken, target int
+	if err := s.queryRow(ctx, tx, "SELECT COUNT(*) FROM links WHERE slug = $1", a.Slug).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrConflict
+	}
+	if err := s.queryRow(ctx, tx, "SELECT COUNT(*) FROM links WHERE slug = $1", a.Target).Scan(&target); err != nil {
+		return err
+	}
+	if target == 0 {
+		return ErrNotFound
+	}
+	if _, err := s.exec(ctx, tx, "DELETE FROM aliases WHERE slug = $1", a.Slug); err != nil {
+		return err
+	}
+	if _, err := s.exec(ctx, tx, "INSERT INTO aliases (slug, target) VALUES ($1, $2)", a.Slug, a.Target); err != nil {
+		return s.d.conflict(err)
+	}
+	return s.d.conflict(tx.Commit())
+}
+
+func (s *Server) RemoveAlias(ctx context.Context, slug string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, s.db, "DELETE FROM aliases WHERE slug = $1", slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *Server) RecordClicks(ctx context.Context, clicks []Click) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Clicks of links removed meanwhile are dropped
+	exists := make(map[string]bool)
+	for slug, t := range clickTotals(clicks) {
+		res, err := s.exec(ctx, tx, "UPDATE links SET clicks = clicks + $1, list_opens = list_opens + $2, last_used = GREATEST(last_used, $3) WHERE slug = $4", t.n, t.fromList, t.last, slug)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		exists[slug] = n > 0
+	}
+	for _, c := range clicks {
+		if !exists[c.Slug] || c.Unsampled {
+			continue
+		}
+		if _, err := s.exec(ctx, tx, "INSERT INTO clicks (slug, at, weight) VALUES ($1, $2, $3)", c.Slug, c.At.Unix(), c.weight()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Server) ClickCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, s.db, "SELECT slug, SUM(weight) FROM clicks WHERE at >= $1 GROUP BY slug", since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var slug string
+		var n int
+		if err := rows.Scan(&slug, &n); err != nil {
+			return nil, err
+		}
+		counts[slug] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *Server) ClickTimes(ctx context.Context, slug string, since time.Time) ([]time.Time, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, s.db, "SELECT at, weight FROM clicks WHERE slug = $1 AND at >= $2 ORDER BY at", slug, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var at int64
+		var weight int
+		if err := rows.Scan(&at, &weight); err != nil {
+			return nil, err
+		}
+		times = appendClickTimes(times, time.Unix(at, 0).UTC(), weight)
+	}
+	return times, rows.Err()
+}
+
+func (s *Server) ClickDays(ctx context.Context, slug string, since time.Time, loc *time.Location) (map[string]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// The start of each bucket, since / divides exactly in MySQL
+	query := "SELECT at - at % $1, SUM(weight) FROM clicks WHERE at >= $2 GROUP BY 1"
+	if slug != "" {
+		query = "SELECT at - at % $1, SUM(weight) FROM clicks WHERE slug = $3 AND at >= $2 GROUP BY 1"
+	}
+	rows, err := s.query(ctx, s.db, query, clickBucket, since.Unix(), slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[string]int)
+	for rows.Next() {
+		var bucket int64
+		var n int
+		if err := rows.Scan(&bucket, &n); err != nil {
+			return nil, err
+		}
+		days[time.Unix(bucket, 0).In(loc).Format(time.DateOnly)] += n
+	}
+	return days, rows.Err()
+}
+
+func (s *Server) PruneClicks(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, s.db, "DELETE FROM clicks WHERE at < $1", before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Server) SaveCollection(ctx context.Context, c Collection) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := s.exec(ctx, tx, s.d.upsertCollection, c.Name, c.Title, c.Description, c.CreatedBy); err != nil {
+		return err
+	}
+	if _, err := s.exec(ctx, tx, "DELETE FROM collection_links WHERE collection = $1", c.Name); err != nil {
+		return err
+	}
+	// A slug listed twice keeps its first position
+	seen := make(map[string]bool, len(c.Slugs))
+	for i, slug := range c.Slugs {
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		if _, err := s.exec(ctx, tx, "INSERT INTO collection_links (collection, slug, position) VALUES ($1, $2, $3)", c.Name, slug, i); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Server) GetCollection(ctx context.Context, name string) (*Collection, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	c := Collection{Slugs: []string{}}
+	err := s.queryRow(ctx, s.db, "SELECT name, title, description, created_by, created_at FROM collections WHERE name = $1", name).
+		Scan(&c.Name, &c.Title, &c.Description, &c.CreatedBy, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, s.db, "SELECT slug FROM collection_links WHERE collection = $1 ORDER BY position", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		c.Slugs = append(c.Slugs, slug)
+	}
+	return &c, rows.Err()
+}
+
+func (s *Server) ListCollections(ctx context.Context) ([]Collection, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT name, title, description, created_by, created_at FROM collections ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collections := []Collection{}
+	index := map[string]int{}
+	for rows.Next() {
+		c := Collection{Slugs: []string{}}
+		if err := rows.Scan(&c.Name, &c.Title, &c.Description, &c.CreatedBy, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[c.Name] = len(collections)
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, "SELECT collection, slug FROM collection_links ORDER BY collection, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, slug string
+		if err := rows.Scan(&name, &slug); err != nil {
+			return nil, err
+		}
+		if i, ok := index[name]; ok {
+			collections[i].Slugs = append(collections[i].Slugs, slug)
+		}
+	}
+	return collections, rows.Err()
+}
+
+func (s *Server) RemoveCollection(ctx context.Context, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := s.exec(ctx, tx, "DELETE FROM collections WHERE name = $1", name)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res, ErrNotFound); err != nil {
+		return err
+	}
+	if _, err := s.exec(ctx, tx, "DELETE FROM collection_links WHERE collection = $1", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,1136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// SQLite is a Store backed by a local SQLite database file.
+type SQLite struct {
+	db    *sql.DB
+	opts  SQLiteOptions
+	stmts sqliteStmts
+}
+
+// sqliteStmts are the queries of every redirect, prepared once rather than
+// parsed again each time.
+type sqliteStmts struct {
+	getLink      *sql.Stmt
+	resolveAlias *sql.Stmt
+	insertClick  *sql.Stmt
+	addClicks    *sql.Stmt
+}
+
+// prepare prepares the statements of s.stmts.
+func (s *SQLite) prepare() error {
+	for _, q := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.stmts.getLink, "SELECT " + linkColumns + " FROM links WHERE slug = ?"},
+		{&s.stmts.resolveAlias, "SELECT target FROM aliases WHERE slug = ?"},
+		{&s.stmts.insertClick, "INSERT INTO clicks (slug, at, weight) SELECT ?1, ?2, ?3 WHERE EXISTS (SELECT 1 FROM links WHERE slug = ?1)"},
+		{&s.stmts.addClicks, "UPDATE links SET clicks = clicks + ?, list_opens = list_opens + ?, last_used = MAX(last_used, ?) WHERE slug = ?"},
+	} {
+		stmt, err := s.db.Prepare(q.query)
+		if err != nil {
+			s.closeStmts()
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		*q.stmt = stmt
+	}
+	return nil
+}
+
+func (s *SQLite) closeStmts() {
+	for _, stmt := range []*sql.Stmt{s.stmts.getLink, s.stmts.resolveAlias, s.stmts.insertClick, s.stmts.addClicks} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+type SQLiteOptions struct {
+	// QueryTimeout bounds every query so a stuck volume fails requests
+	// instead of hanging them. Zero means no timeout beyond the caller's.
+	QueryTimeout time.Duration
+	// JournalMode is the journal_mode pragma, WAL if empty: redirects then
+	// read while an admin writes. Network filesystems need DELETE.
+	JournalMode string
+	// Synchronous is the synchronous pragma, NORMAL if empty, which is
+	// safe with WAL; a power cut may only lose the latest commits.
+	Synchronous string
+	// BusyTimeout is how long a write waits for another to finish before
+	// failing with "database is locked", 5s if zero.
+	BusyTimeout time.Duration
+	// MaxOpenConns caps the connections to the file, the number of CPUs
+	// but at least 4 if zero. With WAL they read in parallel; writes take
+	// turns regardless.
+	MaxOpenConns int
+	// MaxIdleConns is how many connections are kept open between
+	// requests, MaxOpenConns if zero. A new connection runs the pragmas
+	// and prepares its statements again.
+	MaxIdleConns int
+}
+
+// The values SQLiteOptions.JournalMode and Synchronous may take.
+var (
+	SQLiteJournalModes = []string{"WAL", "DELETE", "TRUNCATE", "PERSIST"}
+	SQLiteSynchronous  = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// Validate checks the pragmas of o.
+func (o SQLiteOptions) Validate() error {
+	if o.JournalMode != "" && !slices.Contains(SQLiteJournalModes, strings.ToUpper(o.JournalMode)) {
+		return fmt.Errorf("journal mode must be one of %s", strings.Join(SQLiteJournalModes, ", "))
+	}
+	if o.Synchronous != "" && !slices.Contains(SQLiteSynchronous, strings.ToUpper(o.Synchronous)) {
+		return fmt.Errorf("synchronous must be one of %s", strings.Join(SQLiteSynchronous, ", "))
+	}
+	if o.BusyTimeout < 0 {
+		return fmt.Errorf("busy timeout must not be negative")
+	}
+	if o.MaxOpenConns < 0 || o.MaxIdleConns < 0 {
+		return fmt.Errorf("connection limits must not be negative")
+	}
+	return nil
+}
+
+// pool returns the connection limits of o, defaults filled in.
+func (o SQLiteOptions) pool() (open, idle int) {
+	open = o.MaxOpenConns
+	if open == 0 {
+		open = max(4, runtime.NumCPU())
+	}
+	idle = o.MaxIdleConns
+	if idle == 0 {
+		idle = open
+	}
+	return open, idle
+}
+
+// dsn returns the data source name opening dbPath with the pragmas of o,
+// which the driver runs on every new connection.
+func (o SQLiteOptions) dsn(dbPath string) string {
+	journal, sync, busy := "WAL", "NORMAL", 5*time.Second
+	if o.JournalMode != "" {
+		journal = strings.ToUpper(o.JournalMode)
+	}
+	if o.Synchronous != "" {
+		sync = strings.ToUpper(o.Synchronous)
+	}
+	if o.BusyTimeout > 0 {
+		busy = o.BusyTimeout
+	}
+	q := url.Values{}
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busy.Milliseconds()))
+	q.Add("_pragma", "journal_mode("+journal+")")
+	q.Add("_pragma", "synchronous("+sync+")")
+	q.Add("_pragma", "foreign_keys(1)")
+	// Transactions take the write lock when they begin, waiting for it
+	// like single statements do, rather than failing at once when a read
+	// in them turns into a write while another writer holds it
+	q.Set("_txlock", "immediate")
+	return dbPath + "?" + q.Encode()
+}
+
+// OpenSQLite opens (creating if needed) the database at dbPath and brings its
+// schema up to date.
+func OpenSQLite(dbPath string, opts SQLiteOptions) (*SQLite, error) {
+	// Create directory if it doesn't exist
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", opts.dsn(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	open, idle := opts.pool()
+	db.SetMaxOpenConns(open)
+	db.SetMaxIdleConns(idle)
+
+	s := &SQLite{db: db, opts: opts}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.prepare(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// SQLite falls back to another mode where WAL is not supported
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err == nil && opts.JournalMode == "" && !strings.EqualFold(mode, "wal") {
+		slog.Warn("Database is not in WAL journal mode; redirects wait for writes", "journal_mode", mode)
+	}
+	slog.Info("Database initialized successfully", "journal_mode", mode)
+	return s, nil
+}
+
+func (s *SQLite) Close() error {
+	s.closeStmts()
+	return s.db.Close()
+}
+
+// backupPages is how many pages a backup copies at a time. Writers wait
+// for each step only, not for the whole backup.
+const backupPages = 256
+
+// Backup writes a consistent copy of the database file to w, taken with
+// SQLite's online backup API while the database stays in use.
+func (s *SQLite) Backup(ctx context.Context, w io.Writer) error {
+	tmp, err := os.CreateTemp("", "golinks-backup-*.db")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.Raw(func(dc any) error {
+		src, ok := dc.(interface {
+			NewBackup(dstURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("driver does not support online backups")
+		}
+		b, err := src.NewBackup(tmp.Name())
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if err := ctx.Err(); err != nil {
+				b.Finish()
+				return err
+			}
+			if more, err = b.Step(backupPages); err != nil {
+				b.Finish()
+				return err
+			}
+		}
+		return b.Finish()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// withTimeout applies the configured per-query timeout to ctx.
+func (s *SQLite) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.opts.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.opts.QueryTimeout)
+}
+
+// linkColumns are the columns scanLink reads, in order.
+const linkColumns = "slug, url, status, created_by, approved_by, public, review_at, review_months, access_rules, clicks, last_used, pin, hit_budget, failover, referrer, created_at, list_opens, updated_at, fields"
+
+// scanLink scans a row of linkColumns followed by extra.
+func scanLink(row interface{ Scan(...any) error }, extra ...any) (Link, error) {
+	var link Link
+	var access, fields string
+	var lastUsed int64
+	dest := []any{&link.Slug, &link.URL, &link.Status, &link.CreatedBy, &link.ApprovedBy, &link.Public, &link.ReviewAt, &link.ReviewMonths, &access, &link.Clicks, &lastUsed, &link.Pin, &link.HitBudget, &link.Failover, &link.Referrer, &link.CreatedAt, &link.ListOpens, &link.UpdatedAt, &fields}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return Link{}, err
+	}
+	if lastUsed != 0 {
+		t := time.Unix(lastUsed, 0).UTC()
+		link.LastUsedAt = &t
+	}
+	var err error
+	if link.Access, err = decodeAccess(access); err != nil {
+		return Link{}, err
+	}
+	if link.Fields, err = decodeFields(fields); err != nil {
+		return Link{}, err
+	}
+	return link, nil
+}
+
+func (s *SQLite) GetLink(ctx context.Context, slug string) (*Link, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	link, err := scanLink(s.stmts.getLink.QueryRowContext(ctx, slug))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (s *SQLite) ListLinks(ctx context.Context) ([]Link, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var links []Link
+	err := s.EachLink(ctx, func(link Link) error {
+		links = append(links, link)
+		return nil
+	})
+	return links, err
+}
+
+// eachLinkBatch is how many rows EachLink reads per query.
+const eachLinkBatch = 500
+
+// EachLink reads links in keyset-paged batches, each under the query
+// timeout, and only calls fn once a batch's cursor is closed. A slow
+// consumer, such as a browser on the list page, therefore never holds a
+// read lock that would make concurrent writes fail with SQLITE_BUSY.
+func (s *SQLite) EachLink(ctx context.Context, fn func(Link) error) error {
+	return s.EachLinkBy(ctx, OrderNewest, fn)
+}
+
+// linkOrder is how one LinkOrder pages through links: rows are sorted by
+// orderBy, and after selects the rows following a cursor given as the
+// cursor expression of the last row read and its slug (both twice).
+type linkOrder struct {
+	orderBy, after, cursor string
+}
+
+var linkOrders = map[LinkOrder]linkOrder{
+	// created_at is compared as its raw text, so the cursor matches the
+	// stored value exactly
+	OrderNewest: {"created_at DESC, slug DESC", "created_at < ?1 OR (created_at = ?1 AND slug < ?2)", "CAST(created_at AS TEXT)"},
+	OrderClicks: {"clicks DESC, slug", "clicks < ?1 OR (clicks = ?1 AND slug > ?2)", "clicks"},
+	OrderRecent: {"last_used DESC, slug", "last_used < ?1 OR (last_used = ?1 AND slug > ?2)", "last_used"},
+	OrderAlpha:  {"slug", "slug > ?2", "slug"},
+	OrderPinned: {"pin DESC, slug", "pin < ?1 OR (pin = ?1 AND slug > ?2)", "pin"},
+}
+
+func (s *SQLite) EachLinkBy(ctx context.Context, order LinkOrder, fn func(Link) error) error {
+	o, ok := linkOrders[order]
+	if !ok {
+		return fmt.Errorf("unknown link order %q", order)
+	}
+	var (
+		cursor     any
+		cursorSlug string
+		first      = true
+	)
+	for {
+		batch, last, err := s.linkPage(ctx, o, first, cursor, cursorSlug)
+		if err != nil {
+			return err
+		}
+		for _, link := range batch {
+			if err := fn(link); err != nil {
+				return err
+			}
+		}
+		if len(batch) < eachLinkBatch {
+			return nil
+		}
+		first = false
+		cursor, cursorSlug = last, batch[len(batch)-1].Slug
+	}
+}
+
+// linkPage returns up to eachLinkBatch links in order o that sort after the
+// (cursor, cursorSlug) keyset cursor, plus the cursor of the last one.
+func (s *SQLite) linkPage(ctx context.Context, o linkOrder, first bool, cursor any, cursorSlug string) ([]Link, any, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	columns := "SELECT " + linkColumns + ", " + o.cursor + " FROM links"
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if first {
+		rows, err = s.db.QueryContext(ctx, columns+" ORDER BY "+o.orderBy+" LIMIT ?", eachLinkBatch)
+	} else {
+		rows, err = s.db.QueryContext(ctx, columns+" WHERE "+o.after+" ORDER BY "+o.orderBy+" LIMIT ?3", cursor, cursorSlug, eachLinkBatch)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	links := make([]Link, 0, eachLinkBatch)
+	var last any
+	for rows.Next() {
+		link, err := scanLink(rows, &last)
+		if err != nil {
+			return nil, nil, err
+		}
+		links = append(links, link)
+	}
+	return links, last, rows.Err()
+}
+
+func (s *SQLite) CountLinks(ctx context.Context) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var n int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM links").Scan(&n)
+	return n, err
+}
+
+func (s *SQLite) AddLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	res, err := s.db.ExecContext(ctx, "INSERT INTO links (slug, url, status, created_by, public, updated_at) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (slug) DO NOTHING",
+		link.Slug, link.URL, link.Status, link.CreatedBy, link.Public, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrConflict)
+}
+
+func (s *SQLite) CreateLink(ctx context.Context, link Link, aliases, collections []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	link.ApprovedBy, link.Clicks, link.ListOpens, link.LastUsedAt = "", 0, 0, nil
+	link.CreatedAt, link.UpdatedAt = time.Now(), time.Time{}
+	if err := insertLink(ctx, tx, link); err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		var taken int
+		err := tx.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM links WHERE slug = ?) + (SELECT COUNT(*) FROM aliases WHERE slug = ?)", alias, alias).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrConflict
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO aliases (slug, target) VALUES (?, ?)", alias, link.Slug); err != nil {
+			return err
+		}
+	}
+	for _, name := range collections {
+		var exists int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM collections WHERE name = ?", name).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return ErrNotFound
+		}
+		// A collection may already list the slug from before it existed
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO collection_links (collection, slug, position)
+			SELECT ?, ?, COALESCE(MAX(position), -1) + 1 FROM collection_links WHERE collection = ?`, name, link.Slug, name)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) RestoreLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return insertLink(ctx, s.db, link)
+}
+
+// execer is what insertLink needs of *sql.DB or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertLink inserts link with every field as given, or returns
+// ErrConflict if the slug is taken. A zero UpdatedAt is set to CreatedAt.
+func insertLink(ctx context.Context, db execer, link Link) error {
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = link.CreatedAt
+	}
+	access, err := encodeAccess(link.Access)
+	if err != nil {
+		return err
+	}
+	fields, err := encodeFields(link.Fields)
+	if err != nil {
+		return err
+	}
+	var reviewAt any
+	if link.ReviewAt != nil {
+		reviewAt = link.ReviewAt.UTC()
+	}
+	var lastUsed int64
+	if link.LastUsedAt != nil {
+		lastUsed = link.LastUsedAt.Unix()
+	}
+	res, err := db.ExecContext(ctx, "INSERT INTO links ("+linkColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (slug) DO NOTHING",
+		link.Slug, link.URL, link.Status, link.CreatedBy, link.ApprovedBy, link.Public, reviewAt, link.ReviewMonths,
+		access, link.Clicks, lastUsed, link.Pin, link.HitBudget, link.Failover, link.Referrer, link.CreatedAt.UTC(), link.ListOpens, link.UpdatedAt.UTC(), fields)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrConflict)
+}
+
+func (s *SQLite) ApproveLink(ctx context.Context, slug, url, approvedBy string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET status = ?, approved_by = ?, updated_at = ? WHERE slug = ? AND status = ? AND url = ?",
+		StatusActive, approvedBy, time.Now().UTC(), slug, StatusPending, url)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrConflict)
+}
+
+func (s *SQLite) UpdateLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET url = ?, status = ?, created_by = ?, approved_by = '', updated_at = ? WHERE slug = ?",
+		link.URL, link.Status, link.CreatedBy, time.Now().UTC(), link.Slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) ReplaceLink(ctx context.Context, link Link) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if link.Status == "" {
+		link.Status = StatusActive
+	}
+	if link.UpdatedAt.IsZero() {
+		link.UpdatedAt = time.Now()
+	}
+	access, err := encodeAccess(link.Access)
+	if err != nil {
+		return err
+	}
+	fields, err := encodeFields(link.Fields)
+	if err != nil {
+		return err
+	}
+	var reviewAt any
+	if link.ReviewAt != nil {
+		reviewAt = link.ReviewAt.UTC()
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE links SET url = ?, status = ?, created_by = ?, approved_by = ?, public = ?, review_at = ?, review_months = ?,
+		access_rules = ?, pin = ?, hit_budget = ?, failover = ?, referrer = ?, fields = ?, updated_at = ? WHERE slug = ?`,
+		link.URL, link.Status, link.CreatedBy, link.ApprovedBy, link.Public, reviewAt, link.ReviewMonths,
+		access, link.Pin, link.HitBudget, link.Failover, link.Referrer, fields, link.UpdatedAt.UTC(), link.Slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetPublic(ctx context.Context, slug string, public bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET public = ?, updated_at = ? WHERE slug = ?", public, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetReview(ctx context.Context, slug string, at *time.Time, months int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var reviewAt any
+	if at != nil {
+		reviewAt = at.UTC()
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET review_at = ?, review_months = ?, updated_at = ? WHERE slug = ?", reviewAt, months, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) RemoveLink(ctx context.Context, slug string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM links WHERE slug = ?", slug)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res, ErrNotFound); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collection_links WHERE slug = ?", slug); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM clicks WHERE slug = ?", slug); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM aliases WHERE target = ?", slug); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) RenameLink(ctx context.Context, from, to string, alias bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM links WHERE slug = ?", to).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrConflict
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE links SET slug = ?, updated_at = ? WHERE slug = ?", to, time.Now().UTC(), from)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res, ErrNotFound); err != nil {
+		return err
+	}
+
+	// A stale membership of to wins over the renamed one
+	if _, err := tx.ExecContext(ctx, "UPDATE OR IGNORE collection_links SET slug = ? WHERE slug = ?", to, from); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collection_links WHERE slug = ?", from); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE clicks SET slug = ? WHERE slug = ?", to, from); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM aliases WHERE slug = ?", to); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE aliases SET target = ? WHERE target = ?", to, from); err != nil {
+		return err
+	}
+	if alias {
+		if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO aliases (slug, target) VALUES (?, ?)", from, to); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ResolveAlias(ctx context.Context, slug string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var target string
+	err := s.stmts.resolveAlias.QueryRowContext(ctx, slug).Scan(&target)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return target, err
+}
+
+func (s *SQLite) ListAliases(ctx context.Context) ([]Alias, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT slug, target FROM aliases ORDER BY slug")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []Alias{}
+	for rows.Next() {
+		var a Alias
+		if err := rows.Scan(&a.Slug, &a.Target); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+func (s *SQLite) SetAlias(ctx context.Context, a Alias) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM links WHERE slug = ?", a.Slug).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrConflict
+	}
+	// The insert only happens with a link at the target
+	res, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO aliases (slug, target) SELECT ?, slug FROM links WHERE slug = ?", a.Slug, a.Target)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res, ErrNotFound); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) RemoveAlias(ctx context.Context, slug string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM aliases WHERE slug = ?", slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetAccess(ctx context.Context, slug string, rules []AccessRule) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	access, err := encodeAccess(rules)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET access_rules = ?, updated_at = ? WHERE slug = ?", access, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetPin(ctx context.Context, slug string, pin int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET pin = ?, updated_at = ? WHERE slug = ?", pin, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetHitBudget(ctx context.Context, slug string, hitsPerDay int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET hit_budget = ?, updated_at = ? WHERE slug = ?", hitsPerDay, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetFailover(ctx context.Context, slug, url string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET failover = ?, updated_at = ? WHERE slug = ?", url, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetReferrer(ctx context.Context, slug, policy string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET referrer = ?, updated_at = ? WHERE slug = ?", policy, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+func (s *SQLite) SetFields(ctx context.Context, slug string, fields map[string]string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	encoded, err := encodeFields(fields)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, "UPDATE links SET fields = ?, updated_at = ? WHERE slug = ?", encoded, time.Now().UTC(), slug)
+	if err != nil {
+		return err
+	}
+	return expectRow(res, ErrNotFound)
+}
+
+// clickTotal sums up the clicks of one link in a batch.
+type clickTotal struct {
+	n, fromList int
+	last        int64
+}
+
+// clickTotals sums up a batch of clicks by slug.
+func clickTotals(clicks []Click) map[string]clickTotal {
+	totals := make(map[string]clickTotal)
+	for _, c := range clicks {
+		t := totals[c.Slug]
+		t.n++
+		if c.FromList {
+			t.fromList++
+		}
+		t.last = max(t.last, c.At.Unix())
+		totals[c.Slug] = t
+	}
+	return totals
+}
+
+func (s *SQLite) RecordClicks(ctx context.Context, clicks []Click) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	totals := clickTotals(clicks)
+	insert, add := tx.StmtContext(ctx, s.stmts.insertClick), tx.StmtContext(ctx, s.stmts.addClicks)
+	for _, c := range clicks {
+		if c.Unsampled {
+			continue
+		}
+		if _, err := insert.ExecContext(ctx, c.Slug, c.At.Unix(), c.weight()); err != nil {
+			return err
+		}
+	}
+	for slug, t := range totals {
+		if _, err := add.ExecContext(ctx, t.n, t.fromList, t.last, slug); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) ClickCounts(ctx context.Context, since time.Time) (map[string]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT slug, SUM(weight) FROM clicks WHERE at >= ? GROUP BY slug", since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var slug string
+		var n int
+		if err := rows.Scan(&slug, &n); err != nil {
+			return nil, err
+		}
+		counts[slug] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *SQLite) ClickTimes(ctx context.Context, slug string, since time.Time) ([]time.Time, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT at, weight FROM clicks WHERE slug = ? AND at >= ? ORDER BY at", slug, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var at int64
+		var weight int
+		if err := rows.Scan(&at, &weight); err != nil {
+			return nil, err
+		}
+		times = appendClickTimes(times, time.Unix(at, 0).UTC(), weight)
+	}
+	return times, rows.Err()
+}
+
+// clickBucket is the span ClickDays counts clicks in before assigning them
+// to days. Every time zone offset is a multiple of it, so no bucket spans
+// midnight.
+const clickBucket = 15 * 60
+
+func (s *SQLite) ClickDays(ctx context.Context, slug string, since time.Time, loc *time.Location) (map[string]int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT at / ?, SUM(weight) FROM clicks WHERE at >= ? GROUP BY 1"
+	args := []any{clickBucket, since.Unix()}
+	if slug != "" {
+		query = "SELECT at / ?, SUM(weight) FROM clicks WHERE slug = ? AND at >= ? GROUP BY 1"
+		args = []any{clickBucket, slug, since.Unix()}
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[string]int)
+	for rows.Next() {
+		var bucket int64
+		var n int
+		if err := rows.Scan(&bucket, &n); err != nil {
+			return nil, err
+		}
+		days[time.Unix(bucket*clickBucket, 0).In(loc).Format(time.DateOnly)] += n
+	}
+	return days, rows.Err()
+}
+
+func (s *SQLite) PruneClicks(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM clicks WHERE at < ?", before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// encodeAccess formats rules for the access_rules column.
+func encodeAccess(rules []AccessRule) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(rules)
+	return string(data), err
+}
+
+// decodeAccess parses the access_rules column; empty means no rules.
+func decodeAccess(access string) ([]AccessRule, error) {
+	if access == "" {
+		return nil, nil
+	}
+	var rules []AccessRule
+	if err := json.Unmarshal([]byte(access), &rules); err != nil {
+		return nil, fmt.Errorf("invalid access rules: %w", err)
+	}
+	return rules, nil
+}
+
+// encodeFields formats custom fields for the fields column.
+func encodeFields(fields map[string]string) (string, error) {
+	if len(fields) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+// decodeFields parses the fields column; empty means no fields.
+func decodeFields(fields string) (map[string]string, error) {
+	if fields == "" {
+		return nil, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(fields), &m); err != nil {
+		return nil, fmt.Errorf("invalid custom fields: %w", err)
+	}
+	return m, nil
+}
+
+func (s *SQLite) SaveCollection(ctx context.Context, c Collection) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO collections (name, title, description, created_by) VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET title = excluded.title, description = excluded.description`,
+		c.Name, c.Title, c.Description, c.CreatedBy)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collection_links WHERE collection = ?", c.Name); err != nil {
+		return err
+	}
+	for i, slug := range c.Slugs {
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO collection_links (collection, slug, position) VALUES (?, ?, ?)", c.Name, slug, i); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) GetCollection(ctx context.Context, name string) (*Collection, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	c := Collection{Slugs: []string{}}
+	err := s.db.QueryRowContext(ctx, "SELECT name, title, description, created_by, created_at FROM collections WHERE name = ?", name).
+		Scan(&c.Name, &c.Title, &c.Description, &c.CreatedBy, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT slug FROM collection_links WHERE collection = ? ORDER BY position", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		c.Slugs = append(c.Slugs, slug)
+	}
+	return &c, rows.Err()
+}
+
+func (s *SQLite) ListCollections(ctx context.Context) ([]Collection, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT name, title, description, created_by, created_at FROM collections ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	collections := []Collection{}
+	index := map[string]int{}
+	for rows.Next() {
+		c := Collection{Slugs: []string{}}
+		if err := rows.Scan(&c.Name, &c.Title, &c.Description, &c.CreatedBy, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		index[c.Name] = len(collections)
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, "SELECT collection, slug FROM collection_links ORDER BY collection, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, slug string
+		if err := rows.Scan(&name, &slug); err != nil {
+			return nil, err
+		}
+		if i, ok := index[name]; ok {
+			collections[i].Slugs = append(collections[i].Slugs, slug)
+		}
+	}
+	return collections, rows.Err()
+}
+
+func (s *SQLite) RemoveCollection(ctx context.Context, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM collections WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if err := expectRow(res, ErrNotFound); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collection_links WHERE collection = ?", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// expectRow returns errNone if a statement changed no rows.
+func expectRow(res sql.Result, errNone error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNone
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+// Package store defines the link storage interface and its implementations.
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+type Link struct {
+	Slug       string `json:"slug"`
+	URL        string `json:"url"`
+	Status     string `json:"status"`
+	CreatedBy  string `json:"created_by,omitempty"`
+	ApprovedBy string `json:"approved_by,omitempty"`
+	// Public links are meant to be found: they are listed in the sitemap.
+	Public bool `json:"public"`
+	// ReviewAt is when the owner is next reminded to review the link, nil
+	// for never. After a reminder it moves ReviewMonths ahead, or is
+	// cleared if ReviewMonths is 0.
+	ReviewAt     *time.Time `json:"review_at,omitempty"`
+	ReviewMonths int        `json:"review_months,omitempty"`
+	// Access limits when clients in an IP group may open the link. Groups
+	// without a rule are not restricted.
+	Access []AccessRule `json:"access,omitempty"`
+	// Clicks counts redirects; LastUsedAt is the latest, nil if never.
+	Clicks     int        `json:"clicks"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// ListOpens counts the redirects opened from the list page, which
+	// are also in Clicks.
+	ListOpens int `json:"list_opens,omitempty"`
+	// Pin places a link in the pinned order: higher pins come first, 0 is
+	// unpinned.
+	Pin int `json:"pin,omitempty"`
+	// HitBudget is how many redirects a day are expected at most; more
+	// raise an alert. 0 means no budget.
+	HitBudget int `json:"hit_budget,omitempty"`
+	// Failover is a backup destination, used instead of URL while the
+	// health checker finds URL broken. Empty means none.
+	Failover string `json:"failover,omitempty"`
+	// Referrer is what the destination is told of the page the link was
+	// opened from: ReferrerStrip, ReferrerReplace, or "" to leave it to
+	// the browser.
+	Referrer string `json:"referrer,omitempty"`
+	// Fields are custom fields such as "cost_center" or "room", keyed by
+	// field name. Which names are allowed is up to the caller; the store
+	// keeps whatever it is given.
+	Fields    map[string]string `json:"fields,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	// UpdatedAt is when the link or its settings last changed, set by the
+	// store; redirects don't count. Zero in exports from before it was
+	// kept.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Alias is another slug of a link, kept when the link was renamed or
+// given when it was created.
+type Alias struct {
+	Slug   string `json:"slug"`
+	Target string `json:"target"`
+}
+
+// Click is one redirect of a link.
+type Click struct {
+	Slug string
+	At   time.Time
+	// FromList is whether the link was opened from the list page.
+	FromList bool
+	// Weight is how many clicks a stored click stands for when clicks are
+	// sampled, itself included; 0 is 1. The per-click counts add up
+	// weights.
+	Weight int
+	// Unsampled is a click sampling left out: it counts in its link's
+	// totals but is not stored on its own.
+	Unsampled bool
+}
+
+// weight returns how many clicks c stands for in the per-click counts.
+func (c Click) weight() int {
+	return max(c.Weight, 1)
+}
+
+// appendClickTimes appends at to times once for each of the weight clicks
+// a stored click stands for.
+func appendClickTimes(times []time.Time, at time.Time, weight int) []time.Time {
+	for range max(weight, 1) {
+		times = append(times, at)
+	}
+	return times
+}
+
+// LinkOrder is an order EachLinkBy can list links in. Ties are broken by
+// slug.
+type LinkOrder string
+
+const (
+	// OrderNewest lists the newest links first, as ListLinks does.
+	OrderNewest LinkOrder = "newest"
+	// OrderClicks lists the most clicked links first.
+	OrderClicks LinkOrder = "clicks"
+	// OrderRecent lists the most recently used links first.
+	OrderRecent LinkOrder = "recent"
+	// OrderAlpha lists links by slug.
+	OrderAlpha LinkOrder = "alpha"
+	// OrderPinned lists pinned links by descending pin, then the rest by
+	// slug.
+	OrderPinned LinkOrder = "pinned"
+)
+
+// LinkOrders are all orders, in the order a UI should offer them.
+var LinkOrders = []LinkOrder{OrderNewest, OrderClicks, OrderRecent, OrderAlpha, OrderPinned}
+
+// Valid reports whether o is one of LinkOrders.
+func (o LinkOrder) Valid() bool {
+	return slices.Contains(LinkOrders, o)
+}
+
+// AccessRule is a weekly window in which clients of an IP group may open a
+// link, e.g. "kids" on "fri-sat" from "15:00" to "21:00". A window whose To
+// is not after From ends on the next day.
+type AccessRule struct {
+	Group string `json:"group"`
+	// Days is a comma-separated list of days and ranges such as
+	// "mon-thu,sun"; empty means every day.
+	Days string `json:"days,omitempty"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Collection is a named, ordered group of links with its own page, such as
+// go/+onboarding.
+type Collection struct {
+	Name        string    `json:"name"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Slugs       []string  `json:"slugs"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Link statuses. Links pointing at sensitive destinations start out pending
+// and only resolve once a second admin approves them. Reserved links hold a
+// slug without a URL until their creator, or another admin, claims them.
+const (
+	StatusActive   = "active"
+	StatusPending  = "pending"
+	StatusReserved = "reserved"
+)
+
+// Referrer policies of a link. Both redirect through a page on golinks
+// rather than straight to the destination.
+const (
+	// ReferrerStrip sends no Referer at all.
+	ReferrerStrip = "strip"
+	// ReferrerReplace sends the golinks origin as the Referer instead of
+	// the page the link was opened from.
+	ReferrerReplace = "replace"
+)
+
+// Errors returned by Store implementations. Callers should test for them
+// with errors.Is so every backend maps to the same HTTP statuses.
+var (
+	ErrNotFound = errors.New("link not found")
+	ErrConflict = errors.New("slug already exists")
+)
+
+// Store persists links. Implementations must be safe for concurrent use and
+// should give up once ctx is done.
+type Store interface {
+	// GetLink returns the link for slug regardless of its status, or
+	// ErrNotFound.
+	GetLink(ctx context.Context, slug string) (*Link, error)
+	// ListLinks returns all links, newest first (ties broken by slug,
+	// descending).
+	ListLinks(ctx context.Context) ([]Link, error)
+	// EachLink calls fn for every link in ListLinks order without loading
+	// them all into memory. Iteration stops at the first error fn returns.
+	EachLink(ctx context.Context, fn func(Link) error) error
+	// EachLinkBy is EachLink in the given order, which must be valid.
+	EachLinkBy(ctx context.Context, order LinkOrder, fn func(Link) error) error
+	// CountLinks returns the number of links.
+	CountLinks(ctx context.Context) (int, error)
+	// AddLink inserts a new link, or returns ErrConflict if the slug is
+	// taken. CreatedAt is set by the store.
+	AddLink(ctx context.Context, link Link) error
+	// CreateLink adds a new link with every setting as given, plus the
+	// aliases pointing at it and its place at the end of the named
+	// collections, as one change: either all of it is stored or nothing
+	// is. Clicks, last use and approver are ignored and CreatedAt is set
+	// by the store. It returns ErrConflict if the slug or an alias is
+	// taken by a link or an alias, or ErrNotFound if a collection does
+	// not exist.
+	CreateLink(ctx context.Context, link Link, aliases, collections []string) error
+	// RemoveLink deletes the link for slug, its clicks and the aliases
+	// pointing at it, and drops it from every collection, or returns
+	// ErrNotFound.
+	RemoveLink(ctx context.Context, slug string) error
+	// RenameLink moves the link at from to the slug to, together with its
+	// clicks, collection memberships and aliases. With alias, from is kept
+	// as an alias of to. It returns ErrNotFound if there is no link at
+	// from, or ErrConflict if to is taken by a link; an alias named to is
+	// replaced.
+	RenameLink(ctx context.Context, from, to string, alias bool) error
+	// ResolveAlias returns the slug a renamed link moved to from slug, or
+	// ErrNotFound.
+	ResolveAlias(ctx context.Context, slug string) (string, error)
+	// ListAliases returns every alias, sorted by slug.
+	ListAliases(ctx context.Context) ([]Alias, error)
+	// SetAlias points the alias a.Slug at the link a.Target, replacing an
+	// alias of that name, as when restoring an archive. It returns
+	// ErrConflict if a link has the slug, or ErrNotFound if there is no
+	// link at the target.
+	SetAlias(ctx context.Context, a Alias) error
+	// RemoveAlias deletes the alias slug, or returns ErrNotFound.
+	RemoveAlias(ctx context.Context, slug string) error
+	// ApproveLink marks a pending link active and records the approver. It
+	// only applies while the link is still pending and points at url, the
+	// destination the approver reviewed; otherwise it returns ErrConflict.
+	ApproveLink(ctx context.Context, slug, url, approvedBy string) error
+	// RestoreLink inserts a link with every field as given, including its
+	// creation time and click count, as when restoring an archive, or
+	// returns ErrConflict if the slug is taken. A zero CreatedAt is set to
+	// now, a zero UpdatedAt to CreatedAt.
+	RestoreLink(ctx context.Context, link Link) error
+	// ReplaceLink replaces every setting of an existing link with those of
+	// link, its approver and UpdatedAt included, as when syncing with
+	// another instance. Clicks, creation time, aliases and collections
+	// stay. A zero UpdatedAt is set to now. It returns ErrNotFound if
+	// there is no link at the slug.
+	ReplaceLink(ctx context.Context, link Link) error
+	// UpdateLink replaces the destination, status and creator of an
+	// existing link and clears its approver, or returns ErrNotFound.
+	UpdateLink(ctx context.Context, link Link) error
+	// SetPublic marks a link public or private, or returns ErrNotFound.
+	SetPublic(ctx context.Context, slug string, public bool) error
+	// SetReview sets or, with a nil at, clears the review reminder of a
+	// link, or returns ErrNotFound.
+	SetReview(ctx context.Context, slug string, at *time.Time, months int) error
+	// SetAccess replaces the access rules of a link, or returns
+	// ErrNotFound.
+	SetAccess(ctx context.Context, slug string, rules []AccessRule) error
+	// SetPin sets the pin of a link, 0 to unpin it, or returns ErrNotFound.
+	SetPin(ctx context.Context, slug string, pin int) error
+	// SetHitBudget sets the daily hit budget of a link, 0 to remove it, or
+	// returns ErrNotFound.
+	SetHitBudget(ctx context.Context, slug string, hitsPerDay int) error
+	// SetFailover sets the backup destination of a link, "" to remove it,
+	// or returns ErrNotFound.
+	SetFailover(ctx context.Context, slug, url string) error
+	// SetReferrer sets the referrer policy of a link, "" to leave the
+	// Referer to the browser, or returns ErrNotFound.
+	SetReferrer(ctx context.Context, slug, policy string) error
+	// SetFields replaces the custom fields of a link, nil or empty to
+	// clear them, or returns ErrNotFound.
+	SetFields(ctx context.Context, slug string, fields map[string]string) error
+	// RecordClicks stores redirects and adds them to the click counts and
+	// last use of their links. Clicks of links that no longer exist are
+	// dropped.
+	RecordClicks(ctx context.Context, clicks []Click) error
+	// ClickCounts returns the number of clicks per slug since since. Slugs
+	// without clicks are left out.
+	ClickCounts(ctx context.Context, since time.Time) (map[string]int, error)
+	// ClickTimes returns when slug was clicked since since, oldest first.
+	ClickTimes(ctx context.Context, slug string, since time.Time) ([]time.Time, error)
+	// ClickDays counts the clicks of slug since since per calendar day in
+	// loc, keyed by date ("2006-01-02"); an empty slug counts the clicks of
+	// every link. Days without clicks are left out.
+	ClickDays(ctx context.Context, slug string, since time.Time, loc *time.Location) (map[string]int, error)
+	// PruneClicks deletes the clicks before before and returns how many
+	// there were. Link click counts are kept.
+	PruneClicks(ctx context.Context, before time.Time) (int64, error)
+
+	// SaveCollection creates a collection or replaces the title,
+	// description and slugs of an existing one. Slugs keep their order.
+	// CreatedBy and CreatedAt are only set on creation.
+	SaveCollection(ctx context.Context, c Collection) error
+	// GetCollection returns the named collection, or ErrNotFound.
+	GetCollection(ctx context.Context, name string) (*Collection, error)
+	// ListCollections returns all collections ordered by name.
+	ListCollections(ctx context.Context) ([]Collection, error)
+	// RemoveCollection deletes a collection, not its links, or returns
+	// ErrNotFound.
+	RemoveCollection(ctx context.Context, name string) error
+	Close() error
+}
+
+// The backends behind Store; a method missing from one fails the build here
+// rather than where it is plugged in.
+var (
+	_ Store = (*SQLite)(nil)
+	_ Store = (*Server)(nil)
+	_ Store = (*Bolt)(nil)
+	_ Store = (*Memory)(nil)
+	_ Store = (*Coalescing)(nil)
+	_ Store = (*LRUCache)(nil)
+	_ Store = (*RedisCache)(nil)
+)
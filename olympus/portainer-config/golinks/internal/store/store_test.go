@@ -0,0 +1,814 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore exercises the behaviour every Store implementation must share.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := s.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"}); err != nil {
+		t.Fatalf("AddLink: %v", err)
+	}
+	if err := s.AddLink(ctx, Link{Slug: "pay", URL: "https://pay.example.com", Status: StatusPending, CreatedBy: "alice"}); err != nil {
+		t.Fatalf("AddLink pending: %v", err)
+	}
+	if err := s.AddLink(ctx, Link{Slug: "wiki", URL: "https://other.example.com"}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("AddLink duplicate = %v, want ErrConflict", err)
+	}
+
+	link, err := s.GetLink(ctx, "wiki")
+	if err != nil {
+		t.Fatalf("GetLink: %v", err)
+	}
+	if link.URL != "https://wiki.example.com" || link.Status != StatusActive {
+		t.Errorf("GetLink = %+v", link)
+	}
+	if link.CreatedAt.IsZero() || link.UpdatedAt.IsZero() {
+		t.Error("GetLink: CreatedAt or UpdatedAt not set")
+	}
+	if _, err := s.GetLink(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLink missing = %v, want ErrNotFound", err)
+	}
+
+	links, err := s.ListLinks(ctx)
+	if err != nil {
+		t.Fatalf("ListLinks: %v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("ListLinks returned %d links, want 2", len(links))
+	}
+	if n, err := s.CountLinks(ctx); err != nil || n != 2 {
+		t.Errorf("CountLinks = %d, %v; want 2", n, err)
+	}
+
+	var streamed []string
+	err = s.EachLink(ctx, func(link Link) error {
+		streamed = append(streamed, link.Slug)
+		return nil
+	})
+	if err != nil || len(streamed) != 2 {
+		t.Errorf("EachLink streamed %v, %v; want 2 links", streamed, err)
+	}
+	stop := errors.New("stop")
+	calls := 0
+	err = s.EachLink(ctx, func(Link) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("EachLink with failing callback = %v after %d calls, want stop after 1", err, calls)
+	}
+
+	if err := s.ApproveLink(ctx, "pay", "https://other.example.com", "bob"); !errors.Is(err, ErrConflict) {
+		t.Errorf("ApproveLink with different URL = %v, want ErrConflict", err)
+	}
+	if err := s.ApproveLink(ctx, "pay", "https://pay.example.com", "bob"); err != nil {
+		t.Fatalf("ApproveLink: %v", err)
+	}
+	link, err = s.GetLink(ctx, "pay")
+	if err != nil {
+		t.Fatalf("GetLink after approve: %v", err)
+	}
+	if link.Status != StatusActive || link.CreatedBy != "alice" || link.ApprovedBy != "bob" {
+		t.Errorf("GetLink after approve = %+v", link)
+	}
+
+	if err := s.UpdateLink(ctx, Link{Slug: "pay", URL: "https://pay2.example.com", Status: StatusPending, CreatedBy: "carol"}); err != nil {
+		t.Fatalf("UpdateLink: %v", err)
+	}
+	link, err = s.GetLink(ctx, "pay")
+	if err != nil {
+		t.Fatalf("GetLink after update: %v", err)
+	}
+	if link.URL != "https://pay2.example.com" || link.Status != StatusPending || link.CreatedBy != "carol" || link.ApprovedBy != "" {
+		t.Errorf("GetLink after update = %+v", link)
+	}
+	if err := s.UpdateLink(ctx, Link{Slug: "missing", URL: "https://x.example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateLink missing = %v, want ErrNotFound", err)
+	}
+
+	if err := s.SetPublic(ctx, "pay", true); err != nil {
+		t.Fatalf("SetPublic: %v", err)
+	}
+	if link, err = s.GetLink(ctx, "pay"); err != nil || !link.Public {
+		t.Errorf("GetLink after SetPublic = %+v, %v", link, err)
+	}
+	if err := s.AddLink(ctx, Link{Slug: "news", URL: "https://news.example.com", Public: true}); err != nil {
+		t.Fatalf("AddLink public: %v", err)
+	}
+	public := map[string]bool{}
+	s.EachLink(ctx, func(link Link) error {
+		public[link.Slug] = link.Public
+		return nil
+	})
+	if !public["pay"] || !public["news"] || public["wiki"] {
+		t.Errorf("EachLink public flags = %v", public)
+	}
+	if err := s.SetPublic(ctx, "missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetPublic missing = %v, want ErrNotFound", err)
+	}
+
+	review := time.Date(2027, 1, 15, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	if err := s.SetReview(ctx, "pay", &review, 12); err != nil {
+		t.Fatalf("SetReview: %v", err)
+	}
+	if link, err = s.GetLink(ctx, "pay"); err != nil || link.ReviewAt == nil || !link.ReviewAt.Equal(review) || link.ReviewMonths != 12 {
+		t.Errorf("GetLink after SetReview = %+v, %v", link, err)
+	}
+	s.EachLink(ctx, func(link Link) error {
+		if (link.Slug == "pay") != (link.ReviewAt != nil) {
+			t.Errorf("EachLink %s review = %v", link.Slug, link.ReviewAt)
+		}
+		return nil
+	})
+	if err := s.SetReview(ctx, "pay", nil, 0); err != nil {
+		t.Fatalf("SetReview clear: %v", err)
+	}
+	if link, err = s.GetLink(ctx, "pay"); err != nil || link.ReviewAt != nil || link.ReviewMonths != 0 {
+		t.Errorf("GetLink after clearing review = %+v, %v", link, err)
+	}
+	if err := s.SetReview(ctx, "missing", &review, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetReview missing = %v, want ErrNotFound", err)
+	}
+
+	rules := []AccessRule{{Group: "kids", Days: "fri-sat", From: "15:00", To: "21:00"}, {Group: "kids", From: "09:00", To: "10:00"}}
+	if err := s.SetAccess(ctx, "pay", rules); err != nil {
+		t.Fatalf("SetAccess: %v", err)
+	}
+	if link, err = s.GetLink(ctx, "pay"); err != nil || fmt.Sprint(link.Access) != fmt.Sprint(rules) {
+		t.Errorf("GetLink after SetAccess = %+v, %v", link, err)
+	}
+	s.EachLink(ctx, func(link Link) error {
+		if link.Slug == "pay" && len(link.Access) != 2 {
+			t.Errorf("EachLink access = %+v", link.Access)
+		}
+		return nil
+	})
+	if err := s.SetAccess(ctx, "pay", nil); err != nil {
+		t.Fatalf("SetAccess clear: %v", err)
+	}
+	if link, err = s.GetLink(ctx, "pay"); err != nil || link.Access != nil {
+		t.Errorf("GetLink after clearing access = %+v, %v", link, err)
+	}
+	if err := s.SetAccess(ctx, "missing", rules); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetAccess missing = %v, want ErrNotFound", err)
+	}
+
+	// A restored link keeps every field
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	used := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	restored := Link{
+		Slug: "old", URL: "https://old.example.com", Status: StatusActive, CreatedBy: "alice", ApprovedBy: "bob",
+		Public: true, ReviewAt: &used, ReviewMonths: 6, Access: rules, Clicks: 42, LastUsedAt: &used, ListOpens: 5, Pin: 3, HitBudget: 9,
+		Failover: "http://old.lan", CreatedAt: created, UpdatedAt: used,
+	}
+	if err := s.RestoreLink(ctx, restored); err != nil {
+		t.Fatalf("RestoreLink: %v", err)
+	}
+	if got, err := s.GetLink(ctx, "old"); err != nil || fmt.Sprintf("%+v", *got) != fmt.Sprintf("%+v", restored) {
+		t.Errorf("GetLink restored = %+v, %v; want %+v", got, err, restored)
+	}
+	if err := s.RestoreLink(ctx, restored); !errors.Is(err, ErrConflict) {
+		t.Errorf("RestoreLink twice = %v, want ErrConflict", err)
+	}
+	// Changing a setting moves UpdatedAt; a click doesn't
+	if err := s.SetPin(ctx, "old", 4); err != nil {
+		t.Fatal(err)
+	}
+	pinned, err := s.GetLink(ctx, "old")
+	if err != nil || !pinned.UpdatedAt.After(used) {
+		t.Errorf("UpdatedAt after SetPin = %v, %v", pinned.UpdatedAt, err)
+	}
+	if err := s.RecordClicks(ctx, []Click{{Slug: "old", At: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetLink(ctx, "old"); err != nil || !got.UpdatedAt.Equal(pinned.UpdatedAt) {
+		t.Errorf("UpdatedAt after a click = %v, want %v", got.UpdatedAt, pinned.UpdatedAt)
+	}
+	// A replaced link takes every setting as given and keeps its clicks
+	synced := time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC)
+	replaced := Link{Slug: "old", URL: "https://new.example.com", Status: StatusPending, CreatedBy: "carol", Pin: 1, Referrer: ReferrerStrip,
+		Fields: map[string]string{"room": "B12"}, UpdatedAt: synced}
+	if err := s.ReplaceLink(ctx, replaced); err != nil {
+		t.Fatalf("ReplaceLink: %v", err)
+	}
+	got, err := s.GetLink(ctx, "old")
+	if err != nil || got.URL != replaced.URL || got.Status != StatusPending || got.CreatedBy != "carol" || got.ApprovedBy != "" ||
+		got.Public || got.ReviewAt != nil || got.Access != nil || got.Pin != 1 || got.HitBudget != 0 || got.Failover != "" ||
+		got.Referrer != ReferrerStrip || got.Fields["room"] != "B12" || !got.UpdatedAt.Equal(synced) || got.Clicks != 43 || got.ListOpens != 5 || !got.CreatedAt.Equal(created) {
+		t.Errorf("GetLink replaced = %+v, %v", got, err)
+	}
+	if err := s.ReplaceLink(ctx, Link{Slug: "missing", URL: "https://example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReplaceLink missing = %v, want ErrNotFound", err)
+	}
+	if err := s.RemoveLink(ctx, "old"); err != nil {
+		t.Fatalf("RemoveLink restored: %v", err)
+	}
+
+	if err := s.SaveCollection(ctx, Collection{Name: "onboarding", Title: "Onboarding", Slugs: []string{"wiki", "pay", "wiki"}, CreatedBy: "alice"}); err != nil {
+		t.Fatalf("SaveCollection: %v", err)
+	}
+	if err := s.SaveCollection(ctx, Collection{Name: "empty", CreatedBy: "bob"}); err != nil {
+		t.Fatalf("SaveCollection empty: %v", err)
+	}
+	c, err := s.GetCollection(ctx, "onboarding")
+	if err != nil || c.Title != "Onboarding" || c.CreatedBy != "alice" || fmt.Sprint(c.Slugs) != "[wiki pay]" || c.CreatedAt.IsZero() {
+		t.Errorf("GetCollection = %+v, %v", c, err)
+	}
+	// Saving again replaces the contents but keeps the creator
+	if err := s.SaveCollection(ctx, Collection{Name: "onboarding", Title: "Day one", Slugs: []string{"pay", "wiki"}, CreatedBy: "bob"}); err != nil {
+		t.Fatalf("SaveCollection replace: %v", err)
+	}
+	if c, err = s.GetCollection(ctx, "onboarding"); err != nil || c.Title != "Day one" || c.CreatedBy != "alice" || fmt.Sprint(c.Slugs) != "[pay wiki]" {
+		t.Errorf("GetCollection after replace = %+v, %v", c, err)
+	}
+	if _, err := s.GetCollection(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCollection missing = %v, want ErrNotFound", err)
+	}
+
+	// Renaming moves clicks, memberships and aliases along
+	clickAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := s.RecordClicks(ctx, []Click{{Slug: "pay", At: clickAt}}); err != nil {
+		t.Fatalf("RecordClicks: %v", err)
+	}
+	if err := s.RenameLink(ctx, "pay", "payments", true); err != nil {
+		t.Fatalf("RenameLink: %v", err)
+	}
+	if link, err := s.GetLink(ctx, "payments"); err != nil || link.URL != "https://pay2.example.com" || link.Clicks != 1 {
+		t.Errorf("GetLink renamed = %+v, %v", link, err)
+	}
+	if _, err := s.GetLink(ctx, "pay"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLink old slug = %v, want ErrNotFound", err)
+	}
+	if target, err := s.ResolveAlias(ctx, "pay"); err != nil || target != "payments" {
+		t.Errorf("ResolveAlias = %q, %v; want payments", target, err)
+	}
+	if times, err := s.ClickTimes(ctx, "payments", time.Time{}); err != nil || len(times) != 1 {
+		t.Errorf("ClickTimes renamed = %v, %v", times, err)
+	}
+	if c, err = s.GetCollection(ctx, "onboarding"); err != nil || fmt.Sprint(c.Slugs) != "[payments wiki]" {
+		t.Errorf("GetCollection after rename = %+v, %v", c, err)
+	}
+	if err := s.RenameLink(ctx, "payments", "wiki", false); !errors.Is(err, ErrConflict) {
+		t.Errorf("RenameLink to a taken slug = %v, want ErrConflict", err)
+	}
+	if err := s.RenameLink(ctx, "missing", "other", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RenameLink missing = %v, want ErrNotFound", err)
+	}
+	// Renaming back reclaims the alias
+	if err := s.RenameLink(ctx, "payments", "pay", false); err != nil {
+		t.Fatalf("RenameLink back: %v", err)
+	}
+	if _, err := s.ResolveAlias(ctx, "pay"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveAlias after renaming back = %v, want ErrNotFound", err)
+	}
+	if _, err := s.ResolveAlias(ctx, "payments"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveAlias of a slug renamed without alias = %v, want ErrNotFound", err)
+	}
+
+	if err := s.RemoveLink(ctx, "wiki"); err != nil {
+		t.Fatalf("RemoveLink: %v", err)
+	}
+	collections, err := s.ListCollections(ctx)
+	if err != nil || len(collections) != 2 || collections[0].Name != "empty" || len(collections[0].Slugs) != 0 || fmt.Sprint(collections[1].Slugs) != "[pay]" {
+		t.Errorf("ListCollections after removing a member = %+v, %v", collections, err)
+	}
+	if err := s.RemoveCollection(ctx, "empty"); err != nil {
+		t.Fatalf("RemoveCollection: %v", err)
+	}
+	if err := s.RemoveCollection(ctx, "empty"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveCollection twice = %v, want ErrNotFound", err)
+	}
+
+	if err := s.RemoveLink(ctx, "wiki"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveLink twice = %v, want ErrNotFound", err)
+	}
+	// Aliases go with their link
+	s.AddLink(ctx, Link{Slug: "old-docs", URL: "https://docs.example.com"})
+	s.RenameLink(ctx, "old-docs", "docs", true)
+	s.RenameLink(ctx, "docs", "handbook", true)
+	if target, err := s.ResolveAlias(ctx, "old-docs"); err != nil || target != "handbook" {
+		t.Errorf("ResolveAlias after two renames = %q, %v; want handbook", target, err)
+	}
+	if aliases, err := s.ListAliases(ctx); err != nil || fmt.Sprint(aliases) != "[{docs handbook} {old-docs handbook}]" {
+		t.Errorf("ListAliases = %v, %v", aliases, err)
+	}
+	if _, err := s.ResolveAlias(ctx, "hb"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveAlias(hb) = %v, want ErrNotFound", err)
+	}
+	if err := s.SetAlias(ctx, Alias{Slug: "hb", Target: "handbook"}); err != nil {
+		t.Fatalf("SetAlias: %v", err)
+	}
+	if target, err := s.ResolveAlias(ctx, "hb"); err != nil || target != "handbook" {
+		t.Errorf("ResolveAlias(hb) after SetAlias = %q, %v", target, err)
+	}
+	if err := s.SetAlias(ctx, Alias{Slug: "docs", Target: "pay"}); err != nil {
+		t.Errorf("SetAlias repointing docs: %v", err)
+	}
+	if err := s.SetAlias(ctx, Alias{Slug: "pay", Target: "handbook"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("SetAlias over a link = %v, want ErrConflict", err)
+	}
+	if err := s.SetAlias(ctx, Alias{Slug: "nowhere", Target: "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetAlias to a missing link = %v, want ErrNotFound", err)
+	}
+	if err := s.RemoveAlias(ctx, "hb"); err != nil {
+		t.Errorf("RemoveAlias: %v", err)
+	}
+	if err := s.RemoveAlias(ctx, "hb"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveAlias twice = %v, want ErrNotFound", err)
+	}
+	if _, err := s.ResolveAlias(ctx, "hb"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveAlias(hb) after RemoveAlias = %v, want ErrNotFound", err)
+	}
+	if aliases, err := s.ListAliases(ctx); err != nil || fmt.Sprint(aliases) != "[{docs pay} {old-docs handbook}]" {
+		t.Errorf("ListAliases after changes = %v, %v", aliases, err)
+	}
+	if err := s.RemoveLink(ctx, "handbook"); err != nil {
+		t.Fatalf("RemoveLink renamed: %v", err)
+	}
+	if _, err := s.ResolveAlias(ctx, "old-docs"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveAlias after removing the link = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetLink(ctx, "wiki"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLink after remove = %v, want ErrNotFound", err)
+	}
+	if err := s.ApproveLink(ctx, "pay", "https://pay.example.com", "bob"); !errors.Is(err, ErrConflict) {
+		t.Errorf("ApproveLink already active = %v, want ErrConflict", err)
+	}
+	if err := s.ApproveLink(ctx, "wiki", "https://wiki.example.com", "bob"); !errors.Is(err, ErrConflict) {
+		t.Errorf("ApproveLink missing = %v, want ErrConflict", err)
+	}
+}
+
+// testLinkOrders checks click recording, pins and EachLinkBy on an empty
+// store.
+func testLinkOrders(t *testing.T, s Store) {
+	ctx := context.Background()
+	for _, slug := range []string{"cal", "mail", "wiki", "docs"} {
+		if err := s.AddLink(ctx, Link{Slug: slug, URL: "https://" + slug + ".example.com"}); err != nil {
+			t.Fatalf("AddLink %s: %v", slug, err)
+		}
+	}
+	base := time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)
+	clicks := []Click{
+		{Slug: "mail", At: base, FromList: true}, {Slug: "mail", At: base.Add(time.Minute)}, {Slug: "wiki", At: base.Add(2 * time.Minute), FromList: true},
+		{Slug: "missing", At: base, FromList: true}, // dropped
+	}
+	if err := s.RecordClicks(ctx, clicks); err != nil {
+		t.Fatalf("RecordClicks: %v", err)
+	}
+	// A later batch may hold an older click
+	if err := s.RecordClicks(ctx, []Click{{Slug: "cal", At: base.Add(time.Hour)}, {Slug: "wiki", At: base.Add(time.Second), FromList: true}}); err != nil {
+		t.Fatalf("RecordClicks: %v", err)
+	}
+	counts, err := s.ClickCounts(ctx, base.Add(time.Second))
+	if err != nil || fmt.Sprint(counts) != "map[cal:1 mail:1 wiki:2]" {
+		t.Errorf("ClickCounts = %v, %v", counts, err)
+	}
+	times, err := s.ClickTimes(ctx, "wiki", base)
+	if err != nil || len(times) != 2 || !times[0].Equal(base.Add(time.Second)) || !times[1].Equal(base.Add(2*time.Minute)) {
+		t.Errorf("ClickTimes = %v, %v", times, err)
+	}
+	// 13:00 UTC is already the next day in UTC+11
+	east := time.FixedZone("UTC+11", 11*60*60)
+	if days, err := s.ClickDays(ctx, "", base, east); err != nil || fmt.Sprint(days) != "map[2024-05-03:4 2024-05-04:1]" {
+		t.Errorf("ClickDays of every link = %v, %v", days, err)
+	}
+	if days, err := s.ClickDays(ctx, "wiki", base.Add(time.Minute), time.UTC); err != nil || fmt.Sprint(days) != "map[2024-05-03:1]" {
+		t.Errorf("ClickDays of wiki = %v, %v", days, err)
+	}
+	// Sampled clicks: one stored for three, all three in the totals
+	if err := s.AddLink(ctx, Link{Slug: "hot", URL: "https://hot.example.com"}); err != nil {
+		t.Fatalf("AddLink hot: %v", err)
+	}
+	sampled := []Click{{Slug: "hot", At: base, Unsampled: true}, {Slug: "hot", At: base, Unsampled: true}, {Slug: "hot", At: base, Weight: 3}}
+	if err := s.RecordClicks(ctx, sampled); err != nil {
+		t.Fatalf("RecordClicks of sampled clicks: %v", err)
+	}
+	if link, err := s.GetLink(ctx, "hot"); err != nil || link.Clicks != 3 {
+		t.Errorf("hot after sampled clicks = %+v, %v", link, err)
+	}
+	if counts, err := s.ClickCounts(ctx, base); err != nil || counts["hot"] != 3 {
+		t.Errorf("ClickCounts of sampled clicks = %v, %v", counts, err)
+	}
+	if times, err := s.ClickTimes(ctx, "hot", base); err != nil || len(times) != 3 {
+		t.Errorf("ClickTimes of sampled clicks = %v, %v", times, err)
+	}
+	if days, err := s.ClickDays(ctx, "hot", base, time.UTC); err != nil || fmt.Sprint(days) != "map[2024-05-03:3]" {
+		t.Errorf("ClickDays of sampled clicks = %v, %v", days, err)
+	}
+	if err := s.RemoveLink(ctx, "hot"); err != nil {
+		t.Fatalf("RemoveLink hot: %v", err)
+	}
+	for slug, pin := range map[string]int{"wiki": 1, "docs": 5} {
+		if err := s.SetPin(ctx, slug, pin); err != nil {
+			t.Fatalf("SetPin %s: %v", slug, err)
+		}
+	}
+	if err := s.SetPin(ctx, "missing", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetPin missing = %v, want ErrNotFound", err)
+	}
+	if err := s.SetHitBudget(ctx, "wiki", 100); err != nil {
+		t.Fatalf("SetHitBudget: %v", err)
+	}
+	if err := s.SetHitBudget(ctx, "missing", 100); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetHitBudget missing = %v, want ErrNotFound", err)
+	}
+	if err := s.SetFailover(ctx, "wiki", "http://wiki.lan"); err != nil {
+		t.Fatalf("SetFailover: %v", err)
+	}
+	if err := s.SetFailover(ctx, "missing", "http://wiki.lan"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetFailover missing = %v, want ErrNotFound", err)
+	}
+	if err := s.SetReferrer(ctx, "wiki", ReferrerStrip); err != nil {
+		t.Fatalf("SetReferrer: %v", err)
+	}
+	if err := s.SetReferrer(ctx, "missing", ReferrerStrip); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetReferrer missing = %v, want ErrNotFound", err)
+	}
+	if err := s.SetFields(ctx, "wiki", map[string]string{"cost_center": "4711", "room": "B12"}); err != nil {
+		t.Fatalf("SetFields: %v", err)
+	}
+	if err := s.SetFields(ctx, "missing", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetFields missing = %v, want ErrNotFound", err)
+	}
+
+	link, err := s.GetLink(ctx, "wiki")
+	if err != nil || link.Clicks != 2 || link.ListOpens != 2 || link.LastUsedAt == nil || !link.LastUsedAt.Equal(base.Add(2*time.Minute)) || link.Pin != 1 || link.HitBudget != 100 || link.Failover != "http://wiki.lan" || link.Referrer != ReferrerStrip ||
+		len(link.Fields) != 2 || link.Fields["cost_center"] != "4711" {
+		t.Errorf("GetLink after clicks = %+v, %v", link, err)
+	}
+	if err := s.SetFields(ctx, "wiki", map[string]string{}); err != nil {
+		t.Fatalf("SetFields to clear: %v", err)
+	}
+	if link, err := s.GetLink(ctx, "wiki"); err != nil || link.Fields != nil {
+		t.Errorf("fields after clearing = %#v, %v", link.Fields, err)
+	}
+
+	tests := []struct {
+		order LinkOrder
+		want  string
+	}{
+		{OrderClicks, "[mail wiki cal docs]"},
+		{OrderRecent, "[cal wiki mail docs]"},
+		{OrderAlpha, "[cal docs mail wiki]"},
+		{OrderPinned, "[docs wiki cal mail]"},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := s.EachLinkBy(ctx, tt.order, func(link Link) error {
+			got = append(got, link.Slug)
+			return nil
+		})
+		if err != nil || fmt.Sprint(got) != tt.want {
+			t.Errorf("EachLinkBy(%s) = %v, %v; want %s", tt.order, got, err, tt.want)
+		}
+	}
+	if err := s.EachLinkBy(ctx, "random", func(Link) error { return nil }); err == nil {
+		t.Error("EachLinkBy with unknown order: expected error")
+	}
+
+	// Pruning drops old clicks but not the link totals; removing a link
+	// drops its clicks
+	if n, err := s.PruneClicks(ctx, base.Add(time.Minute)); err != nil || n != 2 {
+		t.Errorf("PruneClicks = %d, %v; want 2", n, err)
+	}
+	if err := s.RemoveLink(ctx, "cal"); err != nil {
+		t.Fatalf("RemoveLink: %v", err)
+	}
+	counts, err = s.ClickCounts(ctx, time.Time{})
+	if err != nil || fmt.Sprint(counts) != "map[mail:1 wiki:1]" {
+		t.Errorf("ClickCounts after pruning = %v, %v", counts, err)
+	}
+	if link, err := s.GetLink(ctx, "mail"); err != nil || link.Clicks != 2 || link.ListOpens != 1 {
+		t.Errorf("GetLink after pruning = %+v, %v", link, err)
+	}
+}
+
+// testCreateLink checks that CreateLink stores a link with its settings,
+// aliases and collections all at once, or nothing, on an empty store.
+func testCreateLink(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameLink(ctx, "wiki", "kb", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCollection(ctx, Collection{Name: "onboarding", Slugs: []string{"kb"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	review := time.Date(2027, 1, 1, 9, 0, 0, 0, time.UTC)
+	link := Link{
+		Slug: "docs", URL: "https://docs.example.com", CreatedBy: "alice", Public: true,
+		ReviewAt: &review, ReviewMonths: 6, Access: []AccessRule{{Group: "kids", From: "15:00", To: "21:00"}},
+		Pin: 2, HitBudget: 100, Failover: "https://mirror.example.com", Referrer: ReferrerStrip,
+		Fields: map[string]string{"room": "B12"}, Clicks: 7, ListOpens: 3, ApprovedBy: "mallory", // ignored
+	}
+	for _, tc := range []struct {
+		name                 string
+		aliases, collections []string
+		want                 error
+	}{
+		{"alias is a link", []string{"handbook", "kb"}, nil, ErrConflict},
+		{"alias is an alias", []string{"wiki"}, nil, ErrConflict},
+		{"alias is the slug", []string{"docs"}, nil, ErrConflict},
+		{"alias twice", []string{"manual", "manual"}, nil, ErrConflict},
+		{"missing collection", []string{"handbook"}, []string{"onboarding", "missing"}, ErrNotFound},
+	} {
+		if err := s.CreateLink(ctx, link, tc.aliases, tc.collections); !errors.Is(err, tc.want) {
+			t.Errorf("CreateLink with %s = %v, want %v", tc.name, err, tc.want)
+		}
+		if _, err := s.GetLink(ctx, "docs"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CreateLink with %s left the link behind: %v", tc.name, err)
+		}
+		if _, err := s.ResolveAlias(ctx, "handbook"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CreateLink with %s left an alias behind: %v", tc.name, err)
+		}
+	}
+	if c, err := s.GetCollection(ctx, "onboarding"); err != nil || fmt.Sprint(c.Slugs) != "[kb]" {
+		t.Fatalf("collection after failed creates = %+v, %v", c, err)
+	}
+
+	if err := s.CreateLink(ctx, link, []string{"handbook", "manual"}, []string{"onboarding"}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+	got, err := s.GetLink(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusActive || !got.Public || got.ReviewAt == nil || !got.ReviewAt.Equal(review) || got.ReviewMonths != 6 ||
+		len(got.Access) != 1 || got.Pin != 2 || got.HitBudget != 100 || got.Failover != link.Failover || got.Referrer != ReferrerStrip ||
+		got.Fields["room"] != "B12" || got.Clicks != 0 || got.ListOpens != 0 || got.ApprovedBy != "" || got.CreatedAt.IsZero() {
+		t.Errorf("created link = %+v", got)
+	}
+	for _, alias := range []string{"handbook", "manual"} {
+		if target, err := s.ResolveAlias(ctx, alias); err != nil || target != "docs" {
+			t.Errorf("ResolveAlias(%s) = %q, %v", alias, target, err)
+		}
+	}
+	if c, err := s.GetCollection(ctx, "onboarding"); err != nil || fmt.Sprint(c.Slugs) != "[kb docs]" {
+		t.Errorf("collection = %+v, %v", c, err)
+	}
+	if err := s.CreateLink(ctx, link, nil, nil); !errors.Is(err, ErrConflict) {
+		t.Errorf("CreateLink of a taken slug = %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	s := NewMemory()
+	defer s.Close()
+	testStore(t, s)
+	testLinkOrders(t, NewMemory())
+	testCreateLink(t, NewMemory())
+}
+
+func TestMemoryCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := NewMemory()
+	if _, err := s.GetLink(ctx, "wiki"); err != context.Canceled {
+		t.Errorf("GetLink with canceled context = %v, want context.Canceled", err)
+	}
+	if err := s.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"}); err != context.Canceled {
+		t.Errorf("AddLink with canceled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "data", "links.db"), SQLiteOptions{QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+	testStore(t, s)
+
+	s, err = OpenSQLite(filepath.Join(t.TempDir(), "orders.db"), SQLiteOptions{QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+	testLinkOrders(t, s)
+
+	s, err = OpenSQLite(filepath.Join(t.TempDir(), "create.db"), SQLiteOptions{QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+	testCreateLink(t, s)
+}
+
+func TestSQLiteReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.db")
+
+	s, err := OpenSQLite(path, SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	if err := s.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"}); err != nil {
+		t.Fatalf("AddLink: %v", err)
+	}
+	s.Close()
+
+	// Reopening runs the schema upgrade again against existing columns
+	s, err = OpenSQLite(path, SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite reopen: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.GetLink(ctx, "wiki"); err != nil {
+		t.Errorf("GetLink after reopen: %v", err)
+	}
+}
+
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "links.db"), SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+	var mode string
+	if err := s.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q, %v", mode, err)
+	}
+
+	// Admin writes, click batches and redirects at once, each on its own
+	// connection
+	s.AddLink(ctx, Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	var wg sync.WaitGroup
+	errs := make(chan error, 300)
+	for w := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 10 {
+				errs <- s.CreateLink(ctx, Link{Slug: fmt.Sprintf("w%d-%d", w, i), URL: "https://example.com"}, nil, nil)
+				errs <- s.RecordClicks(ctx, []Click{{Slug: "wiki", At: time.Now()}})
+				_, err := s.GetLink(ctx, "wiki")
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent write: %v", err)
+		}
+	}
+	if link, _ := s.GetLink(ctx, "wiki"); link.Clicks != 100 {
+		t.Errorf("clicks = %d, want 100", link.Clicks)
+	}
+}
+
+func TestSQLiteOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+	s, err := OpenSQLite(path, SQLiteOptions{JournalMode: "delete", Synchronous: "full", BusyTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+	var mode string
+	var synchronous, busy int
+	s.db.QueryRow("PRAGMA journal_mode").Scan(&mode)
+	s.db.QueryRow("PRAGMA synchronous").Scan(&synchronous)
+	s.db.QueryRow("PRAGMA busy_timeout").Scan(&busy)
+	if mode != "delete" || synchronous != 2 || busy != 1000 {
+		t.Errorf("journal_mode %q, synchronous %d, busy_timeout %d", mode, synchronous, busy)
+	}
+	if open := s.db.Stats().MaxOpenConnections; open < 4 {
+		t.Errorf("MaxOpenConnections = %d, want the default of at least 4", open)
+	}
+
+	pooled, err := OpenSQLite(path, SQLiteOptions{MaxOpenConns: 3, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer pooled.Close()
+	if open := pooled.db.Stats().MaxOpenConnections; open != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", open)
+	}
+
+	for _, opts := range []SQLiteOptions{{JournalMode: "memory; DROP TABLE links"}, {Synchronous: "sometimes"}, {BusyTimeout: -time.Second}, {MaxIdleConns: -1}} {
+		if _, err := OpenSQLite(path, opts); err == nil {
+			t.Errorf("OpenSQLite with %+v succeeded", opts)
+		}
+	}
+}
+
+func TestSQLiteBackup(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "links.db"), SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+	for i := range 500 {
+		if err := s.AddLink(ctx, Link{Slug: fmt.Sprintf("link-%d", i), URL: "https://example.com/" + strings.Repeat("x", 500)}); err != nil {
+			t.Fatalf("AddLink: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.Backup(ctx, &buf); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "restored.db")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := OpenSQLite(path, SQLiteOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQLite of the backup: %v", err)
+	}
+	defer restored.Close()
+	if n, err := restored.CountLinks(ctx); err != nil || n != 500 {
+		t.Errorf("backup has %d links (%v), want 500", n, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := s.Backup(canceled, io.Discard); err == nil {
+		t.Error("Backup with a canceled context succeeded")
+	}
+}
+
+func TestSQLiteEachLinkPages(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "links.db"), SQLiteOptions{QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+
+	// All rows share the same created_at second, so paging relies on the
+	// slug tiebreak.
+	n := eachLinkBatch*2 + 7
+	for i := 0; i < n; i++ {
+		if err := s.AddLink(ctx, Link{Slug: fmt.Sprintf("link%04d", i), URL: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every order pages through all links; most clicked first means
+	// decreasing click counts, ties by increasing slug
+	var clicks []Click
+	for i := 0; i < n; i += 3 {
+		clicks = append(clicks, Click{Slug: fmt.Sprintf("link%04d", i), At: time.Now()})
+	}
+	if err := s.RecordClicks(ctx, clicks); err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range LinkOrders {
+		seen := 0
+		var prev Link
+		err := s.EachLinkBy(ctx, order, func(link Link) error {
+			if order == OrderClicks && seen > 0 && (link.Clicks > prev.Clicks || link.Clicks == prev.Clicks && link.Slug <= prev.Slug) {
+				t.Errorf("link %s streamed after %s", link.Slug, prev.Slug)
+			}
+			prev = link
+			seen++
+			return nil
+		})
+		if err != nil || seen != n {
+			t.Errorf("EachLinkBy(%s) streamed %d links, %v; want %d", order, seen, err, n)
+		}
+	}
+
+	// Slugs increase with insertion time, so newest-first order means
+	// strictly decreasing slugs
+	seen := 0
+	prev := ""
+	err = s.EachLink(ctx, func(link Link) error {
+		if prev != "" && link.Slug >= prev {
+			t.Errorf("link %s streamed after %s", link.Slug, prev)
+		}
+		prev = link.Slug
+		seen++
+		// Writes must succeed while the consumer is busy with a page
+		return s.RemoveLink(ctx, link.Slug)
+	})
+	if err != nil {
+		t.Fatalf("EachLink: %v", err)
+	}
+	if seen != n {
+		t.Errorf("streamed %d links, want %d", seen, n)
+	}
+}
@@ -0,0 +1,29 @@
+// Package textwidth measures how wide text shows in a terminal, so the
+// tables of the SSH admin console and golinksctl line up around emoji
+// slugs.
+package textwidth
+
+// Width returns how many terminal columns s takes: emoji and East Asian
+// wide characters take two, joiners, variation selectors and combining
+// marks none.
+func Width(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case r == 0x200D || (r >= 0xFE00 && r <= 0xFE0F) || (r >= 0x0300 && r <= 0x036F) || (r >= 0x1F3FB && r <= 0x1F3FF):
+			// zero width
+		case r >= 0x1F000 && r <= 0x1FAFF,
+			r >= 0x2600 && r <= 0x27BF,
+			r >= 0x1100 && r <= 0x115F,
+			r >= 0x2E80 && r <= 0xA4CF,
+			r >= 0xAC00 && r <= 0xD7A3,
+			r >= 0xF900 && r <= 0xFAFF,
+			r >= 0xFF00 && r <= 0xFF60,
+			r >= 0xFFE0 && r <= 0xFFE6:
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
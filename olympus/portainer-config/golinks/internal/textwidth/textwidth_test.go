@@ -0,0 +1,21 @@
+package textwidth
+
+import "testing"
+
+func TestWidth(t *testing.T) {
+	tests := map[string]int{
+		"wiki":           4,
+		"go/🍕":           5,
+		"❤️":             2,
+		"👩‍💻":            4,
+		"日本":             4,
+		"café":           4,
+		"café":          4,
+		"👍\U0001F3FD ok": 5,
+	}
+	for in, want := range tests {
+		if got := Width(in); got != want {
+			t.Errorf("Width(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+
+	"golinks/internal/httpapi"
+)
+
+// ServeAddForm renders the quick-add page with status: a box to paste a
+// link into, and the slug and URL fields it fills in.
+func (h *Handler) ServeAddForm(w http.ResponseWriter, r *http.Request, form httpapi.AddForm, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := h.templates.ExecuteTemplate(w, "addform", form); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
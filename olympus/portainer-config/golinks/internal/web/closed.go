@@ -0,0 +1,40 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golinks/internal/httpapi"
+	"golinks/internal/store"
+)
+
+// ServeClosed renders the "not now" page for a link an access rule keeps
+// the client from opening, saying when it opens again. The destination is
+// left out so the page is no way around the rule.
+func (h *Handler) ServeClosed(w http.ResponseWriter, r *http.Request, link store.Link, opens time.Time) {
+	data := struct {
+		Slug          string
+		Opens         string
+		Impersonating *httpapi.Impersonation
+	}{Slug: link.Slug, Impersonating: impersonating(r)}
+	if !opens.IsZero() {
+		data.Opens = opens.Format("Monday 15:04")
+	}
+	if h.serveCustomError(w, ErrorPage{
+		Status:  http.StatusForbidden,
+		Title:   http.StatusText(http.StatusForbidden),
+		Message: "go/" + link.Slug + " isn't available right now.",
+		Slug:    link.Slug,
+		Opens:   data.Opens,
+		Contact: h.cfg.Contact,
+		Banner:  h.banner(),
+	}) {
+		return
+	}
+
+	writeErrorPage(w, http.StatusForbidden)
+	if err := h.templates.ExecuteTemplate(w, "closed", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// ServeCollection renders the page of the named collection: its title,
+// description and links in the collection's order. Links removed since
+// the collection was saved are skipped.
+func (h *Handler) ServeCollection(w http.ResponseWriter, r *http.Request, name string) {
+	c, err := h.store.GetCollection(r.Context(), name)
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading collection", "collection", name, "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	data := struct {
+		store.Collection
+		Links []store.Link
+	}{
+		Collection: *c,
+		Links:      make([]store.Link, 0, len(c.Slugs)),
+	}
+	for _, slug := range c.Slugs {
+		link, err := h.store.GetLink(r.Context(), slug)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading collection", "collection", name, "error", err)
+			httperr.Write(w, err)
+			return
+		}
+		data.Links = append(data.Links, *link)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, "collection", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golinks/internal/banner"
+	"golinks/internal/store"
+)
+
+// errorPageFiles are the templates a deployment may put in
+// Config.ErrorPages, by the status they are served with. 500.html is used
+// for every 5xx status.
+var errorPageFiles = map[int]string{
+	http.StatusNotFound:            "404.html",
+	http.StatusForbidden:           "403.html",
+	http.StatusInternalServerError: "500.html",
+}
+
+// ErrorPage is the data custom error page templates are executed with.
+type ErrorPage struct {
+	// Status is the HTTP status the page is served with, Title its reason
+	// phrase and Message what went wrong.
+	Status  int
+	Title   string
+	Message string
+	// Slug is the slug that was requested.
+	Slug string
+	// Suggestions are the slugs of active links similar to an unknown
+	// Slug, best first.
+	Suggestions []string
+	// Opens is when a link closed by an access rule opens again, such as
+	// "Friday 15:00", empty if it does not.
+	Opens string
+	// Contact is who to ask for help, as configured.
+	Contact string
+	// Banner is the current announcement, nil if there is none.
+	Banner *banner.Banner
+}
+
+// loadErrorPages parses the custom error pages in dir. Pages missing from
+// it keep the built-in ones.
+func loadErrorPages(dir string) (map[int]*template.Template, error) {
+	pages := make(map[int]*template.Template)
+	if dir == "" {
+		return pages, nil
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("error pages: %w", err)
+	}
+	for status, name := range errorPageFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		t, err := template.ParseFiles(path)
+		if err != nil {
+			return nil, fmt.Errorf("error pages: %w", err)
+		}
+		pages[status] = t
+	}
+	return pages, nil
+}
+
+// customErrorPage returns the custom page for status, or nil.
+func (h *Handler) customErrorPage(status int) *template.Template {
+	if status >= 500 {
+		status = http.StatusInternalServerError
+	}
+	return h.errorPages[status]
+}
+
+// writeErrorPage writes the headers of an error page with status.
+func writeErrorPage(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+}
+
+// serveCustomError renders page with the custom template of its status, if
+// there is one, and reports whether it did.
+func (h *Handler) serveCustomError(w http.ResponseWriter, page ErrorPage) bool {
+	t := h.customErrorPage(page.Status)
+	if t == nil {
+		return false
+	}
+	writeErrorPage(w, page.Status)
+	if err := t.Execute(w, page); err != nil {
+		slog.Error("Error page template execution error", "error", err)
+	}
+	return true
+}
+
+// ServeError renders the page of a redirect that failed with status, such
+// as a link pending approval or a database error.
+func (h *Handler) ServeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	page := ErrorPage{
+		Status:  status,
+		Title:   http.StatusText(status),
+		Message: message,
+		Slug:    strings.TrimPrefix(r.URL.Path, "/"),
+		Contact: h.cfg.Contact,
+		Banner:  h.banner(),
+	}
+	if h.serveCustomError(w, page) {
+		return
+	}
+	writeErrorPage(w, status)
+	if err := h.templates.ExecuteTemplate(w, "error", page); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
+
+// maxSuggestions is how many similar slugs a 404 page offers.
+const maxSuggestions = 5
+
+// suggestions returns up to maxSuggestions active slugs that contain slug
+// or are contained in it, those sharing its start first.
+func (h *Handler) suggestions(ctx context.Context, slug string) []string {
+	slug = strings.ToLower(slug)
+	if len(slug) < 2 {
+		return nil
+	}
+	var prefixed, others []string
+	err := h.store.EachLink(ctx, func(link store.Link) error {
+		if link.Status != store.StatusActive {
+			return nil
+		}
+		candidate := strings.ToLower(link.Slug)
+		switch {
+		case len(candidate) < 2:
+		case strings.HasPrefix(candidate, slug) || strings.HasPrefix(slug, candidate):
+			prefixed = append(prefixed, link.Slug)
+		case strings.Contains(candidate, slug) || strings.Contains(slug, candidate):
+			others = append(others, link.Slug)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error looking for similar slugs", "slug", slug, "error", err)
+		return nil
+	}
+	all := append(prefixed, others...)
+	return all[:min(len(all), maxSuggestions)]
+}
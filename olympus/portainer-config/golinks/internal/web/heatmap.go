@@ -0,0 +1,103 @@
+package web
+
+import (
+	"context"
+	"time"
+)
+
+// heatmapWeeks is how many weeks the click heatmap covers, ending with the
+// current one.
+const heatmapWeeks = 53
+
+// heatmapCell is the size of one day of the heatmap in SVG units, gap
+// included; heatmapLeft and heatmapTop leave room for the labels.
+const (
+	heatmapCell = 13
+	heatmapLeft = 28
+	heatmapTop  = 15
+)
+
+// heatmapFills are the colours of the heatmap, from no clicks to the
+// busiest days.
+var heatmapFills = []string{"#ebedf0", "#c5cbf6", "#9da8ee", "#7584e3", "#5a4fcf"}
+
+// heatmap is a calendar of clicks per day, one column per week.
+type heatmap struct {
+	From   string         `json:"from"`
+	To     string         `json:"to"`
+	Total  int            `json:"total"`
+	Days   map[string]int `json:"days"`
+	Cells  []heatmapDay   `json:"-"`
+	Months []heatmapLabel `json:"-"`
+	Width  int            `json:"-"`
+	Height int            `json:"-"`
+	Legend []string       `json:"-"`
+}
+
+// heatmapDay is one day of the heatmap and where it is drawn.
+type heatmapDay struct {
+	Date   time.Time
+	Clicks int
+	Fill   string
+	X, Y   int
+}
+
+// heatmapLabel is a month name drawn above the week at X.
+type heatmapLabel struct {
+	X    int
+	Name string
+}
+
+// clickHeatmap builds the heatmap of slug, or of every link when slug is
+// empty, for the weeks up to now in the server's time zone.
+func (h *Handler) clickHeatmap(ctx context.Context, slug string, now time.Time) (heatmap, error) {
+	now = now.Local()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	// Columns start on Sunday, like a wall calendar
+	first := today.AddDate(0, 0, -int(today.Weekday())-7*(heatmapWeeks-1))
+	days, err := h.store.ClickDays(ctx, slug, first, time.Local)
+	if err != nil {
+		return heatmap{}, err
+	}
+
+	hm := heatmap{
+		From:   first.Format(time.DateOnly),
+		To:     today.Format(time.DateOnly),
+		Days:   days,
+		Width:  heatmapLeft + heatmapWeeks*heatmapCell,
+		Height: heatmapTop + 7*heatmapCell,
+	}
+	most := 0
+	for _, n := range days {
+		hm.Total += n
+		most = max(most, n)
+	}
+	for week := 0; week < heatmapWeeks; week++ {
+		x := heatmapLeft + week*heatmapCell
+		for wd := 0; wd < 7; wd++ {
+			day := first.AddDate(0, 0, 7*week+wd)
+			if day.After(today) {
+				break
+			}
+			labelled := len(hm.Months) > 0 && hm.Months[len(hm.Months)-1].X == x
+			if !labelled && (day.Day() == 1 || (week == 0 && wd == 0 && day.Day() <= 14)) {
+				hm.Months = append(hm.Months, heatmapLabel{X: x, Name: day.Format("Jan")})
+			}
+			n := days[day.Format(time.DateOnly)]
+			level := 0
+			if n > 0 {
+				// Spread the clicks over the colours relative to the busiest day
+				level = (n*(len(heatmapFills)-1) + most - 1) / most
+			}
+			hm.Cells = append(hm.Cells, heatmapDay{
+				Date:   day,
+				Clicks: n,
+				Fill:   heatmapFills[level],
+				X:      x,
+				Y:      heatmapTop + wd*heatmapCell,
+			})
+		}
+	}
+	hm.Legend = heatmapFills
+	return hm, nil
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golinks/internal/health"
+	"golinks/internal/httpapi"
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// infoDays is the window of the recent clicks on the info page.
+const infoDays = 30
+
+// ServeInfo renders the info page of link, served at go/slug+: where it
+// points, who added it, how much it is used over the last year and a QR code
+// for it.
+func (h *Handler) ServeInfo(w http.ResponseWriter, r *http.Request, link store.Link) {
+	times, err := h.store.ClickTimes(r.Context(), link.Slug, time.Now().AddDate(0, 0, -infoDays))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching clicks", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	data := struct {
+		Link     store.Link
+		Days     int
+		Recent   int
+		QR       posterCode
+		Snapshot bool
+		// Health is the latest check of the destination, Unknown without
+		// a checker.
+		Health      health.Result
+		FailingOver bool
+		Heatmap     heatmap
+		// Fields are the custom fields, the unset ones with no value.
+		Fields []httpapi.FieldValue
+	}{Link: link, Days: infoDays, Recent: len(times), Fields: httpapi.FieldValues(h.cfg.LinkFields, link.Fields)}
+	if data.Heatmap, err = h.clickHeatmap(r.Context(), link.Slug, time.Now()); err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching clicks", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+	if data.QR, err = newPosterCode(requestBase(r), link.Slug); err != nil {
+		slog.WarnContext(r.Context(), "No QR code", "slug", link.Slug, "error", err)
+	}
+	if h.cfg.Snapshots != nil {
+		_, err := h.cfg.Snapshots.Get(link.URL)
+		data.Snapshot = err == nil
+	}
+	if h.cfg.Health != nil {
+		data.Health = h.cfg.Health.Result(link)
+		data.FailingOver = h.cfg.Health.FailoverActive(link)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, "info", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golinks/internal/banner"
+	"golinks/internal/httpapi"
+)
+
+// ServeNotFound renders the 404 page for an unknown slug, with the banner
+// so visitors following links broken by an announced change learn why, and
+// the slugs they may have meant.
+func (h *Handler) ServeNotFound(w http.ResponseWriter, r *http.Request) {
+	slug := strings.TrimPrefix(r.URL.Path, "/")
+	suggestions := h.suggestions(r.Context(), slug)
+	if h.serveCustomError(w, ErrorPage{
+		Status:      http.StatusNotFound,
+		Title:       http.StatusText(http.StatusNotFound),
+		Message:     "There is no link go/" + slug + ".",
+		Slug:        slug,
+		Suggestions: suggestions,
+		Contact:     h.cfg.Contact,
+		Banner:      h.banner(),
+	}) {
+		return
+	}
+
+	data := struct {
+		Slug          string
+		Suggestions   []string
+		Contact       string
+		Banner        *banner.Banner
+		Impersonating *httpapi.Impersonation
+	}{Slug: slug, Suggestions: suggestions, Contact: h.cfg.Contact, Banner: h.banner(), Impersonating: impersonating(r)}
+
+	writeErrorPage(w, http.StatusNotFound)
+	if err := h.templates.ExecuteTemplate(w, "notfound", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golinks/internal/store"
+)
+
+// A visitor's starred and recently used links are kept in cookies, so the
+// index can show them without accounts.
+const (
+	starsCookie  = "golinks_stars"
+	recentCookie = "golinks_recent"
+	maxStars     = 20
+	maxRecent    = 8
+	// maxSlugsCookie keeps a slug list cookie well below the 4 KB
+	// browsers store.
+	maxSlugsCookie = 3000
+)
+
+// readSlugs returns the slugs in the cookie name, most recent first.
+func readSlugs(r *http.Request, name string) []string {
+	c, err := r.Cookie(name)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	var slugs []string
+	for _, part := range strings.Split(c.Value, "|") {
+		if slug, err := url.QueryUnescape(part); err == nil && slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+// writeSlugs stores slugs in the cookie name, dropping the oldest ones if
+// they don't fit.
+func writeSlugs(w http.ResponseWriter, name string, slugs []string) {
+	parts := make([]string, 0, len(slugs))
+	size := 0
+	for _, slug := range slugs {
+		part := url.QueryEscape(slug)
+		if size += len(part) + 1; size > maxSlugsCookie {
+			break
+		}
+		parts = append(parts, part)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    strings.Join(parts, "|"),
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// pushSlug moves slug to the front of slugs, keeping at most limit.
+func pushSlug(slugs []string, slug string, limit int) []string {
+	slugs = slices.DeleteFunc(slugs, func(s string) bool { return s == slug })
+	slugs = append([]string{slug}, slugs...)
+	return slugs[:min(len(slugs), limit)]
+}
+
+// RememberVisit adds slug to the visitor's recently used links. It is
+// called on every redirect.
+func (h *Handler) RememberVisit(w http.ResponseWriter, r *http.Request, slug string) {
+	writeSlugs(w, recentCookie, pushSlug(readSlugs(r, recentCookie), slug, maxRecent))
+}
+
+// toggleStar stars the link of ?star= or unstars the one of ?unstar= for
+// the visitor and redirects back to the index. It reports whether it did.
+func (h *Handler) toggleStar(w http.ResponseWriter, r *http.Request) bool {
+	query := r.URL.Query()
+	stars := readSlugs(r, starsCookie)
+	switch {
+	case query.Get("star") != "":
+		stars = pushSlug(stars, query.Get("star"), maxStars)
+	case query.Get("unstar") != "":
+		stars = slices.DeleteFunc(stars, func(s string) bool { return s == query.Get("unstar") })
+	default:
+		return false
+	}
+	writeSlugs(w, starsCookie, stars)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return true
+}
+
+// personalLinks returns the visitor's starred links and the recently used
+// ones that are not starred. Removed links are skipped.
+func (h *Handler) personalLinks(ctx context.Context, r *http.Request) (starred, recent []store.Link, err error) {
+	stars := readSlugs(r, starsCookie)
+	lookup := func(slugs []string) ([]store.Link, error) {
+		var links []store.Link
+		for _, slug := range slugs {
+			link, err := h.store.GetLink(ctx, slug)
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			links = append(links, *link)
+		}
+		return links, nil
+	}
+	if starred, err = lookup(stars); err != nil {
+		return nil, nil, err
+	}
+	recentSlugs := slices.DeleteFunc(readSlugs(r, recentCookie), func(s string) bool { return slices.Contains(stars, s) })
+	if recent, err = lookup(recentSlugs); err != nil {
+		return nil, nil, err
+	}
+	return starred, recent, nil
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// posterLimit caps the number of codes on one poster; narrow larger sets
+// down with a prefix.
+const posterLimit = 120
+
+// posterCode is one QR code on the poster.
+type posterCode struct {
+	Slug string
+	URL  string
+	// Size is the width of the code in modules, including the quiet zone.
+	Size int
+	// Path draws the dark modules as an SVG path in module units.
+	Path string
+}
+
+// ServePoster renders a printable sheet of QR codes for the active links
+// whose slug starts with the "prefix" query parameter. Codes encode the go
+// link itself, built from "base" (default: the scheme and host of the
+// request), so a reprint is never needed when a destination changes.
+func (h *Handler) ServePoster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	base := strings.TrimSuffix(r.URL.Query().Get("base"), "/")
+	if base == "" {
+		base = requestBase(r)
+	} else if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "Invalid base - must be an http:// or https:// URL", http.StatusBadRequest)
+		return
+	}
+
+	data := struct {
+		Prefix    string
+		Base      string
+		Codes     []posterCode
+		Truncated bool
+	}{Prefix: prefix, Base: base}
+
+	err := h.store.EachLink(r.Context(), func(link store.Link) error {
+		if link.Status != store.StatusActive || !strings.HasPrefix(link.Slug, prefix) {
+			return nil
+		}
+		if len(data.Codes) == posterLimit {
+			data.Truncated = true
+			return errStop
+		}
+		code, err := newPosterCode(base, link.Slug)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Skipping link on poster", "slug", link.Slug, "error", err)
+			return nil
+		}
+		data.Codes = append(data.Codes, code)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		slog.ErrorContext(r.Context(), "Error listing links for poster", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, "poster", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
+
+// errStop ends an EachLink walk early.
+var errStop = errors.New("stop")
+
+func newPosterCode(base, slug string) (posterCode, error) {
+	target := base + (&url.URL{Path: "/" + slug}).EscapedPath()
+	qr, err := qrcode.New(target, qrcode.Medium)
+	if err != nil {
+		return posterCode{}, err
+	}
+
+	bitmap := qr.Bitmap()
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	return posterCode{Slug: slug, URL: target, Size: len(bitmap), Path: path.String()}, nil
+}
+
+// requestBase returns the scheme and host the request was made to,
+// honouring X-Forwarded-Proto from a TLS-terminating reverse proxy.
+func requestBase(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
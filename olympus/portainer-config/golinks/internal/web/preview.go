@@ -0,0 +1,37 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golinks/internal/store"
+)
+
+// ServePreview renders an OpenGraph/Twitter card page describing link, so a
+// go link pasted into chat unfurls with its slug and destination domain. The
+// page also refreshes to the destination in case a person ends up on it.
+func (h *Handler) ServePreview(w http.ResponseWriter, r *http.Request, link store.Link) {
+	domain := link.URL
+	if u, err := url.Parse(link.URL); err == nil {
+		domain = strings.TrimPrefix(u.Hostname(), "www.")
+	}
+
+	data := struct {
+		Slug   string
+		URL    string
+		Domain string
+		Self   string
+	}{
+		Slug:   link.Slug,
+		URL:    link.URL,
+		Domain: domain,
+		Self:   requestBase(r) + (&url.URL{Path: "/" + link.Slug}).EscapedPath(),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, "preview", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
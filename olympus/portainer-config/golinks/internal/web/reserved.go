@@ -0,0 +1,26 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+
+	"golinks/internal/store"
+)
+
+// ServeReserved renders the "coming soon" page of a reserved link. It is
+// served with 404 and not cached, so the link works as soon as it is
+// claimed.
+func (h *Handler) ServeReserved(w http.ResponseWriter, r *http.Request, link store.Link) {
+	data := struct {
+		Slug       string
+		ReservedBy string
+		Since      string
+	}{Slug: link.Slug, ReservedBy: link.CreatedBy, Since: link.CreatedAt.Format("Jan 02, 2006")}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNotFound)
+	if err := h.templates.ExecuteTemplate(w, "reserved", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
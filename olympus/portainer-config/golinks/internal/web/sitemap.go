@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"golinks/internal/httperr"
+	"golinks/internal/store"
+)
+
+// sitemapLimit is the most URLs one sitemap file may hold.
+const sitemapLimit = 50000
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type urlset struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// ServeSitemap lists the active links marked public as a sitemaps.org
+// sitemap. Private links never appear in it.
+func (h *Handler) ServeSitemap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	base := requestBase(r)
+	set := urlset{URLs: []sitemapURL{}}
+	err := h.store.EachLink(r.Context(), func(link store.Link) error {
+		if !link.Public || link.Status != store.StatusActive {
+			return nil
+		}
+		if len(set.URLs) == sitemapLimit {
+			return errStop
+		}
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     base + (&url.URL{Path: "/" + link.Slug}).EscapedPath(),
+			LastMod: link.CreatedAt.UTC().Format("2006-01-02"),
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		slog.ErrorContext(r.Context(), "Error listing links for sitemap", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(set); err != nil {
+		slog.ErrorContext(r.Context(), "Sitemap encoding error", "error", err)
+	}
+}
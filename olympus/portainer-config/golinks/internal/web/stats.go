@@ -0,0 +1,90 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golinks/internal/httperr"
+	"golinks/internal/report"
+)
+
+// statsWindows are the periods the stats page shows top links for, in days.
+var statsWindows = []int{7, 30}
+
+// MissLog lists the latest requests for unknown slugs.
+type MissLog interface {
+	RecentMisses() []report.Miss
+}
+
+// statsWindow is the top links of one period.
+type statsWindow struct {
+	Days      int            `json:"days"`
+	Redirects int            `json:"redirects"`
+	Top       []report.Count `json:"top"`
+}
+
+// StatsPage returns a handler for the usage dashboard, as HTML or as JSON
+// with ?format=json: total redirects and how many were opened from the list
+// page, the most used links of the last 7
+// and 30 days, the clicks per day of the last year and the latest requests
+// for unknown slugs.
+func (h *Handler) StatsPage(misses MissLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data := struct {
+			Links     int           `json:"links"`
+			Redirects int           `json:"redirects"`
+			ListOpens int           `json:"list_opens"`
+			Windows   []statsWindow `json:"windows"`
+			Heatmap   heatmap       `json:"heatmap"`
+			Misses    []report.Miss `json:"recent_misses"`
+		}{
+			Misses: misses.RecentMisses(),
+		}
+		links, err := h.store.ListLinks(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error fetching links", "error", err)
+			httperr.Write(w, err)
+			return
+		}
+		data.Links = len(links)
+		for _, link := range links {
+			data.Redirects += link.Clicks
+			data.ListOpens += link.ListOpens
+		}
+		for _, days := range statsWindows {
+			counts, err := h.store.ClickCounts(r.Context(), time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error counting clicks", "error", err)
+				httperr.Write(w, err)
+				return
+			}
+			win := statsWindow{Days: days, Top: report.Top(counts)}
+			for _, n := range counts {
+				win.Redirects += n
+			}
+			data.Windows = append(data.Windows, win)
+		}
+		if data.Heatmap, err = h.clickHeatmap(r.Context(), "", time.Now()); err != nil {
+			slog.ErrorContext(r.Context(), "Error counting clicks", "error", err)
+			httperr.Write(w, err)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(data)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.templates.ExecuteTemplate(w, "stats", data); err != nil {
+			slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+		}
+	})
+}
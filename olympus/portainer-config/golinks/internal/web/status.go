@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"golinks/internal/health"
+	"golinks/internal/httperr"
+)
+
+// statusOrder sorts the detailed status list: problems first.
+var statusOrder = map[health.Status]int{health.Broken: 0, health.Unknown: 1, health.Healthy: 2}
+
+// StatusPage returns a handler for the link health status page, as HTML or
+// as JSON with ?format=json. The summary counts only, safe to show anyone,
+// unless detail is set: then every active link is listed with its latest
+// result, broken ones first.
+func (h *Handler) StatusPage(checker *health.Checker, detail bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		sum, results, err := checker.Results(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing link health", "error", err)
+			httperr.Write(w, err)
+			return
+		}
+		data := struct {
+			health.Summary
+			Links  []health.Result `json:"links,omitempty"`
+			Detail bool            `json:"-"`
+		}{
+			Summary: sum,
+			Detail:  detail,
+		}
+		if detail {
+			sort.SliceStable(results, func(i, j int) bool {
+				if results[i].Status != results[j].Status {
+					return statusOrder[results[i].Status] < statusOrder[results[j].Status]
+				}
+				return results[i].Slug < results[j].Slug
+			})
+			data.Links = results
+		}
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(data)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := h.templates.ExecuteTemplate(w, "status", data); err != nil {
+			slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+// Package web renders the HTML pages of the golinks UI.
+package web
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golinks/internal/banner"
+	"golinks/internal/health"
+	"golinks/internal/httpapi"
+	"golinks/internal/httperr"
+	"golinks/internal/snapshot"
+	"golinks/internal/store"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// Config holds the settings of the HTML pages.
+type Config struct {
+	// Order is the index order for visitors without a preference cookie;
+	// empty means store.OrderNewest.
+	Order store.LinkOrder
+	// Snapshots, if set, has the copies of destinations the list links
+	// to; Health, if set, tells which destinations are broken.
+	Snapshots *snapshot.Archiver
+	Health    *health.Checker
+	// Banner, if set, holds the announcement shown at the top of the list
+	// and 404 pages.
+	Banner *banner.Board
+	// MirrorOf, if set, is the instance this one mirrors; the list points
+	// there instead of offering to add links.
+	MirrorOf string
+	// ErrorPages, if set, is a directory of templates replacing the
+	// built-in error pages: 404.html, 403.html and 500.html, each executed
+	// with an ErrorPage.
+	ErrorPages string
+	// Contact, if set, is who error pages tell visitors to ask for help,
+	// such as "it@example.com".
+	Contact string
+	// LinkFields are the custom fields info pages show.
+	LinkFields []httpapi.LinkField
+}
+
+// Handler serves the link listing page.
+type Handler struct {
+	cfg       Config
+	store     store.Store
+	templates *template.Template
+	// errorPages are the custom error pages by status
+	errorPages map[int]*template.Template
+}
+
+// New parses the embedded templates and returns a Handler rendering them.
+func New(cfg Config, st store.Store) (*Handler, error) {
+	if cfg.Order == "" {
+		cfg.Order = store.OrderNewest
+	}
+	if !cfg.Order.Valid() {
+		return nil, fmt.Errorf("unknown index order %q", cfg.Order)
+	}
+	templates, err := parseTemplates(templateFS)
+	if err != nil {
+		return nil, err
+	}
+	errorPages, err := loadErrorPages(cfg.ErrorPages)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{cfg: cfg, store: st, templates: templates, errorPages: errorPages}, nil
+}
+
+func parseTemplates(fsys fs.FS) (*template.Template, error) {
+	t, err := template.ParseFS(fsys, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	return t, nil
+}
+
+// flushEvery is how many list rows are written between flushes.
+const flushEvery = 100
+
+// listItem is one row of the list page.
+type listItem struct {
+	store.Link
+	// Snapshot is set if a copy of the destination can be offered, Broken
+	// if the destination failed its last health check and FailingOver if
+	// redirects therefore go to the link's failover destination.
+	Snapshot    bool
+	Broken      bool
+	FailingOver bool
+}
+
+// item returns the list row of link.
+func (h *Handler) item(link store.Link) listItem {
+	it := listItem{Link: link}
+	if h.cfg.Snapshots != nil {
+		_, err := h.cfg.Snapshots.Get(link.URL)
+		it.Snapshot = err == nil
+	}
+	if h.cfg.Health != nil {
+		it.Broken = h.cfg.Health.Result(link).Status == health.Broken
+		it.FailingOver = h.cfg.Health.FailoverActive(link)
+	}
+	return it
+}
+
+// banner returns the announcement to show now, or nil.
+func (h *Handler) banner() *banner.Banner {
+	if h.cfg.Banner == nil {
+		return nil
+	}
+	return h.cfg.Banner.Current(time.Now())
+}
+
+// impersonating returns the impersonation r is served under, if any.
+func impersonating(r *http.Request) *httpapi.Impersonation {
+	if imp, ok := httpapi.ImpersonationFrom(r.Context()); ok {
+		return &imp
+	}
+	return nil
+}
+
+// orderCookie remembers the index order a visitor picked with ?order=.
+const orderCookie = "golinks_order"
+
+// orderLabels name the index orders in the sort selector.
+var orderLabels = map[store.LinkOrder]string{
+	store.OrderNewest: "Newest",
+	store.OrderClicks: "Most used",
+	store.OrderRecent: "Recently used",
+	store.OrderAlpha:  "A–Z",
+	store.OrderPinned: "Pinned",
+}
+
+// sortOption is one entry of the sort selector.
+type sortOption struct {
+	Order    store.LinkOrder
+	Label    string
+	Selected bool
+}
+
+// indexOrder returns the order to list links in: a valid ?order= parameter,
+// which is also remembered in a cookie, else the cookie, else the server
+// default.
+func (h *Handler) indexOrder(w http.ResponseWriter, r *http.Request) store.LinkOrder {
+	if order := store.LinkOrder(r.URL.Query().Get("order")); order.Valid() {
+		http.SetCookie(w, &http.Cookie{
+			Name:     orderCookie,
+			Value:    string(order),
+			Path:     "/",
+			MaxAge:   365 * 24 * 60 * 60,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return order
+	}
+	if c, err := r.Cookie(orderCookie); err == nil && store.LinkOrder(c.Value).Valid() {
+		return store.LinkOrder(c.Value)
+	}
+	return h.cfg.Order
+}
+
+// ServeHTTP streams the list page so memory use stays flat however many
+// links there are. Once the header is sent, errors can only be logged.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.toggleStar(w, r) {
+		return
+	}
+
+	count, err := h.store.CountLinks(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting links", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	collections, err := h.store.ListCollections(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing collections", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	starred, recent, err := h.personalLinks(r.Context(), r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error looking up starred and recent links", "error", err)
+		httperr.Write(w, err)
+		return
+	}
+
+	order := h.indexOrder(w, r)
+	sortOptions := make([]sortOption, len(store.LinkOrders))
+	for i, o := range store.LinkOrders {
+		sortOptions[i] = sortOption{Order: o, Label: orderLabels[o], Selected: o == order}
+	}
+
+	// Count feeds the header badge only. Whether the list is opened and
+	// closed depends on the rows actually streamed, since links can be
+	// added or removed between the two queries.
+	data := struct {
+		Count       int
+		Rows        int
+		Collections []store.Collection
+		Sort        []sortOption
+		Starred     []store.Link
+		Recent      []store.Link
+		Banner      *banner.Banner
+		MirrorOf    string
+		// Impersonating is set while an admin views the list as someone
+		// else
+		Impersonating *httpapi.Impersonation
+	}{
+		Banner:        h.banner(),
+		MirrorOf:      h.cfg.MirrorOf,
+		Impersonating: impersonating(r),
+		Count:         count,
+		Collections:   collections,
+		Sort:          sortOptions,
+		Starred:       starred,
+		Recent:        recent,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, "list_header", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	err = h.store.EachLinkBy(r.Context(), order, func(link store.Link) error {
+		if data.Rows == 0 {
+			if err := h.templates.ExecuteTemplate(w, "list_open", nil); err != nil {
+				return err
+			}
+		}
+		if err := h.templates.ExecuteTemplate(w, "list_item", h.item(link)); err != nil {
+			return err
+		}
+		if data.Rows++; data.Rows%flushEvery == 0 && flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error streaming links", "error", err)
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, "list_footer", data); err != nil {
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
+	}
+}
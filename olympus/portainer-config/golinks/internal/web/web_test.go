@@ -0,0 +1,835 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"golinks/internal/banner"
+	"golinks/internal/health"
+	"golinks/internal/httpapi"
+	"golinks/internal/report"
+	"golinks/internal/snapshot"
+	"golinks/internal/store"
+)
+
+func newHandler(t *testing.T, st store.Store) *Handler {
+	t.Helper()
+	h, err := New(Config{}, st)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h
+}
+
+func TestListLinks(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending})
+
+	rec := httptest.NewRecorder()
+	newHandler(t, st).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"go/wiki", "https://wiki.example.com", "go/pay", "pending approval", `<span class="count">2</span>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q", want)
+		}
+	}
+}
+
+func TestListLinksEmpty(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newHandler(t, store.NewMemory()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !strings.Contains(rec.Body.String(), "No links yet") {
+		t.Error("empty list page missing placeholder text")
+	}
+}
+
+func TestListLinksStreams(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for i := 0; i < flushEvery+1; i++ {
+		st.AddLink(ctx, store.Link{Slug: fmt.Sprintf("link%d", i), URL: "https://example.com"})
+	}
+
+	rec := httptest.NewRecorder()
+	newHandler(t, st).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !rec.Flushed {
+		t.Error("large list was not flushed while streaming")
+	}
+	body := rec.Body.String()
+	if n := strings.Count(body, `class="link-item"`); n != flushEvery+1 {
+		t.Errorf("rendered %d items, want %d", n, flushEvery+1)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(body), "</html>") {
+		t.Error("page footer missing")
+	}
+}
+
+// staleCountStore reports no links even though one exists, as if a link was
+// added between the count and the listing.
+type staleCountStore struct {
+	*store.Memory
+}
+
+func (staleCountStore) CountLinks(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func TestListLinksStaleCount(t *testing.T) {
+	st := store.NewMemory()
+	st.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+
+	rec := httptest.NewRecorder()
+	newHandler(t, staleCountStore{st}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	body := rec.Body.String()
+	if strings.Count(body, `<ul class="link-list">`) != 1 || strings.Count(body, "</ul>") != 1 {
+		t.Error("streamed item not wrapped in exactly one list")
+	}
+	if strings.Contains(body, "No links yet") {
+		t.Error("empty-state shown although a link was rendered")
+	}
+}
+
+func TestParseTemplatesError(t *testing.T) {
+	broken := fstest.MapFS{
+		"templates/list.html": {Data: []byte("{{range .Links}}unterminated")},
+	}
+	if _, err := parseTemplates(broken); err == nil {
+		t.Error("parseTemplates with broken template: expected error")
+	}
+
+	if _, err := parseTemplates(fstest.MapFS{}); err == nil {
+		t.Error("parseTemplates with no templates: expected error")
+	}
+}
+
+func BenchmarkListLinks(b *testing.B) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for i := 0; i < 50; i++ {
+		st.AddLink(ctx, store.Link{Slug: fmt.Sprintf("link%d", i), URL: "https://example.com"})
+	}
+	h, err := New(Config{}, st)
+	if err != nil {
+		b.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestPoster(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "house/wifi", URL: "https://wifi.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "house/bins", URL: "https://bins.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "house/pay", URL: "https://pay.example.com", Status: store.StatusPending})
+	st.AddLink(ctx, store.Link{Slug: "work", URL: "https://work.example.com"})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/poster?prefix=house/", nil)
+	req.Host = "go"
+	newHandler(t, st).ServePoster(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if n := strings.Count(body, "<svg"); n != 2 {
+		t.Errorf("rendered %d codes, want 2", n)
+	}
+	for _, want := range []string{"go/house/wifi", "go/house/bins", `aria-label="http://go/house/wifi"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"house/pay", "go/work"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("body contains %q", unwanted)
+		}
+	}
+}
+
+func TestPosterBase(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wifi", URL: "https://wifi.example.com"})
+	h := newHandler(t, st)
+
+	rec := httptest.NewRecorder()
+	h.ServePoster(rec, httptest.NewRequest(http.MethodGet, "/admin/poster?base=https://go.example.com/", nil))
+	if !strings.Contains(rec.Body.String(), `aria-label="https://go.example.com/wifi"`) {
+		t.Error("poster does not use the base URL")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServePoster(rec, httptest.NewRequest(http.MethodGet, "/admin/poster?base=javascript:alert(1)", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid base: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestPosterTruncated(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for i := 0; i < posterLimit+1; i++ {
+		st.AddLink(ctx, store.Link{Slug: fmt.Sprintf("link%d", i), URL: "https://example.com"})
+	}
+
+	rec := httptest.NewRecorder()
+	newHandler(t, st).ServePoster(rec, httptest.NewRequest(http.MethodGet, "/admin/poster", nil))
+
+	body := rec.Body.String()
+	if n := strings.Count(body, "<svg"); n != posterLimit {
+		t.Errorf("rendered %d codes, want %d", n, posterLimit)
+	}
+	if !strings.Contains(body, "Only the first") {
+		t.Error("truncation notice missing")
+	}
+}
+
+func TestPreview(t *testing.T) {
+	link := store.Link{Slug: "wiki", URL: "https://www.wiki.example.com/home?a=1&b=2"}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/wiki", nil)
+	req.Host = "go"
+	newHandler(t, store.NewMemory()).ServePreview(rec, req, link)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<meta property="og:title" content="go/wiki">`,
+		`<meta property="og:description" content="→ wiki.example.com">`,
+		`<meta property="og:url" content="http://go/wiki">`,
+		`<meta name="twitter:card" content="summary">`,
+		`url=https://www.wiki.example.com/home?a=1&amp;b=2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestListLinksSnapshots(t *testing.T) {
+	ctx := context.Background()
+	var gone atomic.Bool
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if gone.Load() && r.URL.Path == "/wiki" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "<h1>Wiki</h1>")
+	}))
+	defer dest.Close()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: dest.URL + "/wiki"})
+	st.AddLink(ctx, store.Link{Slug: "cal", URL: dest.URL + "/cal"})
+
+	archiver, err := snapshot.New(snapshot.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiver.Capture(ctx, dest.URL+"/wiki"); err != nil {
+		t.Fatal(err)
+	}
+	// The destination disappears after the snapshot
+	gone.Store(true)
+	checker := health.NewChecker(st, 0)
+	if err := checker.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	st.SetFailover(ctx, "wiki", "http://wiki.lan")
+	st.SetFailover(ctx, "cal", "http://cal.lan")
+	h, err := New(Config{Snapshots: archiver, Health: checker}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `<a href="/admin/snapshots/wiki" class="snapshot">cached copy</a>`) {
+		t.Errorf("page does not offer the snapshot of go/wiki:\n%s", body)
+	}
+	if strings.Count(body, "cached copy") != 1 || strings.Count(body, "destination unreachable") != 1 {
+		t.Errorf("want one snapshot and one broken link:\n%s", body)
+	}
+	// Only the broken link fails over
+	if !strings.Contains(body, "Failover active: go/wiki leads to http://wiki.lan") || strings.Count(body, "Failover active") != 1 {
+		t.Errorf("want a failover banner for go/wiki only:\n%s", body)
+	}
+}
+
+func TestInfoPage(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com", CreatedBy: "alice"})
+	now := time.Now()
+	st.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: now}, {Slug: "wiki", At: now.AddDate(0, 0, -40)}})
+	link, _ := st.GetLink(ctx, "wiki")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/wiki+", nil)
+	req.Host = "go"
+	newHandler(t, st).ServeInfo(rec, req, *link)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<h1>go/wiki</h1>`,
+		`href="https://wiki.example.com"`,
+		` by alice`,
+		`2 in total, 1 in the last 30 days`,
+		`aria-label="http://go/wiki"`,
+		`2 clicks in the last year`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "community", URL: "https://community.example.com", Public: true})
+	st.AddLink(ctx, store.Link{Slug: "🍕", URL: "https://pizza.example.com", Public: true})
+	st.AddLink(ctx, store.Link{Slug: "payroll", URL: "https://payroll.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "pay", URL: "https://pay.example.com", Status: store.StatusPending, Public: true})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil)
+	req.Host = "go.example.com"
+	newHandler(t, st).ServeSitemap(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		"<loc>http://go.example.com/community</loc>",
+		"<loc>http://go.example.com/%F0%9F%8D%95</loc>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("sitemap missing %q:\n%s", want, body)
+		}
+	}
+	if n := strings.Count(body, "<url>"); n != 2 {
+		t.Errorf("sitemap lists %d links, want 2 (private and pending excluded)", n)
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	ctx := context.Background()
+	dest := httptest.NewServer(http.NotFoundHandler())
+	defer dest.Close()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "gone", URL: dest.URL + "/gone"})
+
+	checker := health.NewChecker(st, 0)
+	if err := checker.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	st.AddLink(ctx, store.Link{Slug: "new", URL: "https://new.example.com"})
+	h := newHandler(t, st)
+
+	rec := httptest.NewRecorder()
+	h.StatusPage(checker, false).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/status", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "<strong>1</strong>broken") || !strings.Contains(body, "<strong>1</strong>unknown") {
+		t.Errorf("summary counts missing:\n%s", body)
+	}
+	if strings.Contains(body, "go/gone") {
+		t.Error("summary page lists links")
+	}
+
+	rec = httptest.NewRecorder()
+	h.StatusPage(checker, true).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/status/links?format=json", nil))
+	var detail struct {
+		Broken int
+		Links  []health.Result
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Broken != 1 || len(detail.Links) != 2 || detail.Links[0].Slug != "gone" || detail.Links[0].Problem != "HTTP 404" {
+		t.Errorf("detail = %+v, want broken link first", detail)
+	}
+}
+
+func TestCollectionPage(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "hr", URL: "https://hr.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "laptop", URL: "https://it.example.com/laptop"})
+	st.SaveCollection(ctx, store.Collection{Name: "onboarding", Title: "First week", Slugs: []string{"hr", "laptop", "wiki"}})
+	st.RemoveLink(ctx, "laptop")
+	h := newHandler(t, st)
+
+	rec := httptest.NewRecorder()
+	h.ServeCollection(rec, httptest.NewRequest(http.MethodGet, "/+onboarding", nil), "onboarding")
+	body := rec.Body.String()
+	hr, wiki := strings.Index(body, "go/hr"), strings.Index(body, "go/wiki")
+	if rec.Code != http.StatusOK || !strings.Contains(body, "First week") || hr < 0 || wiki < hr {
+		t.Errorf("collection page (%d) missing title or links in order:\n%s", rec.Code, body)
+	}
+	if strings.Contains(body, "laptop") {
+		t.Error("removed link still listed")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeCollection(rec, httptest.NewRequest(http.MethodGet, "/+missing", nil), "missing")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing collection: status = %d", rec.Code)
+	}
+
+	// The index links to every collection
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `<a href="/+onboarding" title="First week">+onboarding</a>`) {
+		t.Errorf("index does not link the collection:\n%s", rec.Body)
+	}
+}
+
+func TestClosedPage(t *testing.T) {
+	h := newHandler(t, store.NewMemory())
+	link := store.Link{Slug: "games", URL: "https://games.example.com"}
+
+	rec := httptest.NewRecorder()
+	h.ServeClosed(rec, httptest.NewRequest(http.MethodGet, "/games", nil), link, time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC))
+	body := rec.Body.String()
+	if rec.Code != http.StatusForbidden || !strings.Contains(body, "go/games") || !strings.Contains(body, "Friday 15:00") {
+		t.Errorf("closed page (%d) missing slug or opening time:\n%s", rec.Code, body)
+	}
+	if strings.Contains(body, "games.example.com") {
+		t.Error("closed page reveals the destination")
+	}
+}
+
+func TestImpersonationNotice(t *testing.T) {
+	st := store.NewMemory()
+	h := newHandler(t, st)
+	api := httpapi.New(httpapi.Config{Admins: map[string]string{"alice": "pw", "bob": "pw2"}}, st, httpapi.Pages{Index: h, NotFound: h.ServeNotFound})
+	get := func(target string, cookies ...*http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		api.Handler().ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	if body := get("/"); strings.Contains(body, "Viewing as") {
+		t.Error("list shows an impersonation notice without impersonating")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/impersonate", strings.NewReader(`{"user": "bob"}`))
+	req.SetBasicAuth("alice", "pw")
+	rec := httptest.NewRecorder()
+	api.Handler().ServeHTTP(rec, req)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 {
+		t.Fatalf("start impersonating: %d %s", rec.Code, rec.Body)
+	}
+	for _, target := range []string{"/", "/missing"} {
+		if body := get(target, cookies[0]); !strings.Contains(body, "Viewing as <strong>bob</strong>, impersonated by alice") {
+			t.Errorf("%s lacks the impersonation notice:\n%s", target, body)
+		}
+	}
+}
+
+func TestReservedPage(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "launch", Status: store.StatusReserved, CreatedBy: "alice"})
+	h := newHandler(t, st)
+	link, _ := st.GetLink(ctx, "launch")
+
+	rec := httptest.NewRecorder()
+	h.ServeReserved(rec, httptest.NewRequest(http.MethodGet, "/launch", nil), *link)
+	if body := rec.Body.String(); rec.Code != http.StatusNotFound || !strings.Contains(body, "Coming soon") || !strings.Contains(body, "by alice") {
+		t.Errorf("reserved page (%d):\n%s", rec.Code, body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "coming soon · reserved by alice") || strings.Contains(body, "→ </span>") {
+		t.Errorf("list lacks the reservation:\n%s", body)
+	}
+}
+
+func TestNotFoundSuggestions(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, slug := range []string{"wiki", "team-wiki", "wikipedia", "mail"} {
+		st.AddLink(ctx, store.Link{Slug: slug, URL: "https://example.com/" + slug})
+	}
+	st.AddLink(ctx, store.Link{Slug: "wiki-old", URL: "https://old.example.com", Status: store.StatusPending})
+	h := newHandler(t, st)
+
+	if got := h.suggestions(ctx, "wik"); !slices.Equal(got, []string{"wikipedia", "wiki", "team-wiki"}) {
+		t.Errorf("suggestions for wik = %q", got)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeNotFound(rec, httptest.NewRequest(http.MethodGet, "/wik", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `Did you mean <a href="/wikipedia">go/wikipedia</a>`) || strings.Contains(body, "wiki-old") {
+		t.Errorf("404 page suggestions:\n%s", body)
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "404.html"), []byte(`{{.Status}} {{.Slug}}: {{range .Suggestions}}[{{.}}]{{end}} ask {{.Contact}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "500.html"), []byte(`{{.Status}} {{.Title}}: {{.Message}}`), 0o644)
+	st := store.NewMemory()
+	st.AddLink(context.Background(), store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	h, err := New(Config{ErrorPages: dir, Contact: "it@example.com"}, st)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeNotFound(rec, httptest.NewRequest(http.MethodGet, "/wik", nil))
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "404 wik: [wiki] ask it@example.com" {
+		t.Errorf("custom 404 page (%d): %q", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeError(rec, httptest.NewRequest(http.MethodGet, "/wiki", nil), http.StatusServiceUnavailable, "Service unavailable")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "503 Service Unavailable: Service unavailable" {
+		t.Errorf("custom 5xx page (%d): %q", rec.Code, rec.Body)
+	}
+	// Without a 403.html the built-in pages are kept
+	rec = httptest.NewRecorder()
+	h.ServeError(rec, httptest.NewRequest(http.MethodGet, "/pay", nil), http.StatusForbidden, "Waiting for approval")
+	if body := rec.Body.String(); rec.Code != http.StatusForbidden || !strings.Contains(body, "Waiting for approval") || !strings.Contains(body, "Ask it@example.com") {
+		t.Errorf("built-in 403 page (%d):\n%s", rec.Code, body)
+	}
+
+	os.WriteFile(filepath.Join(dir, "403.html"), []byte(`{{.Status`), 0o644)
+	if _, err := New(Config{ErrorPages: dir}, st); err == nil {
+		t.Error("New with a broken error page succeeded")
+	}
+	if _, err := New(Config{ErrorPages: filepath.Join(dir, "missing")}, st); err == nil {
+		t.Error("New with a missing error page directory succeeded")
+	}
+}
+
+func TestAddFormPage(t *testing.T) {
+	h := newHandler(t, store.NewMemory())
+	form := httpapi.AddForm{Paste: "<https://wiki.example.com|Wiki>", Slug: "wiki", URL: "https://wiki.example.com", Format: httpapi.PasteSlack, Error: "Slug already exists"}
+	rec := httptest.NewRecorder()
+	h.ServeAddForm(rec, httptest.NewRequest(http.MethodPost, "/admin/new", nil), form, http.StatusConflict)
+	body := rec.Body.String()
+	for _, want := range []string{`value="wiki"`, `value="https://wiki.example.com"`, "Recognized a Slack link", "Slug already exists", "&lt;https://wiki.example.com|Wiki&gt;"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("add form (%d) lacks %q:\n%s", rec.Code, want, body)
+		}
+	}
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d", rec.Code)
+	}
+}
+
+func TestBanner(t *testing.T) {
+	board, _ := banner.Open("")
+	h, err := New(Config{Banner: board}, store.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `📣 Wiki migration &amp; cleanup <a href="https://wiki.example.com/migration">More</a>`
+
+	pages := map[string]http.HandlerFunc{"list": h.ServeHTTP, "404": h.ServeNotFound}
+	for name, serve := range pages {
+		rec := httptest.NewRecorder()
+		serve(rec, httptest.NewRequest(http.MethodGet, "/old-wiki", nil))
+		if strings.Contains(rec.Body.String(), `class="banner"`) {
+			t.Errorf("%s page shows a banner without one set", name)
+		}
+	}
+
+	board.Set(banner.Banner{Text: "Wiki migration & cleanup", Link: "https://wiki.example.com/migration"})
+	for name, serve := range pages {
+		rec := httptest.NewRecorder()
+		serve(rec, httptest.NewRequest(http.MethodGet, "/old-wiki", nil))
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s page lacks the banner:\n%s", name, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeNotFound(rec, httptest.NewRequest(http.MethodGet, "/old-wiki", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "go/old-wiki") {
+		t.Errorf("404 page (%d):\n%s", rec.Code, rec.Body)
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	board.Set(banner.Banner{Text: "Wiki migration", Expires: &expired})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), "Wiki migration") {
+		t.Error("expired banner shown")
+	}
+}
+
+func TestListLinksOrder(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, slug := range []string{"cal", "mail", "wiki"} {
+		st.AddLink(ctx, store.Link{Slug: slug, URL: "https://" + slug + ".example.com"})
+	}
+	st.RecordClicks(ctx, []store.Click{{Slug: "wiki", At: time.Now()}, {Slug: "wiki", At: time.Now(), FromList: true}, {Slug: "mail", At: time.Now()}})
+	h, err := New(Config{Order: store.OrderAlpha}, st)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	order := func(body string) string {
+		cal, mail, wiki := strings.Index(body, "go/cal"), strings.Index(body, "go/mail"), strings.Index(body, "go/wiki")
+		switch {
+		case cal < mail && mail < wiki:
+			return "alpha"
+		case wiki < mail && mail < cal:
+			return "clicks"
+		}
+		return "other"
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := order(rec.Body.String()); got != "alpha" {
+		t.Errorf("default order = %s, want alpha", got)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "2 clicks (1 from this list)") || strings.Contains(body, "1 click (") {
+		t.Errorf("list opens not shown as such:\n%s", body)
+	}
+
+	// ?order= wins and is remembered in a cookie
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?order=clicks", nil))
+	if got := order(rec.Body.String()); got != "clicks" {
+		t.Errorf("?order=clicks order = %s", got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != orderCookie || cookies[0].Value != "clicks" {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	if !strings.Contains(rec.Body.String(), `<span class="selected">Most used</span>`) {
+		t.Errorf("selector does not mark the current order:\n%s", rec.Body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := order(rec.Body.String()); got != "clicks" {
+		t.Errorf("order with cookie = %s, want clicks", got)
+	}
+
+	// Unknown orders fall back to the default
+	req = httptest.NewRequest(http.MethodGet, "/?order=random", nil)
+	req.AddCookie(&http.Cookie{Name: orderCookie, Value: "random"})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := order(rec.Body.String()); got != "alpha" || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("unknown order = %s, cookies %v", got, rec.Result().Cookies())
+	}
+
+	if _, err := New(Config{Order: "random"}, st); err == nil {
+		t.Error("New with unknown order: expected error")
+	}
+}
+
+func TestStarredAndRecent(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, slug := range []string{"cal", "mail", "wiki"} {
+		st.AddLink(ctx, store.Link{Slug: slug, URL: "https://" + slug + ".example.com"})
+	}
+	h := newHandler(t, st)
+
+	// Visits are remembered most recent first
+	var recent *http.Cookie
+	for _, slug := range []string{"wiki", "cal", "mail", "cal"} {
+		req := httptest.NewRequest(http.MethodGet, "/"+slug, nil)
+		if recent != nil {
+			req.AddCookie(recent)
+		}
+		rec := httptest.NewRecorder()
+		h.RememberVisit(rec, req, slug)
+		recent = rec.Result().Cookies()[0]
+	}
+	if got := readSlugs(cookieRequest(recent), recentCookie); fmt.Sprint(got) != "[cal mail wiki]" {
+		t.Errorf("recent = %v, want [cal mail wiki]", got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?star=mail", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Fatalf("star: status %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+	stars := rec.Result().Cookies()[0]
+	if stars.Name != starsCookie || stars.Value != "mail" {
+		t.Fatalf("star cookie = %v", stars)
+	}
+
+	// Starred links are left out of the recent ones, and removed links
+	// are skipped
+	st.RemoveLink(ctx, "wiki")
+	req := cookieRequest(stars, recent)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, `⭐ <a href="/mail"`) || !strings.Contains(body, `href="/?unstar=mail"`) {
+		t.Errorf("page missing starred strip:\n%s", body)
+	}
+	if !strings.Contains(body, `🕘 <a href="/cal" title="https://cal.example.com" class="recent">go/cal</a></p>`) {
+		t.Errorf("page missing recent strip with cal only:\n%s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?unstar=mail", nil)
+	req.AddCookie(stars)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if c := rec.Result().Cookies(); rec.Code != http.StatusSeeOther || len(c) != 1 || c[0].Value != "" {
+		t.Errorf("unstar: status %d, cookies %v", rec.Code, c)
+	}
+
+	// Without cookies there is no strip
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), "⭐ <a") || strings.Contains(rec.Body.String(), "🕘") {
+		t.Error("strip shown without cookies")
+	}
+}
+
+func cookieRequest(cookies ...*http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+// missLog is a fixed MissLog.
+type missLog []report.Miss
+
+func (m missLog) RecentMisses() []report.Miss { return m }
+
+func TestStatsPage(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "mail", URL: "https://mail.example.com"})
+	now := time.Now()
+	st.RecordClicks(ctx, []store.Click{
+		{Slug: "wiki", At: now}, {Slug: "mail", At: now, FromList: true}, {Slug: "mail", At: now},
+		{Slug: "wiki", At: now.AddDate(0, 0, -20), FromList: true}, {Slug: "wiki", At: now.AddDate(0, 0, -20)},
+		{Slug: "wiki", At: now.AddDate(0, -3, 0)},
+	})
+	h := newHandler(t, st)
+	misses := missLog{{Slug: "wkii", At: now}}
+
+	rec := httptest.NewRecorder()
+	h.StatsPage(misses).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/stats?format=json", nil))
+	var stats struct {
+		Redirects int
+		ListOpens int `json:"list_opens"`
+		Windows   []struct {
+			Days      int
+			Redirects int
+			Top       []report.Count
+		}
+		Misses []report.Miss `json:"recent_misses"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Redirects != 6 || stats.ListOpens != 2 || len(stats.Windows) != 2 || len(stats.Misses) != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if w := stats.Windows[0]; w.Days != 7 || w.Redirects != 3 || fmt.Sprint(w.Top) != "[{mail 2} {wiki 1}]" {
+		t.Errorf("7 days = %+v", w)
+	}
+	if w := stats.Windows[1]; w.Days != 30 || w.Redirects != 5 || fmt.Sprint(w.Top) != "[{wiki 3} {mail 2}]" {
+		t.Errorf("30 days = %+v", w)
+	}
+
+	rec = httptest.NewRecorder()
+	h.StatsPage(misses).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<strong>6</strong>redirects") || !strings.Contains(body, "<strong>2</strong>from the list page") || !strings.Contains(body, "go/wkii") || !strings.Contains(body, "6 clicks in the last year") {
+		t.Errorf("stats page:\n%s", body)
+	}
+}
+
+func TestClickHeatmap(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.AddLink(ctx, store.Link{Slug: "wiki", URL: "https://wiki.example.com"})
+	st.AddLink(ctx, store.Link{Slug: "mail", URL: "https://mail.example.com"})
+	// A Thursday
+	now := time.Date(2026, 10, 15, 18, 0, 0, 0, time.Local)
+	st.RecordClicks(ctx, []store.Click{
+		{Slug: "wiki", At: now}, {Slug: "wiki", At: now}, {Slug: "wiki", At: now}, {Slug: "wiki", At: now},
+		{Slug: "wiki", At: now.AddDate(0, 0, -1)}, {Slug: "mail", At: now.AddDate(0, 0, -1)},
+		{Slug: "wiki", At: now.AddDate(-2, 0, 0)}, // too old
+	})
+	h := newHandler(t, st)
+
+	hm, err := h.clickHeatmap(ctx, "wiki", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 52 full weeks and Sunday to Thursday of this one
+	if hm.From != "2025-10-12" || hm.To != "2026-10-15" || len(hm.Cells) != 52*7+5 || hm.Total != 5 {
+		t.Fatalf("heatmap from %s to %s with %d days and %d clicks", hm.From, hm.To, len(hm.Cells), hm.Total)
+	}
+	today, yesterday, first := hm.Cells[len(hm.Cells)-1], hm.Cells[len(hm.Cells)-2], hm.Cells[0]
+	if today.Clicks != 4 || today.Fill != heatmapFills[4] || today.Y != heatmapTop+4*heatmapCell {
+		t.Errorf("today = %+v", today)
+	}
+	if yesterday.Clicks != 1 || yesterday.Fill != heatmapFills[1] {
+		t.Errorf("yesterday = %+v", yesterday)
+	}
+	if first.Date.Weekday() != time.Sunday || first.Fill != heatmapFills[0] || first.X != heatmapLeft {
+		t.Errorf("first day = %+v", first)
+	}
+	if len(hm.Months) != 13 || hm.Months[0].Name != "Oct" || hm.Months[1].Name != "Nov" {
+		t.Errorf("months = %+v", hm.Months)
+	}
+
+	if hm, err := h.clickHeatmap(ctx, "", now); err != nil || hm.Total != 6 || fmt.Sprint(hm.Days) != "map[2026-10-14:2 2026-10-15:4]" {
+		t.Errorf("heatmap of every link = %v, %v", hm.Days, err)
+	}
+}
@@ -0,0 +1,48 @@
+// k6 load test for the redirect path.
+//
+//   ./loadtest/seed.sh 1000
+//   k6 run -e BASE_URL=http://localhost:8080 loadtest/redirect.js
+//
+// 90% of requests hit seeded slugs (skewed towards a few hot ones), 10% miss.
+// The run fails if the thresholds below are not met.
+import http from 'k6/http';
+import { check } from 'k6';
+
+const BASE_URL = __ENV.BASE_URL || 'http://localhost:8080';
+const LINKS = parseInt(__ENV.LINKS || '1000', 10);
+const RATE = parseInt(__ENV.RATE || '1000', 10);
+
+export const options = {
+  scenarios: {
+    redirects: {
+      executor: 'constant-arrival-rate',
+      rate: RATE,
+      timeUnit: '1s',
+      duration: __ENV.DURATION || '60s',
+      preAllocatedVUs: 50,
+      maxVUs: 200,
+    },
+  },
+  thresholds: {
+    http_req_failed: ['rate<0.01'],
+    'http_req_duration{kind:hit}': ['p(99)<50'],
+    'http_req_duration{kind:miss}': ['p(99)<50'],
+  },
+};
+
+export default function () {
+  const params = { redirects: 0 };
+
+  if (Math.random() < 0.1) {
+    params.tags = { kind: 'miss' };
+    const res = http.get(`${BASE_URL}/missing-${__VU}-${__ITER}`, params);
+    check(res, { 'miss is 404': (r) => r.status === 404 });
+    return;
+  }
+
+  // Square the random number so low-numbered slugs are requested more often
+  const n = Math.floor(Math.pow(Math.random(), 2) * LINKS);
+  params.tags = { kind: 'hit' };
+  const res = http.get(`${BASE_URL}/load${n}`, params);
+  check(res, { 'hit is 302': (r) => r.status === 302 });
+}
@@ -0,0 +1,132 @@
+// Command replay drives a running golinks instance with the scenario of
+// loadtest/redirect.js, for machines without k6: requests start at a
+// constant rate, 90% hit a seeded slug (low numbers more often, by squaring
+// a random number) and 10% miss, redirects are not followed and at most
+// -max-vus requests are in flight. Iterations that find them all busy are
+// dropped, as k6 drops them.
+//
+//	./loadtest/seed.sh 1000
+//	go run ./loadtest/replay -url http://localhost:8080
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// result is one request: whether it was a hit, how long it took and
+// whether it got the status it should.
+type result struct {
+	hit      bool
+	duration time.Duration
+	ok       bool
+}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "golinks instance to drive")
+	links := flag.Int("links", 1000, "number of seeded links, load0 to load<n-1>")
+	rate := flag.Int("rate", 1000, "requests started per second")
+	duration := flag.Duration("duration", 60*time.Second, "how long to start requests")
+	maxVUs := flag.Int("max-vus", 200, "requests in flight at most")
+	flag.Parse()
+	if *links <= 0 || *rate <= 0 || *duration <= 0 || *maxVUs <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		Transport:     &http.Transport{MaxIdleConnsPerHost: *maxVUs},
+		Timeout:       60 * time.Second,
+	}
+	var (
+		mu      sync.Mutex
+		results []result
+		wg      sync.WaitGroup
+	)
+	slots := make(chan struct{}, *maxVUs)
+	dropped := 0
+	interval := time.Second / time.Duration(*rate)
+	iterations := int(duration.Seconds() * float64(*rate))
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		time.Sleep(time.Until(start.Add(time.Duration(i) * interval)))
+		select {
+		case slots <- struct{}{}:
+		default:
+			dropped++
+			continue
+		}
+		hit := rand.Float64() >= 0.1
+		path, want := fmt.Sprintf("/missing-%d", i), http.StatusNotFound
+		if hit {
+			r := rand.Float64()
+			path, want = fmt.Sprintf("/load%d", int(r*r*float64(*links))), http.StatusFound
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			began := time.Now()
+			ok := false
+			if resp, err := client.Get(*baseURL + path); err == nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				ok = resp.StatusCode == want
+			}
+			mu.Lock()
+			results = append(results, result{hit: hit, duration: time.Since(began), ok: ok})
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	failed := 0
+	var hits, misses []time.Duration
+	for _, r := range results {
+		if !r.ok {
+			failed++
+		}
+		if r.hit {
+			hits = append(hits, r.duration)
+		} else {
+			misses = append(misses, r.duration)
+		}
+	}
+	fmt.Printf("requests: %d in %s, %.1f/s\n", len(results), elapsed.Round(time.Millisecond), float64(len(results))/elapsed.Seconds())
+	fmt.Printf("failed:   %.2f%%\n", 100*float64(failed)/float64(max(len(results), 1)))
+	fmt.Printf("dropped:  %d iterations\n", dropped)
+	printDurations("hit", hits)
+	printDurations("miss", misses)
+}
+
+// printDurations prints the spread of durations as k6 summarizes
+// http_req_duration.
+func printDurations(kind string, durations []time.Duration) {
+	if len(durations) == 0 {
+		fmt.Printf("%-5s     none\n", kind+":")
+		return
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	p := func(q float64) string {
+		return ms(durations[int(q*float64(len(durations)-1))])
+	}
+	fmt.Printf("%-5s     avg=%s med=%s p(90)=%s p(95)=%s p(99)=%s max=%s\n", kind+":",
+		ms(sum/time.Duration(len(durations))), p(0.5), p(0.9), p(0.95), p(0.99), p(1))
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000)
+}
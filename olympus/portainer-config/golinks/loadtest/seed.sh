@@ -0,0 +1,21 @@
+#!/bin/sh
+# Seed a golinks instance with N links (load0..load<N-1>) for load testing.
+#
+#   BASE_URL=http://localhost:8080 ADMIN=admin:secretpass ./loadtest/seed.sh 1000
+set -e
+
+BASE_URL=${BASE_URL:-http://localhost:8080}
+COUNT=${1:-1000}
+AUTH=""
+if [ -n "$ADMIN" ]; then
+  AUTH="-u $ADMIN"
+fi
+
+i=0
+while [ "$i" -lt "$COUNT" ]; do
+  curl -s -o /dev/null $AUTH -X POST "$BASE_URL/admin/add" \
+    -H "Content-Type: application/json" \
+    -d "{\"slug\": \"load$i\", \"url\": \"https://example.com/$i\"}"
+  i=$((i + 1))
+done
+echo "Seeded $COUNT links"